@@ -43,6 +43,20 @@ func (s *Stockpile) GetProfileId(name string, at time.Time) (*entity.ProfileId,
   return rpc.ProfileIdFromRpc(profileId)
 }
 
+// queries a server for the offline mode profile id of a given name at a specific time
+func (s *Stockpile) GetOfflineProfileId(name string, at time.Time) (*entity.ProfileId, error) {
+  profileId, err := s.profileService.GetId(context.Background(), &rpc.GetIdRequest{
+    Name:      name,
+    Timestamp: at.Unix(),
+    Offline:   true,
+  })
+  if err != nil {
+    return nil, err
+  }
+
+  return rpc.ProfileIdFromRpc(profileId)
+}
+
 // queries the server for the player profiles which are currently associated to the given names
 func (s *Stockpile) BulkGetProfileId(names []string) ([]*entity.ProfileId, error) {
   response, err := s.profileService.BulkGetId(context.Background(), &rpc.BulkIdRequest{
@@ -71,6 +85,18 @@ func (s *Stockpile) GetNameHistory(id uuid.UUID) (*entity.NameChangeHistory, err
   return rpc.NameHistoryFromRpc(history), nil
 }
 
+// queries the server for all known ownership periods of a given name
+func (s *Stockpile) GetNameTimeline(name string) (*entity.NameTimeline, error) {
+  timeline, err := s.profileService.GetNameTimeline(context.Background(), &rpc.NameRequest{
+    Name: name,
+  })
+  if err != nil {
+    return nil, err
+  }
+
+  return rpc.NameTimelineFromRpc(timeline)
+}
+
 // queries the server for a given profile
 func (s *Stockpile) GetProfile(id uuid.UUID) (*entity.Profile, error) {
   profile, err := s.profileService.GetProfile(context.Background(), &rpc.IdRequest{
@@ -35,12 +35,47 @@ func (s *Stockpile) GetStatus() (*entity.Status, error) {
   return rpc.StatusFromRpc(status), nil
 }
 
-// queries a server for its loaded plugins
-func (s *Stockpile) GetPluginList() ([]*plugin.Metadata, error) {
+// queries a server for the status of its plugins (including plugins which failed to load)
+func (s *Stockpile) GetPluginList() ([]*plugin.Status, error) {
   result, err := s.systemService.GetPlugins(context.Background(), &empty.Empty{})
   if err != nil {
     return nil, err
   }
 
-  return rpc.PluginMetadataListFromRpc(result), nil
+  return rpc.PluginStatusListFromRpc(result), nil
+}
+
+// queries a server for the effective levels of its loggers (the default level is identified by an
+// empty module name)
+func (s *Stockpile) GetLogLevels() (map[string]string, error) {
+  result, err := s.systemService.GetLogLevels(context.Background(), &empty.Empty{})
+  if err != nil {
+    return nil, err
+  }
+
+  return rpc.LogLevelsFromRpc(result), nil
+}
+
+// updates the level of a given logger (or the default level when an empty module name is passed)
+func (s *Stockpile) SetLogLevel(module string, level string) (map[string]string, error) {
+  result, err := s.systemService.SetLogLevel(context.Background(), &rpc.LogLevel{
+    Module: module,
+    Level:  level,
+  })
+  if err != nil {
+    return nil, err
+  }
+
+  return rpc.LogLevelsFromRpc(result), nil
+}
+
+// reloads the server configuration and returns the list of settings which have been changed as a
+// result
+func (s *Stockpile) ReloadConfig() ([]*entity.ConfigChange, error) {
+  result, err := s.systemService.ReloadConfig(context.Background(), &empty.Empty{})
+  if err != nil {
+    return nil, err
+  }
+
+  return rpc.ConfigChangeSetFromRpc(result).Changes, nil
 }
@@ -0,0 +1,20 @@
+plugin-dir = "plugins"
+bind-address = "0.0.0.0:36623"
+ui = true
+legacy-api = false
+
+// stores all entries within a single transactional database file which is locked while the server
+// is running
+storage "bolt" {
+  path = "data/stockpile.db"
+
+  // specifies how long to wait for another instance to release the database lock
+  // lock-timeout = "5s"
+
+  // specifies how often expired entries are removed from the database
+  // sweep-interval = "10m"
+
+  // reclaims the space occupied by removed entries whenever the server starts (the server will
+  // take longer to start up when large databases are compacted)
+  // compact-on-start = false
+}
@@ -0,0 +1,30 @@
+plugin-dir = "plugins"
+bind-address = "0.0.0.0:36623"
+ui = true
+legacy-api = false
+metrics = true
+health = true
+
+// each instance keeps its own in-process cache while modifications and purges are replicated to
+// all other instances via the cluster transport
+storage "mem" {
+  max-entries = 100000
+}
+
+// the redis transport accepts the same connection parameters as the redis storage backend
+cluster "redis" {
+  address = "localhost:6379"
+  // password = "admin1234"
+  // key-prefix = "production:"
+  // channel = "stockpile:events"
+}
+
+// Mojang limits requests per source address - when multiple instances share an address (for
+// instance when placed behind a NAT), they should draw from a shared request budget
+rate-limit "redis" {
+  limit = 600
+  period = "10m"
+
+  address = "localhost:6379"
+  // key = "stockpile:rate-limit"
+}
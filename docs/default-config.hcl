@@ -2,6 +2,9 @@ plugin-dir = "plugins"
 bind-address = "0.0.0.0:36623"
 ui = false
 legacy-api = false
+metrics = false
+health = false
+shutdown-timeout = "30s"
 
 // no storage backend in default - required for actual operation
 // example:
@@ -10,6 +13,31 @@ legacy-api = false
 //   param2 = "hostname:port"
 // }
 
+// resources which are not cached are requested from the Mojang API by default - the chain provider
+// consults multiple providers in order (e.g. providers registered by plugins) and passes requests
+// on to the next provider when a resource is unknown
+upstream "mojang" {}
+// example:
+// upstream "chain" {
+//   provider "example" {
+//     param1 = false
+//   }
+//   provider "mojang" {}
+// }
+
+// requests which exceed the budget are merely reported unless enforcement is enabled (in which case
+// they are rejected or answered using stale data where available)
+rate-limit "local" {
+  limit = 600
+  period = "10m"
+  enforce = false
+}
+
+logging {
+  level = "info"
+  format = "text"
+}
+
 ttl {
   name = "888h"
   name-history = "180h"
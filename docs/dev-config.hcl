@@ -2,8 +2,17 @@ plugin-dir = "plugins"
 bind-address = "127.0.0.1:36623"
 ui = true
 legacy-api = true
+metrics = true
+health = true
 
 storage "mem" {
+  // limits the amount of retained entries and their approximate encoded size (in bytes) - the
+  // least recently used entries are evicted once either limit is exceeded (unbounded when omitted)
+  // max-entries = 100000
+  // max-bytes = 67108864
+
+  // specifies how often expired entries are removed from memory
+  // janitor-interval = "1m"
 }
 
 ttl {
@@ -0,0 +1,22 @@
+plugin-dir = "plugins"
+bind-address = "0.0.0.0:36623"
+ui = true
+legacy-api = false
+
+// the migrating backend reads from its target first, falls back to its source (copying the entry
+// to the target when found) and writes to both backends while all remaining entries are copied in
+// the background - once the migration has completed, the configuration may be switched to the
+// target backend directly
+storage "migrating" {
+  // background-copy = true
+
+  source "file" {
+    path = "data"
+  }
+
+  target "redis" {
+    address = "localhost:6379"
+    // password = "admin1234"
+    database = 0
+  }
+}
@@ -0,0 +1,26 @@
+plugin-dir = "plugins"
+bind-address = "0.0.0.0:36623"
+ui = true
+legacy-api = false
+
+storage "mem" {}
+
+// names which are used on offline mode (e.g. test or LAN) servers are resolved to the identifiers
+// such servers derive from them while all other names are resolved using the Mojang API
+upstream "chain" {
+  provider "offline" {
+    // matched case insensitively (* matches any sequence of characters while ? matches a single
+    // character)
+    patterns = ["lan_*", "test-?"]
+
+    // answers all names (providers which follow are only consulted for profiles and histories
+    // which are not known to this instance)
+    // realm = true
+
+    // limits the amount of names which are retained in order to answer profile and history
+    // requests for offline identifiers
+    // max-names = 10000
+  }
+
+  provider "mojang" {}
+}
@@ -0,0 +1,19 @@
+// plugins may be accompanied by an optional manifest which shares their file name (e.g.
+// "example.hcl" or "example.json" for "example.so" or "stockpile-plugin-example")
+// all settings are optional - plugins which violate their constraints are refused at startup
+
+// refuses to load the plugin on older versions of Stockpile
+min-stockpile-version = "2.0"
+
+// lists plugins (identified by their file names) which need to be loaded beforehand
+depends = ["redis"]
+
+// lists plugins which cannot be loaded alongside this plugin
+conflicts = ["sql"]
+
+// lists the components which are registered by the plugin (in the format reported by
+// "stockpile plugins") - conflicts with other plugins are detected before the plugin is loaded
+components = [
+  "storage:example",
+  "event-sink:example",
+]
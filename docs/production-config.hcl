@@ -2,9 +2,56 @@ plugin-dir = "plugins"
 bind-address = "127.0.0.1:36623"
 ui = true
 legacy-api = false
+metrics = true
+health = true
+shutdown-timeout = "30s"
+
+// restricts the plugins which may be loaded to a set of approved binaries (identified by their
+// SHA-256 checksums as printed by "sha256sum")
+// plugin-allowlist = [
+//   "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+// ]
+
+// permits cross origin requests to the web ui from a given origin
+// cors-override = "https://stockpile.example.org"
+
+// TTLs, rate limits, log levels, plugin parameters and the CORS override may be changed at runtime by sending SIGHUP
+// to the server process (or via "stockpile reload") - all other settings require a restart
 
 // file storage is technically suited for small production deployments, however, a proper storage
 // server like redis is recommended for higher volumes
 storage "file" {
   path = "data"
 }
+
+// writes json formatted log messages to a file which is rotated once it reaches 100 megabytes
+logging {
+  level = "info"
+  format = "json"
+  file = "logs/stockpile.log"
+  max-size = 100
+  max-backups = 5
+
+  modules = {
+    api = "warning"
+  }
+}
+
+// submits spans for all grpc calls, cache operations, storage operations and upstream requests to
+// an OTLP collector (via HTTP)
+// tracing {
+//   endpoint = "localhost:4318"
+//   insecure = true
+//   service-name = "stockpile"
+//   sample-ratio = 0.1
+//   headers = {
+//     authorization = "Bearer 1234"
+//   }
+// }
+
+// passes arbitrary parameters to the plugin with the given file name (e.g. "example.so" or
+// "stockpile-plugin-example") - plugins receive their parameters upon startup as well as whenever
+// the configuration is reloaded
+// plugin "example" {
+//   greeting = "Hello, World!"
+// }
@@ -4,7 +4,45 @@ ui = true
 legacy-api = false
 
 storage "redis" {
+  // one of "standalone", "cluster" or "sentinel"
+  // mode = "standalone"
+
   address = "localhost:6379"
   // password = "admin1234"
+  // secrets may also be read from mounted files or the environment (alternatively, any setting may
+  // be overridden via STOCKPILE_* variables such as STOCKPILE_STORAGE_PASSWORD)
+  // password = file("/run/secrets/redis-password")
+  // password = env("REDIS_PASSWORD")
   database = 0
+
+  // cluster and sentinel deployments accept a list of seed nodes (or sentinels) instead
+  // mode = "cluster"
+  // addresses = ["redis-1:6379", "redis-2:6379", "redis-3:6379"]
+  // replica-reads = "never" // one of "never", "random" or "latency" (cluster mode only)
+
+  // mode = "sentinel"
+  // master-name = "stockpile"
+  // addresses = ["sentinel-1:26379", "sentinel-2:26379"]
+
+  // prefixes all keys (and the invalidation channel) in order to permit multiple deployments to
+  // share a single server
+  // key-prefix = "stockpile:"
+
+  // tls {
+  //   ca-file = "/etc/stockpile/redis-ca.pem"
+  //   cert-file = "/etc/stockpile/redis-client.pem"
+  //   key-file = "/etc/stockpile/redis-client.key"
+  //   server-name = "redis.example.org"
+  // }
+
+  // pool {
+  //   size = 20 // per node
+  //   min-idle = 5
+  //   max-retries = 3
+  //   dial-timeout = "5s"
+  //   read-timeout = "3s"
+  //   write-timeout = "3s"
+  //   pool-timeout = "4s"
+  //   idle-timeout = "5m"
+  // }
 }
@@ -0,0 +1,21 @@
+plugin-dir = "plugins"
+bind-address = "0.0.0.0:36623"
+ui = true
+legacy-api = false
+
+// the tiered backend keeps recently used entries in memory in order to avoid a network round-trip
+// for every lookup - entries are invalidated when modified through any instance which shares the
+// same redis server
+storage "tiered" {
+  l1 {
+    max-entries = 10000
+    ttl = "5m"
+  }
+
+  l2 "redis" {
+    address = "localhost:6379"
+    // password = "admin1234"
+    database = 0
+    // invalidation-channel = "stockpile:invalidations"
+  }
+}
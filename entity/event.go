@@ -17,7 +17,9 @@
 package entity
 
 import (
+  "encoding/json"
   "errors"
+  "fmt"
   "time"
 
   "github.com/google/uuid"
@@ -30,6 +32,128 @@ type Event struct {
   Object interface{}
 }
 
+// represents a serializable version of an event
+type serializableEvent struct {
+  Type   EventType       `json:"type"`
+  Key    json.RawMessage `json:"key,omitempty"`
+  Object json.RawMessage `json:"object,omitempty"`
+}
+
+// represents a serializable version of a profile id key
+type serializableProfileIdKey struct {
+  Name string `json:"name"`
+  At   int64  `json:"at"`
+}
+
+func (e *Event) Serialize() ([]byte, error) {
+  enc := serializableEvent{
+    Type: e.Type,
+  }
+
+  var err error
+  switch key := e.Key.(type) {
+  case nil:
+  case *ProfileIdKey:
+    enc.Key, err = json.Marshal(&serializableProfileIdKey{
+      Name: key.Name,
+      At:   key.At.Unix(),
+    })
+  case *uuid.UUID:
+    enc.Key, err = json.Marshal(key.String())
+  default:
+    return nil, fmt.Errorf("unknown key type: %v", e.Key)
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  if e.Object != nil {
+    obj, ok := e.Object.(interface {
+      Serialize() ([]byte, error)
+    })
+    if !ok {
+      return nil, fmt.Errorf("unknown payload type: %v", e.Object)
+    }
+
+    enc.Object, err = obj.Serialize()
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  return json.Marshal(&enc)
+}
+
+func (e *Event) Deserialize(enc []byte) error {
+  parsed := serializableEvent{}
+  err := json.Unmarshal(enc, &parsed)
+  if err != nil {
+    return err
+  }
+
+  e.Type = parsed.Type
+  e.Key = nil
+  e.Object = nil
+
+  if len(parsed.Key) != 0 {
+    switch parsed.Type {
+    case ProfileIdEvent:
+      key := serializableProfileIdKey{}
+      err = json.Unmarshal(parsed.Key, &key)
+      if err != nil {
+        return err
+      }
+
+      e.Key = &ProfileIdKey{
+        Name: key.Name,
+        At:   time.Unix(key.At, 0),
+      }
+    case NameHistoryEvent, ProfileEvent:
+      var key string
+      err = json.Unmarshal(parsed.Key, &key)
+      if err != nil {
+        return err
+      }
+
+      id, err := uuid.Parse(key)
+      if err != nil {
+        return err
+      }
+      e.Key = &id
+    default:
+      return fmt.Errorf("unexpected key for event of type %d", parsed.Type)
+    }
+  }
+
+  if len(parsed.Object) != 0 {
+    switch parsed.Type {
+    case ProfileIdEvent:
+      obj := &ProfileId{}
+      err = obj.Deserialize(parsed.Object)
+      e.Object = obj
+    case NameHistoryEvent:
+      obj := &NameChangeHistory{}
+      err = obj.Deserialize(parsed.Object)
+      e.Object = obj
+    case ProfileEvent:
+      obj := &Profile{}
+      err = obj.Deserialize(parsed.Object)
+      e.Object = obj
+    case BlacklistEvent:
+      obj := &Blacklist{}
+      err = obj.Deserialize(parsed.Object)
+      e.Object = obj
+    case ConfigEvent:
+      obj := &ConfigChangeSet{}
+      err = obj.Deserialize(parsed.Object)
+      e.Object = obj
+    default:
+      return fmt.Errorf("unknown event type: %d", parsed.Type)
+    }
+  }
+  return err
+}
+
 func (e *Event) ProfileIdPayload() (*ProfileId, error) {
   if e.Type != ProfileIdEvent {
     return nil, errors.New("cannot convert event payload to ProfileId")
@@ -62,6 +186,14 @@ func (e *Event) BlacklistPayload() (*Blacklist, error) {
   return e.Object.(*Blacklist), nil
 }
 
+func (e *Event) ConfigPayload() (*ConfigChangeSet, error) {
+  if e.Type != ConfigEvent {
+    return nil, errors.New("cannot convert event payload to ConfigChangeSet")
+  }
+
+  return e.Object.(*ConfigChangeSet), nil
+}
+
 func (e *Event) ProfileIdKey() (*ProfileIdKey, error) {
   if e.Type != ProfileIdEvent {
     return nil, errors.New("cannot convert event key to ProfileIdKey")
@@ -86,6 +218,7 @@ const (
   NameHistoryEvent EventType = 1
   ProfileEvent     EventType = 2
   BlacklistEvent   EventType = 3
+  ConfigEvent      EventType = 4
 )
 
 type ProfileIdKey struct {
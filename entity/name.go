@@ -19,6 +19,7 @@ package entity
 import (
   "encoding/json"
   "io"
+  "strings"
   "time"
 
   "github.com/google/uuid"
@@ -169,6 +170,21 @@ func ReadProfileIdArray(reader io.Reader) ([]*ProfileId, error) {
   return res, nil
 }
 
+// creates a copy of this association
+func (p *ProfileId) Copy() *ProfileId {
+  c := *p
+  return &c
+}
+
+// creates a deep copy of a list of associations
+func CopyProfileIdArray(profileIds []*ProfileId) []*ProfileId {
+  res := make([]*ProfileId, len(profileIds))
+  for i, profileId := range profileIds {
+    res[i] = profileId.Copy()
+  }
+  return res
+}
+
 // updates the time at which this id has been discovered
 func (p *ProfileId) UpdateDiscovery(at time.Time) {
   p.FirstSeenAt = at
@@ -230,6 +246,33 @@ func (h *NameChangeHistory) Read(reader io.Reader) error {
   return nil
 }
 
+// creates a deep copy of this history
+func (h *NameChangeHistory) Copy() *NameChangeHistory {
+  history := make([]*NameChange, len(h.History))
+  for i, change := range h.History {
+    c := *change
+    history[i] = &c
+  }
+
+  return &NameChangeHistory{
+    History: history,
+  }
+}
+
+// lists the distinct names (ignoring their case) which appear within this history
+func (h *NameChangeHistory) Names() []string {
+  known := make(map[string]bool)
+  names := make([]string, 0, len(h.History))
+  for _, change := range h.History {
+    key := strings.ToLower(change.Name)
+    if !known[key] {
+      known[key] = true
+      names = append(names, change.Name)
+    }
+  }
+  return names
+}
+
 // represents a single name change within a profile's history
 // note that changedToAt and validUntil may be set to UNIX epoch when the entry represents the initial account name
 type NameChange struct {
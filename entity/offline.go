@@ -0,0 +1,78 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package entity
+
+import (
+  "crypto/md5"
+  "time"
+
+  "github.com/google/uuid"
+)
+
+// defines the prefix which offline mode servers prepend to names when deriving profile identifiers
+const OfflineIdPrefix = "OfflinePlayer:"
+
+// derives the profile identifier which offline mode servers assign to a given name
+// the result is a version 3 UUID of "OfflinePlayer:<name>" (as generated by Java's
+// UUID.nameUUIDFromBytes) and is thus case sensitive
+func OfflineId(name string) uuid.UUID {
+  id := uuid.UUID(md5.Sum([]byte(OfflineIdPrefix + name)))
+  id[6] = id[6]&0x0f | 0x30
+  id[8] = id[8]&0x3f | 0x80
+  return id
+}
+
+// evaluates whether the passed identifier may have been derived by an offline mode server
+// (Mojang exclusively assigns version 4 identifiers)
+func IsOfflineId(id uuid.UUID) bool {
+  return id.Version() == 3
+}
+
+// creates the association between a name and its offline mode identifier at a given time
+func NewOfflineProfileId(name string, at time.Time) *ProfileId {
+  profileId := &ProfileId{
+    Id:   OfflineId(name),
+    Name: name,
+  }
+  profileId.UpdateDiscovery(at)
+  return profileId
+}
+
+// creates the name history of an offline mode profile (offline names cannot be changed and the
+// history thus consists of the initial name alone)
+func NewOfflineNameHistory(name string) *NameChangeHistory {
+  epoch := time.Unix(0, 0)
+  return &NameChangeHistory{
+    History: []*NameChange{
+      {
+        Name:        name,
+        ChangedToAt: epoch,
+        ValidUntil:  CalculateNameGracePeriodEnd(epoch),
+      },
+    },
+  }
+}
+
+// creates a synthetic offline mode profile (offline profiles carry neither properties nor
+// textures)
+func NewOfflineProfile(name string) *Profile {
+  return &Profile{
+    Id:         OfflineId(name),
+    Name:       name,
+    Properties: make(map[string]*ProfileProperty),
+  }
+}
@@ -177,6 +177,30 @@ func (p *Profile) Mojang() ([]byte, error) {
   return json.Marshal(enc)
 }
 
+// creates a deep copy of this profile
+func (p *Profile) Copy() *Profile {
+  var properties map[string]*ProfileProperty
+  if p.Properties != nil {
+    properties = make(map[string]*ProfileProperty, len(p.Properties))
+    for key, property := range p.Properties {
+      c := *property
+      properties[key] = &c
+    }
+  }
+
+  var textures *ProfileTextures
+  if p.Textures != nil {
+    textures = p.Textures.Copy()
+  }
+
+  return &Profile{
+    Id:         p.Id,
+    Name:       p.Name,
+    Properties: properties,
+    Textures:   textures,
+  }
+}
+
 type ProfileProperty struct {
   Name      string `json:"name"`
   Value     string `json:"value"`
@@ -220,6 +244,24 @@ type restProfileTextureSpec struct {
   Url string `json:"url"`
 }
 
+// creates a deep copy of this texture set
+func (t *ProfileTextures) Copy() *ProfileTextures {
+  var textures map[string]string
+  if t.Textures != nil {
+    textures = make(map[string]string, len(t.Textures))
+    for key, url := range t.Textures {
+      textures[key] = url
+    }
+  }
+
+  return &ProfileTextures{
+    Timestamp:   t.Timestamp,
+    ProfileId:   t.ProfileId,
+    ProfileName: t.ProfileName,
+    Textures:    textures,
+  }
+}
+
 func (t *ProfileTextures) Serialize() ([]byte, error) {
   enc := &serializableProfileTextures{
     Timestamp:   t.Timestamp.Unix(),
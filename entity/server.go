@@ -71,6 +71,16 @@ func (b *Blacklist) Deserialize(enc []byte) error {
   return nil
 }
 
+// creates a deep copy of this blacklist
+func (b *Blacklist) Copy() *Blacklist {
+  hashes := make([]string, len(b.Hashes))
+  copy(hashes, b.Hashes)
+
+  return &Blacklist{
+    Hashes: hashes,
+  }
+}
+
 // evaluates whether a certain hash is part of a blacklist
 func (b *Blacklist) Contains(hash string) bool {
   for _, blacklistedHash := range b.Hashes {
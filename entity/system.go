@@ -16,13 +16,62 @@
  */
 package entity
 
-import "time"
+import (
+  "encoding/json"
+  "fmt"
+  "time"
+)
 
 // represents status information exposed by the server
 type Status struct {
-  Brand          string
-  Version        string
-  VersionFull    string
-  CommitHash     string
-  BuildTimestamp time.Time
+  Brand            string
+  Version          string
+  VersionFull      string
+  CommitHash       string
+  BuildTimestamp   time.Time
+  Storage          *StorageStatistics
+  StorageAvailable bool
+  StorageError     string
+}
+
+// represents a snapshot of the utilization and access statistics of a storage backend
+// (only available when the backend collects statistics)
+type StorageStatistics struct {
+  Entries     int64
+  Bytes       int64
+  Hits        uint64
+  Misses      uint64
+  Evictions   uint64
+  Expirations uint64
+}
+
+// describes a setting which differs between two configurations
+type ConfigChange struct {
+  Setting string `json:"setting"`
+  Old     string `json:"old"`
+  New     string `json:"new"`
+}
+
+func (c *ConfigChange) String() string {
+  return fmt.Sprintf("%s: \"%s\" -> \"%s\"", c.Setting, c.Old, c.New)
+}
+
+// represents the set of changes which have been applied as part of a configuration reload
+type ConfigChangeSet struct {
+  Changes []*ConfigChange
+}
+
+func (s *ConfigChangeSet) Serialize() ([]byte, error) {
+  return json.Marshal(s.Changes)
+}
+
+func (s *ConfigChangeSet) Deserialize(enc []byte) error {
+  changes := make([]*ConfigChange, 0)
+  err := json.Unmarshal(enc, &changes)
+  if err != nil {
+    return err
+  }
+
+  s.Changes = changes
+  return nil
 }
@@ -0,0 +1,317 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package entity
+
+import (
+  "encoding/json"
+  "sort"
+  "strings"
+  "time"
+
+  "github.com/google/uuid"
+)
+
+// represents the known ownership periods of a name in chronological order
+// timelines are derived from the name histories of the profiles which have used a name and are
+// thus only as complete as the set of histories which have been retrieved so far
+type NameTimeline struct {
+  Name    string
+  Periods []*NameOwnership
+}
+
+// represents a period during which a name has been assigned to a given profile
+// periods which begin at UNIX epoch refer to the initial account name while periods without an
+// end (e.g. a zero until time) refer to the name which was assigned to the profile when its
+// history was last retrieved
+// periods which refer to the nil profile id record times at which the name was not assigned
+type NameOwnership struct {
+  Id        uuid.UUID
+  Name      string
+  From      time.Time
+  Until     time.Time
+  UpdatedAt time.Time
+}
+
+type serializableNameTimeline struct {
+  Name    string                       `json:"name"`
+  Periods []*serializableNameOwnership `json:"periods"`
+}
+
+type serializableNameOwnership struct {
+  Id        string `json:"id"`
+  Name      string `json:"name"`
+  From      int64  `json:"from"`
+  Until     int64  `json:"until"`
+  UpdatedAt int64  `json:"updatedAt"`
+}
+
+// creates an empty timeline for a given name
+func NewNameTimeline(name string) *NameTimeline {
+  return &NameTimeline{
+    Name:    strings.ToLower(name),
+    Periods: make([]*NameOwnership, 0),
+  }
+}
+
+func (t *NameTimeline) Serialize() ([]byte, error) {
+  enc := serializableNameTimeline{
+    Name:    t.Name,
+    Periods: make([]*serializableNameOwnership, len(t.Periods)),
+  }
+  for i, period := range t.Periods {
+    var until int64
+    if !period.IsOpen() {
+      until = period.Until.Unix()
+    }
+
+    enc.Periods[i] = &serializableNameOwnership{
+      Id:        period.Id.String(),
+      Name:      period.Name,
+      From:      period.From.Unix(),
+      Until:     until,
+      UpdatedAt: period.UpdatedAt.Unix(),
+    }
+  }
+  return json.Marshal(&enc)
+}
+
+func (t *NameTimeline) Deserialize(enc []byte) error {
+  parsed := serializableNameTimeline{}
+  err := json.Unmarshal(enc, &parsed)
+  if err != nil {
+    return err
+  }
+
+  t.Name = parsed.Name
+  t.Periods = make([]*NameOwnership, len(parsed.Periods))
+  for i, period := range parsed.Periods {
+    id, err := uuid.Parse(period.Id)
+    if err != nil {
+      return err
+    }
+
+    var until time.Time
+    if period.Until != 0 {
+      until = time.Unix(period.Until, 0)
+    }
+
+    t.Periods[i] = &NameOwnership{
+      Id:        id,
+      Name:      period.Name,
+      From:      time.Unix(period.From, 0),
+      Until:     until,
+      UpdatedAt: time.Unix(period.UpdatedAt, 0),
+    }
+  }
+  return nil
+}
+
+// creates a deep copy of this timeline
+func (t *NameTimeline) Copy() *NameTimeline {
+  periods := make([]*NameOwnership, len(t.Periods))
+  for i, period := range t.Periods {
+    p := *period
+    periods[i] = &p
+  }
+
+  return &NameTimeline{
+    Name:    t.Name,
+    Periods: periods,
+  }
+}
+
+// retrieves the period during which the name has been assigned at a given time
+// nil is returned when the ownership at the given time is unknown
+func (t *NameTimeline) OwnerAt(at time.Time) *NameOwnership {
+  for _, period := range t.Periods {
+    if period.Contains(at) {
+      return period
+    }
+  }
+  return nil
+}
+
+// records that the name is not known to have been assigned to any profile at a given time (e.g.
+// because upstream reports an owner whose name history does not cover the time) and returns the
+// period which covers the time afterwards
+//
+// the recorded period spans the entire gap between the surrounding periods as upstream would report
+// the same owner for any time within the gap - it is shortened or removed once the name history of
+// a profile which owned the name during the gap is merged into the timeline
+func (t *NameTimeline) MarkUnowned(at time.Time, updatedAt time.Time) *NameOwnership {
+  if period := t.OwnerAt(at); period != nil {
+    return period
+  }
+
+  unowned := &NameOwnership{
+    Id:        uuid.Nil,
+    Name:      t.Name,
+    From:      time.Unix(0, 0),
+    UpdatedAt: updatedAt,
+  }
+  for _, period := range t.Periods {
+    if period.From.After(at) {
+      if unowned.IsOpen() || period.From.Before(unowned.Until) {
+        unowned.Until = period.From
+      }
+      continue
+    }
+
+    // open periods are only known to extend until they were last confirmed
+    end := period.Until
+    if period.IsOpen() {
+      end = period.UpdatedAt
+    }
+    if end.After(unowned.From) {
+      unowned.From = end
+    }
+  }
+
+  t.Periods = append(t.Periods, unowned)
+  sort.SliceStable(t.Periods, func(i, j int) bool {
+    return t.Periods[i].From.Before(t.Periods[j].From)
+  })
+  return unowned
+}
+
+// creates a copy of this timeline which omits all periods during which the name is known to have
+// been unowned
+func (t *NameTimeline) Owned() *NameTimeline {
+  periods := make([]*NameOwnership, 0, len(t.Periods))
+  for _, period := range t.Periods {
+    if !period.IsUnowned() {
+      p := *period
+      periods = append(periods, &p)
+    }
+  }
+
+  return &NameTimeline{
+    Name:    t.Name,
+    Periods: periods,
+  }
+}
+
+// evaluates whether the timeline contains at least one period of a given profile
+func (t *NameTimeline) Contains(id uuid.UUID) bool {
+  for _, period := range t.Periods {
+    if period.Id == id {
+      return true
+    }
+  }
+  return false
+}
+
+// replaces all periods of a given profile with the periods derived from its name history
+//
+// as the passed history has been retrieved at the given time, it is considered more accurate than
+// the periods of other profiles which overlap with it: periods which began earlier end once the
+// profile claimed the name (and resume once it released the name again) while periods which began
+// during its ownership are shortened or removed
+func (t *NameTimeline) Update(id uuid.UUID, history *NameChangeHistory, at time.Time) {
+  periods := make([]*NameOwnership, 0, len(t.Periods))
+  for _, period := range t.Periods {
+    if period.Id != id {
+      periods = append(periods, period)
+    }
+  }
+
+  for i, change := range history.History {
+    if !strings.EqualFold(change.Name, t.Name) {
+      continue
+    }
+
+    claimed := &NameOwnership{
+      Id:        id,
+      Name:      change.Name,
+      From:      change.ChangedToAt,
+      UpdatedAt: at,
+    }
+    if i+1 < len(history.History) {
+      claimed.Until = history.History[i+1].ChangedToAt
+    }
+
+    remaining := make([]*NameOwnership, 0, len(periods)+1)
+    for _, period := range periods {
+      if period.IsOverlappingWith(claimed) {
+        if period.From.Before(claimed.From) {
+          // periods which outlast the claimed period are split in order to retain their remainder
+          if !claimed.IsOpen() && (period.IsOpen() || period.Until.After(claimed.Until)) {
+            tail := *period
+            tail.From = claimed.Until
+            remaining = append(remaining, &tail)
+          }
+          period.Until = claimed.From
+        } else if claimed.IsOpen() || (!period.IsOpen() && !period.Until.After(claimed.Until)) {
+          continue
+        } else {
+          period.From = claimed.Until
+        }
+      }
+      remaining = append(remaining, period)
+    }
+    periods = append(remaining, claimed)
+  }
+
+  sort.SliceStable(periods, func(i, j int) bool {
+    return periods[i].From.Before(periods[j].From)
+  })
+  t.Periods = periods
+}
+
+// evaluates whether this period refers to a time during which the name is known not to have been
+// assigned to any profile
+func (o *NameOwnership) IsUnowned() bool {
+  return o.Id == uuid.Nil
+}
+
+// evaluates whether the profile still owned the name when its history was last retrieved
+func (o *NameOwnership) IsOpen() bool {
+  return o.Until.IsZero()
+}
+
+// evaluates whether the name is known to have been assigned to the profile at a given time
+// open periods are only considered until the time at which they were last confirmed
+func (o *NameOwnership) Contains(at time.Time) bool {
+  if o.From.After(at) {
+    return false
+  }
+  if o.IsOpen() {
+    return !at.After(o.UpdatedAt)
+  }
+  return at.Before(o.Until)
+}
+
+// evaluates whether two periods overlap at any point in time
+func (o *NameOwnership) IsOverlappingWith(other *NameOwnership) bool {
+  return (o.IsOpen() || o.Until.After(other.From)) && (other.IsOpen() || other.Until.After(o.From))
+}
+
+// converts this period into a name association
+func (o *NameOwnership) ProfileId() *ProfileId {
+  profileId := &ProfileId{
+    Id:          o.Id,
+    Name:        o.Name,
+    FirstSeenAt: o.From,
+    LastSeenAt:  o.Until,
+    ValidUntil:  o.Until,
+  }
+  if o.IsOpen() {
+    profileId.LastSeenAt = o.UpdatedAt
+    profileId.ValidUntil = CalculateNameGracePeriodEnd(o.UpdatedAt)
+  }
+  return profileId
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package entity_test
+
+import (
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/google/uuid"
+)
+
+var epoch = time.Unix(1500000000, 0)
+
+// creates a point in time relative to a fixed epoch
+func day(n int) time.Time {
+  return epoch.Add(time.Duration(n) * 24 * time.Hour)
+}
+
+// verifies that a timeline consists of the expected periods in order
+func expectPeriods(t *testing.T, timeline *entity.NameTimeline, expected ...*entity.NameOwnership) {
+  t.Helper()
+  if len(timeline.Periods) != len(expected) {
+    t.Fatalf("expected %d periods but got %d", len(expected), len(timeline.Periods))
+  }
+  for i, period := range timeline.Periods {
+    if period.Id != expected[i].Id || !period.From.Equal(expected[i].From) || !period.Until.Equal(expected[i].Until) {
+      t.Errorf("expected period %d to be %s from %s until %s but got %s from %s until %s", i,
+        expected[i].Id, expected[i].From, expected[i].Until, period.Id, period.From, period.Until)
+    }
+  }
+}
+
+func TestNameTimelineUpdateTruncate(t *testing.T) {
+  a := uuid.New()
+  b := uuid.New()
+
+  timeline := entity.NewNameTimeline("Notch")
+  timeline.Update(a, &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "Notch", ChangedToAt: day(0)},
+  }}, day(10))
+  timeline.Update(b, &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "jeb", ChangedToAt: day(0)},
+    {Name: "notch", ChangedToAt: day(5)},
+  }}, day(10))
+
+  expectPeriods(t, timeline,
+    &entity.NameOwnership{Id: a, From: day(0), Until: day(5)},
+    &entity.NameOwnership{Id: b, From: day(5)},
+  )
+}
+
+func TestNameTimelineUpdateSplit(t *testing.T) {
+  a := uuid.New()
+  b := uuid.New()
+
+  timeline := entity.NewNameTimeline("Notch")
+  timeline.Update(a, &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "Notch", ChangedToAt: day(0)},
+    {Name: "Dinnerbone", ChangedToAt: day(20)},
+  }}, day(30))
+
+  // the name has been claimed by another profile in the middle of the known period
+  timeline.Update(b, &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "jeb", ChangedToAt: day(0)},
+    {Name: "notch", ChangedToAt: day(5)},
+    {Name: "jeb", ChangedToAt: day(10)},
+  }}, day(30))
+
+  expectPeriods(t, timeline,
+    &entity.NameOwnership{Id: a, From: day(0), Until: day(5)},
+    &entity.NameOwnership{Id: b, From: day(5), Until: day(10)},
+    &entity.NameOwnership{Id: a, From: day(10), Until: day(20)},
+  )
+
+  // open periods retain their open end
+  timeline = entity.NewNameTimeline("Notch")
+  timeline.Update(a, &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "Notch", ChangedToAt: day(0)},
+  }}, day(30))
+  timeline.Update(b, &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "jeb", ChangedToAt: day(0)},
+    {Name: "notch", ChangedToAt: day(5)},
+    {Name: "jeb", ChangedToAt: day(10)},
+  }}, day(30))
+
+  expectPeriods(t, timeline,
+    &entity.NameOwnership{Id: a, From: day(0), Until: day(5)},
+    &entity.NameOwnership{Id: b, From: day(5), Until: day(10)},
+    &entity.NameOwnership{Id: a, From: day(10)},
+  )
+  if owner := timeline.OwnerAt(day(15)); owner == nil || owner.Id != a {
+    t.Errorf("expected name to be owned by %s again after it has been released", a)
+  }
+}
+
+func TestNameTimelineMarkUnowned(t *testing.T) {
+  a := uuid.New()
+  b := uuid.New()
+
+  timeline := entity.NewNameTimeline("Notch")
+  timeline.Update(a, &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "jeb", ChangedToAt: day(0)},
+    {Name: "Notch", ChangedToAt: day(5)},
+    {Name: "jeb", ChangedToAt: day(10)},
+  }}, day(30))
+
+  // the gap between the known periods is recorded as a whole
+  unowned := timeline.MarkUnowned(day(2), day(30))
+  if !unowned.IsUnowned() {
+    t.Fatal("expected unowned period to be recorded")
+  }
+  expectPeriods(t, timeline,
+    &entity.NameOwnership{Id: uuid.Nil, From: time.Unix(0, 0), Until: day(5)},
+    &entity.NameOwnership{Id: a, From: day(5), Until: day(10)},
+  )
+  if owner := timeline.OwnerAt(day(1)); owner == nil || !owner.IsUnowned() {
+    t.Errorf("expected name to be unowned throughout the gap but got %v", owner)
+  }
+  if len(timeline.Owned().Periods) != 1 {
+    t.Errorf("expected unowned periods to be omitted from owned periods")
+  }
+
+  // gaps following the last known period remain open until the time at which they were recorded
+  timeline.MarkUnowned(day(15), day(30))
+  if owner := timeline.OwnerAt(day(20)); owner == nil || !owner.IsUnowned() {
+    t.Errorf("expected name to be unowned after it has been released but got %v", owner)
+  }
+  if owner := timeline.OwnerAt(day(31)); owner != nil {
+    t.Errorf("expected ownership after the time of recording to be unknown but got %v", owner)
+  }
+
+  // unowned periods are shortened when a profile which owned the name is discovered later on
+  timeline.Update(b, &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "jeb", ChangedToAt: day(0)},
+    {Name: "notch", ChangedToAt: day(12)},
+    {Name: "jeb", ChangedToAt: day(14)},
+  }}, day(30))
+  expectPeriods(t, timeline,
+    &entity.NameOwnership{Id: uuid.Nil, From: time.Unix(0, 0), Until: day(5)},
+    &entity.NameOwnership{Id: a, From: day(5), Until: day(10)},
+    &entity.NameOwnership{Id: uuid.Nil, From: day(10), Until: day(12)},
+    &entity.NameOwnership{Id: b, From: day(12), Until: day(14)},
+    &entity.NameOwnership{Id: uuid.Nil, From: day(14)},
+  )
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+  "fmt"
+  "strings"
+
+  "github.com/dotStart/Stockpile/stockpile/cluster"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/go-redis/redis"
+  "github.com/google/uuid"
+  "github.com/hashicorp/hcl2/gohcl"
+  "github.com/hashicorp/hcl2/hcl"
+  "github.com/op/go-logging"
+)
+
+var defaultEventChannel = "stockpile:events"
+
+// provides a cluster event bus which distributes messages via redis pub/sub
+type redisEventBus struct {
+  logger     *logging.Logger
+  client     redis.UniversalClient
+  channel    string
+  instanceId string
+  pubsub     *redis.PubSub
+}
+
+// accepts the same connection parameters as the storage backend
+type RedisEventBusConfig struct {
+  Channel    *string  `hcl:"channel,attr"`
+  Connection hcl.Body `hcl:",remain"`
+}
+
+func NewRedisEventBus(cfg *server.Config) (cluster.EventBus, error) {
+  busCfg := &RedisEventBusConfig{}
+  diag := gohcl.DecodeBody(cfg.Cluster.Parameters, server.EvalContext(), busCfg)
+  if diag.HasErrors() {
+    return nil, fmt.Errorf("illegal cluster configuration: %s", diag.Error())
+  }
+
+  connCfg := &RedisStorageBackendConfig{}
+  diag = gohcl.DecodeBody(busCfg.Connection, server.EvalContext(), connCfg)
+  if diag.HasErrors() {
+    return nil, fmt.Errorf("illegal cluster configuration: %s", diag.Error())
+  }
+  connCfg.applyDefaults()
+
+  channel := *connCfg.KeyPrefix + defaultEventChannel
+  if busCfg.Channel != nil {
+    channel = *busCfg.Channel
+  }
+
+  client, err := connCfg.client()
+  if err != nil {
+    return nil, fmt.Errorf("illegal cluster configuration: %s", err)
+  }
+
+  err = ping(client)
+  if err != nil {
+    client.Close()
+    return nil, fmt.Errorf("cannot reach configured redis server: %s", err)
+  }
+
+  return &redisEventBus{
+    logger:     logging.MustGetLogger("redis-bus"),
+    client:     client,
+    channel:    channel,
+    instanceId: uuid.New().String(),
+  }, nil
+}
+
+func (b *redisEventBus) Publish(msg *cluster.Message) error {
+  enc, err := msg.Serialize()
+  if err != nil {
+    return err
+  }
+
+  // messages are encoded as "<instance> <message>"
+  return b.client.Publish(b.channel, b.instanceId+" "+string(enc)).Err()
+}
+
+func (b *redisEventBus) Subscribe(handler cluster.Handler) error {
+  pubsub := b.client.Subscribe(b.channel)
+  _, err := pubsub.Receive()
+  if err != nil {
+    pubsub.Close()
+    return err
+  }
+  b.pubsub = pubsub
+
+  go func() {
+    for payload := range pubsub.Channel() {
+      elements := strings.SplitN(payload.Payload, " ", 2)
+      if len(elements) != 2 || elements[0] == b.instanceId {
+        continue
+      }
+
+      msg := &cluster.Message{}
+      err := msg.Deserialize([]byte(elements[1]))
+      if err != nil {
+        b.logger.Warningf("received malformed message from instance %s: %s", elements[0], err)
+        continue
+      }
+
+      b.logger.Debugf("received %s message from instance %s", msg.Type, elements[0])
+      handler(msg)
+    }
+  }()
+  return nil
+}
+
+func (b *redisEventBus) Close() error {
+  if b.pubsub != nil {
+    b.pubsub.Close()
+  }
+  return b.client.Close()
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/cluster"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+)
+
+// opens an event bus on a given channel
+func openEventBus(t *testing.T, parameters string, channel string) cluster.EventBus {
+  cfg := servertest.LoadConfig(t, "cluster \"redis\" {\nchannel = \""+channel+"\"\n"+parameters+"\n}\n")
+  bus, err := NewRedisEventBus(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return bus
+}
+
+func TestEventBus(t *testing.T) {
+  parameters := standaloneParameters(t)
+  channel := randomPrefix() + "events"
+
+  a := openEventBus(t, parameters, channel)
+  defer a.Close()
+  b := openEventBus(t, parameters, channel)
+  defer b.Close()
+
+  own := make(chan *cluster.Message, 4)
+  received := make(chan *cluster.Message, 4)
+  err := a.Subscribe(func(msg *cluster.Message) { own <- msg })
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = b.Subscribe(func(msg *cluster.Message) { received <- msg })
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  err = a.Publish(&cluster.Message{Type: cluster.PurgeMessage, Payload: []byte("payload")})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  select {
+  case msg := <-received:
+    if msg.Type != cluster.PurgeMessage || string(msg.Payload) != "payload" {
+      t.Fatalf("expected purge message but got %s message with payload %s", msg.Type, msg.Payload)
+    }
+  case <-time.After(deliveryTimeout):
+    t.Fatal("expected message to be delivered")
+  }
+
+  select {
+  case msg := <-own:
+    t.Fatalf("expected own messages to be ignored but got %s message", msg.Type)
+  case <-time.After(time.Millisecond * 100):
+  }
+}
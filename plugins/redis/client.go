@@ -0,0 +1,274 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+  "crypto/tls"
+  "crypto/x509"
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "time"
+
+  "github.com/go-redis/redis"
+)
+
+// defines the supported connection modes
+const (
+  modeStandalone = "standalone"
+  modeCluster    = "cluster"
+  modeSentinel   = "sentinel"
+)
+
+// defines the supported replica read strategies
+const (
+  replicaReadsNever   = "never"
+  replicaReadsRandom  = "random"
+  replicaReadsLatency = "latency"
+)
+
+type RedisTlsConfig struct {
+  CaFile             *string `hcl:"ca-file,attr"`
+  CertFile           *string `hcl:"cert-file,attr"`
+  KeyFile            *string `hcl:"key-file,attr"`
+  ServerName         *string `hcl:"server-name,attr"`
+  InsecureSkipVerify *bool   `hcl:"insecure-skip-verify,attr"`
+}
+
+type RedisPoolConfig struct {
+  Size         *int    `hcl:"size,attr"`
+  MinIdle      *int    `hcl:"min-idle,attr"`
+  MaxRetries   *int    `hcl:"max-retries,attr"`
+  DialTimeout  *string `hcl:"dial-timeout,attr"`
+  ReadTimeout  *string `hcl:"read-timeout,attr"`
+  WriteTimeout *string `hcl:"write-timeout,attr"`
+  PoolTimeout  *string `hcl:"pool-timeout,attr"`
+  IdleTimeout  *string `hcl:"idle-timeout,attr"`
+}
+
+// creates a tls configuration based on the user configuration
+func (c *RedisTlsConfig) build() (*tls.Config, error) {
+  cfg := &tls.Config{}
+  if c.ServerName != nil {
+    cfg.ServerName = *c.ServerName
+  }
+  if c.InsecureSkipVerify != nil {
+    cfg.InsecureSkipVerify = *c.InsecureSkipVerify
+  }
+
+  if c.CaFile != nil {
+    enc, err := ioutil.ReadFile(*c.CaFile)
+    if err != nil {
+      return nil, fmt.Errorf("failed to read ca file: %s", err)
+    }
+
+    cfg.RootCAs = x509.NewCertPool()
+    if !cfg.RootCAs.AppendCertsFromPEM(enc) {
+      return nil, fmt.Errorf("failed to read ca file: no certificates found")
+    }
+  }
+
+  if c.CertFile != nil || c.KeyFile != nil {
+    if c.CertFile == nil || c.KeyFile == nil {
+      return nil, errors.New("cert-file and key-file must be specified together")
+    }
+
+    cert, err := tls.LoadX509KeyPair(*c.CertFile, *c.KeyFile)
+    if err != nil {
+      return nil, fmt.Errorf("failed to load client certificate: %s", err)
+    }
+    cfg.Certificates = []tls.Certificate{cert}
+  }
+
+  return cfg, nil
+}
+
+// parses an optional duration parameter
+func parseOptionalDuration(name string, value *string) (time.Duration, error) {
+  if value == nil {
+    return 0, nil
+  }
+
+  duration, err := time.ParseDuration(*value)
+  if err != nil {
+    return 0, fmt.Errorf("illegal %s: %s", name, err)
+  }
+  return duration, nil
+}
+
+// converts the user configuration into a set of client options which are shared between all
+// connection modes (zero values select the client defaults)
+func (c *RedisStorageBackendConfig) options() (*redis.UniversalOptions, error) {
+  opts := &redis.UniversalOptions{
+    Password: *c.Password,
+    DB:       *c.DatabaseId,
+  }
+
+  if c.Address != nil {
+    opts.Addrs = append(opts.Addrs, *c.Address)
+  }
+  if c.Addresses != nil {
+    opts.Addrs = append(opts.Addrs, *c.Addresses...)
+  }
+  if len(opts.Addrs) == 0 {
+    return nil, errors.New("at least one address is required")
+  }
+
+  if c.Tls != nil {
+    tlsCfg, err := c.Tls.build()
+    if err != nil {
+      return nil, fmt.Errorf("illegal tls configuration: %s", err)
+    }
+    opts.TLSConfig = tlsCfg
+  }
+
+  if c.Pool != nil {
+    if c.Pool.Size != nil {
+      opts.PoolSize = *c.Pool.Size
+    }
+    if c.Pool.MinIdle != nil {
+      opts.MinIdleConns = *c.Pool.MinIdle
+    }
+    if c.Pool.MaxRetries != nil {
+      opts.MaxRetries = *c.Pool.MaxRetries
+    }
+
+    var err error
+    durations := []struct {
+      name  string
+      value *string
+      dst   *time.Duration
+    }{
+      {"dial timeout", c.Pool.DialTimeout, &opts.DialTimeout},
+      {"read timeout", c.Pool.ReadTimeout, &opts.ReadTimeout},
+      {"write timeout", c.Pool.WriteTimeout, &opts.WriteTimeout},
+      {"pool timeout", c.Pool.PoolTimeout, &opts.PoolTimeout},
+      {"idle timeout", c.Pool.IdleTimeout, &opts.IdleTimeout},
+    }
+    for _, d := range durations {
+      *d.dst, err = parseOptionalDuration(d.name, d.value)
+      if err != nil {
+        return nil, fmt.Errorf("illegal pool configuration: %s", err)
+      }
+    }
+  }
+
+  switch *c.ReplicaReads {
+  case replicaReadsNever:
+  case replicaReadsRandom:
+    opts.RouteRandomly = true
+  case replicaReadsLatency:
+    opts.RouteByLatency = true
+  default:
+    return nil, fmt.Errorf("illegal replica read strategy \"%s\": must be one of %s, %s or %s", *c.ReplicaReads, replicaReadsNever, replicaReadsRandom, replicaReadsLatency)
+  }
+  opts.ReadOnly = opts.RouteRandomly || opts.RouteByLatency
+
+  return opts, nil
+}
+
+// creates a new redis client for the configured connection mode
+func (c *RedisStorageBackendConfig) client() (redis.UniversalClient, error) {
+  opts, err := c.options()
+  if err != nil {
+    return nil, err
+  }
+
+  if opts.ReadOnly && *c.Mode != modeCluster {
+    return nil, fmt.Errorf("replica reads are only supported in %s mode", modeCluster)
+  }
+
+  switch *c.Mode {
+  case modeStandalone:
+    if len(opts.Addrs) != 1 {
+      return nil, fmt.Errorf("exactly one address is required in %s mode", modeStandalone)
+    }
+
+    return redis.NewClient(&redis.Options{
+      Addr:         opts.Addrs[0],
+      Password:     opts.Password,
+      DB:           opts.DB,
+      MaxRetries:   opts.MaxRetries,
+      DialTimeout:  opts.DialTimeout,
+      ReadTimeout:  opts.ReadTimeout,
+      WriteTimeout: opts.WriteTimeout,
+      PoolSize:     opts.PoolSize,
+      MinIdleConns: opts.MinIdleConns,
+      PoolTimeout:  opts.PoolTimeout,
+      IdleTimeout:  opts.IdleTimeout,
+      TLSConfig:    opts.TLSConfig,
+    }), nil
+  case modeCluster:
+    if opts.DB != 0 {
+      return nil, fmt.Errorf("database selection is not supported in %s mode", modeCluster)
+    }
+
+    return redis.NewClusterClient(&redis.ClusterOptions{
+      Addrs:          opts.Addrs,
+      ReadOnly:       opts.ReadOnly,
+      RouteByLatency: opts.RouteByLatency,
+      RouteRandomly:  opts.RouteRandomly,
+      Password:       opts.Password,
+      MaxRetries:     opts.MaxRetries,
+      DialTimeout:    opts.DialTimeout,
+      ReadTimeout:    opts.ReadTimeout,
+      WriteTimeout:   opts.WriteTimeout,
+      PoolSize:       opts.PoolSize,
+      MinIdleConns:   opts.MinIdleConns,
+      PoolTimeout:    opts.PoolTimeout,
+      IdleTimeout:    opts.IdleTimeout,
+      TLSConfig:      opts.TLSConfig,
+    }), nil
+  case modeSentinel:
+    if c.MasterName == nil {
+      return nil, fmt.Errorf("master-name is required in %s mode", modeSentinel)
+    }
+
+    return redis.NewFailoverClient(&redis.FailoverOptions{
+      MasterName:    *c.MasterName,
+      SentinelAddrs: opts.Addrs,
+      Password:      opts.Password,
+      DB:            opts.DB,
+      MaxRetries:    opts.MaxRetries,
+      DialTimeout:   opts.DialTimeout,
+      ReadTimeout:   opts.ReadTimeout,
+      WriteTimeout:  opts.WriteTimeout,
+      PoolSize:      opts.PoolSize,
+      MinIdleConns:  opts.MinIdleConns,
+      PoolTimeout:   opts.PoolTimeout,
+      IdleTimeout:   opts.IdleTimeout,
+      TLSConfig:     opts.TLSConfig,
+    }), nil
+  }
+
+  return nil, fmt.Errorf("illegal mode \"%s\": must be one of %s, %s or %s", *c.Mode, modeStandalone, modeCluster, modeSentinel)
+}
+
+// verifies whether all redis servers (or all masters within a cluster) are reachable
+func ping(client redis.UniversalClient) error {
+  if cluster, ok := client.(*redis.ClusterClient); ok {
+    return cluster.ForEachMaster(func(client *redis.Client) error {
+      err := client.Ping().Err()
+      if err != nil {
+        return fmt.Errorf("node %s: %s", client.Options().Addr, err)
+      }
+      return nil
+    })
+  }
+
+  return client.Ping().Err()
+}
@@ -22,13 +22,16 @@ import (
 
 var version string
 var Metadata = plugin.Metadata{
-  Name:    "Redis Storage Backend",
-  Version: version,
-  Authors: []string{"Johannes \".start\" Donath"},
-  Website: "https://dotStart.github.io/Stockpile",
+  Name:       "Redis Storage Backend",
+  Version:    version,
+  Authors:    []string{"Johannes \".start\" Donath"},
+  Website:    "https://dotStart.github.io/Stockpile",
+  ApiVersion: plugin.ApiVersion,
 }
 
 func InitializePlugin(ctx *plugin.Context) error {
   ctx.RegisterStorageBackend("redis", NewRedisStorageBackend)
+  ctx.RegisterEventBus("redis", NewRedisEventBus)
+  ctx.RegisterRateLimitStore("redis", NewRedisRateLimitStore)
   return nil
 }
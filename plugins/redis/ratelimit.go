@@ -0,0 +1,197 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+  "fmt"
+  "strconv"
+  "sync"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/go-redis/redis"
+  "github.com/hashicorp/hcl2/gohcl"
+  "github.com/hashicorp/hcl2/hcl"
+)
+
+var defaultRateLimitKey = "stockpile:rate-limit"
+
+// atomically replenishes the token bucket stored within KEYS[1] and consumes ARGV[3] tokens from
+// it (if sufficient tokens are available)
+//
+// the server clock is used in order to avoid skew between instances which is why commands are
+// replicated by effect rather than by script
+//
+// ARGV[1] - bucket capacity
+// ARGV[2] - replenishment period (in microseconds)
+// ARGV[3] - amount of tokens to consume (zero in order to solely retrieve the bucket state)
+//
+// returns a tuple consisting of a flag which indicates whether the tokens have been consumed and
+// the amount of remaining tokens
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated-at')
+local tokens = tonumber(state[1]) or limit
+local updatedAt = tonumber(state[2]) or now
+
+tokens = math.min(limit, tokens + math.max(0, now - updatedAt) * limit / period)
+
+local granted = 0
+if cost > 0 and tokens >= cost then
+  tokens = tokens - cost
+  granted = 1
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'updated-at', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(period / 1000))
+
+return {granted, tostring(tokens)}
+`)
+
+// provides a rate limit store which keeps its token bucket within redis in order to share the
+// upstream request budget between all instances which connect to the same server
+type redisRateLimitStore struct {
+  client redis.UniversalClient
+  key    string
+
+  mutex  sync.RWMutex
+  limit  int
+  period int64
+}
+
+// accepts the same connection parameters as the storage backend
+type RedisRateLimitStoreConfig struct {
+  Key        *string  `hcl:"key,attr"`
+  Connection hcl.Body `hcl:",remain"`
+}
+
+func NewRedisRateLimitStore(cfg *server.Config) (ratelimit.Store, error) {
+  storeCfg := &RedisRateLimitStoreConfig{}
+  diag := gohcl.DecodeBody(cfg.RateLimit.Parameters, server.EvalContext(), storeCfg)
+  if diag.HasErrors() {
+    return nil, fmt.Errorf("illegal rate limit configuration: %s", diag.Error())
+  }
+
+  connCfg := &RedisStorageBackendConfig{}
+  diag = gohcl.DecodeBody(storeCfg.Connection, server.EvalContext(), connCfg)
+  if diag.HasErrors() {
+    return nil, fmt.Errorf("illegal rate limit configuration: %s", diag.Error())
+  }
+  connCfg.applyDefaults()
+
+  key := *connCfg.KeyPrefix + defaultRateLimitKey
+  if storeCfg.Key != nil {
+    key = *storeCfg.Key
+  }
+
+  client, err := connCfg.client()
+  if err != nil {
+    return nil, fmt.Errorf("illegal rate limit configuration: %s", err)
+  }
+
+  err = ping(client)
+  if err != nil {
+    client.Close()
+    return nil, fmt.Errorf("cannot reach configured redis server: %s", err)
+  }
+
+  return &redisRateLimitStore{
+    client: client,
+    key:    key,
+    limit:  *cfg.RateLimit.Limit,
+    period: int64(cfg.RateLimit.Period / 1000),
+  }, nil
+}
+
+// evaluates the token bucket script with a given cost and returns its result
+func (s *redisRateLimitStore) consume(cost int) (bool, float64, error) {
+  s.mutex.RLock()
+  limit, period := s.limit, s.period
+  s.mutex.RUnlock()
+
+  res, err := tokenBucketScript.Run(s.client, []string{s.key}, limit, period, cost).Result()
+  if err != nil {
+    return false, 0, err
+  }
+
+  values, ok := res.([]interface{})
+  if !ok || len(values) != 2 {
+    return false, 0, fmt.Errorf("illegal script result: %v", res)
+  }
+  granted, ok := values[0].(int64)
+  if !ok {
+    return false, 0, fmt.Errorf("illegal script result: %v", res)
+  }
+  encodedTokens, ok := values[1].(string)
+  if !ok {
+    return false, 0, fmt.Errorf("illegal script result: %v", res)
+  }
+  tokens, err := strconv.ParseFloat(encodedTokens, 64)
+  if err != nil {
+    return false, 0, fmt.Errorf("illegal script result: %s", err)
+  }
+
+  return granted == 1, tokens, nil
+}
+
+func (s *redisRateLimitStore) Take() (bool, error) {
+  granted, _, err := s.consume(1)
+  return granted, err
+}
+
+func (s *redisRateLimitStore) Allocation() (uint64, error) {
+  _, tokens, err := s.consume(0)
+  if err != nil {
+    return 0, err
+  }
+
+  allocation := int(s.Capacity()) - int(tokens)
+  if allocation < 0 {
+    return 0, nil
+  }
+  return uint64(allocation), nil
+}
+
+func (s *redisRateLimitStore) Capacity() uint64 {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+  return uint64(s.limit)
+}
+
+// updates the parameters which are passed to the token bucket script
+// the bucket within redis adapts on its next evaluation as its state is clamped to the limit
+func (s *redisRateLimitStore) Reconfigure(limit int, period time.Duration) error {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.limit = limit
+  s.period = int64(period / 1000)
+  return nil
+}
+
+func (s *redisRateLimitStore) Close() error {
+  return s.client.Close()
+}
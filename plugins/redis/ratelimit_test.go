@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+)
+
+// opens a rate limit store which shares a given bucket
+func openRateLimitStore(t *testing.T, parameters string, key string, limit string) ratelimit.Store {
+  cfg := servertest.LoadConfig(t, "rate-limit \"redis\" {\nlimit = "+limit+"\nperiod = \"1h\"\nkey = \""+key+"\"\n"+parameters+"\n}\n")
+  store, err := NewRedisRateLimitStore(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return store
+}
+
+func TestTokenBucket(t *testing.T) {
+  parameters := standaloneParameters(t)
+  key := randomPrefix() + "rate-limit"
+  defer purge(t, parameters, key)
+
+  // the bucket replenishes far too slowly to be observed within the test
+  a := openRateLimitStore(t, parameters, key, "2")
+  defer a.Close()
+  b := openRateLimitStore(t, parameters, key, "2")
+  defer b.Close()
+
+  allocation, err := a.Allocation()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if allocation != 0 || a.Capacity() != 2 {
+    t.Fatalf("expected empty allocation of 2 tokens but got %d of %d", allocation, a.Capacity())
+  }
+
+  // the budget is shared between all stores which refer to the same key
+  for i, store := range []ratelimit.Store{a, b, a} {
+    granted, err := store.Take()
+    if err != nil {
+      t.Fatal(err)
+    }
+    if granted != (i < 2) {
+      t.Fatalf("take #%d: expected granted to be %v", i+1, i < 2)
+    }
+  }
+
+  allocation, err = b.Allocation()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if allocation != 2 {
+    t.Fatalf("expected allocation of 2 tokens but got %d", allocation)
+  }
+
+  // the bucket state is retained when the limit is raised
+  err = a.Reconfigure(3, time.Hour)
+  if err != nil {
+    t.Fatal(err)
+  }
+  allocation, err = a.Allocation()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if allocation != 3 || a.Capacity() != 3 {
+    t.Fatalf("expected full allocation of 3 tokens but got %d of %d", allocation, a.Capacity())
+  }
+}
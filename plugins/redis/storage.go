@@ -17,61 +17,123 @@
 package main
 
 import (
+  "bytes"
   "fmt"
+  "strings"
+  "sync"
   "time"
 
   "github.com/dotStart/Stockpile/stockpile/server"
   "github.com/dotStart/Stockpile/stockpile/storage"
   "github.com/go-redis/redis"
+  "github.com/google/uuid"
   "github.com/hashicorp/hcl2/gohcl"
   "github.com/op/go-logging"
 )
 
 var defaultPassword = ""
+var defaultDatabaseId = 0
+var defaultInvalidationChannel = "stockpile:invalidations"
+var defaultKeyPrefix = ""
+var defaultMode = modeStandalone
+var defaultReplicaReads = replicaReadsNever
 
 type redisStorageBackendInterface struct {
-  logger *logging.Logger
-  cfg    *RedisStorageBackendConfig
-  client *redis.Client
+  logger     *logging.Logger
+  cfg        *RedisStorageBackendConfig
+  client     redis.UniversalClient
+  instanceId string
+  pubsub     *redis.PubSub
 }
 
 type RedisStorageBackendConfig struct {
-  Address    string  `hcl:"address,attr"`
-  Password   *string `hcl:"password,attr"`
-  DatabaseId int     `hcl:"database,attr"`
+  Mode                *string          `hcl:"mode,attr"`
+  Address             *string          `hcl:"address,attr"`
+  Addresses           *[]string        `hcl:"addresses,attr"`
+  MasterName          *string          `hcl:"master-name,attr"`
+  Password            *string          `hcl:"password,attr"`
+  DatabaseId          *int             `hcl:"database,attr"`
+  KeyPrefix           *string          `hcl:"key-prefix,attr"`
+  InvalidationChannel *string          `hcl:"invalidation-channel,attr"`
+  ReplicaReads        *string          `hcl:"replica-reads,attr"`
+  Tls                 *RedisTlsConfig  `hcl:"tls,block"`
+  Pool                *RedisPoolConfig `hcl:"pool,block"`
 }
 
 func NewRedisStorageBackend(cfg *server.Config) (storage.StorageBackend, error) {
   redisCfg := &RedisStorageBackendConfig{}
-  diag := gohcl.DecodeBody(cfg.Storage.Parameters, nil, redisCfg)
+  diag := gohcl.DecodeBody(cfg.Storage.Parameters, server.EvalContext(), redisCfg)
   if diag.HasErrors() {
     return nil, fmt.Errorf("illegal backend configuration: %s", diag.Error())
   }
 
-  if redisCfg.Password == nil {
-    redisCfg.Password = &defaultPassword
+  redisCfg.applyDefaults()
+  if redisCfg.InvalidationChannel == nil {
+    // deployments which share a server are isolated from each other by default
+    channel := *redisCfg.KeyPrefix + defaultInvalidationChannel
+    redisCfg.InvalidationChannel = &channel
   }
 
-  client := redis.NewClient(&redis.Options{
-    Addr:     redisCfg.Address,
-    Password: *redisCfg.Password,
-    DB:       redisCfg.DatabaseId,
-  })
+  client, err := redisCfg.client()
+  if err != nil {
+    return nil, fmt.Errorf("illegal backend configuration: %s", err)
+  }
+
+  impl := &redisStorageBackendInterface{
+    logger:     logging.MustGetLogger("redis"),
+    cfg:        redisCfg,
+    client:     client,
+    instanceId: uuid.New().String(),
+  }
 
-  _, err := client.Ping().Result()
+  err = impl.Ping()
   if err != nil {
+    client.Close()
     return nil, fmt.Errorf("cannot reach configured redis server: %s", err)
   }
 
-  return storage.NewEncodedStorageBackend(cfg, &redisStorageBackendInterface{
-    logger: logging.MustGetLogger("redis"),
-    cfg:    redisCfg,
-    client: client,
-  }), nil
+  return storage.NewEncodedStorageBackend(cfg, impl), nil
+}
+
+// populates all omitted connection parameters with their respective defaults
+func (c *RedisStorageBackendConfig) applyDefaults() {
+  if c.Mode == nil {
+    c.Mode = &defaultMode
+  }
+  if c.Password == nil {
+    c.Password = &defaultPassword
+  }
+  if c.DatabaseId == nil {
+    c.DatabaseId = &defaultDatabaseId
+  }
+  if c.KeyPrefix == nil {
+    c.KeyPrefix = &defaultKeyPrefix
+  }
+  if c.ReplicaReads == nil {
+    c.ReplicaReads = &defaultReplicaReads
+  }
+}
+
+// calculates the redis key of a given cache entry
+func (f *redisStorageBackendInterface) key(category string, key string) string {
+  return fmt.Sprintf("%s%s_%s", *f.cfg.KeyPrefix, category, key)
+}
+
+// verifies whether all redis servers are reachable
+func (f *redisStorageBackendInterface) Ping() error {
+  return ping(f.client)
+}
+
+// notifies other instances about the modification of a given cache entry
+func (f *redisStorageBackendInterface) publishInvalidation(category string, key string) {
+  err := f.client.Publish(*f.cfg.InvalidationChannel, fmt.Sprintf("%s %s_%s", f.instanceId, category, key)).Err()
+  if err != nil {
+    f.logger.Errorf("failed to publish invalidation of entry %s_%s: %s", category, key, err)
+  }
 }
 
 func (f *redisStorageBackendInterface) GetCacheEntry(category string, key string, ttl time.Duration) ([]byte, error) {
-  enc, err := f.client.Get(fmt.Sprintf("%s_%s", category, key)).Bytes()
+  enc, err := f.client.Get(f.key(category, key)).Bytes()
   if err == redis.Nil {
     return nil, nil
   }
@@ -79,13 +141,168 @@ func (f *redisStorageBackendInterface) GetCacheEntry(category string, key string
 }
 
 func (f *redisStorageBackendInterface) PutCacheEntry(category string, key string, data []byte, ttl time.Duration) error {
-  return f.client.Set(fmt.Sprintf("%s_%s", category, key), data, ttl).Err()
+  err := f.client.Set(f.key(category, key), data, ttl).Err()
+  if err != nil {
+    return err
+  }
+
+  f.publishInvalidation(category, key)
+  return nil
+}
+
+// redis does not retain the time at which an entry has been written and thus derives it from the
+// remaining lifetime of the entry instead
+func (f *redisStorageBackendInterface) GetTimestampedCacheEntry(category string, key string, ttl time.Duration) ([]byte, time.Time, error) {
+  pipe := f.client.TxPipeline()
+  get := pipe.Get(f.key(category, key))
+  pttl := pipe.PTTL(f.key(category, key))
+  _, err := pipe.Exec()
+  if err == redis.Nil {
+    return nil, time.Time{}, nil
+  }
+  if err != nil {
+    return nil, time.Time{}, err
+  }
+
+  enc, err := get.Bytes()
+  if err != nil {
+    return nil, time.Time{}, err
+  }
+
+  writtenAt := time.Now()
+  if remaining := pttl.Val(); ttl > 0 && remaining > 0 {
+    writtenAt = writtenAt.Add(remaining - ttl)
+  }
+  return enc, writtenAt, nil
+}
+
+// entries are stored with their remaining lifetime and skipped entirely if they have already
+// expired
+func (f *redisStorageBackendInterface) PutTimestampedCacheEntry(category string, key string, data []byte, writtenAt time.Time, ttl time.Duration) error {
+  if ttl > 0 {
+    ttl -= time.Since(writtenAt)
+    if ttl <= 0 {
+      return nil
+    }
+  }
+
+  return f.PutCacheEntry(category, key, data, ttl)
 }
 
 func (f *redisStorageBackendInterface) PurgeCacheEntry(category string, key string) error {
-  return f.client.Del(fmt.Sprintf("%s_%s", category, key)).Err()
+  err := f.client.Del(f.key(category, key)).Err()
+  if err != nil {
+    return err
+  }
+
+  f.publishInvalidation(category, key)
+  return nil
+}
+
+// escapes all characters within a string which would otherwise be interpreted by redis as part of
+// a glob pattern
+func escapePattern(str string) string {
+  var b bytes.Buffer
+  for _, c := range str {
+    switch c {
+    case '*', '?', '[', ']', '\\':
+      b.WriteRune('\\')
+    }
+    b.WriteRune(c)
+  }
+  return b.String()
+}
+
+// retrieves all keys which match a given pattern from a single redis server
+func scanKeys(client redis.Cmdable, pattern string) ([]string, error) {
+  keys := make([]string, 0)
+
+  var cursor uint64
+  for {
+    page, next, err := client.Scan(cursor, pattern, 100).Result()
+    if err != nil {
+      return nil, err
+    }
+    keys = append(keys, page...)
+
+    cursor = next
+    if cursor == 0 {
+      break
+    }
+  }
+  return keys, nil
+}
+
+func (f *redisStorageBackendInterface) ListCacheEntries(category string) ([]string, error) {
+  prefix := f.key(category, "")
+  pattern := escapePattern(prefix) + "*"
+
+  var keys []string
+  if cluster, ok := f.client.(*redis.ClusterClient); ok {
+    // keys are distributed among all masters within a cluster thus requiring us to scan each of
+    // them individually
+    var mutex sync.Mutex
+    err := cluster.ForEachMaster(func(client *redis.Client) error {
+      page, err := scanKeys(client, pattern)
+      if err != nil {
+        return err
+      }
+
+      mutex.Lock()
+      keys = append(keys, page...)
+      mutex.Unlock()
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+  } else {
+    var err error
+    keys, err = scanKeys(f.client, pattern)
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  for i, key := range keys {
+    keys[i] = strings.TrimPrefix(key, prefix)
+  }
+  return keys, nil
+}
+
+func (f *redisStorageBackendInterface) SubscribeCacheEntryInvalidations(handler storage.InvalidationHandler) error {
+  pubsub := f.client.Subscribe(*f.cfg.InvalidationChannel)
+  _, err := pubsub.Receive()
+  if err != nil {
+    pubsub.Close()
+    return err
+  }
+  f.pubsub = pubsub
+
+  go func() {
+    for msg := range pubsub.Channel() {
+      // messages are encoded as "<instance> <category>_<key>"
+      elements := strings.SplitN(msg.Payload, " ", 2)
+      if len(elements) != 2 || elements[0] == f.instanceId {
+        continue
+      }
+
+      entry := strings.SplitN(elements[1], "_", 2)
+      if len(entry) != 2 {
+        f.logger.Warningf("received malformed invalidation: %s", msg.Payload)
+        continue
+      }
+
+      f.logger.Debugf("entry %s has been modified by instance %s", elements[1], elements[0])
+      handler(entry[0], entry[1])
+    }
+  }()
+  return nil
 }
 
 func (f *redisStorageBackendInterface) Close() error {
+  if f.pubsub != nil {
+    f.pubsub.Close()
+  }
   return f.client.Close()
 }
@@ -0,0 +1,336 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+  "os"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/dotStart/Stockpile/stockpile/storage/storagetest"
+  "github.com/go-redis/redis"
+  "github.com/google/uuid"
+  "github.com/hashicorp/hcl2/gohcl"
+)
+
+// defines the amount of time to wait for pub/sub messages to be delivered
+const deliveryTimeout = time.Second * 5
+
+// loads a configuration which selects the redis backend with a given set of parameters
+func loadStorageConfig(t *testing.T, parameters string) *server.Config {
+  return servertest.LoadConfig(t, "storage \"redis\" {\n"+parameters+"\n}\n")
+}
+
+// decodes the connection parameters of a given storage configuration
+func decodeConnection(t *testing.T, cfg *server.Config) *RedisStorageBackendConfig {
+  redisCfg := &RedisStorageBackendConfig{}
+  diag := gohcl.DecodeBody(cfg.Storage.Parameters, server.EvalContext(), redisCfg)
+  if diag.HasErrors() {
+    t.Fatal(diag.Error())
+  }
+  redisCfg.applyDefaults()
+  return redisCfg
+}
+
+// retrieves the connection parameters of the standalone server passed via REDIS_ADDR
+func standaloneParameters(t *testing.T) string {
+  addr := os.Getenv("REDIS_ADDR")
+  if addr == "" {
+    t.Skip("REDIS_ADDR is not set")
+  }
+  return `address = "` + addr + `"`
+}
+
+// converts a comma separated list of addresses into a list expression
+func addressList(addresses string) string {
+  return `["` + strings.Join(strings.Split(addresses, ","), `", "`) + `"]`
+}
+
+// generates a key prefix which isolates a test from all other users of the same server
+func randomPrefix() string {
+  return "stockpile-test:" + uuid.New().String() + ":"
+}
+
+// removes all keys with a given prefix from the server(s) selected by a set of parameters
+func purge(t *testing.T, parameters string, prefix string) {
+  client, err := decodeConnection(t, loadStorageConfig(t, parameters)).client()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer client.Close()
+
+  pattern := escapePattern(prefix) + "*"
+  remove := func(client redis.Cmdable) error {
+    keys, err := scanKeys(client, pattern)
+    if err != nil {
+      return err
+    }
+    for _, key := range keys {
+      err = client.Del(key).Err()
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  }
+
+  if cluster, ok := client.(*redis.ClusterClient); ok {
+    err = cluster.ForEachMaster(func(client *redis.Client) error {
+      return remove(client)
+    })
+  } else {
+    err = remove(client)
+  }
+  if err != nil {
+    t.Errorf("failed to remove test keys: %s", err)
+  }
+}
+
+// executes the storage conformance suite against a given set of connection parameters
+func testBackend(t *testing.T, parameters string) {
+  prefix := randomPrefix()
+  parameters += "\nkey-prefix = \"" + prefix + "\""
+  defer purge(t, parameters, prefix)
+
+  storagetest.Test(t, loadStorageConfig(t, parameters), NewRedisStorageBackend, storagetest.Options{
+    SkipExpiry: testing.Short(),
+  })
+}
+
+// opens a redis backend for a given set of connection parameters
+func open(t *testing.T, parameters string) storage.StorageBackend {
+  backend, err := NewRedisStorageBackend(loadStorageConfig(t, parameters))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return backend
+}
+
+// subscribes to the invalidations which are received by a given backend
+func subscribe(t *testing.T, backend storage.StorageBackend) <-chan string {
+  observable, ok := backend.(storage.ObservableStorageBackend)
+  if !ok {
+    t.Fatal("expected backend to be observable")
+  }
+
+  ch := make(chan string, 16)
+  err := observable.SubscribeInvalidations(func(category string, key string) {
+    ch <- category + "_" + key
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  return ch
+}
+
+func TestClientModes(t *testing.T) {
+  tests := []struct {
+    name       string
+    parameters string
+    err        string
+  }{
+    {"standalone", `address = "localhost:6379"`, ""},
+    {"standalone with multiple addresses", `addresses = ["redis-1:6379", "redis-2:6379"]`, "exactly one address is required"},
+    {"standalone with replica reads", `address = "localhost:6379"
+    replica-reads = "random"`, "replica reads are only supported"},
+    {"missing address", `mode = "standalone"`, "at least one address is required"},
+    {"cluster", `mode = "cluster"
+    addresses = ["redis-1:6379", "redis-2:6379"]
+    replica-reads = "latency"`, ""},
+    {"cluster with database", `mode = "cluster"
+    address = "localhost:6379"
+    database = 1`, "database selection is not supported"},
+    {"sentinel", `mode = "sentinel"
+    master-name = "stockpile"
+    addresses = ["sentinel-1:26379", "sentinel-2:26379"]`, ""},
+    {"sentinel without master", `mode = "sentinel"
+    addresses = ["sentinel-1:26379"]`, "master-name is required"},
+    {"unknown mode", `mode = "replicated"
+    address = "localhost:6379"`, "illegal mode"},
+    {"unknown replica read strategy", `mode = "cluster"
+    address = "localhost:6379"
+    replica-reads = "always"`, "illegal replica read strategy"},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      client, err := decodeConnection(t, loadStorageConfig(t, test.parameters)).client()
+      if test.err == "" {
+        if err != nil {
+          t.Fatalf("expected client to be created but got: %s", err)
+        }
+        client.Close()
+        return
+      }
+
+      if err == nil {
+        client.Close()
+        t.Fatalf("expected error \"%s\" but client was created", test.err)
+      }
+      if !strings.Contains(err.Error(), test.err) {
+        t.Fatalf("expected error \"%s\" but got: %s", test.err, err)
+      }
+    })
+  }
+}
+
+func TestEscapePattern(t *testing.T) {
+  expected := `stockpile\*\?\[x\]\\:`
+  actual := escapePattern(`stockpile*?[x]\:`)
+  if actual != expected {
+    t.Fatalf("expected %s but got %s", expected, actual)
+  }
+}
+
+func TestStandaloneStorageBackend(t *testing.T) {
+  testBackend(t, standaloneParameters(t))
+}
+
+// executes the storage conformance suite against a cluster when a comma separated list of seed
+// nodes has been passed via the REDIS_CLUSTER_ADDRS environment variable
+func TestClusterStorageBackend(t *testing.T) {
+  addrs := os.Getenv("REDIS_CLUSTER_ADDRS")
+  if addrs == "" {
+    t.Skip("REDIS_CLUSTER_ADDRS is not set")
+  }
+
+  testBackend(t, `mode = "cluster"
+  addresses = `+addressList(addrs))
+}
+
+// executes the storage conformance suite against a sentinel deployment when a comma separated list
+// of sentinels and the name of the monitored master have been passed via the
+// REDIS_SENTINEL_ADDRS and REDIS_SENTINEL_MASTER environment variables
+func TestSentinelStorageBackend(t *testing.T) {
+  addrs := os.Getenv("REDIS_SENTINEL_ADDRS")
+  master := os.Getenv("REDIS_SENTINEL_MASTER")
+  if addrs == "" || master == "" {
+    t.Skip("REDIS_SENTINEL_ADDRS or REDIS_SENTINEL_MASTER is not set")
+  }
+
+  testBackend(t, `mode = "sentinel"
+  master-name = "`+master+`"
+  addresses = `+addressList(addrs))
+}
+
+func TestKeyPrefixIsolation(t *testing.T) {
+  base := standaloneParameters(t)
+  prefixA := randomPrefix()
+  prefixB := randomPrefix()
+  parametersA := base + "\nkey-prefix = \"" + prefixA + "\""
+  parametersB := base + "\nkey-prefix = \"" + prefixB + "\""
+  defer purge(t, parametersA, prefixA)
+  defer purge(t, parametersB, prefixB)
+
+  a := open(t, parametersA)
+  defer a.Close()
+  b := open(t, parametersB)
+  defer b.Close()
+
+  id := uuid.New()
+  err := a.PutProfile(&entity.Profile{Id: id, Name: "isolated"})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  profile, err := a.GetProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile == nil {
+    t.Fatal("expected profile to be retrievable with its own prefix")
+  }
+
+  profile, err = b.GetProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile != nil {
+    t.Fatal("expected profile to be invisible to deployments with a different prefix")
+  }
+
+  client, err := decodeConnection(t, loadStorageConfig(t, parametersA)).client()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer client.Close()
+
+  keys, err := scanKeys(client, escapePattern(prefixA)+"*")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(keys) != 1 || keys[0] != prefixA+"profile_"+id.String() {
+    t.Fatalf("expected a single prefixed key but got %v", keys)
+  }
+}
+
+func TestInvalidations(t *testing.T) {
+  base := standaloneParameters(t)
+  prefix := randomPrefix()
+  parameters := base + "\nkey-prefix = \"" + prefix + "\""
+  otherPrefix := randomPrefix()
+  otherParameters := base + "\nkey-prefix = \"" + otherPrefix + "\""
+  defer purge(t, parameters, prefix)
+  defer purge(t, otherParameters, otherPrefix)
+
+  a := open(t, parameters)
+  defer a.Close()
+  b := open(t, parameters)
+  defer b.Close()
+  other := open(t, otherParameters)
+  defer other.Close()
+
+  ownInvalidations := subscribe(t, a)
+  invalidations := subscribe(t, b)
+  otherInvalidations := subscribe(t, other)
+
+  id := uuid.New()
+  err := a.PutProfile(&entity.Profile{Id: id, Name: "invalidated"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = a.PurgeProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  expected := "profile_" + id.String()
+  for i := 0; i < 2; i++ {
+    select {
+    case entry := <-invalidations:
+      if entry != expected {
+        t.Fatalf("expected invalidation of %s but got %s", expected, entry)
+      }
+    case <-time.After(deliveryTimeout):
+      t.Fatalf("expected invalidation #%d of %s to be delivered", i+1, expected)
+    }
+  }
+
+  // messages are delivered to all subscribers in order and thus would have arrived by now
+  select {
+  case entry := <-ownInvalidations:
+    t.Fatalf("expected own invalidations to be ignored but got %s", entry)
+  case entry := <-otherInvalidations:
+    t.Fatalf("expected invalidations to be isolated by prefix but got %s", entry)
+  case <-time.After(time.Millisecond * 100):
+  }
+}
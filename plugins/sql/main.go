@@ -0,0 +1,35 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+  "github.com/dotStart/Stockpile/stockpile/plugin"
+)
+
+var version string
+var Metadata = plugin.Metadata{
+  Name:       "SQL Storage Backend",
+  Version:    version,
+  Authors:    []string{"Johannes \".start\" Donath"},
+  Website:    "https://dotStart.github.io/Stockpile",
+  ApiVersion: plugin.ApiVersion,
+}
+
+func InitializePlugin(ctx *plugin.Context) error {
+  ctx.RegisterStorageBackend("sql", NewSqlStorageBackend)
+  return nil
+}
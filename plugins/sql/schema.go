@@ -0,0 +1,207 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+  "bytes"
+  "database/sql"
+  "fmt"
+  "strconv"
+  "time"
+)
+
+// represents a single schema revision
+type migration struct {
+  version    int
+  statements []string
+}
+
+// lists all schema revisions in the order in which they are applied
+// revisions which have been released must never be modified - changes to the schema are applied
+// by appending a new revision instead
+var migrations = []migration{
+  {
+    version: 1,
+    statements: []string{
+      `CREATE TABLE profile_ids (
+        name_key      VARCHAR(64) NOT NULL,
+        profile_id    CHAR(36)    NOT NULL,
+        name          VARCHAR(64) NOT NULL,
+        first_seen_at BIGINT      NOT NULL,
+        last_seen_at  BIGINT      NOT NULL,
+        valid_until   BIGINT      NOT NULL,
+        cached_at     BIGINT      NOT NULL,
+        PRIMARY KEY (name_key, profile_id, first_seen_at)
+      )`,
+      `CREATE INDEX profile_ids_validity ON profile_ids (name_key, first_seen_at, valid_until)`,
+      `CREATE INDEX profile_ids_cached_at ON profile_ids (cached_at)`,
+
+      `CREATE TABLE name_histories (
+        profile_id CHAR(36) NOT NULL PRIMARY KEY,
+        cached_at  BIGINT   NOT NULL
+      )`,
+      `CREATE INDEX name_histories_cached_at ON name_histories (cached_at)`,
+      `CREATE TABLE name_changes (
+        profile_id    CHAR(36)    NOT NULL,
+        position      INTEGER     NOT NULL,
+        name          VARCHAR(64) NOT NULL,
+        changed_to_at BIGINT      NOT NULL,
+        valid_until   BIGINT      NOT NULL,
+        PRIMARY KEY (profile_id, position)
+      )`,
+
+      `CREATE TABLE profiles (
+        profile_id            CHAR(36)    NOT NULL PRIMARY KEY,
+        name                  VARCHAR(64) NOT NULL,
+        textures_timestamp    BIGINT,
+        textures_profile_id   CHAR(36),
+        textures_profile_name VARCHAR(64),
+        cached_at             BIGINT      NOT NULL
+      )`,
+      `CREATE INDEX profiles_cached_at ON profiles (cached_at)`,
+      `CREATE TABLE profile_properties (
+        profile_id CHAR(36)     NOT NULL,
+        name       VARCHAR(255) NOT NULL,
+        value      TEXT         NOT NULL,
+        signature  TEXT         NOT NULL,
+        PRIMARY KEY (profile_id, name)
+      )`,
+      `CREATE TABLE profile_textures (
+        profile_id CHAR(36)     NOT NULL,
+        type       VARCHAR(64)  NOT NULL,
+        url        TEXT         NOT NULL,
+        PRIMARY KEY (profile_id, type)
+      )`,
+
+      `CREATE TABLE blacklists (
+        id        INTEGER NOT NULL PRIMARY KEY,
+        cached_at BIGINT  NOT NULL
+      )`,
+      `CREATE TABLE blacklist_hashes (
+        hash CHAR(40) NOT NULL PRIMARY KEY
+      )`,
+    },
+  },
+  {
+    version: 2,
+    statements: []string{
+      `CREATE TABLE name_timelines (
+        name_key  VARCHAR(64) NOT NULL PRIMARY KEY,
+        cached_at BIGINT      NOT NULL
+      )`,
+      `CREATE INDEX name_timelines_cached_at ON name_timelines (cached_at)`,
+      `CREATE TABLE name_ownerships (
+        name_key   VARCHAR(64) NOT NULL,
+        position   INTEGER     NOT NULL,
+        profile_id CHAR(36)    NOT NULL,
+        name       VARCHAR(64) NOT NULL,
+        from_at    BIGINT      NOT NULL,
+        until_at   BIGINT      NOT NULL,
+        updated_at BIGINT      NOT NULL,
+        PRIMARY KEY (name_key, position)
+      )`,
+    },
+  },
+}
+
+// represents the differences between the supported database systems
+type dialect struct {
+  // identifies the database/sql driver which is used to access databases of this type
+  driver string
+  // indicates whether the database uses numbered placeholders ($1) instead of question marks
+  numberedPlaceholders bool
+  // limits the amount of connections which may be opened concurrently (zero if unlimited)
+  // embedded databases cannot handle concurrent writes and are thus limited to a single connection
+  maxOpenConnections int
+}
+
+// lists the supported database systems
+var dialects = map[string]*dialect{
+  "sqlite3": {
+    driver:             "sqlite3",
+    maxOpenConnections: 1,
+  },
+  "postgres": {
+    driver:               "postgres",
+    numberedPlaceholders: true,
+  },
+}
+
+// rewrites the placeholders within a query to match the format expected by the database
+func (d *dialect) rebind(query string) string {
+  if !d.numberedPlaceholders {
+    return query
+  }
+
+  var b bytes.Buffer
+  n := 0
+  for _, c := range query {
+    if c == '?' {
+      n++
+      b.WriteByte('$')
+      b.WriteString(strconv.Itoa(n))
+      continue
+    }
+    b.WriteRune(c)
+  }
+  return b.String()
+}
+
+// applies all schema revisions which have yet to be applied to the database
+func (s *SqlStorageBackend) migrate() error {
+  _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER NOT NULL PRIMARY KEY,
+    applied_at BIGINT  NOT NULL
+  )`)
+  if err != nil {
+    return fmt.Errorf("failed to create migration table: %s", err)
+  }
+
+  var current int
+  err = s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+  if err != nil {
+    return fmt.Errorf("failed to retrieve schema version: %s", err)
+  }
+
+  latest := migrations[len(migrations)-1].version
+  if current > latest {
+    return fmt.Errorf("database schema version %d is newer than the latest known version %d", current, latest)
+  }
+
+  for _, m := range migrations {
+    if m.version <= current {
+      continue
+    }
+
+    s.logger.Infof("migrating database schema to version %d", m.version)
+    err = s.transaction(func(tx *sql.Tx) error {
+      for _, statement := range m.statements {
+        if _, err := tx.Exec(statement); err != nil {
+          return err
+        }
+      }
+
+      _, err := tx.Exec(s.dialect.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`), m.version, time.Now().Unix())
+      return err
+    })
+    if err != nil {
+      return fmt.Errorf("failed to migrate database schema to version %d: %s", m.version, err)
+    }
+  }
+
+  return nil
+}
@@ -0,0 +1,833 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+  "database/sql"
+  "fmt"
+  "strings"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/google/uuid"
+  "github.com/hashicorp/hcl2/gohcl"
+  _ "github.com/lib/pq"
+  _ "github.com/mattn/go-sqlite3"
+  "github.com/op/go-logging"
+)
+
+var defaultSweepInterval = "10m"
+
+// provides a storage backend which stores all entities within a relational database (such as
+// SQLite or PostgreSQL)
+//
+// unlike encoded storage backends, entities are decomposed into their respective fields and
+// thus permit indexed lookups (for instance, when looking up the owner of a name at a given time)
+type SqlStorageBackend struct {
+  logger  *logging.Logger
+  cfg     *server.Config
+  dialect *dialect
+  db      *sql.DB
+
+  sweepTicker *time.Ticker
+  done        chan struct{}
+}
+
+type SqlStorageBackendConfig struct {
+  Driver             string  `hcl:"driver,attr"`
+  Dsn                string  `hcl:"dsn,attr"`
+  MaxOpenConnections *int    `hcl:"max-open-connections,attr"`
+  SweepInterval      *string `hcl:"sweep-interval,attr"`
+}
+
+func NewSqlStorageBackend(cfg *server.Config) (storage.StorageBackend, error) {
+  sqlCfg := &SqlStorageBackendConfig{}
+  diag := gohcl.DecodeBody(cfg.Storage.Parameters, server.EvalContext(), sqlCfg)
+  if diag.HasErrors() {
+    return nil, fmt.Errorf("illegal backend configuration: %s", diag.Error())
+  }
+
+  d := dialects[sqlCfg.Driver]
+  if d == nil {
+    return nil, fmt.Errorf("illegal backend configuration: unsupported driver \"%s\"", sqlCfg.Driver)
+  }
+  if sqlCfg.SweepInterval == nil {
+    sqlCfg.SweepInterval = &defaultSweepInterval
+  }
+  sweepInterval, err := time.ParseDuration(*sqlCfg.SweepInterval)
+  if err != nil {
+    return nil, fmt.Errorf("illegal backend configuration: illegal sweep interval: %s", err)
+  }
+  if sweepInterval <= 0 {
+    return nil, fmt.Errorf("illegal backend configuration: sweep interval must be positive")
+  }
+
+  db, err := sql.Open(d.driver, sqlCfg.Dsn)
+  if err != nil {
+    return nil, fmt.Errorf("failed to open database: %s", err)
+  }
+  if d.maxOpenConnections != 0 {
+    db.SetMaxOpenConns(d.maxOpenConnections)
+  } else if sqlCfg.MaxOpenConnections != nil {
+    db.SetMaxOpenConns(*sqlCfg.MaxOpenConnections)
+  }
+
+  err = db.Ping()
+  if err != nil {
+    db.Close()
+    return nil, fmt.Errorf("cannot reach configured database: %s", err)
+  }
+
+  backend := &SqlStorageBackend{
+    logger:  logging.MustGetLogger("sql"),
+    cfg:     cfg,
+    dialect: d,
+    db:      db,
+
+    sweepTicker: time.NewTicker(sweepInterval),
+    done:        make(chan struct{}),
+  }
+
+  err = backend.migrate()
+  if err != nil {
+    backend.sweepTicker.Stop()
+    db.Close()
+    return nil, err
+  }
+
+  go backend.sweep()
+  return backend, nil
+}
+
+// executes a function within a database transaction which is committed when the function returns
+// successfully and rolled back otherwise
+func (s *SqlStorageBackend) transaction(fn func(tx *sql.Tx) error) error {
+  tx, err := s.db.Begin()
+  if err != nil {
+    return err
+  }
+
+  err = fn(tx)
+  if err != nil {
+    tx.Rollback()
+    return err
+  }
+  return tx.Commit()
+}
+
+// calculates the point in time before which entries of a given ttl are considered expired
+func cutoff(ttl time.Duration) int64 {
+  return time.Now().Add(-ttl).Unix()
+}
+
+// calculates the key under which the associations of a given name are stored
+func nameKey(name string) string {
+  return strings.ToLower(name)
+}
+
+// reads a list of name associations from a result set
+func scanProfileIds(rows *sql.Rows) ([]*entity.ProfileId, error) {
+  defer rows.Close()
+
+  profileIds := make([]*entity.ProfileId, 0)
+  for rows.Next() {
+    var id string
+    var name string
+    var firstSeenAt, lastSeenAt, validUntil int64
+    err := rows.Scan(&id, &name, &firstSeenAt, &lastSeenAt, &validUntil)
+    if err != nil {
+      return nil, err
+    }
+
+    profileId, err := uuid.Parse(id)
+    if err != nil {
+      return nil, fmt.Errorf("encountered malformed profile id \"%s\": %s", id, err)
+    }
+
+    profileIds = append(profileIds, &entity.ProfileId{
+      Id:          profileId,
+      Name:        name,
+      FirstSeenAt: time.Unix(firstSeenAt, 0),
+      LastSeenAt:  time.Unix(lastSeenAt, 0),
+      ValidUntil:  time.Unix(validUntil, 0),
+    })
+  }
+  return profileIds, rows.Err()
+}
+
+func (s *SqlStorageBackend) GetProfileId(name string, at time.Time) (*entity.ProfileId, error) {
+  rows, err := s.db.Query(s.dialect.rebind(`SELECT profile_id, name, first_seen_at, last_seen_at, valid_until FROM profile_ids WHERE name_key = ? AND first_seen_at <= ? AND valid_until > ? AND cached_at > ? ORDER BY first_seen_at DESC LIMIT 1`), nameKey(name), at.Unix(), at.Unix(), cutoff(s.cfg.GetTtl().Name))
+  if err != nil {
+    return nil, err
+  }
+
+  profileIds, err := scanProfileIds(rows)
+  if err != nil || len(profileIds) == 0 {
+    return nil, err
+  }
+  return profileIds[0], nil
+}
+
+func (s *SqlStorageBackend) PutProfileId(profileId *entity.ProfileId) error {
+  key := nameKey(profileId.Name)
+  now := time.Now().Unix()
+  expiry := cutoff(s.cfg.GetTtl().Name)
+
+  return s.transaction(func(tx *sql.Tx) error {
+    _, err := tx.Exec(s.dialect.rebind(`DELETE FROM profile_ids WHERE name_key = ? AND cached_at <= ?`), key, expiry)
+    if err != nil {
+      return err
+    }
+
+    rows, err := tx.Query(s.dialect.rebind(`SELECT profile_id, name, first_seen_at, last_seen_at, valid_until FROM profile_ids WHERE name_key = ? ORDER BY first_seen_at`), key)
+    if err != nil {
+      return err
+    }
+    existing, err := scanProfileIds(rows)
+    if err != nil {
+      return err
+    }
+
+    // if there is an overlap (e.g. the passed profile was encountered during the local validity
+    // period or was assigned to the same profile within the 30 day limitation period) the existing
+    // association is extended instead
+    found := false
+    for _, e := range existing {
+      if e.IsOverlappingWith(profileId) {
+        e.UpdateExpiration(profileId.LastSeenAt)
+        _, err = tx.Exec(s.dialect.rebind(`UPDATE profile_ids SET last_seen_at = ?, valid_until = ? WHERE name_key = ? AND profile_id = ? AND first_seen_at = ?`), e.LastSeenAt.Unix(), e.ValidUntil.Unix(), key, e.Id.String(), e.FirstSeenAt.Unix())
+        if err != nil {
+          return err
+        }
+
+        found = true
+        break
+      }
+    }
+
+    if !found {
+      _, err = tx.Exec(s.dialect.rebind(`INSERT INTO profile_ids (name_key, profile_id, name, first_seen_at, last_seen_at, valid_until, cached_at) VALUES (?, ?, ?, ?, ?, ?, ?)`), key, profileId.Id.String(), profileId.Name, profileId.FirstSeenAt.Unix(), profileId.LastSeenAt.Unix(), profileId.ValidUntil.Unix(), now)
+      if err != nil {
+        return err
+      }
+    }
+
+    // all associations of a name share a common ttl (as is the case with any other backend)
+    _, err = tx.Exec(s.dialect.rebind(`UPDATE profile_ids SET cached_at = ? WHERE name_key = ?`), now, key)
+    return err
+  })
+}
+
+func (s *SqlStorageBackend) PurgeProfileId(name string, at time.Time) error {
+  _, err := s.db.Exec(s.dialect.rebind(`DELETE FROM profile_ids WHERE name_key = ? AND first_seen_at <= ? AND valid_until > ?`), nameKey(name), at.Unix(), at.Unix())
+  return err
+}
+
+func (s *SqlStorageBackend) GetNameHistory(id uuid.UUID) (*entity.NameChangeHistory, error) {
+  var history *entity.NameChangeHistory
+  err := s.transaction(func(tx *sql.Tx) error {
+    var cachedAt int64
+    err := tx.QueryRow(s.dialect.rebind(`SELECT cached_at FROM name_histories WHERE profile_id = ? AND cached_at > ?`), id.String(), cutoff(s.cfg.GetTtl().NameHistory)).Scan(&cachedAt)
+    if err == sql.ErrNoRows {
+      return nil
+    }
+    if err != nil {
+      return err
+    }
+
+    rows, err := tx.Query(s.dialect.rebind(`SELECT name, changed_to_at, valid_until FROM name_changes WHERE profile_id = ? ORDER BY position`), id.String())
+    if err != nil {
+      return err
+    }
+    defer rows.Close()
+
+    changes := make([]*entity.NameChange, 0)
+    for rows.Next() {
+      var name string
+      var changedToAt, validUntil int64
+      err = rows.Scan(&name, &changedToAt, &validUntil)
+      if err != nil {
+        return err
+      }
+
+      changes = append(changes, &entity.NameChange{
+        Name:        name,
+        ChangedToAt: time.Unix(changedToAt, 0),
+        ValidUntil:  time.Unix(validUntil, 0),
+      })
+    }
+    if err = rows.Err(); err != nil {
+      return err
+    }
+
+    history = &entity.NameChangeHistory{
+      History: changes,
+    }
+    return nil
+  })
+  return history, err
+}
+
+// removes a name history within a given transaction
+func (s *SqlStorageBackend) deleteNameHistory(tx *sql.Tx, id uuid.UUID) error {
+  _, err := tx.Exec(s.dialect.rebind(`DELETE FROM name_changes WHERE profile_id = ?`), id.String())
+  if err != nil {
+    return err
+  }
+  _, err = tx.Exec(s.dialect.rebind(`DELETE FROM name_histories WHERE profile_id = ?`), id.String())
+  return err
+}
+
+func (s *SqlStorageBackend) PutNameHistory(id uuid.UUID, history *entity.NameChangeHistory) error {
+  return s.transaction(func(tx *sql.Tx) error {
+    err := s.deleteNameHistory(tx, id)
+    if err != nil {
+      return err
+    }
+
+    _, err = tx.Exec(s.dialect.rebind(`INSERT INTO name_histories (profile_id, cached_at) VALUES (?, ?)`), id.String(), time.Now().Unix())
+    if err != nil {
+      return err
+    }
+
+    for i, change := range history.History {
+      _, err = tx.Exec(s.dialect.rebind(`INSERT INTO name_changes (profile_id, position, name, changed_to_at, valid_until) VALUES (?, ?, ?, ?, ?)`), id.String(), i, change.Name, change.ChangedToAt.Unix(), change.ValidUntil.Unix())
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  })
+}
+
+func (s *SqlStorageBackend) PurgeNameHistory(id uuid.UUID) error {
+  return s.transaction(func(tx *sql.Tx) error {
+    return s.deleteNameHistory(tx, id)
+  })
+}
+
+func (s *SqlStorageBackend) GetProfile(id uuid.UUID) (*entity.Profile, error) {
+  var profile *entity.Profile
+  err := s.transaction(func(tx *sql.Tx) error {
+    var name string
+    var texturesTimestamp sql.NullInt64
+    var texturesProfileId, texturesProfileName sql.NullString
+    err := tx.QueryRow(s.dialect.rebind(`SELECT name, textures_timestamp, textures_profile_id, textures_profile_name FROM profiles WHERE profile_id = ? AND cached_at > ?`), id.String(), cutoff(s.cfg.GetTtl().Profile)).Scan(&name, &texturesTimestamp, &texturesProfileId, &texturesProfileName)
+    if err == sql.ErrNoRows {
+      return nil
+    }
+    if err != nil {
+      return err
+    }
+
+    p := &entity.Profile{
+      Id:         id,
+      Name:       name,
+      Properties: make(map[string]*entity.ProfileProperty),
+    }
+
+    rows, err := tx.Query(s.dialect.rebind(`SELECT name, value, signature FROM profile_properties WHERE profile_id = ?`), id.String())
+    if err != nil {
+      return err
+    }
+    defer rows.Close()
+    for rows.Next() {
+      property := &entity.ProfileProperty{}
+      err = rows.Scan(&property.Name, &property.Value, &property.Signature)
+      if err != nil {
+        return err
+      }
+      p.Properties[property.Name] = property
+    }
+    if err = rows.Err(); err != nil {
+      return err
+    }
+
+    if texturesTimestamp.Valid {
+      texturesId, err := uuid.Parse(texturesProfileId.String)
+      if err != nil {
+        return fmt.Errorf("encountered malformed texture profile id \"%s\": %s", texturesProfileId.String, err)
+      }
+
+      p.Textures = &entity.ProfileTextures{
+        Timestamp:   time.Unix(texturesTimestamp.Int64, 0),
+        ProfileId:   texturesId,
+        ProfileName: texturesProfileName.String,
+        Textures:    make(map[string]string),
+      }
+
+      rows, err := tx.Query(s.dialect.rebind(`SELECT type, url FROM profile_textures WHERE profile_id = ?`), id.String())
+      if err != nil {
+        return err
+      }
+      defer rows.Close()
+      for rows.Next() {
+        var typ, url string
+        err = rows.Scan(&typ, &url)
+        if err != nil {
+          return err
+        }
+        p.Textures.Textures[typ] = url
+      }
+      if err = rows.Err(); err != nil {
+        return err
+      }
+    }
+
+    profile = p
+    return nil
+  })
+  return profile, err
+}
+
+// removes a profile within a given transaction
+func (s *SqlStorageBackend) deleteProfile(tx *sql.Tx, id uuid.UUID) error {
+  for _, table := range []string{"profile_properties", "profile_textures", "profiles"} {
+    _, err := tx.Exec(s.dialect.rebind(`DELETE FROM `+table+` WHERE profile_id = ?`), id.String())
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (s *SqlStorageBackend) PutProfile(profile *entity.Profile) error {
+  return s.transaction(func(tx *sql.Tx) error {
+    err := s.deleteProfile(tx, profile.Id)
+    if err != nil {
+      return err
+    }
+
+    var texturesTimestamp sql.NullInt64
+    var texturesProfileId, texturesProfileName sql.NullString
+    if profile.Textures != nil {
+      texturesTimestamp = sql.NullInt64{Int64: profile.Textures.Timestamp.Unix(), Valid: true}
+      texturesProfileId = sql.NullString{String: profile.Textures.ProfileId.String(), Valid: true}
+      texturesProfileName = sql.NullString{String: profile.Textures.ProfileName, Valid: true}
+    }
+
+    _, err = tx.Exec(s.dialect.rebind(`INSERT INTO profiles (profile_id, name, textures_timestamp, textures_profile_id, textures_profile_name, cached_at) VALUES (?, ?, ?, ?, ?, ?)`), profile.Id.String(), profile.Name, texturesTimestamp, texturesProfileId, texturesProfileName, time.Now().Unix())
+    if err != nil {
+      return err
+    }
+
+    for _, property := range profile.Properties {
+      _, err = tx.Exec(s.dialect.rebind(`INSERT INTO profile_properties (profile_id, name, value, signature) VALUES (?, ?, ?, ?)`), profile.Id.String(), property.Name, property.Value, property.Signature)
+      if err != nil {
+        return err
+      }
+    }
+
+    if profile.Textures != nil {
+      for typ, url := range profile.Textures.Textures {
+        _, err = tx.Exec(s.dialect.rebind(`INSERT INTO profile_textures (profile_id, type, url) VALUES (?, ?, ?)`), profile.Id.String(), typ, url)
+        if err != nil {
+          return err
+        }
+      }
+    }
+    return nil
+  })
+}
+
+func (s *SqlStorageBackend) PurgeProfile(id uuid.UUID) error {
+  return s.transaction(func(tx *sql.Tx) error {
+    return s.deleteProfile(tx, id)
+  })
+}
+
+func (s *SqlStorageBackend) GetBlacklist() (*entity.Blacklist, error) {
+  var blacklist *entity.Blacklist
+  err := s.transaction(func(tx *sql.Tx) error {
+    var cachedAt int64
+    err := tx.QueryRow(s.dialect.rebind(`SELECT cached_at FROM blacklists WHERE id = 1 AND cached_at > ?`), cutoff(s.cfg.GetTtl().Blacklist)).Scan(&cachedAt)
+    if err == sql.ErrNoRows {
+      return nil
+    }
+    if err != nil {
+      return err
+    }
+
+    rows, err := tx.Query(`SELECT hash FROM blacklist_hashes`)
+    if err != nil {
+      return err
+    }
+    defer rows.Close()
+
+    hashes := make([]string, 0)
+    for rows.Next() {
+      var hash string
+      err = rows.Scan(&hash)
+      if err != nil {
+        return err
+      }
+      hashes = append(hashes, hash)
+    }
+    if err = rows.Err(); err != nil {
+      return err
+    }
+
+    blacklist = &entity.Blacklist{
+      Hashes: hashes,
+    }
+    return nil
+  })
+  return blacklist, err
+}
+
+// removes the blacklist within a given transaction
+func (s *SqlStorageBackend) deleteBlacklist(tx *sql.Tx) error {
+  _, err := tx.Exec(`DELETE FROM blacklist_hashes`)
+  if err != nil {
+    return err
+  }
+  _, err = tx.Exec(`DELETE FROM blacklists`)
+  return err
+}
+
+func (s *SqlStorageBackend) PutBlacklist(blacklist *entity.Blacklist) error {
+  return s.transaction(func(tx *sql.Tx) error {
+    err := s.deleteBlacklist(tx)
+    if err != nil {
+      return err
+    }
+
+    _, err = tx.Exec(s.dialect.rebind(`INSERT INTO blacklists (id, cached_at) VALUES (1, ?)`), time.Now().Unix())
+    if err != nil {
+      return err
+    }
+
+    // the upstream list may contain duplicates which would otherwise violate the primary key
+    inserted := make(map[string]bool)
+    for _, hash := range blacklist.Hashes {
+      if inserted[hash] {
+        continue
+      }
+      inserted[hash] = true
+
+      _, err = tx.Exec(s.dialect.rebind(`INSERT INTO blacklist_hashes (hash) VALUES (?)`), hash)
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  })
+}
+
+func (s *SqlStorageBackend) PurgeBlacklist() error {
+  return s.transaction(s.deleteBlacklist)
+}
+
+// retrieves the ownership timeline of a given name within a given transaction
+func (s *SqlStorageBackend) getNameTimeline(tx *sql.Tx, key string) (*entity.NameTimeline, error) {
+  var cachedAt int64
+  err := tx.QueryRow(s.dialect.rebind(`SELECT cached_at FROM name_timelines WHERE name_key = ? AND cached_at > ?`), key, cutoff(s.cfg.GetTtl().Name)).Scan(&cachedAt)
+  if err == sql.ErrNoRows {
+    return nil, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  rows, err := tx.Query(s.dialect.rebind(`SELECT profile_id, name, from_at, until_at, updated_at FROM name_ownerships WHERE name_key = ? ORDER BY position`), key)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  timeline := entity.NewNameTimeline(key)
+  for rows.Next() {
+    var enc, name string
+    var from, until, updatedAt int64
+    err = rows.Scan(&enc, &name, &from, &until, &updatedAt)
+    if err != nil {
+      return nil, err
+    }
+
+    id, err := uuid.Parse(enc)
+    if err != nil {
+      return nil, fmt.Errorf("encountered malformed profile id \"%s\": %s", enc, err)
+    }
+
+    // open periods are stored with an end of zero
+    period := &entity.NameOwnership{
+      Id:        id,
+      Name:      name,
+      From:      time.Unix(from, 0),
+      UpdatedAt: time.Unix(updatedAt, 0),
+    }
+    if until != 0 {
+      period.Until = time.Unix(until, 0)
+    }
+    timeline.Periods = append(timeline.Periods, period)
+  }
+  return timeline, rows.Err()
+}
+
+func (s *SqlStorageBackend) GetNameTimeline(name string) (*entity.NameTimeline, error) {
+  var timeline *entity.NameTimeline
+  err := s.transaction(func(tx *sql.Tx) error {
+    var err error
+    timeline, err = s.getNameTimeline(tx, nameKey(name))
+    return err
+  })
+  return timeline, err
+}
+
+// removes the ownership timeline of a name within a given transaction
+func (s *SqlStorageBackend) deleteNameTimeline(tx *sql.Tx, key string) error {
+  _, err := tx.Exec(s.dialect.rebind(`DELETE FROM name_ownerships WHERE name_key = ?`), key)
+  if err != nil {
+    return err
+  }
+  _, err = tx.Exec(s.dialect.rebind(`DELETE FROM name_timelines WHERE name_key = ?`), key)
+  return err
+}
+
+func (s *SqlStorageBackend) PutNameTimeline(timeline *entity.NameTimeline) error {
+  key := nameKey(timeline.Name)
+
+  return s.transaction(func(tx *sql.Tx) error {
+    err := s.deleteNameTimeline(tx, key)
+    if err != nil {
+      return err
+    }
+
+    _, err = tx.Exec(s.dialect.rebind(`INSERT INTO name_timelines (name_key, cached_at) VALUES (?, ?)`), key, time.Now().Unix())
+    if err != nil {
+      return err
+    }
+
+    for i, period := range timeline.Periods {
+      var until int64
+      if !period.IsOpen() {
+        until = period.Until.Unix()
+      }
+
+      _, err = tx.Exec(s.dialect.rebind(`INSERT INTO name_ownerships (name_key, position, profile_id, name, from_at, until_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`), key, i, period.Id.String(), period.Name, period.From.Unix(), until, period.UpdatedAt.Unix())
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  })
+}
+
+func (s *SqlStorageBackend) PurgeNameTimeline(name string) error {
+  return s.transaction(func(tx *sql.Tx) error {
+    return s.deleteNameTimeline(tx, nameKey(name))
+  })
+}
+
+func (s *SqlStorageBackend) ForEachProfileId(fn func(profileId *entity.ProfileId) error) error {
+  // results are buffered in order to release the connection before passing them on as embedded
+  // databases are limited to a single connection
+  rows, err := s.db.Query(s.dialect.rebind(`SELECT profile_id, name, first_seen_at, last_seen_at, valid_until FROM profile_ids WHERE cached_at > ?`), cutoff(s.cfg.GetTtl().Name))
+  if err != nil {
+    return err
+  }
+  profileIds, err := scanProfileIds(rows)
+  if err != nil {
+    return err
+  }
+
+  for _, profileId := range profileIds {
+    err = fn(profileId)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// retrieves the profile ids of all valid entries within a given table
+func (s *SqlStorageBackend) listProfileIds(table string, ttl time.Duration) ([]uuid.UUID, error) {
+  rows, err := s.db.Query(s.dialect.rebind(`SELECT profile_id FROM `+table+` WHERE cached_at > ?`), cutoff(ttl))
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  ids := make([]uuid.UUID, 0)
+  for rows.Next() {
+    var enc string
+    err = rows.Scan(&enc)
+    if err != nil {
+      return nil, err
+    }
+
+    id, err := uuid.Parse(enc)
+    if err != nil {
+      return nil, fmt.Errorf("encountered malformed profile id \"%s\": %s", enc, err)
+    }
+    ids = append(ids, id)
+  }
+  return ids, rows.Err()
+}
+
+func (s *SqlStorageBackend) ForEachNameHistory(fn func(id uuid.UUID, history *entity.NameChangeHistory) error) error {
+  ids, err := s.listProfileIds("name_histories", s.cfg.GetTtl().NameHistory)
+  if err != nil {
+    return err
+  }
+
+  for _, id := range ids {
+    history, err := s.GetNameHistory(id)
+    if err != nil {
+      return err
+    }
+    if history == nil {
+      continue // expired in the meantime
+    }
+
+    err = fn(id, history)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (s *SqlStorageBackend) ForEachProfile(fn func(profile *entity.Profile) error) error {
+  ids, err := s.listProfileIds("profiles", s.cfg.GetTtl().Profile)
+  if err != nil {
+    return err
+  }
+
+  for _, id := range ids {
+    profile, err := s.GetProfile(id)
+    if err != nil {
+      return err
+    }
+    if profile == nil {
+      continue // expired in the meantime
+    }
+
+    err = fn(profile)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (s *SqlStorageBackend) ForEachNameTimeline(fn func(timeline *entity.NameTimeline) error) error {
+  // keys are buffered for the same reason as associations are buffered within ForEachProfileId
+  rows, err := s.db.Query(s.dialect.rebind(`SELECT name_key FROM name_timelines WHERE cached_at > ?`), cutoff(s.cfg.GetTtl().Name))
+  if err != nil {
+    return err
+  }
+  keys := make([]string, 0)
+  for rows.Next() {
+    var key string
+    err = rows.Scan(&key)
+    if err != nil {
+      rows.Close()
+      return err
+    }
+    keys = append(keys, key)
+  }
+  rows.Close()
+  if err = rows.Err(); err != nil {
+    return err
+  }
+
+  for _, key := range keys {
+    timeline, err := s.GetNameTimeline(key)
+    if err != nil {
+      return err
+    }
+    if timeline == nil {
+      continue // expired in the meantime
+    }
+
+    err = fn(timeline)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// removes all expired entries from the database
+func (s *SqlStorageBackend) removeExpiredEntries() (int64, error) {
+  var removed int64
+  err := s.transaction(func(tx *sql.Tx) error {
+    removed = 0
+
+    ttl := s.cfg.GetTtl()
+    name := cutoff(ttl.Name)
+    history := cutoff(ttl.NameHistory)
+    profile := cutoff(ttl.Profile)
+    blacklist := cutoff(ttl.Blacklist)
+
+    statements := []struct {
+      query  string
+      cutoff int64
+    }{
+      {`DELETE FROM profile_ids WHERE cached_at <= ?`, name},
+      {`DELETE FROM name_ownerships WHERE name_key IN (SELECT name_key FROM name_timelines WHERE cached_at <= ?)`, name},
+      {`DELETE FROM name_timelines WHERE cached_at <= ?`, name},
+      {`DELETE FROM name_changes WHERE profile_id IN (SELECT profile_id FROM name_histories WHERE cached_at <= ?)`, history},
+      {`DELETE FROM name_histories WHERE cached_at <= ?`, history},
+      {`DELETE FROM profile_properties WHERE profile_id IN (SELECT profile_id FROM profiles WHERE cached_at <= ?)`, profile},
+      {`DELETE FROM profile_textures WHERE profile_id IN (SELECT profile_id FROM profiles WHERE cached_at <= ?)`, profile},
+      {`DELETE FROM profiles WHERE cached_at <= ?`, profile},
+      {`DELETE FROM blacklist_hashes WHERE EXISTS (SELECT 1 FROM blacklists WHERE cached_at <= ?)`, blacklist},
+      {`DELETE FROM blacklists WHERE cached_at <= ?`, blacklist},
+    }
+
+    for _, statement := range statements {
+      res, err := tx.Exec(s.dialect.rebind(statement.query), statement.cutoff)
+      if err != nil {
+        return err
+      }
+
+      n, err := res.RowsAffected()
+      if err == nil {
+        removed += n
+      }
+    }
+    return nil
+  })
+  return removed, err
+}
+
+// periodically removes expired entries from the database until the backend is closed
+func (s *SqlStorageBackend) sweep() {
+  for {
+    select {
+    case <-s.done:
+      return
+    case <-s.sweepTicker.C:
+      removed, err := s.removeExpiredEntries()
+      if err != nil {
+        s.logger.Errorf("failed to remove expired entries: %s", err)
+        continue
+      }
+      s.logger.Debugf("removed %d expired rows", removed)
+    }
+  }
+}
+
+func (s *SqlStorageBackend) Close() error {
+  s.sweepTicker.Stop()
+  close(s.done)
+  return s.db.Close()
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+  "os"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/dotStart/Stockpile/stockpile/storage/storagetest"
+  "github.com/google/uuid"
+)
+
+// loads a configuration which selects the sql backend with a given set of parameters
+func loadConfig(f *servertest.Fixture, parameters string) *server.Config {
+  return f.Load("storage \"sql\" {\n" + parameters + "\n}\n")
+}
+
+// defines the backend parameters which select a sqlite database within the fixture directory
+const sqliteParameters = `driver = "sqlite3"
+  dsn = "{dir}/stockpile.db"`
+
+// opens a sql backend for a given configuration
+func open(t *testing.T, cfg *server.Config) *SqlStorageBackend {
+  backend, err := NewSqlStorageBackend(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return backend.(*SqlStorageBackend)
+}
+
+// counts the rows within a given table
+func count(t *testing.T, backend *SqlStorageBackend, table string) int {
+  var n int
+  err := backend.db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return n
+}
+
+func TestSqliteStorageBackend(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  cfg := loadConfig(f, sqliteParameters)
+  storagetest.Test(t, cfg, NewSqlStorageBackend, storagetest.Options{
+    SkipExpiry: testing.Short(),
+  })
+}
+
+// executes the storage conformance suite against a PostgreSQL database when a DSN has been
+// passed via the POSTGRES_DSN environment variable
+func TestPostgresStorageBackend(t *testing.T) {
+  dsn := os.Getenv("POSTGRES_DSN")
+  if dsn == "" {
+    t.Skip("POSTGRES_DSN is not set")
+  }
+
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  cfg := loadConfig(f, `driver = "postgres"
+  dsn = "`+strings.Replace(dsn, `"`, `\"`, -1)+`"`)
+  storagetest.Test(t, cfg, NewSqlStorageBackend, storagetest.Options{
+    SkipExpiry: testing.Short(),
+  })
+}
+
+func TestMigrate(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+  cfg := loadConfig(f, sqliteParameters)
+  latest := migrations[len(migrations)-1].version
+
+  // the schema is created when the database is first opened and left untouched afterwards
+  for i := 0; i < 2; i++ {
+    backend := open(t, cfg)
+    var version, revisions int
+    err := backend.db.QueryRow(`SELECT MAX(version), COUNT(*) FROM schema_migrations`).Scan(&version, &revisions)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if version != latest || revisions != len(migrations) {
+      t.Errorf("open #%d: expected schema version %d with %d revision(s) but got version %d with %d revision(s)", i, latest, len(migrations), version, revisions)
+    }
+
+    if i == 1 {
+      _, err = backend.db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, latest+1, time.Now().Unix())
+      if err != nil {
+        t.Fatal(err)
+      }
+    }
+    backend.Close()
+  }
+
+  // databases which have been migrated by a newer release must be rejected
+  _, err := NewSqlStorageBackend(cfg)
+  if err == nil || !strings.Contains(err.Error(), "is newer than the latest known version") {
+    t.Fatalf("expected newer schema to be rejected but got %v", err)
+  }
+}
+
+// stores one entry of each type within a given backend
+func populate(t *testing.T, backend *SqlStorageBackend) {
+  id := uuid.New()
+  now := time.Now()
+
+  profileId := &entity.ProfileId{Id: id, Name: "sweep"}
+  profileId.UpdateDiscovery(now)
+  err := backend.PutProfileId(profileId)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = backend.PutNameHistory(id, &entity.NameChangeHistory{
+    History: []*entity.NameChange{{Name: "sweep", ValidUntil: now.Add(time.Hour)}},
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = backend.PutProfile(&entity.Profile{
+    Id:   id,
+    Name: "sweep",
+    Properties: map[string]*entity.ProfileProperty{
+      "example": {Name: "example", Value: "value", Signature: "signature"},
+    },
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = backend.PutBlacklist(&entity.Blacklist{Hashes: []string{strings.Repeat("a", 40)}})
+  if err != nil {
+    t.Fatal(err)
+  }
+  timeline := entity.NewNameTimeline("sweep")
+  timeline.Update(id, &entity.NameChangeHistory{
+    History: []*entity.NameChange{{Name: "sweep"}},
+  }, now)
+  err = backend.PutNameTimeline(timeline)
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
+// lists the tables which hold cached entries
+var tables = []string{"profile_ids", "name_histories", "name_changes", "profiles", "profile_properties", "blacklists", "blacklist_hashes", "name_timelines", "name_ownerships"}
+
+func TestRemoveExpiredEntries(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+  cfg := loadConfig(f, sqliteParameters)
+
+  backend := open(t, cfg)
+  defer backend.Close()
+  populate(t, backend)
+
+  // entries which have yet to expire are retained
+  removed, err := backend.removeExpiredEntries()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if removed != 0 {
+    t.Fatalf("expected no rows to be removed but got %d", removed)
+  }
+
+  cfg.Ttl = &server.TtlConfig{}
+  removed, err = backend.removeExpiredEntries()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if removed == 0 {
+    t.Fatal("expected expired rows to be removed")
+  }
+  for _, table := range tables {
+    if n := count(t, backend, table); n != 0 {
+      t.Errorf("expected table %s to be empty but got %d row(s)", table, n)
+    }
+  }
+}
+
+func TestSweep(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+  cfg := loadConfig(f, sqliteParameters+"\n  sweep-interval = \"50ms\"")
+  cfg.Ttl = &server.TtlConfig{}
+
+  backend := open(t, cfg)
+  defer backend.Close()
+  populate(t, backend)
+
+  deadline := time.Now().Add(5 * time.Second)
+  for count(t, backend, "profiles") != 0 {
+    if time.Now().After(deadline) {
+      t.Fatal("expected expired entries to be removed by the sweep")
+    }
+    time.Sleep(25 * time.Millisecond)
+  }
+}
+
+func TestNameTimeline(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+  cfg := loadConfig(f, sqliteParameters)
+
+  backend := open(t, cfg)
+  defer backend.Close()
+
+  // the conformance suite silently skips timelines when a backend does not support them
+  var timelines storage.StorageBackend = backend
+  if _, ok := timelines.(storage.IterableTimelineStorageBackend); !ok {
+    t.Fatal("expected sql backend to support iterable name timelines")
+  }
+  populate(t, backend)
+
+  found := 0
+  err := backend.ForEachNameTimeline(func(timeline *entity.NameTimeline) error {
+    found++
+    if timeline.Name != "sweep" || len(timeline.Periods) != 1 {
+      t.Errorf("expected timeline of name \"sweep\" with a single period but got %v", timeline)
+    } else if !timeline.Periods[0].IsOpen() {
+      t.Errorf("expected open period to remain open but got %v", timeline.Periods[0].Until)
+    }
+    return nil
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  if found != 1 {
+    t.Fatalf("expected 1 timeline but got %d", found)
+  }
+}
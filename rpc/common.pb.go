@@ -5,6 +5,7 @@
 Package rpc is a generated protocol buffer package.
 
 It is generated from these files:
+
 	common.proto
 	events.proto
 	profile.proto
@@ -12,6 +13,7 @@ It is generated from these files:
 	system.proto
 
 It has these top-level messages:
+
 	Profile
 	ProfileProperty
 	ProfileTextures
@@ -172,32 +174,86 @@ func (m *ProfileTextures) GetTimestamp() int64 {
 	return 0
 }
 
+type ConfigChangeSet struct {
+	Changes []*ConfigChange `protobuf:"bytes,1,rep,name=changes" json:"changes,omitempty"`
+}
+
+func (m *ConfigChangeSet) Reset()                    { *m = ConfigChangeSet{} }
+func (m *ConfigChangeSet) String() string            { return proto.CompactTextString(m) }
+func (*ConfigChangeSet) ProtoMessage()               {}
+func (*ConfigChangeSet) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *ConfigChangeSet) GetChanges() []*ConfigChange {
+	if m != nil {
+		return m.Changes
+	}
+	return nil
+}
+
+type ConfigChange struct {
+	Setting string `protobuf:"bytes,1,opt,name=setting" json:"setting,omitempty"`
+	Old     string `protobuf:"bytes,2,opt,name=old" json:"old,omitempty"`
+	New     string `protobuf:"bytes,3,opt,name=new" json:"new,omitempty"`
+}
+
+func (m *ConfigChange) Reset()                    { *m = ConfigChange{} }
+func (m *ConfigChange) String() string            { return proto.CompactTextString(m) }
+func (*ConfigChange) ProtoMessage()               {}
+func (*ConfigChange) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *ConfigChange) GetSetting() string {
+	if m != nil {
+		return m.Setting
+	}
+	return ""
+}
+
+func (m *ConfigChange) GetOld() string {
+	if m != nil {
+		return m.Old
+	}
+	return ""
+}
+
+func (m *ConfigChange) GetNew() string {
+	if m != nil {
+		return m.New
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*Profile)(nil), "rpc.Profile")
 	proto.RegisterType((*ProfileProperty)(nil), "rpc.ProfileProperty")
 	proto.RegisterType((*ProfileTextures)(nil), "rpc.ProfileTextures")
+	proto.RegisterType((*ConfigChangeSet)(nil), "rpc.ConfigChangeSet")
+	proto.RegisterType((*ConfigChange)(nil), "rpc.ConfigChange")
 }
 
 func init() { proto.RegisterFile("common.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 283 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x91, 0xbf, 0x4e, 0xc3, 0x30,
-	0x10, 0xc6, 0xe5, 0x24, 0xa5, 0xf4, 0x8a, 0x40, 0xb2, 0x3a, 0x78, 0x60, 0xb0, 0x32, 0x65, 0xb2,
-	0x10, 0xf0, 0x04, 0x6c, 0x2c, 0xa8, 0x8a, 0x60, 0x60, 0x74, 0x13, 0x53, 0xac, 0x26, 0xb1, 0x65,
-	0x5f, 0x10, 0x3c, 0x08, 0x4f, 0xc0, 0x8b, 0x22, 0xe7, 0xbf, 0xba, 0xf9, 0xfb, 0x7e, 0x77, 0xdf,
-	0x9d, 0xce, 0x70, 0x55, 0x98, 0xba, 0x36, 0x8d, 0xb0, 0xce, 0xa0, 0xa1, 0xb1, 0xb3, 0x45, 0xfa,
-	0x4b, 0x60, 0xbd, 0x77, 0xe6, 0x43, 0x57, 0x8a, 0x5e, 0x43, 0xa4, 0x4b, 0x46, 0x38, 0xc9, 0x36,
-	0x79, 0xa4, 0x4b, 0x4a, 0x21, 0x69, 0x64, 0xad, 0x58, 0xd4, 0x39, 0xdd, 0x9b, 0x3e, 0x02, 0x58,
-	0x67, 0xac, 0x72, 0xa8, 0x95, 0x67, 0x31, 0x8f, 0xb3, 0xed, 0xfd, 0x4e, 0x38, 0x5b, 0x88, 0x21,
-	0x65, 0xdf, 0xd3, 0x9f, 0x7c, 0x51, 0x47, 0xef, 0xe0, 0x12, 0xd5, 0x37, 0xb6, 0x4e, 0x79, 0x96,
-	0x70, 0x72, 0xde, 0xf3, 0x3a, 0xb0, 0x7c, 0xaa, 0x4a, 0xdf, 0xe1, 0xe6, 0x2c, 0x70, 0x5a, 0x87,
-	0x2c, 0xd6, 0xd9, 0xc1, 0xea, 0x4b, 0x56, 0xed, 0xb8, 0x63, 0x2f, 0xe8, 0x2d, 0x6c, 0xbc, 0x3e,
-	0x36, 0x32, 0x44, 0xb1, 0xb8, 0x23, 0xb3, 0x91, 0xfe, 0x91, 0x29, 0x7b, 0x1c, 0x1c, 0x3a, 0x6c,
-	0x6f, 0x3d, 0x8f, 0x17, 0x98, 0x0d, 0xca, 0x61, 0x3b, 0x88, 0x97, 0xf9, 0x1e, 0x4b, 0x8b, 0x32,
-	0x58, 0xfb, 0x93, 0x6e, 0xde, 0x5c, 0x35, 0xcc, 0x1b, 0x65, 0x20, 0x85, 0xb4, 0x2a, 0x90, 0xa4,
-	0x27, 0x83, 0x0c, 0x33, 0x51, 0xd7, 0xca, 0xa3, 0xac, 0x2d, 0x5b, 0x71, 0x92, 0xc5, 0xf9, 0x6c,
-	0x3c, 0xa5, 0xc0, 0xb5, 0x11, 0x47, 0x8d, 0x9f, 0xed, 0x41, 0x94, 0x06, 0x3d, 0x4a, 0x87, 0xc2,
-	0xa3, 0x29, 0x4e, 0x56, 0x57, 0x2a, 0x9c, 0xef, 0x70, 0xd1, 0x7d, 0xe4, 0xc3, 0x7f, 0x00, 0x00,
-	0x00, 0xff, 0xff, 0xb9, 0x29, 0x12, 0x2a, 0xd8, 0x01, 0x00, 0x00,
+	// 346 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x52, 0xcd, 0x4e, 0xf3, 0x30,
+	0x10, 0x94, 0x9b, 0xf6, 0xeb, 0xd7, 0x6d, 0x45, 0xc1, 0xea, 0xc1, 0x07, 0x0e, 0x51, 0x4e, 0x95,
+	0x90, 0x22, 0x04, 0x9c, 0x39, 0xd0, 0x13, 0x12, 0x42, 0x55, 0x80, 0x03, 0x47, 0x37, 0x71, 0x53,
+	0xab, 0x89, 0x6d, 0xd9, 0x2e, 0x3f, 0x0f, 0xc2, 0x13, 0xf0, 0xa2, 0xc8, 0x8e, 0xd3, 0x44, 0xbd,
+	0xed, 0xcc, 0xac, 0x77, 0x67, 0x47, 0x86, 0x59, 0x2e, 0xeb, 0x5a, 0x8a, 0x54, 0x69, 0x69, 0x25,
+	0x8e, 0xb4, 0xca, 0x93, 0x1f, 0x04, 0xe3, 0xb5, 0x96, 0x5b, 0x5e, 0x31, 0x7c, 0x06, 0x03, 0x5e,
+	0x10, 0x14, 0xa3, 0xe5, 0x24, 0x1b, 0xf0, 0x02, 0x63, 0x18, 0x0a, 0x5a, 0x33, 0x32, 0xf0, 0x8c,
+	0xaf, 0xf1, 0x1d, 0x80, 0xd2, 0x52, 0x31, 0x6d, 0x39, 0x33, 0x24, 0x8a, 0xa3, 0xe5, 0xf4, 0x66,
+	0x91, 0x6a, 0x95, 0xa7, 0x61, 0xca, 0xba, 0x51, 0xbf, 0xb3, 0x5e, 0x1f, 0xbe, 0x86, 0xff, 0x96,
+	0x7d, 0xd9, 0x83, 0x66, 0x86, 0x0c, 0x63, 0x74, 0xfa, 0xe6, 0x35, 0x68, 0xd9, 0xb1, 0x2b, 0x79,
+	0x87, 0xf9, 0xc9, 0xc0, 0xa3, 0x1d, 0xd4, 0xb3, 0xb3, 0x80, 0xd1, 0x07, 0xad, 0x0e, 0xad, 0xc7,
+	0x06, 0xe0, 0x4b, 0x98, 0x18, 0x5e, 0x0a, 0xea, 0x46, 0x91, 0xc8, 0x2b, 0x1d, 0x91, 0xfc, 0x22,
+	0x98, 0x9f, 0x2c, 0x76, 0x2f, 0x54, 0x43, 0x3d, 0xb6, 0x09, 0x74, 0x04, 0x8e, 0x61, 0x1a, 0xc0,
+	0x73, 0x97, 0x47, 0x9f, 0xc2, 0x04, 0xc6, 0x66, 0xcf, 0xc5, 0x9b, 0xae, 0xc2, 0xbe, 0x16, 0x3a,
+	0x25, 0xa7, 0x8a, 0x39, 0x65, 0xd8, 0x28, 0x01, 0xba, 0x9d, 0x96, 0xd7, 0xcc, 0x58, 0x5a, 0x2b,
+	0x32, 0x8a, 0xd1, 0x32, 0xca, 0x3a, 0x22, 0xb9, 0x87, 0xf9, 0x4a, 0x8a, 0x2d, 0x2f, 0x57, 0x3b,
+	0x2a, 0x4a, 0xf6, 0xc2, 0x2c, 0xbe, 0x82, 0x71, 0xee, 0x81, 0x21, 0xc8, 0x07, 0x7f, 0xe1, 0x43,
+	0xec, 0xb7, 0x65, 0x6d, 0x47, 0xf2, 0x04, 0xb3, 0xbe, 0xe0, 0x1d, 0x32, 0x6b, 0xb9, 0x28, 0xc3,
+	0x7d, 0x2d, 0xc4, 0xe7, 0x10, 0xc9, 0xaa, 0x08, 0x57, 0xb9, 0xd2, 0x31, 0x82, 0x7d, 0x86, 0x4b,
+	0x5c, 0xf9, 0x90, 0x40, 0xcc, 0x65, 0x5a, 0x72, 0xbb, 0x3b, 0x6c, 0xd2, 0x42, 0x5a, 0x63, 0xa9,
+	0xb6, 0xa9, 0xb1, 0x32, 0xdf, 0x2b, 0x5e, 0x31, 0xe7, 0x63, 0xf3, 0xcf, 0x7f, 0xab, 0xdb, 0xbf,
+	0x01, 0x00, 0xb9, 0x22, 0x8b, 0x9c, 0x66, 0x02, 0x00, 0x00,
 }
@@ -26,6 +26,7 @@ const (
 	EventType_NAME_HISTORY EventType = 1
 	EventType_PROFILE      EventType = 2
 	EventType_BLACKLIST    EventType = 3
+	EventType_CONFIG       EventType = 4
 )
 
 var EventType_name = map[int32]string{
@@ -33,12 +34,14 @@ var EventType_name = map[int32]string{
 	1: "NAME_HISTORY",
 	2: "PROFILE",
 	3: "BLACKLIST",
+	4: "CONFIG",
 }
 var EventType_value = map[string]int32{
 	"PROFILE_ID":   0,
 	"NAME_HISTORY": 1,
 	"PROFILE":      2,
 	"BLACKLIST":    3,
+	"CONFIG":       4,
 }
 
 func (x EventType) String() string {
@@ -257,29 +260,30 @@ var _EventService_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("events.proto", fileDescriptor1) }
 
 var fileDescriptor1 = []byte{
-	// 378 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x91, 0x5d, 0xcf, 0x93, 0x30,
-	0x18, 0x86, 0x05, 0xf6, 0x91, 0x3d, 0x20, 0x21, 0x8d, 0x51, 0x9c, 0x27, 0x0b, 0x07, 0x66, 0x2e,
-	0xa6, 0x33, 0xe8, 0x1f, 0x60, 0x0e, 0x23, 0x19, 0x3a, 0x02, 0xec, 0xc0, 0xa3, 0x85, 0x8f, 0x6e,
-	0xd6, 0x6d, 0x94, 0x40, 0xb7, 0x84, 0xdf, 0xe4, 0x9f, 0x34, 0x14, 0x74, 0xe6, 0x7d, 0x93, 0xf7,
-	0xac, 0x7d, 0xae, 0x2b, 0x4f, 0xee, 0xde, 0x05, 0x8d, 0xdc, 0x48, 0xc1, 0x6b, 0x5c, 0x56, 0x8c,
-	0x33, 0xa4, 0x54, 0x65, 0x36, 0x7d, 0x7d, 0x64, 0xec, 0x78, 0x26, 0x4b, 0x31, 0x4a, 0xaf, 0x87,
-	0x65, 0x52, 0x34, 0x1d, 0x9f, 0xbe, 0x79, 0x88, 0xc8, 0xa5, 0xe4, 0x3d, 0xb4, 0x7e, 0x4b, 0x30,
-	0x74, 0xdb, 0x6d, 0xc8, 0x82, 0x01, 0x6f, 0x4a, 0x62, 0x4a, 0x33, 0x69, 0xae, 0xdb, 0x3a, 0xae,
-	0xca, 0x0c, 0x0b, 0x12, 0x37, 0x25, 0x09, 0x05, 0x43, 0x73, 0x18, 0x25, 0x19, 0xa7, 0xac, 0x30,
-	0x65, 0x61, 0x19, 0x77, 0xcb, 0x11, 0xf3, 0xb0, 0xe7, 0xe8, 0x2d, 0x28, 0x27, 0xd2, 0x98, 0xca,
-	0x4c, 0x9a, 0xab, 0xf6, 0x0b, 0xdc, 0x45, 0xc0, 0x7f, 0x23, 0x60, 0xa7, 0x68, 0xc2, 0x56, 0x40,
-	0xef, 0x61, 0xc4, 0xd2, 0x5f, 0x24, 0xe3, 0xe6, 0xe0, 0x09, 0xb5, 0x77, 0x2c, 0x1b, 0xb4, 0xa0,
-	0x62, 0x07, 0x7a, 0x26, 0x5e, 0xbe, 0x21, 0x0d, 0x42, 0x30, 0x28, 0x92, 0x4b, 0x97, 0x79, 0x12,
-	0x8a, 0x33, 0xd2, 0x41, 0x4e, 0xb8, 0xc8, 0xa7, 0x84, 0x72, 0xc2, 0xad, 0x57, 0x30, 0xec, 0x64,
-	0x1d, 0x64, 0x9a, 0xf7, 0xaa, 0x4c, 0xf3, 0x85, 0x07, 0x93, 0x7f, 0xef, 0x43, 0x3a, 0x40, 0x10,
-	0x6e, 0xbf, 0x78, 0xbe, 0xbb, 0xf7, 0xd6, 0xc6, 0x33, 0x64, 0x80, 0xf6, 0xdd, 0xf9, 0xe6, 0xee,
-	0xbf, 0x7a, 0x51, 0xbc, 0x0d, 0x7f, 0x18, 0x12, 0x52, 0x61, 0xdc, 0x1b, 0x86, 0x8c, 0x9e, 0xc3,
-	0x64, 0xe5, 0x3b, 0x9f, 0x37, 0xbe, 0x17, 0xc5, 0x86, 0xb2, 0x78, 0x07, 0xea, 0x7f, 0x25, 0xb4,
-	0x34, 0xd8, 0x06, 0x3b, 0xdf, 0x89, 0xdd, 0x76, 0x97, 0x0a, 0xe3, 0x5d, 0xb0, 0x16, 0x17, 0xc9,
-	0x5e, 0x83, 0x26, 0xd4, 0x88, 0x54, 0x37, 0x9a, 0x11, 0xf4, 0x09, 0xb4, 0x88, 0x57, 0x24, 0xb9,
-	0x88, 0x69, 0x8d, 0x5e, 0x3e, 0x2a, 0xc0, 0x6d, 0xbf, 0x6b, 0x0a, 0xf7, 0xaa, 0x3f, 0x48, 0x2b,
-	0x0b, 0x66, 0x94, 0xe1, 0x23, 0xe5, 0x3f, 0xaf, 0x29, 0xce, 0x19, 0xaf, 0x79, 0x52, 0x71, 0x5c,
-	0x73, 0x96, 0x9d, 0x4a, 0x7a, 0x26, 0xad, 0x9b, 0x8e, 0xc4, 0x86, 0x8f, 0x7f, 0x02, 0x00, 0x00,
-	0xff, 0xff, 0x60, 0x8e, 0xe6, 0x95, 0x2e, 0x02, 0x00, 0x00,
+	// 386 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x91, 0xdd, 0x8e, 0x93, 0x40,
+	0x18, 0x86, 0xe5, 0x67, 0xd9, 0xf4, 0x03, 0x09, 0x99, 0x18, 0xc5, 0x7a, 0xd2, 0x70, 0x60, 0xea,
+	0xc6, 0xcc, 0x1a, 0xf4, 0x06, 0xd8, 0x2d, 0xab, 0x64, 0x71, 0x21, 0x40, 0x0f, 0x3c, 0xda, 0xf0,
+	0x33, 0xad, 0x63, 0x5b, 0x86, 0xc0, 0xb4, 0x09, 0xd7, 0xe4, 0x4d, 0x1a, 0x06, 0xb4, 0x46, 0x93,
+	0x3d, 0x9b, 0xf9, 0x9e, 0x27, 0x5f, 0xde, 0x79, 0x07, 0x0c, 0x72, 0x22, 0x35, 0xef, 0x70, 0xd3,
+	0x32, 0xce, 0x90, 0xd2, 0x36, 0xe5, 0xfc, 0xf5, 0x96, 0xb1, 0xed, 0x9e, 0x5c, 0x8b, 0x51, 0x71,
+	0xdc, 0x5c, 0xe7, 0x75, 0x3f, 0xf2, 0xf9, 0x9b, 0x7f, 0x11, 0x39, 0x34, 0x7c, 0x82, 0xce, 0x4f,
+	0x09, 0x2e, 0xfc, 0x61, 0x1b, 0x72, 0x40, 0xe5, 0x7d, 0x43, 0x6c, 0x69, 0x21, 0x2d, 0x4d, 0xd7,
+	0xc4, 0x6d, 0x53, 0x62, 0x41, 0xb2, 0xbe, 0x21, 0x89, 0x60, 0x68, 0x09, 0x5a, 0x5e, 0x72, 0xca,
+	0x6a, 0x5b, 0x16, 0x96, 0x75, 0xb6, 0x3c, 0x31, 0x4f, 0x26, 0x8e, 0xde, 0x82, 0xb2, 0x23, 0xbd,
+	0xad, 0x2c, 0xa4, 0xa5, 0xee, 0xbe, 0xc0, 0x63, 0x04, 0xfc, 0x3b, 0x02, 0xf6, 0xea, 0x3e, 0x19,
+	0x04, 0xf4, 0x1e, 0x34, 0x56, 0xfc, 0x20, 0x25, 0xb7, 0xd5, 0x27, 0xd4, 0xc9, 0x71, 0x5c, 0x30,
+	0xe2, 0x96, 0x6d, 0xe8, 0x9e, 0x04, 0xd5, 0x3d, 0xe9, 0x11, 0x02, 0xb5, 0xce, 0x0f, 0x63, 0xe6,
+	0x59, 0x22, 0xce, 0xc8, 0x04, 0x39, 0xe7, 0x22, 0x9f, 0x92, 0xc8, 0x39, 0x77, 0x5e, 0xc1, 0xc5,
+	0x28, 0x9b, 0x20, 0xd3, 0x6a, 0x52, 0x65, 0x5a, 0x5d, 0xad, 0x61, 0xf6, 0xe7, 0x7d, 0xc8, 0x04,
+	0x88, 0x93, 0xe8, 0x2e, 0x08, 0xfd, 0xc7, 0x60, 0x65, 0x3d, 0x43, 0x16, 0x18, 0x0f, 0xde, 0x57,
+	0xff, 0xf1, 0x4b, 0x90, 0x66, 0x51, 0xf2, 0xcd, 0x92, 0x90, 0x0e, 0x97, 0x93, 0x61, 0xc9, 0xe8,
+	0x39, 0xcc, 0x6e, 0x42, 0xef, 0xf6, 0x3e, 0x0c, 0xd2, 0xcc, 0x52, 0x10, 0x80, 0x76, 0x1b, 0x3d,
+	0xdc, 0x05, 0x9f, 0x2d, 0xf5, 0xea, 0x1d, 0xe8, 0x7f, 0x15, 0x32, 0x98, 0x71, 0x14, 0xaf, 0x43,
+	0x2f, 0xf3, 0x87, 0xbd, 0x3a, 0x5c, 0xae, 0xe3, 0x95, 0xb8, 0x48, 0xee, 0x0a, 0x0c, 0xa1, 0xa6,
+	0xa4, 0x3d, 0xd1, 0x92, 0xa0, 0x4f, 0x60, 0xa4, 0xbc, 0x25, 0xf9, 0x41, 0x4c, 0x3b, 0xf4, 0xf2,
+	0xbf, 0x32, 0xfc, 0xe1, 0xeb, 0xe6, 0x70, 0xae, 0xfd, 0x83, 0x74, 0xe3, 0xc0, 0x82, 0x32, 0xbc,
+	0xa5, 0xfc, 0xfb, 0xb1, 0xc0, 0x15, 0xe3, 0x1d, 0xcf, 0x5b, 0x8e, 0x3b, 0xce, 0xca, 0x5d, 0x43,
+	0xf7, 0x64, 0x70, 0x0b, 0x4d, 0x6c, 0xf8, 0xf8, 0x6b, 0x00, 0x1b, 0xde, 0x60, 0x33, 0x3a, 0x02,
+	0x00, 0x00,
 }
@@ -40,6 +40,10 @@ func (m *IdRequest) GetId() string {
 type GetIdRequest struct {
 	Name      string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 	Timestamp int64  `protobuf:"varint,2,opt,name=timestamp" json:"timestamp,omitempty"`
+	// *
+	// Derives the identifier of the name in the way offline mode servers do (e.g.
+	// based on "OfflinePlayer:<name>") instead of consulting the cache.
+	Offline bool `protobuf:"varint,3,opt,name=offline" json:"offline,omitempty"`
 }
 
 func (m *GetIdRequest) Reset()                    { *m = GetIdRequest{} }
@@ -61,6 +65,13 @@ func (m *GetIdRequest) GetTimestamp() int64 {
 	return 0
 }
 
+func (m *GetIdRequest) GetOffline() bool {
+	if m != nil {
+		return m.Offline
+	}
+	return false
+}
+
 // *
 // Represents a profile <-> name mapping at a specified time.
 type ProfileId struct {
@@ -207,6 +218,100 @@ func (m *BulkIdResponse) GetIds() []*ProfileId {
 	return nil
 }
 
+// *
+// Used to transmit a display name as the sole parameter.
+type NameRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *NameRequest) Reset()                    { *m = NameRequest{} }
+func (m *NameRequest) String() string            { return proto.CompactTextString(m) }
+func (*NameRequest) ProtoMessage()               {}
+func (*NameRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{7} }
+
+func (m *NameRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// *
+// Represents a period during which a name has been assigned to a profile.
+type NameOwnership struct {
+	Id        string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Name      string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	From      int64  `protobuf:"varint,3,opt,name=from" json:"from,omitempty"`
+	Until     int64  `protobuf:"varint,4,opt,name=until" json:"until,omitempty"`
+	UpdatedAt int64  `protobuf:"varint,5,opt,name=updatedAt" json:"updatedAt,omitempty"`
+}
+
+func (m *NameOwnership) Reset()                    { *m = NameOwnership{} }
+func (m *NameOwnership) String() string            { return proto.CompactTextString(m) }
+func (*NameOwnership) ProtoMessage()               {}
+func (*NameOwnership) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{8} }
+
+func (m *NameOwnership) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *NameOwnership) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *NameOwnership) GetFrom() int64 {
+	if m != nil {
+		return m.From
+	}
+	return 0
+}
+
+func (m *NameOwnership) GetUntil() int64 {
+	if m != nil {
+		return m.Until
+	}
+	return 0
+}
+
+func (m *NameOwnership) GetUpdatedAt() int64 {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return 0
+}
+
+// *
+// Represents the known ownership periods of a name.
+type NameTimeline struct {
+	Name    string           `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Periods []*NameOwnership `protobuf:"bytes,2,rep,name=periods" json:"periods,omitempty"`
+}
+
+func (m *NameTimeline) Reset()                    { *m = NameTimeline{} }
+func (m *NameTimeline) String() string            { return proto.CompactTextString(m) }
+func (*NameTimeline) ProtoMessage()               {}
+func (*NameTimeline) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{9} }
+
+func (m *NameTimeline) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *NameTimeline) GetPeriods() []*NameOwnership {
+	if m != nil {
+		return m.Periods
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*IdRequest)(nil), "rpc.IdRequest")
 	proto.RegisterType((*GetIdRequest)(nil), "rpc.GetIdRequest")
@@ -215,6 +320,9 @@ func init() {
 	proto.RegisterType((*NameHistoryEntry)(nil), "rpc.NameHistoryEntry")
 	proto.RegisterType((*BulkIdRequest)(nil), "rpc.BulkIdRequest")
 	proto.RegisterType((*BulkIdResponse)(nil), "rpc.BulkIdResponse")
+	proto.RegisterType((*NameRequest)(nil), "rpc.NameRequest")
+	proto.RegisterType((*NameOwnership)(nil), "rpc.NameOwnership")
+	proto.RegisterType((*NameTimeline)(nil), "rpc.NameTimeline")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -237,6 +345,9 @@ type ProfileServiceClient interface {
 	//
 	// If no profile has been associated with the specified name, an unpopulated
 	// object is returned instead.
+	//
+	// Offline mode identifiers are returned for any name when the offline flag is
+	// set.
 	GetId(ctx context.Context, in *GetIdRequest, opts ...grpc.CallOption) (*ProfileId, error)
 	// *
 	// Retrieves a complete history of name changes for the profile associated
@@ -264,6 +375,18 @@ type ProfileServiceClient interface {
 	// If no profile with the specified identifier exists, an unpopulated object
 	// is returned instead.
 	GetProfile(ctx context.Context, in *IdRequest, opts ...grpc.CallOption) (*Profile, error)
+	// *
+	// Retrieves all known ownership periods of a given name in chronological
+	// order.
+	//
+	// Timelines are derived from the name histories of the profiles which have
+	// used the name and are reconciled with the history of its current owner
+	// before they are returned. Periods which are still ongoing report an until
+	// timestamp of zero.
+	//
+	// When the storage backend does not support timelines, an error is returned
+	// instead.
+	GetNameTimeline(ctx context.Context, in *NameRequest, opts ...grpc.CallOption) (*NameTimeline, error)
 }
 
 type profileServiceClient struct {
@@ -310,6 +433,15 @@ func (c *profileServiceClient) GetProfile(ctx context.Context, in *IdRequest, op
 	return out, nil
 }
 
+func (c *profileServiceClient) GetNameTimeline(ctx context.Context, in *NameRequest, opts ...grpc.CallOption) (*NameTimeline, error) {
+	out := new(NameTimeline)
+	err := grpc.Invoke(ctx, "/rpc.ProfileService/GetNameTimeline", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for ProfileService service
 
 type ProfileServiceServer interface {
@@ -322,6 +454,9 @@ type ProfileServiceServer interface {
 	//
 	// If no profile has been associated with the specified name, an unpopulated
 	// object is returned instead.
+	//
+	// Offline mode identifiers are returned for any name when the offline flag is
+	// set.
 	GetId(context.Context, *GetIdRequest) (*ProfileId, error)
 	// *
 	// Retrieves a complete history of name changes for the profile associated
@@ -349,6 +484,18 @@ type ProfileServiceServer interface {
 	// If no profile with the specified identifier exists, an unpopulated object
 	// is returned instead.
 	GetProfile(context.Context, *IdRequest) (*Profile, error)
+	// *
+	// Retrieves all known ownership periods of a given name in chronological
+	// order.
+	//
+	// Timelines are derived from the name histories of the profiles which have
+	// used the name and are reconciled with the history of its current owner
+	// before they are returned. Periods which are still ongoing report an until
+	// timestamp of zero.
+	//
+	// When the storage backend does not support timelines, an error is returned
+	// instead.
+	GetNameTimeline(context.Context, *NameRequest) (*NameTimeline, error)
 }
 
 func RegisterProfileServiceServer(s *grpc.Server, srv ProfileServiceServer) {
@@ -427,6 +574,24 @@ func _ProfileService_GetProfile_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProfileService_GetNameTimeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProfileServiceServer).GetNameTimeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.ProfileService/GetNameTimeline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProfileServiceServer).GetNameTimeline(ctx, req.(*NameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _ProfileService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "rpc.ProfileService",
 	HandlerType: (*ProfileServiceServer)(nil),
@@ -447,6 +612,10 @@ var _ProfileService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetProfile",
 			Handler:    _ProfileService_GetProfile_Handler,
 		},
+		{
+			MethodName: "GetNameTimeline",
+			Handler:    _ProfileService_GetNameTimeline_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "profile.proto",
@@ -455,31 +624,38 @@ var _ProfileService_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("profile.proto", fileDescriptor2) }
 
 var fileDescriptor2 = []byte{
-	// 414 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x53, 0xd1, 0x8a, 0x9b, 0x40,
-	0x14, 0x45, 0x6d, 0x76, 0xf1, 0x9a, 0x95, 0xed, 0xb4, 0x05, 0x49, 0x4b, 0x11, 0xa1, 0x10, 0xf6,
-	0xc1, 0x82, 0xed, 0x07, 0x74, 0x17, 0x4a, 0xba, 0x2f, 0xa5, 0xb8, 0xed, 0x6b, 0xc1, 0x75, 0x66,
-	0xe3, 0x10, 0x75, 0xa6, 0x33, 0x37, 0x81, 0x7c, 0x46, 0x7f, 0xad, 0x5f, 0x54, 0x1c, 0x4d, 0x9c,
-	0x98, 0x7d, 0xd3, 0x73, 0xcf, 0x3d, 0xe7, 0x9e, 0x3b, 0x33, 0x70, 0x25, 0x95, 0x78, 0xe2, 0x35,
-	0x4b, 0xa5, 0x12, 0x28, 0x88, 0xa7, 0x64, 0xb9, 0x98, 0x97, 0xa2, 0x69, 0x44, 0xdb, 0x43, 0xc9,
-	0x5b, 0xf0, 0xef, 0x69, 0xce, 0xfe, 0x6c, 0x99, 0x46, 0x12, 0x82, 0xcb, 0x69, 0xe4, 0xc4, 0xce,
-	0xd2, 0xcf, 0x5d, 0x4e, 0x93, 0x2f, 0x30, 0x5f, 0x31, 0x1c, 0xeb, 0x04, 0x5e, 0xb4, 0x45, 0xc3,
-	0x06, 0x86, 0xf9, 0x26, 0xef, 0xc0, 0x47, 0xde, 0x30, 0x8d, 0x45, 0x23, 0x23, 0x37, 0x76, 0x96,
-	0x5e, 0x3e, 0x02, 0xc9, 0x5f, 0x07, 0xfc, 0x1f, 0xfd, 0x0c, 0xf7, 0x74, 0xaa, 0x7f, 0xd4, 0x73,
-	0x2d, 0xbd, 0xf7, 0x00, 0xbb, 0xa2, 0xe6, 0xf4, 0x57, 0x8b, 0xbc, 0x8e, 0x66, 0x46, 0xd0, 0x42,
-	0x48, 0x0c, 0xc1, 0x13, 0x57, 0x1a, 0x1f, 0x18, 0x6b, 0x6f, 0x31, 0xba, 0x30, 0x04, 0x1b, 0xea,
-	0x14, 0xea, 0xe2, 0x48, 0xb8, 0xec, 0x15, 0x46, 0x24, 0xf9, 0x0d, 0xc1, 0xf7, 0xa2, 0x61, 0xdf,
-	0xb8, 0x46, 0xa1, 0xf6, 0xe4, 0x23, 0x5c, 0x56, 0xfd, 0x67, 0xe4, 0xc4, 0xde, 0x32, 0xc8, 0xde,
-	0xa4, 0x4a, 0x96, 0xa9, 0x45, 0xf9, 0xda, 0xa2, 0xda, 0xe7, 0x07, 0xd6, 0x64, 0x42, 0x77, 0x3a,
-	0x61, 0x52, 0xc1, 0xf5, 0xb4, 0xf9, 0xd9, 0xcd, 0xc5, 0x10, 0x94, 0x55, 0xd1, 0xae, 0x19, 0xfd,
-	0x29, 0x6e, 0x71, 0x10, 0xb2, 0xa1, 0x89, 0x93, 0x77, 0xe6, 0xf4, 0x01, 0xae, 0xee, 0xb6, 0xf5,
-	0x66, 0x3c, 0xa0, 0xd7, 0x30, 0xeb, 0xa4, 0xb5, 0x49, 0xe2, 0xe7, 0xfd, 0x4f, 0x92, 0x41, 0x78,
-	0xa0, 0x69, 0x29, 0x5a, 0xdd, 0x59, 0x7b, 0x9c, 0xea, 0x21, 0x6f, 0x68, 0xf2, 0x1e, 0x4f, 0x29,
-	0xef, 0x4a, 0xd9, 0x3f, 0x07, 0xc2, 0x01, 0x7a, 0x60, 0x6a, 0xc7, 0x4b, 0x46, 0x6e, 0x60, 0x66,
-	0x6e, 0x03, 0x79, 0x69, 0x1a, 0xec, 0x9b, 0xb1, 0x98, 0x68, 0x90, 0x0c, 0xc2, 0x15, 0x43, 0x7b,
-	0xcd, 0x3d, 0x63, 0xec, 0xb8, 0x9e, 0x6e, 0x99, 0x7c, 0x06, 0xbf, 0x1b, 0xb3, 0xf7, 0x20, 0xa6,
-	0x7c, 0x92, 0x6e, 0xf1, 0xea, 0x04, 0x1b, 0xa2, 0xdc, 0x00, 0xac, 0x18, 0x0e, 0xce, 0x67, 0x2e,
-	0x73, 0x7b, 0xae, 0xbb, 0x04, 0x62, 0x2e, 0xd2, 0x35, 0xc7, 0x6a, 0xfb, 0x98, 0x52, 0x81, 0x1a,
-	0x0b, 0x85, 0xa9, 0x46, 0x51, 0x6e, 0x64, 0xf7, 0x4a, 0x94, 0x2c, 0x1f, 0x2f, 0xcc, 0xbb, 0xf8,
-	0xf4, 0x3f, 0x00, 0x00, 0xff, 0xff, 0x9b, 0x70, 0xdf, 0x90, 0x3b, 0x03, 0x00, 0x00,
+	// 528 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0x5d, 0x6b, 0xd4, 0x40,
+	0x14, 0x25, 0xc9, 0x6e, 0xd7, 0xdc, 0xfd, 0xb0, 0x1d, 0x15, 0xc2, 0x2a, 0x12, 0x03, 0xc2, 0x52,
+	0x24, 0xc2, 0x2a, 0xbe, 0x6f, 0x41, 0xd6, 0xbe, 0x68, 0x49, 0xeb, 0x8b, 0x0f, 0x42, 0x9a, 0x99,
+	0xed, 0x0e, 0x4d, 0x32, 0xe3, 0xcc, 0xa4, 0x52, 0xfc, 0x07, 0xbe, 0xf9, 0x8f, 0x65, 0x66, 0xf2,
+	0xd5, 0xb4, 0x88, 0x6f, 0x33, 0x27, 0xe7, 0xde, 0x73, 0xee, 0x47, 0x06, 0xe6, 0x5c, 0xb0, 0x1d,
+	0xcd, 0x49, 0xcc, 0x05, 0x53, 0x0c, 0x79, 0x82, 0x67, 0xcb, 0x59, 0xc6, 0x8a, 0x82, 0x95, 0x16,
+	0x8a, 0x9e, 0x83, 0x7f, 0x8a, 0x13, 0xf2, 0xa3, 0x22, 0x52, 0xa1, 0x05, 0xb8, 0x14, 0x07, 0x4e,
+	0xe8, 0xac, 0xfc, 0xc4, 0xa5, 0x38, 0xfa, 0x06, 0xb3, 0x2d, 0x51, 0xdd, 0x77, 0x04, 0xa3, 0x32,
+	0x2d, 0x48, 0xcd, 0x30, 0x67, 0xf4, 0x02, 0x7c, 0x45, 0x0b, 0x22, 0x55, 0x5a, 0xf0, 0xc0, 0x0d,
+	0x9d, 0x95, 0x97, 0x74, 0x00, 0x0a, 0x60, 0xc2, 0x76, 0xbb, 0x9c, 0x96, 0x24, 0xf0, 0x42, 0x67,
+	0xf5, 0x28, 0x69, 0xae, 0xd1, 0x1f, 0x07, 0xfc, 0x33, 0xeb, 0xee, 0x14, 0x0f, 0x95, 0x5b, 0x25,
+	0xb7, 0xa7, 0xf4, 0x12, 0xe0, 0x26, 0xcd, 0x29, 0xfe, 0x5a, 0x2a, 0x9a, 0x07, 0x63, 0x23, 0xd5,
+	0x43, 0x50, 0x08, 0xd3, 0x1d, 0x15, 0x52, 0x9d, 0x13, 0x52, 0x6e, 0x54, 0x70, 0x60, 0x08, 0x7d,
+	0x48, 0x67, 0xc8, 0xd3, 0x96, 0x30, 0xb1, 0x19, 0x3a, 0x24, 0xfa, 0x0e, 0xd3, 0xcf, 0x69, 0x41,
+	0x3e, 0x51, 0xa9, 0x98, 0xb8, 0x45, 0x6f, 0x61, 0xb2, 0xb7, 0xc7, 0xc0, 0x09, 0xbd, 0xd5, 0x74,
+	0xfd, 0x2c, 0x16, 0x3c, 0x8b, 0x7b, 0x94, 0x8f, 0xa5, 0x12, 0xb7, 0x49, 0xc3, 0x1a, 0x38, 0x74,
+	0x87, 0x0e, 0xa3, 0x3d, 0x1c, 0x0e, 0x83, 0x1f, 0xec, 0x69, 0x08, 0xd3, 0x6c, 0x9f, 0x96, 0x57,
+	0x04, 0x5f, 0xb0, 0x8d, 0xaa, 0x13, 0xf5, 0xa1, 0x81, 0x92, 0x77, 0x4f, 0xe9, 0x35, 0xcc, 0x4f,
+	0xaa, 0xfc, 0xba, 0x1b, 0xdd, 0x53, 0x18, 0xeb, 0xd4, 0xd2, 0x54, 0xe2, 0x27, 0xf6, 0x12, 0xad,
+	0x61, 0xd1, 0xd0, 0x24, 0x67, 0xa5, 0xd4, 0xd2, 0x1e, 0xc5, 0xb2, 0xae, 0x77, 0x61, 0xea, 0x6d,
+	0xa7, 0x94, 0xe8, 0x4f, 0xd1, 0x2b, 0xdb, 0xa4, 0x7f, 0xec, 0x44, 0xf4, 0x0b, 0xe6, 0x9a, 0xf2,
+	0xe5, 0x67, 0x49, 0x84, 0xdc, 0x53, 0xfe, 0x5f, 0xe3, 0x45, 0x30, 0xda, 0x09, 0x56, 0xd4, 0xc5,
+	0x98, 0xb3, 0x76, 0x5d, 0x99, 0x0a, 0x47, 0x06, 0xb4, 0x17, 0xbd, 0x72, 0x15, 0xc7, 0xa9, 0x22,
+	0x78, 0xa3, 0xea, 0x3d, 0xe8, 0x80, 0xe8, 0x0c, 0x66, 0x5a, 0xfc, 0x82, 0x16, 0x44, 0x2f, 0xda,
+	0x83, 0x0d, 0x7e, 0x03, 0x13, 0x4e, 0x04, 0x65, 0x58, 0x06, 0xae, 0xa9, 0x14, 0xb5, 0x93, 0x6d,
+	0x4d, 0x27, 0x0d, 0x65, 0xfd, 0xdb, 0x85, 0x45, 0xdd, 0x84, 0x73, 0x22, 0x6e, 0x68, 0x46, 0xd0,
+	0x31, 0x8c, 0xcd, 0x9f, 0x81, 0x8e, 0x4c, 0x60, 0xff, 0x2f, 0x59, 0x0e, 0xba, 0x86, 0xd6, 0xb0,
+	0xd8, 0x12, 0xd5, 0x5f, 0x2c, 0xcb, 0xe8, 0x22, 0x0e, 0x87, 0x7b, 0x85, 0xde, 0x83, 0xaf, 0x07,
+	0x63, 0x35, 0xac, 0xb9, 0x3b, 0xf3, 0x5c, 0x3e, 0xb9, 0x83, 0xd5, 0xc3, 0x3b, 0x06, 0xd8, 0x12,
+	0x55, 0x2b, 0xdf, 0x53, 0x99, 0xf5, 0x7d, 0xa1, 0x0f, 0xf0, 0xb8, 0x76, 0xd5, 0x76, 0xaa, 0xb3,
+	0xd1, 0x84, 0x1c, 0xb5, 0x48, 0x43, 0x3a, 0x89, 0x20, 0xa4, 0x2c, 0xbe, 0xa2, 0x6a, 0x5f, 0x5d,
+	0xc6, 0x98, 0x29, 0xa9, 0x52, 0xa1, 0x62, 0xa9, 0x58, 0x76, 0xcd, 0xf5, 0x4b, 0x23, 0x78, 0x76,
+	0x79, 0x60, 0xde, 0x96, 0x77, 0x7f, 0x07, 0x00, 0xb8, 0xf3, 0x9f, 0x3c, 0x7f, 0x04, 0x00, 0x00,
 }
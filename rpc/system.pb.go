@@ -19,11 +19,14 @@ var _ = fmt.Errorf
 var _ = math.Inf
 
 type Status struct {
-	Brand          string `protobuf:"bytes,1,opt,name=Brand,json=brand" json:"Brand,omitempty"`
-	Version        string `protobuf:"bytes,2,opt,name=Version,json=version" json:"Version,omitempty"`
-	VersionFull    string `protobuf:"bytes,3,opt,name=VersionFull,json=versionFull" json:"VersionFull,omitempty"`
-	CommitHash     string `protobuf:"bytes,4,opt,name=CommitHash,json=commitHash" json:"CommitHash,omitempty"`
-	BuildTimestamp int64  `protobuf:"varint,5,opt,name=BuildTimestamp,json=buildTimestamp" json:"BuildTimestamp,omitempty"`
+	Brand            string             `protobuf:"bytes,1,opt,name=Brand,json=brand" json:"Brand,omitempty"`
+	Version          string             `protobuf:"bytes,2,opt,name=Version,json=version" json:"Version,omitempty"`
+	VersionFull      string             `protobuf:"bytes,3,opt,name=VersionFull,json=versionFull" json:"VersionFull,omitempty"`
+	CommitHash       string             `protobuf:"bytes,4,opt,name=CommitHash,json=commitHash" json:"CommitHash,omitempty"`
+	BuildTimestamp   int64              `protobuf:"varint,5,opt,name=BuildTimestamp,json=buildTimestamp" json:"BuildTimestamp,omitempty"`
+	Storage          *StorageStatistics `protobuf:"bytes,6,opt,name=Storage,json=storage" json:"Storage,omitempty"`
+	StorageAvailable bool               `protobuf:"varint,7,opt,name=StorageAvailable,json=storageAvailable" json:"StorageAvailable,omitempty"`
+	StorageError     string             `protobuf:"bytes,8,opt,name=StorageError,json=storageError" json:"StorageError,omitempty"`
 }
 
 func (m *Status) Reset()                    { *m = Status{} }
@@ -66,6 +69,27 @@ func (m *Status) GetBuildTimestamp() int64 {
 	return 0
 }
 
+func (m *Status) GetStorage() *StorageStatistics {
+	if m != nil {
+		return m.Storage
+	}
+	return nil
+}
+
+func (m *Status) GetStorageAvailable() bool {
+	if m != nil {
+		return m.StorageAvailable
+	}
+	return false
+}
+
+func (m *Status) GetStorageError() string {
+	if m != nil {
+		return m.StorageError
+	}
+	return ""
+}
+
 type PluginList struct {
 	Plugins []*Plugin `protobuf:"bytes,1,rep,name=Plugins,json=plugins" json:"Plugins,omitempty"`
 }
@@ -83,10 +107,16 @@ func (m *PluginList) GetPlugins() []*Plugin {
 }
 
 type Plugin struct {
-	Name    string   `protobuf:"bytes,1,opt,name=Name,json=name" json:"Name,omitempty"`
-	Version string   `protobuf:"bytes,2,opt,name=Version,json=version" json:"Version,omitempty"`
-	Authors []string `protobuf:"bytes,3,rep,name=Authors,json=authors" json:"Authors,omitempty"`
-	Website string   `protobuf:"bytes,4,opt,name=Website,json=website" json:"Website,omitempty"`
+	Name       string   `protobuf:"bytes,1,opt,name=Name,json=name" json:"Name,omitempty"`
+	Version    string   `protobuf:"bytes,2,opt,name=Version,json=version" json:"Version,omitempty"`
+	Authors    []string `protobuf:"bytes,3,rep,name=Authors,json=authors" json:"Authors,omitempty"`
+	Website    string   `protobuf:"bytes,4,opt,name=Website,json=website" json:"Website,omitempty"`
+	Id         string   `protobuf:"bytes,5,opt,name=Id,json=id" json:"Id,omitempty"`
+	Path       string   `protobuf:"bytes,6,opt,name=Path,json=path" json:"Path,omitempty"`
+	ApiVersion int32    `protobuf:"varint,7,opt,name=ApiVersion,json=apiVersion" json:"ApiVersion,omitempty"`
+	Loaded     bool     `protobuf:"varint,8,opt,name=Loaded,json=loaded" json:"Loaded,omitempty"`
+	Error      string   `protobuf:"bytes,9,opt,name=Error,json=error" json:"Error,omitempty"`
+	Components []string `protobuf:"bytes,10,rep,name=Components,json=components" json:"Components,omitempty"`
 }
 
 func (m *Plugin) Reset()                    { *m = Plugin{} }
@@ -122,10 +152,151 @@ func (m *Plugin) GetWebsite() string {
 	return ""
 }
 
+func (m *Plugin) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Plugin) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *Plugin) GetApiVersion() int32 {
+	if m != nil {
+		return m.ApiVersion
+	}
+	return 0
+}
+
+func (m *Plugin) GetLoaded() bool {
+	if m != nil {
+		return m.Loaded
+	}
+	return false
+}
+
+func (m *Plugin) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *Plugin) GetComponents() []string {
+	if m != nil {
+		return m.Components
+	}
+	return nil
+}
+
+type StorageStatistics struct {
+	Entries     int64  `protobuf:"varint,1,opt,name=Entries,json=entries" json:"Entries,omitempty"`
+	Bytes       int64  `protobuf:"varint,2,opt,name=Bytes,json=bytes" json:"Bytes,omitempty"`
+	Hits        uint64 `protobuf:"varint,3,opt,name=Hits,json=hits" json:"Hits,omitempty"`
+	Misses      uint64 `protobuf:"varint,4,opt,name=Misses,json=misses" json:"Misses,omitempty"`
+	Evictions   uint64 `protobuf:"varint,5,opt,name=Evictions,json=evictions" json:"Evictions,omitempty"`
+	Expirations uint64 `protobuf:"varint,6,opt,name=Expirations,json=expirations" json:"Expirations,omitempty"`
+}
+
+func (m *StorageStatistics) Reset()                    { *m = StorageStatistics{} }
+func (m *StorageStatistics) String() string            { return proto.CompactTextString(m) }
+func (*StorageStatistics) ProtoMessage()               {}
+func (*StorageStatistics) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{3} }
+
+func (m *StorageStatistics) GetEntries() int64 {
+	if m != nil {
+		return m.Entries
+	}
+	return 0
+}
+
+func (m *StorageStatistics) GetBytes() int64 {
+	if m != nil {
+		return m.Bytes
+	}
+	return 0
+}
+
+func (m *StorageStatistics) GetHits() uint64 {
+	if m != nil {
+		return m.Hits
+	}
+	return 0
+}
+
+func (m *StorageStatistics) GetMisses() uint64 {
+	if m != nil {
+		return m.Misses
+	}
+	return 0
+}
+
+func (m *StorageStatistics) GetEvictions() uint64 {
+	if m != nil {
+		return m.Evictions
+	}
+	return 0
+}
+
+func (m *StorageStatistics) GetExpirations() uint64 {
+	if m != nil {
+		return m.Expirations
+	}
+	return 0
+}
+
+type LogLevelList struct {
+	Levels []*LogLevel `protobuf:"bytes,1,rep,name=Levels,json=levels" json:"Levels,omitempty"`
+}
+
+func (m *LogLevelList) Reset()                    { *m = LogLevelList{} }
+func (m *LogLevelList) String() string            { return proto.CompactTextString(m) }
+func (*LogLevelList) ProtoMessage()               {}
+func (*LogLevelList) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{4} }
+
+func (m *LogLevelList) GetLevels() []*LogLevel {
+	if m != nil {
+		return m.Levels
+	}
+	return nil
+}
+
+type LogLevel struct {
+	Module string `protobuf:"bytes,1,opt,name=Module,json=module" json:"Module,omitempty"`
+	Level  string `protobuf:"bytes,2,opt,name=Level,json=level" json:"Level,omitempty"`
+}
+
+func (m *LogLevel) Reset()                    { *m = LogLevel{} }
+func (m *LogLevel) String() string            { return proto.CompactTextString(m) }
+func (*LogLevel) ProtoMessage()               {}
+func (*LogLevel) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{5} }
+
+func (m *LogLevel) GetModule() string {
+	if m != nil {
+		return m.Module
+	}
+	return ""
+}
+
+func (m *LogLevel) GetLevel() string {
+	if m != nil {
+		return m.Level
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*Status)(nil), "rpc.Status")
 	proto.RegisterType((*PluginList)(nil), "rpc.PluginList")
 	proto.RegisterType((*Plugin)(nil), "rpc.Plugin")
+	proto.RegisterType((*StorageStatistics)(nil), "rpc.StorageStatistics")
+	proto.RegisterType((*LogLevelList)(nil), "rpc.LogLevelList")
+	proto.RegisterType((*LogLevel)(nil), "rpc.LogLevel")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -141,6 +312,9 @@ const _ = grpc.SupportPackageIsVersion4
 type SystemServiceClient interface {
 	GetStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*Status, error)
 	GetPlugins(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*PluginList, error)
+	GetLogLevels(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*LogLevelList, error)
+	SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevelList, error)
+	ReloadConfig(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ConfigChangeSet, error)
 }
 
 type systemServiceClient struct {
@@ -169,11 +343,41 @@ func (c *systemServiceClient) GetPlugins(ctx context.Context, in *google_protobu
 	return out, nil
 }
 
+func (c *systemServiceClient) GetLogLevels(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*LogLevelList, error) {
+	out := new(LogLevelList)
+	err := grpc.Invoke(ctx, "/rpc.SystemService/GetLogLevels", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *systemServiceClient) SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevelList, error) {
+	out := new(LogLevelList)
+	err := grpc.Invoke(ctx, "/rpc.SystemService/SetLogLevel", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *systemServiceClient) ReloadConfig(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ConfigChangeSet, error) {
+	out := new(ConfigChangeSet)
+	err := grpc.Invoke(ctx, "/rpc.SystemService/ReloadConfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for SystemService service
 
 type SystemServiceServer interface {
 	GetStatus(context.Context, *google_protobuf1.Empty) (*Status, error)
 	GetPlugins(context.Context, *google_protobuf1.Empty) (*PluginList, error)
+	GetLogLevels(context.Context, *google_protobuf1.Empty) (*LogLevelList, error)
+	SetLogLevel(context.Context, *LogLevel) (*LogLevelList, error)
+	ReloadConfig(context.Context, *google_protobuf1.Empty) (*ConfigChangeSet, error)
 }
 
 func RegisterSystemServiceServer(s *grpc.Server, srv SystemServiceServer) {
@@ -216,6 +420,60 @@ func _SystemService_GetPlugins_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SystemService_GetLogLevels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SystemServiceServer).GetLogLevels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SystemService/GetLogLevels",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SystemServiceServer).GetLogLevels(ctx, req.(*google_protobuf1.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SystemService_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogLevel)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SystemServiceServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SystemService/SetLogLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SystemServiceServer).SetLogLevel(ctx, req.(*LogLevel))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SystemService_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SystemServiceServer).ReloadConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SystemService/ReloadConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SystemServiceServer).ReloadConfig(ctx, req.(*google_protobuf1.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _SystemService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "rpc.SystemService",
 	HandlerType: (*SystemServiceServer)(nil),
@@ -228,6 +486,18 @@ var _SystemService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetPlugins",
 			Handler:    _SystemService_GetPlugins_Handler,
 		},
+		{
+			MethodName: "GetLogLevels",
+			Handler:    _SystemService_GetLogLevels_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _SystemService_SetLogLevel_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _SystemService_ReloadConfig_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "system.proto",
@@ -236,27 +506,48 @@ var _SystemService_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("system.proto", fileDescriptor4) }
 
 var fileDescriptor4 = []byte{
-	// 345 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x91, 0x5f, 0x4b, 0xf3, 0x30,
-	0x18, 0xc5, 0xe9, 0xdb, 0x6d, 0x65, 0x4f, 0x5f, 0x27, 0x04, 0x91, 0x30, 0x41, 0x4a, 0x41, 0xd9,
-	0x55, 0x26, 0x1b, 0x7e, 0x00, 0x27, 0x3a, 0x2f, 0x44, 0x64, 0x13, 0xbd, 0x4e, 0xbb, 0xd8, 0x45,
-	0x9b, 0xa6, 0x24, 0x4f, 0xa7, 0xfb, 0x3a, 0x7e, 0x52, 0x69, 0x53, 0xff, 0x5d, 0xe8, 0xe5, 0xf9,
-	0x9d, 0xd3, 0x92, 0xf3, 0x1c, 0xf8, 0x6f, 0xb7, 0x16, 0x85, 0x62, 0xa5, 0xd1, 0xa8, 0x89, 0x6f,
-	0xca, 0x74, 0x78, 0x90, 0x69, 0x9d, 0xe5, 0x62, 0xdc, 0xa0, 0xa4, 0x7a, 0x1c, 0x0b, 0x55, 0xe2,
-	0xd6, 0x25, 0xe2, 0x37, 0x0f, 0x7a, 0x4b, 0xe4, 0x58, 0x59, 0xb2, 0x07, 0xdd, 0x99, 0xe1, 0xc5,
-	0x8a, 0x7a, 0x91, 0x37, 0xea, 0x2f, 0xba, 0x49, 0x2d, 0x08, 0x85, 0xe0, 0x5e, 0x18, 0x2b, 0x75,
-	0x41, 0xff, 0x35, 0x3c, 0xd8, 0x38, 0x49, 0x22, 0x08, 0x5b, 0xe7, 0xb2, 0xca, 0x73, 0xea, 0x37,
-	0x6e, 0xb8, 0xf9, 0x42, 0xe4, 0x10, 0xe0, 0x5c, 0x2b, 0x25, 0xf1, 0x8a, 0xdb, 0x35, 0xed, 0x34,
-	0x01, 0x48, 0x3f, 0x09, 0x39, 0x86, 0xc1, 0xac, 0x92, 0xf9, 0xea, 0x4e, 0x2a, 0x61, 0x91, 0xab,
-	0x92, 0x76, 0x23, 0x6f, 0xe4, 0x2f, 0x06, 0xc9, 0x0f, 0x1a, 0x4f, 0x01, 0x6e, 0xf3, 0x2a, 0x93,
-	0xc5, 0xb5, 0xb4, 0x48, 0x8e, 0x20, 0x70, 0xca, 0x52, 0x2f, 0xf2, 0x47, 0xe1, 0x24, 0x64, 0xa6,
-	0x4c, 0x99, 0x63, 0x8b, 0xa0, 0x74, 0x5e, 0xfc, 0x04, 0x3d, 0x87, 0x08, 0x81, 0xce, 0x0d, 0x57,
-	0xa2, 0xed, 0xd5, 0x29, 0xb8, 0x12, 0x7f, 0xd4, 0xa2, 0x10, 0x9c, 0x55, 0xb8, 0xd6, 0xc6, 0x52,
-	0x3f, 0xf2, 0x6b, 0x87, 0x3b, 0x59, 0x3b, 0x0f, 0x22, 0xb1, 0x12, 0x45, 0xdb, 0x25, 0x78, 0x71,
-	0x72, 0xf2, 0x0a, 0x3b, 0xcb, 0xe6, 0xee, 0x4b, 0x61, 0x36, 0x32, 0x15, 0xe4, 0x04, 0xfa, 0x73,
-	0x81, 0xed, 0x61, 0xf7, 0x99, 0x5b, 0x80, 0x7d, 0x2c, 0xc0, 0x2e, 0xea, 0x05, 0x86, 0xee, 0xdd,
-	0x6d, 0xe8, 0x14, 0x60, 0x2e, 0xb0, 0x2d, 0xf6, 0xeb, 0x27, 0xbb, 0xdf, 0xaa, 0xd6, 0xc7, 0x98,
-	0xc5, 0x10, 0x49, 0xcd, 0x32, 0x89, 0xeb, 0x2a, 0x61, 0x2b, 0x8d, 0x16, 0xb9, 0x41, 0x66, 0x51,
-	0xa7, 0xcf, 0xa5, 0xcc, 0x45, 0x1d, 0x4f, 0x7a, 0xcd, 0x4f, 0xa6, 0xef, 0x01, 0x00, 0x00, 0xff,
-	0xff, 0x9d, 0x1a, 0x72, 0x8d, 0x1c, 0x02, 0x00, 0x00,
+	// 683 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x53, 0x4d, 0x6b, 0xe4, 0x46,
+	0x10, 0x45, 0x23, 0x8d, 0x34, 0x53, 0x1a, 0x3b, 0x76, 0x63, 0x8c, 0x70, 0x42, 0x10, 0x82, 0x84,
+	0x21, 0x07, 0xd9, 0xd8, 0x18, 0x12, 0xc8, 0xc5, 0x36, 0x13, 0x3b, 0x30, 0x09, 0xa6, 0x27, 0x24,
+	0xe7, 0x96, 0xd4, 0xd6, 0x34, 0x91, 0xd4, 0x42, 0xdd, 0x9a, 0xc4, 0x7f, 0x27, 0xe4, 0xbe, 0x7f,
+	0x70, 0x0f, 0x4b, 0x57, 0x4b, 0xfe, 0xd8, 0x5d, 0xef, 0xf1, 0xbd, 0xaa, 0x92, 0xaa, 0xdf, 0x7b,
+	0x05, 0x0b, 0xf5, 0xa8, 0x34, 0xaf, 0xd3, 0xb6, 0x93, 0x5a, 0x12, 0xb7, 0x6b, 0xf3, 0x93, 0xaf,
+	0x4b, 0x29, 0xcb, 0x8a, 0x9f, 0x22, 0x95, 0xf5, 0x0f, 0xa7, 0xbc, 0x6e, 0xf5, 0xa3, 0xed, 0x38,
+	0x59, 0xe4, 0xb2, 0xae, 0x65, 0x63, 0x51, 0xf2, 0xff, 0x04, 0xfc, 0x8d, 0x66, 0xba, 0x57, 0xe4,
+	0x08, 0xa6, 0xd7, 0x1d, 0x6b, 0x8a, 0xc8, 0x89, 0x9d, 0xe5, 0x9c, 0x4e, 0x33, 0x03, 0x48, 0x04,
+	0xc1, 0x9f, 0xbc, 0x53, 0x42, 0x36, 0xd1, 0x04, 0xf9, 0x60, 0x67, 0x21, 0x89, 0x21, 0x1c, 0x2a,
+	0xbf, 0xf4, 0x55, 0x15, 0xb9, 0x58, 0x0d, 0x77, 0xcf, 0x14, 0xf9, 0x16, 0xe0, 0x46, 0xd6, 0xb5,
+	0xd0, 0x77, 0x4c, 0x6d, 0x23, 0x0f, 0x1b, 0x20, 0x7f, 0x62, 0xc8, 0xf7, 0xb0, 0x7f, 0xdd, 0x8b,
+	0xaa, 0xf8, 0x43, 0xd4, 0x5c, 0x69, 0x56, 0xb7, 0xd1, 0x34, 0x76, 0x96, 0x2e, 0xdd, 0xcf, 0x5e,
+	0xb1, 0xe4, 0x0c, 0x82, 0x8d, 0x96, 0x1d, 0x2b, 0x79, 0xe4, 0xc7, 0xce, 0x32, 0x3c, 0x3f, 0x4e,
+	0xbb, 0x36, 0x4f, 0x07, 0xce, 0xac, 0x2f, 0x94, 0x16, 0xb9, 0xa2, 0x81, 0xb2, 0x14, 0xf9, 0x01,
+	0x0e, 0x86, 0xea, 0xd5, 0x8e, 0x89, 0x8a, 0x65, 0x15, 0x8f, 0x82, 0xd8, 0x59, 0xce, 0xe8, 0x81,
+	0xfa, 0x88, 0x27, 0x09, 0x2c, 0x86, 0xde, 0x55, 0xd7, 0xc9, 0x2e, 0x9a, 0xe1, 0x9e, 0x0b, 0xf5,
+	0x82, 0x4b, 0x2e, 0x00, 0xee, 0xab, 0xbe, 0x14, 0xcd, 0x5a, 0x28, 0x4d, 0xbe, 0x83, 0xc0, 0x22,
+	0x15, 0x39, 0xb1, 0xbb, 0x0c, 0xcf, 0x43, 0xdc, 0xc7, 0x72, 0x34, 0x68, 0x6d, 0x2d, 0x79, 0xef,
+	0x80, 0x6f, 0x39, 0x42, 0xc0, 0xfb, 0x9d, 0xd5, 0x7c, 0x90, 0xd6, 0x6b, 0x58, 0xcd, 0xbf, 0xa0,
+	0x6c, 0x04, 0xc1, 0x55, 0xaf, 0xb7, 0xb2, 0x53, 0x91, 0x1b, 0xbb, 0xa6, 0xc2, 0x2c, 0x34, 0x95,
+	0xbf, 0x78, 0xa6, 0x84, 0xe6, 0x83, 0x9c, 0xc1, 0x3f, 0x16, 0x92, 0x7d, 0x98, 0xfc, 0x5a, 0xa0,
+	0x7e, 0x73, 0x3a, 0x11, 0x85, 0xf9, 0xe3, 0x3d, 0xd3, 0x5b, 0x14, 0x6c, 0x4e, 0xbd, 0x96, 0xe9,
+	0xad, 0xf1, 0xe3, 0xaa, 0x15, 0xe3, 0x4f, 0x8d, 0x1e, 0x53, 0x0a, 0xec, 0x89, 0x21, 0xc7, 0xe0,
+	0xaf, 0x25, 0x2b, 0x78, 0x81, 0x1a, 0xcc, 0xa8, 0x5f, 0x21, 0x32, 0xc9, 0xb0, 0xd2, 0xcc, 0x6d,
+	0x32, 0xb8, 0x01, 0x83, 0xbb, 0xad, 0x6c, 0x78, 0xa3, 0x55, 0x04, 0xb1, 0x3b, 0xb8, 0x3b, 0x30,
+	0xc9, 0x3b, 0x07, 0x0e, 0x3f, 0xb1, 0xc8, 0xbc, 0x60, 0xd5, 0xe8, 0x4e, 0x70, 0x85, 0x62, 0xb8,
+	0x34, 0xe0, 0x16, 0x62, 0xfe, 0x1e, 0x35, 0x57, 0xa8, 0x86, 0x4b, 0xa7, 0x99, 0x01, 0xe6, 0x1d,
+	0x77, 0x42, 0x2b, 0x8c, 0x97, 0x47, 0xbd, 0xad, 0xd0, 0xca, 0xec, 0xf9, 0x9b, 0x50, 0x8a, 0x2b,
+	0x14, 0xc1, 0xa3, 0x7e, 0x8d, 0x88, 0x7c, 0x03, 0xf3, 0xd5, 0x4e, 0xe4, 0x5a, 0xc8, 0x46, 0xa1,
+	0x14, 0x1e, 0x9d, 0xf3, 0x91, 0x30, 0x79, 0x5d, 0xfd, 0xdb, 0x8a, 0x8e, 0xd9, 0xba, 0x8f, 0xf5,
+	0x90, 0x3f, 0x53, 0xc9, 0x25, 0x2c, 0xd6, 0xb2, 0x5c, 0xf3, 0x1d, 0xaf, 0x06, 0x9f, 0x7d, 0x04,
+	0xa3, 0xcd, 0x7b, 0x68, 0xf3, 0xd8, 0x42, 0xfd, 0x0a, 0x8b, 0xc9, 0x8f, 0x30, 0x1b, 0x39, 0x5c,
+	0x4d, 0x16, 0x7d, 0x35, 0x5a, 0xed, 0xd7, 0x88, 0xcc, 0xe3, 0xb0, 0x61, 0xb0, 0x7a, 0x8a, 0xa3,
+	0xe7, 0xff, 0x4d, 0x60, 0x6f, 0x83, 0xe7, 0xbb, 0xe1, 0xdd, 0x4e, 0xe4, 0x9c, 0x9c, 0xc1, 0xfc,
+	0x96, 0xeb, 0xe1, 0x22, 0x8f, 0x53, 0x7b, 0xc8, 0xe9, 0x78, 0xc8, 0xe9, 0xca, 0x1c, 0xf2, 0x49,
+	0x38, 0xc4, 0x1f, 0x9b, 0x2e, 0x01, 0x6e, 0xb9, 0x1e, 0xf2, 0xf8, 0xe6, 0xc8, 0x57, 0x2f, 0x12,
+	0x8a, 0x6f, 0xfb, 0x09, 0x16, 0xb7, 0x5c, 0x8f, 0x7b, 0xbf, 0x3d, 0x78, 0xf8, 0xea, 0xcd, 0x38,
+	0x7a, 0x0a, 0xe1, 0xe6, 0x79, 0x94, 0xbc, 0x56, 0xe5, 0x73, 0x03, 0x3f, 0xc3, 0x82, 0x72, 0x93,
+	0xa5, 0x1b, 0xd9, 0x3c, 0x88, 0xf2, 0xcd, 0x7f, 0x1d, 0xe1, 0xa8, 0x6d, 0xba, 0xd9, 0xb2, 0xa6,
+	0xe4, 0x1b, 0xae, 0xaf, 0x13, 0x88, 0x85, 0x4c, 0x4b, 0xa1, 0xb7, 0x7d, 0x96, 0x16, 0x52, 0x2b,
+	0xcd, 0x3a, 0x9d, 0x2a, 0x2d, 0xf3, 0xbf, 0x5b, 0x51, 0x71, 0x33, 0x93, 0xf9, 0xf8, 0xa5, 0x8b,
+	0x0f, 0x03, 0x00, 0x5a, 0xba, 0x7e, 0xd7, 0x0d, 0x05, 0x00, 0x00,
 }
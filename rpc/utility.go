@@ -19,6 +19,7 @@ package rpc
 import (
   "errors"
   "fmt"
+  "sort"
   "time"
 
   "github.com/dotStart/Stockpile/entity"
@@ -149,6 +150,69 @@ func NameHistoryFromRpc(history *NameHistory) *entity.NameChangeHistory {
   }
 }
 
+// converts a name ownership period into its rpc representation
+func NameOwnershipToRpc(ownership *entity.NameOwnership) *NameOwnership {
+  var until int64
+  if !ownership.IsOpen() {
+    until = ownership.Until.Unix()
+  }
+
+  return &NameOwnership{
+    Id:        ownership.Id.String(),
+    Name:      ownership.Name,
+    From:      ownership.From.Unix(),
+    Until:     until,
+    UpdatedAt: ownership.UpdatedAt.Unix(),
+  }
+}
+
+// converts a name ownership period from its rpc representation
+func NameOwnershipFromRpc(rpc *NameOwnership) (*entity.NameOwnership, error) {
+  id, err := uuid.Parse(rpc.Id)
+  if err != nil {
+    return nil, err
+  }
+
+  var until time.Time
+  if rpc.Until != 0 {
+    until = time.Unix(rpc.Until, 0)
+  }
+
+  return &entity.NameOwnership{
+    Id:        id,
+    Name:      rpc.Name,
+    From:      time.Unix(rpc.From, 0),
+    Until:     until,
+    UpdatedAt: time.Unix(rpc.UpdatedAt, 0),
+  }, nil
+}
+
+// converts a name timeline into its rpc representation
+func NameTimelineToRpc(timeline *entity.NameTimeline) *NameTimeline {
+  periods := make([]*NameOwnership, len(timeline.Periods))
+  for i, period := range timeline.Periods {
+    periods[i] = NameOwnershipToRpc(period)
+  }
+
+  return &NameTimeline{
+    Name:    timeline.Name,
+    Periods: periods,
+  }
+}
+
+// converts a name timeline from its rpc representation
+func NameTimelineFromRpc(rpc *NameTimeline) (*entity.NameTimeline, error) {
+  timeline := entity.NewNameTimeline(rpc.Name)
+  for _, encoded := range rpc.Periods {
+    period, err := NameOwnershipFromRpc(encoded)
+    if err != nil {
+      return nil, err
+    }
+    timeline.Periods = append(timeline.Periods, period)
+  }
+  return timeline, nil
+}
+
 // converts the result of a bulk id resolve operation into its rpc representation
 func BulkIdsToRpc(ids []*entity.ProfileId) *BulkIdResponse {
   if ids == nil || len(ids) == 0 {
@@ -340,6 +404,8 @@ func EventTypeToRpc(typ entity.EventType) EventType {
     return EventType_PROFILE
   case entity.BlacklistEvent:
     return EventType_BLACKLIST
+  case entity.ConfigEvent:
+    return EventType_CONFIG
   default:
     return -1 // TODO: Unknown?
   }
@@ -356,6 +422,8 @@ func EventTypeFromRpc(typ EventType) (entity.EventType, error) {
     return entity.ProfileEvent, nil
   case EventType_BLACKLIST:
     return entity.BlacklistEvent, nil
+  case EventType_CONFIG:
+    return entity.ConfigEvent, nil
   default:
     return -1, fmt.Errorf("illegal event type: %d", typ)
   }
@@ -441,6 +509,11 @@ func EventPayloadToRpc(payload interface{}) (proto.Message, error) {
     return BlacklistToRpc(blacklist), nil
   }
 
+  changes, ok := payload.(*entity.ConfigChangeSet)
+  if ok {
+    return ConfigChangeSetToRpc(changes), nil
+  }
+
   return nil, fmt.Errorf("illegal payload value: %v", payload)
 }
 
@@ -476,53 +549,155 @@ func EventPayloadFromRpc(payload *any.Any) (interface{}, error) {
     return BlacklistFromRpc(blacklist)
   }
 
+  changes, ok := obj.Message.(*ConfigChangeSet)
+  if ok {
+    return ConfigChangeSetFromRpc(changes), nil
+  }
+
   return nil, fmt.Errorf("illegal payload value: %v", payload)
 }
 
 func StatusFromRpc(rpc *Status) *entity.Status {
   return &entity.Status{
-    Brand:          rpc.Brand,
-    Version:        rpc.Version,
-    VersionFull:    rpc.VersionFull,
-    CommitHash:     rpc.CommitHash,
-    BuildTimestamp: time.Unix(rpc.BuildTimestamp, 0),
+    Brand:            rpc.Brand,
+    Version:          rpc.Version,
+    VersionFull:      rpc.VersionFull,
+    CommitHash:       rpc.CommitHash,
+    BuildTimestamp:   time.Unix(rpc.BuildTimestamp, 0),
+    Storage:          StorageStatisticsFromRpc(rpc.Storage),
+    StorageAvailable: rpc.StorageAvailable,
+    StorageError:     rpc.StorageError,
   }
 }
 
-func PluginMetadataListToRpc(list []*plugin.Metadata) *PluginList {
+func StorageStatisticsToRpc(stats *entity.StorageStatistics) *StorageStatistics {
+  if stats == nil {
+    return nil
+  }
+
+  return &StorageStatistics{
+    Entries:     stats.Entries,
+    Bytes:       stats.Bytes,
+    Hits:        stats.Hits,
+    Misses:      stats.Misses,
+    Evictions:   stats.Evictions,
+    Expirations: stats.Expirations,
+  }
+}
+
+func StorageStatisticsFromRpc(rpc *StorageStatistics) *entity.StorageStatistics {
+  if rpc == nil {
+    return nil
+  }
+
+  return &entity.StorageStatistics{
+    Entries:     rpc.Entries,
+    Bytes:       rpc.Bytes,
+    Hits:        rpc.Hits,
+    Misses:      rpc.Misses,
+    Evictions:   rpc.Evictions,
+    Expirations: rpc.Expirations,
+  }
+}
+
+func PluginStatusListToRpc(list []*plugin.Status) *PluginList {
   enc := make([]*Plugin, len(list))
-  for i, metadata := range list {
-    enc[i] = PluginMetadataToRpc(metadata)
+  for i, status := range list {
+    enc[i] = PluginStatusToRpc(status)
   }
   return &PluginList{
     Plugins: enc,
   }
 }
 
-func PluginMetadataListFromRpc(list *PluginList) []*plugin.Metadata {
-  decoded := make([]*plugin.Metadata, len(list.Plugins))
-  for i, metadata := range list.Plugins {
-    decoded[i] = PluginMetadataFromRpc(metadata)
+func PluginStatusListFromRpc(list *PluginList) []*plugin.Status {
+  decoded := make([]*plugin.Status, len(list.Plugins))
+  for i, status := range list.Plugins {
+    decoded[i] = PluginStatusFromRpc(status)
   }
   return decoded
 }
 
-func PluginMetadataToRpc(metadata *plugin.Metadata) *Plugin {
+func PluginStatusToRpc(status *plugin.Status) *Plugin {
   return &Plugin{
-    Name:    metadata.Name,
-    Version: metadata.Version,
-    Authors: metadata.Authors,
-    Website: metadata.Website,
+    Name:       status.Name,
+    Version:    status.Version,
+    Authors:    status.Authors,
+    Website:    status.Website,
+    Id:         status.Id,
+    Path:       status.Path,
+    ApiVersion: int32(status.ApiVersion),
+    Loaded:     status.Loaded,
+    Error:      status.Error,
+    Components: status.Components,
+  }
+}
+
+func PluginStatusFromRpc(status *Plugin) *plugin.Status {
+  return &plugin.Status{
+    Name:       status.Name,
+    Version:    status.Version,
+    Authors:    status.Authors,
+    Website:    status.Website,
+    Id:         status.Id,
+    Path:       status.Path,
+    ApiVersion: int(status.ApiVersion),
+    Loaded:     status.Loaded,
+    Error:      status.Error,
+    Components: status.Components,
+  }
+}
+
+func ConfigChangeSetToRpc(changes *entity.ConfigChangeSet) *ConfigChangeSet {
+  enc := make([]*ConfigChange, len(changes.Changes))
+  for i, change := range changes.Changes {
+    enc[i] = &ConfigChange{
+      Setting: change.Setting,
+      Old:     change.Old,
+      New:     change.New,
+    }
+  }
+  return &ConfigChangeSet{
+    Changes: enc,
+  }
+}
+
+func ConfigChangeSetFromRpc(changes *ConfigChangeSet) *entity.ConfigChangeSet {
+  decoded := make([]*entity.ConfigChange, len(changes.Changes))
+  for i, change := range changes.Changes {
+    decoded[i] = &entity.ConfigChange{
+      Setting: change.Setting,
+      Old:     change.Old,
+      New:     change.New,
+    }
+  }
+  return &entity.ConfigChangeSet{
+    Changes: decoded,
   }
 }
 
-func PluginMetadataFromRpc(metadata *Plugin) *plugin.Metadata {
-  return &plugin.Metadata{
-    Name:    metadata.Name,
-    Version: metadata.Version,
-    Authors: metadata.Authors,
-    Website: metadata.Website,
+func LogLevelsToRpc(levels map[string]string) *LogLevelList {
+  enc := make([]*LogLevel, 0, len(levels))
+  for module, level := range levels {
+    enc = append(enc, &LogLevel{
+      Module: module,
+      Level:  level,
+    })
   }
+  sort.Slice(enc, func(i, j int) bool {
+    return enc[i].Module < enc[j].Module
+  })
+  return &LogLevelList{
+    Levels: enc,
+  }
+}
+
+func LogLevelsFromRpc(list *LogLevelList) map[string]string {
+  decoded := make(map[string]string)
+  for _, level := range list.Levels {
+    decoded[level.Module] = level.Level
+  }
+  return decoded
 }
 
 // evaluates whether the message has been populated with actual data (e.g. whether it is not empty)
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cache
+
+import (
+  "context"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/cluster"
+  "github.com/dotStart/Stockpile/stockpile/tracing"
+)
+
+// attaches the cache to a cluster in order to replicate events and purges between all of its
+// instances
+// this method is expected to be invoked before the cache is made available to any callers
+func (c *Cache) JoinCluster(bus cluster.EventBus) error {
+  c.bus = bus
+  err := bus.Subscribe(c.handleClusterMessage)
+  if err != nil {
+    c.bus = nil
+    return err
+  }
+  return nil
+}
+
+// publishes a message to all other instances within the cluster (if any)
+func (c *Cache) broadcast(msg *cluster.Message) {
+  if c.bus == nil {
+    return
+  }
+
+  err := c.bus.Publish(msg)
+  if err != nil {
+    c.logger.Errorf("failed to publish %s message to cluster: %s", msg.Type, err)
+  }
+}
+
+// publishes an event to all other instances within the cluster (if any)
+func (c *Cache) broadcastEvent(msgType cluster.MessageType, e *entity.Event) {
+  if c.bus == nil {
+    return
+  }
+
+  enc, err := e.Serialize()
+  if err != nil {
+    c.logger.Errorf("failed to encode event %v: %s", e, err)
+    return
+  }
+
+  c.broadcast(&cluster.Message{
+    Type:    msgType,
+    Payload: enc,
+  })
+}
+
+// notifies all local listeners as well as all other instances within the cluster about a
+// modification
+func (c *Cache) publishEvent(ctx context.Context, e *entity.Event) {
+  _, span := tracing.Start(ctx, "cache.PublishEvent")
+  defer span.End()
+
+  c.dispatch(e)
+  c.broadcastEvent(cluster.EventMessage, e)
+}
+
+// notifies all other instances within the cluster about the removal of an entry
+func (c *Cache) publishPurge(e *entity.Event) {
+  c.broadcastEvent(cluster.PurgeMessage, e)
+}
+
+// handles messages which have been published by other instances within the cluster
+func (c *Cache) handleClusterMessage(msg *cluster.Message) {
+  switch msg.Type {
+  case cluster.EventMessage, cluster.PurgeMessage:
+    e := &entity.Event{}
+    err := e.Deserialize(msg.Payload)
+    if err != nil {
+      c.logger.Errorf("received malformed %s message from cluster: %s", msg.Type, err)
+      return
+    }
+
+    c.invalidate(e)
+    if msg.Type == cluster.EventMessage {
+      c.dispatch(e)
+    }
+  default:
+    c.logger.Warningf("received unknown message of type \"%s\" from cluster", msg.Type)
+  }
+}
+
+// discards all local copies (including stale copies) of the entry referenced by a given event
+func (c *Cache) invalidate(e *entity.Event) {
+  backend := c.storage
+  if e.Key == nil && e.Type != entity.BlacklistEvent {
+    return
+  }
+
+  switch e.Type {
+  case entity.ProfileIdEvent:
+    key, err := e.ProfileIdKey()
+    if err == nil {
+      backend.InvalidateProfileId(key.Name)
+      c.stale.Remove(staleKey("profile_id", key.Name))
+    }
+  case entity.NameHistoryEvent:
+    id, err := e.IdKey()
+    if err == nil {
+      backend.InvalidateNameHistory(*id)
+      c.stale.Remove(staleKey("name_history", id.String()))
+    }
+  case entity.ProfileEvent:
+    id, err := e.IdKey()
+    if err == nil {
+      backend.InvalidateProfile(*id)
+      c.stale.Remove(staleKey("profile", id.String()))
+    }
+  case entity.BlacklistEvent:
+    backend.InvalidateBlacklist()
+    c.stale.Remove(staleKey("blacklist", ""))
+  }
+}
@@ -16,14 +16,21 @@
  */
 package cache
 
-import "github.com/dotStart/Stockpile/entity"
+import (
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/metrics"
+)
+
+// defines the amount of events which are buffered for each listener before further events are
+// dropped
+const listenerBufferSize = 64
 
 type Listener struct {
   cache *Cache
   C     chan *entity.Event
 }
 
-// frees all resources associated with this listener
+// frees all resources associated with this listener and closes its channel
 func (e *Listener) Close() {
   e.cache.removeListener(e)
 }
@@ -35,9 +42,10 @@ func (c *Cache) NewListener() *Listener {
 
   listener := &Listener{
     cache: c,
-    C:     make(chan *entity.Event),
+    C:     make(chan *entity.Event, listenerBufferSize),
   }
   c.listeners = append(c.listeners, listener)
+  metrics.EventListeners.Inc()
   return listener
 }
 
@@ -49,16 +57,64 @@ func (c *Cache) removeListener(listener *Listener) {
   for i, l := range c.listeners {
     if l == listener {
       c.listeners = append(c.listeners[:i], c.listeners[i+1:]...)
+      close(l.C)
+      metrics.EventListeners.Dec()
       break
     }
   }
 }
 
+// removes all listeners and closes their channels in order to notify their consumers about the
+// shutdown of the cache
+func (c *Cache) closeListeners() {
+  c.listenerMutex.Lock()
+  defer c.listenerMutex.Unlock()
+
+  for _, l := range c.listeners {
+    close(l.C)
+    metrics.EventListeners.Dec()
+  }
+  c.listeners = nil
+}
+
+// notifies all local listeners about a change to the server configuration
+// configuration changes are specific to this instance and are thus not published to the cluster
+func (c *Cache) PublishConfigChange(changes []*entity.ConfigChange) {
+  c.dispatch(&entity.Event{
+    Type: entity.ConfigEvent,
+    Object: &entity.ConfigChangeSet{
+      Changes: changes,
+    },
+  })
+}
+
+// passes an event to the delivery routine unless the cache has already been closed
+func (c *Cache) dispatch(e *entity.Event) {
+  c.eventMutex.RLock()
+  defer c.eventMutex.RUnlock()
+
+  if c.closed {
+    return
+  }
+  c.events <- e
+}
+
 // distributes cache events to all registered listeners
+// listeners which do not keep up with the event stream will miss events rather than delay the
+// delivery to all other listeners
 func (c *Cache) deliverEvents() {
+  defer close(c.delivered)
+
   for e := range c.events {
+    c.listenerMutex.Lock()
     for _, listener := range c.listeners {
-      listener.C <- e
+      select {
+      case listener.C <- e:
+      default:
+        c.logger.Warningf("dropped event of type %d as its listener did not keep up", e.Type)
+        metrics.DroppedEvents.Inc()
+      }
     }
+    c.listenerMutex.Unlock()
   }
 }
@@ -17,66 +17,223 @@
 package cache
 
 import (
+  "context"
+  "errors"
   "sync"
-  "sync/atomic"
-  "time"
 
   "github.com/dotStart/Stockpile/entity"
-  "github.com/dotStart/Stockpile/stockpile/mojang"
+  "github.com/dotStart/Stockpile/stockpile/cluster"
+  "github.com/dotStart/Stockpile/stockpile/logs"
+  "github.com/dotStart/Stockpile/stockpile/metrics"
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
   "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/dotStart/Stockpile/stockpile/tracing"
+  "github.com/dotStart/Stockpile/stockpile/upstream"
   "github.com/op/go-logging"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/trace"
 )
 
+// indicates that a refresh has been requested while the cache is shutting down
+var ErrShuttingDown = errors.New("cache is shutting down")
+
+// identifies the refresh which a context belongs to (if any)
+type refreshKey struct{}
+
 // provides an abstraction layer between callers, the caching system and the upstream API
 type Cache struct {
-  logger   *logging.Logger
-  upstream *mojang.MojangAPI
-  storage  storage.StorageBackend
+  logger    *logging.Logger
+  upstream  upstream.Upstream
+  storage   *storage.InstrumentedStorageBackend
+  stale     *staleEntries
+  rateLimit ratelimit.Store
+  bus       cluster.EventBus
+
+  rateLimitMutex    sync.RWMutex
+  rateLimitEnforced bool
 
-  resetTicker    *time.Ticker
-  requestCounter uint64
-  events         chan *entity.Event
+  refreshMutex sync.Mutex
+  refreshes    sync.WaitGroup
+  draining     bool
+
+  eventMutex sync.RWMutex
+  events     chan *entity.Event
+  delivered  chan struct{}
+  closed     bool
 
   listenerMutex *sync.Mutex
   listeners     []*Listener
+
+  timelineMutex sync.Mutex
 }
 
 // creates a new cache client using
-func New(upstream *mojang.MojangAPI, storage storage.StorageBackend) *Cache {
+func New(provider upstream.Upstream, backend storage.StorageBackend, rateLimit ratelimit.Store) *Cache {
   cache := &Cache{
     logger:        logging.MustGetLogger("cache"),
-    upstream:      upstream,
-    storage:       storage,
-    resetTicker:   time.NewTicker(time.Minute * 10),
+    upstream:      provider,
+    storage:       storage.NewInstrumentedStorageBackend(backend),
+    stale:         newStaleEntries(defaultStaleEntries),
+    rateLimit:     rateLimit,
     events:        make(chan *entity.Event),
+    delivered:     make(chan struct{}),
     listenerMutex: &sync.Mutex{},
     listeners:     make([]*Listener, 0),
   }
   go cache.deliverEvents()
-  go cache.resetRequestCounter()
   return cache
 }
 
-// increments the request counter by one
-func (c *Cache) incrementRequestCounter() {
-  c.logger.Debugf("Incremented request counter")
-  atomic.AddUint64(&c.requestCounter, 1)
+// records the result of a cache lookup within the metrics and the current span
+func observeLookup(span trace.Span, entityType string, result string) {
+  metrics.ObserveCacheRequest(entityType, result)
+  span.SetAttributes(
+    attribute.Bool("stockpile.cache.hit", result == metrics.CacheHit),
+    attribute.Bool("stockpile.cache.stale", result == metrics.CacheStale),
+  )
+}
+
+// selects whether upstream requests are rejected once the request budget has been exhausted
+// requests are merely counted by default as Mojang enforces its limit regardless
+func (c *Cache) SetRateLimitEnforced(enforced bool) {
+  c.rateLimitMutex.Lock()
+  defer c.rateLimitMutex.Unlock()
+  c.rateLimitEnforced = enforced
+}
+
+// evaluates whether upstream requests are rejected once the request budget has been exhausted
+func (c *Cache) isRateLimitEnforced() bool {
+  c.rateLimitMutex.RLock()
+  defer c.rateLimitMutex.RUnlock()
+  return c.rateLimitEnforced
+}
+
+// consumes a single request from the (potentially shared) upstream request budget
+// when the budget has been exhausted, the request is rejected if enforcement has been enabled and
+// passed on to upstream otherwise
+func (c *Cache) acquireUpstreamRequest(ctx context.Context) error {
+  _, span := tracing.Start(ctx, "ratelimit.Take")
+  ok, err := c.rateLimit.Take()
+  span.SetAttributes(attribute.Bool("stockpile.ratelimit.granted", ok))
+  tracing.End(span, err)
+
+  logger := logs.ForContext(ctx, c.logger)
+  if err != nil {
+    // mojang enforces its limit regardless so an unavailable store should not render the cache
+    // unusable
+    logger.Warningf("failed to consume upstream request from rate limit store: %s", err)
+    return nil
+  }
+  if !ok {
+    if c.isRateLimitEnforced() {
+      logger.Warningf("upstream request budget has been exhausted - rejecting request")
+      return ratelimit.ErrRateLimitExceeded
+    }
+    logger.Warningf("upstream request budget has been exhausted")
+    return nil
+  }
+
+  logger.Debugf("consumed upstream request from rate limit store")
+  return nil
+}
+
+// registers a refresh (e.g. an upstream request along with the resulting storage updates) which has
+// to complete before the cache is closed and returns a function which marks its completion
+// refreshes which are started as part of another refresh are tracked by their parent while new
+// refreshes are rejected once the cache has begun to drain
+func (c *Cache) beginRefresh(ctx context.Context) (context.Context, func(), error) {
+  if ctx.Value(refreshKey{}) != nil {
+    return ctx, func() {}, nil
+  }
+
+  c.refreshMutex.Lock()
+  defer c.refreshMutex.Unlock()
+  if c.draining {
+    return ctx, func() {}, ErrShuttingDown
+  }
+  c.refreshes.Add(1)
+  return context.WithValue(ctx, refreshKey{}, true), c.refreshes.Done, nil
 }
 
-// regularly clears the request counter
-func (c *Cache) resetRequestCounter() {
-  for range c.resetTicker.C {
-    atomic.StoreUint64(&c.requestCounter, 0)
+// rejects new refreshes and waits for all pending refreshes to complete
+// when the passed context expires first, its error is returned and the remaining refreshes are
+// left to complete (or fail) on their own
+func (c *Cache) Drain(ctx context.Context) error {
+  c.refreshMutex.Lock()
+  c.draining = true
+  c.refreshMutex.Unlock()
+
+  drained := make(chan struct{})
+  go func() {
+    c.refreshes.Wait()
+    close(drained)
+  }()
+
+  select {
+  case <-drained:
+    return nil
+  case <-ctx.Done():
+    c.logger.Warningf("pending refreshes did not complete in time")
+    return ctx.Err()
   }
 }
 
 // retrieves the amount of requests which have been submitted to the upstream servers within the
-// last minute
+// current rate limit period (by all instances which share the rate limit store)
 func (c *Cache) GetRateLimitAllocation() uint64 {
-  return atomic.LoadUint64(&c.requestCounter)
+  allocation, err := c.rateLimit.Allocation()
+  if err != nil {
+    c.logger.Errorf("failed to retrieve rate limit allocation: %s", err)
+    return 0
+  }
+  return allocation
+}
+
+// retrieves the maximum amount of requests which may be submitted to the upstream servers within a
+// single rate limit period
+func (c *Cache) GetRateLimitCapacity() uint64 {
+  return c.rateLimit.Capacity()
+}
+
+// retrieves the statistics collected by the storage backend (or nil if the backend does not
+// collect statistics)
+func (c *Cache) GetStorageStatistics() *entity.StorageStatistics {
+  return c.storage.GetStatistics()
 }
 
+// verifies whether the storage backend is currently able to serve requests
+func (c *Cache) PingStorage() error {
+  return c.storage.Ping()
+}
+
+// verifies whether the upstream provider is currently able to serve requests
+// providers which do not report their state are always considered available
+func (c *Cache) PingUpstream() error {
+  if pingable, ok := c.upstream.(upstream.PingableUpstream); ok {
+    return pingable.Ping()
+  }
+  return nil
+}
+
+// shuts down the cache
+// all requests are expected to have completed before the cache is closed (see Drain) as pending
+// events are delivered to the remaining listeners before their channels are closed
 func (c *Cache) Close() error {
-  c.resetTicker.Stop()
+  if c.bus != nil {
+    c.bus.Close()
+  }
+
+  c.eventMutex.Lock()
+  c.closed = true
+  close(c.events)
+  c.eventMutex.Unlock()
+  <-c.delivered
+  c.closeListeners()
+
+  err := upstream.Close(c.upstream)
+  if err != nil {
+    c.logger.Errorf("failed to close upstream provider: %s", err)
+  }
+  c.rateLimit.Close()
   return c.storage.Close()
 }
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cache_test
+
+import (
+  "context"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/mojang/mock"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/google/uuid"
+)
+
+// represents the outcome of a profile lookup which has been performed in the background
+type lookupResult struct {
+  profile *entity.Profile
+  err     error
+}
+
+// creates a cache whose upstream delays all responses by a given latency and begins a profile
+// lookup which remains pending until the upstream responds
+func newPendingLookup(t *testing.T, latency time.Duration) (*cache.Cache, uuid.UUID, <-chan *lookupResult, func()) {
+  id := uuid.New()
+  c, upstreamMock, closeFn := newMockCache(t, newProfileFixture(id), func(backend storage.StorageBackend) storage.StorageBackend {
+    return backend
+  }, nil)
+  upstreamMock.SetOptions(mock.Options{Latency: latency})
+
+  results := make(chan *lookupResult, 1)
+  go func() {
+    profile, err := c.GetProfile(context.Background(), id)
+    results <- &lookupResult{profile, err}
+  }()
+
+  deadline := time.Now().Add(5 * time.Second)
+  for upstreamMock.RequestCount("profile") == 0 {
+    if time.Now().After(deadline) {
+      t.Fatal("expected lookup to reach upstream")
+    }
+    time.Sleep(5 * time.Millisecond)
+  }
+  return c, id, results, closeFn
+}
+
+// pending refreshes complete before a drain returns while new refreshes are rejected
+func TestDrain(t *testing.T) {
+  c, id, results, closeFn := newPendingLookup(t, 100*time.Millisecond)
+  defer closeFn()
+
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  err := c.Drain(ctx)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  select {
+  case result := <-results:
+    if result.err != nil {
+      t.Fatal(result.err)
+    }
+    if result.profile == nil || result.profile.Id != id {
+      t.Fatalf("expected pending lookup to resolve profile %s but got %v", id, result.profile)
+    }
+  default:
+    t.Fatal("expected pending lookup to complete before the drain returns")
+  }
+
+  // stored entries remain available while refreshes are rejected
+  profile, err := c.GetProfile(context.Background(), id)
+  if err != nil || profile == nil {
+    t.Fatalf("expected stored profile to be served but got %v (%v)", profile, err)
+  }
+  _, err = c.GetProfile(context.Background(), uuid.New())
+  if err != cache.ErrShuttingDown {
+    t.Fatalf("expected new refresh to be rejected but got %v", err)
+  }
+}
+
+// drains give up once their context expires
+func TestDrainTimeout(t *testing.T) {
+  c, _, results, closeFn := newPendingLookup(t, 500*time.Millisecond)
+  defer closeFn()
+
+  ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+  defer cancel()
+  err := c.Drain(ctx)
+  if err != context.DeadlineExceeded {
+    t.Fatalf("expected drain to time out but got %v", err)
+  }
+
+  // the lookup is left to complete on its own before the cache is closed
+  <-results
+}
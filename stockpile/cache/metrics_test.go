@@ -0,0 +1,159 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cache_test
+
+import (
+  "context"
+  "net/http/httptest"
+  "testing"
+
+  "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/mojang"
+  "github.com/dotStart/Stockpile/stockpile/mojang/mock"
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/google/uuid"
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+// retrieves the sum of all samples of a given metric whose labels match the passed values from
+// the default registry (e.g. as they would be exposed via the metrics endpoint)
+func scrape(t *testing.T, name string, labels map[string]string) float64 {
+  families, err := prometheus.DefaultGatherer.Gather()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  var sum float64
+  for _, family := range families {
+    if family.GetName() != name {
+      continue
+    }
+
+  metrics:
+    for _, metric := range family.GetMetric() {
+      for _, pair := range metric.GetLabel() {
+        if value, ok := labels[pair.GetName()]; ok && value != pair.GetValue() {
+          continue metrics
+        }
+      }
+
+      if metric.GetCounter() != nil {
+        sum += metric.GetCounter().GetValue()
+      } else if metric.GetHistogram() != nil {
+        sum += float64(metric.GetHistogram().GetSampleCount())
+      }
+    }
+  }
+  return sum
+}
+
+// retrieves the amount of cache lookups for profiles with a given result
+func scrapeProfileLookups(t *testing.T, result string) float64 {
+  return scrape(t, "stockpile_cache_requests_total", map[string]string{"type": "profile", "result": result})
+}
+
+func TestMetrics(t *testing.T) {
+  id := uuid.New()
+  fixture := &mock.Fixture{
+    Profiles: []*mock.FixtureProfile{
+      {Id: id.String(), Names: []*mock.FixtureName{{Name: "Notch"}}},
+    },
+  }
+  err := fixture.Parse()
+  if err != nil {
+    t.Fatal(err)
+  }
+  upstreamMock := mock.New(fixture, mock.Options{})
+  upstreamSrv := httptest.NewServer(upstreamMock)
+  defer upstreamSrv.Close()
+
+  cfg := servertest.LoadConfig(t, "storage \"mem\" {}\n")
+  api := mojang.New()
+  err = api.SetServers(upstreamSrv.URL, upstreamSrv.URL)
+  if err != nil {
+    t.Fatal(err)
+  }
+  backend, err := storage.NewMemoryStorageBackend(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  rateLimit, err := ratelimit.NewLocalStore(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c := cache.New(api, backend, rateLimit)
+  defer c.Close()
+  ctx := context.Background()
+
+  hits := scrapeProfileLookups(t, "hit")
+  misses := scrapeProfileLookups(t, "miss")
+  stale := scrapeProfileLookups(t, "stale")
+  upstreamRequests := scrape(t, "stockpile_upstream_request_duration_seconds", map[string]string{"endpoint": "profile", "status": "200"})
+
+  // the first lookup misses and is passed on to upstream while the second one is served from storage
+  for i := 0; i < 2; i++ {
+    profile, err := c.GetProfile(ctx, id)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if profile == nil || profile.Id != id {
+      t.Fatalf("expected lookup %d to return profile %s but got %v", i, id, profile)
+    }
+  }
+
+  if value := scrapeProfileLookups(t, "miss") - misses; value != 1 {
+    t.Errorf("expected 1 cache miss but got %v", value)
+  }
+  if value := scrapeProfileLookups(t, "hit") - hits; value != 1 {
+    t.Errorf("expected 1 cache hit but got %v", value)
+  }
+  if value := scrape(t, "stockpile_upstream_request_duration_seconds", map[string]string{"endpoint": "profile", "status": "200"}) - upstreamRequests; value != 1 {
+    t.Errorf("expected 1 successful upstream request but got %v", value)
+  }
+
+  // expired entries are served when upstream is unavailable and reported as such
+  err = backend.PurgeProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  upstreamMock.SetOptions(mock.Options{ErrorRate: 1})
+  profile, err := c.GetProfile(ctx, id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile == nil || profile.Id != id {
+    t.Fatalf("expected stale profile %s but got %v", id, profile)
+  }
+  if value := scrapeProfileLookups(t, "stale") - stale; value != 1 {
+    t.Errorf("expected 1 stale serve but got %v", value)
+  }
+  if value := scrapeProfileLookups(t, "miss") - misses; value != 1 {
+    t.Errorf("expected stale serves not to be reported as misses but got %v misses", value)
+  }
+
+  // purged entries are never served
+  err = c.PurgeProfile(ctx, id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = c.GetProfile(ctx, id)
+  if err == nil {
+    t.Fatal("expected purged profile to remain unavailable while upstream fails")
+  }
+}
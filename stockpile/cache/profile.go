@@ -17,74 +17,139 @@
 package cache
 
 import (
+  "context"
   "fmt"
   "strings"
   "time"
 
   "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/logs"
+  "github.com/dotStart/Stockpile/stockpile/metrics"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/dotStart/Stockpile/stockpile/tracing"
   "github.com/google/uuid"
+  "go.opentelemetry.io/otel/attribute"
 )
 
 // retrieves the profile to which a given display name has been assigned at a specific time
-func (c *Cache) GetProfileId(name string, at time.Time) (*entity.ProfileId, error) {
-  c.logger.Debugf("processing query for profile Id associated with name \"%s\" at time %s", name, at)
+func (c *Cache) GetProfileId(ctx context.Context, name string, at time.Time) (id *entity.ProfileId, err error) {
+  ctx, span := tracing.Start(ctx, "cache.GetProfileId")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("processing query for profile Id associated with name \"%s\" at time %s", name, at)
 
-  id, err := c.storage.GetProfileId(name, at)
+  id, err = c.storage.WithContext(ctx).GetProfileId(name, at)
   if err != nil {
-    c.logger.Errorf("storage backend responded with error: %s", err)
+    logger.Errorf("storage backend responded with error: %s", err)
     id = nil
   }
+  if id != nil && !answersQuery(id, name) {
+    logger.Debugf("cached offline profile %s is known as \"%s\" rather than \"%s\" - ignoring cached data", id.Id, id.Name, name)
+    id = nil
+  }
+  result := metrics.LookupResult(id != nil)
+  defer func() { observeLookup(span, "profile_id", result) }()
+  if id == nil && isHistoricalQuery(at) {
+    timeline, err := c.storage.WithContext(ctx).GetNameTimeline(name)
+    if err == nil {
+      return c.resolveHistoricalProfileId(ctx, timeline, name, at)
+    }
+    if err != storage.ErrTimelineUnsupported {
+      logger.Errorf("storage backend responded with error: %s", err)
+    }
+  }
   if id == nil {
-    c.logger.Debugf("cache miss - requesting update from upstream")
+    logger.Debugf("cache miss - requesting update from upstream")
 
-    c.incrementRequestCounter()
-    id, err = c.upstream.GetId(name, at)
+    var done func()
+    ctx, done, err = c.beginRefresh(ctx)
+    defer done()
+    if err == nil {
+      err = c.acquireUpstreamRequest(ctx)
+    }
+    if err == nil {
+      id, err = c.upstream.GetId(ctx, name, at)
+      if err != nil {
+        err = fmt.Errorf("upstream responded with error: %s", err)
+      }
+    }
     if err != nil {
-      return nil, fmt.Errorf("upstream responded with error: %s", err)
+      // historical queries are never answered using stale data as only the most recent association
+      // of a name is retained
+      if !isHistoricalQuery(at) {
+        if stale, ok := c.getStale(ctx, "profile_id", name, err).(*entity.ProfileId); ok && answersQuery(stale, name) {
+          result = metrics.CacheStale
+          return stale.Copy(), nil
+        }
+      }
+      return nil, err
     }
 
+    if id != nil && !strings.EqualFold(id.Name, name) {
+      // the upstream reports the current name of the profile which owned the name at the given time
+      // thus the association is derived from the name history of the profile instead
+      logger.Debugf("upstream resolved \"%s\" to profile %s which is now known as \"%s\" - consulting name history", name, id.Id, id.Name)
+      id, err = c.resolveHistoricalOwner(ctx, name, id.Id, at)
+      if err != nil {
+        return nil, err
+      }
+    }
     if id != nil {
-      err := c.storage.PutProfileId(id)
+      err := c.storage.WithContext(ctx).PutProfileId(id)
       if err != nil {
         return nil, fmt.Errorf("storage backend responded with error: %s", err)
       }
 
-      c.logger.Debugf("wrote new data to storage backend")
+      logger.Debugf("wrote new data to storage backend")
 
-      c.events <- &entity.Event{
+      c.publishEvent(ctx, &entity.Event{
         Type: entity.ProfileIdEvent,
         Key: &entity.ProfileIdKey{
           Name: name,
           At:   at,
         },
         Object: id,
-      }
-      c.logger.Debugf("notified event channel")
+      })
+      logger.Debugf("notified event channel")
     } else {
-      c.logger.Debugf("cannot find resource on upstream")
+      logger.Debugf("cannot find resource on upstream")
     }
   } else {
-    c.logger.Debugf("query fulfilled using cached data")
+    logger.Debugf("query fulfilled using cached data")
+  }
+  if id != nil && !isHistoricalQuery(at) {
+    c.stale.Put(staleKey("profile_id", name), id.Copy())
   }
   return id, nil
 }
 
 // resolves multiple profile associations at the current time
-func (c *Cache) BulkGetProfileId(names []string) ([]*entity.ProfileId, error) {
-  c.logger.Debugf("processing query for profile Ids associated with names %s", strings.Join(names, ", "))
+func (c *Cache) BulkGetProfileId(ctx context.Context, names []string) (ids []*entity.ProfileId, err error) {
+  ctx, span := tracing.Start(ctx, "cache.BulkGetProfileId")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("processing query for profile Ids associated with names %s", strings.Join(names, ", "))
 
-  ids := make([]*entity.ProfileId, 0)
+  ids = make([]*entity.ProfileId, 0)
   at := time.Now()
 
   for i := 0; i < len(names); {
     name := names[i]
-    id, err := c.storage.GetProfileId(name, at) // TODO: bulk lookup support in storage backend?
+    id, err := c.storage.WithContext(ctx).GetProfileId(name, at) // TODO: bulk lookup support in storage backend?
     if err != nil {
-      c.logger.Errorf("storage backend responded with error: %s", err)
+      // names which cannot be looked up are treated as misses
+      logger.Errorf("storage backend responded with error: %s", err)
+      i++
       continue
     }
 
+    if id != nil && !answersQuery(id, name) {
+      id = nil
+    }
+
     if id != nil {
+      metrics.ObserveCacheRequest("profile_id", metrics.CacheHit)
+      c.stale.Put(staleKey("profile_id", name), id.Copy())
       ids = append(ids, id)
       names = append(names[:i], names[i+1:]...)
       continue
@@ -92,138 +157,313 @@ func (c *Cache) BulkGetProfileId(names []string) ([]*entity.ProfileId, error) {
 
     i++
   }
-  c.logger.Debugf("resolved %d profile Ids from cache, %d will be resolved from upstream", len(ids), len(names))
+  logger.Debugf("resolved %d profile Ids from cache, %d will be resolved from upstream", len(ids), len(names))
+  span.SetAttributes(
+    attribute.Int("stockpile.cache.hits", len(ids)),
+    attribute.Int("stockpile.cache.misses", len(names)),
+  )
 
   if len(names) == 0 {
-    c.logger.Debugf("query fulfilled using cached data")
+    logger.Debugf("query fulfilled using cached data")
     return ids, nil
   }
 
-  c.incrementRequestCounter()
-  newIds, err := c.upstream.BulkGetId(names)
+  ctx, done, err := c.beginRefresh(ctx)
+  defer done()
+  if err == nil {
+    err = c.acquireUpstreamRequest(ctx)
+  }
+  var newIds []*entity.ProfileId
+  if err == nil {
+    newIds, err = c.upstream.BulkGetId(ctx, names)
+    if err != nil {
+      err = fmt.Errorf("upstream responded with error: %s", err)
+    }
+  }
   if err != nil {
-    return nil, fmt.Errorf("upstream responded with error: %s", err)
+    // stale data is only served when it covers all remaining names as omitted names would otherwise
+    // be mistaken for names which are not associated with any profile
+    staleIds := make([]*entity.ProfileId, 0, len(names))
+    for _, name := range names {
+      stale, ok := c.getStale(ctx, "profile_id", name, err).(*entity.ProfileId)
+      if !ok || !answersQuery(stale, name) {
+        break
+      }
+      staleIds = append(staleIds, stale.Copy())
+    }
+    if len(staleIds) != len(names) {
+      metrics.ObserveCacheRequests("profile_id", metrics.CacheMiss, len(names))
+      return nil, err
+    }
+
+    metrics.ObserveCacheRequests("profile_id", metrics.CacheStale, len(names))
+    return append(ids, staleIds...), nil
   }
+  metrics.ObserveCacheRequests("profile_id", metrics.CacheMiss, len(names))
 
   for _, id := range newIds {
-    err := c.storage.PutProfileId(id) // TODO: bulk upload support in storage backend?
+    err := c.storage.WithContext(ctx).PutProfileId(id) // TODO: bulk upload support in storage backend?
     if err != nil {
       return nil, fmt.Errorf("storage backend responded with error: %s", err)
     }
 
-    c.events <- &entity.Event{
+    c.publishEvent(ctx, &entity.Event{
       Type: entity.ProfileIdEvent,
       Key: &entity.ProfileIdKey{
         Name: id.Name,
         At:   at,
       },
       Object: id,
-    }
+    })
+    c.stale.Put(staleKey("profile_id", id.Name), id.Copy())
   }
 
-  c.logger.Debugf("wrote new data to storage backend")
-  c.logger.Debugf("notified event channel")
+  logger.Debugf("wrote new data to storage backend")
+  logger.Debugf("notified event channel")
   return append(ids, newIds...), nil
 }
 
 // purges the profile association of a given name at a given time
-func (c *Cache) PurgeProfileId(name string, at time.Time) error {
-  c.logger.Debugf("purging name association for name \"%s\" at time %s", name, at)
-  return c.storage.PurgeProfileId(name, at)
+func (c *Cache) PurgeProfileId(ctx context.Context, name string, at time.Time) (err error) {
+  ctx, span := tracing.Start(ctx, "cache.PurgeProfileId")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("purging name association for name \"%s\" at time %s", name, at)
+  err = c.storage.WithContext(ctx).PurgeProfileId(name, at)
+  if err != nil {
+    return err
+  }
+  c.stale.Remove(staleKey("profile_id", name))
+
+  c.publishPurge(&entity.Event{
+    Type: entity.ProfileIdEvent,
+    Key: &entity.ProfileIdKey{
+      Name: name,
+      At:   at,
+    },
+  })
+  return nil
 }
 
 // retrieves the name history of a given profile
-func (c *Cache) GetNameHistory(id uuid.UUID) (*entity.NameChangeHistory, error) {
-  c.logger.Debugf("processing query for name history of profile %s", id)
+func (c *Cache) GetNameHistory(ctx context.Context, id uuid.UUID) (history *entity.NameChangeHistory, err error) {
+  ctx, span := tracing.Start(ctx, "cache.GetNameHistory")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("processing query for name history of profile %s", id)
 
-  history, err := c.storage.GetNameHistory(id)
+  history, err = c.storage.WithContext(ctx).GetNameHistory(id)
   if err != nil {
-    c.logger.Errorf("storage backend responded with an error: %s", err)
+    logger.Errorf("storage backend responded with an error: %s", err)
     history = nil
   }
+  result := metrics.LookupResult(history != nil)
+  defer func() { observeLookup(span, "name_history", result) }()
   if history == nil {
-    c.logger.Debugf("cache miss - requesting update from upstream")
+    logger.Debugf("cache miss - requesting update from upstream")
 
-    c.incrementRequestCounter()
-    history, err = c.upstream.GetHistory(id)
+    var done func()
+    ctx, done, err = c.beginRefresh(ctx)
+    defer done()
+    if err == nil {
+      err = c.acquireUpstreamRequest(ctx)
+    }
+    if err == nil {
+      history, err = c.upstream.GetHistory(ctx, id)
+      if err != nil {
+        err = fmt.Errorf("upstream responded with error: %s", err)
+      }
+    }
     if err != nil {
-      return nil, fmt.Errorf("upstream responded with error: %s", err)
+      if stale, ok := c.getStale(ctx, "name_history", id.String(), err).(*entity.NameChangeHistory); ok {
+        result = metrics.CacheStale
+        return stale.Copy(), nil
+      }
+      return nil, err
     }
 
     if history != nil {
-      err := c.storage.PutNameHistory(id, history)
+      err := c.storage.WithContext(ctx).PutNameHistory(id, history)
       if err != nil {
         return nil, fmt.Errorf("storage backend responded with error: %s", err)
       }
-      c.logger.Debugf("wrote new data to storage backend")
+      c.updateTimelines(ctx, id, history)
+      logger.Debugf("wrote new data to storage backend")
 
-      c.events <- &entity.Event{
+      c.publishEvent(ctx, &entity.Event{
         Type:   entity.NameHistoryEvent,
         Key:    &id,
         Object: history,
-      }
-      c.logger.Debugf("notified event channel")
+      })
+      logger.Debugf("notified event channel")
     } else {
-      c.logger.Debugf("cannot find resource on upstream")
+      logger.Debugf("cannot find resource on upstream")
     }
   } else {
-    c.logger.Debugf("query fulfilled using cached data")
+    logger.Debugf("query fulfilled using cached data")
+  }
+  if history != nil {
+    c.stale.Put(staleKey("name_history", id.String()), history.Copy())
   }
   return history, nil
 }
 
 // purges a name history from the cache
-func (c *Cache) PurgeNameHistory(id uuid.UUID) error {
-  c.logger.Debugf("purging name history for profile %s", id)
-  return c.storage.PurgeNameHistory(id)
+func (c *Cache) PurgeNameHistory(ctx context.Context, id uuid.UUID) (err error) {
+  ctx, span := tracing.Start(ctx, "cache.PurgeNameHistory")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("purging name history for profile %s", id)
+  err = c.storage.WithContext(ctx).PurgeNameHistory(id)
+  if err != nil {
+    return err
+  }
+  c.stale.Remove(staleKey("name_history", id.String()))
+
+  c.publishPurge(&entity.Event{
+    Type: entity.NameHistoryEvent,
+    Key:  &id,
+  })
+  return nil
 }
 
 // retrieves a single profile
-func (c *Cache) GetProfile(id uuid.UUID) (*entity.Profile, error) {
-  c.logger.Debugf("processing query for profile %s", id)
+func (c *Cache) GetProfile(ctx context.Context, id uuid.UUID) (profile *entity.Profile, err error) {
+  ctx, span := tracing.Start(ctx, "cache.GetProfile")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("processing query for profile %s", id)
 
-  profile, err := c.storage.GetProfile(id)
+  profile, err = c.storage.WithContext(ctx).GetProfile(id)
   if err != nil {
-    c.logger.Errorf("storage backend responded with an error: %s", err)
+    logger.Errorf("storage backend responded with an error: %s", err)
     profile = nil
   }
+  result := metrics.LookupResult(profile != nil)
+  defer func() { observeLookup(span, "profile", result) }()
   if profile == nil {
-    c.logger.Debugf("cache miss - requesting update from upstream")
+    logger.Debugf("cache miss - requesting update from upstream")
 
-    profile, err = c.upstream.GetProfile(id)
+    var done func()
+    ctx, done, err = c.beginRefresh(ctx)
+    defer done()
+    if err == nil {
+      profile, err = c.upstream.GetProfile(ctx, id)
+      if err != nil {
+        err = fmt.Errorf("upstream responded with error: %s", err)
+      }
+    }
     if err != nil {
-      return nil, fmt.Errorf("upstream responded with error: %s", err)
+      if stale, ok := c.getStale(ctx, "profile", id.String(), err).(*entity.Profile); ok {
+        result = metrics.CacheStale
+        return stale.Copy(), nil
+      }
+      return nil, err
     }
 
     if profile != nil {
-      err := c.storage.PutProfile(profile)
+      err := c.storage.WithContext(ctx).PutProfile(profile)
       if err != nil {
         return nil, fmt.Errorf("storage backend responded with error: %s", err)
       }
 
-      err = c.updateNameMapping(profile)
+      err = c.updateNameMapping(ctx, profile)
       if err != nil {
         return nil, fmt.Errorf("storage backend responded with error: %s", err)
       }
-      c.logger.Debugf("wrote new data to storage backend")
+      logger.Debugf("wrote new data to storage backend")
 
-      c.events <- &entity.Event{
+      c.publishEvent(ctx, &entity.Event{
         Type:   entity.ProfileEvent,
         Key:    &id,
         Object: profile,
-      }
-      c.logger.Debugf("notified event channel")
+      })
+      logger.Debugf("notified event channel")
     } else {
-      c.logger.Debugf("cannot find resource on upstream")
+      logger.Debugf("cannot find resource on upstream")
     }
   } else {
-    c.logger.Debugf("query fulfilled using cached data")
+    logger.Debugf("query fulfilled using cached data")
+  }
+  if profile != nil {
+    c.stale.Put(staleKey("profile", id.String()), profile.Copy())
   }
   return profile, nil
 }
 
 // purges a specific profile from the cache
-func (c *Cache) PurgeProfile(id uuid.UUID) error {
-  c.logger.Debugf("purging profile with id %s", id)
-  return c.storage.PurgeProfile(id)
+func (c *Cache) PurgeProfile(ctx context.Context, id uuid.UUID) (err error) {
+  ctx, span := tracing.Start(ctx, "cache.PurgeProfile")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("purging profile with id %s", id)
+  err = c.storage.WithContext(ctx).PurgeProfile(id)
+  if err != nil {
+    return err
+  }
+  c.stale.Remove(staleKey("profile", id.String()))
+
+  c.publishPurge(&entity.Event{
+    Type: entity.ProfileEvent,
+    Key:  &id,
+  })
+  return nil
+}
+
+// resolves the identifier which offline mode servers derive from a given name
+// the identifier is computed locally regardless of the configured upstream while its profile and
+// name history are cached in order to permit their retrieval via the identifier later on
+func (c *Cache) GetOfflineProfileId(ctx context.Context, name string, at time.Time) (id *entity.ProfileId, err error) {
+  ctx, span := tracing.Start(ctx, "cache.GetOfflineProfileId")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("processing query for offline profile Id associated with name \"%s\" at time %s", name, at)
+
+  id = entity.NewOfflineProfileId(name, at)
+  backend := c.storage.WithContext(ctx)
+  profile, err := backend.GetProfile(id.Id)
+  if err != nil {
+    logger.Errorf("storage backend responded with error: %s", err)
+    profile = nil
+  }
+  observeLookup(span, "profile_id", metrics.LookupResult(profile != nil))
+  if profile != nil {
+    logger.Debugf("query fulfilled using cached data")
+    return id, nil
+  }
+
+  // offline identifiers are kept out of the name cache as the name may be owned by an online
+  // profile at the same time
+  profile = entity.NewOfflineProfile(name)
+  err = backend.PutProfile(profile)
+  if err != nil {
+    return nil, fmt.Errorf("storage backend responded with error: %s", err)
+  }
+  history := entity.NewOfflineNameHistory(name)
+  err = backend.PutNameHistory(id.Id, history)
+  if err != nil {
+    return nil, fmt.Errorf("storage backend responded with error: %s", err)
+  }
+  logger.Debugf("wrote new data to storage backend")
+
+  c.publishEvent(ctx, &entity.Event{
+    Type: entity.ProfileIdEvent,
+    Key: &entity.ProfileIdKey{
+      Name: name,
+      At:   at,
+    },
+    Object: id,
+  })
+  c.publishEvent(ctx, &entity.Event{
+    Type:   entity.NameHistoryEvent,
+    Key:    &id.Id,
+    Object: history,
+  })
+  c.publishEvent(ctx, &entity.Event{
+    Type:   entity.ProfileEvent,
+    Key:    &id.Id,
+    Object: profile,
+  })
+  logger.Debugf("notified event channel")
+  return id, nil
 }
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cache_test
+
+import (
+  "context"
+  "errors"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/dotStart/Stockpile/stockpile/upstream"
+)
+
+// simulates a storage backend which is unable to look up name associations
+type failingStorageBackend struct {
+  storage.StorageBackend
+}
+
+func (*failingStorageBackend) GetProfileId(name string, at time.Time) (*entity.ProfileId, error) {
+  return nil, errors.New("storage unavailable")
+}
+
+// creates a cache which is backed by memory storage and resolves all names to their offline
+// identifiers
+func newOfflineCache(t *testing.T) *cache.Cache {
+  return newOfflineCacheWithStorage(t, func(backend storage.StorageBackend) storage.StorageBackend {
+    return backend
+  })
+}
+
+// creates a cache which resolves all names to their offline identifiers and is backed by a
+// memory storage backend wrapped by the passed function
+func newOfflineCacheWithStorage(t *testing.T, wrap func(backend storage.StorageBackend) storage.StorageBackend) *cache.Cache {
+  cfg := servertest.LoadConfig(t, "storage \"mem\" {}\nupstream \"offline\" {\n  realm = true\n}\n")
+
+  provider, err := upstream.NewOfflineUpstream(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  backend, err := storage.NewMemoryStorageBackend(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  rateLimit, err := ratelimit.NewLocalStore(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return cache.New(provider, wrap(backend), rateLimit)
+}
+
+// offline identifiers are derived from the exact spelling of a name and must thus not be answered
+// from cache for other spellings of the same name
+func TestOfflineProfileIdSpelling(t *testing.T) {
+  c := newOfflineCache(t)
+  defer c.Close()
+  ctx := context.Background()
+
+  for _, name := range []string{"Notch", "notch", "Notch"} {
+    id, err := c.GetProfileId(ctx, name, time.Now())
+    if err != nil {
+      t.Fatal(err)
+    }
+    if id == nil || id.Id != entity.OfflineId(name) || id.Name != name {
+      t.Fatalf("expected \"%s\" to resolve to offline profile %s but got %v", name, entity.OfflineId(name), id)
+    }
+  }
+
+  ids, err := c.BulkGetProfileId(ctx, []string{"notch", "NOTCH"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(ids) != 2 {
+    t.Fatalf("expected 2 profile ids but got %d", len(ids))
+  }
+  for _, id := range ids {
+    if id.Id != entity.OfflineId(id.Name) {
+      t.Errorf("expected \"%s\" to resolve to offline profile %s but got %s", id.Name, entity.OfflineId(id.Name), id.Id)
+    }
+  }
+
+  // offline names cannot be changed and are thus answered for past points in time as well
+  at := time.Now().Add(-24 * time.Hour)
+  id, err := c.GetProfileId(ctx, "nOtCh", at)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if id == nil || id.Id != entity.OfflineId("nOtCh") {
+    t.Fatalf("expected \"nOtCh\" to resolve to offline profile %s at %s but got %v", entity.OfflineId("nOtCh"), at, id)
+  }
+}
+
+// names which cannot be looked up within the storage backend are resolved from upstream instead
+func TestBulkProfileIdStorageError(t *testing.T) {
+  c := newOfflineCacheWithStorage(t, func(backend storage.StorageBackend) storage.StorageBackend {
+    return &failingStorageBackend{backend}
+  })
+  defer c.Close()
+
+  ids, err := c.BulkGetProfileId(context.Background(), []string{"Notch", "jeb_"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(ids) != 2 {
+    t.Fatalf("expected 2 profile ids but got %d", len(ids))
+  }
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cache_test
+
+import (
+  "context"
+  "errors"
+  "testing"
+
+  "github.com/dotStart/Stockpile/stockpile/mojang/mock"
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/google/uuid"
+)
+
+// simulates a rate limit store which grants no requests (or fails to respond when an error is
+// given)
+type deniedRateLimitStore struct {
+  ratelimit.Store
+  err error
+}
+
+func (s *deniedRateLimitStore) Take() (bool, error) {
+  return false, s.err
+}
+
+func (s *deniedRateLimitStore) Close() error {
+  return nil
+}
+
+// creates a fixture which consists of a single profile
+func newProfileFixture(id uuid.UUID) *mock.Fixture {
+  return &mock.Fixture{
+    Profiles: []*mock.FixtureProfile{
+      {Id: id.String(), Names: []*mock.FixtureName{{Name: "Notch"}}},
+    },
+  }
+}
+
+func TestRateLimit(t *testing.T) {
+  tests := []struct {
+    name     string
+    err      error
+    enforced bool
+    rejected bool
+  }{
+    {name: "exhausted"},
+    {name: "exhausted-enforced", enforced: true, rejected: true},
+    {name: "store-error", err: errors.New("store unavailable")},
+    {name: "store-error-enforced", err: errors.New("store unavailable"), enforced: true},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      id := uuid.New()
+      c, upstreamMock, closeFn := newMockCache(t, newProfileFixture(id), func(backend storage.StorageBackend) storage.StorageBackend {
+        return backend
+      }, &deniedRateLimitStore{err: test.err})
+      defer closeFn()
+      c.SetRateLimitEnforced(test.enforced)
+
+      history, err := c.GetNameHistory(context.Background(), id)
+      if test.rejected {
+        if err != ratelimit.ErrRateLimitExceeded {
+          t.Fatalf("expected request to be rejected but got %v", err)
+        }
+        if n := upstreamMock.RequestCount("name_history"); n != 0 {
+          t.Errorf("expected no upstream requests but got %d", n)
+        }
+        return
+      }
+
+      if err != nil {
+        t.Fatal(err)
+      }
+      if history == nil || len(history.History) != 1 {
+        t.Fatalf("expected name history of profile %s but got %v", id, history)
+      }
+      if n := upstreamMock.RequestCount("name_history"); n != 1 {
+        t.Errorf("expected 1 upstream request but got %d", n)
+      }
+    })
+  }
+}
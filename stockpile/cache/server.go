@@ -17,82 +17,128 @@
 package cache
 
 import (
+  "context"
   "fmt"
 
   "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/logs"
+  "github.com/dotStart/Stockpile/stockpile/metrics"
+  "github.com/dotStart/Stockpile/stockpile/tracing"
 )
 
 // retrieves the current server blacklist
-func (c *Cache) GetBlacklist() (*entity.Blacklist, error) {
-  c.logger.Debugf("processing query for server blacklist")
+func (c *Cache) GetBlacklist(ctx context.Context) (blacklist *entity.Blacklist, err error) {
+  ctx, span := tracing.Start(ctx, "cache.GetBlacklist")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("processing query for server blacklist")
 
-  blacklist, err := c.storage.GetBlacklist()
+  blacklist, err = c.storage.WithContext(ctx).GetBlacklist()
   if err != nil {
-    c.logger.Errorf("storage backend responded with an error: %s", err)
+    logger.Errorf("storage backend responded with an error: %s", err)
     blacklist = nil
   }
+  result := metrics.LookupResult(blacklist != nil)
+  defer func() { observeLookup(span, "blacklist", result) }()
   if blacklist == nil {
-    c.logger.Debugf("cache miss - requesting update from upstream")
+    logger.Debugf("cache miss - requesting update from upstream")
 
-    c.incrementRequestCounter()
-    blacklist, err = c.upstream.GetBlacklist()
+    var done func()
+    ctx, done, err = c.beginRefresh(ctx)
+    defer done()
+    if err == nil {
+      err = c.acquireUpstreamRequest(ctx)
+    }
+    if err == nil {
+      blacklist, err = c.upstream.GetBlacklist(ctx)
+      if err != nil {
+        err = fmt.Errorf("upstream responded with error: %s", err)
+      }
+    }
     if err != nil {
-      return nil, fmt.Errorf("upstream responded with error: %s", err)
+      if stale, ok := c.getStale(ctx, "blacklist", "", err).(*entity.Blacklist); ok {
+        result = metrics.CacheStale
+        return stale.Copy(), nil
+      }
+      return nil, err
     }
 
     if blacklist != nil {
-      err = c.storage.PutBlacklist(blacklist)
+      err = c.storage.WithContext(ctx).PutBlacklist(blacklist)
       if err != nil {
         return nil, fmt.Errorf("storage backend responded with error: %s", err)
       }
-      c.logger.Debugf("wrote new data to storage backend")
+      logger.Debugf("wrote new data to storage backend")
 
-      c.events <- &entity.Event{
+      c.publishEvent(ctx, &entity.Event{
         Type:   entity.BlacklistEvent,
         Key:    nil,
         Object: blacklist,
-      }
-      c.logger.Debugf("notified event channel")
+      })
+      logger.Debugf("notified event channel")
     } else {
-      c.logger.Debugf("cannot find resource on upstream")
+      logger.Debugf("cannot find resource on upstream")
     }
   } else {
-    c.logger.Debugf("query fulfilled using cached data")
+    logger.Debugf("query fulfilled using cached data")
+  }
+  if blacklist != nil {
+    c.stale.Put(staleKey("blacklist", ""), blacklist.Copy())
   }
   return blacklist, nil
 }
 
-func (c *Cache) PurgeBlacklist() error {
-  c.logger.Debugf("purging blacklist")
-  return c.storage.PurgeBlacklist()
+func (c *Cache) PurgeBlacklist(ctx context.Context) (err error) {
+  ctx, span := tracing.Start(ctx, "cache.PurgeBlacklist")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("purging blacklist")
+  err = c.storage.WithContext(ctx).PurgeBlacklist()
+  if err != nil {
+    return err
+  }
+  c.stale.Remove(staleKey("blacklist", ""))
+
+  c.publishPurge(&entity.Event{
+    Type: entity.BlacklistEvent,
+  })
+  return nil
 }
 
 // performs a cache assisted server login
-func (c *Cache) Login(displayName string, serverId string, ip string) (*entity.Profile, error) {
-  c.logger.Debugf("processing login for user \"%s\" on server \"%s\" (with address \"%s\")", displayName, serverId, ip)
+func (c *Cache) Login(ctx context.Context, displayName string, serverId string, ip string) (profile *entity.Profile, err error) {
+  ctx, span := tracing.Start(ctx, "cache.Login")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("processing login for user \"%s\" on server \"%s\" (with address \"%s\")", displayName, serverId, ip)
 
-  profile, err := c.upstream.Login(displayName, serverId, ip)
+  ctx, done, err := c.beginRefresh(ctx)
+  defer done()
+  if err != nil {
+    return nil, err
+  }
+  profile, err = c.upstream.Login(ctx, displayName, serverId, ip)
   if err != nil {
     return nil, fmt.Errorf("upstream responded with error: %s", err)
   }
 
-  err = c.storage.PutProfile(profile)
+  err = c.storage.WithContext(ctx).PutProfile(profile)
   if err != nil {
     return nil, fmt.Errorf("storage backend responded with error: %s", err)
   }
 
-  err = c.updateNameMapping(profile)
+  err = c.updateNameMapping(ctx, profile)
   if err != nil {
     return nil, fmt.Errorf("storage backend responded with error: %s", err)
   }
-  c.logger.Debugf("wrote new data to storage backend")
+  logger.Debugf("wrote new data to storage backend")
 
-  c.events <- &entity.Event{
+  c.publishEvent(ctx, &entity.Event{
     Type:   entity.ProfileEvent,
-    Key:    profile.Id,
+    Key:    &profile.Id,
     Object: profile,
-  }
-  c.logger.Debugf("notified event channel")
+  })
+  logger.Debugf("notified event channel")
 
   return profile, nil
 }
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cache
+
+import (
+  "container/list"
+  "context"
+  "strings"
+  "sync"
+
+  "github.com/dotStart/Stockpile/stockpile/logs"
+)
+
+// defines the amount of previously served entries which are retained in order to answer requests
+// while the upstream servers are unavailable
+const defaultStaleEntries = 10000
+
+// retains copies of the most recently served entries beyond their expiry within the storage backend
+// these entries are only ever served when a fresh copy cannot be retrieved from upstream (e.g.
+// because the upstream servers are unavailable or the request budget has been exhausted)
+type staleEntries struct {
+  mutex      sync.Mutex
+  maxEntries int
+  entries    map[string]*list.Element
+  order      *list.List
+}
+
+// represents a single entry within the stale entry cache
+type staleEntry struct {
+  key   string
+  value interface{}
+}
+
+func newStaleEntries(maxEntries int) *staleEntries {
+  return &staleEntries{
+    maxEntries: maxEntries,
+    entries:    make(map[string]*list.Element),
+    order:      list.New(),
+  }
+}
+
+// calculates the key of a stale entry
+// keys are case insensitive as names are cached case insensitively
+func staleKey(entityType string, key string) string {
+  return entityType + "/" + strings.ToLower(key)
+}
+
+// retrieves a previously served entry (if any)
+func (s *staleEntries) Get(key string) (interface{}, bool) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  elem := s.entries[key]
+  if elem == nil {
+    return nil, false
+  }
+  s.order.MoveToFront(elem)
+  return elem.Value.(*staleEntry).value, true
+}
+
+// records an entry which has been served and evicts the least recently used entries if the cache
+// exceeds its capacity
+// the passed value is expected to be a copy which is not referenced by any callers
+func (s *staleEntries) Put(key string, value interface{}) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  if elem := s.entries[key]; elem != nil {
+    elem.Value.(*staleEntry).value = value
+    s.order.MoveToFront(elem)
+    return
+  }
+
+  s.entries[key] = s.order.PushFront(&staleEntry{
+    key:   key,
+    value: value,
+  })
+  for s.order.Len() > s.maxEntries {
+    elem := s.order.Back()
+    s.order.Remove(elem)
+    delete(s.entries, elem.Value.(*staleEntry).key)
+  }
+}
+
+// removes an entry (if present)
+func (s *staleEntries) Remove(key string) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  if elem := s.entries[key]; elem != nil {
+    s.order.Remove(elem)
+    delete(s.entries, key)
+  }
+}
+
+// retrieves a previously served entry after a fresh copy could not be retrieved from upstream
+// nil is returned when no such entry is known
+func (c *Cache) getStale(ctx context.Context, entityType string, key string, cause error) interface{} {
+  value, ok := c.stale.Get(staleKey(entityType, key))
+  if !ok {
+    return nil
+  }
+
+  logs.ForContext(ctx, c.logger).Warningf("serving stale %s \"%s\" as it cannot be retrieved from upstream: %s", entityType, key, cause)
+  return value
+}
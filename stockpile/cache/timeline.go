@@ -0,0 +1,250 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cache
+
+import (
+  "context"
+  "fmt"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/logs"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/dotStart/Stockpile/stockpile/tracing"
+  "github.com/google/uuid"
+)
+
+// defines the age at which a query is considered to refer to a past point in time rather than the
+// current state of a name (e.g. to compensate for clock skew and transmission delays)
+const historicalQueryThreshold = time.Minute
+
+// evaluates whether a given query time refers to a past point in time
+func isHistoricalQuery(at time.Time) bool {
+  return at.Before(time.Now().Add(-historicalQueryThreshold))
+}
+
+// retrieves the ownership timeline of a given name
+// the timeline is reconciled with the name history of the current owner before it is returned and
+// thus covers at least all periods which are known to upstream at this time (periods during which
+// the name is known to have been unowned are omitted)
+func (c *Cache) GetNameTimeline(ctx context.Context, name string) (timeline *entity.NameTimeline, err error) {
+  ctx, span := tracing.Start(ctx, "cache.GetNameTimeline")
+  defer func() { tracing.End(span, err) }()
+  logger := logs.ForContext(ctx, c.logger)
+  logger.Debugf("processing query for timeline of name \"%s\"", name)
+
+  id, err := c.GetProfileId(ctx, name, time.Now())
+  if err != nil {
+    return nil, err
+  }
+  if id != nil {
+    timeline, err = c.reconcileTimeline(ctx, name, id.Id)
+    if err != nil {
+      return nil, err
+    }
+    return timeline.Owned(), nil
+  }
+
+  timeline, err = c.storage.WithContext(ctx).GetNameTimeline(name)
+  if err != nil {
+    return nil, err
+  }
+  if timeline == nil {
+    timeline = entity.NewNameTimeline(name)
+  }
+  return timeline.Owned(), nil
+}
+
+// resolves a historical name association using the ownership timeline of the name
+// when the timeline does not cover the requested time, upstream is consulted and the name history
+// of the reported profile is merged into the timeline before it is evaluated again - if the
+// timeline still does not cover the time, the name is recorded as unowned
+func (c *Cache) resolveHistoricalProfileId(ctx context.Context, timeline *entity.NameTimeline, name string, at time.Time) (*entity.ProfileId, error) {
+  logger := logs.ForContext(ctx, c.logger)
+  if timeline != nil {
+    if ownership := timeline.OwnerAt(at); ownership != nil {
+      logger.Debugf("query fulfilled using name timeline")
+      if ownership.IsUnowned() {
+        return nil, nil
+      }
+      return ownership.ProfileId(), nil
+    }
+  }
+  logger.Debugf("name timeline does not cover requested time - requesting update from upstream")
+
+  ctx, done, err := c.beginRefresh(ctx)
+  defer done()
+  if err != nil {
+    return nil, err
+  }
+  err = c.acquireUpstreamRequest(ctx)
+  if err != nil {
+    return nil, err
+  }
+  id, err := c.upstream.GetId(ctx, name, at)
+  if err != nil {
+    return nil, fmt.Errorf("upstream responded with error: %s", err)
+  }
+  if id == nil {
+    logger.Debugf("cannot find resource on upstream")
+    c.markUnowned(ctx, name, at)
+    return nil, nil
+  }
+  if entity.IsOfflineId(id.Id) {
+    // offline names cannot be changed and are thus assigned to the same profile at all times
+    return id, nil
+  }
+  if timeline != nil && timeline.Contains(id.Id) {
+    logger.Debugf("name timeline already reflects the name history of profile %s", id.Id)
+    c.markUnowned(ctx, name, at)
+    return nil, nil
+  }
+
+  // upstream may ignore the requested time and report the current owner instead thus its claim is
+  // only accepted when backed by the name history of the reported profile
+  return c.resolveHistoricalOwner(ctx, name, id.Id, at)
+}
+
+// resolves a historical name association using the name history of the profile which upstream
+// reports as its owner
+// backends which do not retain timelines derive the association from the history alone
+func (c *Cache) resolveHistoricalOwner(ctx context.Context, name string, id uuid.UUID, at time.Time) (*entity.ProfileId, error) {
+  timeline, err := c.reconcileTimeline(ctx, name, id)
+  if err == storage.ErrTimelineUnsupported {
+    var history *entity.NameChangeHistory
+    history, err = c.GetNameHistory(ctx, id)
+    timeline = entity.NewNameTimeline(name)
+    if history != nil {
+      timeline.Update(id, history, time.Now())
+    }
+  }
+  if err != nil {
+    return nil, err
+  }
+  if ownership := timeline.OwnerAt(at); ownership != nil && !ownership.IsUnowned() {
+    return ownership.ProfileId(), nil
+  }
+
+  logs.ForContext(ctx, c.logger).Debugf("name history of profile %s does not confirm upstream association", id)
+  c.markUnowned(ctx, name, at)
+  return nil, nil
+}
+
+// records that a name is not known to have been assigned at a given time within its timeline in
+// order to answer repeated queries without consulting upstream
+func (c *Cache) markUnowned(ctx context.Context, name string, at time.Time) {
+  logger := logs.ForContext(ctx, c.logger)
+  backend := c.storage.WithContext(ctx)
+
+  c.timelineMutex.Lock()
+  defer c.timelineMutex.Unlock()
+
+  timeline, err := backend.GetNameTimeline(name)
+  if err == storage.ErrTimelineUnsupported {
+    return
+  }
+  if err != nil {
+    logger.Errorf("failed to retrieve timeline of name \"%s\": %s", name, err)
+    return
+  }
+
+  if timeline == nil {
+    timeline = entity.NewNameTimeline(name)
+  } else {
+    timeline = timeline.Copy()
+  }
+  period := timeline.MarkUnowned(at, time.Now())
+
+  err = backend.PutNameTimeline(timeline)
+  if err != nil {
+    logger.Errorf("failed to update timeline of name \"%s\": %s", name, err)
+    return
+  }
+  if period.IsUnowned() {
+    logger.Debugf("recorded name \"%s\" as unowned from %s until %s", name, period.From, period.Until)
+  }
+}
+
+// ensures that the timeline of a given name reflects the name history of a given profile
+// offline profiles are excluded from timelines as their identifiers depend on the exact spelling of
+// a name while timelines are shared by all spellings
+func (c *Cache) reconcileTimeline(ctx context.Context, name string, id uuid.UUID) (*entity.NameTimeline, error) {
+  var history *entity.NameChangeHistory
+  if !entity.IsOfflineId(id) {
+    var err error
+    history, err = c.GetNameHistory(ctx, id)
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  c.timelineMutex.Lock()
+  defer c.timelineMutex.Unlock()
+
+  backend := c.storage.WithContext(ctx)
+  timeline, err := backend.GetNameTimeline(name)
+  if err != nil {
+    return nil, err
+  }
+  if timeline == nil {
+    timeline = entity.NewNameTimeline(name)
+  }
+  if history == nil || timeline.Contains(id) {
+    return timeline, nil
+  }
+
+  timeline = timeline.Copy()
+  timeline.Update(id, history, time.Now())
+  return timeline, backend.PutNameTimeline(timeline)
+}
+
+// merges a freshly retrieved name history into the timelines of all names it contains
+func (c *Cache) updateTimelines(ctx context.Context, id uuid.UUID, history *entity.NameChangeHistory) {
+  if entity.IsOfflineId(id) {
+    return
+  }
+  logger := logs.ForContext(ctx, c.logger)
+  backend := c.storage.WithContext(ctx)
+  at := time.Now()
+
+  c.timelineMutex.Lock()
+  defer c.timelineMutex.Unlock()
+
+  for _, name := range history.Names() {
+    timeline, err := backend.GetNameTimeline(name)
+    if err == storage.ErrTimelineUnsupported {
+      return
+    }
+    if err != nil {
+      logger.Errorf("failed to retrieve timeline of name \"%s\": %s", name, err)
+      continue
+    }
+
+    if timeline == nil {
+      timeline = entity.NewNameTimeline(name)
+    } else {
+      timeline = timeline.Copy()
+    }
+    timeline.Update(id, history, at)
+
+    err = backend.PutNameTimeline(timeline)
+    if err != nil {
+      logger.Errorf("failed to update timeline of name \"%s\": %s", name, err)
+    }
+  }
+  logger.Debugf("updated timelines of names used by profile %s", id)
+}
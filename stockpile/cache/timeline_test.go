@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cache_test
+
+import (
+  "context"
+  "net/http/httptest"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/mojang"
+  "github.com/dotStart/Stockpile/stockpile/mojang/mock"
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/google/uuid"
+)
+
+// hides the timeline support of a storage backend
+type timelineUnawareStorageBackend struct {
+  storage.StorageBackend
+}
+
+// creates a cache which is backed by a mock upstream serving a given fixture and a memory storage
+// backend wrapped by the passed function
+// when no rate limit store is passed, a local store is used instead
+func newMockCache(t *testing.T, fixture *mock.Fixture, wrap func(backend storage.StorageBackend) storage.StorageBackend, rateLimit ratelimit.Store) (*cache.Cache, *mock.Server, func()) {
+  err := fixture.Parse()
+  if err != nil {
+    t.Fatal(err)
+  }
+  upstreamMock := mock.New(fixture, mock.Options{})
+  upstreamSrv := httptest.NewServer(upstreamMock)
+
+  cfg := servertest.LoadConfig(t, "storage \"mem\" {}\n")
+  api := mojang.New()
+  err = api.SetServers(upstreamSrv.URL, upstreamSrv.URL)
+  if err != nil {
+    t.Fatal(err)
+  }
+  backend, err := storage.NewMemoryStorageBackend(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if rateLimit == nil {
+    rateLimit, err = ratelimit.NewLocalStore(cfg)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  c := cache.New(api, wrap(backend), rateLimit)
+  return c, upstreamMock, func() {
+    c.Close()
+    upstreamSrv.Close()
+  }
+}
+
+// creates a fixture which consists of a single profile which has been renamed from "alpha" to
+// "beta" at a given time
+func newRenamedFixture(id uuid.UUID, renamedAt time.Time) *mock.Fixture {
+  return &mock.Fixture{
+    Profiles: []*mock.FixtureProfile{
+      {Id: id.String(), Names: []*mock.FixtureName{
+        {Name: "alpha"},
+        {Name: "beta", ChangedToAt: renamedAt.Unix() * 1000},
+      }},
+    },
+  }
+}
+
+// historical queries are answered from the timeline of a name after its owner (or the absence
+// thereof) has been established via upstream once
+func TestHistoricalProfileIdTimeline(t *testing.T) {
+  id := uuid.New()
+  renamedAt := time.Now().Add(-20 * 24 * time.Hour)
+  c, upstreamMock, closeFn := newMockCache(t, newRenamedFixture(id, renamedAt), func(backend storage.StorageBackend) storage.StorageBackend {
+    return backend
+  }, nil)
+  defer closeFn()
+  ctx := context.Background()
+
+  owned := renamedAt.Add(-10 * 24 * time.Hour)
+  unowned := renamedAt.Add(10 * 24 * time.Hour)
+  for i := 0; i < 2; i++ {
+    profileId, err := c.GetProfileId(ctx, "alpha", owned)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if profileId == nil || profileId.Id != id {
+      t.Fatalf("lookup #%d: expected \"alpha\" to resolve to %s at %s but got %v", i, id, owned, profileId)
+    }
+
+    profileId, err = c.GetProfileId(ctx, "alpha", unowned)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if profileId != nil {
+      t.Fatalf("lookup #%d: expected \"alpha\" to be unowned at %s but got %v", i, unowned, profileId)
+    }
+  }
+  if n := upstreamMock.RequestCount("profile_id"); n != 2 {
+    t.Errorf("expected repeated queries to be answered locally but upstream received %d requests", n)
+  }
+
+  // periods during which a name was unowned are not exposed
+  timeline, err := c.GetNameTimeline(ctx, "alpha")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(timeline.Periods) != 1 || timeline.Periods[0].Id != id {
+    t.Errorf("expected timeline to consist of a single period of profile %s but got %d period(s)", id, len(timeline.Periods))
+  }
+}
+
+// associations with profiles which have since been renamed are derived from their name history
+// and retained when the storage backend does not support timelines
+func TestHistoricalProfileIdRenamedOwner(t *testing.T) {
+  id := uuid.New()
+  renamedAt := time.Now().Add(-20 * 24 * time.Hour)
+  c, upstreamMock, closeFn := newMockCache(t, newRenamedFixture(id, renamedAt), func(backend storage.StorageBackend) storage.StorageBackend {
+    return &timelineUnawareStorageBackend{backend}
+  }, nil)
+  defer closeFn()
+  ctx := context.Background()
+
+  at := renamedAt.Add(-10 * 24 * time.Hour)
+  for i := 0; i < 2; i++ {
+    profileId, err := c.GetProfileId(ctx, "alpha", at)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if profileId == nil || profileId.Id != id || profileId.Name != "alpha" {
+      t.Fatalf("lookup #%d: expected \"alpha\" to resolve to %s at %s but got %v", i, id, at, profileId)
+    }
+  }
+  if n := upstreamMock.RequestCount("profile_id"); n != 1 {
+    t.Errorf("expected repeated query to be answered from storage but upstream received %d requests", n)
+  }
+}
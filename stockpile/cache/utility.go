@@ -17,13 +17,21 @@
 package cache
 
 import (
+  "context"
   "time"
 
   "github.com/dotStart/Stockpile/entity"
 )
 
+// evaluates whether a cached association answers a query for a given name
+// names are cached case insensitively while offline identifiers are derived from the exact
+// spelling of a name and thus only answer queries for the very same spelling
+func answersQuery(id *entity.ProfileId, name string) bool {
+  return !entity.IsOfflineId(id.Id) || id.Name == name
+}
+
 // adjusts the name associations for the data discovered through a profile request
-func (c *Cache) updateNameMapping(profile *entity.Profile) error {
+func (c *Cache) updateNameMapping(ctx context.Context, profile *entity.Profile) error {
   at := time.Now()
 
   mapping := &entity.ProfileId{
@@ -33,15 +41,15 @@ func (c *Cache) updateNameMapping(profile *entity.Profile) error {
   }
   mapping.UpdateExpiration(at)
 
-  c.storage.PutProfileId(mapping)
+  c.storage.WithContext(ctx).PutProfileId(mapping)
 
-  c.events <- &entity.Event{
+  c.publishEvent(ctx, &entity.Event{
     Type: entity.ProfileIdEvent,
     Key: &entity.ProfileIdKey{
       Name: profile.Name,
       At:   at,
     },
     Object: mapping,
-  }
+  })
   return nil
 }
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+  "encoding/json"
+
+  "github.com/dotStart/Stockpile/stockpile/server"
+)
+
+// identifies the type of data which is carried by a message
+type MessageType string
+
+const (
+  // indicates that an instance has written new data (the payload consists of an encoded event)
+  EventMessage MessageType = "event"
+  // indicates that an instance has purged data (the payload consists of an encoded event which
+  // solely identifies the purged entry)
+  PurgeMessage MessageType = "purge"
+)
+
+// represents a message which is exchanged between the instances within a cluster
+type Message struct {
+  Type    MessageType
+  Payload []byte
+}
+
+// represents a serializable version of a message
+type serializableMessage struct {
+  Type    MessageType `json:"type"`
+  Payload []byte      `json:"payload,omitempty"`
+}
+
+func (m *Message) Serialize() ([]byte, error) {
+  return json.Marshal(&serializableMessage{
+    Type:    m.Type,
+    Payload: m.Payload,
+  })
+}
+
+func (m *Message) Deserialize(enc []byte) error {
+  parsed := serializableMessage{}
+  err := json.Unmarshal(enc, &parsed)
+  if err != nil {
+    return err
+  }
+
+  m.Type = parsed.Type
+  m.Payload = parsed.Payload
+  return nil
+}
+
+// handles a message which has been published by another instance
+type Handler = func(msg *Message)
+
+// provides a transport which distributes messages between all instances within a cluster
+type EventBus interface {
+  // publishes a message to all other instances within the cluster
+  Publish(msg *Message) error
+  // registers a handler which is notified of every message published by another instance
+  // (messages published by this instance are never passed to the handler)
+  Subscribe(handler Handler) error
+
+  // clears all allocated resources
+  Close() error
+}
+
+// provides a factory for event bus instances
+type Factory = func(cfg *server.Config) (EventBus, error)
@@ -17,8 +17,11 @@
 package command
 
 import (
+  "errors"
   "flag"
   "fmt"
+  "os"
+  "strings"
 
   "github.com/dotStart/Stockpile/client"
   "github.com/dotStart/Stockpile/stockpile/server"
@@ -42,3 +45,91 @@ func (c *ClientCommand) SetFlags(f *flag.FlagSet) {
   f.StringVar(&c.flagServerAddress, "server-address", fmt.Sprintf("%s:%d", server.DefaultAddress, server.DefaultPort), "specifies the address of the target server")
   // TODO: TLS
 }
+
+type ServerConfigCommand struct {
+  flagConfig       string
+  flagDevelopment  bool
+  flagLogLevel     string
+  flagCorsOverride string
+  flagSet          settingOverrides
+}
+
+// collects the settings which have been passed via the -set flag
+type settingOverrides []string
+
+func (c *ServerConfigCommand) SetFlags(f *flag.FlagSet) {
+  f.StringVar(&c.flagConfig, "config", "", "specifies a configuration file or directory")
+  f.BoolVar(&c.flagDevelopment, "dev", false, "enables development mode")
+  f.StringVar(&c.flagLogLevel, "log-level", "", "specifies a log level (overrides the configured default level)")
+  f.StringVar(&c.flagCorsOverride, "cors-override", "", "specifies a host from which CORS requests are permitted")
+  f.Var(&c.flagSet, "set", "overrides a configuration setting (e.g. -set ttl.profile=24h, may be repeated)")
+}
+
+// loads the server configuration and applies all command line overrides
+// the layers which make up the resulting configuration are returned along with the configuration
+func (c *ServerConfigCommand) loadConfig() ([]*server.ConfigLayer, *server.Config, error) {
+  layers := make([]*server.ConfigLayer, 0)
+  if c.flagDevelopment {
+    layers = append(layers, &server.ConfigLayer{
+      Source: "development mode",
+      Config: server.DevelopmentOverrides(),
+    })
+  }
+  if c.flagConfig != "" {
+    fileLayers, err := server.LoadConfigLayers(c.flagConfig)
+    if err != nil {
+      return nil, nil, err
+    }
+    layers = append(layers, fileLayers...)
+  } else if !c.flagDevelopment {
+    return nil, nil, errors.New("configuration file is required in production mode")
+  }
+
+  envLayer, err := server.EnvironmentLayer(os.Environ())
+  if err != nil {
+    return nil, nil, err
+  }
+  layers = append(layers, envLayer)
+
+  overrides := append([]string{}, c.flagSet...)
+  if c.flagLogLevel != "" {
+    overrides = append(overrides, "logging.level="+c.flagLogLevel)
+  }
+  if c.flagCorsOverride != "" {
+    overrides = append(overrides, "cors-override="+c.flagCorsOverride)
+  }
+  flagLayer, err := server.OverrideLayer("command line", overrides)
+  if err != nil {
+    return nil, nil, err
+  }
+  layers = append(layers, flagLayer)
+
+  cfg, err := server.MergeConfigLayers(layers)
+  if err != nil {
+    return nil, nil, err
+  }
+  return layers, cfg, nil
+}
+
+// prints a configuration error (including source snippets when the error refers to a specific
+// configuration file)
+func printConfigError(err error) {
+  if fileErr, ok := err.(*server.FileError); ok {
+    fmt.Fprintf(os.Stderr, "error: failed to load configuration file \"%s\":\n\n", fileErr.Path)
+    fileErr.WriteDiagnostics(os.Stderr, 100, false)
+    return
+  }
+  fmt.Fprintf(os.Stderr, "error: %s\n", err)
+}
+
+func (s *settingOverrides) String() string {
+  return strings.Join(*s, ", ")
+}
+
+func (s *settingOverrides) Set(value string) error {
+  if !strings.ContainsRune(value, '=') {
+    return fmt.Errorf("expected key=value")
+  }
+  *s = append(*s, value)
+  return nil
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package command
+
+import (
+  "flag"
+  "fmt"
+  "os"
+
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/google/subcommands"
+  "golang.org/x/net/context"
+)
+
+type ConfigCommand struct {
+  ServerConfigCommand
+}
+
+func (*ConfigCommand) Name() string {
+  return "config"
+}
+
+func (*ConfigCommand) Synopsis() string {
+  return "validates or displays a server configuration"
+}
+
+func (*ConfigCommand) Usage() string {
+  return `Usage: stockpile config [options] <validate|show>
+
+This command loads a server configuration in the same way the server command would and reports any
+errors within it:
+
+  $ stockpile config -config=/etc/stockpile/config.hcl validate
+
+When "show" is passed, the effective configuration (e.g. the result of merging all configuration
+files on top of the defaults) is displayed along with the source of each setting:
+
+  $ stockpile config -config=/etc/stockpile/ -dev show
+
+Available command specific flags:
+
+`
+}
+
+func (c *ConfigCommand) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+  if f.NArg() != 1 || (f.Arg(0) != "validate" && f.Arg(0) != "show") {
+    fmt.Fprintf(os.Stderr, "illegal command invocation: expected either \"validate\" or \"show\"\n")
+    return 1
+  }
+
+  layers, cfg, err := c.loadConfig()
+  if err != nil {
+    printConfigError(err)
+    return 1
+  }
+
+  if f.Arg(0) == "validate" {
+    fmt.Fprintf(os.Stdout, "configuration is valid\n")
+    return 0
+  }
+
+  for _, setting := range server.DescribeConfig(cfg, layers) {
+    fmt.Fprintf(os.Stdout, "%-24s %-32s %s\n", setting.Name, setting.Value, setting.Source)
+  }
+  return 0
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package command
+
+import (
+  "flag"
+  "fmt"
+  "os"
+
+  "github.com/dotStart/Stockpile/stockpile/conformance"
+  "github.com/dotStart/Stockpile/stockpile/logs"
+  "github.com/dotStart/Stockpile/stockpile/plugin"
+  "github.com/dotStart/Stockpile/stockpile/server/service/servicetest"
+  "github.com/dotStart/Stockpile/stockpile/storage/storagetest"
+  "github.com/google/subcommands"
+  "golang.org/x/net/context"
+)
+
+type ConformanceCommand struct {
+  ServerConfigCommand
+  flagSkipExpiry bool
+}
+
+func (*ConformanceCommand) Name() string {
+  return "conformance"
+}
+
+func (*ConformanceCommand) Synopsis() string {
+  return "verifies the configured storage backend and server against the expected cache semantics"
+}
+
+func (*ConformanceCommand) Usage() string {
+  return `Usage: stockpile conformance [options] [storage|server]
+
+This command loads a server configuration in the same way the server command would and executes a
+set of conformance checks against the configured storage backend (including backends which are
+provided by plugins):
+
+  $ stockpile conformance -config=/etc/stockpile/config.hcl storage
+
+When "server" is passed, an in-process server is started on top of the configured storage backend
+and every RPC and event is exercised against a mock Mojang API. When no argument is passed, both
+sets of checks are executed:
+
+  $ stockpile conformance -dev
+
+Checks rely on random names and profile ids but will replace the cached blacklist. Do not run this
+command against the storage backend of a production deployment.
+
+Available command specific flags:
+
+`
+}
+
+func (c *ConformanceCommand) SetFlags(f *flag.FlagSet) {
+  c.ServerConfigCommand.SetFlags(f)
+  f.BoolVar(&c.flagSkipExpiry, "skip-expiry", false, "skips checks which wait for entries to expire")
+}
+
+func (c *ConformanceCommand) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+  suite := f.Arg(0)
+  if f.NArg() > 1 || (suite != "" && suite != "storage" && suite != "server") {
+    fmt.Fprintf(os.Stderr, "illegal command invocation: expected either \"storage\" or \"server\"\n")
+    return 1
+  }
+
+  _, cfg, err := c.loadConfig()
+  if err != nil {
+    printConfigError(err)
+    return 1
+  }
+
+  logFile, err := logs.Setup(cfg.Logging)
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "error: Failed to initialize logging: %s", err)
+    return 1
+  }
+  if logFile != nil {
+    defer logFile.Close()
+  }
+
+  pluginManager := plugin.NewManager(*cfg.PluginDir)
+  if cfg.PluginAllowlist != nil {
+    pluginManager.SetAllowlist(*cfg.PluginAllowlist)
+  }
+  pluginManager.Configure(cfg.Plugins)
+  pluginManager.LoadAll()
+  defer pluginManager.Shutdown()
+
+  storageFactory := pluginManager.Context.GetStorageBackend(cfg.Storage.Type)
+  if storageFactory == nil {
+    fmt.Fprintf(os.Stderr, "error: no such storage backend: %s\n", cfg.Storage.Type)
+    return 1
+  }
+
+  results := make([]*conformance.Result, 0)
+  if suite == "" || suite == "storage" {
+    fmt.Printf("==> Storage Backend (%s)\n\n", cfg.Storage.Type)
+    storageResults := storagetest.Run(cfg, storageFactory, storagetest.Options{
+      SkipExpiry: c.flagSkipExpiry,
+    })
+    printResults(storageResults)
+    results = append(results, storageResults...)
+  }
+
+  if suite == "" || suite == "server" {
+    fmt.Printf("==> Server\n\n")
+    backend, err := storageFactory(cfg)
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "error: failed to initialize storage backend \"%s\": %s\n", cfg.Storage.Type, err)
+      return 1
+    }
+    harness, err := servicetest.NewHarness(cfg, pluginManager, backend)
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "error: failed to start server: %s\n", err)
+      return 1
+    }
+    serverResults := harness.Run()
+    err = harness.Close()
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "error: failed to stop server: %s\n", err)
+    }
+    printResults(serverResults)
+    results = append(results, serverResults...)
+  }
+
+  err = conformance.Verify(results)
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "%s\n", err)
+    return 1
+  }
+  fmt.Printf("all %d check(s) passed\n", len(results))
+  return 0
+}
+
+// displays the outcome of a set of conformance checks
+func printResults(results []*conformance.Result) {
+  for _, result := range results {
+    if result.Passed() {
+      fmt.Printf("  PASS %-28s %s\n", result.Name, result.Duration)
+    } else {
+      fmt.Printf("  FAIL %-28s %s\n", result.Name, result.Err)
+    }
+  }
+  fmt.Printf("\n")
+}
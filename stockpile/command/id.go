@@ -31,6 +31,7 @@ type IdCommand struct {
   ClientCommand
 
   flagTimestamp string
+  flagOffline   bool
 }
 
 func (*IdCommand) Name() string {
@@ -59,6 +60,11 @@ In addition, you may also retrieve the profile identifiers of multiple names at
 
 Note that the current time will always be substituted when multiple names are passed.
 
+Identifiers of offline mode servers (which are derived from the respective names) may be retrieved
+using the offline flag:
+
+  $ stockpile get-id --offline dotStart MiniDigger
+
 Available command specific flags:
 
 `
@@ -67,6 +73,7 @@ Available command specific flags:
 func (c *IdCommand) SetFlags(f *flag.FlagSet) {
   c.ClientCommand.SetFlags(f)
   f.StringVar(&c.flagTimestamp, "time", "now", "defines the time at which this name should be resolved (for instance \""+time.RFC3339+"\"; may also be \"now\" for the current time or \"zero\" for the initial account name)")
+  f.BoolVar(&c.flagOffline, "offline", false, "retrieves the identifiers which offline mode servers assign to the passed names")
 }
 
 func (c *IdCommand) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -81,6 +88,33 @@ func (c *IdCommand) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface
     return 1
   }
 
+  if c.flagOffline {
+    at := time.Now()
+    profileIds := make([]*entity.ProfileId, 0, f.NArg())
+    for _, name := range f.Args() {
+      profileId, err := client.GetOfflineProfileId(name, at)
+      if err != nil {
+        fmt.Fprintf(os.Stderr, "command execution has failed: %s\n", err)
+        return 1
+      }
+      profileIds = append(profileIds, profileId)
+    }
+
+    if len(profileIds) == 1 {
+      writeTable(os.Stdout, *profileIds[0])
+      return 0
+    }
+    writeTable(
+      os.Stdout,
+      struct {
+        Ids []*entity.ProfileId
+      }{
+        profileIds,
+      },
+    )
+    return 0
+  }
+
   if f.NArg() == 1 {
     var timestamp time.Time
     if c.flagTimestamp == "now" {
@@ -79,6 +79,9 @@ func (c *ListenCommand) Execute(ctx context.Context, f *flag.FlagSet, _ ...inter
       entry = fmt.Sprintf("updated profile %s (display name: \"%s\")", profile.Id, profile.Name)
     case entity.BlacklistEvent:
       entry = fmt.Sprintf("updated blacklist")
+    case entity.ConfigEvent:
+      changes, _ := event.ConfigPayload()
+      entry = fmt.Sprintf("reloaded configuration (%d setting(s) changed)", len(changes.Changes))
     default:
       entry = "Unknown Event"
     }
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package command
+
+import (
+  "flag"
+  "fmt"
+  "os"
+  "sort"
+
+  "github.com/google/subcommands"
+  "golang.org/x/net/context"
+)
+
+type LogLevelCommand struct {
+  ClientCommand
+}
+
+func (*LogLevelCommand) Name() string {
+  return "log-level"
+}
+
+func (*LogLevelCommand) Synopsis() string {
+  return "displays or changes the log levels of a Stockpile server"
+}
+
+func (*LogLevelCommand) Usage() string {
+  return `Usage: stockpile log-level [options] [module] [level]
+
+This command displays the current log levels of a given Stockpile server:
+
+  $ stockpile log-level
+
+When a level is passed, the default log level is changed at runtime:
+
+  $ stockpile log-level debug
+
+When a module and level are passed, only the level of the respective module is changed:
+
+  $ stockpile log-level cache debug
+
+Available command specific flags:
+
+`
+}
+
+func (c *LogLevelCommand) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+  if f.NArg() > 2 {
+    fmt.Fprintf(os.Stderr, "illegal command invocation: expected at most two arguments\n")
+    return 1
+  }
+
+  client, err := c.createClient()
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "failed to establish a connection to server \"%s\": %s\n", c.flagServerAddress, err)
+    return 1
+  }
+
+  var levels map[string]string
+  switch f.NArg() {
+  case 0:
+    levels, err = client.GetLogLevels()
+  case 1:
+    levels, err = client.SetLogLevel("", f.Arg(0))
+  default:
+    levels, err = client.SetLogLevel(f.Arg(0), f.Arg(1))
+  }
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "failed to execute command: %s", err)
+    return 1
+  }
+
+  modules := make([]string, 0, len(levels))
+  for module := range levels {
+    if module != "" {
+      modules = append(modules, module)
+    }
+  }
+  sort.Strings(modules)
+
+  fmt.Fprintf(os.Stdout, "%-16s %s\n", "(default)", levels[""])
+  for _, module := range modules {
+    fmt.Fprintf(os.Stdout, "%-16s %s\n", module, levels[module])
+  }
+  return 0
+}
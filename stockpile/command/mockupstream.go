@@ -0,0 +1,154 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package command
+
+import (
+  "context"
+  "flag"
+  "fmt"
+  "net"
+  "net/http"
+  "os"
+  "os/signal"
+  "syscall"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/logs"
+  "github.com/dotStart/Stockpile/stockpile/mojang/mock"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/google/subcommands"
+  "github.com/op/go-logging"
+)
+
+type MockUpstreamCommand struct {
+  flagBindAddress     string
+  flagFixture         string
+  flagLatency         time.Duration
+  flagErrorRate       float64
+  flagErrorStatus     int
+  flagRateLimit       int
+  flagRateLimitPeriod time.Duration
+  flagLogLevel        string
+}
+
+func (*MockUpstreamCommand) Name() string {
+  return "mock-upstream"
+}
+
+func (*MockUpstreamCommand) Synopsis() string {
+  return "starts a mock Mojang API server for development and testing purposes"
+}
+
+func (*MockUpstreamCommand) Usage() string {
+  return `Usage: stockpile mock-upstream [options]
+
+This command starts a server which mimics the Mojang API and session server endpoints used by
+Stockpile. Responses are generated based on a fixture file (see docs/mock-fixture.json):
+
+  $ stockpile mock-upstream -fixture=docs/mock-fixture.json
+
+Stockpile may then be pointed at the mock server using the parameters of the mojang provider:
+
+  upstream "mojang" {
+    api-url = "http://127.0.0.1:36624"
+    session-url = "http://127.0.0.1:36624"
+  }
+
+In addition, the server may simulate latency, errors and rate limits:
+
+  $ stockpile mock-upstream -latency=250ms -error-rate=0.1 -rate-limit=600 -rate-limit-period=10m
+
+Available command specific flags:
+
+`
+}
+
+func (c *MockUpstreamCommand) SetFlags(f *flag.FlagSet) {
+  f.StringVar(&c.flagBindAddress, "bind-address", "127.0.0.1:36624", "specifies the address on which the mock server listens")
+  f.StringVar(&c.flagFixture, "fixture", "", "specifies a fixture file (an empty data set is served when omitted)")
+  f.DurationVar(&c.flagLatency, "latency", 0, "delays every response by the given duration")
+  f.Float64Var(&c.flagErrorRate, "error-rate", 0, "specifies the ratio of requests (between 0 and 1) which are answered with an error")
+  f.IntVar(&c.flagErrorStatus, "error-status", http.StatusInternalServerError, "specifies the status code of injected errors")
+  f.IntVar(&c.flagRateLimit, "rate-limit", 0, "specifies the amount of requests which are answered per period before status 429 is returned (unlimited when zero)")
+  f.DurationVar(&c.flagRateLimitPeriod, "rate-limit-period", 10*time.Minute, "specifies the period over which the rate limit is enforced")
+  f.StringVar(&c.flagLogLevel, "log-level", "info", "specifies a log level (requests are logged at debug level)")
+}
+
+func (c *MockUpstreamCommand) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+  if c.flagErrorRate < 0 || c.flagErrorRate > 1 {
+    fmt.Fprintf(os.Stderr, "illegal error rate: must be between 0 and 1\n")
+    return 1
+  }
+  if c.flagErrorStatus < 400 || c.flagErrorStatus > 599 {
+    fmt.Fprintf(os.Stderr, "illegal error status: must be an error status code\n")
+    return 1
+  }
+  if c.flagRateLimit < 0 || (c.flagRateLimit > 0 && c.flagRateLimitPeriod <= 0) {
+    fmt.Fprintf(os.Stderr, "illegal rate limit: limit and period must be positive\n")
+    return 1
+  }
+
+  _, err := logs.Setup(&server.LoggingConfig{Level: &c.flagLogLevel})
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "error: Failed to initialize logging: %s\n", err)
+    return 1
+  }
+  log := logging.MustGetLogger("stockpile")
+
+  fixture := mock.EmptyFixture()
+  if c.flagFixture != "" {
+    fixture, err = mock.LoadFixture(c.flagFixture)
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "error: %s\n", err)
+      return 1
+    }
+  }
+
+  listener, err := net.Listen("tcp", c.flagBindAddress)
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "error: failed to listen on %s (TCP): %s\n", c.flagBindAddress, err)
+    return 1
+  }
+
+  srv := &http.Server{
+    Handler: mock.New(fixture, mock.Options{
+      Latency:         c.flagLatency,
+      ErrorRate:       c.flagErrorRate,
+      ErrorStatus:     c.flagErrorStatus,
+      RateLimit:       c.flagRateLimit,
+      RateLimitPeriod: c.flagRateLimitPeriod,
+    }),
+  }
+  go func() {
+    err := srv.Serve(listener)
+    if err != nil && err != http.ErrServerClosed {
+      log.Fatalf("mock server has failed: %s", err)
+    }
+  }()
+  log.Infof("serving %d profile(s) on http://%s", len(fixture.Profiles), listener.Addr())
+
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+  defer signal.Stop(signals)
+  sig := <-signals
+  log.Infof("received signal %s - shutting down", sig)
+
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  srv.Shutdown(ctx)
+  return 0
+}
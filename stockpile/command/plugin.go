@@ -34,13 +34,14 @@ func (*PluginCommand) Name() string {
 }
 
 func (*PluginCommand) Synopsis() string {
-  return "displays a list of commands loaded on the Stockpile server"
+  return "displays the status of all plugins on the Stockpile server"
 }
 
 func (*PluginCommand) Usage() string {
   return `Usage: stockpile plugins [options]
 
-This command displays the list of loaded plugins on a given Stockpile server:
+This command displays the status of all plugins on a given Stockpile server (including plugins
+which failed to load along with their respective errors):
 
   $ stockpile plugins
 
@@ -62,7 +63,14 @@ func (c *PluginCommand) Execute(ctx context.Context, f *flag.FlagSet, _ ...inter
     return 1
   }
 
-  fmt.Fprintf(os.Stdout, "server has %d plugin(s) loaded:\n\n", len(pluginList))
+  failures := 0
+  for _, plugin := range pluginList {
+    if !plugin.Loaded {
+      failures++
+    }
+  }
+
+  fmt.Fprintf(os.Stdout, "server has %d plugin(s) loaded (%d failed to load):\n\n", len(pluginList)-failures, failures)
   for _, plugin := range pluginList {
     writeTable(os.Stdout, *plugin)
     fmt.Fprintf(os.Stdout, "\n")
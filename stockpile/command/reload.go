@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package command
+
+import (
+  "flag"
+  "fmt"
+  "os"
+
+  "github.com/google/subcommands"
+  "golang.org/x/net/context"
+)
+
+type ReloadCommand struct {
+  ClientCommand
+}
+
+func (*ReloadCommand) Name() string {
+  return "reload"
+}
+
+func (*ReloadCommand) Synopsis() string {
+  return "reloads the configuration of a Stockpile server"
+}
+
+func (*ReloadCommand) Usage() string {
+  return `Usage: stockpile reload [options]
+
+This command instructs a given Stockpile server to reload its configuration file(s) and displays
+the settings which have changed as a result:
+
+  $ stockpile reload
+
+Note that only a subset of settings may be changed at runtime (TTLs, rate limits, log levels and
+the CORS override). When any other setting has been changed, the reload is rejected and the server
+must be restarted instead.
+
+Available command specific flags:
+
+`
+}
+
+func (c *ReloadCommand) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+  if f.NArg() != 0 {
+    fmt.Fprintf(os.Stderr, "illegal command invocation: expected no arguments\n")
+    return 1
+  }
+
+  client, err := c.createClient()
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "failed to establish a connection to server \"%s\": %s\n", c.flagServerAddress, err)
+    return 1
+  }
+
+  changes, err := client.ReloadConfig()
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "failed to execute command: %s", err)
+    return 1
+  }
+
+  if len(changes) == 0 {
+    fmt.Fprintf(os.Stdout, "configuration reloaded - no settings have changed\n")
+    return 0
+  }
+  for _, change := range changes {
+    fmt.Fprintf(os.Stdout, "%s\n", change)
+  }
+  return 0
+}
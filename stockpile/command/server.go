@@ -23,25 +23,32 @@ import (
   "net"
   "net/http"
   "os"
+  "os/signal"
+  "strings"
+  "sync"
+  "syscall"
 
+  "github.com/dotStart/Stockpile/entity"
   "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/health"
+  "github.com/dotStart/Stockpile/stockpile/logs"
   "github.com/dotStart/Stockpile/stockpile/metadata"
-  "github.com/dotStart/Stockpile/stockpile/mojang"
+  "github.com/dotStart/Stockpile/stockpile/metrics"
   "github.com/dotStart/Stockpile/stockpile/plugin"
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
   "github.com/dotStart/Stockpile/stockpile/server"
   "github.com/dotStart/Stockpile/stockpile/server/legacy"
   "github.com/dotStart/Stockpile/stockpile/server/service"
   "github.com/dotStart/Stockpile/stockpile/server/ui"
+  "github.com/dotStart/Stockpile/stockpile/tracing"
   "github.com/google/subcommands"
   "github.com/op/go-logging"
+  "github.com/prometheus/client_golang/prometheus/promhttp"
   "github.com/soheilhy/cmux"
 )
 
 type ServerCommand struct {
-  flagConfig       string
-  flagDevelopment  bool
-  flagLogLevel     string
-  flagCorsOverride string
+  ServerConfigCommand
 }
 
 func (*ServerCommand) Name() string {
@@ -73,59 +80,47 @@ Available command specific flags:
 `
 }
 
-func (c *ServerCommand) SetFlags(f *flag.FlagSet) {
-  f.StringVar(&c.flagConfig, "config", "", "specifies a configuration file or directory")
-  f.BoolVar(&c.flagDevelopment, "dev", false, "enables development mode")
-  f.StringVar(&c.flagLogLevel, "log-level", "info", "specifies a log level")
-  f.StringVar(&c.flagCorsOverride, "cors-override", "", "specifies a host from which CORS requests are permitted")
-}
-
 func (c *ServerCommand) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-  var cfg *server.Config
-  if c.flagDevelopment {
-    cfg = server.DevelopmentConfig()
-  }
-  if c.flagConfig != "" {
-    fileCfg, err := server.LoadConfig(c.flagConfig)
-    if err != nil {
-      fmt.Fprintf(os.Stderr, "error: Failed to read the configuration file(s): %s", err)
-      return 1
-    }
-
-    if cfg == nil {
-      cfg = fileCfg
-    } else {
-      cfg.Merge(fileCfg)
-    }
-  } else if !c.flagDevelopment {
-    fmt.Fprintf(os.Stderr, "error: configuration file is required in production mode")
+  _, cfg, err := c.loadConfig()
+  if err != nil {
+    printConfigError(err)
     return 1
   }
 
-  level, err := logging.LogLevel(c.flagLogLevel)
+  logFile, err := logs.Setup(cfg.Logging)
   if err != nil {
-    fmt.Fprintf(os.Stderr, "error: Illegal log level \"%s\": %s", c.flagLogLevel, err)
+    fmt.Fprintf(os.Stderr, "error: Failed to initialize logging: %s", err)
     return 1
   }
-
-  format := logging.MustStringFormatter(`%{color}%{time:15:04:05.000} [%{level:.4s}] %{module} : %{color:reset} %{message}`)
-  backend := logging.AddModuleLevel(logging.NewBackendFormatter(logging.NewLogBackend(os.Stdout, "", 0), format))
-  backend.SetLevel(level, "")
-  logging.SetBackend(backend)
+  if logFile != nil {
+    defer logFile.Close()
+  }
 
   fmt.Printf("==> Stockpile Configuration\n\n")
   fmt.Printf("   Server Address: %s\n", *cfg.BindAddress)
   fmt.Printf("          Version: %s\n", metadata.VersionFull())
   fmt.Printf("      Commit Hash: %s\n", metadata.CommitHash())
-  fmt.Printf("        Log Level: %s\n", c.flagLogLevel)
+  fmt.Printf("        Log Level: %s\n", *cfg.Logging.Level)
+  fmt.Printf("       Log Format: %s\n", *cfg.Logging.Format)
+  if cfg.Logging.File != nil {
+    fmt.Printf("         Log File: %s\n", *cfg.Logging.File)
+  }
   fmt.Printf("  Storage Backend: %s\n", cfg.Storage.Type)
+  if cfg.Cluster != nil {
+    fmt.Printf("Cluster Transport: %s\n", cfg.Cluster.Type)
+  }
+  if cfg.Tracing != nil {
+    fmt.Printf("          Tracing: %s\n", cfg.Tracing.Endpoint)
+  }
+  fmt.Printf(" Rate Limit Store: %s\n", cfg.RateLimit.Type)
+  fmt.Printf("       Rate Limit: %d requests per %s\n", *cfg.RateLimit.Limit, cfg.RateLimit.Period)
   fmt.Printf("              PID: %d\n\n", os.Getpid())
 
   fmt.Printf("==> TTL Configuration\n\n")
   fmt.Printf("           Names: %s\n", cfg.Ttl.Name)
   fmt.Printf("         Profile: %s\n", cfg.Ttl.Profile)
   fmt.Printf("    Name History: %s\n", cfg.Ttl.NameHistory)
-  fmt.Printf("       Blacklist: %s\n\n", cfg.Ttl.Profile)
+  fmt.Printf("       Blacklist: %s\n\n", cfg.Ttl.Blacklist)
 
   var log = logging.MustGetLogger("stockpile")
 
@@ -141,8 +136,21 @@ func (c *ServerCommand) Execute(_ context.Context, f *flag.FlagSet, _ ...interfa
 
   mux := cmux.New(listener)
 
+  shutdownTracing, err := tracing.Setup(cfg)
+  if err != nil {
+    log.Fatalf("failed to initialize tracing: %s", err)
+  }
+  defer shutdownTracing(context.Background())
+  if cfg.Tracing != nil {
+    log.Infof("submitting traces to %s", cfg.Tracing.Endpoint)
+  }
+
   // initialize the plugin system and cache manager
   pluginManager := plugin.NewManager(*cfg.PluginDir)
+  if cfg.PluginAllowlist != nil {
+    pluginManager.SetAllowlist(*cfg.PluginAllowlist)
+  }
+  pluginManager.Configure(cfg.GetPlugins())
   pluginManager.LoadAll()
 
   storageFactory := pluginManager.Context.GetStorageBackend(cfg.Storage.Type)
@@ -154,49 +162,339 @@ func (c *ServerCommand) Execute(_ context.Context, f *flag.FlagSet, _ ...interfa
     log.Fatalf("failed to initialize storage backend \"%s\": %s", err)
   }
   log.Infof("using database plugin: %s", cfg.Storage.Type)
-  cacheImpl := cache.New(mojang.New(), storage)
 
-  // initialize the RPC server at all times (only differ between mux policies depending on whether the legacy API or UI
-  // is enabled)
+  rateLimitFactory := pluginManager.Context.GetRateLimitStore(cfg.RateLimit.Type)
+  if rateLimitFactory == nil {
+    log.Fatalf("no such rate limit store: %s", cfg.RateLimit.Type)
+  }
+  rateLimit, err := rateLimitFactory(cfg)
+  if err != nil {
+    log.Fatalf("failed to initialize rate limit store \"%s\": %s", cfg.RateLimit.Type, err)
+  }
+  log.Infof("using rate limit store: %s", cfg.RateLimit.Type)
+
+  upstreamFactory := pluginManager.Context.GetUpstreamProvider(cfg.Upstream.Type)
+  if upstreamFactory == nil {
+    log.Fatalf("no such upstream provider: %s", cfg.Upstream.Type)
+  }
+  upstream, err := upstreamFactory(cfg)
+  if err != nil {
+    log.Fatalf("failed to initialize upstream provider \"%s\": %s", cfg.Upstream.Type, err)
+  }
+  log.Infof("using upstream provider: %s", cfg.Upstream.Type)
+  cacheImpl := cache.New(upstream, storage, rateLimit)
+  cacheImpl.SetRateLimitEnforced(cfg.IsRateLimitEnforced())
+
+  if cfg.Cluster != nil {
+    busFactory := pluginManager.Context.GetEventBus(cfg.Cluster.Type)
+    if busFactory == nil {
+      log.Fatalf("no such cluster transport: %s", cfg.Cluster.Type)
+    }
+    bus, err := busFactory(cfg)
+    if err != nil {
+      log.Fatalf("failed to initialize cluster transport \"%s\": %s", cfg.Cluster.Type, err)
+    }
+    err = cacheImpl.JoinCluster(bus)
+    if err != nil {
+      log.Fatalf("failed to join cluster: %s", err)
+    }
+    log.Infof("using cluster transport: %s", cfg.Cluster.Type)
+  }
+
+  if *cfg.MetricsEnabled {
+    err = metrics.RegisterRateLimit(cacheImpl.GetRateLimitAllocation, cacheImpl.GetRateLimitCapacity)
+    if err != nil {
+      log.Fatalf("failed to register rate limit metrics: %s", err)
+    }
+  }
+
+  services := &plugin.Services{
+    Config: cfg,
+    Cache:  cacheImpl,
+  }
+  err = pluginManager.StartEventSinks(services)
+  if err != nil {
+    log.Fatalf("failed to initialize plugin event sinks: %s", err)
+  }
+
+  healthChecker := health.NewChecker()
+  healthChecker.Register("storage", true, cacheImpl.PingStorage)
+  healthChecker.Register("upstream", false, cacheImpl.PingUpstream)
+  healthChecker.Register("plugins", false, func() error {
+    if len(pluginManager.Failures) != 0 {
+      return fmt.Errorf("%d plugin(s) failed to load (first failure: %s: %s)", len(pluginManager.Failures), pluginManager.Failures[0].Path, pluginManager.Failures[0].Err)
+    }
+    return nil
+  })
+  healthChecker.Register("rate-limit", false, func() error {
+    allocation := cacheImpl.GetRateLimitAllocation()
+    capacity := cacheImpl.GetRateLimitCapacity()
+    if allocation >= capacity {
+      return fmt.Errorf("upstream request budget has been exhausted (%d of %d requests)", allocation, capacity)
+    }
+    return nil
+  })
+  go healthChecker.Run(health.DefaultInterval)
+  defer healthChecker.Close()
+
+  // initialize the RPC server at all times (only differ between mux policies depending on whether the legacy API, UI,
+  // metrics, health endpoints or plugin handlers are enabled)
+  pluginHandlers := pluginManager.Context.GetHttpHandlers()
+  httpEnabled := *cfg.UiEnabled || *cfg.LegacyApiEnabled || *cfg.MetricsEnabled || *cfg.HealthEnabled || len(pluginHandlers) != 0
   var grpcListener net.Listener
-  if *cfg.UiEnabled || *cfg.LegacyApiEnabled {
+  if httpEnabled {
     grpcListener = mux.MatchWithWriters(
       cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"),
     )
   } else {
     grpcListener = mux.Match(cmux.Any())
   }
-  rpcServer, err := service.NewServer(pluginManager, cacheImpl)
+  reloader := &configReloader{
+    logger: log,
+    load: func() (*server.Config, error) {
+      _, cfg, err := c.loadConfig()
+      return cfg, err
+    },
+    cfg:              cfg,
+    configurePlugins: pluginManager.Configure,
+    rateLimit:        rateLimit,
+    enforceRateLimit: cacheImpl.SetRateLimitEnforced,
+    publish:          cacheImpl.PublishConfigChange,
+  }
+  rpcServer, err := service.NewServer(pluginManager, services, healthChecker, reloader.Reload)
   if err != nil {
     log.Fatalf("failed to initialize grpc server: %s", err)
   }
   go rpcServer.Listen(grpcListener)
-  defer rpcServer.Destroy()
   log.Info("grpc server enabled")
 
-  if *cfg.UiEnabled || *cfg.LegacyApiEnabled {
+  // shutdown may be requested via a signal or via the legacy API while a configuration reload is
+  // requested via SIGHUP
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+  defer signal.Stop(signals)
+
+  reloadSignals := make(chan os.Signal, 1)
+  signal.Notify(reloadSignals, syscall.SIGHUP)
+  defer signal.Stop(reloadSignals)
+
+  shutdownRequested := make(chan struct{}, 1)
+  requestShutdown := func() {
+    select {
+    case shutdownRequested <- struct{}{}:
+    default:
+    }
+  }
+
+  var uiServer *ui.Server
+  var httpSrv *http.Server
+  if httpEnabled {
     httpMux := http.NewServeMux()
 
-    if c.flagCorsOverride != "" {
-      log.Warningf("CORS override configured: %s", c.flagCorsOverride)
+    corsOverride := cfg.GetCorsOverride()
+    if corsOverride != "" {
+      log.Warningf("CORS override configured: %s", corsOverride)
     }
 
     // instances currently unused
     if *cfg.LegacyApiEnabled {
-      legacy.NewServer(httpMux, cacheImpl)
+      legacy.NewServer(httpMux, cacheImpl, requestShutdown)
       log.Warningf("legacy api enabled")
     }
     if *cfg.UiEnabled {
-      ui.NewServer(httpMux, c.flagCorsOverride, pluginManager, cacheImpl)
+      uiServer, err = ui.NewServer(httpMux, corsOverride, pluginManager, cacheImpl)
+      if err != nil {
+        log.Fatalf("failed to initialize web ui: %s", err)
+      }
+      reloader.setUiServer(uiServer)
       log.Info("web ui enabled")
     }
+    if *cfg.MetricsEnabled {
+      httpMux.Handle("/metrics", promhttp.Handler())
+      log.Info("metrics endpoint enabled")
+    }
+    if *cfg.HealthEnabled {
+      healthChecker.RegisterHandlers(httpMux)
+      log.Info("health endpoints enabled")
+    }
+    for pattern, factory := range pluginHandlers {
+      handler, err := factory(services)
+      if err != nil {
+        log.Fatalf("failed to initialize plugin handler for pattern \"%s\": %s", pattern, err)
+      }
+      httpMux.Handle(pattern, handler)
+      log.Infof("plugin handler enabled: %s", pattern)
+    }
 
-    httpSrv := &http.Server{
-      Handler: httpMux,
+    httpSrv = &http.Server{
+      Handler: logs.Handler(httpMux),
     }
     go httpSrv.Serve(mux.Match(cmux.Any()))
   }
 
-  mux.Serve()
+  go mux.Serve()
+
+running:
+  for {
+    select {
+    case <-reloadSignals:
+      log.Infof("received signal SIGHUP - reloading configuration")
+      _, err := reloader.Reload()
+      if err != nil {
+        log.Errorf("failed to reload configuration: %s", err)
+      }
+    case sig := <-signals:
+      log.Infof("received signal %s - shutting down", sig)
+      break running
+    case <-shutdownRequested:
+      log.Infof("shutting down")
+      break running
+    }
+  }
+
+  // report the instance as unavailable and drain all in-flight requests before the cache (and
+  // thus the storage backend) is closed
+  healthChecker.Shutdown()
+  ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+  defer cancel()
+
+  var wg sync.WaitGroup
+  wg.Add(1)
+  go func() {
+    defer wg.Done()
+    err := rpcServer.Stop(ctx)
+    if err != nil {
+      log.Warningf("failed to drain grpc calls: %s", err)
+    }
+  }()
+  if httpSrv != nil {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      err := httpSrv.Shutdown(ctx)
+      if err != nil {
+        log.Warningf("failed to drain http requests: %s", err)
+        httpSrv.Close()
+      }
+    }()
+  }
+  wg.Wait()
+  listener.Close()
+
+  // calls which have been aborted (as well as cluster and plugin activity) may leave refreshes
+  // behind which are given the remainder of the timeout before the storage backend is closed
+  err = cacheImpl.Drain(ctx)
+  if err != nil {
+    log.Warningf("failed to drain pending cache refreshes: %s", err)
+  }
+
+  if uiServer != nil {
+    uiServer.Close()
+  }
+  err = cacheImpl.Close()
+  if err != nil {
+    log.Errorf("failed to close cache: %s", err)
+  }
+  pluginManager.Shutdown()
+
+  log.Info("shutdown complete")
   return 0
 }
+
+// applies configuration changes to a running server
+type configReloader struct {
+  logger           *logging.Logger
+  mutex            sync.Mutex
+  load             func() (*server.Config, error)
+  cfg              *server.Config
+  configurePlugins func(cfgs []*server.PluginConfig) error
+  rateLimit        ratelimit.Store
+  enforceRateLimit func(enforced bool)
+  publish          func(changes []*entity.ConfigChange)
+  ui               *ui.Server
+}
+
+// registers the web ui server in order to pass CORS changes to it
+func (r *configReloader) setUiServer(srv *ui.Server) {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+  r.ui = srv
+}
+
+// reloads the configuration and applies all changed settings
+// the configuration is left untouched when any of the changed settings require a restart, when
+// any of the new settings are invalid or when any of them cannot be applied
+func (r *configReloader) Reload() ([]*entity.ConfigChange, error) {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  cfg, err := r.load()
+  if err != nil {
+    return nil, err
+  }
+
+  changes, fixed := r.cfg.Compare(cfg)
+  if len(fixed) != 0 {
+    diffs := make([]string, len(fixed))
+    for i, change := range fixed {
+      diffs[i] = change.String()
+    }
+    return nil, fmt.Errorf("cannot apply changes to settings which require a restart: %s", strings.Join(diffs, ", "))
+  }
+  err = cfg.Validate()
+  if err != nil {
+    return nil, fmt.Errorf("illegal configuration: %s", err)
+  }
+
+  // settings are applied in a fixed order and reverted in reverse order when one of the later
+  // steps fails so that the previous configuration remains in effect as a whole
+  rollbacks := make([]func() error, 0)
+  rollback := func(cause error) error {
+    for i := len(rollbacks) - 1; i >= 0; i-- {
+      if err := rollbacks[i](); err != nil {
+        r.logger.Errorf("failed to restore previous configuration: %s", err)
+      }
+    }
+    return cause
+  }
+
+  // plugin parameters are opaque to us so plugins are reconfigured on every reload
+  previousPlugins := r.cfg.GetPlugins()
+  rollbacks = append(rollbacks, func() error {
+    return r.configurePlugins(previousPlugins)
+  })
+  err = r.configurePlugins(cfg.Plugins)
+  if err != nil {
+    return nil, rollback(fmt.Errorf("failed to reconfigure plugins: %s", err))
+  }
+  if len(changes) == 0 {
+    r.logger.Info("configuration reloaded - no settings have changed")
+    return changes, nil
+  }
+
+  previousLimit, previousPeriod := *r.cfg.RateLimit.Limit, r.cfg.RateLimit.Period
+  err = r.rateLimit.Reconfigure(*cfg.RateLimit.Limit, cfg.RateLimit.Period)
+  if err != nil {
+    return nil, rollback(fmt.Errorf("failed to reconfigure rate limit: %s", err))
+  }
+  rollbacks = append(rollbacks, func() error {
+    return r.rateLimit.Reconfigure(previousLimit, previousPeriod)
+  })
+
+  err = logs.Reload(cfg.Logging)
+  if err != nil {
+    return nil, rollback(fmt.Errorf("failed to reconfigure logging: %s", err))
+  }
+
+  r.cfg.Apply(cfg)
+  r.enforceRateLimit(r.cfg.IsRateLimitEnforced())
+  if r.ui != nil {
+    r.ui.SetCorsOverride(r.cfg.GetCorsOverride())
+  }
+
+  for _, change := range changes {
+    r.logger.Noticef("configuration changed: %s", change)
+  }
+  r.publish(changes)
+  return changes, nil
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package command
+
+import (
+  "errors"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/logs"
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/op/go-logging"
+)
+
+// defines the configuration which is in effect before a reload
+const reloadBaseConfig = `storage "mem" {}
+
+rate-limit "local" {
+  limit = 600
+  period = "10m"
+}
+
+logging {
+  level = "info"
+}
+`
+
+// defines the configuration which is loaded upon reload
+const reloadNextConfig = `storage "mem" {}
+
+rate-limit "local" {
+  limit = 100
+  period = "1m"
+}
+
+logging {
+  level = "debug"
+}
+
+plugin "example" {
+  enabled = true
+}
+`
+
+// simulates a rate limit store which rejects all changes to its parameters
+type failingRateLimitStore struct {
+  ratelimit.Store
+  reconfigured int
+}
+
+func (s *failingRateLimitStore) Reconfigure(limit int, period time.Duration) error {
+  s.reconfigured++
+  return errors.New("store unavailable")
+}
+
+// records the plugin configurations and config changes which are passed on by a reloader
+type reloadRecorder struct {
+  plugins [][]*server.PluginConfig
+  changes [][]*entity.ConfigChange
+}
+
+// creates a reloader which replaces a given base configuration with the passed configuration
+func newTestReloader(t *testing.T, next *server.Config, rateLimit ratelimit.Store) (*configReloader, *server.Config, *reloadRecorder) {
+  cfg := servertest.LoadConfig(t, reloadBaseConfig)
+  _, err := logs.Setup(cfg.Logging)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  recorder := &reloadRecorder{}
+  return &configReloader{
+    logger: logging.MustGetLogger("test"),
+    load: func() (*server.Config, error) {
+      return next, nil
+    },
+    cfg: cfg,
+    configurePlugins: func(cfgs []*server.PluginConfig) error {
+      recorder.plugins = append(recorder.plugins, cfgs)
+      return nil
+    },
+    rateLimit:        rateLimit,
+    enforceRateLimit: func(enforced bool) {},
+    publish: func(changes []*entity.ConfigChange) {
+      recorder.changes = append(recorder.changes, changes)
+    },
+  }, cfg, recorder
+}
+
+func TestConfigReloaderReload(t *testing.T) {
+  next := servertest.LoadConfig(t, reloadNextConfig)
+  rateLimit, err := ratelimit.NewLocalStore(next)
+  if err != nil {
+    t.Fatal(err)
+  }
+  reloader, cfg, recorder := newTestReloader(t, next, rateLimit)
+
+  changes, err := reloader.Reload()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(changes) == 0 || len(recorder.changes) != 1 {
+    t.Fatalf("expected changes to be reported and published once but got %d change(s) and %d publication(s)", len(changes), len(recorder.changes))
+  }
+  if *cfg.RateLimit.Limit != 100 || cfg.RateLimit.Period != time.Minute || *cfg.GetLogging().Level != "debug" {
+    t.Errorf("expected new settings to be applied")
+  }
+  if rateLimit.Capacity() != 100 {
+    t.Errorf("expected rate limit capacity of 100 but got %d", rateLimit.Capacity())
+  }
+  if len(recorder.plugins) != 1 || len(recorder.plugins[0]) != 1 {
+    t.Errorf("expected plugins to be configured once with the new configuration")
+  }
+}
+
+// invalid settings are rejected before any of the settings are applied
+func TestConfigReloaderReloadInvalid(t *testing.T) {
+  next := servertest.LoadConfig(t, reloadNextConfig)
+  level := "verbose"
+  next.Logging.Level = &level
+  rateLimit := &failingRateLimitStore{}
+  reloader, cfg, recorder := newTestReloader(t, next, rateLimit)
+
+  _, err := reloader.Reload()
+  if err == nil {
+    t.Fatal("expected illegal log level to be rejected")
+  }
+  if len(recorder.plugins) != 0 || rateLimit.reconfigured != 0 || len(recorder.changes) != 0 {
+    t.Errorf("expected no settings to be applied but got %d plugin reconfiguration(s) and %d rate limit reconfiguration(s)", len(recorder.plugins), rateLimit.reconfigured)
+  }
+  if *cfg.GetLogging().Level != "info" {
+    t.Errorf("expected log level to remain unchanged but got %s", *cfg.GetLogging().Level)
+  }
+}
+
+// settings which have already been applied are reverted when a later step fails
+func TestConfigReloaderReloadRollback(t *testing.T) {
+  next := servertest.LoadConfig(t, reloadNextConfig)
+  rateLimit := &failingRateLimitStore{}
+  reloader, cfg, recorder := newTestReloader(t, next, rateLimit)
+
+  _, err := reloader.Reload()
+  if err == nil {
+    t.Fatal("expected rate limit failure to be reported")
+  }
+  if len(recorder.plugins) != 2 || len(recorder.plugins[0]) != 1 || len(recorder.plugins[1]) != 0 {
+    t.Fatalf("expected plugins to be configured with the new configuration and restored afterwards but got %d configuration(s)", len(recorder.plugins))
+  }
+  if len(recorder.changes) != 0 {
+    t.Errorf("expected no changes to be published")
+  }
+  if *cfg.RateLimit.Limit != 600 || *cfg.GetLogging().Level != "info" {
+    t.Errorf("expected previous settings to remain in effect")
+  }
+  if level := logging.GetLevel(""); level != logging.INFO {
+    t.Errorf("expected default log level to remain INFO but got %s", level)
+  }
+}
+
+// tracing settings are only applied upon startup and thus cannot be reloaded
+func TestConfigReloaderReloadTracing(t *testing.T) {
+  next := servertest.LoadConfig(t, reloadNextConfig+`
+tracing {
+  endpoint = "localhost:4318"
+  sample-ratio = 0.25
+}
+`)
+  rateLimit := &failingRateLimitStore{}
+  reloader, cfg, recorder := newTestReloader(t, next, rateLimit)
+  ratio := 0.5
+  cfg.Tracing = &server.TracingConfig{Endpoint: "localhost:4318", SampleRatio: &ratio}
+
+  _, err := reloader.Reload()
+  if err == nil || !strings.Contains(err.Error(), "tracing.sample-ratio") {
+    t.Fatalf("expected sample ratio change to be rejected but got: %v", err)
+  }
+  if len(recorder.plugins) != 0 || rateLimit.reconfigured != 0 || len(recorder.changes) != 0 {
+    t.Errorf("expected no settings to be applied")
+  }
+  if *cfg.Tracing.SampleRatio != 0.5 || *cfg.GetLogging().Level != "info" {
+    t.Errorf("expected previous settings to remain in effect")
+  }
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package command
+
+import (
+  "flag"
+  "fmt"
+  "os"
+
+  "github.com/google/subcommands"
+  "golang.org/x/net/context"
+)
+
+type TimelineCommand struct {
+  ClientCommand
+}
+
+func (*TimelineCommand) Name() string {
+  return "name-timeline"
+}
+
+func (*TimelineCommand) Synopsis() string {
+  return "queries the ownership timeline of a name"
+}
+
+func (*TimelineCommand) Usage() string {
+  return `Usage: stockpile name-timeline [options] <name>
+
+This command retrieves all known periods during which a name has been assigned to a profile from a
+Stockpile server:
+
+  $ stockpile name-timeline dotStart
+
+Periods without an end refer to the current owner of the name.
+
+Available command specific flags:
+
+`
+}
+
+func (c *TimelineCommand) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+  client, err := c.createClient()
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "failed to establish a connection to server \"%s\": %s\n", c.flagServerAddress, err)
+    return 1
+  }
+
+  if f.NArg() != 1 {
+    fmt.Fprintf(os.Stderr, "illegal command invocation: name is required\n")
+    return 1
+  }
+
+  timeline, err := client.GetNameTimeline(f.Arg(0))
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "command execution has failed: %s\n", err)
+    return 1
+  }
+
+  if len(timeline.Periods) == 0 {
+    fmt.Fprintf(os.Stderr, "no known owners\n")
+    return 1
+  }
+  writeTable(os.Stdout, *timeline)
+  return 0
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package conformance
+
+import (
+  "fmt"
+  "strings"
+  "time"
+)
+
+// represents a single conformance check
+type Check struct {
+  Name string
+  Run  func() error
+}
+
+// represents the outcome of a single conformance check
+type Result struct {
+  Name     string
+  Duration time.Duration
+  Err      error
+}
+
+// evaluates whether the check has passed
+func (r *Result) Passed() bool {
+  return r.Err == nil
+}
+
+// executes a set of checks in order
+// checks which panic are reported as failures instead of aborting the remaining checks
+func Run(checks []*Check) []*Result {
+  results := make([]*Result, len(checks))
+  for i, check := range checks {
+    start := time.Now()
+    err := run(check)
+    results[i] = &Result{
+      Name:     check.Name,
+      Duration: time.Since(start),
+      Err:      err,
+    }
+  }
+  return results
+}
+
+// executes a single check while recovering from panics
+func run(check *Check) (err error) {
+  defer func() {
+    if r := recover(); r != nil {
+      err = fmt.Errorf("panic: %v", r)
+    }
+  }()
+  return check.Run()
+}
+
+// summarizes a set of results into a single error (nil when all checks have passed)
+func Verify(results []*Result) error {
+  failures := make([]string, 0)
+  for _, result := range results {
+    if !result.Passed() {
+      failures = append(failures, fmt.Sprintf("%s: %s", result.Name, result.Err))
+    }
+  }
+  if len(failures) == 0 {
+    return nil
+  }
+  return fmt.Errorf("%d of %d check(s) failed:\n  %s", len(failures), len(results), strings.Join(failures, "\n  "))
+}
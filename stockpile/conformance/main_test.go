@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package conformance
+
+import (
+  "errors"
+  "strings"
+  "testing"
+)
+
+func TestRunRecoversPanics(t *testing.T) {
+  results := Run([]*Check{
+    {Name: "passing", Run: func() error { return nil }},
+    {Name: "failing", Run: func() error { return errors.New("expected failure") }},
+    {Name: "panicking", Run: func() error { panic("expected panic") }},
+  })
+
+  if len(results) != 3 {
+    t.Fatalf("expected 3 results but got %d", len(results))
+  }
+  if !results[0].Passed() {
+    t.Errorf("expected check \"passing\" to pass but got %s", results[0].Err)
+  }
+  if results[1].Passed() || results[1].Err.Error() != "expected failure" {
+    t.Errorf("expected check \"failing\" to report its error but got %v", results[1].Err)
+  }
+  if results[2].Passed() || !strings.Contains(results[2].Err.Error(), "expected panic") {
+    t.Errorf("expected check \"panicking\" to report its panic but got %v", results[2].Err)
+  }
+}
+
+func TestVerify(t *testing.T) {
+  passed := &Result{Name: "passed"}
+  failed := &Result{Name: "failed", Err: errors.New("broken")}
+
+  if err := Verify([]*Result{passed}); err != nil {
+    t.Errorf("expected no error but got %s", err)
+  }
+
+  err := Verify([]*Result{passed, failed})
+  if err == nil {
+    t.Fatal("expected an error")
+  }
+  if !strings.HasPrefix(err.Error(), "1 of 2 check(s) failed:") || !strings.Contains(err.Error(), "failed: broken") {
+    t.Errorf("unexpected summary: %s", err)
+  }
+}
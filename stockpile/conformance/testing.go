@@ -0,0 +1,35 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package conformance
+
+import (
+  "testing"
+)
+
+// executes a set of checks as subtests of a given go test
+// checks are executed in order as some suites rely upon the state created by previous checks
+func Test(t *testing.T, checks []*Check) {
+  for _, check := range checks {
+    check := check
+    t.Run(check.Name, func(t *testing.T) {
+      err := run(check)
+      if err != nil {
+        t.Fatal(err)
+      }
+    })
+  }
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package health
+
+import (
+  "encoding/json"
+  "net/http"
+)
+
+// registers the liveness (/healthz) and readiness (/readyz) endpoints with a given mux
+func (c *Checker) RegisterHandlers(httpMux *http.ServeMux) {
+  httpMux.HandleFunc("/healthz", c.handleLiveness)
+  httpMux.HandleFunc("/readyz", c.handleReadiness)
+}
+
+// reports the health of all components while the process is capable of serving requests at all
+func (c *Checker) handleLiveness(w http.ResponseWriter, r *http.Request) {
+  writeReport(w, http.StatusOK, c.Check())
+}
+
+// reports the health of all components and signals whether the application is ready to serve
+// requests
+func (c *Checker) handleReadiness(w http.ResponseWriter, r *http.Request) {
+  report := c.Check()
+  code := http.StatusOK
+  if !report.Ready() {
+    code = http.StatusServiceUnavailable
+  }
+  writeReport(w, code, report)
+}
+
+func writeReport(w http.ResponseWriter, code int, report *Report) {
+  enc, err := json.Marshal(report)
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusInternalServerError)
+    return
+  }
+
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(code)
+  w.Write(enc)
+}
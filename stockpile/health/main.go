@@ -0,0 +1,205 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package health
+
+import (
+  "sort"
+  "sync"
+  "time"
+
+  "github.com/op/go-logging"
+  "google.golang.org/grpc/health"
+  healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// defines the interval in which the gRPC health status is refreshed
+const DefaultInterval = 10 * time.Second
+
+// identifies the health of a component (or the application as a whole)
+type Status string
+
+const (
+  // the component is fully operational
+  StatusOk Status = "ok"
+  // the component is impaired but requests may still be served (e.g. from the cache)
+  StatusDegraded Status = "degraded"
+  // the component is unable to serve requests
+  StatusUnavailable Status = "unavailable"
+)
+
+// evaluates the health of a component and returns a descriptive error when it is impaired
+type Check = func() error
+
+// describes the health of a single component
+type ComponentReport struct {
+  Status  Status `json:"status"`
+  Message string `json:"message,omitempty"`
+}
+
+// describes the health of all registered components
+type Report struct {
+  Status     Status                      `json:"status"`
+  Components map[string]*ComponentReport `json:"components"`
+}
+
+// evaluates whether the reported application is able to serve requests
+func (r *Report) Ready() bool {
+  return r.Status != StatusUnavailable
+}
+
+type component struct {
+  name     string
+  critical bool
+  check    Check
+}
+
+// aggregates the health of a set of components and reports it via the standard gRPC health
+// protocol
+type Checker struct {
+  logger     *logging.Logger
+  mutex      sync.RWMutex
+  components []*component
+  grpc       *health.Server
+  shutdown   chan struct{}
+  stopping   bool
+}
+
+// creates a new empty health checker
+func NewChecker() *Checker {
+  return &Checker{
+    logger:   logging.MustGetLogger("health"),
+    grpc:     health.NewServer(),
+    shutdown: make(chan struct{}),
+  }
+}
+
+// registers a component with the checker
+// failing critical components render the application unavailable while all other components
+// merely degrade its health
+func (c *Checker) Register(name string, critical bool, check Check) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+
+  c.components = append(c.components, &component{
+    name:     name,
+    critical: critical,
+    check:    check,
+  })
+  sort.Slice(c.components, func(i, j int) bool {
+    return c.components[i].name < c.components[j].name
+  })
+}
+
+// evaluates the health of all registered components
+func (c *Checker) Check() *Report {
+  c.mutex.RLock()
+  defer c.mutex.RUnlock()
+
+  report := &Report{
+    Status:     StatusOk,
+    Components: make(map[string]*ComponentReport),
+  }
+  if c.stopping {
+    report.Status = StatusUnavailable
+    report.Components["server"] = &ComponentReport{
+      Status:  StatusUnavailable,
+      Message: "server is shutting down",
+    }
+  }
+  for _, comp := range c.components {
+    err := comp.check()
+    if err == nil {
+      report.Components[comp.name] = &ComponentReport{
+        Status: StatusOk,
+      }
+      continue
+    }
+
+    status := StatusDegraded
+    if comp.critical {
+      status = StatusUnavailable
+    }
+    report.Components[comp.name] = &ComponentReport{
+      Status:  status,
+      Message: err.Error(),
+    }
+    if report.Status != StatusUnavailable {
+      report.Status = status
+    }
+  }
+  return report
+}
+
+// retrieves the gRPC health service which reports the health of this checker
+func (c *Checker) GrpcServer() healthpb.HealthServer {
+  return c.grpc
+}
+
+// refreshes the status which is reported via gRPC
+// the application as a whole is identified by an empty service name while components are
+// identified by their respective names
+func (c *Checker) refresh() *Report {
+  report := c.Check()
+  c.grpc.SetServingStatus("", servingStatus(report.Ready()))
+  for name, comp := range report.Components {
+    c.grpc.SetServingStatus(name, servingStatus(comp.Status == StatusOk))
+  }
+  return report
+}
+
+// periodically refreshes the status which is reported via gRPC until the checker is closed
+func (c *Checker) Run(interval time.Duration) {
+  previous := c.refresh().Status
+
+  ticker := time.NewTicker(interval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ticker.C:
+      report := c.refresh()
+      if report.Status != previous {
+        c.logger.Warningf("health has changed from %s to %s", previous, report.Status)
+        previous = report.Status
+      }
+    case <-c.shutdown:
+      return
+    }
+  }
+}
+
+// marks the application as unavailable in order to divert new requests to other instances while
+// in-flight requests are drained
+func (c *Checker) Shutdown() {
+  c.mutex.Lock()
+  c.stopping = true
+  c.mutex.Unlock()
+
+  c.grpc.Shutdown()
+}
+
+// stops refreshing the gRPC health status
+func (c *Checker) Close() error {
+  close(c.shutdown)
+  return nil
+}
+
+func servingStatus(serving bool) healthpb.HealthCheckResponse_ServingStatus {
+  if serving {
+    return healthpb.HealthCheckResponse_SERVING
+  }
+  return healthpb.HealthCheckResponse_NOT_SERVING
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package health_test
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "sync"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/health"
+  healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// creates a check which reports the passed error
+func staticCheck(err error) health.Check {
+  return func() error {
+    return err
+  }
+}
+
+// provides a check whose result may be replaced while it is evaluated by other routines
+type mutableCheck struct {
+  mutex sync.Mutex
+  err   error
+}
+
+func (c *mutableCheck) Set(err error) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  c.err = err
+}
+
+func (c *mutableCheck) Check() error {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  return c.err
+}
+
+func TestCheck(t *testing.T) {
+  failure := errors.New("connection refused")
+  tests := []struct {
+    name     string
+    critical error
+    optional error
+    expected health.Status
+  }{
+    {"ok", nil, nil, health.StatusOk},
+    {"degraded", nil, failure, health.StatusDegraded},
+    {"unavailable", failure, nil, health.StatusUnavailable},
+    {"unavailable-and-degraded", failure, failure, health.StatusUnavailable},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      checker := health.NewChecker()
+      checker.Register("storage", true, staticCheck(test.critical))
+      checker.Register("upstream", false, staticCheck(test.optional))
+
+      report := checker.Check()
+      if report.Status != test.expected {
+        t.Errorf("expected status %s but got %s", test.expected, report.Status)
+      }
+      if report.Ready() != (test.expected != health.StatusUnavailable) {
+        t.Errorf("expected readiness to be %v", !report.Ready())
+      }
+
+      storage := report.Components["storage"]
+      if storage == nil {
+        t.Fatal("expected storage component to be reported")
+      }
+      if test.critical != nil && (storage.Status != health.StatusUnavailable || storage.Message != failure.Error()) {
+        t.Errorf("expected failing critical component to be reported as unavailable but got %+v", storage)
+      }
+      upstream := report.Components["upstream"]
+      if upstream == nil {
+        t.Fatal("expected upstream component to be reported")
+      }
+      if test.optional != nil && (upstream.Status != health.StatusDegraded || upstream.Message != failure.Error()) {
+        t.Errorf("expected failing optional component to be reported as degraded but got %+v", upstream)
+      }
+    })
+  }
+}
+
+func TestHandlers(t *testing.T) {
+  storage := &mutableCheck{}
+  upstream := &mutableCheck{}
+  checker := health.NewChecker()
+  checker.Register("storage", true, storage.Check)
+  checker.Register("upstream", false, upstream.Check)
+
+  mux := http.NewServeMux()
+  checker.RegisterHandlers(mux)
+  srv := httptest.NewServer(mux)
+  defer srv.Close()
+
+  tests := []struct {
+    name      string
+    storage   error
+    upstream  error
+    stopping  bool
+    status    health.Status
+    readiness int
+  }{
+    {"ok", nil, nil, false, health.StatusOk, http.StatusOK},
+    {"degraded", nil, errors.New("circuit breaker is open"), false, health.StatusDegraded, http.StatusOK},
+    {"unavailable", errors.New("connection refused"), nil, false, health.StatusUnavailable, http.StatusServiceUnavailable},
+    {"shutdown", nil, nil, true, health.StatusUnavailable, http.StatusServiceUnavailable},
+  }
+
+  // test cases are executed in order as shutdowns cannot be reverted
+  for _, test := range tests {
+    storage.Set(test.storage)
+    upstream.Set(test.upstream)
+    if test.stopping {
+      checker.Shutdown()
+    }
+
+    for path, expected := range map[string]int{"/healthz": http.StatusOK, "/readyz": test.readiness} {
+      res, err := http.Get(srv.URL + path)
+      if err != nil {
+        t.Fatal(err)
+      }
+      report := &health.Report{}
+      err = json.NewDecoder(res.Body).Decode(report)
+      res.Body.Close()
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if res.StatusCode != expected {
+        t.Errorf("%s: expected %s to respond with status %d but got %d", test.name, path, expected, res.StatusCode)
+      }
+      if report.Status != test.status {
+        t.Errorf("%s: expected %s to report status %s but got %s", test.name, path, test.status, report.Status)
+      }
+    }
+  }
+}
+
+func TestGrpcServer(t *testing.T) {
+  storage := &mutableCheck{}
+  checker := health.NewChecker()
+  checker.Register("storage", true, storage.Check)
+  checker.Register("upstream", false, staticCheck(errors.New("circuit breaker is open")))
+
+  go checker.Run(time.Millisecond)
+  defer checker.Close()
+
+  // waits for the reported status of a given service to reach an expected value
+  await := func(service string, expected healthpb.HealthCheckResponse_ServingStatus) {
+    deadline := time.Now().Add(time.Second)
+    for {
+      res, err := checker.GrpcServer().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+      if err == nil && res.Status == expected {
+        return
+      }
+      if time.Now().After(deadline) {
+        t.Fatalf("expected service \"%s\" to report %s but got %v (%v)", service, expected, res, err)
+      }
+      time.Sleep(time.Millisecond)
+    }
+  }
+
+  // degraded components are reported individually while the application remains serving
+  await("", healthpb.HealthCheckResponse_SERVING)
+  await("storage", healthpb.HealthCheckResponse_SERVING)
+  await("upstream", healthpb.HealthCheckResponse_NOT_SERVING)
+
+  storage.Set(errors.New("connection refused"))
+  await("", healthpb.HealthCheckResponse_NOT_SERVING)
+  await("storage", healthpb.HealthCheckResponse_NOT_SERVING)
+}
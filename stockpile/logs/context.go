@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package logs
+
+import (
+  "context"
+
+  "github.com/google/uuid"
+  "github.com/op/go-logging"
+)
+
+// identifies the request id within a context
+type requestIdKey struct{}
+
+// marks the request id which is passed as the first argument of all messages logged through a
+// context logger
+type requestIdArg string
+
+func (r requestIdArg) String() string {
+  return "[" + string(r) + "] "
+}
+
+// generates a new random request id
+func NewRequestId() string {
+  return uuid.New().String()
+}
+
+// creates a copy of the passed context which carries a given request id
+func WithRequestId(ctx context.Context, id string) context.Context {
+  return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// retrieves the request id which is carried by the passed context (or an empty string if no
+// request id has been assigned)
+func RequestId(ctx context.Context) string {
+  id, _ := ctx.Value(requestIdKey{}).(string)
+  return id
+}
+
+// provides a logger which prefixes all messages with the request id of a given context in order
+// to permit the correlation of messages which have been logged by different modules
+type ContextLogger struct {
+  logger    *logging.Logger
+  requestId string
+}
+
+// creates a logger which annotates all messages with the request id of the passed context
+func ForContext(ctx context.Context, logger *logging.Logger) *ContextLogger {
+  return &ContextLogger{
+    logger:    logger,
+    requestId: RequestId(ctx),
+  }
+}
+
+// prepends the request id to a message format and its arguments
+func (l *ContextLogger) annotate(format string, args []interface{}) (string, []interface{}) {
+  if l.requestId == "" {
+    return format, args
+  }
+
+  return "%s" + format, append([]interface{}{requestIdArg(l.requestId)}, args...)
+}
+
+func (l *ContextLogger) Debugf(format string, args ...interface{}) {
+  format, args = l.annotate(format, args)
+  l.logger.Debugf(format, args...)
+}
+
+func (l *ContextLogger) Infof(format string, args ...interface{}) {
+  format, args = l.annotate(format, args)
+  l.logger.Infof(format, args...)
+}
+
+func (l *ContextLogger) Noticef(format string, args ...interface{}) {
+  format, args = l.annotate(format, args)
+  l.logger.Noticef(format, args...)
+}
+
+func (l *ContextLogger) Warningf(format string, args ...interface{}) {
+  format, args = l.annotate(format, args)
+  l.logger.Warningf(format, args...)
+}
+
+func (l *ContextLogger) Errorf(format string, args ...interface{}) {
+  format, args = l.annotate(format, args)
+  l.logger.Errorf(format, args...)
+}
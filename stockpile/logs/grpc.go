@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package logs
+
+import (
+  "context"
+  "net/http"
+
+  "github.com/op/go-logging"
+  "google.golang.org/grpc"
+  "google.golang.org/grpc/metadata"
+)
+
+// identifies the header (or metadata key) which carries the request id of a call
+const RequestIdHeader = "x-request-id"
+
+var rpcLogger = logging.MustGetLogger("rpc")
+
+// retrieves the request id which has been passed by the caller or generates a new one
+func incomingRequestId(ctx context.Context) string {
+  if md, ok := metadata.FromIncomingContext(ctx); ok {
+    if values := md.Get(RequestIdHeader); len(values) != 0 && values[0] != "" {
+      return values[0]
+    }
+  }
+  return NewRequestId()
+}
+
+// assigns a request id to every unary gRPC call and reports it to the caller
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+  id := incomingRequestId(ctx)
+  ctx = WithRequestId(ctx, id)
+  grpc.SetHeader(ctx, metadata.Pairs(RequestIdHeader, id))
+
+  logger := ForContext(ctx, rpcLogger)
+  logger.Debugf("handling call to %s", info.FullMethod)
+  res, err := handler(ctx, req)
+  if err != nil {
+    logger.Debugf("call to %s failed: %s", info.FullMethod, err)
+  }
+  return res, err
+}
+
+// replaces the context of a server stream with a context which carries a request id
+type requestIdServerStream struct {
+  grpc.ServerStream
+  ctx context.Context
+}
+
+func (s *requestIdServerStream) Context() context.Context {
+  return s.ctx
+}
+
+// assigns a request id to every streaming gRPC call and reports it to the caller
+func StreamServerInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+  id := incomingRequestId(stream.Context())
+  ctx := WithRequestId(stream.Context(), id)
+  stream.SetHeader(metadata.Pairs(RequestIdHeader, id))
+
+  ForContext(ctx, rpcLogger).Debugf("handling stream %s", info.FullMethod)
+  return handler(srv, &requestIdServerStream{
+    ServerStream: stream,
+    ctx:          ctx,
+  })
+}
+
+// assigns a request id to every HTTP request and reports it to the caller
+func Handler(handler http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+    id := req.Header.Get(RequestIdHeader)
+    if id == "" {
+      id = NewRequestId()
+    }
+
+    w.Header().Set(RequestIdHeader, id)
+    handler.ServeHTTP(w, req.WithContext(WithRequestId(req.Context(), id)))
+  })
+}
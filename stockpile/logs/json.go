@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package logs
+
+import (
+  "encoding/json"
+  "io"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/op/go-logging"
+)
+
+// provides a logging backend which encodes every record as a single line JSON object
+type jsonBackend struct {
+  mutex  sync.Mutex
+  writer io.Writer
+}
+
+// represents the encoded form of a log record
+type jsonRecord struct {
+  Time      string `json:"time"`
+  Level     string `json:"level"`
+  Module    string `json:"module"`
+  RequestId string `json:"request_id,omitempty"`
+  Message   string `json:"message"`
+}
+
+func newJsonBackend(writer io.Writer) *jsonBackend {
+  return &jsonBackend{
+    writer: writer,
+  }
+}
+
+func (b *jsonBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+  record := jsonRecord{
+    Time:    rec.Time.Format(time.RFC3339Nano),
+    Level:   strings.ToLower(level.String()),
+    Module:  rec.Module,
+    Message: rec.Message(),
+  }
+
+  // context loggers pass the request id as their first argument
+  if len(rec.Args) != 0 {
+    if requestId, ok := rec.Args[0].(requestIdArg); ok {
+      record.RequestId = string(requestId)
+      record.Message = strings.TrimPrefix(record.Message, requestId.String())
+    }
+  }
+
+  enc, err := json.Marshal(&record)
+  if err != nil {
+    return err
+  }
+
+  b.mutex.Lock()
+  defer b.mutex.Unlock()
+  _, err = b.writer.Write(append(enc, '\n'))
+  return err
+}
@@ -0,0 +1,235 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package logs
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "sync"
+
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/op/go-logging"
+)
+
+// defines the format of text log messages written to a terminal
+const textFormat = `%{color}%{time:15:04:05.000} [%{level:.4s}] %{module} : %{color:reset} %{message}`
+
+// defines the format of text log messages written to a file
+const fileFormat = `%{time:2006-01-02 15:04:05.000} [%{level:.4s}] %{module} : %{message}`
+
+// lists the modules which are always reported along with their effective level (in addition to
+// all modules which have been explicitly configured)
+var defaultModules = []string{"api", "cache", "memdb", "plugin", "rpc", "ui"}
+
+var (
+  mutex   sync.Mutex
+  backend *syncLeveledBackend
+  modules = make(map[string]bool)
+)
+
+// provides a leveled backend which permits the modification of levels while messages are being
+// logged by other routines
+type syncLeveledBackend struct {
+  mutex  sync.RWMutex
+  base   logging.Backend
+  levels logging.LeveledBackend
+}
+
+func newSyncLeveledBackend(base logging.Backend) *syncLeveledBackend {
+  return &syncLeveledBackend{
+    base:   base,
+    levels: logging.AddModuleLevel(base),
+  }
+}
+
+func (b *syncLeveledBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+  if !b.IsEnabledFor(level, rec.Module) {
+    return nil
+  }
+  return b.base.Log(level, calldepth+1, rec)
+}
+
+func (b *syncLeveledBackend) GetLevel(module string) logging.Level {
+  b.mutex.RLock()
+  defer b.mutex.RUnlock()
+  return b.levels.GetLevel(module)
+}
+
+func (b *syncLeveledBackend) SetLevel(level logging.Level, module string) {
+  b.mutex.Lock()
+  defer b.mutex.Unlock()
+  b.levels.SetLevel(level, module)
+}
+
+func (b *syncLeveledBackend) IsEnabledFor(level logging.Level, module string) bool {
+  b.mutex.RLock()
+  defer b.mutex.RUnlock()
+  return b.levels.IsEnabledFor(level, module)
+}
+
+// configures the global logging backend and returns the file it writes to (if any) so that it may
+// be closed upon shutdown
+func Setup(cfg *server.LoggingConfig) (io.Closer, error) {
+  var writer io.Writer = os.Stdout
+  var closer io.Closer
+  format := logging.MustStringFormatter(textFormat)
+  if cfg.File != nil {
+    var maxSize int64
+    if cfg.MaxSize != nil {
+      maxSize = int64(*cfg.MaxSize) * 1024 * 1024
+    }
+    maxBackups := server.DefaultLogMaxBackups
+    if cfg.MaxBackups != nil {
+      maxBackups = *cfg.MaxBackups
+    }
+
+    file, err := openRotatingFile(*cfg.File, maxSize, maxBackups)
+    if err != nil {
+      return nil, fmt.Errorf("cannot open log file \"%s\": %s", *cfg.File, err)
+    }
+    writer = file
+    closer = file
+    format = logging.MustStringFormatter(fileFormat)
+  }
+
+  var base logging.Backend
+  if cfg.Format != nil && *cfg.Format == server.LogFormatJson {
+    base = newJsonBackend(writer)
+  } else {
+    base = logging.NewBackendFormatter(logging.NewLogBackend(writer, "", 0), format)
+  }
+
+  leveled := newSyncLeveledBackend(base)
+  err := applyLevels(leveled, cfg)
+  if err != nil {
+    if closer != nil {
+      closer.Close()
+    }
+    return nil, err
+  }
+
+  mutex.Lock()
+  backend = leveled
+  mutex.Unlock()
+  logging.SetBackend(leveled)
+  return closer, nil
+}
+
+// applies the default and per-module levels of a given configuration to a backend
+func applyLevels(leveled *syncLeveledBackend, cfg *server.LoggingConfig) error {
+  level := logging.INFO
+  if cfg.Level != nil {
+    parsed, err := logging.LogLevel(*cfg.Level)
+    if err != nil {
+      return fmt.Errorf("illegal log level \"%s\": %s", *cfg.Level, err)
+    }
+    level = parsed
+  }
+  leveled.SetLevel(level, "")
+
+  if cfg.Modules != nil {
+    for module, moduleLevel := range *cfg.Modules {
+      parsed, err := logging.LogLevel(moduleLevel)
+      if err != nil {
+        return fmt.Errorf("illegal log level \"%s\" for module \"%s\": %s", moduleLevel, module, err)
+      }
+      leveled.SetLevel(parsed, module)
+
+      mutex.Lock()
+      modules[module] = true
+      mutex.Unlock()
+    }
+  }
+  return nil
+}
+
+// replaces the default and per-module levels with the levels of a given configuration
+// modules which are no longer configured fall back to the new default level
+func Reload(cfg *server.LoggingConfig) error {
+  mutex.Lock()
+  leveled := backend
+  previous := modules
+  modules = make(map[string]bool)
+  mutex.Unlock()
+
+  if leveled == nil {
+    return fmt.Errorf("logging has not been initialized")
+  }
+
+  err := applyLevels(leveled, cfg)
+  if err != nil {
+    return err
+  }
+
+  defaultLevel := leveled.GetLevel("")
+  mutex.Lock()
+  defer mutex.Unlock()
+  for module := range previous {
+    if !modules[module] {
+      leveled.SetLevel(defaultLevel, module)
+    }
+  }
+  return nil
+}
+
+// updates the level of a given module at runtime
+// when an empty module name is passed, the default level is updated instead
+func SetLevel(module string, level string) error {
+  parsed, err := logging.LogLevel(level)
+  if err != nil {
+    return fmt.Errorf("illegal log level \"%s\": %s", level, err)
+  }
+
+  mutex.Lock()
+  defer mutex.Unlock()
+
+  if backend == nil {
+    return fmt.Errorf("logging has not been initialized")
+  }
+  backend.SetLevel(parsed, module)
+  if module != "" {
+    modules[module] = true
+  }
+  return nil
+}
+
+// retrieves the effective levels of all known modules (the default level is reported using an
+// empty module name)
+func GetLevels() map[string]string {
+  mutex.Lock()
+  defer mutex.Unlock()
+
+  levels := make(map[string]string)
+  if backend == nil {
+    return levels
+  }
+
+  names := make([]string, 0, len(defaultModules)+len(modules))
+  names = append(names, defaultModules...)
+  for module := range modules {
+    names = append(names, module)
+  }
+  sort.Strings(names)
+
+  levels[""] = backend.GetLevel("").String()
+  for _, module := range names {
+    levels[module] = backend.GetLevel(module).String()
+  }
+  return levels
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package logs_test
+
+import (
+  "io/ioutil"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/dotStart/Stockpile/stockpile/logs"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/op/go-logging"
+)
+
+// fails the test unless the reported levels of the passed modules match their expected values
+func expectLevels(t *testing.T, expected map[string]string) {
+  levels := logs.GetLevels()
+  for module, level := range expected {
+    if levels[module] != level {
+      t.Errorf("expected module \"%s\" to use level %s but got %s", module, level, levels[module])
+    }
+  }
+}
+
+func TestReload(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  cfg := f.Load(`logging {
+    level = "INFO"
+    file = "{dir}/stockpile.log"
+    modules = {
+      cache = "DEBUG"
+      rpc = "ERROR"
+    }
+  }`)
+  closer, err := logs.Setup(cfg.Logging)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer closer.Close()
+
+  expectLevels(t, map[string]string{
+    "":      "INFO",
+    "api":   "INFO",
+    "cache": "DEBUG",
+    "rpc":   "ERROR",
+  })
+
+  // modules which are no longer configured fall back to the new default level
+  err = logs.Reload(f.Load(`logging {
+    level = "WARNING"
+    modules = {
+      cache = "ERROR"
+      plugin = "DEBUG"
+    }
+  }`).Logging)
+  if err != nil {
+    t.Fatal(err)
+  }
+  expectLevels(t, map[string]string{
+    "":       "WARNING",
+    "api":    "WARNING",
+    "cache":  "ERROR",
+    "plugin": "DEBUG",
+    "rpc":    "WARNING",
+  })
+
+  // reloaded levels apply to loggers which have been created beforehand
+  logging.MustGetLogger("cache").Warning("suppressed cache message")
+  logging.MustGetLogger("plugin").Debug("reported plugin message")
+  logging.MustGetLogger("rpc").Info("suppressed rpc message")
+  logging.MustGetLogger("rpc").Warning("reported rpc message")
+
+  contents, err := ioutil.ReadFile(filepath.Join(f.Dir, "stockpile.log"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  for _, msg := range []string{"reported plugin message", "reported rpc message"} {
+    if !strings.Contains(string(contents), msg) {
+      t.Errorf("expected log to contain \"%s\"", msg)
+    }
+  }
+  if strings.Contains(string(contents), "suppressed") {
+    t.Errorf("expected messages below the configured levels to be suppressed but got:\n%s", contents)
+  }
+}
+
+func TestReloadInvalid(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  closer, err := logs.Setup(f.Load(`logging {
+    file = "{dir}/stockpile.log"
+  }`).Logging)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer closer.Close()
+
+  cfg := f.Load(`logging {}`)
+  cfg.Logging.Modules = &map[string]string{"cache": "VERBOSE"}
+  err = logs.Reload(cfg.Logging)
+  if err == nil || !strings.Contains(err.Error(), "module \"cache\"") {
+    t.Fatalf("expected illegal module level to be rejected but got: %v", err)
+  }
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package logs
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "sync"
+)
+
+// provides a writer which appends to a file and rotates it once it exceeds a given size
+// rotated files are suffixed with their generation (e.g. stockpile.log.1 is the most recent one)
+type rotatingFile struct {
+  mutex      sync.Mutex
+  path       string
+  maxSize    int64
+  maxBackups int
+  file       *os.File
+  size       int64
+}
+
+// opens (or creates) a log file which is rotated once it exceeds the given amount of bytes
+// when zero is passed as the maximum size, the file is never rotated
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+  err := os.MkdirAll(filepath.Dir(path), 0775)
+  if err != nil {
+    return nil, err
+  }
+
+  f := &rotatingFile{
+    path:       path,
+    maxSize:    maxSize,
+    maxBackups: maxBackups,
+  }
+  err = f.open()
+  if err != nil {
+    return nil, err
+  }
+  return f, nil
+}
+
+// opens the current log file for appending
+// this method expects the caller to hold the file mutex
+func (f *rotatingFile) open() error {
+  file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0664)
+  if err != nil {
+    return err
+  }
+
+  info, err := file.Stat()
+  if err != nil {
+    file.Close()
+    return err
+  }
+
+  f.file = file
+  f.size = info.Size()
+  return nil
+}
+
+// moves the current log file to its first backup generation and discards the oldest generation
+// this method expects the caller to hold the file mutex
+func (f *rotatingFile) rotate() error {
+  err := f.file.Close()
+  if err != nil {
+    return err
+  }
+
+  if f.maxBackups <= 0 {
+    err = os.Remove(f.path)
+  } else {
+    os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+    for i := f.maxBackups - 1; i > 0; i-- {
+      os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+    }
+    err = os.Rename(f.path, f.path+".1")
+  }
+  if err != nil && !os.IsNotExist(err) {
+    return err
+  }
+
+  return f.open()
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+  f.mutex.Lock()
+  defer f.mutex.Unlock()
+
+  if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+    err := f.rotate()
+    if err != nil {
+      return 0, fmt.Errorf("failed to rotate log file: %s", err)
+    }
+  }
+
+  n, err := f.file.Write(p)
+  f.size += int64(n)
+  return n, err
+}
+
+func (f *rotatingFile) Close() error {
+  f.mutex.Lock()
+  defer f.mutex.Unlock()
+
+  return f.file.Close()
+}
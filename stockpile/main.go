@@ -19,27 +19,99 @@ package main
 import (
   "context"
   "flag"
+  "fmt"
   "os"
+  "sort"
 
   "github.com/dotStart/Stockpile/stockpile/command"
+  "github.com/dotStart/Stockpile/stockpile/plugin"
+  "github.com/dotStart/Stockpile/stockpile/server"
   "github.com/google/subcommands"
 )
 
+// keeps track of the names of all commands which are provided by Stockpile itself
+var builtinCommands = make(map[string]bool)
+
 // Application Entry Point
 func main() {
-  subcommands.Register(subcommands.HelpCommand(), "")
-  subcommands.Register(subcommands.CommandsCommand(), "")
-  subcommands.Register(&command.ServerCommand{}, "")
-
-  subcommands.Register(&command.BlacklistCommand{}, "Client")
-  subcommands.Register(&command.HistoryCommand{}, "Client")
-  subcommands.Register(&command.IdCommand{}, "Client")
-  subcommands.Register(&command.ListenCommand{}, "Client")
-  subcommands.Register(&command.PluginCommand{}, "Client")
-  subcommands.Register(&command.ProfileCommand{}, "Client")
-  subcommands.Register(&command.StatusCommand{}, "Client")
+  register(subcommands.HelpCommand(), "")
+  register(subcommands.CommandsCommand(), "")
+  register(&command.ServerCommand{}, "")
+  register(&command.ConfigCommand{}, "")
+  register(&command.MockUpstreamCommand{}, "")
+  register(&command.ConformanceCommand{}, "")
+
+  register(&command.BlacklistCommand{}, "Client")
+  register(&command.HistoryCommand{}, "Client")
+  register(&command.IdCommand{}, "Client")
+  register(&command.ListenCommand{}, "Client")
+  register(&command.LogLevelCommand{}, "Client")
+  register(&command.PluginCommand{}, "Client")
+  register(&command.ProfileCommand{}, "Client")
+  register(&command.ReloadCommand{}, "Client")
+  register(&command.StatusCommand{}, "Client")
+  register(&command.TimelineCommand{}, "Client")
 
+  // plugins are only loaded when their commands may be invoked or listed as loading them is
+  // comparatively expensive
   flag.Parse()
+  var manager *plugin.Manager
+  if name := flag.Arg(0); !builtinCommands[name] || name == "help" || name == "commands" {
+    manager = registerPluginCommands()
+  }
+
   ctx := context.Background()
-  os.Exit(int(subcommands.Execute(ctx)))
+  status := subcommands.Execute(ctx)
+  if manager != nil {
+    manager.Shutdown()
+  }
+  os.Exit(int(status))
+}
+
+// registers a command which is provided by Stockpile itself
+func register(cmd subcommands.Command, group string) {
+  builtinCommands[cmd.Name()] = true
+  subcommands.Register(cmd, group)
+}
+
+// registers all commands which are provided by plugins within the plugin directory (as given via
+// the STOCKPILE_PLUGIN_DIR environment variable)
+// since the configuration has yet to be loaded at this point, the plugin allowlist is only honored
+// when it is passed via the STOCKPILE_PLUGIN_ALLOWLIST environment variable
+func registerPluginCommands() *plugin.Manager {
+  cfg := server.DefaultConfig()
+  if pluginDir, ok := os.LookupEnv(server.EnvironmentPrefix + "PLUGIN_DIR"); ok {
+    cfg.PluginDir = &pluginDir
+  }
+  if allowlist, ok := os.LookupEnv(server.EnvironmentPrefix + "PLUGIN_ALLOWLIST"); ok {
+    // plugins must not be loaded without the allowlist when it cannot be applied
+    err := cfg.Set("plugin-allowlist", allowlist)
+    if err == nil {
+      err = cfg.Validate()
+    }
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "error: failed to apply %sPLUGIN_ALLOWLIST: %s\n", server.EnvironmentPrefix, err)
+      os.Exit(1)
+    }
+  }
+
+  manager := plugin.NewManager(*cfg.PluginDir)
+  if cfg.PluginAllowlist != nil {
+    manager.SetAllowlist(*cfg.PluginAllowlist)
+  }
+  manager.ExternalPlugins = false // out-of-process plugins cannot provide commands
+  manager.CommandsOnly = true
+  manager.LoadAll()
+
+  commands := manager.Context.GetCommands()
+  names := make([]string, 0, len(commands))
+  for name := range commands {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  for _, name := range names {
+    subcommands.Register(commands[name], "Plugin")
+  }
+  return manager
 }
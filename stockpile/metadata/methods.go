@@ -33,6 +33,12 @@ func Version() string {
   return version
 }
 
+// Evaluates whether this version has been built without release information (e.g. directly via
+// go build rather than the Makefile)
+func IsDevelopmentBuild() bool {
+  return version == "0.0.0"
+}
+
 // Retrieves the full application version (including its build identifier)
 func VersionFull() string {
   versionExtension := "+dev"
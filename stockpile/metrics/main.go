@@ -0,0 +1,176 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package metrics
+
+import (
+  "strconv"
+  "time"
+
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "stockpile"
+
+// defines the possible results of a cache lookup
+const (
+  // the entry has been served from the storage backend
+  CacheHit = "hit"
+  // the entry has been retrieved from upstream (or does not exist)
+  CacheMiss = "miss"
+  // an expired copy of the entry has been served as it could not be retrieved from upstream
+  CacheStale = "stale"
+)
+
+var (
+  // counts the cache lookups per entity type and result (hit, miss or stale)
+  CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Namespace: namespace,
+    Subsystem: "cache",
+    Name:      "requests_total",
+    Help:      "Amount of cache lookups by entity type and result",
+  }, []string{"type", "result"})
+
+  // measures the duration of upstream requests per endpoint and status code
+  UpstreamRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+    Namespace: namespace,
+    Subsystem: "upstream",
+    Name:      "request_duration_seconds",
+    Help:      "Duration of requests to the upstream servers by endpoint and status code",
+    Buckets:   prometheus.DefBuckets,
+  }, []string{"endpoint", "status"})
+
+  // measures the duration of storage backend operations
+  StorageOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+    Namespace: namespace,
+    Subsystem: "storage",
+    Name:      "operation_duration_seconds",
+    Help:      "Duration of storage backend operations by operation",
+    Buckets:   prometheus.DefBuckets,
+  }, []string{"operation"})
+
+  // counts the storage backend operations which resulted in an error
+  StorageErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Namespace: namespace,
+    Subsystem: "storage",
+    Name:      "errors_total",
+    Help:      "Amount of failed storage backend operations by operation",
+  }, []string{"operation"})
+
+  // measures the duration of gRPC calls per method and status code
+  GrpcRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+    Namespace: namespace,
+    Subsystem: "grpc",
+    Name:      "request_duration_seconds",
+    Help:      "Duration of gRPC calls by method and status code",
+    Buckets:   prometheus.DefBuckets,
+  }, []string{"method", "code"})
+
+  // keeps track of the amount of currently registered event listeners
+  EventListeners = prometheus.NewGauge(prometheus.GaugeOpts{
+    Namespace: namespace,
+    Subsystem: "events",
+    Name:      "listeners",
+    Help:      "Amount of currently registered event listeners",
+  })
+
+  // counts the events which could not be delivered to a listener in time
+  DroppedEvents = prometheus.NewCounter(prometheus.CounterOpts{
+    Namespace: namespace,
+    Subsystem: "events",
+    Name:      "dropped_total",
+    Help:      "Amount of events which have been dropped as their listener did not keep up",
+  })
+)
+
+func init() {
+  prometheus.MustRegister(
+    CacheRequests,
+    UpstreamRequestDuration,
+    StorageOperationDuration,
+    StorageErrors,
+    GrpcRequestDuration,
+    EventListeners,
+    DroppedEvents,
+  )
+}
+
+// records the result of a cache lookup for a given entity type
+func ObserveCacheRequest(entityType string, result string) {
+  CacheRequests.WithLabelValues(entityType, result).Inc()
+}
+
+// records the result of multiple cache lookups for a given entity type
+func ObserveCacheRequests(entityType string, result string, count int) {
+  CacheRequests.WithLabelValues(entityType, result).Add(float64(count))
+}
+
+// resolves the result of a cache lookup which has either been served from storage or passed on to
+// upstream
+func LookupResult(hit bool) string {
+  if hit {
+    return CacheHit
+  }
+  return CacheMiss
+}
+
+// records an upstream request which has been submitted at a given time
+// when the request failed before a response was received, a status code of zero is expected
+func ObserveUpstreamRequest(endpoint string, status int, start time.Time) {
+  label := "error"
+  if status != 0 {
+    label = strconv.Itoa(status)
+  }
+  UpstreamRequestDuration.WithLabelValues(endpoint, label).Observe(time.Since(start).Seconds())
+}
+
+// records a storage backend operation which has been started at a given time
+func ObserveStorageOperation(operation string, start time.Time, err error) {
+  StorageOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+  if err != nil {
+    StorageErrors.WithLabelValues(operation).Inc()
+  }
+}
+
+// exposes the state of the upstream rate limit budget
+// the passed functions are invoked whenever the metrics are collected
+func RegisterRateLimit(allocation func() uint64, capacity func() uint64) error {
+  err := prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+    Namespace: namespace,
+    Subsystem: "rate_limit",
+    Name:      "tokens",
+    Help:      "Amount of upstream requests which may currently be submitted",
+  }, func() float64 {
+    allocated := allocation()
+    available := capacity()
+    if allocated > available {
+      return 0
+    }
+    return float64(available - allocated)
+  }))
+  if err != nil {
+    return err
+  }
+
+  return prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+    Namespace: namespace,
+    Subsystem: "rate_limit",
+    Name:      "capacity",
+    Help:      "Maximum amount of upstream requests which may be submitted within a single period",
+  }, func() float64 {
+    return float64(capacity())
+  }))
+}
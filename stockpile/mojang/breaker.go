@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mojang
+
+import (
+  "errors"
+  "sync"
+  "time"
+)
+
+// defines the amount of consecutive failures after which requests to the upstream servers are
+// suspended
+const DefaultBreakerThreshold = 5
+
+// defines the duration for which requests are suspended before a trial request is permitted
+const DefaultBreakerCooldown = 30 * time.Second
+
+// indicates that a request has been rejected as the upstream servers are considered unavailable
+var ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+
+// identifies the state of a circuit breaker
+type BreakerState int
+
+const (
+  // requests are passed to the upstream servers
+  BreakerClosed BreakerState = iota
+  // requests are rejected until the cooldown has elapsed
+  BreakerOpen
+  // a single trial request is passed to the upstream servers in order to decide whether the
+  // breaker may be closed again
+  BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+  switch s {
+  case BreakerClosed:
+    return "closed"
+  case BreakerOpen:
+    return "open"
+  case BreakerHalfOpen:
+    return "half-open"
+  }
+  return "unknown"
+}
+
+// suspends requests to the upstream servers when they repeatedly fail in order to avoid piling up
+// requests (and thus exhausting the rate limit) while they are unavailable
+type breaker struct {
+  mutex     sync.Mutex
+  threshold int
+  cooldown  time.Duration
+
+  state    BreakerState
+  failures int
+  openedAt time.Time
+  trial    bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+  return &breaker{
+    threshold: threshold,
+    cooldown:  cooldown,
+  }
+}
+
+// evaluates whether a request may be passed to the upstream servers
+func (b *breaker) allow() error {
+  b.mutex.Lock()
+  defer b.mutex.Unlock()
+
+  if b.state == BreakerOpen {
+    if time.Since(b.openedAt) < b.cooldown {
+      return ErrCircuitOpen
+    }
+    b.state = BreakerHalfOpen
+  }
+  if b.state == BreakerHalfOpen {
+    if b.trial {
+      return ErrCircuitOpen
+    }
+    b.trial = true
+  }
+  return nil
+}
+
+// records the outcome of a request which has previously been permitted
+func (b *breaker) record(success bool) {
+  b.mutex.Lock()
+  defer b.mutex.Unlock()
+
+  b.trial = false
+  if success {
+    b.state = BreakerClosed
+    b.failures = 0
+    return
+  }
+
+  b.failures++
+  if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+    b.state = BreakerOpen
+    b.openedAt = time.Now()
+  }
+}
+
+// releases a trial request which has been aborted by the caller without recording an outcome
+func (b *breaker) abort() {
+  b.mutex.Lock()
+  defer b.mutex.Unlock()
+  b.trial = false
+}
+
+// retrieves the current state of the breaker
+func (b *breaker) State() BreakerState {
+  b.mutex.Lock()
+  defer b.mutex.Unlock()
+
+  if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+    return BreakerHalfOpen
+  }
+  return b.state
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mojang
+
+import (
+  "context"
+  "net/http/httptest"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/mojang/mock"
+  "github.com/google/uuid"
+)
+
+// fails the test unless the breaker reports the expected state
+func expectBreakerState(t *testing.T, b *breaker, expected BreakerState) {
+  if state := b.State(); state != expected {
+    t.Fatalf("expected breaker to be %s but got %s", expected, state)
+  }
+}
+
+func TestBreaker(t *testing.T) {
+  b := newBreaker(2, 50*time.Millisecond)
+  expectBreakerState(t, b, BreakerClosed)
+
+  // successful requests reset the amount of consecutive failures
+  for _, success := range []bool{false, true, false} {
+    if err := b.allow(); err != nil {
+      t.Fatalf("expected closed breaker to permit requests but got: %s", err)
+    }
+    b.record(success)
+  }
+  expectBreakerState(t, b, BreakerClosed)
+
+  if err := b.allow(); err != nil {
+    t.Fatal(err)
+  }
+  b.record(false)
+  expectBreakerState(t, b, BreakerOpen)
+  if err := b.allow(); err != ErrCircuitOpen {
+    t.Fatalf("expected open breaker to reject requests but got: %v", err)
+  }
+
+  // a single trial is permitted once the cooldown has elapsed and re-opens the breaker on failure
+  time.Sleep(60 * time.Millisecond)
+  expectBreakerState(t, b, BreakerHalfOpen)
+  if err := b.allow(); err != nil {
+    t.Fatalf("expected half-open breaker to permit a trial request but got: %s", err)
+  }
+  if err := b.allow(); err != ErrCircuitOpen {
+    t.Fatalf("expected half-open breaker to reject concurrent requests but got: %v", err)
+  }
+  b.record(false)
+  expectBreakerState(t, b, BreakerOpen)
+
+  // aborted trials are released without affecting the state of the breaker
+  time.Sleep(60 * time.Millisecond)
+  if err := b.allow(); err != nil {
+    t.Fatal(err)
+  }
+  b.abort()
+  expectBreakerState(t, b, BreakerHalfOpen)
+
+  if err := b.allow(); err != nil {
+    t.Fatalf("expected aborted trial to be released but got: %s", err)
+  }
+  b.record(true)
+  expectBreakerState(t, b, BreakerClosed)
+}
+
+func TestBreakerUpstream(t *testing.T) {
+  fixture := &mock.Fixture{}
+  err := fixture.Parse()
+  if err != nil {
+    t.Fatal(err)
+  }
+  upstreamMock := mock.New(fixture, mock.Options{ErrorRate: 1})
+  upstreamSrv := httptest.NewServer(upstreamMock)
+  defer upstreamSrv.Close()
+
+  api := New()
+  api.breaker = newBreaker(2, time.Hour)
+  err = api.SetServers(upstreamSrv.URL, upstreamSrv.URL)
+  if err != nil {
+    t.Fatal(err)
+  }
+  ctx := context.Background()
+
+  // client errors do not indicate an unavailable upstream
+  upstreamMock.SetOptions(mock.Options{ErrorRate: 1, ErrorStatus: 400})
+  for i := 0; i < 3; i++ {
+    api.GetProfile(ctx, uuid.New())
+  }
+  if err := api.Ping(); err != nil {
+    t.Fatalf("expected client errors to keep the breaker closed but got: %s", err)
+  }
+
+  upstreamMock.SetOptions(mock.Options{ErrorRate: 1})
+  for i := 0; i < 2; i++ {
+    _, err = api.GetProfile(ctx, uuid.New())
+    if err == nil || err == ErrCircuitOpen {
+      t.Fatalf("expected request %d to be passed to upstream and fail but got: %v", i, err)
+    }
+  }
+  if api.BreakerState() != BreakerOpen {
+    t.Fatalf("expected repeated server errors to open the breaker but got %s", api.BreakerState())
+  }
+  if err := api.Ping(); err == nil {
+    t.Fatal("expected ping to report the open breaker")
+  }
+
+  upstreamMock.SetOptions(mock.Options{})
+  _, err = api.GetProfile(ctx, uuid.New())
+  if err != ErrCircuitOpen {
+    t.Fatalf("expected requests to be rejected while the breaker is open but got: %v", err)
+  }
+}
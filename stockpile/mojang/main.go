@@ -17,46 +17,178 @@
 package mojang
 
 import (
+  "context"
   "fmt"
   "io"
   "net/http"
+  "net/url"
   "runtime"
+  "strings"
+  "time"
 
+  "github.com/dotStart/Stockpile/stockpile/logs"
   "github.com/dotStart/Stockpile/stockpile/metadata"
+  "github.com/dotStart/Stockpile/stockpile/metrics"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/tracing"
+  "github.com/dotStart/Stockpile/stockpile/upstream"
+  "github.com/hashicorp/hcl2/gohcl"
   "github.com/op/go-logging"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/trace"
 )
 
+// defines the base URL of the Mojang API (which resolves names and name histories)
+const DefaultApiUrl = "https://api.mojang.com"
+
+// defines the base URL of the Mojang session server (which provides profiles, the blacklist and
+// the online handshake)
+const DefaultSessionUrl = "https://sessionserver.mojang.com"
+
 type MojangAPI struct {
-  logger *logging.Logger
-  http   *http.Client
+  logger     *logging.Logger
+  http       *http.Client
+  breaker    *breaker
+  apiUrl     string
+  sessionUrl string
+}
+
+type MojangUpstreamCfg struct {
+  ApiUrl     *string `hcl:"api-url,attr"`
+  SessionUrl *string `hcl:"session-url,attr"`
 }
 
 // Creates a new Mojang API client
 func New() *MojangAPI {
   return &MojangAPI{
-    logger: logging.MustGetLogger("api"),
-    http:   &http.Client{},
+    logger:     logging.MustGetLogger("api"),
+    http:       &http.Client{},
+    breaker:    newBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
+    apiUrl:     DefaultApiUrl,
+    sessionUrl: DefaultSessionUrl,
+  }
+}
+
+// creates a factory for Mojang API clients which submit their requests through the transport
+// returned by the passed decorator (the decorator is invoked whenever a client is constructed)
+// the servers may be replaced via the provider parameters (e.g. in order to use a mock server)
+func NewUpstreamFactory(decorate func(http.RoundTripper) http.RoundTripper) upstream.Factory {
+  return func(cfg *server.Config) (upstream.Upstream, error) {
+    mojangCfg := &MojangUpstreamCfg{}
+    if cfg.Upstream.Parameters != nil {
+      diag := gohcl.DecodeBody(cfg.Upstream.Parameters, server.EvalContext(), mojangCfg)
+      if diag.HasErrors() {
+        return nil, fmt.Errorf("illegal provider configuration: %s", diag.Error())
+      }
+    }
+
+    api := New()
+    api.SetTransport(decorate(api.Transport()))
+
+    apiUrl := DefaultApiUrl
+    if mojangCfg.ApiUrl != nil {
+      apiUrl = *mojangCfg.ApiUrl
+    }
+    sessionUrl := DefaultSessionUrl
+    if mojangCfg.SessionUrl != nil {
+      sessionUrl = *mojangCfg.SessionUrl
+    }
+    err := api.SetServers(apiUrl, sessionUrl)
+    if err != nil {
+      return nil, fmt.Errorf("illegal provider configuration: %s", err)
+    }
+    return api, nil
+  }
+}
+
+// retrieves the transport which is used to submit requests to the upstream servers
+func (a *MojangAPI) Transport() http.RoundTripper {
+  if a.http.Transport == nil {
+    return http.DefaultTransport
+  }
+  return a.http.Transport
+}
+
+// replaces the transport which is used to submit requests to the upstream servers
+// this method is not safe for concurrent use and should thus be called before the client is used
+func (a *MojangAPI) SetTransport(transport http.RoundTripper) {
+  a.http.Transport = transport
+}
+
+// replaces the base URLs of the servers to which requests are submitted
+// this method is not safe for concurrent use and should thus be called before the client is used
+func (a *MojangAPI) SetServers(apiUrl string, sessionUrl string) error {
+  for _, baseUrl := range []string{apiUrl, sessionUrl} {
+    parsed, err := url.Parse(baseUrl)
+    if err != nil {
+      return fmt.Errorf("illegal server url \"%s\": %s", baseUrl, err)
+    }
+    if parsed.Scheme != "http" && parsed.Scheme != "https" {
+      return fmt.Errorf("illegal server url \"%s\": expected http or https", baseUrl)
+    }
+  }
+
+  a.apiUrl = strings.TrimSuffix(apiUrl, "/")
+  a.sessionUrl = strings.TrimSuffix(sessionUrl, "/")
+  return nil
+}
+
+// retrieves the state of the circuit breaker which guards requests to the upstream servers
+func (a *MojangAPI) BreakerState() BreakerState {
+  return a.breaker.State()
+}
+
+// reports an error while the circuit breaker rejects requests to the upstream servers
+func (a *MojangAPI) Ping() error {
+  if state := a.BreakerState(); state != BreakerClosed {
+    return fmt.Errorf("circuit breaker is %s", state)
   }
+  return nil
 }
 
 // Executes an HTTP request
-func (a *MojangAPI) execute(method string, uri string, body io.Reader) (*http.Response, error) {
+// the endpoint identifies the request within the collected metrics
+func (a *MojangAPI) execute(ctx context.Context, endpoint string, method string, uri string, body io.Reader) (res *http.Response, err error) {
+  ctx, span := tracing.Start(ctx, "upstream."+endpoint, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+    attribute.String("http.method", method),
+    attribute.String("http.url", uri),
+  ))
+  defer func() { tracing.End(span, err) }()
+
   req, err := http.NewRequest(method, uri, body)
   if err != nil {
     return nil, err
   }
+  req = req.WithContext(ctx)
 
   req.Header.Set("user-agent", fmt.Sprintf("Stockpile/%s (Go/%s; %s; +https://github.com/dotStart/Stockpile)", metadata.VersionFull(), runtime.Version(), metadata.Brand()))
   req.Header.Set("content-type", "application/json")
 
-  a.logger.Debugf("sending request: %s %s", method, uri)
-  res, err := a.http.Do(req)
+  logger := logs.ForContext(ctx, a.logger)
+  err = a.breaker.allow()
+  if err != nil {
+    logger.Debugf("rejected request as upstream is considered unavailable: %s %s", method, uri)
+    return nil, err
+  }
+
+  logger.Debugf("sending request: %s %s", method, uri)
+  start := time.Now()
+  res, err = a.http.Do(req)
   if err != nil {
+    metrics.ObserveUpstreamRequest(endpoint, 0, start)
+    if ctx.Err() != nil {
+      a.breaker.abort()
+    } else {
+      a.breaker.record(false)
+    }
     return nil, err
   }
+  metrics.ObserveUpstreamRequest(endpoint, res.StatusCode, start)
+  a.breaker.record(res.StatusCode/100 != 5 && res.StatusCode != http.StatusTooManyRequests)
+  span.SetAttributes(attribute.Int("http.status_code", res.StatusCode))
 
   statusCategory := res.StatusCode / 100
-  a.logger.Debugf("server responded with status code %d (category %d)", res.StatusCode, statusCategory)
+  logger.Debugf("server responded with status code %d (category %d)", res.StatusCode, statusCategory)
 
   if statusCategory == 2 || res.StatusCode == 404 {
     return res, nil
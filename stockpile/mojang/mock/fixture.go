@@ -0,0 +1,178 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mock
+
+import (
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "strings"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/google/uuid"
+)
+
+// represents the data set which is served by a mock server
+//
+// fixtures are typically loaded from JSON files such as:
+//
+//  {
+//    "profiles": [
+//      {
+//        "id": "069a79f444e94726a5befca90e38aaf5",
+//        "names": [
+//          {"name": "Notch"},
+//          {"name": "NotNotch", "changedToAt": 1423059891000}
+//        ],
+//        "properties": [
+//          {"name": "textures", "value": "<base64>", "signature": "<base64>"}
+//        ]
+//      }
+//    ],
+//    "blacklist": ["6f2520f8bd70a718c568ab5274c56bdbbfc14ef4"]
+//  }
+type Fixture struct {
+  Profiles  []*FixtureProfile `json:"profiles"`
+  Blacklist []string          `json:"blacklist"`
+}
+
+// represents a single profile within a fixture
+// names are listed in the order in which they have been assigned (the initial name carries no
+// timestamp)
+type FixtureProfile struct {
+  Id         string                    `json:"id"`
+  Names      []*FixtureName            `json:"names"`
+  Properties []*entity.ProfileProperty `json:"properties"`
+
+  id uuid.UUID
+}
+
+// represents a single name change within a fixture profile
+// the time of the change is given in milliseconds since the UNIX epoch (as reported by Mojang)
+type FixtureName struct {
+  Name        string `json:"name"`
+  ChangedToAt int64  `json:"changedToAt,omitempty"`
+}
+
+// creates an empty fixture
+func EmptyFixture() *Fixture {
+  return &Fixture{
+    Profiles:  make([]*FixtureProfile, 0),
+    Blacklist: make([]string, 0),
+  }
+}
+
+// loads a fixture from a JSON file
+func LoadFixture(path string) (*Fixture, error) {
+  enc, err := ioutil.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+
+  fixture := EmptyFixture()
+  err = json.Unmarshal(enc, fixture)
+  if err != nil {
+    return nil, fmt.Errorf("failed to parse fixture \"%s\": %s", path, err)
+  }
+  err = fixture.Parse()
+  if err != nil {
+    return nil, fmt.Errorf("illegal fixture \"%s\": %s", path, err)
+  }
+  return fixture, nil
+}
+
+// validates the fixture and prepares it for use
+func (f *Fixture) Parse() error {
+  known := make(map[uuid.UUID]bool)
+  for i, profile := range f.Profiles {
+    id, err := entity.ParseId(profile.Id)
+    if err != nil {
+      return fmt.Errorf("profile #%d: illegal id \"%s\": %s", i, profile.Id, err)
+    }
+    if known[id] {
+      return fmt.Errorf("profile #%d: duplicate id \"%s\"", i, profile.Id)
+    }
+    known[id] = true
+    profile.id = id
+
+    if len(profile.Names) == 0 {
+      return fmt.Errorf("profile #%d: at least one name is required", i)
+    }
+    for j, name := range profile.Names {
+      if name.Name == "" {
+        return fmt.Errorf("profile #%d: name #%d must not be empty", i, j)
+      }
+      if j != 0 && name.ChangedToAt <= profile.Names[j-1].ChangedToAt {
+        return fmt.Errorf("profile #%d: name #%d must have been assigned after its predecessor", i, j)
+      }
+    }
+  }
+
+  for _, hash := range f.Blacklist {
+    if len(hash) != 40 {
+      return fmt.Errorf("malformed blacklist hash \"%s\": must be exactly 40 characters long", hash)
+    }
+  }
+  return nil
+}
+
+// retrieves the name which is currently assigned to a profile
+func (p *FixtureProfile) currentName() string {
+  return p.Names[len(p.Names)-1].Name
+}
+
+// evaluates whether a profile has been associated with a given name at the specified time
+// names are only matched against the initial name of a profile when the UNIX epoch is passed (as
+// is the case with the Mojang API)
+func (p *FixtureProfile) isAssigned(name string, at time.Time) bool {
+  if at.Unix() == 0 {
+    return strings.EqualFold(p.Names[0].Name, name)
+  }
+
+  ms := at.UnixNano() / int64(time.Millisecond)
+  for i, change := range p.Names {
+    if change.ChangedToAt > ms {
+      break
+    }
+    if i+1 < len(p.Names) && p.Names[i+1].ChangedToAt <= ms {
+      continue
+    }
+    return strings.EqualFold(change.Name, name)
+  }
+  return false
+}
+
+// locates the profile with a given identifier
+func (f *Fixture) findProfile(id uuid.UUID) *FixtureProfile {
+  for _, profile := range f.Profiles {
+    if profile.id == id {
+      return profile
+    }
+  }
+  return nil
+}
+
+// locates the profile which has been associated with a given name at the specified time
+func (f *Fixture) findName(name string, at time.Time) *FixtureProfile {
+  for _, profile := range f.Profiles {
+    if profile.isAssigned(name, at) {
+      return profile
+    }
+  }
+  return nil
+}
@@ -0,0 +1,338 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mock
+
+import (
+  "encoding/json"
+  "math/rand"
+  "net/http"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/op/go-logging"
+)
+
+// defines the maximum amount of names which may be resolved within a single bulk request
+const maxBulkNames = 100
+
+// defines the options which control how a mock server responds to requests
+type Options struct {
+  // delays every response by the given duration
+  Latency time.Duration
+  // defines the ratio of requests (between 0 and 1) which are answered with an error
+  ErrorRate float64
+  // defines the status code of injected errors (500 when omitted)
+  ErrorStatus int
+  // defines the amount of requests which are answered within a single period before requests are
+  // rejected with status 429 (unlimited when zero)
+  RateLimit       int
+  RateLimitPeriod time.Duration
+}
+
+// provides a mock implementation of the Mojang API and session server endpoints which are used
+// by Stockpile (both sets of endpoints are served by the same handler)
+type Server struct {
+  logger  *logging.Logger
+  fixture *Fixture
+
+  mutex          sync.Mutex
+  options        Options
+  random         *rand.Rand
+  windowStart    time.Time
+  windowRequests int
+  requests       map[string]int
+}
+
+// represents the error format of the Mojang API
+type restError struct {
+  Error        string `json:"error"`
+  ErrorMessage string `json:"errorMessage"`
+}
+
+type restProfileId struct {
+  Id   string `json:"id"`
+  Name string `json:"name"`
+}
+
+type restProfile struct {
+  Id         string          `json:"id"`
+  Name       string          `json:"name"`
+  Properties []*restProperty `json:"properties"`
+}
+
+type restProperty struct {
+  Name      string `json:"name"`
+  Value     string `json:"value"`
+  Signature string `json:"signature,omitempty"`
+}
+
+// creates a new mock server which serves the passed fixture
+func New(fixture *Fixture, options Options) *Server {
+  return &Server{
+    logger:   logging.MustGetLogger("mock"),
+    fixture:  fixture,
+    options:  options,
+    random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+    requests: make(map[string]int),
+  }
+}
+
+// replaces the options of this server (e.g. in order to simulate an outage while a test is
+// running)
+// the rate limit period is restarted when the options are replaced
+func (s *Server) SetOptions(options Options) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  s.options = options
+  s.windowStart = time.Time{}
+  s.windowRequests = 0
+}
+
+// retrieves the amount of requests which have been received for a given endpoint (one of
+// "profile_id", "bulk_profile_id", "name_history", "profile", "login" or "blacklist")
+func (s *Server) RequestCount(endpoint string) int {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  return s.requests[endpoint]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  endpoint, method, handler := s.route(r.URL.Path)
+  if handler == nil {
+    writeError(w, http.StatusNotFound, "Not Found", "The requested resource could not be found")
+    return
+  }
+  if r.Method != method {
+    writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "The method specified in the request is not allowed for the resource identified by the request URI")
+    return
+  }
+
+  status := s.record(endpoint)
+  s.logger.Debugf("%s %s (%s)", r.Method, r.URL, endpoint)
+
+  s.mutex.Lock()
+  latency := s.options.Latency
+  s.mutex.Unlock()
+  if latency > 0 {
+    select {
+    case <-time.After(latency):
+    case <-r.Context().Done():
+      return
+    }
+  }
+
+  switch status {
+  case http.StatusOK:
+    handler(w, r)
+  case http.StatusTooManyRequests:
+    writeError(w, status, "TooManyRequestsException", "The client has sent too many requests within a certain amount of time")
+  default:
+    writeError(w, status, http.StatusText(status), "An injected error has occurred")
+  }
+}
+
+// records a request and decides whether it is answered or rejected
+func (s *Server) record(endpoint string) int {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  s.requests[endpoint]++
+
+  if s.options.RateLimit > 0 {
+    now := time.Now()
+    if now.Sub(s.windowStart) >= s.options.RateLimitPeriod {
+      s.windowStart = now
+      s.windowRequests = 0
+    }
+    s.windowRequests++
+    if s.windowRequests > s.options.RateLimit {
+      return http.StatusTooManyRequests
+    }
+  }
+
+  if s.options.ErrorRate > 0 && s.random.Float64() < s.options.ErrorRate {
+    if s.options.ErrorStatus != 0 {
+      return s.options.ErrorStatus
+    }
+    return http.StatusInternalServerError
+  }
+  return http.StatusOK
+}
+
+// identifies the endpoint (as well as its permitted method and handler) for a given path
+func (s *Server) route(path string) (string, string, http.HandlerFunc) {
+  switch {
+  case strings.HasPrefix(path, "/users/profiles/minecraft/"):
+    name := strings.TrimPrefix(path, "/users/profiles/minecraft/")
+    return "profile_id", "GET", func(w http.ResponseWriter, r *http.Request) { s.handleProfileId(w, r, name) }
+  case path == "/profiles/minecraft":
+    return "bulk_profile_id", "POST", s.handleBulkProfileId
+  case strings.HasPrefix(path, "/user/profiles/") && strings.HasSuffix(path, "/names"):
+    id := strings.TrimSuffix(strings.TrimPrefix(path, "/user/profiles/"), "/names")
+    return "name_history", "GET", func(w http.ResponseWriter, r *http.Request) { s.handleNameHistory(w, r, id) }
+  case strings.HasPrefix(path, "/session/minecraft/profile/"):
+    id := strings.TrimPrefix(path, "/session/minecraft/profile/")
+    return "profile", "GET", func(w http.ResponseWriter, r *http.Request) { s.handleProfile(w, r, id) }
+  case path == "/session/minecraft/hasJoined":
+    return "login", "GET", s.handleLogin
+  case path == "/blockedservers":
+    return "blacklist", "GET", s.handleBlacklist
+  }
+  return "", "", nil
+}
+
+func (s *Server) handleProfileId(w http.ResponseWriter, r *http.Request, name string) {
+  at := time.Now()
+  if raw := r.URL.Query().Get("at"); raw != "" {
+    timestamp, err := strconv.ParseInt(raw, 10, 64)
+    if err != nil {
+      writeError(w, http.StatusBadRequest, "IllegalArgumentException", "Invalid timestamp.")
+      return
+    }
+    at = time.Unix(timestamp, 0)
+  }
+
+  profile := s.fixture.findName(name, at)
+  if profile == nil {
+    w.WriteHeader(http.StatusNoContent)
+    return
+  }
+  writeJson(w, &restProfileId{
+    Id:   entity.ToMojangId(profile.id),
+    Name: profile.currentName(),
+  })
+}
+
+func (s *Server) handleBulkProfileId(w http.ResponseWriter, r *http.Request) {
+  names := make([]string, 0)
+  err := json.NewDecoder(r.Body).Decode(&names)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, "IllegalArgumentException", "Invalid payload.")
+    return
+  }
+  if len(names) > maxBulkNames {
+    writeError(w, http.StatusBadRequest, "IllegalArgumentException", "Not more that 100 profile name per call is allowed.")
+    return
+  }
+
+  at := time.Now()
+  resolved := make(map[*FixtureProfile]bool)
+  ids := make([]*restProfileId, 0)
+  for _, name := range names {
+    profile := s.fixture.findName(name, at)
+    if profile == nil || resolved[profile] {
+      continue
+    }
+
+    resolved[profile] = true
+    ids = append(ids, &restProfileId{
+      Id:   entity.ToMojangId(profile.id),
+      Name: profile.currentName(),
+    })
+  }
+  writeJson(w, ids)
+}
+
+func (s *Server) handleNameHistory(w http.ResponseWriter, r *http.Request, rawId string) {
+  id, err := entity.ParseId(rawId)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, "IllegalArgumentException", "Invalid UUID string: "+rawId)
+    return
+  }
+
+  profile := s.fixture.findProfile(id)
+  if profile == nil {
+    w.WriteHeader(http.StatusNoContent)
+    return
+  }
+  writeJson(w, profile.Names)
+}
+
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request, rawId string) {
+  id, err := entity.ParseId(rawId)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, "IllegalArgumentException", "Invalid UUID string: "+rawId)
+    return
+  }
+
+  profile := s.fixture.findProfile(id)
+  if profile == nil {
+    w.WriteHeader(http.StatusNoContent)
+    return
+  }
+  writeJson(w, newRestProfile(profile, r.URL.Query().Get("unsigned") == "false"))
+}
+
+// accepts the handshake of any profile which is currently known by the passed name
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+  name := r.URL.Query().Get("username")
+  serverId := r.URL.Query().Get("serverId")
+  if name == "" || serverId == "" {
+    w.WriteHeader(http.StatusNoContent)
+    return
+  }
+
+  profile := s.fixture.findName(name, time.Now())
+  if profile == nil {
+    w.WriteHeader(http.StatusNoContent)
+    return
+  }
+  writeJson(w, newRestProfile(profile, true))
+}
+
+func (s *Server) handleBlacklist(w http.ResponseWriter, r *http.Request) {
+  w.Header().Set("Content-Type", "text/plain")
+  w.Write([]byte(strings.Join(s.fixture.Blacklist, "\n")))
+}
+
+// converts a fixture profile into its REST representation
+// signatures are only included when requested (as is the case with the session server)
+func newRestProfile(profile *FixtureProfile, signed bool) *restProfile {
+  properties := make([]*restProperty, 0, len(profile.Properties))
+  for _, property := range profile.Properties {
+    prop := &restProperty{
+      Name:  property.Name,
+      Value: property.Value,
+    }
+    if signed {
+      prop.Signature = property.Signature
+    }
+    properties = append(properties, prop)
+  }
+
+  return &restProfile{
+    Id:         entity.ToMojangId(profile.id),
+    Name:       profile.currentName(),
+    Properties: properties,
+  }
+}
+
+func writeJson(w http.ResponseWriter, value interface{}) {
+  w.Header().Set("Content-Type", "application/json")
+  json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err string, message string) {
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  json.NewEncoder(w).Encode(&restError{
+    Error:        err,
+    ErrorMessage: message,
+  })
+}
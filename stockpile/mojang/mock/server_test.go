@@ -0,0 +1,313 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mock_test
+
+import (
+  "context"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/mojang"
+  "github.com/dotStart/Stockpile/stockpile/mojang/mock"
+  "github.com/google/uuid"
+)
+
+var (
+  notchId   = uuid.MustParse("069a79f4-44e9-4726-a5be-fca90e38aaf5")
+  renamedId = uuid.MustParse("c2d0a1b6-a0e5-4d8c-9a6f-3b2e7d4c1f05")
+  aliceId   = uuid.MustParse("5f0b8d7e-2c4a-4e9f-8b1d-3a6c9e2f4b07")
+)
+
+// starts a mock server which serves the example fixture and creates a client which submits its
+// requests to it
+func newMockServer(t *testing.T, options mock.Options) (*mock.Server, *httptest.Server, *mojang.MojangAPI) {
+  fixture, err := mock.LoadFixture("../../../docs/mock-fixture.json")
+  if err != nil {
+    t.Fatal(err)
+  }
+  upstreamMock := mock.New(fixture, options)
+  upstreamSrv := httptest.NewServer(upstreamMock)
+
+  api := mojang.New()
+  err = api.SetServers(upstreamSrv.URL, upstreamSrv.URL)
+  if err != nil {
+    upstreamSrv.Close()
+    t.Fatal(err)
+  }
+  return upstreamMock, upstreamSrv, api
+}
+
+func TestProfileId(t *testing.T) {
+  _, srv, api := newMockServer(t, mock.Options{})
+  defer srv.Close()
+  ctx := context.Background()
+
+  tests := []struct {
+    name     string
+    at       time.Time
+    expected uuid.UUID
+  }{
+    {"Notch", time.Now(), notchId},
+    {"notch", time.Now(), notchId},
+    {"Bob", time.Now(), renamedId},
+    {"Alice_", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), renamedId},
+    {"Alice", time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), renamedId},
+    // the epoch refers to the initial name of every profile (thus matching the first profile)
+    {"Alice", time.Unix(0, 0), renamedId},
+    {"Alice", time.Now(), aliceId},
+    {"Alice_", time.Now(), uuid.Nil},
+    {"jeb_", time.Now(), uuid.Nil},
+  }
+
+  for _, test := range tests {
+    profileId, err := api.GetId(ctx, test.name, test.at)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if test.expected == uuid.Nil {
+      if profileId != nil {
+        t.Errorf("expected name \"%s\" to be unassigned at %s but got %s", test.name, test.at, profileId.Id)
+      }
+      continue
+    }
+    if profileId == nil || profileId.Id != test.expected {
+      t.Errorf("expected name \"%s\" to resolve to %s at %s but got %v", test.name, test.expected, test.at, profileId)
+    }
+  }
+
+  profileIds, err := api.BulkGetId(ctx, []string{"Notch", "bob", "Alice_", "Notch"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(profileIds) != 2 || profileIds[0].Id != notchId || profileIds[1].Id != renamedId || profileIds[1].Name != "Bob" {
+    t.Errorf("expected bulk lookup to resolve current names once but got %v", profileIds)
+  }
+}
+
+func TestNameHistory(t *testing.T) {
+  _, srv, api := newMockServer(t, mock.Options{})
+  defer srv.Close()
+  ctx := context.Background()
+
+  history, err := api.GetHistory(ctx, renamedId)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if history == nil || len(history.History) != 3 {
+    t.Fatalf("expected 3 name changes but got %v", history)
+  }
+  expected := []struct {
+    name        string
+    changedToAt int64
+  }{
+    {"Alice", 0},
+    {"Alice_", 1496275200000},
+    {"Bob", 1530403200000},
+  }
+  for i, change := range history.History {
+    ms := change.ChangedToAt.UnixNano() / int64(time.Millisecond)
+    if change.Name != expected[i].name || (expected[i].changedToAt != 0 && ms != expected[i].changedToAt) {
+      t.Errorf("expected change #%d to %s at %d but got %s at %d", i, expected[i].name, expected[i].changedToAt, change.Name, ms)
+    }
+  }
+
+  history, err = api.GetHistory(ctx, uuid.New())
+  if err != nil || history != nil {
+    t.Errorf("expected unknown profile to have no history but got %v (%v)", history, err)
+  }
+}
+
+func TestProfile(t *testing.T) {
+  _, srv, api := newMockServer(t, mock.Options{})
+  defer srv.Close()
+  ctx := context.Background()
+
+  // profiles are retrieved along with their signatures
+  profile, err := api.GetProfile(ctx, notchId)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile == nil || profile.Id != notchId || profile.Name != "Notch" {
+    t.Fatalf("expected profile of Notch but got %v", profile)
+  }
+  textures := profile.Properties["textures"]
+  if textures == nil || textures.Value == "" || textures.Signature == "" {
+    t.Errorf("expected signed textures property but got %+v", textures)
+  }
+
+  profile, err = api.GetProfile(ctx, uuid.New())
+  if err != nil || profile != nil {
+    t.Errorf("expected unknown profile to be missing but got %v (%v)", profile, err)
+  }
+
+  profile, err = api.Login(ctx, "bob", "server", "")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile == nil || profile.Id != renamedId || profile.Name != "Bob" {
+    t.Errorf("expected handshake to resolve the current owner of the name but got %v", profile)
+  }
+}
+
+func TestBlacklist(t *testing.T) {
+  _, srv, api := newMockServer(t, mock.Options{})
+  defer srv.Close()
+
+  blacklist, err := api.GetBlacklist(context.Background())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if blacklist == nil || len(blacklist.Hashes) != 1 || blacklist.Hashes[0] != "6f2520f8bd70a718c568ab5274c56bdbbfc14ef4" {
+    t.Errorf("expected fixture blacklist but got %v", blacklist)
+  }
+}
+
+func TestErrors(t *testing.T) {
+  upstreamMock, srv, _ := newMockServer(t, mock.Options{})
+  defer srv.Close()
+
+  tests := []struct {
+    method   string
+    path     string
+    expected int
+  }{
+    {"GET", "/users/profiles/minecraft/Notch", http.StatusOK},
+    {"GET", "/unknown", http.StatusNotFound},
+    {"POST", "/users/profiles/minecraft/Notch", http.StatusMethodNotAllowed},
+    {"GET", "/users/profiles/minecraft/Notch?at=soon", http.StatusBadRequest},
+    {"GET", "/user/profiles/invalid/names", http.StatusBadRequest},
+    {"POST", "/profiles/minecraft", http.StatusBadRequest},
+  }
+  for _, test := range tests {
+    req, err := http.NewRequest(test.method, srv.URL+test.path, strings.NewReader("{"))
+    if err != nil {
+      t.Fatal(err)
+    }
+    res, err := http.DefaultClient.Do(req)
+    if err != nil {
+      t.Fatal(err)
+    }
+    res.Body.Close()
+    if res.StatusCode != test.expected {
+      t.Errorf("expected %s %s to respond with status %d but got %d", test.method, test.path, test.expected, res.StatusCode)
+    }
+  }
+
+  // rejected methods and unknown paths are not counted
+  if count := upstreamMock.RequestCount("profile_id"); count != 2 {
+    t.Errorf("expected 2 profile id requests but got %d", count)
+  }
+
+  upstreamMock.SetOptions(mock.Options{ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable})
+  res, err := http.Get(srv.URL + "/blockedservers")
+  if err != nil {
+    t.Fatal(err)
+  }
+  res.Body.Close()
+  if res.StatusCode != http.StatusServiceUnavailable {
+    t.Errorf("expected injected error status but got %d", res.StatusCode)
+  }
+}
+
+func TestRateLimit(t *testing.T) {
+  upstreamMock, srv, api := newMockServer(t, mock.Options{RateLimit: 2, RateLimitPeriod: time.Hour})
+  defer srv.Close()
+  ctx := context.Background()
+
+  for i := 0; i < 2; i++ {
+    _, err := api.GetProfile(ctx, notchId)
+    if err != nil {
+      t.Fatalf("expected request %d to be answered but got: %s", i, err)
+    }
+  }
+  _, err := api.GetProfile(ctx, notchId)
+  if err == nil || !strings.Contains(err.Error(), "429") {
+    t.Fatalf("expected request beyond the limit to be rejected but got: %v", err)
+  }
+  if count := upstreamMock.RequestCount("profile"); count != 3 {
+    t.Errorf("expected rejected requests to be counted but got %d requests", count)
+  }
+
+  // replacing the options restarts the period
+  upstreamMock.SetOptions(mock.Options{RateLimit: 2, RateLimitPeriod: time.Hour})
+  _, err = api.GetProfile(ctx, notchId)
+  if err != nil {
+    t.Fatalf("expected rate limit period to be restarted but got: %s", err)
+  }
+}
+
+func TestFixtureParse(t *testing.T) {
+  id := "069a79f444e94726a5befca90e38aaf5"
+  tests := []struct {
+    name     string
+    fixture  *mock.Fixture
+    expected string
+  }{
+    {"illegal-id", &mock.Fixture{Profiles: []*mock.FixtureProfile{{Id: "notch", Names: []*mock.FixtureName{{Name: "Notch"}}}}}, "illegal id"},
+    {"duplicate-id", &mock.Fixture{Profiles: []*mock.FixtureProfile{
+      {Id: id, Names: []*mock.FixtureName{{Name: "Notch"}}},
+      {Id: id, Names: []*mock.FixtureName{{Name: "jeb_"}}},
+    }}, "duplicate id"},
+    {"no-names", &mock.Fixture{Profiles: []*mock.FixtureProfile{{Id: id}}}, "at least one name is required"},
+    {"empty-name", &mock.Fixture{Profiles: []*mock.FixtureProfile{{Id: id, Names: []*mock.FixtureName{{Name: ""}}}}}, "must not be empty"},
+    {"unordered", &mock.Fixture{Profiles: []*mock.FixtureProfile{{Id: id, Names: []*mock.FixtureName{
+      {Name: "Notch"},
+      {Name: "jeb_", ChangedToAt: 2000},
+      {Name: "Dinnerbone", ChangedToAt: 1000},
+    }}}}, "must have been assigned after its predecessor"},
+    {"blacklist", &mock.Fixture{Blacklist: []string{"abc"}}, "malformed blacklist hash"},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      err := test.fixture.Parse()
+      if err == nil || !strings.Contains(err.Error(), test.expected) {
+        t.Fatalf("expected error \"%s\" but got: %v", test.expected, err)
+      }
+    })
+  }
+
+  if err := mock.EmptyFixture().Parse(); err != nil {
+    t.Errorf("expected empty fixture to be accepted but got: %s", err)
+  }
+}
+
+func TestLatency(t *testing.T) {
+  _, srv, api := newMockServer(t, mock.Options{Latency: 50 * time.Millisecond})
+  defer srv.Close()
+
+  start := time.Now()
+  _, err := api.GetProfile(context.Background(), notchId)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+    t.Errorf("expected response to be delayed but it arrived after %s", elapsed)
+  }
+
+  // delayed requests are released as soon as the client gives up
+
+  ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+  defer cancel()
+  _, err = api.GetProfile(ctx, notchId)
+  if err == nil {
+    t.Error("expected request to be aborted by its deadline")
+  }
+}
@@ -17,6 +17,7 @@
 package mojang
 
 import (
+  "context"
   "bytes"
   "encoding/json"
   "errors"
@@ -25,6 +26,7 @@ import (
   "time"
 
   "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/logs"
   "github.com/google/uuid"
 )
 
@@ -32,14 +34,14 @@ import (
 // - if the UNIX epoch (e.g. zero) is passed instead of a real time, the initial account name will be checked (assuming
 //   that the account in question is a legacy account or has changed its name at least once)
 // - if no profile matches the specified name, nil will be returned instead
-func (a *MojangAPI) GetId(name string, at time.Time) (*entity.ProfileId, error) {
-  res, err := a.execute("GET", fmt.Sprintf("https://api.mojang.com/users/profiles/minecraft/%s?at=%d", url.PathEscape(name), at.Unix()), nil)
+func (a *MojangAPI) GetId(ctx context.Context, name string, at time.Time) (*entity.ProfileId, error) {
+  res, err := a.execute(ctx, "profile_id", "GET", fmt.Sprintf("%s/users/profiles/minecraft/%s?at=%d", a.apiUrl, url.PathEscape(name), at.Unix()), nil)
   if err != nil {
     return nil, err
   }
 
   if res.StatusCode == 204 {
-    a.logger.Debugf("server reported no association for name \"%s\" at time %s", name, at)
+    logs.ForContext(ctx, a.logger).Debugf("server reported no association for name \"%s\" at time %s", name, at)
     return nil, nil
   }
 
@@ -51,7 +53,7 @@ func (a *MojangAPI) GetId(name string, at time.Time) (*entity.ProfileId, error)
 
 // resolves a list of multiple names at the current time
 // only 100 names may be resolved at a time
-func (a *MojangAPI) BulkGetId(names []string) ([]*entity.ProfileId, error) {
+func (a *MojangAPI) BulkGetId(ctx context.Context, names []string) ([]*entity.ProfileId, error) {
   if len(names) > 100 {
     return nil, errors.New("cannot request more than 100 names")
   }
@@ -61,7 +63,7 @@ func (a *MojangAPI) BulkGetId(names []string) ([]*entity.ProfileId, error) {
     return nil, err
   }
 
-  res, err := a.execute("POST", "https://api.mojang.com/profiles/minecraft", bytes.NewBuffer(payload))
+  res, err := a.execute(ctx, "bulk_profile_id", "POST", a.apiUrl+"/profiles/minecraft", bytes.NewBuffer(payload))
   if err != nil {
     return nil, err
   }
@@ -76,8 +78,8 @@ func (a *MojangAPI) BulkGetId(names []string) ([]*entity.ProfileId, error) {
 
 // retrieves the complete name change history for a given profile
 // the initial account name is indicated by the lack of its timestamp (e.g. if set to UNIX epoch)
-func (a *MojangAPI) GetHistory(id uuid.UUID) (*entity.NameChangeHistory, error) {
-  res, err := a.execute("GET", fmt.Sprintf("https://api.mojang.com/user/profiles/%s/names", entity.ToMojangId(id)), nil)
+func (a *MojangAPI) GetHistory(ctx context.Context, id uuid.UUID) (*entity.NameChangeHistory, error) {
+  res, err := a.execute(ctx, "name_history", "GET", fmt.Sprintf("%s/user/profiles/%s/names", a.apiUrl, entity.ToMojangId(id)), nil)
   if err != nil {
     return nil, err
   }
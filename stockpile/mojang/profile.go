@@ -17,6 +17,7 @@
 package mojang
 
 import (
+  "context"
   "fmt"
 
   "github.com/dotStart/Stockpile/entity"
@@ -24,8 +25,8 @@ import (
 )
 
 // retrieves a single profile from the server
-func (a *MojangAPI) GetProfile(id uuid.UUID) (*entity.Profile, error) {
-  res, err := a.execute("GET", fmt.Sprintf("https://sessionserver.mojang.com/session/minecraft/profile/%s?unsigned=false", entity.ToMojangId(id)), nil)
+func (a *MojangAPI) GetProfile(ctx context.Context, id uuid.UUID) (*entity.Profile, error) {
+  res, err := a.execute(ctx, "profile", "GET", fmt.Sprintf("%s/session/minecraft/profile/%s?unsigned=false", a.sessionUrl, entity.ToMojangId(id)), nil)
   if err != nil {
     return nil, err
   }
@@ -17,6 +17,7 @@
 package mojang
 
 import (
+  "context"
   "fmt"
   "io/ioutil"
   "net/url"
@@ -29,8 +30,8 @@ import (
 var blacklistLogger = logging.MustGetLogger("blacklist")
 
 // retrieves the server blacklist
-func (a *MojangAPI) GetBlacklist() (*entity.Blacklist, error) {
-  res, err := a.execute("GET", "https://sessionserver.mojang.com/blockedservers", nil)
+func (a *MojangAPI) GetBlacklist(ctx context.Context) (*entity.Blacklist, error) {
+  res, err := a.execute(ctx, "blacklist", "GET", a.sessionUrl+"/blockedservers", nil)
   if err != nil {
     return nil, err
   }
@@ -52,11 +53,11 @@ func (a *MojangAPI) GetBlacklist() (*entity.Blacklist, error) {
 }
 
 // performs the server-side phase of the online handshake
-func (a *MojangAPI) Login(displayName string, serverId string, ip string) (*entity.Profile, error) {
+func (a *MojangAPI) Login(ctx context.Context, displayName string, serverId string, ip string) (*entity.Profile, error) {
   if ip != "" {
     ip = "&ip=" + url.QueryEscape(ip)
   }
-  res, err := a.execute("GET", fmt.Sprintf("https://sessionserver.mojang.com/session/minecraft/hasJoined?username=%s&serverId=%s%s", url.QueryEscape(displayName), url.QueryEscape(serverId), ip), nil)
+  res, err := a.execute(ctx, "login", "GET", fmt.Sprintf("%s/session/minecraft/hasJoined?username=%s&serverId=%s%s", a.sessionUrl, url.QueryEscape(displayName), url.QueryEscape(serverId), ip), nil)
   if err != nil {
     return nil, err
   }
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package plugin
+
+import (
+  "fmt"
+  "os"
+  "runtime"
+  "strings"
+
+  "github.com/dotStart/Stockpile/stockpile/plugin/external"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/hashicorp/hcl2/hcl"
+  "github.com/zclconf/go-cty/cty"
+  "github.com/zclconf/go-cty/cty/convert"
+)
+
+// evaluates whether a file within the plugin directory refers to an out-of-process plugin
+func isExternalPlugin(file os.FileInfo) bool {
+  if !file.Mode().IsRegular() || !strings.HasPrefix(file.Name(), external.ExecutablePrefix) || isManifest(file.Name()) {
+    return false
+  }
+  if runtime.GOOS == "windows" {
+    return strings.HasSuffix(file.Name(), ".exe")
+  }
+  return file.Mode()&0111 != 0
+}
+
+// launches an out-of-process plugin in order to retrieve its metadata and registers factories for
+// all of its components
+// the process is stopped again once its metadata has been retrieved as every component instance
+// is provided by a dedicated process
+// when a checksum is given, the executable is verified against it whenever a process is launched
+func LoadExternal(path string, checksum string) (*Plugin, error) {
+  client, err := external.Launch(path, checksum)
+  if err != nil {
+    return nil, fmt.Errorf("cannot launch plugin \"%s\": %s", path, err)
+  }
+  res := client.Metadata()
+  err = client.Close()
+  if err != nil {
+    return nil, fmt.Errorf("plugin \"%s\" failed to shut down: %s", path, err)
+  }
+
+  ctx := newContext()
+  for _, id := range res.StorageBackends {
+    ctx.RegisterStorageBackend(id, newExternalStorageBackendFactory(path, checksum, id))
+  }
+
+  return &Plugin{
+    Id:   pluginId(path),
+    Path: path,
+    Metadata: Metadata{
+      Name:       res.Name,
+      Version:    res.Version,
+      Authors:    res.Authors,
+      Website:    res.Website,
+      ApiVersion: ApiVersion,
+    },
+    Context: ctx,
+  }, nil
+}
+
+// creates a storage backend factory which launches a new plugin process for every backend
+func newExternalStorageBackendFactory(path string, checksum string, id string) StorageBackendFactory {
+  return func(cfg *server.Config) (storage.StorageBackend, error) {
+    parameters, err := flattenParameters(cfg.Storage.Parameters)
+    if err != nil {
+      return nil, fmt.Errorf("illegal backend configuration: %s", err)
+    }
+
+    client, err := external.Launch(path, checksum)
+    if err != nil {
+      return nil, fmt.Errorf("cannot launch plugin \"%s\": %s", path, err)
+    }
+    impl, err := client.StorageBackend(id, parameters)
+    if err != nil {
+      client.Close()
+      return nil, err
+    }
+    return storage.NewEncodedStorageBackend(cfg, impl), nil
+  }
+}
+
+// converts a parameter body into a set of strings which may be passed to out-of-process plugins
+// since plugins cannot evaluate expressions on their own, nested blocks are not supported
+func flattenParameters(body hcl.Body) (map[string]string, error) {
+  parameters := make(map[string]string)
+  if body == nil {
+    return parameters, nil
+  }
+
+  attrs, diag := body.JustAttributes()
+  if diag.HasErrors() {
+    return nil, fmt.Errorf("%s", diag.Error())
+  }
+  for name, attr := range attrs {
+    value, diag := attr.Expr.Value(server.EvalContext())
+    if diag.HasErrors() {
+      return nil, fmt.Errorf("%s", diag.Error())
+    }
+    if value.IsNull() {
+      continue
+    }
+
+    value, err := convert.Convert(value, cty.String)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %s", name, err)
+    }
+    parameters[name] = value.AsString()
+  }
+  return parameters, nil
+}
@@ -0,0 +1,436 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package external
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "io"
+  "net"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/metadata"
+  "github.com/golang/protobuf/ptypes/empty"
+  "github.com/op/go-logging"
+  "golang.org/x/net/context"
+  "google.golang.org/grpc"
+  healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// defines the maximum amount of time a plugin may take to announce its address
+const startTimeout = 10 * time.Second
+
+// defines the maximum amount of time a plugin may take to respond to a single call
+const callTimeout = 30 * time.Second
+
+// defines the interval in which the health of a plugin process is verified
+const healthCheckInterval = 10 * time.Second
+
+// defines the amount of consecutive failed health checks after which a plugin process is restarted
+const healthCheckThreshold = 3
+
+// defines the bounds of the delay between restart attempts
+const minRestartDelay = time.Second
+const maxRestartDelay = 30 * time.Second
+
+// indicates that a plugin process has exited and has yet to be restarted
+var ErrUnavailable = errors.New("plugin is unavailable")
+
+// manages a single out-of-process plugin instance
+// processes which exit unexpectedly (or stop responding to health checks) are restarted
+// automatically until the client is closed
+type Client struct {
+  logger   *logging.Logger
+  path     string
+  checksum string
+  metadata *HandshakeResponse
+
+  mutex   sync.RWMutex
+  process *process
+  storage *StorageConfiguration
+  closed  bool
+  done    chan struct{}
+}
+
+// represents a single execution of a plugin executable
+type process struct {
+  cmd    *exec.Cmd
+  stdin  io.WriteCloser
+  conn   *grpc.ClientConn
+  exited chan struct{}
+}
+
+// launches the plugin executable at the given path and negotiates a protocol version with it
+// when a checksum is given, the executable is verified against it whenever it is (re)started
+func Launch(path string, checksum string) (*Client, error) {
+  c := &Client{
+    logger:   logging.MustGetLogger("plugin"),
+    path:     path,
+    checksum: checksum,
+    done:     make(chan struct{}),
+  }
+
+  proc, res, err := c.start()
+  if err != nil {
+    return nil, err
+  }
+  c.process = proc
+  c.metadata = res
+
+  go c.supervise()
+  return c, nil
+}
+
+// retrieves the metadata which has been announced by the plugin during its handshake
+func (c *Client) Metadata() *HandshakeResponse {
+  return c.metadata
+}
+
+// stops the plugin process and disables automatic restarts
+func (c *Client) Close() error {
+  c.mutex.Lock()
+  if c.closed {
+    c.mutex.Unlock()
+    return nil
+  }
+  c.closed = true
+  close(c.done)
+  proc := c.process
+  c.process = nil
+  c.mutex.Unlock()
+
+  if proc == nil {
+    return nil
+  }
+  return proc.stop()
+}
+
+// retrieves the connection to the currently running plugin process
+func (c *Client) conn() (*grpc.ClientConn, error) {
+  c.mutex.RLock()
+  defer c.mutex.RUnlock()
+  if c.process == nil {
+    return nil, ErrUnavailable
+  }
+  return c.process.conn, nil
+}
+
+// starts a new plugin process and performs the handshake
+func (c *Client) start() (*process, *HandshakeResponse, error) {
+  // the executable may have been replaced since it has last been started
+  if c.checksum != "" {
+    sum, err := Checksum(c.path)
+    if err != nil {
+      return nil, nil, fmt.Errorf("cannot calculate checksum: %s", err)
+    }
+    if sum != c.checksum {
+      return nil, nil, fmt.Errorf("plugin checksum %s does not match approved checksum %s", sum, c.checksum)
+    }
+  }
+
+  // the first line written to the standard output is the handshake while everything else is
+  // considered to be regular log output
+  handshake := make(chan string, 1)
+  announced := false
+  stdout := &lineWriter{fn: func(line string) {
+    if !announced {
+      announced = true
+      handshake <- line
+      return
+    }
+    c.logger.Infof("[%s] %s", filepath.Base(c.path), line)
+  }}
+  stderr := &lineWriter{fn: func(line string) {
+    c.logger.Warningf("[%s] %s", filepath.Base(c.path), line)
+  }}
+
+  cmd := exec.Command(c.path)
+  cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", CookieKey, CookieValue))
+  cmd.Stdout = stdout
+  cmd.Stderr = stderr
+  stdin, err := cmd.StdinPipe()
+  if err != nil {
+    return nil, nil, err
+  }
+  err = cmd.Start()
+  if err != nil {
+    return nil, nil, fmt.Errorf("failed to start plugin process: %s", err)
+  }
+
+  proc := &process{
+    cmd:    cmd,
+    stdin:  stdin,
+    exited: make(chan struct{}),
+  }
+  go func() {
+    cmd.Wait()
+    close(proc.exited)
+  }()
+
+  var line string
+  select {
+  case line = <-handshake:
+  case <-proc.exited:
+    stdin.Close()
+    return nil, nil, fmt.Errorf("plugin process exited before completing its handshake: %s", cmd.ProcessState)
+  case <-time.After(startTimeout):
+    proc.kill()
+    return nil, nil, fmt.Errorf("plugin process did not complete its handshake within %s", startTimeout)
+  }
+
+  res, err := proc.connect(line)
+  if err != nil {
+    proc.kill()
+    return nil, nil, err
+  }
+  return proc, res, nil
+}
+
+// connects to the address announced by a plugin process and negotiates the protocol version
+func (p *process) connect(line string) (*HandshakeResponse, error) {
+  network, address, err := decodeHandshake(line)
+  if err != nil {
+    return nil, err
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+  defer cancel()
+  p.conn, err = grpc.DialContext(ctx, address,
+    grpc.WithInsecure(),
+    grpc.WithBlock(),
+    grpc.WithDialer(func(address string, timeout time.Duration) (net.Conn, error) {
+      return net.DialTimeout(network, address, timeout)
+    }),
+  )
+  if err != nil {
+    return nil, fmt.Errorf("failed to connect to plugin at %s://%s: %s", network, address, err)
+  }
+
+  res, err := NewPluginServiceClient(p.conn).Handshake(ctx, &HandshakeRequest{
+    ProtocolVersions: ProtocolVersions,
+    HostVersion:      metadata.Version(),
+  })
+  if err != nil {
+    return nil, fmt.Errorf("handshake failed: %s", err)
+  }
+  if _, err := negotiate([]uint32{res.ProtocolVersion}, ProtocolVersions); err != nil {
+    return nil, fmt.Errorf("plugin selected unsupported protocol version %d", res.ProtocolVersion)
+  }
+  return res, nil
+}
+
+// asks the plugin process to shut down and kills it when it fails to do so in time
+func (p *process) stop() error {
+  ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+  defer cancel()
+  _, err := NewPluginServiceClient(p.conn).Shutdown(ctx, &empty.Empty{})
+  if err == nil {
+    select {
+    case <-p.exited:
+    case <-ctx.Done():
+      err = fmt.Errorf("plugin process did not exit within %s", startTimeout)
+    }
+  }
+  p.kill()
+  return err
+}
+
+// forcefully terminates the plugin process and releases the connection
+func (p *process) kill() {
+  if p.conn != nil {
+    p.conn.Close()
+  }
+  p.stdin.Close()
+  select {
+  case <-p.exited:
+  default:
+    p.cmd.Process.Kill()
+    <-p.exited
+  }
+}
+
+// watches the plugin process and restarts it when it exits or stops responding
+func (c *Client) supervise() {
+  delay := minRestartDelay
+  for {
+    c.mutex.RLock()
+    proc := c.process
+    c.mutex.RUnlock()
+    if proc == nil {
+      return
+    }
+
+    if !c.watch(proc) {
+      return
+    }
+
+    for {
+      select {
+      case <-c.done:
+        return
+      case <-time.After(delay):
+      }
+
+      err := c.restart()
+      if err == nil {
+        delay = minRestartDelay
+        break
+      }
+
+      c.logger.Errorf("failed to restart plugin \"%s\": %s", c.path, err)
+      delay *= 2
+      if delay > maxRestartDelay {
+        delay = maxRestartDelay
+      }
+    }
+  }
+}
+
+// blocks until a process exits (or fails its health checks) and returns false when the client
+// has been closed in the meantime
+func (c *Client) watch(proc *process) bool {
+  ticker := time.NewTicker(healthCheckInterval)
+  defer ticker.Stop()
+
+  health := healthpb.NewHealthClient(proc.conn)
+  failures := 0
+  for {
+    select {
+    case <-c.done:
+      return false
+    case <-proc.exited:
+      c.logger.Errorf("plugin \"%s\" exited unexpectedly (%s) - restarting", c.path, proc.cmd.ProcessState)
+      c.discard(proc)
+      return true
+    case <-ticker.C:
+      ctx, cancel := context.WithTimeout(context.Background(), healthCheckInterval)
+      res, err := health.Check(ctx, &healthpb.HealthCheckRequest{})
+      cancel()
+      if err == nil && res.Status == healthpb.HealthCheckResponse_SERVING {
+        failures = 0
+        continue
+      }
+
+      failures++
+      c.logger.Warningf("plugin \"%s\" failed health check (%d/%d): %v", c.path, failures, healthCheckThreshold, err)
+      if failures >= healthCheckThreshold {
+        c.logger.Errorf("plugin \"%s\" stopped responding - restarting", c.path)
+        c.discard(proc)
+        proc.kill()
+        return true
+      }
+    }
+  }
+}
+
+// marks a process as unavailable
+func (c *Client) discard(proc *process) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  if c.process == proc {
+    c.process = nil
+  }
+  if proc.conn != nil {
+    proc.conn.Close()
+  }
+}
+
+// starts a replacement process and restores the previously applied configuration
+func (c *Client) restart() error {
+  proc, res, err := c.start()
+  if err != nil {
+    return err
+  }
+  if res.Name != c.metadata.Name {
+    proc.kill()
+    return fmt.Errorf("plugin executable has been replaced with a different plugin (%s)", res.Name)
+  }
+
+  // the new process is configured before it is published in order to avoid blocking callers
+  // while the configuration is restored
+  c.mutex.RLock()
+  storageCfg := c.storage
+  c.mutex.RUnlock()
+  if storageCfg != nil {
+    ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+    defer cancel()
+    _, err = NewStorageBackendServiceClient(proc.conn).Configure(ctx, storageCfg)
+    if err != nil {
+      proc.kill()
+      return fmt.Errorf("failed to restore storage backend configuration: %s", err)
+    }
+  }
+
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  if c.closed {
+    go proc.stop()
+    return nil
+  }
+  if c.storage != storageCfg {
+    proc.kill()
+    return errors.New("storage backend has been configured while the plugin was restarting")
+  }
+
+  c.process = proc
+  c.logger.Noticef("plugin \"%s\" has been restarted", c.path)
+  return nil
+}
+
+// calculates the SHA-256 checksum of a file
+func Checksum(path string) (string, error) {
+  file, err := os.Open(path)
+  if err != nil {
+    return "", err
+  }
+  defer file.Close()
+
+  hash := sha256.New()
+  _, err = io.Copy(hash, file)
+  if err != nil {
+    return "", err
+  }
+  return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// passes the output of a plugin process to a function line by line
+type lineWriter struct {
+  buffer bytes.Buffer
+  fn     func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+  w.buffer.Write(p)
+  for {
+    line, err := w.buffer.ReadString('\n')
+    if err != nil {
+      // incomplete lines are retained until the remainder has been written
+      w.buffer.WriteString(line)
+      break
+    }
+    w.fn(strings.TrimRight(line, "\r\n"))
+  }
+  return len(p), nil
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package external
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "testing"
+
+  "github.com/op/go-logging"
+)
+
+// writes an executable shell script to a temporary directory which is to be removed by the caller
+func writeScript(t *testing.T, src string) (string, string) {
+  if runtime.GOOS == "windows" {
+    t.Skip("shell scripts are not supported on windows")
+  }
+
+  dir, err := ioutil.TempDir("", "stockpile-plugin")
+  if err != nil {
+    t.Fatal(err)
+  }
+  path := filepath.Join(dir, ExecutablePrefix+"test")
+  err = ioutil.WriteFile(path, []byte("#!/bin/sh\n"+src+"\n"), 0755)
+  if err != nil {
+    os.RemoveAll(dir)
+    t.Fatal(err)
+  }
+  return dir, path
+}
+
+func TestLaunchChecksumMismatch(t *testing.T) {
+  dir, path := writeScript(t, "exit 0")
+  defer os.RemoveAll(dir)
+
+  _, err := Launch(path, strings.Repeat("0", 64))
+  if err == nil || !strings.Contains(err.Error(), "does not match approved checksum") {
+    t.Fatalf("expected checksum mismatch but got: %v", err)
+  }
+}
+
+func TestLaunchEarlyExit(t *testing.T) {
+  dir, path := writeScript(t, "exit 1")
+  defer os.RemoveAll(dir)
+
+  sum, err := Checksum(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = Launch(path, sum)
+  if err == nil || !strings.Contains(err.Error(), "exited before completing its handshake") {
+    t.Fatalf("expected early exit to be reported but got: %v", err)
+  }
+}
+
+func TestRestartVerifiesChecksum(t *testing.T) {
+  dir, path := writeScript(t, "exit 1")
+  defer os.RemoveAll(dir)
+
+  sum, err := Checksum(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c := &Client{
+    logger:   logging.MustGetLogger("plugin"),
+    path:     path,
+    checksum: sum,
+    metadata: &HandshakeResponse{Name: "test"},
+    done:     make(chan struct{}),
+  }
+
+  // executables which are replaced while the server is running must not be restarted
+  err = ioutil.WriteFile(path, []byte("#!/bin/sh\nexit 2\n"), 0755)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = c.restart()
+  if err == nil || !strings.Contains(err.Error(), "does not match approved checksum") {
+    t.Fatalf("expected replaced executable to be rejected but got: %v", err)
+  }
+}
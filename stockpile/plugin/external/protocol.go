@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package external
+
+import (
+  "fmt"
+  "strings"
+)
+
+// defines the prefix which identifies out-of-process plugin executables within the plugin
+// directory
+const ExecutablePrefix = "stockpile-plugin-"
+
+// identifies the environment variable which is passed to plugin processes in order to verify that
+// they have been started by Stockpile
+const CookieKey = "STOCKPILE_PLUGIN_COOKIE"
+
+// provides a static value which is not meant to provide any security but simply prevents users
+// from accidentally executing plugins directly
+const CookieValue = "b2a7a1c0e5e94e45a1e31f4f8c3ed8a9"
+
+// defines the version of the handshake line which plugins print to their standard output once
+// they are ready to accept connections
+const HandshakeVersion = 1
+
+// lists all versions of the plugin protocol which are understood by this build (in order of
+// preference)
+var ProtocolVersions = []uint32{1}
+
+// selects the most recent protocol version which is supported by both sides
+func negotiate(host []uint32, plugin []uint32) (uint32, error) {
+  for _, version := range host {
+    for _, candidate := range plugin {
+      if version == candidate {
+        return version, nil
+      }
+    }
+  }
+  return 0, fmt.Errorf("no common protocol version (host supports %s while plugin supports %s)", formatVersions(host), formatVersions(plugin))
+}
+
+// encodes a handshake line which announces the address a plugin is listening on
+func encodeHandshake(network string, address string) string {
+  return fmt.Sprintf("%d|%s|%s", HandshakeVersion, network, address)
+}
+
+// decodes a handshake line into the network and address the plugin is listening on
+func decodeHandshake(line string) (network string, address string, err error) {
+  elements := strings.SplitN(strings.TrimSpace(line), "|", 3)
+  if len(elements) != 3 {
+    return "", "", fmt.Errorf("malformed handshake: \"%s\"", line)
+  }
+  if elements[0] != fmt.Sprintf("%d", HandshakeVersion) {
+    return "", "", fmt.Errorf("unsupported handshake version %s (expected %d)", elements[0], HandshakeVersion)
+  }
+  return elements[1], elements[2], nil
+}
+
+func formatVersions(versions []uint32) string {
+  if len(versions) == 0 {
+    return "none"
+  }
+  elements := make([]string, len(versions))
+  for i, version := range versions {
+    elements[i] = fmt.Sprintf("%d", version)
+  }
+  return strings.Join(elements, ", ")
+}
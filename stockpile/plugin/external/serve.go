@@ -0,0 +1,312 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package external
+
+import (
+  "fmt"
+  "io"
+  "io/ioutil"
+  "net"
+  "os"
+  "os/signal"
+  "path/filepath"
+  "runtime"
+  "sort"
+  "sync"
+  "syscall"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/golang/protobuf/ptypes/empty"
+  "golang.org/x/net/context"
+  "google.golang.org/grpc"
+  "google.golang.org/grpc/codes"
+  "google.golang.org/grpc/health"
+  healthpb "google.golang.org/grpc/health/grpc_health_v1"
+  "google.golang.org/grpc/status"
+)
+
+// creates a new storage backend implementation based on a set of (flattened) configuration
+// parameters
+type StorageBackendFactory = func(parameters map[string]string) (storage.EncodedStorageBackendInterface, error)
+
+// describes the components which are provided by an out-of-process plugin
+type ServeConfig struct {
+  Name            string
+  Version         string
+  Authors         []string
+  Website         string
+  StorageBackends map[string]StorageBackendFactory
+}
+
+// exposes the components of an out-of-process plugin to its host process
+// this function is expected to be invoked from the main function of plugin executables and returns
+// once the host requests a shutdown or exits
+func Serve(cfg *ServeConfig) error {
+  if os.Getenv(CookieKey) != CookieValue {
+    return fmt.Errorf("this executable is a Stockpile plugin and is not meant to be executed directly - place it within the plugin directory instead")
+  }
+
+  listener, err := listen()
+  if err != nil {
+    return fmt.Errorf("failed to listen for host connections: %s", err)
+  }
+  defer listener.Close()
+
+  srv := grpc.NewServer()
+  shutdown := make(chan struct{})
+  var shutdownOnce sync.Once
+  stop := func() {
+    shutdownOnce.Do(func() { close(shutdown) })
+  }
+
+  storageSrv := &storageServer{cfg: cfg}
+  RegisterPluginServiceServer(srv, &pluginServer{cfg: cfg, stop: stop})
+  RegisterStorageBackendServiceServer(srv, storageSrv)
+  healthpb.RegisterHealthServer(srv, health.NewServer())
+
+  // the host keeps our standard input open for as long as it is running so we'll use it to detect
+  // hosts which have exited without shutting us down
+  go func() {
+    io.Copy(ioutil.Discard, os.Stdin)
+    stop()
+  }()
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+  go func() {
+    <-signals
+    stop()
+  }()
+
+  errs := make(chan error, 1)
+  go func() {
+    errs <- srv.Serve(listener)
+  }()
+  fmt.Fprintln(os.Stdout, encodeHandshake(listener.Addr().Network(), listener.Addr().String()))
+
+  select {
+  case err = <-errs:
+  case <-shutdown:
+    srv.GracefulStop()
+  }
+  storageSrv.close()
+  return err
+}
+
+// creates a listener on a local socket which is only reachable by the host
+func listen() (net.Listener, error) {
+  if runtime.GOOS == "windows" {
+    return net.Listen("tcp", "127.0.0.1:0")
+  }
+
+  dir, err := ioutil.TempDir("", "stockpile-plugin")
+  if err != nil {
+    return nil, err
+  }
+  // the socket file itself is removed when the listener is closed
+  listener, err := net.Listen("unix", filepath.Join(dir, "plugin.sock"))
+  if err != nil {
+    os.RemoveAll(dir)
+    return nil, err
+  }
+  return &socketListener{listener, dir}, nil
+}
+
+// removes the temporary socket directory along with the listener
+type socketListener struct {
+  net.Listener
+  dir string
+}
+
+func (l *socketListener) Close() error {
+  err := l.Listener.Close()
+  os.RemoveAll(l.dir)
+  return err
+}
+
+type pluginServer struct {
+  cfg  *ServeConfig
+  stop func()
+}
+
+func (s *pluginServer) Handshake(ctx context.Context, req *HandshakeRequest) (*HandshakeResponse, error) {
+  version, err := negotiate(req.ProtocolVersions, ProtocolVersions)
+  if err != nil {
+    return nil, status.Error(codes.FailedPrecondition, err.Error())
+  }
+
+  backends := make([]string, 0, len(s.cfg.StorageBackends))
+  for id := range s.cfg.StorageBackends {
+    backends = append(backends, id)
+  }
+  sort.Strings(backends)
+
+  return &HandshakeResponse{
+    ProtocolVersion: version,
+    Name:            s.cfg.Name,
+    Version:         s.cfg.Version,
+    Authors:         s.cfg.Authors,
+    Website:         s.cfg.Website,
+    StorageBackends: backends,
+  }, nil
+}
+
+func (s *pluginServer) Shutdown(context.Context, *empty.Empty) (*empty.Empty, error) {
+  // give the host a chance to receive our response before the server is stopped
+  time.AfterFunc(100*time.Millisecond, s.stop)
+  return &empty.Empty{}, nil
+}
+
+// exposes a single storage backend instance to the host
+// hosts are expected to launch a separate process for every backend they configure
+type storageServer struct {
+  cfg     *ServeConfig
+  mutex   sync.RWMutex
+  backend storage.EncodedStorageBackendInterface
+}
+
+func (s *storageServer) Configure(ctx context.Context, req *StorageConfiguration) (*empty.Empty, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  if s.backend != nil {
+    return nil, status.Error(codes.FailedPrecondition, "storage backend has already been configured")
+  }
+  factory, ok := s.cfg.StorageBackends[req.Backend]
+  if !ok {
+    return nil, status.Errorf(codes.NotFound, "no such storage backend: %s", req.Backend)
+  }
+
+  parameters := make(map[string]string, len(req.Parameters))
+  for _, parameter := range req.Parameters {
+    parameters[parameter.Name] = parameter.Value
+  }
+  backend, err := factory(parameters)
+  if err != nil {
+    return nil, status.Error(codes.InvalidArgument, err.Error())
+  }
+  s.backend = backend
+  return &empty.Empty{}, nil
+}
+
+// retrieves the configured backend or fails when the host has yet to configure it
+func (s *storageServer) get() (storage.EncodedStorageBackendInterface, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+  if s.backend == nil {
+    return nil, status.Error(codes.FailedPrecondition, "storage backend has not been configured")
+  }
+  return s.backend, nil
+}
+
+func (s *storageServer) GetCacheEntry(ctx context.Context, req *CacheEntryRequest) (*CacheEntry, error) {
+  backend, err := s.get()
+  if err != nil {
+    return nil, err
+  }
+
+  data, err := backend.GetCacheEntry(req.Category, req.Name, time.Duration(req.Ttl))
+  if err != nil {
+    return nil, status.Error(codes.Unknown, err.Error())
+  }
+  return &CacheEntry{
+    Category: req.Category,
+    Name:     req.Name,
+    Data:     data,
+    Ttl:      req.Ttl,
+    Found:    data != nil,
+  }, nil
+}
+
+func (s *storageServer) PutCacheEntry(ctx context.Context, req *CacheEntry) (*empty.Empty, error) {
+  backend, err := s.get()
+  if err != nil {
+    return nil, err
+  }
+
+  err = backend.PutCacheEntry(req.Category, req.Name, req.Data, time.Duration(req.Ttl))
+  if err != nil {
+    return nil, status.Error(codes.Unknown, err.Error())
+  }
+  return &empty.Empty{}, nil
+}
+
+func (s *storageServer) PurgeCacheEntry(ctx context.Context, req *CacheEntryKey) (*empty.Empty, error) {
+  backend, err := s.get()
+  if err != nil {
+    return nil, err
+  }
+
+  err = backend.PurgeCacheEntry(req.Category, req.Name)
+  if err != nil {
+    return nil, status.Error(codes.Unknown, err.Error())
+  }
+  return &empty.Empty{}, nil
+}
+
+func (s *storageServer) ListCacheEntries(ctx context.Context, req *CacheCategory) (*CacheEntryKeyList, error) {
+  backend, err := s.get()
+  if err != nil {
+    return nil, err
+  }
+
+  iterator, ok := backend.(storage.EncodedStorageBackendIterator)
+  if !ok {
+    return nil, status.Error(codes.Unimplemented, storage.ErrIterationUnsupported.Error())
+  }
+  names, err := iterator.ListCacheEntries(req.Category)
+  if err != nil {
+    return nil, status.Error(codes.Unknown, err.Error())
+  }
+  return &CacheEntryKeyList{Names: names}, nil
+}
+
+func (s *storageServer) Ping(context.Context, *empty.Empty) (*empty.Empty, error) {
+  backend, err := s.get()
+  if err != nil {
+    return nil, err
+  }
+
+  pinger, ok := backend.(storage.EncodedStorageBackendPinger)
+  if ok {
+    err = pinger.Ping()
+    if err != nil {
+      return nil, status.Error(codes.Unavailable, err.Error())
+    }
+  }
+  return &empty.Empty{}, nil
+}
+
+func (s *storageServer) Close(context.Context, *empty.Empty) (*empty.Empty, error) {
+  err := s.close()
+  if err != nil {
+    return nil, status.Error(codes.Unknown, err.Error())
+  }
+  return &empty.Empty{}, nil
+}
+
+// releases the configured backend (if any)
+func (s *storageServer) close() error {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  if s.backend == nil {
+    return nil
+  }
+  err := s.backend.Close()
+  s.backend = nil
+  return err
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package external
+
+import (
+  "fmt"
+  "sort"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/golang/protobuf/ptypes/empty"
+  "golang.org/x/net/context"
+  "google.golang.org/grpc/codes"
+  "google.golang.org/grpc/status"
+)
+
+// provides an encoded storage backend which delegates to an out-of-process plugin
+type storageBackend struct {
+  client *Client
+}
+
+// configures a storage backend within the plugin process
+// the configuration is replayed automatically whenever the plugin process is restarted while the
+// returned backend takes ownership of the client (e.g. closing it will stop the plugin process)
+func (c *Client) StorageBackend(backend string, parameters map[string]string) (storage.EncodedStorageBackendInterface, error) {
+  cfg := &StorageConfiguration{
+    Backend:    backend,
+    Parameters: make([]*Parameter, 0, len(parameters)),
+  }
+  for name, value := range parameters {
+    cfg.Parameters = append(cfg.Parameters, &Parameter{Name: name, Value: value})
+  }
+  sort.Slice(cfg.Parameters, func(i, j int) bool {
+    return cfg.Parameters[i].Name < cfg.Parameters[j].Name
+  })
+
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  if c.storage != nil {
+    return nil, fmt.Errorf("plugin process has already been configured as storage backend \"%s\"", c.storage.Backend)
+  }
+  if c.process == nil {
+    return nil, ErrUnavailable
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+  defer cancel()
+  _, err := NewStorageBackendServiceClient(c.process.conn).Configure(ctx, cfg)
+  if err != nil {
+    return nil, fmt.Errorf("plugin refused storage backend configuration: %s", status.Convert(err).Message())
+  }
+  c.storage = cfg
+
+  return &storageBackend{c}, nil
+}
+
+// retrieves a client for the storage service of the currently running plugin process
+func (s *storageBackend) service() (StorageBackendServiceClient, error) {
+  conn, err := s.client.conn()
+  if err != nil {
+    return nil, err
+  }
+  return NewStorageBackendServiceClient(conn), nil
+}
+
+func (s *storageBackend) GetCacheEntry(category string, name string, ttl time.Duration) ([]byte, error) {
+  svc, err := s.service()
+  if err != nil {
+    return nil, err
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+  defer cancel()
+  entry, err := svc.GetCacheEntry(ctx, &CacheEntryRequest{
+    Category: category,
+    Name:     name,
+    Ttl:      int64(ttl),
+  })
+  if err != nil {
+    return nil, convertError(err)
+  }
+  if !entry.Found {
+    return nil, nil
+  }
+  return entry.Data, nil
+}
+
+func (s *storageBackend) PutCacheEntry(category string, name string, encoded []byte, ttl time.Duration) error {
+  svc, err := s.service()
+  if err != nil {
+    return err
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+  defer cancel()
+  _, err = svc.PutCacheEntry(ctx, &CacheEntry{
+    Category: category,
+    Name:     name,
+    Data:     encoded,
+    Ttl:      int64(ttl),
+  })
+  return convertError(err)
+}
+
+func (s *storageBackend) PurgeCacheEntry(category string, name string) error {
+  svc, err := s.service()
+  if err != nil {
+    return err
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+  defer cancel()
+  _, err = svc.PurgeCacheEntry(ctx, &CacheEntryKey{
+    Category: category,
+    Name:     name,
+  })
+  return convertError(err)
+}
+
+func (s *storageBackend) ListCacheEntries(category string) ([]string, error) {
+  svc, err := s.service()
+  if err != nil {
+    return nil, err
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+  defer cancel()
+  list, err := svc.ListCacheEntries(ctx, &CacheCategory{Category: category})
+  if status.Code(err) == codes.Unimplemented {
+    return nil, storage.ErrIterationUnsupported
+  }
+  if err != nil {
+    return nil, convertError(err)
+  }
+  return list.Names, nil
+}
+
+func (s *storageBackend) Ping() error {
+  svc, err := s.service()
+  if err != nil {
+    return err
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+  defer cancel()
+  _, err = svc.Ping(ctx, &empty.Empty{})
+  return convertError(err)
+}
+
+func (s *storageBackend) Close() error {
+  svc, err := s.service()
+  if err == nil {
+    ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+    _, err = svc.Close(ctx, &empty.Empty{})
+    cancel()
+    err = convertError(err)
+  }
+
+  closeErr := s.client.Close()
+  if err == ErrUnavailable {
+    return closeErr
+  }
+  if err != nil {
+    return err
+  }
+  return closeErr
+}
+
+// strips the grpc status information from errors reported by a plugin
+func convertError(err error) error {
+  if err == nil {
+    return nil
+  }
+  st, ok := status.FromError(err)
+  if !ok {
+    return err
+  }
+  return fmt.Errorf("plugin error: %s", st.Message())
+}
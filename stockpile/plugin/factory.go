@@ -17,12 +17,61 @@
 package plugin
 
 import (
+  "net/http"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/cluster"
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
   "github.com/dotStart/Stockpile/stockpile/server"
   "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/dotStart/Stockpile/stockpile/upstream"
+  "github.com/hashicorp/hcl2/hcl"
+  "google.golang.org/grpc"
 )
 
 // provides an initializer function for plugins
 type Initializer = func(*Context) error
 
+// passes the parameters of the plugin's configuration block to a plugin
+// configurators are invoked before the plugin is initialized as well as whenever the server
+// configuration is reloaded (the passed body is empty when no block has been defined)
+type Configurator = func(cfg hcl.Body) error
+
+// releases all resources which have been allocated by a plugin when the server shuts down
+type Finalizer = func() error
+
 // provides a factory for storage backend instances
-type StorageBackendFactory = func(*server.Config) (storage.StorageBackend, error)
+type StorageBackendFactory = storage.Factory
+
+// provides a factory for cluster event bus instances
+type EventBusFactory = cluster.Factory
+
+// provides a factory for upstream provider instances
+type UpstreamProviderFactory = upstream.Factory
+
+// provides a factory for upstream rate limit store instances
+type RateLimitStoreFactory = ratelimit.Factory
+
+// provides access to the server components which plugin extensions may rely upon
+type Services struct {
+  Config *server.Config
+  Cache  *cache.Cache
+}
+
+// registers one or more services with the grpc server
+type GrpcServiceFactory = func(srv *grpc.Server, services *Services) error
+
+// provides a factory for http handlers which are mounted on the shared http mux
+type HttpHandlerFactory = func(services *Services) (http.Handler, error)
+
+// receives all events which are published by the cache
+// sinks are invoked sequentially and should thus hand off expensive work to a separate routine
+type EventSink = func(e *entity.Event)
+
+// provides a factory for cache event sinks
+type EventSinkFactory = func(services *Services) (EventSink, error)
+
+// decorates the transport which is used to submit requests to the upstream servers (e.g. in order
+// to route requests through a proxy or to record them)
+type UpstreamDecorator = func(transport http.RoundTripper) http.RoundTripper
@@ -18,29 +18,57 @@ package plugin
 
 import (
   "fmt"
+  "net/http"
+  "path/filepath"
   "plugin"
   "reflect"
   "runtime"
+  "strings"
+
+  "github.com/dotStart/Stockpile/stockpile/plugin/external"
+  "github.com/google/subcommands"
+  "github.com/hashicorp/hcl2/hcl"
+  "google.golang.org/grpc"
 )
 
 // represents a loaded plugin instance
 type Plugin struct {
-  handle *plugin.Plugin
+  handle    *plugin.Plugin
+  configure Configurator
+  shutdown  Finalizer
 
+  Id       string
+  Path     string
   Metadata Metadata
+  Manifest *Manifest
   Context  *Context
 }
 
-// loads an arbitrary plugin from the specified location
-func Load(path string) (*Plugin, error) {
+// loads an arbitrary plugin from the specified location and passes the given configuration to it
+// (if the plugin accepts configuration)
+// plugins which fail to initialize are returned alongside the error (if their metadata could be
+// retrieved) in order to permit the reporting of their status
+func Load(path string, cfg hcl.Body) (p *Plugin, err error) {
   // at the moment the go plugin architecture isn't particularly consistent so we won't be able to
   // load anything unless we're on Linux or Mac OS
   if !PluginsAvailable {
     return nil, fmt.Errorf("plugins are not supported on platform %s", runtime.GOOS)
   }
 
+  // plugins which have been built against a different version of Stockpile (or its dependencies)
+  // may panic while they are initialized - refuse them rather than taking down the server
+  defer func() {
+    if r := recover(); r != nil {
+      p = nil
+      err = fmt.Errorf("plugin \"%s\" panicked while loading (it has most likely been built against an incompatible version of Stockpile): %v", path, r)
+    }
+  }()
+
   handle, err := plugin.Open(path)
   if err != nil {
+    if strings.Contains(err.Error(), "different version of package") {
+      return nil, fmt.Errorf("plugin \"%s\" has been built against an incompatible version of Stockpile or its dependencies: %s", path, err)
+    }
     return nil, fmt.Errorf("cannot open plugin \"%s\": %s", path, err)
   }
 
@@ -54,6 +82,36 @@ func Load(path string) (*Plugin, error) {
     return nil, fmt.Errorf("plugin \"%s\" defines an illegal metadata symbol: expected *Metadata but got %s", path, reflect.TypeOf(metadataHandle))
   }
 
+  p = &Plugin{
+    handle:   handle,
+    Id:       pluginId(path),
+    Path:     path,
+    Metadata: *metadata,
+  }
+
+  err = metadata.checkApiVersion()
+  if err != nil {
+    return p, err
+  }
+
+  // configuration and shutdown hooks are optional
+  configuratorHandle, err := handle.Lookup("ConfigurePlugin")
+  if err == nil {
+    configurator, ok := configuratorHandle.(Configurator)
+    if !ok {
+      return p, fmt.Errorf("plugin \"%s\" defines an illegal configurator symbol: expected func(hcl.Body) error but got %s", path, reflect.TypeOf(configuratorHandle))
+    }
+    p.configure = configurator
+  }
+  finalizerHandle, err := handle.Lookup("ShutdownPlugin")
+  if err == nil {
+    finalizer, ok := finalizerHandle.(Finalizer)
+    if !ok {
+      return p, fmt.Errorf("plugin \"%s\" defines an illegal shutdown symbol: expected func() error but got %s", path, reflect.TypeOf(finalizerHandle))
+    }
+    p.shutdown = finalizer
+  }
+
   initializerHandle, err := handle.Lookup("InitializePlugin")
   if err != nil {
     return nil, fmt.Errorf("plugin \"%s\" does not expose an initializer: %s", path, err)
@@ -61,45 +119,180 @@ func Load(path string) (*Plugin, error) {
 
   initializer, ok := initializerHandle.(Initializer)
   if !ok {
-    return nil, fmt.Errorf("plugin \"%s\" defines an illegal initializer symbol: expected func(*plugin.Context) error but got %s", path, reflect.TypeOf(initializerHandle))
+    return p, fmt.Errorf("plugin \"%s\" defines an illegal initializer symbol: expected func(*plugin.Context) error but got %s", path, reflect.TypeOf(initializerHandle))
   }
 
-  ctx := &Context{
-    storage: make(map[string]StorageBackendFactory),
+  err = p.Configure(cfg)
+  if err != nil {
+    return p, err
   }
 
+  ctx := newContext()
   err = initializer(ctx)
   if err != nil {
-    return nil, fmt.Errorf("plugin \"%s\" failed to initialize: %s", path, err)
+    p.Shutdown()
+    return p, fmt.Errorf("plugin \"%s\" failed to initialize: %s", path, err)
   }
 
-  return &Plugin{
-    handle:   handle,
-    Metadata: *metadata,
-    Context:  ctx,
-  }, nil
+  p.Context = ctx
+  return p, nil
+}
+
+// evaluates whether the plugin accepts configuration
+func (p *Plugin) IsConfigurable() bool {
+  return p.configure != nil
+}
+
+// passes a configuration block to the plugin
+// plugins which do not accept configuration silently ignore the passed block
+func (p *Plugin) Configure(cfg hcl.Body) error {
+  if p.configure == nil {
+    return nil
+  }
+  if cfg == nil {
+    cfg = hcl.EmptyBody()
+  }
+
+  err := p.configure(cfg)
+  if err != nil {
+    return fmt.Errorf("plugin \"%s\" rejected its configuration: %s", p.Path, err)
+  }
+  return nil
+}
+
+// releases all resources which have been allocated by the plugin (if the plugin exposes a shutdown
+// hook)
+func (p *Plugin) Shutdown() error {
+  if p.shutdown == nil {
+    return nil
+  }
+  return p.shutdown()
+}
+
+// derives the identifier of a plugin (as used to refer to it within the configuration) from its
+// file name
+func pluginId(path string) string {
+  name := filepath.Base(path)
+  name = strings.TrimSuffix(name, filepath.Ext(name))
+  return strings.TrimPrefix(name, external.ExecutablePrefix)
 }
 
 // represents the context associated with a given plugin
 // we use this instance to simplify the registration of plugin implementations
 type Context struct {
-  storage map[string]StorageBackendFactory
+  storage   map[string]StorageBackendFactory
+  providers map[string]UpstreamProviderFactory
+  eventBus  map[string]EventBusFactory
+  rateLimit map[string]RateLimitStoreFactory
+
+  grpcServices       []GrpcServiceFactory
+  unaryInterceptors  []grpc.UnaryServerInterceptor
+  streamInterceptors []grpc.StreamServerInterceptor
+  httpHandlers       map[string]HttpHandlerFactory
+  eventSinks         map[string]EventSinkFactory
+  upstream           []UpstreamDecorator
+  commands           map[string]subcommands.Command
+}
+
+// creates a new empty context
+func newContext() *Context {
+  return &Context{
+    storage:   make(map[string]StorageBackendFactory),
+    providers: make(map[string]UpstreamProviderFactory),
+    eventBus:  make(map[string]EventBusFactory),
+    rateLimit: make(map[string]RateLimitStoreFactory),
+
+    httpHandlers: make(map[string]HttpHandlerFactory),
+    eventSinks:   make(map[string]EventSinkFactory),
+    commands:     make(map[string]subcommands.Command),
+  }
 }
 
 // merges two context instances with each other
+// conflicts are detected before any component is registered in order to prevent partially
+// registered plugins
 func (c *Context) merge(other *Context) error {
-  for key, factory := range other.storage {
-    current := c.storage[key]
-    if current != nil {
-      return fmt.Errorf("storage backend with identifier \"%s\" is already defined")
+  for key := range other.storage {
+    if c.storage[key] != nil {
+      return fmt.Errorf("storage backend with identifier \"%s\" is already defined", key)
+    }
+  }
+  for key := range other.providers {
+    if c.providers[key] != nil {
+      return fmt.Errorf("upstream provider with identifier \"%s\" is already defined", key)
+    }
+  }
+  for key := range other.eventBus {
+    if c.eventBus[key] != nil {
+      return fmt.Errorf("event bus with identifier \"%s\" is already defined", key)
+    }
+  }
+  for key := range other.rateLimit {
+    if c.rateLimit[key] != nil {
+      return fmt.Errorf("rate limit store with identifier \"%s\" is already defined", key)
+    }
+  }
+  for pattern := range other.httpHandlers {
+    if c.httpHandlers[pattern] != nil {
+      return fmt.Errorf("http handler for pattern \"%s\" is already defined", pattern)
+    }
+  }
+  for key := range other.eventSinks {
+    if c.eventSinks[key] != nil {
+      return fmt.Errorf("event sink with identifier \"%s\" is already defined", key)
+    }
+  }
+  for name := range other.commands {
+    if c.commands[name] != nil {
+      return fmt.Errorf("command \"%s\" is already defined", name)
     }
+  }
 
+  for key, factory := range other.storage {
     c.storage[key] = factory
   }
-
+  for key, factory := range other.providers {
+    c.providers[key] = factory
+  }
+  for key, factory := range other.eventBus {
+    c.eventBus[key] = factory
+  }
+  for key, factory := range other.rateLimit {
+    c.rateLimit[key] = factory
+  }
+  for pattern, factory := range other.httpHandlers {
+    c.httpHandlers[pattern] = factory
+  }
+  for key, factory := range other.eventSinks {
+    c.eventSinks[key] = factory
+  }
+  for name, command := range other.commands {
+    c.commands[name] = command
+  }
+  c.grpcServices = append(c.grpcServices, other.grpcServices...)
+  c.unaryInterceptors = append(c.unaryInterceptors, other.unaryInterceptors...)
+  c.streamInterceptors = append(c.streamInterceptors, other.streamInterceptors...)
+  c.upstream = append(c.upstream, other.upstream...)
   return nil
 }
 
+// lists all components which have been registered with this context (e.g. "storage:redis")
+func (c *Context) Components() []string {
+  components := make([]string, 0)
+  components = appendComponents(components, "storage", keys(c.storage))
+  components = appendComponents(components, "upstream", keys(c.providers))
+  components = appendComponents(components, "event-bus", keys(c.eventBus))
+  components = appendComponents(components, "rate-limit", keys(c.rateLimit))
+  components = appendComponents(components, "http", keys(c.httpHandlers))
+  components = appendComponents(components, "event-sink", keys(c.eventSinks))
+  components = appendComponents(components, "command", keys(c.commands))
+  components = appendCount(components, "grpc-service", len(c.grpcServices))
+  components = appendCount(components, "unary-interceptor", len(c.unaryInterceptors))
+  components = appendCount(components, "stream-interceptor", len(c.streamInterceptors))
+  components = appendCount(components, "upstream-decorator", len(c.upstream))
+  return components
+}
+
 // retrieves the storage backend factory for the specified identifier
 func (c *Context) GetStorageBackend(id string) StorageBackendFactory {
   return c.storage[id]
@@ -115,3 +308,149 @@ func (c *Context) RegisterStorageBackend(id string, factory StorageBackendFactor
   c.storage[id] = factory
   return nil
 }
+
+// retrieves the upstream provider factory for the specified identifier
+func (c *Context) GetUpstreamProvider(id string) UpstreamProviderFactory {
+  return c.providers[id]
+}
+
+// registers a new upstream provider with the context
+func (c *Context) RegisterUpstreamProvider(id string, factory UpstreamProviderFactory) error {
+  current := c.providers[id]
+  if current != nil {
+    return fmt.Errorf("upstream provider with id \"%s\" has already been registered", id)
+  }
+
+  c.providers[id] = factory
+  return nil
+}
+
+// retrieves the event bus factory for the specified identifier
+func (c *Context) GetEventBus(id string) EventBusFactory {
+  return c.eventBus[id]
+}
+
+// registers a new cluster event bus with the context
+func (c *Context) RegisterEventBus(id string, factory EventBusFactory) error {
+  current := c.eventBus[id]
+  if current != nil {
+    return fmt.Errorf("event bus with id \"%s\" has already been registered", id)
+  }
+
+  c.eventBus[id] = factory
+  return nil
+}
+
+// retrieves the rate limit store factory for the specified identifier
+func (c *Context) GetRateLimitStore(id string) RateLimitStoreFactory {
+  return c.rateLimit[id]
+}
+
+// registers a new upstream rate limit store with the context
+func (c *Context) RegisterRateLimitStore(id string, factory RateLimitStoreFactory) error {
+  current := c.rateLimit[id]
+  if current != nil {
+    return fmt.Errorf("rate limit store with id \"%s\" has already been registered", id)
+  }
+
+  c.rateLimit[id] = factory
+  return nil
+}
+
+// retrieves all registered grpc service factories
+func (c *Context) GetGrpcServices() []GrpcServiceFactory {
+  return c.grpcServices
+}
+
+// registers one or more grpc services which are exposed alongside the builtin services
+func (c *Context) RegisterGrpcService(factory GrpcServiceFactory) {
+  c.grpcServices = append(c.grpcServices, factory)
+}
+
+// retrieves all registered unary grpc interceptors
+func (c *Context) GetUnaryInterceptors() []grpc.UnaryServerInterceptor {
+  return c.unaryInterceptors
+}
+
+// registers a new unary grpc interceptor
+// plugin interceptors are invoked after the builtin tracing, logging and metrics interceptors
+func (c *Context) RegisterUnaryInterceptor(interceptor grpc.UnaryServerInterceptor) {
+  c.unaryInterceptors = append(c.unaryInterceptors, interceptor)
+}
+
+// retrieves all registered streaming grpc interceptors
+func (c *Context) GetStreamInterceptors() []grpc.StreamServerInterceptor {
+  return c.streamInterceptors
+}
+
+// registers a new streaming grpc interceptor
+// plugin interceptors are invoked after the builtin tracing, logging and metrics interceptors
+func (c *Context) RegisterStreamInterceptor(interceptor grpc.StreamServerInterceptor) {
+  c.streamInterceptors = append(c.streamInterceptors, interceptor)
+}
+
+// retrieves all registered http handler factories (indexed by their patterns)
+func (c *Context) GetHttpHandlers() map[string]HttpHandlerFactory {
+  return c.httpHandlers
+}
+
+// registers a new http handler which is mounted on the shared http mux using the given pattern
+func (c *Context) RegisterHttpHandler(pattern string, factory HttpHandlerFactory) error {
+  current := c.httpHandlers[pattern]
+  if current != nil {
+    return fmt.Errorf("http handler for pattern \"%s\" has already been registered", pattern)
+  }
+
+  c.httpHandlers[pattern] = factory
+  return nil
+}
+
+// retrieves all registered event sink factories (indexed by their identifiers)
+func (c *Context) GetEventSinks() map[string]EventSinkFactory {
+  return c.eventSinks
+}
+
+// registers a new cache event sink with the context
+func (c *Context) RegisterEventSink(id string, factory EventSinkFactory) error {
+  current := c.eventSinks[id]
+  if current != nil {
+    return fmt.Errorf("event sink with id \"%s\" has already been registered", id)
+  }
+
+  c.eventSinks[id] = factory
+  return nil
+}
+
+// retrieves all registered upstream decorators (in order of registration)
+func (c *Context) GetUpstreamDecorators() []UpstreamDecorator {
+  return c.upstream
+}
+
+// registers a new decorator for the transport which is used to reach the upstream servers
+func (c *Context) RegisterUpstreamDecorator(decorator UpstreamDecorator) {
+  c.upstream = append(c.upstream, decorator)
+}
+
+// retrieves all registered commands (indexed by their names)
+func (c *Context) GetCommands() map[string]subcommands.Command {
+  return c.commands
+}
+
+// registers a new command line subcommand with the context
+func (c *Context) RegisterCommand(command subcommands.Command) error {
+  current := c.commands[command.Name()]
+  if current != nil {
+    return fmt.Errorf("command \"%s\" has already been registered", command.Name())
+  }
+
+  c.commands[command.Name()] = command
+  return nil
+}
+
+// decorates a given transport with all registered upstream decorators
+func (c *Context) DecorateUpstream(transport http.RoundTripper) http.RoundTripper {
+  for _, decorator := range c.upstream {
+    transport = decorator(transport)
+  }
+  return transport
+}
@@ -17,45 +17,95 @@
 package plugin
 
 import (
+  "fmt"
   "io/ioutil"
   "os"
   "path/filepath"
   "runtime"
   "strings"
 
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/mojang"
+  "github.com/dotStart/Stockpile/stockpile/plugin/external"
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
+  "github.com/dotStart/Stockpile/stockpile/server"
   "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/dotStart/Stockpile/stockpile/upstream"
+  "github.com/hashicorp/hcl2/hcl"
   "github.com/op/go-logging"
 )
 
 const pluginExt = ".so"
 
 type Manager struct {
-  logger  *logging.Logger
-  path    string
-  Context *Context
-  Plugins []*Plugin
+  logger    *logging.Logger
+  path      string
+  settings  map[string]hcl.Body
+  allowlist map[string]bool
+  Context   *Context
+  Plugins   []*Plugin
+  Failures  []*Failure
+
+  // indicates whether out-of-process plugins are launched by LoadAll
+  ExternalPlugins bool
+  // indicates whether LoadAll skips plugins which declare their components but do not declare any
+  // commands (plugins without a manifest are always loaded)
+  CommandsOnly bool
+}
+
+// describes a plugin which could not be loaded (or which failed to register its components)
+// the metadata is only available when the plugin could be opened
+type Failure struct {
+  Path     string
+  Metadata *Metadata
+  Err      error
+}
+
+// describes the state of a plugin within the plugin directory
+type Status struct {
+  Id         string
+  Path       string
+  Name       string
+  Version    string
+  Authors    []string
+  Website    string
+  ApiVersion int
+  Loaded     bool
+  Error      string
+  Components []string
 }
 
 // creates a new empty plugin manager with the given base path
 func NewManager(path string) *Manager {
-  ctx := &Context{
-    storage: make(map[string]StorageBackendFactory),
-  }
+  ctx := newContext()
   ctx.RegisterStorageBackend("mem", storage.NewMemoryStorageBackend)
   ctx.RegisterStorageBackend("file", storage.NewFileStorageBackend)
+  ctx.RegisterStorageBackend("bolt", storage.NewBoltStorageBackend)
+  ctx.RegisterStorageBackend("migrating", storage.NewMigratingStorageBackendFactory(ctx.GetStorageBackend))
+  ctx.RegisterStorageBackend("tiered", storage.NewTieredStorageBackendFactory(ctx.GetStorageBackend))
+  ctx.RegisterUpstreamProvider("mojang", mojang.NewUpstreamFactory(ctx.DecorateUpstream))
+  ctx.RegisterUpstreamProvider("offline", upstream.NewOfflineUpstream)
+  ctx.RegisterUpstreamProvider("chain", upstream.NewChainedUpstreamFactory(ctx.GetUpstreamProvider))
+  ctx.RegisterRateLimitStore("local", ratelimit.NewLocalStore)
 
   return &Manager{
-    logger:  logging.MustGetLogger("plugin"),
-    path:    path,
-    Context: ctx,
+    logger:   logging.MustGetLogger("plugin"),
+    path:     path,
+    settings: make(map[string]hcl.Body),
+    Context:  ctx,
+
+    ExternalPlugins: true,
   }
 }
 
 // loads all plugins in the plugin directory
+// out-of-process plugins are loaded on all platforms while shared object plugins are skipped on
+// platforms which do not support them
+// plugins are loaded in order of their dependencies (as declared by their manifests)
 func (m *Manager) LoadAll() error {
   if !PluginsAvailable {
-    m.logger.Warningf("plugins are unavailable on platform %s - Plugin manager startup has been skipped", runtime.GOOS)
-    return nil
+    m.logger.Warningf("shared object plugins are unavailable on platform %s - only out-of-process plugins will be loaded", runtime.GOOS)
   }
 
   files, err := ioutil.ReadDir(m.path)
@@ -68,30 +118,382 @@ func (m *Manager) LoadAll() error {
     return err
   }
 
+  candidates := make([]*candidate, 0)
   for _, file := range files {
-    if !strings.HasSuffix(file.Name(), pluginExt) {
+    path := filepath.Join(m.path, file.Name())
+    if isExternalPlugin(file) {
+      if m.ExternalPlugins {
+        candidates = append(candidates, m.newCandidate(path, true))
+      }
+      continue
+    }
+    if !PluginsAvailable || !strings.HasSuffix(file.Name(), pluginExt) {
       continue
     }
 
-    path := filepath.Join(m.path, file.Name())
-    m.Load(path)
+    candidates = append(candidates, m.newCandidate(path, false))
+  }
+
+  for _, c := range sortCandidates(candidates) {
+    if m.CommandsOnly && !c.manifest.declaresCommands() {
+      continue
+    }
+    m.loadCandidate(c)
   }
 
+  m.checkSettings()
   return nil
 }
 
 // loads a plugin from the specified path
-func (m *Manager) Load(path string) {
-  plugin, err := Load(path)
+func (m *Manager) Load(path string) error {
+  return m.loadCandidate(m.newCandidate(path, false))
+}
+
+// launches an out-of-process plugin from the specified path
+func (m *Manager) LoadExternal(path string) error {
+  return m.loadCandidate(m.newCandidate(path, true))
+}
+
+// restricts the plugins which may be loaded to a set of approved SHA-256 checksums
+// passing nil lifts the restriction
+func (m *Manager) SetAllowlist(checksums []string) {
+  if checksums == nil {
+    m.allowlist = nil
+    return
+  }
+
+  m.allowlist = make(map[string]bool)
+  for _, checksum := range checksums {
+    m.allowlist[strings.ToLower(checksum)] = true
+  }
+}
+
+// represents a plugin which has yet to be loaded
+type candidate struct {
+  id       string
+  path     string
+  external bool
+  checksum string
+  manifest *Manifest
+  err      error
+}
+
+// prepares a plugin for loading by verifying its checksum and reading its manifest
+func (m *Manager) newCandidate(path string, isExternal bool) *candidate {
+  c := &candidate{
+    id:       pluginId(path),
+    path:     path,
+    external: isExternal,
+  }
+
+  if m.allowlist != nil {
+    sum, err := external.Checksum(path)
+    if err != nil {
+      c.err = fmt.Errorf("cannot calculate checksum: %s", err)
+      return c
+    }
+    if !m.allowlist[sum] {
+      c.err = fmt.Errorf("plugin checksum %s has not been approved", sum)
+      return c
+    }
+    c.checksum = sum
+  }
+
+  c.manifest, c.err = LoadManifest(path)
+  return c
+}
+
+// verifies the constraints of a plugin and loads it when they are satisfied
+func (m *Manager) loadCandidate(c *candidate) error {
+  err := c.err
+  if err == nil {
+    err = m.checkConstraints(c)
+  }
   if err != nil {
-    m.logger.Errorf("failed to load plugin from path \"%s\": %s", path, err)
+    return m.register(c.path, nil, err)
+  }
+
+  var plugin *Plugin
+  if c.external {
+    plugin, err = LoadExternal(c.path, c.checksum)
   } else {
-    m.Plugins = append(m.Plugins, plugin)
+    plugin, err = Load(c.path, m.settings[c.id])
+  }
+  if err == nil {
+    err = c.manifest.checkComponents(plugin.Context)
+    if err != nil {
+      plugin.Shutdown()
+    }
+  }
+  if plugin != nil {
+    plugin.Manifest = c.manifest
+  }
+  return m.register(c.path, plugin, err)
+}
+
+// verifies whether the dependencies, conflicts, version requirements and declared components of a
+// plugin permit it to be loaded alongside the plugins which have been loaded so far
+func (m *Manager) checkConstraints(c *candidate) error {
+  for _, plugin := range m.Plugins {
+    if plugin.Id == c.id {
+      return fmt.Errorf("plugin with id \"%s\" has already been loaded from file %s", c.id, plugin.Path)
+    }
+  }
+  if c.manifest == nil {
+    return nil
+  }
+
+  err := c.manifest.checkVersion()
+  if err != nil {
+    return err
+  }
+
+dependencies:
+  for _, dependency := range c.manifest.GetDependencies() {
+    for _, plugin := range m.Plugins {
+      if plugin.Id == dependency {
+        continue dependencies
+      }
+    }
+    return fmt.Errorf("dependency \"%s\" has not been loaded", dependency)
+  }
+
+  for _, plugin := range m.Plugins {
+    for _, conflict := range c.manifest.GetConflicts() {
+      if plugin.Id == conflict {
+        return fmt.Errorf("plugin conflicts with plugin \"%s\"", conflict)
+      }
+    }
+    for _, conflict := range plugin.Manifest.GetConflicts() {
+      if c.id == conflict {
+        return fmt.Errorf("plugin \"%s\" conflicts with this plugin", plugin.Id)
+      }
+    }
+  }
+
+  if c.manifest.Components != nil {
+    existing := make(map[string]bool)
+    for _, component := range m.Context.Components() {
+      existing[component] = true
+    }
+    for _, component := range *c.manifest.Components {
+      if existing[component] {
+        return fmt.Errorf("component %s has already been registered", component)
+      }
+    }
+  }
+  return nil
+}
+
+// orders plugins such that every plugin is preceded by its dependencies
+// plugins which are part of a dependency cycle are marked as failed
+func sortCandidates(candidates []*candidate) []*candidate {
+  index := make(map[string]*candidate)
+  for _, c := range candidates {
+    if index[c.id] == nil {
+      index[c.id] = c
+    }
+  }
+
+  const (
+    unvisited = iota
+    visiting
+    visited
+  )
+  state := make(map[*candidate]int)
+  ordered := make([]*candidate, 0, len(candidates))
+  stack := make([]*candidate, 0)
+
+  var visit func(c *candidate)
+  visit = func(c *candidate) {
+    switch state[c] {
+    case visited:
+      return
+    case visiting:
+      i := len(stack) - 1
+      for stack[i] != c {
+        i--
+      }
+      ids := make([]string, 0, len(stack)-i+1)
+      for _, member := range stack[i:] {
+        ids = append(ids, member.id)
+      }
+      err := fmt.Errorf("dependency cycle: %s -> %s", strings.Join(ids, " -> "), c.id)
+      for _, member := range stack[i:] {
+        if member.err == nil {
+          member.err = err
+        }
+      }
+      return
+    }
+
+    state[c] = visiting
+    stack = append(stack, c)
+    for _, dependency := range c.manifest.GetDependencies() {
+      if d := index[dependency]; d != nil {
+        visit(d)
+      }
+    }
+    stack = stack[:len(stack)-1]
+    state[c] = visited
+    ordered = append(ordered, c)
+  }
+
+  for _, c := range candidates {
+    visit(c)
+  }
+  return ordered
+}
+
+// registers the components of a loaded plugin or records its failure
+// plugins are only considered loaded when all of their components have been registered
+func (m *Manager) register(path string, plugin *Plugin, err error) error {
+  if err == nil {
     err = m.Context.merge(plugin.Context)
     if err != nil {
-      m.logger.Errorf("failed to register one or more components of plugin \"%s\" v%s (defined by file \"%s\"): %s", plugin.Metadata.Name, plugin.Metadata.Version, path, err)
+      err = fmt.Errorf("failed to register one or more components of plugin \"%s\" v%s: %s", plugin.Metadata.Name, plugin.Metadata.Version, err)
+      plugin.Shutdown()
+    }
+  }
+
+  if err != nil {
+    m.logger.Errorf("failed to load plugin from path \"%s\": %s", path, err)
+    failure := &Failure{Path: path, Err: err}
+    if plugin != nil {
+      failure.Metadata = &plugin.Metadata
+    }
+    m.Failures = append(m.Failures, failure)
+    return err
+  }
+
+  m.Plugins = append(m.Plugins, plugin)
+  m.logger.Infof("loaded plugin \"%s\" v%s from file %s", plugin.Metadata.Name, plugin.Metadata.Version, path)
+  return nil
+}
+
+// updates the plugin configuration blocks and passes them to all plugins which have been loaded
+// so far (plugins which are loaded later on receive their configuration upon initialization)
+func (m *Manager) Configure(cfgs []*server.PluginConfig) error {
+  settings := make(map[string]hcl.Body)
+  for _, cfg := range cfgs {
+    settings[cfg.Name] = cfg.Parameters
+  }
+  m.settings = settings
+
+  errs := make([]string, 0)
+  for _, plugin := range m.Plugins {
+    err := plugin.Configure(settings[plugin.Id])
+    if err != nil {
+      errs = append(errs, err.Error())
+    }
+  }
+  if len(m.Plugins) != 0 {
+    m.checkSettings()
+  }
+
+  if len(errs) != 0 {
+    return fmt.Errorf("%s", strings.Join(errs, "; "))
+  }
+  return nil
+}
+
+// warns about configuration blocks which are not consumed by any plugin
+func (m *Manager) checkSettings() {
+  for id := range m.settings {
+    var target *Plugin
+    for _, plugin := range m.Plugins {
+      if plugin.Id == id {
+        target = plugin
+        break
+      }
+    }
+
+    if target == nil {
+      m.logger.Warningf("configuration has been provided for plugin \"%s\" but no such plugin has been loaded", id)
+    } else if !target.IsConfigurable() {
+      m.logger.Warningf("configuration has been provided for plugin \"%s\" but the plugin does not accept configuration", id)
+    }
+  }
+}
+
+// invokes the shutdown hooks of all loaded plugins (in reverse order of loading)
+func (m *Manager) Shutdown() {
+  for i := len(m.Plugins) - 1; i >= 0; i-- {
+    plugin := m.Plugins[i]
+    err := plugin.Shutdown()
+    if err != nil {
+      m.logger.Errorf("plugin \"%s\" failed to shut down: %s", plugin.Id, err)
     }
+  }
+}
 
-    m.logger.Infof("loaded plugin \"%s\" v%s from file %s", plugin.Metadata.Name, plugin.Metadata.Version, path)
+// describes the state of all plugins (including plugins which failed to load)
+func (m *Manager) Status() []*Status {
+  statuses := make([]*Status, 0, len(m.Plugins)+len(m.Failures))
+  for _, plugin := range m.Plugins {
+    status := newStatus(plugin.Path, &plugin.Metadata)
+    status.Loaded = true
+    status.Components = plugin.Context.Components()
+    statuses = append(statuses, status)
+  }
+  for _, failure := range m.Failures {
+    status := newStatus(failure.Path, failure.Metadata)
+    status.Error = failure.Err.Error()
+    statuses = append(statuses, status)
   }
+  return statuses
+}
+
+// creates a status from the (optional) metadata of a plugin
+func newStatus(path string, metadata *Metadata) *Status {
+  status := &Status{
+    Id:         pluginId(path),
+    Path:       path,
+    Components: make([]string, 0),
+  }
+  if metadata != nil {
+    status.Name = metadata.Name
+    status.Version = metadata.Version
+    status.Authors = metadata.Authors
+    status.Website = metadata.Website
+    status.ApiVersion = metadata.ApiVersion
+    if status.ApiVersion == 0 {
+      status.ApiVersion = 1
+    }
+  }
+  return status
+}
+
+// creates all registered event sinks and forwards cache events to them until the cache is closed
+func (m *Manager) StartEventSinks(services *Services) error {
+  for id, factory := range m.Context.GetEventSinks() {
+    sink, err := factory(services)
+    if err != nil {
+      return fmt.Errorf("failed to initialize event sink \"%s\": %s", id, err)
+    }
+
+    listener := services.Cache.NewListener()
+    go m.forwardEvents(id, sink, listener)
+    m.logger.Infof("enabled event sink: %s", id)
+  }
+  return nil
+}
+
+// forwards all events from a cache listener to an event sink
+func (m *Manager) forwardEvents(id string, sink EventSink, listener *cache.Listener) {
+  for e := range listener.C {
+    m.deliverEvent(id, sink, e)
+  }
+}
+
+// passes a single event to a sink (panics are logged in order to protect the server from
+// misbehaving plugins)
+func (m *Manager) deliverEvent(id string, sink EventSink, e *entity.Event) {
+  defer func() {
+    if r := recover(); r != nil {
+      m.logger.Errorf("event sink \"%s\" panicked while handling event of type %d: %v", id, e.Type, r)
+    }
+  }()
+  sink(e)
 }
@@ -0,0 +1,212 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package plugin
+
+import (
+  "errors"
+  "reflect"
+  "strings"
+  "testing"
+
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/hashicorp/hcl2/hcl"
+)
+
+// creates a plugin instance which exposes the passed hooks (nil hooks are omitted)
+func newTestPlugin(id string, configure Configurator, shutdown Finalizer) *Plugin {
+  return &Plugin{
+    configure: configure,
+    shutdown:  shutdown,
+    Id:        id,
+    Path:      "/plugins/" + id + pluginExt,
+    Metadata:  Metadata{Name: id, Version: "1.0.0"},
+    Context:   newContext(),
+  }
+}
+
+// retrieves the names of all attributes within a configuration body
+func attributeNames(t *testing.T, body hcl.Body) []string {
+  attrs, diag := body.JustAttributes()
+  if diag.HasErrors() {
+    t.Fatal(diag.Error())
+  }
+
+  names := make([]string, 0, len(attrs))
+  for name := range attrs {
+    names = append(names, name)
+  }
+  return names
+}
+
+func TestPluginConfigure(t *testing.T) {
+  p := newTestPlugin("static", nil, nil)
+  if p.IsConfigurable() {
+    t.Error("expected plugin without configurator to be reported as not configurable")
+  }
+  if err := p.Configure(hcl.EmptyBody()); err != nil {
+    t.Errorf("expected configuration to be ignored but got: %s", err)
+  }
+
+  var received hcl.Body
+  p = newTestPlugin("configurable", func(cfg hcl.Body) error {
+    received = cfg
+    return nil
+  }, nil)
+  if !p.IsConfigurable() {
+    t.Error("expected plugin with configurator to be reported as configurable")
+  }
+
+  // plugins without a configuration block receive an empty body rather than nil
+  err := p.Configure(nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if received == nil || len(attributeNames(t, received)) != 0 {
+    t.Errorf("expected empty configuration body but got %v", received)
+  }
+
+  p = newTestPlugin("picky", func(cfg hcl.Body) error {
+    return errors.New("address is required")
+  }, nil)
+  err = p.Configure(nil)
+  if err == nil || !strings.Contains(err.Error(), "rejected its configuration: address is required") {
+    t.Fatalf("expected configuration error to be reported but got: %v", err)
+  }
+}
+
+func TestManagerConfigure(t *testing.T) {
+  received := make(map[string][]string)
+  configurator := func(id string, err error) Configurator {
+    return func(cfg hcl.Body) error {
+      received[id] = attributeNames(t, cfg)
+      return err
+    }
+  }
+
+  m := NewManager("plugins")
+  for _, p := range []*Plugin{
+    newTestPlugin("redis", configurator("redis", nil), nil),
+    newTestPlugin("metrics", configurator("metrics", nil), nil),
+    newTestPlugin("picky", configurator("picky", errors.New("address is required")), nil),
+  } {
+    err := m.register(p.Path, p, nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  cfg := servertest.LoadConfig(t, `plugin "redis" {
+    address = "localhost:6379"
+  }
+
+  plugin "late" {
+    enabled = true
+  }`)
+  err := m.Configure(cfg.Plugins)
+  if err == nil || !strings.Contains(err.Error(), "address is required") {
+    t.Fatalf("expected rejected configuration to be reported but got: %v", err)
+  }
+
+  // every loaded plugin is configured even when another plugin rejects its configuration
+  if !reflect.DeepEqual(received["redis"], []string{"address"}) {
+    t.Errorf("expected redis plugin to receive its configuration block but got %v", received["redis"])
+  }
+  if names, ok := received["metrics"]; !ok || len(names) != 0 {
+    t.Errorf("expected unconfigured plugin to receive an empty configuration block but got %v", names)
+  }
+  if _, ok := received["picky"]; !ok {
+    t.Error("expected failing plugin to be configured")
+  }
+
+  // blocks for plugins which have yet to be loaded are retained for their initialization
+  if m.settings["late"] == nil {
+    t.Error("expected configuration of unloaded plugin to be retained")
+  }
+
+  err = m.Configure([]*server.PluginConfig{})
+  if err == nil {
+    t.Fatal("expected failing plugin to reject its empty configuration as well")
+  }
+  if names := received["redis"]; len(names) != 0 {
+    t.Errorf("expected removed configuration block to be replaced with an empty body but got %v", names)
+  }
+}
+
+func TestManagerShutdown(t *testing.T) {
+  order := make([]string, 0)
+  finalizer := func(id string, err error) Finalizer {
+    return func() error {
+      order = append(order, id)
+      return err
+    }
+  }
+
+  m := NewManager("plugins")
+  for _, p := range []*Plugin{
+    newTestPlugin("first", nil, finalizer("first", nil)),
+    newTestPlugin("static", nil, nil),
+    newTestPlugin("failing", nil, finalizer("failing", errors.New("connection reset"))),
+    newTestPlugin("last", nil, finalizer("last", nil)),
+  } {
+    err := m.register(p.Path, p, nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  // plugins are shut down in reverse order of loading and failures do not abort the shutdown
+  m.Shutdown()
+  if expected := []string{"last", "failing", "first"}; !reflect.DeepEqual(order, expected) {
+    t.Fatalf("expected shutdown order %v but got %v", expected, order)
+  }
+}
+
+func TestManagerRegisterFailure(t *testing.T) {
+  shutdown := false
+  m := NewManager("plugins")
+
+  // plugins whose components cannot be registered are shut down immediately
+  p := newTestPlugin("conflicting", nil, func() error {
+    shutdown = true
+    return nil
+  })
+  p.Context.RegisterStorageBackend("mem", storage.NewMemoryStorageBackend)
+  err := m.register(p.Path, p, nil)
+  if err == nil || !strings.Contains(err.Error(), "\"mem\" is already defined") {
+    t.Fatalf("expected conflicting component to be rejected but got: %v", err)
+  }
+  if !shutdown {
+    t.Error("expected rejected plugin to be shut down")
+  }
+  if len(m.Plugins) != 0 || len(m.Failures) != 1 {
+    t.Fatalf("expected plugin to be recorded as failed but got %d plugins and %d failures", len(m.Plugins), len(m.Failures))
+  }
+
+  statuses := m.Status()
+  if len(statuses) != 1 {
+    t.Fatalf("expected 1 status but got %d", len(statuses))
+  }
+  status := statuses[0]
+  if status.Id != "conflicting" || status.Name != "conflicting" || status.Loaded || status.Error == "" {
+    t.Errorf("expected failure to be reported along with the plugin metadata but got %+v", status)
+  }
+  if status.ApiVersion != 1 {
+    t.Errorf("expected omitted API version to be reported as version 1 but got %d", status.ApiVersion)
+  }
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package plugin
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+
+  "github.com/dotStart/Stockpile/stockpile/metadata"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/hashicorp/hcl2/gohcl"
+  "github.com/hashicorp/hcl2/hcl"
+  "github.com/hashicorp/hcl2/hclparse"
+)
+
+// lists the file extensions of plugin manifests (in order of preference)
+var manifestExts = []string{".hcl", ".json"}
+
+// describes the constraints and components of a plugin
+// manifests are optional and are placed next to the plugin file using the same name (e.g.
+// "redis.hcl" or "redis.json" for "redis.so")
+type Manifest struct {
+  MinVersion   *string   `hcl:"min-stockpile-version,attr"`
+  Dependencies *[]string `hcl:"depends,attr"`
+  Conflicts    *[]string `hcl:"conflicts,attr"`
+  Components   *[]string `hcl:"components,attr"`
+}
+
+// loads the manifest of the plugin at the given path (if any)
+func LoadManifest(path string) (*Manifest, error) {
+  base := path
+  if ext := filepath.Ext(path); ext == pluginExt || ext == ".exe" {
+    base = strings.TrimSuffix(path, ext)
+  }
+
+  for _, ext := range manifestExts {
+    manifestPath := base + ext
+    if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
+      continue
+    }
+
+    parser := hclparse.NewParser()
+    var file *hcl.File
+    var diag hcl.Diagnostics
+    if ext == ".json" {
+      file, diag = parser.ParseJSONFile(manifestPath)
+    } else {
+      file, diag = parser.ParseHCLFile(manifestPath)
+    }
+    if diag.HasErrors() {
+      return nil, fmt.Errorf("illegal manifest \"%s\": %s", manifestPath, diag.Error())
+    }
+
+    manifest := &Manifest{}
+    diag = gohcl.DecodeBody(file.Body, server.EvalContext(), manifest)
+    if diag.HasErrors() {
+      return nil, fmt.Errorf("illegal manifest \"%s\": %s", manifestPath, diag.Error())
+    }
+    return manifest, nil
+  }
+  return nil, nil
+}
+
+// evaluates whether a given file is a plugin manifest
+func isManifest(name string) bool {
+  for _, ext := range manifestExts {
+    if strings.HasSuffix(name, ext) {
+      return true
+    }
+  }
+  return false
+}
+
+// lists the identifiers of all plugins this plugin depends upon
+func (m *Manifest) GetDependencies() []string {
+  if m == nil || m.Dependencies == nil {
+    return nil
+  }
+  return *m.Dependencies
+}
+
+// lists the identifiers of all plugins this plugin cannot be loaded alongside
+func (m *Manifest) GetConflicts() []string {
+  if m == nil || m.Conflicts == nil {
+    return nil
+  }
+  return *m.Conflicts
+}
+
+// evaluates whether the plugin may register commands (e.g. whether it does not declare its
+// components or declares at least one command)
+func (m *Manifest) declaresCommands() bool {
+  if m == nil || m.Components == nil {
+    return true
+  }
+  for _, component := range *m.Components {
+    if strings.HasPrefix(component, "command:") {
+      return true
+    }
+  }
+  return false
+}
+
+// verifies whether this build satisfies the minimum version required by the plugin
+// development builds are considered to satisfy any version
+func (m *Manifest) checkVersion() error {
+  if m == nil || m.MinVersion == nil || metadata.IsDevelopmentBuild() {
+    return nil
+  }
+  if compareVersions(metadata.Version(), *m.MinVersion) < 0 {
+    return fmt.Errorf("plugin requires Stockpile %s or newer but this is version %s", *m.MinVersion, metadata.Version())
+  }
+  return nil
+}
+
+// verifies whether the components which have been registered by a plugin match the declared
+// components (anonymous components such as interceptors are not declared)
+func (m *Manifest) checkComponents(ctx *Context) error {
+  if m == nil || m.Components == nil {
+    return nil
+  }
+
+  declared := make(map[string]bool)
+  for _, component := range *m.Components {
+    declared[component] = true
+  }
+  for _, component := range ctx.Components() {
+    if !strings.Contains(component, ":") {
+      continue
+    }
+    if !declared[component] {
+      return fmt.Errorf("plugin registered undeclared component %s", component)
+    }
+    delete(declared, component)
+  }
+  for component := range declared {
+    return fmt.Errorf("plugin did not register declared component %s", component)
+  }
+  return nil
+}
+
+// compares two dotted version numbers (pre-release and build suffixes are ignored)
+func compareVersions(a string, b string) int {
+  aElements := versionElements(a)
+  bElements := versionElements(b)
+  for i := 0; i < len(aElements) || i < len(bElements); i++ {
+    var x, y int
+    if i < len(aElements) {
+      x = aElements[i]
+    }
+    if i < len(bElements) {
+      y = bElements[i]
+    }
+    if x != y {
+      if x < y {
+        return -1
+      }
+      return 1
+    }
+  }
+  return 0
+}
+
+func versionElements(version string) []int {
+  if i := strings.IndexAny(version, "-+"); i != -1 {
+    version = version[:i]
+  }
+
+  elements := strings.Split(version, ".")
+  numbers := make([]int, len(elements))
+  for i, element := range elements {
+    numbers[i], _ = strconv.Atoi(element)
+  }
+  return numbers
+}
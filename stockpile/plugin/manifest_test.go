@@ -0,0 +1,243 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package plugin
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "io/ioutil"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+)
+
+// creates a candidate which depends upon the passed plugins
+func newTestCandidate(id string, dependencies ...string) *candidate {
+  return &candidate{
+    id:       id,
+    path:     "/plugins/" + id + pluginExt,
+    manifest: &Manifest{Dependencies: &dependencies},
+  }
+}
+
+// lists the identifiers of a set of candidates
+func candidateIds(candidates []*candidate) []string {
+  ids := make([]string, len(candidates))
+  for i, c := range candidates {
+    ids[i] = c.id
+  }
+  return ids
+}
+
+func TestLoadManifest(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+  f.Write("redis.hcl", `min-stockpile-version = "1.2.0"
+  depends = ["metrics"]
+  components = ["storage:redis", "event-bus:redis"]`)
+  f.Write("stockpile-plugin-sql.json", `{"conflicts": ["redis"]}`)
+  f.Write("broken.hcl", `depends = "metrics"`)
+
+  manifest, err := LoadManifest(filepath.Join(f.Dir, "redis"+pluginExt))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if manifest == nil || *manifest.MinVersion != "1.2.0" || !reflect.DeepEqual(manifest.GetDependencies(), []string{"metrics"}) || len(*manifest.Components) != 2 {
+    t.Errorf("expected hcl manifest to be decoded but got %+v", manifest)
+  }
+
+  manifest, err = LoadManifest(filepath.Join(f.Dir, "stockpile-plugin-sql"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !reflect.DeepEqual(manifest.GetConflicts(), []string{"redis"}) {
+    t.Errorf("expected json manifest to be decoded but got %+v", manifest)
+  }
+
+  // manifests are optional
+  manifest, err = LoadManifest(filepath.Join(f.Dir, "metrics"+pluginExt))
+  if err != nil || manifest != nil {
+    t.Errorf("expected missing manifest to be ignored but got %+v (%v)", manifest, err)
+  }
+
+  _, err = LoadManifest(filepath.Join(f.Dir, "broken"+pluginExt))
+  if err == nil || !strings.Contains(err.Error(), "illegal manifest") {
+    t.Errorf("expected malformed manifest to be rejected but got: %v", err)
+  }
+}
+
+func TestSortCandidates(t *testing.T) {
+  // dependencies which are not present within the plugin directory are reported upon load
+  ordered := sortCandidates([]*candidate{
+    newTestCandidate("c", "b"),
+    newTestCandidate("b", "a", "missing"),
+    newTestCandidate("a"),
+    newTestCandidate("d", "a", "c"),
+    {id: "e", path: "/plugins/e" + pluginExt},
+  })
+  if ids, expected := candidateIds(ordered), []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(ids, expected) {
+    t.Fatalf("expected plugins to be ordered as %v but got %v", expected, ids)
+  }
+  for _, c := range ordered {
+    if c.err != nil {
+      t.Errorf("expected plugin \"%s\" to remain loadable but got: %s", c.id, c.err)
+    }
+  }
+}
+
+func TestSortCandidatesCycle(t *testing.T) {
+  ordered := sortCandidates([]*candidate{
+    newTestCandidate("a", "c"),
+    newTestCandidate("b", "a"),
+    newTestCandidate("c", "b"),
+    newTestCandidate("d", "a"),
+    newTestCandidate("e"),
+    newTestCandidate("f", "f"),
+  })
+  if len(ordered) != 6 {
+    t.Fatalf("expected all plugins to be retained but got %v", candidateIds(ordered))
+  }
+
+  // plugins which depend on a cycle are not part of it and fail when their dependency is missing
+  for _, c := range ordered {
+    switch c.id {
+    case "a", "b", "c":
+      if c.err == nil || !strings.Contains(c.err.Error(), "dependency cycle: a -> c -> b -> a") {
+        t.Errorf("expected plugin \"%s\" to be reported as part of the cycle but got: %v", c.id, c.err)
+      }
+    case "f":
+      if c.err == nil || !strings.Contains(c.err.Error(), "dependency cycle: f -> f") {
+        t.Errorf("expected self-dependency to be reported as a cycle but got: %v", c.err)
+      }
+    default:
+      if c.err != nil {
+        t.Errorf("expected plugin \"%s\" not to be part of a cycle but got: %s", c.id, c.err)
+      }
+    }
+  }
+
+  m := NewManager("plugins")
+  for _, c := range ordered {
+    if c.id == "d" {
+      err := m.checkConstraints(c)
+      if err == nil || !strings.Contains(err.Error(), "dependency \"a\" has not been loaded") {
+        t.Errorf("expected plugin depending on a cycle to be refused but got: %v", err)
+      }
+    }
+  }
+}
+
+func TestCheckConstraints(t *testing.T) {
+  m := NewManager("plugins")
+  conflicts := []string{"legacy"}
+  redis := newTestPlugin("redis", nil, nil)
+  redis.Manifest = &Manifest{Conflicts: &conflicts}
+  err := m.register(redis.Path, redis, nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  components := []string{"storage:bolt"}
+  tests := []struct {
+    name      string
+    candidate *candidate
+    expected  string
+  }{
+    {"satisfied", newTestCandidate("metrics", "redis"), ""},
+    {"duplicate", newTestCandidate("redis"), "has already been loaded"},
+    {"missing-dependency", newTestCandidate("cluster", "metrics"), "dependency \"metrics\" has not been loaded"},
+    {"conflict", &candidate{id: "sql", manifest: &Manifest{Conflicts: &[]string{"redis"}}}, "conflicts with plugin \"redis\""},
+    {"reverse-conflict", newTestCandidate("legacy"), "plugin \"redis\" conflicts with this plugin"},
+    {"component", &candidate{id: "bolt", manifest: &Manifest{Components: &components}}, "component storage:bolt has already been registered"},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      err := m.checkConstraints(test.candidate)
+      if test.expected == "" {
+        if err != nil {
+          t.Fatalf("expected plugin to be accepted but got: %s", err)
+        }
+        return
+      }
+      if err == nil || !strings.Contains(err.Error(), test.expected) {
+        t.Fatalf("expected error \"%s\" but got: %v", test.expected, err)
+      }
+    })
+  }
+}
+
+func TestAllowlist(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+  approved := f.Write("approved"+pluginExt, "approved plugin")
+  unapproved := f.Write("unapproved"+pluginExt, "unapproved plugin")
+  f.Write("approved.hcl", `depends = ["metrics"]`)
+
+  sum := sha256.Sum256([]byte("approved plugin"))
+  checksum := hex.EncodeToString(sum[:])
+
+  // checksums are compared case insensitively
+  m := NewManager(f.Dir)
+  m.SetAllowlist([]string{strings.ToUpper(checksum)})
+
+  c := m.newCandidate(approved, false)
+  if c.err != nil {
+    t.Fatalf("expected approved plugin to be accepted but got: %s", c.err)
+  }
+  if c.checksum != checksum {
+    t.Errorf("expected checksum %s to be passed on but got %s", checksum, c.checksum)
+  }
+  if !reflect.DeepEqual(c.manifest.GetDependencies(), []string{"metrics"}) {
+    t.Errorf("expected manifest of approved plugin to be loaded but got %+v", c.manifest)
+  }
+
+  c = m.newCandidate(unapproved, false)
+  if c.err == nil || !strings.Contains(c.err.Error(), "has not been approved") {
+    t.Fatalf("expected unapproved plugin to be refused but got: %v", c.err)
+  }
+  if c.manifest != nil {
+    t.Error("expected manifest of unapproved plugin not to be loaded")
+  }
+
+  // refused plugins are reported as failures without being launched
+  executable := filepath.Join(f.Dir, "stockpile-plugin-external")
+  err := ioutil.WriteFile(executable, []byte("#!/bin/sh\nexit 1\n"), 0755)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = m.LoadAll()
+  if err != nil {
+    t.Fatal(err)
+  }
+  failed := make(map[string]string)
+  for _, failure := range m.Failures {
+    failed[pluginId(failure.Path)] = failure.Err.Error()
+  }
+  if !strings.Contains(failed["external"], "has not been approved") {
+    t.Errorf("expected unapproved executable to be refused but got: %v", failed)
+  }
+
+  // lifting the restriction permits all plugins
+  m.SetAllowlist(nil)
+  if c := m.newCandidate(unapproved, false); c.err != nil {
+    t.Errorf("expected plugin to be accepted without an allowlist but got: %s", c.err)
+  }
+}
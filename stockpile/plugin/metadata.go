@@ -16,10 +16,31 @@
  */
 package plugin
 
+import "fmt"
+
+// defines the version of the plugin API which is provided by this build
+// plugins which target a different version are refused at load time
+const ApiVersion = 2
+
 // represents the metadata associated with a plugin implementation
+// plugins are expected to set the API version to the value of ApiVersion at the time they are
+// built (plugins which omit it are considered to target the original API)
 type Metadata struct {
-  Name    string
-  Version string
-  Authors []string
-  Website string
+  Name       string
+  Version    string
+  Authors    []string
+  Website    string
+  ApiVersion int
+}
+
+// verifies that the plugin targets the version of the plugin API which is provided by this build
+func (m *Metadata) checkApiVersion() error {
+  apiVersion := m.ApiVersion
+  if apiVersion == 0 {
+    apiVersion = 1
+  }
+  if apiVersion != ApiVersion {
+    return fmt.Errorf("plugin \"%s\" v%s targets plugin API version %d but this build of Stockpile provides version %d", m.Name, m.Version, apiVersion, ApiVersion)
+  }
+  return nil
 }
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package plugin
+
+import (
+  "fmt"
+  "strings"
+  "testing"
+)
+
+func TestMetadataCheckApiVersion(t *testing.T) {
+  tests := []struct {
+    name       string
+    apiVersion int
+    expected   string
+  }{
+    {"current", ApiVersion, ""},
+    {"omitted", 0, "targets plugin API version 1"},
+    {"previous", ApiVersion - 1, fmt.Sprintf("targets plugin API version %d", ApiVersion-1)},
+    {"future", ApiVersion + 1, fmt.Sprintf("targets plugin API version %d", ApiVersion+1)},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      metadata := &Metadata{
+        Name:       "example",
+        Version:    "1.0.0",
+        ApiVersion: test.apiVersion,
+      }
+
+      err := metadata.checkApiVersion()
+      if test.expected == "" {
+        if err != nil {
+          t.Fatalf("expected plugin to be accepted but got: %s", err)
+        }
+        return
+      }
+      if err == nil {
+        t.Fatal("expected plugin to be refused")
+      }
+      if !strings.Contains(err.Error(), test.expected) || !strings.Contains(err.Error(), "\"example\" v1.0.0") {
+        t.Errorf("expected error to identify the plugin and its API version but got: %s", err)
+      }
+    })
+  }
+}
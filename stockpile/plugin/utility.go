@@ -17,8 +17,41 @@
 package plugin
 
 import (
+  "fmt"
+  "reflect"
   "runtime"
+  "sort"
 )
 
 // defines whether plugins are available on the current platform
 const PluginsAvailable = runtime.GOOS == "darwin" || runtime.GOOS == "linux"
+
+// lists the (sorted) keys of an arbitrary map with string keys
+func keys(m interface{}) []string {
+  values := reflect.ValueOf(m).MapKeys()
+  keys := make([]string, len(values))
+  for i, value := range values {
+    keys[i] = value.String()
+  }
+  sort.Strings(keys)
+  return keys
+}
+
+// appends a set of named components of a given kind to a component list
+func appendComponents(components []string, kind string, names []string) []string {
+  for _, name := range names {
+    components = append(components, kind+":"+name)
+  }
+  return components
+}
+
+// appends a set of anonymous components of a given kind to a component list
+func appendCount(components []string, kind string, count int) []string {
+  switch {
+  case count == 1:
+    components = append(components, kind)
+  case count > 1:
+    components = append(components, fmt.Sprintf("%s (x%d)", kind, count))
+  }
+  return components
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+  "math"
+  "sync"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/server"
+)
+
+// provides a rate limit store which keeps track of the requests submitted by this instance only
+type LocalStore struct {
+  mutex     sync.Mutex
+  limit     float64
+  rate      float64 // tokens per nanosecond
+  tokens    float64
+  updatedAt time.Time
+}
+
+// creates a new in-process rate limit store
+func NewLocalStore(cfg *server.Config) (Store, error) {
+  limit := float64(*cfg.RateLimit.Limit)
+  return &LocalStore{
+    limit:     limit,
+    rate:      limit / float64(cfg.RateLimit.Period),
+    tokens:    limit,
+    updatedAt: time.Now(),
+  }, nil
+}
+
+// replenishes the bucket based on the time which passed since its last update
+// this method expects the caller to hold the store mutex
+func (s *LocalStore) refill() {
+  now := time.Now()
+  s.tokens = math.Min(s.limit, s.tokens+float64(now.Sub(s.updatedAt))*s.rate)
+  s.updatedAt = now
+}
+
+func (s *LocalStore) Take() (bool, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.refill()
+  if s.tokens < 1 {
+    return false, nil
+  }
+
+  s.tokens--
+  return true, nil
+}
+
+func (s *LocalStore) Allocation() (uint64, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.refill()
+  return uint64(s.limit - math.Floor(s.tokens)), nil
+}
+
+func (s *LocalStore) Capacity() uint64 {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  return uint64(s.limit)
+}
+
+func (s *LocalStore) Reconfigure(limit int, period time.Duration) error {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.refill()
+  allocation := s.limit - s.tokens
+  s.limit = float64(limit)
+  s.rate = s.limit / float64(period)
+  s.tokens = math.Max(0, s.limit-allocation)
+  return nil
+}
+
+func (s *LocalStore) Close() error {
+  return nil
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+  "errors"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/server"
+)
+
+// indicates that the upstream request budget has been exhausted
+var ErrRateLimitExceeded = errors.New("upstream rate limit exceeded")
+
+// keeps track of the budget of requests which may be submitted to the upstream servers
+//
+// stores are expected to implement a token bucket which holds up to the configured limit of
+// requests and is replenished continuously over the configured period - when multiple instances
+// share a store, all of them draw from the same bucket
+type Store interface {
+  // consumes a single request from the budget and indicates whether the request may be submitted
+  Take() (bool, error)
+  // retrieves the amount of requests which are currently deducted from the budget (e.g. the
+  // amount of requests submitted by all instances within the last period)
+  Allocation() (uint64, error)
+  // retrieves the maximum amount of requests which may be submitted within a single period
+  Capacity() uint64
+  // replaces the limit and replenishment period of the budget (requests which have already been
+  // deducted from the budget remain deducted)
+  Reconfigure(limit int, period time.Duration) error
+
+  // clears all allocated resources
+  Close() error
+}
+
+// provides a factory for rate limit store instances
+type Factory = func(cfg *server.Config) (Store, error)
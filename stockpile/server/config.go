@@ -17,18 +17,20 @@
 package server
 
 import (
-  "errors"
+  "crypto/sha256"
+  "encoding/hex"
   "fmt"
-  "io/ioutil"
-  "os"
-  "path/filepath"
+  "net"
+  "strconv"
   "strings"
   "time"
 
   "github.com/dotStart/Stockpile/entity"
   "github.com/hashicorp/hcl2/gohcl"
   "github.com/hashicorp/hcl2/hcl"
+  "github.com/hashicorp/hcl2/hcl/hclsyntax"
   "github.com/hashicorp/hcl2/hclparse"
+  "github.com/op/go-logging"
 )
 
 // defines the default address to listen on when none is given
@@ -37,18 +39,56 @@ const DefaultAddress = "0.0.0.0"
 // defines the default port to listen on when none is given
 const DefaultPort = 36623
 
+// defines the amount of requests which may be submitted to the upstream servers within a single
+// rate limit period (this matches the limit enforced by Mojang per source address)
+const DefaultRateLimit = 600
+
+// defines the period over which the upstream request budget is replenished
+const DefaultRateLimitPeriod = time.Minute * 10
+
+// defines the duration for which in-flight requests are drained before the server is forcefully
+// stopped
+const DefaultShutdownTimeout = time.Second * 30
+
+// defines the service name which is reported to the tracing collector when none is given
+const DefaultTracingServiceName = "stockpile"
+
+// defines the available log formats
+const (
+  LogFormatText = "text"
+  LogFormatJson = "json"
+)
+
+// defines the amount of rotated log files which are retained when no limit is given
+const DefaultLogMaxBackups = 5
+
 // utility variables
 var featureEnabled = true
 var featureDisabled = false
+var defaultRateLimit = DefaultRateLimit
+var defaultLogLevel = "info"
+var defaultLogFormat = LogFormatText
 
 // Represents a server configuration (typically parsed from one or more HCL files)
 type Config struct {
-  PluginDir        *string        `hcl:"plugin-dir"`
-  BindAddress      *string        `hcl:"bind-address,attr"`
-  UiEnabled        *bool          `hcl:"ui,attr"`
-  LegacyApiEnabled *bool          `hcl:"legacy-api,attr"`
-  Storage          *StorageConfig `hcl:"storage,block"`
-  Ttl              *TtlConfig     `hcl:"ttl,block"`
+  PluginDir          *string   `hcl:"plugin-dir"`
+  PluginAllowlist    *[]string `hcl:"plugin-allowlist,attr"`
+  BindAddress        *string   `hcl:"bind-address,attr"`
+  UiEnabled          *bool     `hcl:"ui,attr"`
+  LegacyApiEnabled   *bool     `hcl:"legacy-api,attr"`
+  MetricsEnabled     *bool     `hcl:"metrics,attr"`
+  HealthEnabled      *bool     `hcl:"health,attr"`
+  CorsOverride       *string   `hcl:"cors-override,attr"`
+  ShutdownTimeout    time.Duration
+  RawShutdownTimeout *string          `hcl:"shutdown-timeout,attr"`
+  Storage            *StorageConfig   `hcl:"storage,block"`
+  Upstream           *UpstreamConfig  `hcl:"upstream,block"`
+  Cluster            *ClusterConfig   `hcl:"cluster,block"`
+  RateLimit          *RateLimitConfig `hcl:"rate-limit,block"`
+  Tracing            *TracingConfig   `hcl:"tracing,block"`
+  Logging            *LoggingConfig   `hcl:"logging,block"`
+  Ttl                *TtlConfig       `hcl:"ttl,block"`
+  Plugins            []*PluginConfig  `hcl:"plugin,block"`
 }
 
 // Represents a storage backend configuration
@@ -59,16 +99,83 @@ type StorageConfig struct {
   Parameters hcl.Body `hcl:",remain"`
 }
 
+// Represents an upstream provider configuration
+// The "type" parameter identifies the provider which is consulted when a resource is not cached
+// while all parameters are passed on to the provider upon startup
+type UpstreamConfig struct {
+  Type       string   `hcl:"type,label"`
+  Parameters hcl.Body `hcl:",remain"`
+}
+
+// Represents a cluster configuration
+// The "type" parameter identifies the transport which is used to exchange events with other instances
+// while all parameters are passed on to the transport upon startup
+type ClusterConfig struct {
+  Type       string   `hcl:"type,label"`
+  Parameters hcl.Body `hcl:",remain"`
+}
+
+// Represents an upstream rate limit configuration
+// The "type" parameter identifies the store which keeps track of the shared request budget while
+// all remaining parameters are passed on to the store upon startup
+type RateLimitConfig struct {
+  Type       string `hcl:"type,label"`
+  Limit      *int   `hcl:"limit,attr"`
+  Period     time.Duration
+  RawPeriod  *string  `hcl:"period,attr"`
+  Enforce    *bool    `hcl:"enforce,attr"`
+  Parameters hcl.Body `hcl:",remain"`
+}
+
+// Represents the configuration of a single plugin
+// The "name" label identifies the plugin by its file name (without its extension or prefix) while
+// all parameters are passed on to the plugin upon startup and whenever the configuration is reloaded
+type PluginConfig struct {
+  Name       string   `hcl:"name,label"`
+  Parameters hcl.Body `hcl:",remain"`
+}
+
+// Represents a tracing configuration
+// When present, spans are submitted to the OTLP (HTTP) collector at the given endpoint
+type TracingConfig struct {
+  Endpoint    string             `hcl:"endpoint,attr"`
+  Path        *string            `hcl:"path,attr"`
+  Insecure    *bool              `hcl:"insecure,attr"`
+  Headers     *map[string]string `hcl:"headers,attr"`
+  ServiceName *string            `hcl:"service-name,attr"`
+  SampleRatio *float64           `hcl:"sample-ratio,attr"`
+}
+
+// Represents a logging configuration
+// Module levels override the default level for the loggers of the respective modules (e.g. "cache")
+// while the maximum size of log files is given in megabytes
+type LoggingConfig struct {
+  Level      *string            `hcl:"level,attr"`
+  Format     *string            `hcl:"format,attr"`
+  File       *string            `hcl:"file,attr"`
+  MaxSize    *int               `hcl:"max-size,attr"`
+  MaxBackups *int               `hcl:"max-backups,attr"`
+  Modules    *map[string]string `hcl:"modules,attr"`
+}
+
 // Represents the TTL (Time To Live) configuration (e.g. caching durations for various value types)
+// Each duration may be omitted in which case the previously configured (or default) value is retained
 type TtlConfig struct {
   Name           time.Duration
-  RawName        string `hcl:"name,attr"`
+  RawName        *string `hcl:"name,attr"`
   NameHistory    time.Duration
-  RawNameHistory string `hcl:"name-history,attr"`
+  RawNameHistory *string `hcl:"name-history,attr"`
   Profile        time.Duration
-  RawProfile     string `hcl:"profile,attr"`
+  RawProfile     *string `hcl:"profile,attr"`
   Blacklist      time.Duration
-  RawBlacklist   string `hcl:"blacklist,attr"`
+  RawBlacklist   *string `hcl:"blacklist,attr"`
+}
+
+// Represents an error which has been caused by the value of a specific setting
+// Settings are identified by their path within the configuration (e.g. "ttl.profile")
+type SettingError struct {
+  Setting string
+  Err     error
 }
 
 // Creates an empty configuration
@@ -85,9 +192,25 @@ func DefaultConfig() *Config {
     BindAddress:      &addr,
     UiEnabled:        &featureDisabled,
     LegacyApiEnabled: &featureDisabled,
+    MetricsEnabled:   &featureDisabled,
+    HealthEnabled:    &featureDisabled,
+    ShutdownTimeout:  DefaultShutdownTimeout,
     Storage: &StorageConfig{
       Type: "mem",
     },
+    Upstream: &UpstreamConfig{
+      Type: "mojang",
+    },
+    RateLimit: &RateLimitConfig{
+      Type:    "local",
+      Limit:   &defaultRateLimit,
+      Period:  DefaultRateLimitPeriod,
+      Enforce: &featureDisabled,
+    },
+    Logging: &LoggingConfig{
+      Level:  &defaultLogLevel,
+      Format: &defaultLogFormat,
+    },
     Ttl: &TtlConfig{
       Name:        entity.NameValidityPeriod,            // Full Mojang limit
       NameHistory: entity.NameChangeRateLimitPeriod / 4, // 1/4th of the Mojang limit
@@ -98,81 +221,92 @@ func DefaultConfig() *Config {
 
   // since parse may be called on this config we'll have to copy the string representations as well
   ttl := cfg.Ttl
-  ttl.RawName = ttl.Name.String()
-  ttl.RawNameHistory = ttl.NameHistory.String()
-  ttl.RawProfile = ttl.Profile.String()
-  ttl.RawBlacklist = ttl.Blacklist.String()
+  ttl.RawName = formatDuration(ttl.Name)
+  ttl.RawNameHistory = formatDuration(ttl.NameHistory)
+  ttl.RawProfile = formatDuration(ttl.Profile)
+  ttl.RawBlacklist = formatDuration(ttl.Blacklist)
+
+  cfg.RateLimit.RawPeriod = formatDuration(cfg.RateLimit.Period)
+  cfg.RawShutdownTimeout = formatDuration(cfg.ShutdownTimeout)
 
   return cfg
 }
 
 func DevelopmentConfig() *Config {
-  return DefaultConfig().Merge(&Config{
+  return DefaultConfig().Merge(DevelopmentOverrides())
+}
+
+// Creates a configuration which enables all features which are typically desired during
+// development (e.g. to be merged on top of the default configuration)
+func DevelopmentOverrides() *Config {
+  return &Config{
     UiEnabled:        &featureEnabled,
     LegacyApiEnabled: &featureEnabled,
-  })
+    MetricsEnabled:   &featureEnabled,
+    HealthEnabled:    &featureEnabled,
+  }
 }
 
 // Loads a file or directory
 func LoadConfig(path string) (*Config, error) {
-  file, err := os.Stat(path)
-  if err != nil {
-    return nil, err
-  }
-
-  if file.IsDir() {
-    return LoadConfigDirectory(path)
-  }
-
-  base := DefaultConfig()
-  cfg, err := LoadConfigFile(path)
+  layers, err := LoadConfigLayers(path)
   if err != nil {
     return nil, err
   }
-  base.Merge(cfg)
-  return base, base.validate()
+  return MergeConfigLayers(layers)
 }
 
 // Loads an entire directory of configuration files
 func LoadConfigDirectory(path string) (*Config, error) {
-  files, err := ioutil.ReadDir(path)
+  layers, err := loadConfigDirectoryLayers(path)
   if err != nil {
     return nil, err
   }
-
-  base := DefaultConfig()
-  for _, file := range files {
-    if file.IsDir() {
-      continue
-    }
-
-    if strings.HasSuffix(file.Name(), ".hcl") || strings.HasSuffix(file.Name(), ".json") {
-      cfg, err := LoadConfigFile(filepath.Join(path, file.Name()))
-      if err != nil {
-        return nil, err
-      }
-      base.Merge(cfg)
-    }
-  }
-
-  return base, base.validate()
+  return MergeConfigLayers(layers)
 }
 
 // Loads a single configuration file
+// the returned configuration only contains the settings which are present within the file and is
+// thus not validated
 func LoadConfigFile(path string) (*Config, error) {
   parser := hclparse.NewParser()
-  file, diag := parser.ParseHCLFile(path)
 
+  var file *hcl.File
+  var diag hcl.Diagnostics
+  if strings.HasSuffix(path, ".json") {
+    file, diag = parser.ParseJSONFile(path)
+  } else {
+    file, diag = parser.ParseHCLFile(path)
+  }
   if diag.HasErrors() {
-    return nil, fmt.Errorf("failed to load configuration file \"%s\": %s", path, diag.Error())
+    return nil, &FileError{Path: path, Diagnostics: diag, files: parser.Files()}
   }
 
   cfg := EmptyConfig()
-  diag = gohcl.DecodeBody(file.Body, nil, cfg)
-  cfg.Parse()
-
+  diag = gohcl.DecodeBody(file.Body, EvalContext(), cfg)
   if diag.HasErrors() {
-    return nil, fmt.Errorf("failed to load configuration file \"%s\": %s", path, diag.Error())
+    return nil, &FileError{Path: path, Diagnostics: diag, files: parser.Files()}
+  }
+
+  err := cfg.Parse()
+  if err != nil {
+    settingErr, ok := err.(*SettingError)
+    if !ok {
+      return nil, fmt.Errorf("failed to load configuration file \"%s\": %s", path, err)
+    }
+
+    return nil, &FileError{
+      Path: path,
+      Diagnostics: hcl.Diagnostics{
+        {
+          Severity: hcl.DiagError,
+          Summary:  fmt.Sprintf("Invalid value for \"%s\"", settingErr.Setting),
+          Detail:   settingErr.Err.Error(),
+          Subject:  attributeRange(file.Body, strings.Split(settingErr.Setting, ".")...),
+        },
+      },
+      files: parser.Files(),
+    }
   }
   return cfg, nil
 }
@@ -183,6 +317,10 @@ func (c *Config) Merge(other *Config) *Config {
     c.PluginDir = other.PluginDir
   }
 
+  if other.PluginAllowlist != nil {
+    c.PluginAllowlist = other.PluginAllowlist
+  }
+
   if other.BindAddress != nil {
     c.BindAddress = other.BindAddress
   }
@@ -195,104 +333,484 @@ func (c *Config) Merge(other *Config) *Config {
     c.LegacyApiEnabled = other.LegacyApiEnabled
   }
 
+  if other.MetricsEnabled != nil {
+    c.MetricsEnabled = other.MetricsEnabled
+  }
+  if other.HealthEnabled != nil {
+    c.HealthEnabled = other.HealthEnabled
+  }
+
+  if other.CorsOverride != nil {
+    c.CorsOverride = other.CorsOverride
+  }
+
+  if other.RawShutdownTimeout != nil {
+    c.ShutdownTimeout = other.ShutdownTimeout
+    c.RawShutdownTimeout = other.RawShutdownTimeout
+  }
+
   if c.Storage == nil {
     c.Storage = other.Storage
   } else if other.Storage != nil {
     c.Storage.Merge(other.Storage)
   }
 
+  if c.Upstream == nil {
+    c.Upstream = other.Upstream
+  } else if other.Upstream != nil {
+    c.Upstream.Merge(other.Upstream)
+  }
+
+  if c.Cluster == nil {
+    c.Cluster = other.Cluster
+  } else if other.Cluster != nil {
+    c.Cluster.Merge(other.Cluster)
+  }
+
+  if c.RateLimit == nil {
+    c.RateLimit = other.RateLimit
+  } else if other.RateLimit != nil {
+    c.RateLimit.Merge(other.RateLimit)
+  }
+
+  if c.Tracing == nil {
+    c.Tracing = other.Tracing
+  } else if other.Tracing != nil {
+    c.Tracing.Merge(other.Tracing)
+  }
+
+  if c.Logging == nil {
+    c.Logging = other.Logging
+  } else if other.Logging != nil {
+    c.Logging.Merge(other.Logging)
+  }
+
   if c.Ttl == nil {
     c.Ttl = other.Ttl
   } else if other.Ttl != nil {
     c.Ttl.Merge(other.Ttl)
   }
 
+  if len(other.Plugins) != 0 {
+    c.Plugins = mergePluginConfigs(c.Plugins, other.Plugins)
+  }
+
   return c
 }
 
+// merges two sets of plugin configurations
+// parameters of plugins which are configured in both sets are overridden individually while the
+// passed slices are left untouched as they may be shared between layers
+func mergePluginConfigs(base []*PluginConfig, overrides []*PluginConfig) []*PluginConfig {
+  merged := make([]*PluginConfig, len(base))
+  for i, plugin := range base {
+    cpy := *plugin
+    merged[i] = &cpy
+  }
+
+overrides:
+  for _, override := range overrides {
+    for _, plugin := range merged {
+      if plugin.Name == override.Name {
+        if _, ok := override.Parameters.(*hclsyntax.Body); ok {
+          plugin.Parameters = mergeParameters(plugin.Parameters, override.Parameters)
+        } else {
+          plugin.Parameters = override.Parameters
+        }
+        continue overrides
+      }
+    }
+
+    cpy := *override
+    merged = append(merged, &cpy)
+  }
+  return merged
+}
+
+// creates a shallow copy of this configuration which refers to a different storage backend
+// configuration (typically used by backends which delegate to other backends)
+func (c *Config) WithStorage(storage *StorageConfig) *Config {
+  cpy := *c
+  cpy.Storage = storage
+  return &cpy
+}
+
+// parameters are replaced when the backend type is given and overridden otherwise (e.g. when a
+// single parameter is passed via the environment)
 func (c *StorageConfig) Merge(other *StorageConfig) *StorageConfig {
   if other.Type != "" {
     c.Type = other.Type
     c.Parameters = other.Parameters
+  } else {
+    c.Parameters = mergeParameters(c.Parameters, other.Parameters)
+  }
+  return c
+}
+
+// creates a shallow copy of this configuration which refers to a different upstream provider
+// configuration (typically used by providers which delegate to other providers)
+func (c *Config) WithUpstream(upstream *UpstreamConfig) *Config {
+  cpy := *c
+  cpy.Upstream = upstream
+  return &cpy
+}
+
+func (c *UpstreamConfig) Merge(other *UpstreamConfig) *UpstreamConfig {
+  if other.Type != "" {
+    c.Type = other.Type
+    c.Parameters = other.Parameters
+  } else {
+    c.Parameters = mergeParameters(c.Parameters, other.Parameters)
+  }
+  return c
+}
+
+func (c *ClusterConfig) Merge(other *ClusterConfig) *ClusterConfig {
+  if other.Type != "" {
+    c.Type = other.Type
+    c.Parameters = other.Parameters
+  } else {
+    c.Parameters = mergeParameters(c.Parameters, other.Parameters)
+  }
+  return c
+}
+
+func (c *RateLimitConfig) Merge(other *RateLimitConfig) *RateLimitConfig {
+  if other.Type != "" {
+    c.Type = other.Type
+    c.Parameters = other.Parameters
+  } else {
+    c.Parameters = mergeParameters(c.Parameters, other.Parameters)
+  }
+  if other.Limit != nil {
+    c.Limit = other.Limit
+  }
+  if other.RawPeriod != nil {
+    c.Period = other.Period
+    c.RawPeriod = other.RawPeriod
+  }
+  if other.Enforce != nil {
+    c.Enforce = other.Enforce
+  }
+  return c
+}
+
+func (c *RateLimitConfig) Parse() error {
+  return parseDuration("rate-limit.period", c.RawPeriod, &c.Period)
+}
+
+func (c *TracingConfig) Merge(other *TracingConfig) *TracingConfig {
+  if other.Endpoint != "" {
+    c.Endpoint = other.Endpoint
+  }
+  if other.Path != nil {
+    c.Path = other.Path
+  }
+  if other.Insecure != nil {
+    c.Insecure = other.Insecure
+  }
+  if other.Headers != nil {
+    if c.Headers == nil {
+      c.Headers = other.Headers
+    } else {
+      headers := make(map[string]string)
+      for name, value := range *c.Headers {
+        headers[name] = value
+      }
+      for name, value := range *other.Headers {
+        headers[name] = value
+      }
+      c.Headers = &headers
+    }
+  }
+  if other.ServiceName != nil {
+    c.ServiceName = other.ServiceName
+  }
+  if other.SampleRatio != nil {
+    c.SampleRatio = other.SampleRatio
+  }
+  return c
+}
+
+func (c *LoggingConfig) Merge(other *LoggingConfig) *LoggingConfig {
+  if other.Level != nil {
+    c.Level = other.Level
+  }
+  if other.Format != nil {
+    c.Format = other.Format
+  }
+  if other.File != nil {
+    c.File = other.File
+  }
+  if other.MaxSize != nil {
+    c.MaxSize = other.MaxSize
+  }
+  if other.MaxBackups != nil {
+    c.MaxBackups = other.MaxBackups
+  }
+  if other.Modules != nil {
+    if c.Modules == nil {
+      c.Modules = other.Modules
+    } else {
+      modules := make(map[string]string)
+      for module, level := range *c.Modules {
+        modules[module] = level
+      }
+      for module, level := range *other.Modules {
+        modules[module] = level
+      }
+      c.Modules = &modules
+    }
   }
   return c
 }
 
 func (c *TtlConfig) Merge(other *TtlConfig) *TtlConfig {
-  if other.Name != 0 {
+  if other.RawName != nil {
     c.Name = other.Name
+    c.RawName = other.RawName
   }
-  if other.NameHistory != 0 {
+  if other.RawNameHistory != nil {
     c.NameHistory = other.NameHistory
+    c.RawNameHistory = other.RawNameHistory
   }
-  if c.Profile != 0 {
+  if other.RawProfile != nil {
     c.Profile = other.Profile
+    c.RawProfile = other.RawProfile
   }
-  if other.Blacklist != 0 {
+  if other.RawBlacklist != nil {
     c.Blacklist = other.Blacklist
+    c.RawBlacklist = other.RawBlacklist
   }
   return c
 }
 
 func (c *TtlConfig) Parse() error {
-  name, err := time.ParseDuration(c.RawName)
+  err := parseDuration("ttl.name", c.RawName, &c.Name)
   if err != nil {
     return err
   }
-
-  nameHistory, err := time.ParseDuration(c.RawNameHistory)
+  err = parseDuration("ttl.name-history", c.RawNameHistory, &c.NameHistory)
   if err != nil {
     return err
   }
-
-  profile, err := time.ParseDuration(c.RawProfile)
+  err = parseDuration("ttl.profile", c.RawProfile, &c.Profile)
   if err != nil {
     return err
   }
+  return parseDuration("ttl.blacklist", c.RawBlacklist, &c.Blacklist)
+}
 
-  blacklist, err := time.ParseDuration(c.RawBlacklist)
+func (c *Config) Parse() error {
+  err := parseDuration("shutdown-timeout", c.RawShutdownTimeout, &c.ShutdownTimeout)
   if err != nil {
     return err
   }
+  if c.RateLimit != nil {
+    err := c.RateLimit.Parse()
+    if err != nil {
+      return err
+    }
+  }
+  if c.Ttl != nil {
+    err := c.Ttl.Parse()
+    if err != nil {
+      return err
+    }
+  }
 
-  c.Name = name
-  c.NameHistory = nameHistory
-  c.Profile = profile
-  c.Blacklist = blacklist
+  known := make(map[string]bool)
+  for _, plugin := range c.Plugins {
+    if plugin.Name == "" {
+      return settingError("plugin", "illegal plugin name: must not be empty")
+    }
+    if known[plugin.Name] {
+      return settingError("plugin."+plugin.Name, "plugin \"%s\" has been configured more than once", plugin.Name)
+    }
+    known[plugin.Name] = true
+  }
   return nil
 }
 
-func (c *Config) Parse() error {
-  if c.Ttl != nil {
-    return c.Ttl.Parse()
+// verifies that all settings of a (merged) configuration are present and hold legal values
+func (c *Config) Validate() error {
+  if c.PluginDir == nil || *c.PluginDir == "" {
+    return settingError("plugin-dir", "missing plugin directory")
   }
-  return nil
-}
 
-func (c *Config) validate() error {
-  if c.PluginDir == nil {
-    return errors.New("missing plugin directory")
+  if c.PluginAllowlist != nil {
+    for _, checksum := range *c.PluginAllowlist {
+      if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+        return settingError("plugin-allowlist", "illegal checksum \"%s\": expected hex encoded SHA-256 checksum", checksum)
+      }
+    }
   }
 
   if c.BindAddress == nil {
-    return errors.New("missing bind address")
+    return settingError("bind-address", "missing bind address")
+  }
+  _, port, err := net.SplitHostPort(*c.BindAddress)
+  if err != nil {
+    return &SettingError{Setting: "bind-address", Err: err}
+  }
+  if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+    return settingError("bind-address", "illegal port \"%s\"", port)
   }
 
   if c.UiEnabled == nil {
-    return errors.New("missing ui flag")
+    return settingError("ui", "missing ui flag")
+  }
+
+  if c.LegacyApiEnabled == nil {
+    return settingError("legacy-api", "missing legacy api flag")
+  }
+
+  if c.MetricsEnabled == nil {
+    return settingError("metrics", "missing metrics flag")
+  }
+
+  if c.HealthEnabled == nil {
+    return settingError("health", "missing health flag")
+  }
+
+  if c.CorsOverride != nil && *c.CorsOverride == "" {
+    return settingError("cors-override", "illegal origin: must not be empty")
+  }
+
+  if c.ShutdownTimeout <= 0 {
+    return settingError("shutdown-timeout", "illegal shutdown timeout: must be positive")
   }
 
   if c.Storage == nil {
-    return errors.New("missing storage backend configuration")
+    return settingError("storage", "missing storage backend configuration")
   }
 
   if c.Storage.Type == "" {
-    return errors.New("illegal storage backend type")
+    return settingError("storage", "illegal storage backend type")
+  }
+
+  if c.Upstream == nil {
+    return settingError("upstream", "missing upstream provider configuration")
+  }
+
+  if c.Upstream.Type == "" {
+    return settingError("upstream", "illegal upstream provider type")
+  }
+
+  if c.Cluster != nil && c.Cluster.Type == "" {
+    return settingError("cluster", "illegal cluster transport type")
+  }
+
+  if c.RateLimit == nil {
+    return settingError("rate-limit", "missing rate limit configuration")
+  }
+
+  if c.RateLimit.Type == "" {
+    return settingError("rate-limit", "illegal rate limit store type")
+  }
+
+  if c.RateLimit.Limit == nil || *c.RateLimit.Limit <= 0 {
+    return settingError("rate-limit.limit", "illegal rate limit: must be positive")
+  }
+
+  if c.RateLimit.Period <= 0 {
+    return settingError("rate-limit.period", "illegal rate limit period: must be positive")
+  }
+
+  if c.Tracing != nil {
+    if c.Tracing.Endpoint == "" {
+      return settingError("tracing.endpoint", "missing tracing endpoint")
+    }
+
+    if c.Tracing.SampleRatio != nil && (*c.Tracing.SampleRatio < 0 || *c.Tracing.SampleRatio > 1) {
+      return settingError("tracing.sample-ratio", "illegal tracing sample ratio: must be between 0 and 1")
+    }
+  }
+
+  if c.Logging == nil {
+    return settingError("logging", "missing logging configuration")
+  }
+
+  if c.Logging.Format != nil && *c.Logging.Format != LogFormatText && *c.Logging.Format != LogFormatJson {
+    return settingError("logging.format", "illegal log format \"%s\": must be one of %s or %s", *c.Logging.Format, LogFormatText, LogFormatJson)
+  }
+
+  if c.Logging.Level != nil {
+    _, err := logging.LogLevel(*c.Logging.Level)
+    if err != nil {
+      return settingError("logging.level", "illegal log level \"%s\": %s", *c.Logging.Level, err)
+    }
+  }
+
+  if c.Logging.Modules != nil {
+    for module, level := range *c.Logging.Modules {
+      _, err := logging.LogLevel(level)
+      if err != nil {
+        return settingError("logging.modules."+module, "illegal log level \"%s\" for module \"%s\": %s", level, module, err)
+      }
+    }
+  }
+
+  if c.Logging.MaxSize != nil && *c.Logging.MaxSize < 0 {
+    return settingError("logging.max-size", "illegal maximum log file size: must not be negative")
+  }
+
+  if c.Logging.MaxBackups != nil && *c.Logging.MaxBackups < 0 {
+    return settingError("logging.max-backups", "illegal amount of log file backups: must not be negative")
   }
 
   if c.Ttl == nil {
-    return errors.New("missing ttl configuration")
+    return settingError("ttl", "missing ttl configuration")
+  }
+
+  if c.Ttl.Name <= 0 {
+    return settingError("ttl.name", "illegal ttl: must be positive")
+  }
+
+  if c.Ttl.NameHistory <= 0 {
+    return settingError("ttl.name-history", "illegal ttl: must be positive")
+  }
+
+  if c.Ttl.Profile <= 0 {
+    return settingError("ttl.profile", "illegal ttl: must be positive")
+  }
+
+  if c.Ttl.Blacklist <= 0 {
+    return settingError("ttl.blacklist", "illegal ttl: must be positive")
   }
 
   return nil
 }
+
+func (e *SettingError) Error() string {
+  return fmt.Sprintf("%s: %s", e.Setting, e.Err)
+}
+
+// creates a new error for a given setting
+func settingError(setting string, format string, args ...interface{}) *SettingError {
+  return &SettingError{
+    Setting: setting,
+    Err:     fmt.Errorf(format, args...),
+  }
+}
+
+// parses a duration (if present) and stores it within the given target
+func parseDuration(setting string, raw *string, target *time.Duration) error {
+  if raw == nil {
+    return nil
+  }
+
+  duration, err := time.ParseDuration(*raw)
+  if err != nil {
+    return &SettingError{Setting: setting, Err: err}
+  }
+  *target = duration
+  return nil
+}
+
+// creates the string representation of a duration (as it would be given within a configuration
+// file)
+func formatDuration(duration time.Duration) *string {
+  raw := duration.String()
+  return &raw
+}
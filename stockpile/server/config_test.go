@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package server_test
+
+import (
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+)
+
+func TestPartialTtl(t *testing.T) {
+  cfg, err := servertest.ParseConfig(t, `ttl {
+    profile = "1h"
+  }`)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  defaults := server.DefaultConfig().Ttl
+  if cfg.Ttl.Profile != time.Hour {
+    t.Errorf("expected profile ttl of 1h but got %s", cfg.Ttl.Profile)
+  }
+  if cfg.Ttl.Name != defaults.Name || cfg.Ttl.NameHistory != defaults.NameHistory || cfg.Ttl.Blacklist != defaults.Blacklist {
+    t.Errorf("expected omitted ttls to retain their defaults but got %+v", cfg.Ttl)
+  }
+}
+
+func TestTtlMerge(t *testing.T) {
+  hour := "1h"
+  twoHours := "2h"
+  cfg := &server.TtlConfig{
+    Name:       time.Hour,
+    RawName:    &hour,
+    Profile:    time.Hour,
+    RawProfile: &hour,
+  }
+
+  cfg.Merge(&server.TtlConfig{
+    Profile:    time.Hour * 2,
+    RawProfile: &twoHours,
+  })
+  if cfg.Profile != time.Hour*2 || *cfg.RawProfile != twoHours {
+    t.Errorf("expected profile ttl to be overridden but got %s", cfg.Profile)
+  }
+  if cfg.Name != time.Hour || *cfg.RawName != hour {
+    t.Errorf("expected name ttl to be retained but got %s", cfg.Name)
+  }
+
+  // durations without a raw value have not been configured and thus never override
+  cfg.Merge(&server.TtlConfig{Profile: time.Hour * 3})
+  if cfg.Profile != time.Hour*2 {
+    t.Errorf("expected profile ttl to be retained but got %s", cfg.Profile)
+  }
+}
+
+func TestLayerPrecedence(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+  f.Write("00-base.hcl", `legacy-api = true
+  ttl {
+    name = "2h"
+    profile = "1h"
+  }`)
+  f.Write("10-override.hcl", `ttl {
+    profile = "3h"
+  }`)
+
+  cfg, err := server.LoadConfig(f.Dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if cfg.Ttl.Profile != time.Hour*3 {
+    t.Errorf("expected later layer to override profile ttl but got %s", cfg.Ttl.Profile)
+  }
+  if cfg.Ttl.Name != time.Hour*2 {
+    t.Errorf("expected name ttl of earlier layer to be retained but got %s", cfg.Ttl.Name)
+  }
+  if !*cfg.LegacyApiEnabled {
+    t.Error("expected legacy api to be enabled")
+  }
+}
+
+func TestParseErrors(t *testing.T) {
+  tests := map[string]string{
+    "ttl.profile":       `ttl { profile = "soon" }`,
+    "ttl.blacklist":     `ttl { blacklist = "" }`,
+    "shutdown-timeout":  `shutdown-timeout = "never"`,
+    "rate-limit.period": `rate-limit "local" { period = "10" }`,
+  }
+
+  for setting, src := range tests {
+    _, err := servertest.ParseConfig(t, src+"\n")
+    if err == nil {
+      t.Errorf("expected illegal %s to be rejected", setting)
+      continue
+    }
+
+    fileErr, ok := err.(*server.FileError)
+    if !ok {
+      t.Errorf("expected file error for illegal %s but got: %s", setting, err)
+      continue
+    }
+    if !strings.Contains(fileErr.Diagnostics[0].Summary, setting) || fileErr.Diagnostics[0].Subject == nil {
+      t.Errorf("expected diagnostic to point at %s but got: %s", setting, err)
+    }
+  }
+}
+
+func TestValidation(t *testing.T) {
+  tests := map[string]string{
+    "ttl.profile":      `ttl { profile = "-1h" }`,
+    "bind-address":     `bind-address = "localhost:http"`,
+    "plugin-allowlist": `plugin-allowlist = ["abc"]`,
+    "rate-limit.limit": `rate-limit "local" { limit = 0 }`,
+  }
+
+  for setting, src := range tests {
+    _, err := servertest.ParseConfig(t, src+"\n")
+    if err == nil || !strings.HasPrefix(err.Error(), setting+":") {
+      t.Errorf("expected illegal %s to be rejected but got: %v", setting, err)
+    }
+  }
+}
+
+func TestLegacyApiValidation(t *testing.T) {
+  cfg, err := server.MergeConfigLayers(nil)
+  if err != nil {
+    t.Fatalf("expected default configuration to be valid but got: %s", err)
+  }
+  if *cfg.LegacyApiEnabled {
+    t.Error("expected legacy api to be disabled by default")
+  }
+
+  cfg, err = servertest.ParseConfig(t, `legacy-api = true`)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !*cfg.LegacyApiEnabled {
+    t.Error("expected legacy api to be enabled")
+  }
+}
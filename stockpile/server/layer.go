@@ -0,0 +1,450 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package server
+
+import (
+  "fmt"
+  "io"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sort"
+  "strconv"
+  "strings"
+
+  "github.com/hashicorp/hcl2/hcl"
+  "github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+// identifies the source of settings which have not been overridden by any layer
+const DefaultSource = "default"
+
+// Represents a partial configuration along with the source it originates from (e.g. a file path)
+// Layers are merged on top of the default configuration in order to produce the effective
+// configuration
+type ConfigLayer struct {
+  Source string
+  Config *Config
+}
+
+// Represents the effective value of a single setting along with the source which defined it
+type ConfigSetting struct {
+  Name   string
+  Value  string
+  Source string
+}
+
+// Represents a set of diagnostics which have been produced while loading a configuration file
+type FileError struct {
+  Path        string
+  Diagnostics hcl.Diagnostics
+
+  files map[string]*hcl.File
+}
+
+// Loads a file or directory as a set of layers (one per file)
+func LoadConfigLayers(path string) ([]*ConfigLayer, error) {
+  file, err := os.Stat(path)
+  if err != nil {
+    return nil, err
+  }
+
+  if file.IsDir() {
+    return loadConfigDirectoryLayers(path)
+  }
+
+  cfg, err := LoadConfigFile(path)
+  if err != nil {
+    return nil, err
+  }
+  return []*ConfigLayer{{Source: path, Config: cfg}}, nil
+}
+
+// loads all configuration files within a directory (in lexical order)
+func loadConfigDirectoryLayers(path string) ([]*ConfigLayer, error) {
+  files, err := ioutil.ReadDir(path)
+  if err != nil {
+    return nil, err
+  }
+
+  layers := make([]*ConfigLayer, 0)
+  for _, file := range files {
+    if file.IsDir() {
+      continue
+    }
+
+    if strings.HasSuffix(file.Name(), ".hcl") || strings.HasSuffix(file.Name(), ".json") {
+      filePath := filepath.Join(path, file.Name())
+      cfg, err := LoadConfigFile(filePath)
+      if err != nil {
+        return nil, err
+      }
+      layers = append(layers, &ConfigLayer{Source: filePath, Config: cfg})
+    }
+  }
+  return layers, nil
+}
+
+// Merges a set of layers on top of the default configuration and validates the result
+func MergeConfigLayers(layers []*ConfigLayer) (*Config, error) {
+  cfg := DefaultConfig()
+  for _, layer := range layers {
+    cfg.Merge(layer.Config)
+  }
+
+  err := cfg.Validate()
+  if err != nil {
+    if settingErr, ok := err.(*SettingError); ok {
+      if source := settingSource(settingErr.Setting, layers); source != DefaultSource {
+        return nil, fmt.Errorf("%s (defined in %s)", err, source)
+      }
+    }
+    return nil, err
+  }
+  return cfg, nil
+}
+
+// describes all settings of an effective configuration along with the layer which defined them
+func DescribeConfig(cfg *Config, layers []*ConfigLayer) []*ConfigSetting {
+  settings := make([]*ConfigSetting, 0, len(describedSettings))
+  for _, setting := range describedSettings {
+    value, ok := setting.value(cfg)
+    if !ok {
+      continue
+    }
+
+    settings = append(settings, &ConfigSetting{
+      Name:   setting.name,
+      Value:  value,
+      Source: settingSource(setting.name, layers),
+    })
+  }
+
+  if cfg.Logging != nil && cfg.Logging.Modules != nil {
+    modules := make([]string, 0, len(*cfg.Logging.Modules))
+    for module := range *cfg.Logging.Modules {
+      modules = append(modules, module)
+    }
+    sort.Strings(modules)
+
+    for _, module := range modules {
+      name := "logging.modules." + module
+      settings = append(settings, &ConfigSetting{
+        Name:   name,
+        Value:  (*cfg.Logging.Modules)[module],
+        Source: settingSource(name, layers),
+      })
+    }
+  }
+
+  // plugin parameters are opaque to us (and may contain secrets) so we'll merely list their names
+  for _, plugin := range cfg.Plugins {
+    name := "plugin." + plugin.Name
+    settings = append(settings, &ConfigSetting{
+      Name:   name,
+      Value:  describeParameters(plugin.Parameters),
+      Source: settingSource(name, layers),
+    })
+  }
+  return settings
+}
+
+// identifies the last layer which defines a given setting
+func settingSource(name string, layers []*ConfigLayer) string {
+  for i := len(layers) - 1; i >= 0; i-- {
+    if isSettingDefined(layers[i].Config, name) {
+      return layers[i].Source
+    }
+  }
+  return DefaultSource
+}
+
+// evaluates whether a given setting is defined within a (partial) configuration
+func isSettingDefined(cfg *Config, name string) bool {
+  if strings.HasPrefix(name, "logging.modules.") {
+    if cfg.Logging == nil || cfg.Logging.Modules == nil {
+      return false
+    }
+    _, ok := (*cfg.Logging.Modules)[strings.TrimPrefix(name, "logging.modules.")]
+    return ok
+  }
+  if strings.HasPrefix(name, "plugin.") {
+    for _, plugin := range cfg.Plugins {
+      if plugin.Name == strings.TrimPrefix(name, "plugin.") {
+        return true
+      }
+    }
+    return false
+  }
+
+  // settings which refer to an entire block (e.g. "ttl") are considered defined when any of their
+  // children are defined
+  for _, setting := range describedSettings {
+    if setting.name == name {
+      _, ok := setting.value(cfg)
+      return ok
+    }
+  }
+  for _, setting := range describedSettings {
+    if strings.HasPrefix(setting.name, name+".") {
+      if _, ok := setting.value(cfg); ok {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+func (e *FileError) Error() string {
+  return fmt.Sprintf("failed to load configuration file \"%s\": %s", e.Path, e.Diagnostics.Error())
+}
+
+// writes a detailed description of all diagnostics (including the respective source snippets) to
+// a given writer
+func (e *FileError) WriteDiagnostics(w io.Writer, width uint, color bool) error {
+  return hcl.NewDiagnosticTextWriter(w, e.files, width, color).WriteDiagnostics(e.Diagnostics)
+}
+
+// locates the value of an attribute within a file body
+// attributes within blocks are identified by the block type followed by the attribute name (any
+// trailing path elements such as map keys are ignored)
+func attributeRange(body hcl.Body, path ...string) *hcl.Range {
+  syntaxBody, ok := body.(*hclsyntax.Body)
+  if !ok {
+    return nil
+  }
+
+  for _, name := range path {
+    if attr, ok := syntaxBody.Attributes[name]; ok {
+      rng := attr.Expr.Range()
+      return &rng
+    }
+
+    var next *hclsyntax.Body
+    for _, block := range syntaxBody.Blocks {
+      if block.Type == name {
+        next = block.Body
+      }
+    }
+    if next == nil {
+      return nil
+    }
+    syntaxBody = next
+  }
+  return nil
+}
+
+// describes how the value of a setting is retrieved from a (partial) configuration
+type describedSetting struct {
+  name  string
+  value func(cfg *Config) (string, bool)
+}
+
+// defines the settings which are reported when describing a configuration (module specific log
+// levels are handled separately as their names are not known in advance)
+var describedSettings = []describedSetting{
+  {"plugin-dir", func(cfg *Config) (string, bool) { return describeString(cfg.PluginDir) }},
+  {"plugin-allowlist", func(cfg *Config) (string, bool) { return describeStringList(cfg.PluginAllowlist) }},
+  {"bind-address", func(cfg *Config) (string, bool) { return describeString(cfg.BindAddress) }},
+  {"ui", func(cfg *Config) (string, bool) { return describeBool(cfg.UiEnabled) }},
+  {"legacy-api", func(cfg *Config) (string, bool) { return describeBool(cfg.LegacyApiEnabled) }},
+  {"metrics", func(cfg *Config) (string, bool) { return describeBool(cfg.MetricsEnabled) }},
+  {"health", func(cfg *Config) (string, bool) { return describeBool(cfg.HealthEnabled) }},
+  {"cors-override", func(cfg *Config) (string, bool) { return describeString(cfg.CorsOverride) }},
+  {"shutdown-timeout", func(cfg *Config) (string, bool) { return describeString(cfg.RawShutdownTimeout) }},
+  {"storage", func(cfg *Config) (string, bool) {
+    if cfg.Storage == nil || cfg.Storage.Type == "" {
+      return "", false
+    }
+    return cfg.Storage.Type, true
+  }},
+  {"upstream", func(cfg *Config) (string, bool) {
+    if cfg.Upstream == nil || cfg.Upstream.Type == "" {
+      return "", false
+    }
+    return cfg.Upstream.Type, true
+  }},
+  {"cluster", func(cfg *Config) (string, bool) {
+    if cfg.Cluster == nil || cfg.Cluster.Type == "" {
+      return "", false
+    }
+    return cfg.Cluster.Type, true
+  }},
+  {"rate-limit", func(cfg *Config) (string, bool) {
+    if cfg.RateLimit == nil || cfg.RateLimit.Type == "" {
+      return "", false
+    }
+    return cfg.RateLimit.Type, true
+  }},
+  {"rate-limit.limit", func(cfg *Config) (string, bool) {
+    if cfg.RateLimit == nil {
+      return "", false
+    }
+    return describeInt(cfg.RateLimit.Limit)
+  }},
+  {"rate-limit.period", func(cfg *Config) (string, bool) {
+    if cfg.RateLimit == nil {
+      return "", false
+    }
+    return describeString(cfg.RateLimit.RawPeriod)
+  }},
+  {"tracing.endpoint", func(cfg *Config) (string, bool) {
+    if cfg.Tracing == nil || cfg.Tracing.Endpoint == "" {
+      return "", false
+    }
+    return cfg.Tracing.Endpoint, true
+  }},
+  {"tracing.path", func(cfg *Config) (string, bool) {
+    if cfg.Tracing == nil {
+      return "", false
+    }
+    return describeString(cfg.Tracing.Path)
+  }},
+  {"tracing.insecure", func(cfg *Config) (string, bool) {
+    if cfg.Tracing == nil {
+      return "", false
+    }
+    return describeBool(cfg.Tracing.Insecure)
+  }},
+  {"tracing.headers", func(cfg *Config) (string, bool) {
+    if cfg.Tracing == nil || cfg.Tracing.Headers == nil {
+      return "", false
+    }
+
+    // header values typically contain credentials and are thus omitted
+    names := make([]string, 0, len(*cfg.Tracing.Headers))
+    for name := range *cfg.Tracing.Headers {
+      names = append(names, name)
+    }
+    sort.Strings(names)
+    return strings.Join(names, ", "), true
+  }},
+  {"tracing.service-name", func(cfg *Config) (string, bool) {
+    if cfg.Tracing == nil {
+      return "", false
+    }
+    return describeString(cfg.Tracing.ServiceName)
+  }},
+  {"tracing.sample-ratio", func(cfg *Config) (string, bool) {
+    if cfg.Tracing == nil || cfg.Tracing.SampleRatio == nil {
+      return "", false
+    }
+    return strconv.FormatFloat(*cfg.Tracing.SampleRatio, 'f', -1, 64), true
+  }},
+  {"logging.level", func(cfg *Config) (string, bool) {
+    if cfg.Logging == nil {
+      return "", false
+    }
+    return describeString(cfg.Logging.Level)
+  }},
+  {"logging.format", func(cfg *Config) (string, bool) {
+    if cfg.Logging == nil {
+      return "", false
+    }
+    return describeString(cfg.Logging.Format)
+  }},
+  {"logging.file", func(cfg *Config) (string, bool) {
+    if cfg.Logging == nil {
+      return "", false
+    }
+    return describeString(cfg.Logging.File)
+  }},
+  {"logging.max-size", func(cfg *Config) (string, bool) {
+    if cfg.Logging == nil {
+      return "", false
+    }
+    return describeInt(cfg.Logging.MaxSize)
+  }},
+  {"logging.max-backups", func(cfg *Config) (string, bool) {
+    if cfg.Logging == nil {
+      return "", false
+    }
+    return describeInt(cfg.Logging.MaxBackups)
+  }},
+  {"ttl.name", func(cfg *Config) (string, bool) {
+    if cfg.Ttl == nil {
+      return "", false
+    }
+    return describeString(cfg.Ttl.RawName)
+  }},
+  {"ttl.name-history", func(cfg *Config) (string, bool) {
+    if cfg.Ttl == nil {
+      return "", false
+    }
+    return describeString(cfg.Ttl.RawNameHistory)
+  }},
+  {"ttl.profile", func(cfg *Config) (string, bool) {
+    if cfg.Ttl == nil {
+      return "", false
+    }
+    return describeString(cfg.Ttl.RawProfile)
+  }},
+  {"ttl.blacklist", func(cfg *Config) (string, bool) {
+    if cfg.Ttl == nil {
+      return "", false
+    }
+    return describeString(cfg.Ttl.RawBlacklist)
+  }},
+}
+
+func describeString(value *string) (string, bool) {
+  if value == nil {
+    return "", false
+  }
+  return *value, true
+}
+
+func describeStringList(value *[]string) (string, bool) {
+  if value == nil {
+    return "", false
+  }
+  return "[" + strings.Join(*value, ", ") + "]", true
+}
+
+func describeBool(value *bool) (string, bool) {
+  if value == nil {
+    return "", false
+  }
+  return strconv.FormatBool(*value), true
+}
+
+func describeInt(value *int) (string, bool) {
+  if value == nil {
+    return "", false
+  }
+  return strconv.Itoa(*value), true
+}
+
+// lists the names of all parameters within a parameter body
+func describeParameters(body hcl.Body) string {
+  syntaxBody, ok := body.(*hclsyntax.Body)
+  if !ok {
+    return "{...}"
+  }
+
+  // attributes which have already been decoded by the enclosing block are hidden from this list
+  attrs, _ := syntaxBody.JustAttributes()
+  names := make([]string, 0, len(attrs)+len(syntaxBody.Blocks))
+  for name := range attrs {
+    names = append(names, name)
+  }
+  for _, block := range syntaxBody.Blocks {
+    names = append(names, block.Type)
+  }
+  sort.Strings(names)
+  return "{" + strings.Join(names, ", ") + "}"
+}
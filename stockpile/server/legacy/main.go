@@ -24,14 +24,17 @@ import (
 )
 
 type Server struct {
-  logger *logging.Logger
-  cache  *cache.Cache
+  logger   *logging.Logger
+  cache    *cache.Cache
+  shutdown func()
 }
 
-func NewServer(httpMux *http.ServeMux, cache *cache.Cache) (*Server) {
+// creates a new legacy API server which invokes the passed function when a shutdown is requested
+func NewServer(httpMux *http.ServeMux, cache *cache.Cache, shutdown func()) (*Server) {
   srv := &Server{
-    logger: logging.MustGetLogger("legacy"),
-    cache:  cache,
+    logger:   logging.MustGetLogger("legacy"),
+    cache:    cache,
+    shutdown: shutdown,
   }
 
   httpMux.HandleFunc("/v1/shutdown", srv.handleServerShutdown)
@@ -20,8 +20,6 @@ import (
   "encoding/json"
   "fmt"
   "net/http"
-  "os"
-  "time"
 
   "github.com/dotStart/Stockpile/stockpile/metadata"
 )
@@ -67,10 +65,6 @@ func (s *Server) handleServerShutdown(w http.ResponseWriter, req *http.Request)
   }
 
   w.WriteHeader(http.StatusNoContent)
-  go func() {
-    s.logger.Infof("Graceful shutdown has been requested via legacy API")
-    time.Sleep(time.Second * 5)
-    s.logger.Infof("Performing shutdown")
-    os.Exit(0)
-  }()
+  s.logger.Infof("Graceful shutdown has been requested via legacy API")
+  s.shutdown()
 }
@@ -59,7 +59,7 @@ func (s *Server) handleName(w http.ResponseWriter, req *http.Request) {
 
   at := time.Now()
   if req.Method == "DELETE" {
-    err := s.cache.PurgeProfileId(query, at)
+    err := s.cache.PurgeProfileId(req.Context(), query, at)
     if err != nil {
       http.Error(w, fmt.Sprintf("failed to purge profile association: %s", err), http.StatusServiceUnavailable)
       return
@@ -68,7 +68,7 @@ func (s *Server) handleName(w http.ResponseWriter, req *http.Request) {
     return
   }
 
-  profileId, err := s.cache.GetProfileId(query, time.Now())
+  profileId, err := s.cache.GetProfileId(req.Context(), query, time.Now())
   if err != nil {
     http.Error(w, fmt.Sprintf("failed to retrieve profile association: %s", err), http.StatusServiceUnavailable)
     return
@@ -131,7 +131,7 @@ func (s *Server) handleProfile(w http.ResponseWriter, req *http.Request) {
       return
     }
   } else {
-    profileId, err := s.cache.GetProfileId(query, time.Now())
+    profileId, err := s.cache.GetProfileId(req.Context(), query, time.Now())
     if err != nil {
       http.Error(w, fmt.Sprintf("failed to retrieve profile association: %s", err), http.StatusServiceUnavailable)
       return
@@ -141,7 +141,7 @@ func (s *Server) handleProfile(w http.ResponseWriter, req *http.Request) {
   }
 
   if req.Method == "DELETE" {
-    err := s.cache.PurgeProfile(id)
+    err := s.cache.PurgeProfile(req.Context(), id)
     if err != nil {
       http.Error(w, fmt.Sprintf("failed to purge profile: %s", err), http.StatusServiceUnavailable)
       return
@@ -150,7 +150,7 @@ func (s *Server) handleProfile(w http.ResponseWriter, req *http.Request) {
     return
   }
 
-  profile, err := s.cache.GetProfile(id)
+  profile, err := s.cache.GetProfile(req.Context(), id)
   if err != nil {
     http.Error(w, fmt.Sprintf("failed to retrieve profile: %s", err), http.StatusServiceUnavailable)
     return
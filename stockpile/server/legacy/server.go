@@ -41,7 +41,7 @@ func (s *Server) handleBlacklist(w http.ResponseWriter, req *http.Request) {
   }
 
   hostname := string(enc)
-  blacklist, err := s.cache.GetBlacklist()
+  blacklist, err := s.cache.GetBlacklist(req.Context())
   if err != nil {
     http.Error(w, fmt.Sprintf("failed to retrieve blacklist: %s", err), http.StatusServiceUnavailable)
     return
@@ -83,7 +83,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, req *http.Request) {
     return
   }
 
-  profile, err := s.cache.Login(query.Get("username"), query.Get("serverId"), "") // ip not supported in legacy
+  profile, err := s.cache.Login(req.Context(), query.Get("username"), query.Get("serverId"), "") // ip not supported in legacy
   if err != nil {
     http.Error(w, fmt.Sprintf("login server responded with error: %s", err), http.StatusServiceUnavailable)
     return
@@ -0,0 +1,518 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package server
+
+import (
+  "fmt"
+  "io/ioutil"
+  "os"
+  "reflect"
+  "sort"
+  "strconv"
+  "strings"
+
+  "github.com/hashicorp/hcl2/hcl"
+  "github.com/hashicorp/hcl2/hcl/hclsyntax"
+  "github.com/op/go-logging"
+  "github.com/zclconf/go-cty/cty"
+  "github.com/zclconf/go-cty/cty/function"
+)
+
+// defines the prefix of environment variables which override configuration settings
+const EnvironmentPrefix = "STOCKPILE_"
+
+// identifies the layer which is created from environment variables
+const EnvironmentSource = "environment"
+
+var configLogger = logging.MustGetLogger("config")
+
+// describes the kind of value a setting path refers to
+type settingKind int
+
+const (
+  // an attribute which holds a single value
+  valueSetting settingKind = iota
+  // an attribute which holds a map of values (the key is given as the last path element)
+  mapSetting
+  // the type label of a block
+  labelSetting
+  // a parameter which is passed on to a plugin (the name is given as the remaining path elements)
+  parameterSetting
+  // a parameter within one of multiple labeled blocks (the label is given as the next path element
+  // followed by the parameter name)
+  labeledParameterSetting
+)
+
+// describes a setting which may be overridden
+type overridableSetting struct {
+  path []string
+  kind settingKind
+}
+
+// lists all overridable settings (ordered by their length in descending order in order to prefer
+// more specific settings when matching environment variables)
+var overridableSettings = listOverridableSettings(reflect.TypeOf(Config{}), nil)
+
+// creates an evaluation context which provides the functions which are available within
+// configuration files (and the parameters of plugins)
+func EvalContext() *hcl.EvalContext {
+  return &hcl.EvalContext{
+    Functions: map[string]function.Function{
+      "env":  envFunction,
+      "file": fileFunction,
+    },
+  }
+}
+
+// retrieves the value of an environment variable (or the passed default value when the variable
+// is not defined)
+var envFunction = function.New(&function.Spec{
+  Params: []function.Parameter{
+    {Name: "name", Type: cty.String},
+  },
+  VarParam: &function.Parameter{Name: "default", Type: cty.String},
+  Type:     function.StaticReturnType(cty.String),
+  Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+    name := args[0].AsString()
+    if value, ok := os.LookupEnv(name); ok {
+      return cty.StringVal(value), nil
+    }
+    if len(args) > 1 {
+      return args[1], nil
+    }
+    return cty.NilVal, fmt.Errorf("environment variable \"%s\" is not defined", name)
+  },
+})
+
+// retrieves the contents of a file (trailing line breaks are removed as they are typically
+// introduced by editors or secret management tools)
+var fileFunction = function.New(&function.Spec{
+  Params: []function.Parameter{
+    {Name: "path", Type: cty.String},
+  },
+  Type: function.StaticReturnType(cty.String),
+  Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+    contents, err := ioutil.ReadFile(args[0].AsString())
+    if err != nil {
+      return cty.NilVal, err
+    }
+    return cty.StringVal(strings.TrimRight(string(contents), "\r\n")), nil
+  },
+})
+
+// creates a configuration layer from all environment variables which carry the STOCKPILE_ prefix
+// (e.g. STOCKPILE_BIND_ADDRESS or STOCKPILE_STORAGE_PASSWORD)
+// nested blocks within plugin parameters are separated using two underscores (e.g.
+// STOCKPILE_STORAGE_TLS__CA_FILE) as are the names of plugins and their parameters (e.g.
+// STOCKPILE_PLUGIN_REDIS__ADDRESS)
+// variables which do not refer to a known setting are ignored as orchestrators may inject variables
+// with the same prefix (e.g. STOCKPILE_PORT or STOCKPILE_SERVICE_HOST within Kubernetes)
+func EnvironmentLayer(environ []string) (*ConfigLayer, error) {
+  cfg := EmptyConfig()
+  for _, variable := range environ {
+    if !strings.HasPrefix(variable, EnvironmentPrefix) {
+      continue
+    }
+
+    i := strings.IndexRune(variable, '=')
+    if i == -1 {
+      continue
+    }
+    name := variable[:i]
+
+    path, err := environmentSettingPath(strings.TrimPrefix(name, EnvironmentPrefix))
+    if err != nil {
+      configLogger.Infof("ignoring environment variable %s: %s", name, err)
+      continue
+    }
+    err = cfg.Set(strings.Join(path, "."), variable[i+1:])
+    if err != nil {
+      return nil, fmt.Errorf("illegal environment variable %s: %s", name, err)
+    }
+  }
+
+  return &ConfigLayer{Source: EnvironmentSource, Config: cfg}, nil
+}
+
+// creates a configuration layer from a set of key=value pairs (e.g. as passed via the command
+// line)
+func OverrideLayer(source string, overrides []string) (*ConfigLayer, error) {
+  cfg := EmptyConfig()
+  for _, override := range overrides {
+    i := strings.IndexRune(override, '=')
+    if i == -1 {
+      return nil, fmt.Errorf("illegal override \"%s\": expected key=value", override)
+    }
+
+    err := cfg.Set(override[:i], override[i+1:])
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  return &ConfigLayer{Source: source, Config: cfg}, nil
+}
+
+// replaces the value of a setting (identified by its path such as "ttl.profile" or
+// "storage.password")
+func (c *Config) Set(name string, value string) error {
+  path := strings.Split(name, ".")
+  for _, element := range path {
+    if element == "" {
+      return fmt.Errorf("illegal setting \"%s\"", name)
+    }
+  }
+
+  err := setValue(reflect.ValueOf(c).Elem(), path, value)
+  if err != nil {
+    return &SettingError{Setting: name, Err: err}
+  }
+  return c.Parse()
+}
+
+// translates the name of an environment variable (without its prefix) into a setting path
+func environmentSettingPath(name string) ([]string, error) {
+  name = strings.ToLower(name)
+
+  for _, setting := range overridableSettings {
+    prefix := environmentName(setting.path)
+    switch setting.kind {
+    case valueSetting, labelSetting:
+      if name == prefix {
+        return setting.path, nil
+      }
+    case mapSetting, parameterSetting, labeledParameterSetting:
+      if !strings.HasPrefix(name, prefix+"_") {
+        continue
+      }
+
+      remaining := strings.Split(strings.TrimPrefix(name, prefix+"_"), "__")
+      if setting.kind == mapSetting && len(remaining) != 1 {
+        continue
+      }
+      if setting.kind == labeledParameterSetting && len(remaining) < 2 {
+        return nil, fmt.Errorf("expected %s name and parameter", setting.path[len(setting.path)-1])
+      }
+
+      path := append([]string{}, setting.path...)
+      for _, element := range remaining {
+        if element == "" {
+          return nil, fmt.Errorf("illegal parameter name")
+        }
+        path = append(path, strings.Replace(element, "_", "-", -1))
+      }
+      return path, nil
+    }
+  }
+  return nil, fmt.Errorf("no such setting")
+}
+
+// creates the (lower case) environment variable name for a given setting path
+func environmentName(path []string) string {
+  return strings.Replace(strings.Join(path, "_"), "-", "_", -1)
+}
+
+// lists all overridable settings within a given configuration structure
+func listOverridableSettings(t reflect.Type, parent []string) []*overridableSetting {
+  settings := make([]*overridableSetting, 0)
+  for i := 0; i < t.NumField(); i++ {
+    name, kind := hclTag(t.Field(i))
+    if kind == "" {
+      continue
+    }
+
+    path := append(append([]string{}, parent...), name)
+    fieldType := t.Field(i).Type
+    switch kind {
+    case "label":
+      settings = append(settings, &overridableSetting{path: parent, kind: labelSetting})
+    case "remain":
+      settings = append(settings, &overridableSetting{path: parent, kind: parameterSetting})
+    case "block":
+      if fieldType.Kind() == reflect.Slice {
+        settings = append(settings, &overridableSetting{path: path, kind: labeledParameterSetting})
+        continue
+      }
+      settings = append(settings, listOverridableSettings(fieldType.Elem(), path)...)
+    default:
+      if fieldType.Kind() == reflect.Ptr && fieldType.Elem().Kind() == reflect.Map {
+        settings = append(settings, &overridableSetting{path: path, kind: mapSetting})
+      } else {
+        settings = append(settings, &overridableSetting{path: path, kind: valueSetting})
+      }
+    }
+  }
+
+  sort.SliceStable(settings, func(i, j int) bool {
+    return len(environmentName(settings[i].path)) > len(environmentName(settings[j].path))
+  })
+  return settings
+}
+
+// extracts the name and kind of a field from its hcl tag
+// fields without a tag are derived from other fields (e.g. parsed durations) and are thus not
+// considered settings
+func hclTag(field reflect.StructField) (string, string) {
+  tag, ok := field.Tag.Lookup("hcl")
+  if !ok {
+    return "", ""
+  }
+
+  elements := strings.SplitN(tag, ",", 2)
+  if len(elements) == 1 {
+    return elements[0], "attr"
+  }
+  return elements[0], elements[1]
+}
+
+// updates the value of a setting within a given configuration structure
+func setValue(v reflect.Value, path []string, value string) error {
+  t := v.Type()
+  for i := 0; i < t.NumField(); i++ {
+    name, kind := hclTag(t.Field(i))
+    if name != path[0] || kind == "" || kind == "label" || kind == "remain" {
+      continue
+    }
+
+    field := v.Field(i)
+    if kind == "block" && field.Kind() == reflect.Slice {
+      if len(path) < 3 {
+        return fmt.Errorf("expected %s name and parameter", name)
+      }
+      return setValue(labeledBlock(field, path[1]), path[2:], value)
+    }
+    if kind == "block" {
+      if field.IsNil() {
+        field.Set(reflect.New(field.Type().Elem()))
+      }
+      if len(path) == 1 {
+        return setLabel(field.Elem(), value)
+      }
+      return setValue(field.Elem(), path[1:], value)
+    }
+
+    if field.Type().Elem().Kind() == reflect.Map {
+      if len(path) != 2 {
+        return fmt.Errorf("expected map key")
+      }
+      return setMapValue(field, path[1], value)
+    }
+    if len(path) != 1 {
+      return fmt.Errorf("no such setting")
+    }
+    return setScalarValue(field, value)
+  }
+
+  // settings which are not known to the configuration are passed on to plugins (if the block
+  // accepts parameters)
+  for i := 0; i < t.NumField(); i++ {
+    if _, kind := hclTag(t.Field(i)); kind == "remain" {
+      field := v.Field(i)
+      body, _ := field.Interface().(hcl.Body)
+      field.Set(reflect.ValueOf(overrideParameter(body, path, value)))
+      return nil
+    }
+  }
+  return fmt.Errorf("no such setting")
+}
+
+// retrieves the block with a given label from a slice of blocks (a new block is appended when
+// none of the existing blocks carries the label)
+func labeledBlock(field reflect.Value, label string) reflect.Value {
+  for i := 0; i < field.Len(); i++ {
+    block := field.Index(i).Elem()
+    if labelOf(block) == label {
+      return block
+    }
+  }
+
+  block := reflect.New(field.Type().Elem().Elem())
+  setLabel(block.Elem(), label)
+  field.Set(reflect.Append(field, block))
+  return block.Elem()
+}
+
+// retrieves the label of a block
+func labelOf(v reflect.Value) string {
+  t := v.Type()
+  for i := 0; i < t.NumField(); i++ {
+    if _, kind := hclTag(t.Field(i)); kind == "label" {
+      return v.Field(i).String()
+    }
+  }
+  return ""
+}
+
+// updates the type label of a block
+func setLabel(v reflect.Value, value string) error {
+  t := v.Type()
+  for i := 0; i < t.NumField(); i++ {
+    if _, kind := hclTag(t.Field(i)); kind == "label" {
+      v.Field(i).SetString(value)
+      return nil
+    }
+  }
+  return fmt.Errorf("expected attribute name")
+}
+
+// updates a single entry within a map attribute
+func setMapValue(field reflect.Value, key string, value string) error {
+  if field.IsNil() {
+    field.Set(reflect.New(field.Type().Elem()))
+  }
+  if field.Elem().IsNil() {
+    field.Elem().Set(reflect.MakeMap(field.Type().Elem()))
+  }
+
+  // maps are shared between layers and are thus copied before they are modified
+  copied := reflect.MakeMap(field.Type().Elem())
+  for _, k := range field.Elem().MapKeys() {
+    copied.SetMapIndex(k, field.Elem().MapIndex(k))
+  }
+  copied.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+  field.Elem().Set(copied)
+  return nil
+}
+
+// updates the value of a scalar attribute
+func setScalarValue(field reflect.Value, value string) error {
+  target := field.Type()
+  if target.Kind() == reflect.Ptr {
+    target = target.Elem()
+  }
+
+  parsed := reflect.New(target)
+  switch target.Kind() {
+  case reflect.String:
+    parsed.Elem().SetString(value)
+  case reflect.Bool:
+    b, err := strconv.ParseBool(value)
+    if err != nil {
+      return fmt.Errorf("illegal boolean \"%s\"", value)
+    }
+    parsed.Elem().SetBool(b)
+  case reflect.Int:
+    n, err := strconv.Atoi(value)
+    if err != nil {
+      return fmt.Errorf("illegal number \"%s\"", value)
+    }
+    parsed.Elem().SetInt(int64(n))
+  case reflect.Float64:
+    f, err := strconv.ParseFloat(value, 64)
+    if err != nil {
+      return fmt.Errorf("illegal number \"%s\"", value)
+    }
+    parsed.Elem().SetFloat(f)
+  case reflect.Slice:
+    // lists are passed as comma separated values
+    if target.Elem().Kind() != reflect.String {
+      return fmt.Errorf("unsupported setting type %s", target)
+    }
+    elements := make([]string, 0)
+    for _, element := range strings.Split(value, ",") {
+      if element = strings.TrimSpace(element); element != "" {
+        elements = append(elements, element)
+      }
+    }
+    parsed.Elem().Set(reflect.ValueOf(elements))
+  default:
+    return fmt.Errorf("unsupported setting type %s", target)
+  }
+
+  if field.Kind() == reflect.Ptr {
+    field.Set(parsed)
+  } else {
+    field.Set(parsed.Elem())
+  }
+  return nil
+}
+
+// applies the parameter overrides of a layer to a given parameter body
+func mergeParameters(base hcl.Body, overrides hcl.Body) hcl.Body {
+  override, ok := overrides.(*hclsyntax.Body)
+  if !ok {
+    return base
+  }
+  return overrideBody(base, override)
+}
+
+// creates a parameter body which overrides a single (potentially nested) parameter
+func overrideParameter(base hcl.Body, path []string, value string) hcl.Body {
+  override := &hclsyntax.Body{
+    Attributes: hclsyntax.Attributes{},
+  }
+
+  body := override
+  for _, blockType := range path[:len(path)-1] {
+    block := &hclsyntax.Block{
+      Type: blockType,
+      Body: &hclsyntax.Body{
+        Attributes: hclsyntax.Attributes{},
+      },
+    }
+    body.Blocks = append(body.Blocks, block)
+    body = block.Body
+  }
+
+  name := path[len(path)-1]
+  body.Attributes[name] = &hclsyntax.Attribute{
+    Name: name,
+    Expr: &hclsyntax.LiteralValueExpr{Val: cty.StringVal(value)},
+  }
+  return overrideBody(base, override)
+}
+
+// replaces the attributes and nested blocks within a given parameter body with the respective
+// attributes and blocks of an override body
+// bodies which have not been parsed from HCL (e.g. JSON) cannot be modified and are merged
+// instead (overriding parameters which are already defined within the body is thus not possible)
+func overrideBody(base hcl.Body, override *hclsyntax.Body) hcl.Body {
+  if base == nil {
+    return override
+  }
+  syntaxBody, ok := base.(*hclsyntax.Body)
+  if !ok {
+    return hcl.MergeBodies([]hcl.Body{base, override})
+  }
+
+  merged := *syntaxBody
+  merged.Attributes = hclsyntax.Attributes{}
+  for name, attr := range syntaxBody.Attributes {
+    merged.Attributes[name] = attr
+  }
+  for name, attr := range override.Attributes {
+    merged.Attributes[name] = attr
+  }
+
+  merged.Blocks = append(hclsyntax.Blocks{}, syntaxBody.Blocks...)
+  for _, block := range override.Blocks {
+    replaced := false
+    for i, existing := range merged.Blocks {
+      if existing.Type == block.Type {
+        cpy := *existing
+        cpy.Body = overrideBody(existing.Body, block.Body).(*hclsyntax.Body)
+        merged.Blocks[i] = &cpy
+        replaced = true
+        break
+      }
+    }
+    if !replaced {
+      merged.Blocks = append(merged.Blocks, block)
+    }
+  }
+  return &merged
+}
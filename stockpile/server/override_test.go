@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package server_test
+
+import (
+  "reflect"
+  "strings"
+  "testing"
+
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/zclconf/go-cty/cty"
+  "github.com/zclconf/go-cty/cty/convert"
+)
+
+func TestEnvironmentLayer(t *testing.T) {
+  layer, err := server.EnvironmentLayer([]string{
+    "PATH=/usr/bin",
+    "STOCKPILE_BIND_ADDRESS=127.0.0.1:8080",
+    "STOCKPILE_TTL_PROFILE=2h",
+    // injected by Kubernetes for a service called "stockpile"
+    "STOCKPILE_PORT=tcp://10.0.0.1:36623",
+    "STOCKPILE_SERVICE_HOST=10.0.0.1",
+    "STOCKPILE_SERVICE_PORT=36623",
+    "STOCKPILE_PORT_36623_TCP=tcp://10.0.0.1:36623",
+    "STOCKPILE_PORT_36623_TCP_ADDR=10.0.0.1",
+  })
+  if err != nil {
+    t.Fatalf("expected unknown variables to be ignored but got: %s", err)
+  }
+
+  cfg, err := server.MergeConfigLayers([]*server.ConfigLayer{layer})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if *cfg.BindAddress != "127.0.0.1:8080" {
+    t.Errorf("expected bind address to be overridden but got %s", *cfg.BindAddress)
+  }
+  if cfg.Ttl.Profile.String() != "2h0m0s" {
+    t.Errorf("expected profile ttl to be overridden but got %s", cfg.Ttl.Profile)
+  }
+}
+
+func TestEnvironmentLayerIllegalValue(t *testing.T) {
+  _, err := server.EnvironmentLayer([]string{"STOCKPILE_TTL_PROFILE=soon"})
+  if err == nil || !strings.Contains(err.Error(), "STOCKPILE_TTL_PROFILE") {
+    t.Fatalf("expected illegal value to be rejected but got: %v", err)
+  }
+}
+
+func TestOverrideLayer(t *testing.T) {
+  layer, err := server.OverrideLayer("command line", []string{"ttl.profile=3h", "storage.password=secret"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if layer.Config.Ttl.Profile.String() != "3h0m0s" {
+    t.Errorf("expected profile ttl to be overridden but got %s", layer.Config.Ttl.Profile)
+  }
+
+  // flags are given explicitly and are thus never ignored
+  for _, override := range []string{"port=36623", "ttl.soon=1h", "ttl.profile=soon", "ttl"} {
+    _, err = server.OverrideLayer("command line", []string{override})
+    if err == nil {
+      t.Errorf("expected override \"%s\" to be rejected", override)
+    }
+  }
+}
+
+func TestPluginLayers(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+  f.Write("00-base.hcl", `plugin "redis" {
+    address = "localhost:6379"
+    database = 1
+  }`)
+
+  layers, err := server.LoadConfigLayers(f.Dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+  env, err := server.EnvironmentLayer([]string{
+    "STOCKPILE_PLUGIN_REDIS__ADDRESS=redis:6379",
+    "STOCKPILE_PLUGIN_METRICS__PORT=9100",
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // parameters of configured plugins are overridden individually while new blocks are appended
+  cfg, err := server.MergeConfigLayers(append(layers, env))
+  if err != nil {
+    t.Fatal(err)
+  }
+  parameters := make(map[string]map[string]string)
+  for _, plugin := range cfg.Plugins {
+    attrs, diag := plugin.Parameters.JustAttributes()
+    if diag.HasErrors() {
+      t.Fatal(diag.Error())
+    }
+
+    parameters[plugin.Name] = make(map[string]string)
+    for name, attr := range attrs {
+      value, diag := attr.Expr.Value(server.EvalContext())
+      if diag.HasErrors() {
+        t.Fatal(diag.Error())
+      }
+      value, err := convert.Convert(value, cty.String)
+      if err != nil {
+        t.Fatal(err)
+      }
+      parameters[plugin.Name][name] = value.AsString()
+    }
+  }
+
+  expected := map[string]map[string]string{
+    "redis":   {"address": "redis:6379", "database": "1"},
+    "metrics": {"port": "9100"},
+  }
+  if !reflect.DeepEqual(parameters, expected) {
+    t.Fatalf("expected plugin parameters %v but got %v", expected, parameters)
+  }
+
+  _, err = server.OverrideLayer("command line", []string{"plugin.redis=redis:6379"})
+  if err == nil {
+    t.Error("expected plugin override without a parameter name to be rejected")
+  }
+}
@@ -0,0 +1,363 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package server
+
+import (
+  "crypto/sha256"
+  "fmt"
+  "io"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/hashicorp/hcl2/hcl"
+  "github.com/hashicorp/hcl2/hcl/hclsyntax"
+  ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// guards settings which may be replaced while the server is running
+var reloadMutex sync.RWMutex
+
+// retrieves a snapshot of the TTL configuration which is currently in effect
+// callers should not retain the returned value as the configuration may be reloaded at any time
+func (c *Config) GetTtl() *TtlConfig {
+  reloadMutex.RLock()
+  defer reloadMutex.RUnlock()
+  ttl := *c.Ttl
+  return &ttl
+}
+
+// retrieves the CORS override which is currently in effect (or an empty string when no override
+// has been configured)
+func (c *Config) GetCorsOverride() string {
+  reloadMutex.RLock()
+  defer reloadMutex.RUnlock()
+  if c.CorsOverride == nil {
+    return ""
+  }
+  return *c.CorsOverride
+}
+
+// evaluates whether upstream requests are rejected once the request budget has been exhausted
+// (budgets are merely tracked otherwise)
+func (c *Config) IsRateLimitEnforced() bool {
+  reloadMutex.RLock()
+  defer reloadMutex.RUnlock()
+  return c.RateLimit.Enforce != nil && *c.RateLimit.Enforce
+}
+
+// retrieves a snapshot of the logging configuration which is currently in effect
+func (c *Config) GetLogging() *LoggingConfig {
+  reloadMutex.RLock()
+  defer reloadMutex.RUnlock()
+  logging := *c.Logging
+  return &logging
+}
+
+// retrieves a snapshot of the plugin configurations which are currently in effect
+func (c *Config) GetPlugins() []*PluginConfig {
+  reloadMutex.RLock()
+  defer reloadMutex.RUnlock()
+  plugins := make([]*PluginConfig, len(c.Plugins))
+  copy(plugins, c.Plugins)
+  return plugins
+}
+
+// compares this configuration with a given (newly loaded) configuration
+// the resulting changes are split into changes which may be applied while the server is running
+// and changes which require a restart
+func (c *Config) Compare(other *Config) (reloadable []*entity.ConfigChange, fixed []*entity.ConfigChange) {
+  reloadMutex.RLock()
+  defer reloadMutex.RUnlock()
+
+  fixed = make([]*entity.ConfigChange, 0)
+  compare(&fixed, "plugin-dir", stringValue(c.PluginDir), stringValue(other.PluginDir))
+  compare(&fixed, "plugin-allowlist", stringListValue(c.PluginAllowlist), stringListValue(other.PluginAllowlist))
+  compare(&fixed, "bind-address", stringValue(c.BindAddress), stringValue(other.BindAddress))
+  compare(&fixed, "ui", boolValue(c.UiEnabled), boolValue(other.UiEnabled))
+  compare(&fixed, "legacy-api", boolValue(c.LegacyApiEnabled), boolValue(other.LegacyApiEnabled))
+  compare(&fixed, "metrics", boolValue(c.MetricsEnabled), boolValue(other.MetricsEnabled))
+  compare(&fixed, "health", boolValue(c.HealthEnabled), boolValue(other.HealthEnabled))
+  compare(&fixed, "shutdown-timeout", c.ShutdownTimeout.String(), other.ShutdownTimeout.String())
+  compare(&fixed, "storage", c.Storage.Type, other.Storage.Type)
+  compare(&fixed, "storage.parameters", parameterDigest(c.Storage.Parameters), parameterDigest(other.Storage.Parameters))
+  compare(&fixed, "upstream", upstreamType(c.Upstream), upstreamType(other.Upstream))
+  compare(&fixed, "upstream.parameters", upstreamParameters(c.Upstream), upstreamParameters(other.Upstream))
+  compare(&fixed, "cluster", clusterType(c.Cluster), clusterType(other.Cluster))
+  compare(&fixed, "cluster.parameters", clusterParameters(c.Cluster), clusterParameters(other.Cluster))
+  compare(&fixed, "rate-limit", c.RateLimit.Type, other.RateLimit.Type)
+  compare(&fixed, "rate-limit.parameters", parameterDigest(c.RateLimit.Parameters), parameterDigest(other.RateLimit.Parameters))
+  // the tracer is created upon startup and thus none of its settings may be reloaded
+  oldTracing, newTracing := tracingValues(c.Tracing), tracingValues(other.Tracing)
+  for _, setting := range tracingSettings {
+    compare(&fixed, "tracing."+setting, oldTracing[setting], newTracing[setting])
+  }
+  compare(&fixed, "logging.format", stringValue(c.Logging.Format), stringValue(other.Logging.Format))
+  compare(&fixed, "logging.file", stringValue(c.Logging.File), stringValue(other.Logging.File))
+  compare(&fixed, "logging.max-size", intValue(c.Logging.MaxSize), intValue(other.Logging.MaxSize))
+  compare(&fixed, "logging.max-backups", intValue(c.Logging.MaxBackups), intValue(other.Logging.MaxBackups))
+
+  reloadable = make([]*entity.ConfigChange, 0)
+  compare(&reloadable, "cors-override", stringValue(c.CorsOverride), stringValue(other.CorsOverride))
+  compare(&reloadable, "rate-limit.limit", intValue(c.RateLimit.Limit), intValue(other.RateLimit.Limit))
+  compare(&reloadable, "rate-limit.period", c.RateLimit.Period.String(), other.RateLimit.Period.String())
+  compare(&reloadable, "rate-limit.enforce", boolValue(c.RateLimit.Enforce), boolValue(other.RateLimit.Enforce))
+  compare(&reloadable, "logging.level", stringValue(c.Logging.Level), stringValue(other.Logging.Level))
+  for _, module := range moduleNames(c.Logging, other.Logging) {
+    compare(&reloadable, "logging.modules."+module, moduleLevel(c.Logging, module), moduleLevel(other.Logging, module))
+  }
+  compare(&reloadable, "ttl.name", c.Ttl.Name.String(), other.Ttl.Name.String())
+  compare(&reloadable, "ttl.name-history", c.Ttl.NameHistory.String(), other.Ttl.NameHistory.String())
+  compare(&reloadable, "ttl.profile", c.Ttl.Profile.String(), other.Ttl.Profile.String())
+  compare(&reloadable, "ttl.blacklist", c.Ttl.Blacklist.String(), other.Ttl.Blacklist.String())
+  // plugins are reconfigured on every reload as changes to the values of their parameters cannot
+  // be detected reliably - we'll merely report added or removed parameters here
+  for _, plugin := range pluginNames(c.Plugins, other.Plugins) {
+    compare(&reloadable, "plugin."+plugin, pluginParameters(c.Plugins, plugin), pluginParameters(other.Plugins, plugin))
+  }
+  return
+}
+
+// replaces all reloadable settings of this configuration with the settings of a given
+// configuration
+func (c *Config) Apply(other *Config) {
+  reloadMutex.Lock()
+  defer reloadMutex.Unlock()
+
+  c.CorsOverride = other.CorsOverride
+  c.RateLimit.Limit = other.RateLimit.Limit
+  c.RateLimit.Period = other.RateLimit.Period
+  c.RateLimit.RawPeriod = other.RateLimit.RawPeriod
+  c.RateLimit.Enforce = other.RateLimit.Enforce
+  c.Logging.Level = other.Logging.Level
+  c.Logging.Modules = other.Logging.Modules
+  c.Plugins = other.Plugins
+  // TTLs are updated in place as derived configurations (see WithStorage) share this instance
+  *c.Ttl = *other.Ttl
+}
+
+// records a change when two setting values differ
+func compare(changes *[]*entity.ConfigChange, setting string, old string, new string) {
+  if old == new {
+    return
+  }
+
+  *changes = append(*changes, &entity.ConfigChange{
+    Setting: setting,
+    Old:     old,
+    New:     new,
+  })
+}
+
+func stringValue(value *string) string {
+  if value == nil {
+    return ""
+  }
+  return *value
+}
+
+func stringListValue(value *[]string) string {
+  if value == nil {
+    return ""
+  }
+  return strings.Join(*value, ", ")
+}
+
+func boolValue(value *bool) string {
+  if value == nil {
+    return ""
+  }
+  return strconv.FormatBool(*value)
+}
+
+func intValue(value *int) string {
+  if value == nil {
+    return ""
+  }
+  return strconv.Itoa(*value)
+}
+
+func floatValue(value *float64) string {
+  if value == nil {
+    return ""
+  }
+  return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+func upstreamType(cfg *UpstreamConfig) string {
+  if cfg == nil {
+    return ""
+  }
+  return cfg.Type
+}
+
+func clusterType(cfg *ClusterConfig) string {
+  if cfg == nil {
+    return ""
+  }
+  return cfg.Type
+}
+
+func upstreamParameters(cfg *UpstreamConfig) string {
+  if cfg == nil {
+    return ""
+  }
+  return parameterDigest(cfg.Parameters)
+}
+
+func clusterParameters(cfg *ClusterConfig) string {
+  if cfg == nil {
+    return ""
+  }
+  return parameterDigest(cfg.Parameters)
+}
+
+// summarizes the parameters within a parameter body in order to detect changes to their values
+// values are hashed rather than reported as they frequently contain credentials
+func parameterDigest(body hcl.Body) string {
+  if body == nil {
+    return "{}"
+  }
+  names := describeParameters(body)
+  syntaxBody, ok := body.(*hclsyntax.Body)
+  if !ok || names == "{}" {
+    return names
+  }
+
+  hash := sha256.New()
+  writeParameters(hash, syntaxBody)
+  return fmt.Sprintf("%s (%x)", names, hash.Sum(nil)[:4])
+}
+
+// writes the evaluated values of all parameters within a body (and its nested blocks) in a stable
+// order
+func writeParameters(w io.Writer, body *hclsyntax.Body) {
+  attrs, _ := body.JustAttributes()
+  names := make([]string, 0, len(attrs))
+  for name := range attrs {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  for _, name := range names {
+    // values which cannot be evaluated are rejected by their consumer anyways
+    enc := []byte("<invalid>")
+    value, diag := attrs[name].Expr.Value(EvalContext())
+    if !diag.HasErrors() && value.IsWhollyKnown() {
+      if marshaled, err := ctyjson.Marshal(value, value.Type()); err == nil {
+        enc = marshaled
+      }
+    }
+    fmt.Fprintf(w, "%s = %s\n", name, enc)
+  }
+
+  for _, block := range body.Blocks {
+    fmt.Fprintf(w, "%s %q {\n", block.Type, block.Labels)
+    writeParameters(w, block.Body)
+    fmt.Fprint(w, "}\n")
+  }
+}
+
+// lists the names of all tracing settings (in the order in which their changes are reported)
+var tracingSettings = []string{"endpoint", "path", "insecure", "headers", "service-name", "sample-ratio"}
+
+// retrieves the values of all tracing settings (indexed by their names)
+func tracingValues(cfg *TracingConfig) map[string]string {
+  if cfg == nil {
+    return make(map[string]string)
+  }
+  return map[string]string{
+    "endpoint":     cfg.Endpoint,
+    "path":         stringValue(cfg.Path),
+    "insecure":     boolValue(cfg.Insecure),
+    "headers":      headerDigest(cfg.Headers),
+    "service-name": stringValue(cfg.ServiceName),
+    "sample-ratio": floatValue(cfg.SampleRatio),
+  }
+}
+
+// summarizes a set of headers in order to detect changes to their values
+// values are hashed rather than reported as they frequently contain credentials
+func headerDigest(headers *map[string]string) string {
+  if headers == nil {
+    return ""
+  }
+  names := make([]string, 0, len(*headers))
+  for name := range *headers {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  hash := sha256.New()
+  for _, name := range names {
+    fmt.Fprintf(hash, "%s = %q\n", name, (*headers)[name])
+  }
+  return fmt.Sprintf("{%s} (%x)", strings.Join(names, ", "), hash.Sum(nil)[:4])
+}
+
+// lists the names of all modules which have been configured in either of the passed configurations
+func moduleNames(configs ...*LoggingConfig) []string {
+  known := make(map[string]bool)
+  names := make([]string, 0)
+  for _, cfg := range configs {
+    if cfg.Modules == nil {
+      continue
+    }
+    for module := range *cfg.Modules {
+      if !known[module] {
+        known[module] = true
+        names = append(names, module)
+      }
+    }
+  }
+  sort.Strings(names)
+  return names
+}
+
+func moduleLevel(cfg *LoggingConfig, module string) string {
+  if cfg.Modules == nil {
+    return ""
+  }
+  return (*cfg.Modules)[module]
+}
+
+// lists the names of all plugins which have been configured in either of the passed sets
+func pluginNames(sets ...[]*PluginConfig) []string {
+  known := make(map[string]bool)
+  names := make([]string, 0)
+  for _, set := range sets {
+    for _, plugin := range set {
+      if !known[plugin.Name] {
+        known[plugin.Name] = true
+        names = append(names, plugin.Name)
+      }
+    }
+  }
+  sort.Strings(names)
+  return names
+}
+
+func pluginParameters(set []*PluginConfig, name string) string {
+  for _, plugin := range set {
+    if plugin.Name == name {
+      return describeParameters(plugin.Parameters)
+    }
+  }
+  return ""
+}
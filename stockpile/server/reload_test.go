@@ -0,0 +1,230 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package server_test
+
+import (
+  "strings"
+  "testing"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+)
+
+// retrieves the change to a given setting (or nil if the setting has not been changed)
+func findChange(changes []*entity.ConfigChange, setting string) *entity.ConfigChange {
+  for _, change := range changes {
+    if change.Setting == setting {
+      return change
+    }
+  }
+  return nil
+}
+
+func TestCompareIdentical(t *testing.T) {
+  src := `storage "file" {
+    path = "cache"
+    options {
+      mode = 1
+    }
+  }
+
+  cluster "redis" {
+    address = "localhost:6379"
+  }`
+
+  reloadable, fixed := servertest.LoadConfig(t, src).Compare(servertest.LoadConfig(t, src))
+  if len(reloadable) != 0 || len(fixed) != 0 {
+    t.Fatalf("expected no changes but got %v and %v", reloadable, fixed)
+  }
+}
+
+func TestCompareParameters(t *testing.T) {
+  old := servertest.LoadConfig(t, `storage "redis" {
+    address = "localhost:6379"
+    password = "secret-1"
+  }
+
+  upstream "mojang" {
+    api-url = "https://api.mojang.com"
+  }
+
+  cluster "redis" {
+    address = "localhost:6379"
+  }
+
+  rate-limit "redis" {
+    address = "localhost:6379"
+  }`)
+  current := servertest.LoadConfig(t, `storage "redis" {
+    address = "localhost:6379"
+    password = "secret-2"
+  }
+
+  upstream "mojang" {
+    api-url = "https://api.example.org"
+  }
+
+  cluster "redis" {
+    address = "localhost:6380"
+  }
+
+  rate-limit "redis" {
+    address = "localhost:6380"
+  }`)
+
+  reloadable, fixed := old.Compare(current)
+  if len(reloadable) != 0 {
+    t.Errorf("expected no reloadable changes but got %v", reloadable)
+  }
+  for _, setting := range []string{"storage.parameters", "upstream.parameters", "cluster.parameters", "rate-limit.parameters"} {
+    if findChange(fixed, setting) == nil {
+      t.Errorf("expected change to %s to require a restart", setting)
+    }
+  }
+
+  // parameter values are never reported as they may contain credentials
+  change := findChange(fixed, "storage.parameters")
+  if change != nil && (strings.Contains(change.String(), "secret") || !strings.HasPrefix(change.Old, "{address, password}")) {
+    t.Errorf("expected parameter names and digest to be reported but got %s", change)
+  }
+}
+
+func TestCompareNestedParameters(t *testing.T) {
+  old := servertest.LoadConfig(t, `storage "redis" {
+    address = "localhost:6379"
+    pool {
+      size = 10
+    }
+  }`)
+  current := servertest.LoadConfig(t, `storage "redis" {
+    address = "localhost:6379"
+    pool {
+      size = 20
+    }
+  }`)
+
+  _, fixed := old.Compare(current)
+  if findChange(fixed, "storage.parameters") == nil {
+    t.Fatal("expected change to nested parameters to require a restart")
+  }
+}
+
+func TestCompareFixedSettings(t *testing.T) {
+  old := servertest.LoadConfig(t, `plugin-dir = "plugins"`)
+  current := servertest.LoadConfig(t, `plugin-dir = "other-plugins"
+  bind-address = "0.0.0.0:36623"
+  plugin-allowlist = ["`+strings.Repeat("a", 64)+`"]`)
+
+  _, fixed := old.Compare(current)
+  for _, setting := range []string{"plugin-dir", "bind-address", "plugin-allowlist"} {
+    if findChange(fixed, setting) == nil {
+      t.Errorf("expected change to %s to require a restart", setting)
+    }
+  }
+}
+
+func TestCompareTracingSettings(t *testing.T) {
+  old := servertest.LoadConfig(t, `tracing {
+    endpoint = "localhost:4318"
+    sample-ratio = 0.5
+    headers = {
+      authorization = "secret-1"
+    }
+  }`)
+  current := servertest.LoadConfig(t, `tracing {
+    endpoint = "localhost:4318"
+    sample-ratio = 0.25
+    service-name = "stockpile-eu"
+    insecure = true
+    headers = {
+      authorization = "secret-2"
+    }
+  }`)
+
+  reloadable, fixed := old.Compare(current)
+  if len(reloadable) != 0 {
+    t.Errorf("expected no reloadable changes but got %v", reloadable)
+  }
+  for _, setting := range []string{"tracing.sample-ratio", "tracing.service-name", "tracing.insecure", "tracing.headers"} {
+    if findChange(fixed, setting) == nil {
+      t.Errorf("expected change to %s to require a restart", setting)
+    }
+  }
+  if findChange(fixed, "tracing.endpoint") != nil {
+    t.Error("expected unchanged endpoint not to be reported")
+  }
+  if change := findChange(fixed, "tracing.headers"); change != nil && (strings.Contains(change.Old, "secret") || strings.Contains(change.New, "secret")) {
+    t.Errorf("expected header values to be omitted but got %s", change)
+  }
+}
+
+func TestCompareReloadableSettings(t *testing.T) {
+  old := servertest.LoadConfig(t, `ttl {
+    profile = "1h"
+  }`)
+  current := servertest.LoadConfig(t, `ttl {
+    profile = "2h"
+  }
+
+  rate-limit "local" {
+    limit = 100
+    enforce = true
+  }`)
+
+  if old.IsRateLimitEnforced() || !current.IsRateLimitEnforced() {
+    t.Errorf("expected rate limit to be enforced only when enabled")
+  }
+
+  reloadable, fixed := old.Compare(current)
+  if len(fixed) != 0 {
+    t.Errorf("expected no changes which require a restart but got %v", fixed)
+  }
+  for _, setting := range []string{"ttl.profile", "rate-limit.limit", "rate-limit.enforce"} {
+    if findChange(reloadable, setting) == nil {
+      t.Errorf("expected change to %s to be reloadable", setting)
+    }
+  }
+}
+
+func TestApply(t *testing.T) {
+  cfg := servertest.LoadConfig(t, `ttl {
+    profile = "1h"
+  }`)
+  ttl := cfg.Ttl
+
+  cfg.Apply(servertest.LoadConfig(t, `cors-override = "*"
+
+  ttl {
+    profile = "2h"
+  }
+
+  logging {
+    level = "DEBUG"
+  }`))
+  if cfg.Ttl != ttl {
+    t.Error("expected ttl configuration to be updated in place")
+  }
+  if cfg.GetTtl().Profile.String() != "2h0m0s" {
+    t.Errorf("expected profile ttl of 2h but got %s", cfg.GetTtl().Profile)
+  }
+  if cfg.GetCorsOverride() != "*" {
+    t.Errorf("expected cors override \"*\" but got \"%s\"", cfg.GetCorsOverride())
+  }
+  if level := cfg.GetLogging().Level; level == nil || *level != "DEBUG" {
+    t.Errorf("expected log level DEBUG but got %v", level)
+  }
+}
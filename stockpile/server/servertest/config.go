@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package servertest
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/dotStart/Stockpile/stockpile/server"
+)
+
+// Represents a temporary directory which holds configuration files along with any data they refer
+// to (such as databases)
+type Fixture struct {
+  t   testing.TB
+  Dir string
+}
+
+// creates a new fixture within a temporary directory which is to be removed by the caller
+func NewFixture(t testing.TB) *Fixture {
+  dir, err := ioutil.TempDir("", "stockpile-test")
+  if err != nil {
+    t.Fatal(err)
+  }
+  return &Fixture{t: t, Dir: dir}
+}
+
+// removes the fixture directory along with its contents
+func (f *Fixture) Remove() {
+  os.RemoveAll(f.Dir)
+}
+
+// writes a file to the fixture directory and returns its path
+// all occurrences of {dir} within the source are replaced with the fixture directory
+func (f *Fixture) Write(name string, src string) string {
+  path := filepath.Join(f.Dir, name)
+  err := ioutil.WriteFile(path, []byte(strings.Replace(src, "{dir}", filepath.ToSlash(f.Dir), -1)), 0644)
+  if err != nil {
+    f.t.Fatal(err)
+  }
+  return path
+}
+
+// loads a configuration from a given source and returns the resulting error (if any)
+func (f *Fixture) Parse(src string) (*server.Config, error) {
+  return server.LoadConfig(f.Write("config.hcl", src))
+}
+
+// loads a configuration from a given source which is expected to be valid
+func (f *Fixture) Load(src string) *server.Config {
+  cfg, err := f.Parse(src)
+  if err != nil {
+    f.t.Fatal(err)
+  }
+  return cfg
+}
+
+// loads a configuration from a given source and returns the resulting error (if any)
+func ParseConfig(t testing.TB, src string) (*server.Config, error) {
+  f := NewFixture(t)
+  defer f.Remove()
+  return f.Parse(src)
+}
+
+// loads a configuration from a given source which is expected to be valid
+func LoadConfig(t testing.TB, src string) *server.Config {
+  f := NewFixture(t)
+  defer f.Remove()
+  return f.Load(src)
+}
@@ -17,49 +17,65 @@
 package service
 
 import (
+  "github.com/dotStart/Stockpile/entity"
   "github.com/dotStart/Stockpile/rpc"
   "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/logs"
   "github.com/golang/protobuf/ptypes/empty"
   "github.com/op/go-logging"
   "google.golang.org/grpc/peer"
 )
 
 type EventServiceImpl struct {
-  logger *logging.Logger
-  cache  *cache.Cache
+  logger   *logging.Logger
+  cache    *cache.Cache
+  shutdown <-chan struct{}
 }
 
-func NewEventService(cache *cache.Cache) (*EventServiceImpl) {
+// creates a new event service which ends all active streams once the passed channel is closed
+func NewEventService(cache *cache.Cache, shutdown <-chan struct{}) (*EventServiceImpl) {
   return &EventServiceImpl{
-    logger: logging.MustGetLogger("event-srv"),
-    cache:  cache,
+    logger:   logging.MustGetLogger("event-srv"),
+    cache:    cache,
+    shutdown: shutdown,
   }
 }
 
 func (s *EventServiceImpl) StreamEvents(_ *empty.Empty, srv rpc.EventService_StreamEventsServer) error {
+  logger := logs.ForContext(srv.Context(), s.logger)
   p, ok := peer.FromContext(srv.Context())
   if ok {
-    s.logger.Debugf("beginning to stream events to rpc client %s", p.Addr)
+    logger.Debugf("beginning to stream events to rpc client %s", p.Addr)
   }
 
   listener := s.cache.NewListener()
   defer listener.Close()
 
-  for e := range listener.C {
+  for {
+    var e *entity.Event
+    select {
+    case e = <-listener.C:
+    case <-srv.Context().Done():
+    case <-s.shutdown:
+    }
+    if e == nil {
+      break
+    }
+
     if ok {
-      s.logger.Debugf("forwarding event of type %T (using key %T) to rpc client %s", e.Object, e.Key, p.Addr)
+      logger.Debugf("forwarding event of type %T (using key %T) to rpc client %s", e.Object, e.Key, p.Addr)
     }
 
     enc, err := rpc.EventToRpc(e)
     if err != nil {
-      s.logger.Errorf("failed to encode event %v: %s", e, err)
+      logger.Errorf("failed to encode event %v: %s", e, err)
       continue
     }
     srv.Send(enc)
   }
 
   if ok {
-    s.logger.Debugf("event stream has ended - closing session with %s", p.Addr)
+    logger.Debugf("event stream has ended - closing session with %s", p.Addr)
   }
   return nil
 }
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package service
+
+import (
+  "golang.org/x/net/context"
+  "google.golang.org/grpc"
+)
+
+// combines multiple unary interceptors into a single interceptor (the first interceptor will be
+// the outermost one)
+func chainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+  return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+    for i := len(interceptors) - 1; i >= 0; i-- {
+      interceptor := interceptors[i]
+      next := handler
+      handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+        return interceptor(ctx, req, info, next)
+      }
+    }
+    return handler(ctx, req)
+  }
+}
+
+// combines multiple stream interceptors into a single interceptor (the first interceptor will be
+// the outermost one)
+func chainStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+  return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+    for i := len(interceptors) - 1; i >= 0; i-- {
+      interceptor := interceptors[i]
+      next := handler
+      handler = func(srv interface{}, stream grpc.ServerStream) error {
+        return interceptor(srv, stream, info, next)
+      }
+    }
+    return handler(srv, stream)
+  }
+}
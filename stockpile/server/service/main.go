@@ -17,13 +17,21 @@
 package service
 
 import (
+  "context"
+  "fmt"
   "net"
 
+  "github.com/dotStart/Stockpile/entity"
   "github.com/dotStart/Stockpile/rpc"
   "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/health"
+  "github.com/dotStart/Stockpile/stockpile/logs"
+  "github.com/dotStart/Stockpile/stockpile/metrics"
   "github.com/dotStart/Stockpile/stockpile/plugin"
+  "github.com/dotStart/Stockpile/stockpile/tracing"
   "github.com/op/go-logging"
   "google.golang.org/grpc"
+  healthpb "google.golang.org/grpc/health/grpc_health_v1"
   "google.golang.org/grpc/reflection"
 )
 
@@ -32,44 +40,74 @@ type Server struct {
   logger *logging.Logger
   plugin *plugin.Manager
   cache  *cache.Cache
+  health *health.Checker
+  reload ReloadFunc
 
-  srv *grpc.Server
+  srv      *grpc.Server
+  shutdown chan struct{}
 }
 
-// Constructs a new RPC server instance and starts it
-func NewServer(plugin *plugin.Manager, cache *cache.Cache) (*Server, error) {
+// Reloads the server configuration and returns the list of applied changes
+type ReloadFunc = func() ([]*entity.ConfigChange, error)
+
+// Constructs a new RPC server instance
+// services which have been registered by plugins are exposed alongside the builtin services
+func NewServer(plugin *plugin.Manager, services *plugin.Services, health *health.Checker, reload ReloadFunc) (*Server, error) {
   logger := logging.MustGetLogger("rpc")
 
-  return &Server{
+  s := &Server{
     logger: logger,
     plugin: plugin,
-    cache:  cache,
-  }, nil
-}
+    cache:  services.Cache,
+    health: health,
+    reload: reload,
 
-// Starts listening on an arbitrary socket
-func (s *Server) Listen(listener net.Listener) {
-  s.srv = grpc.NewServer()
-  grpc.NewServer()
-  rpc.RegisterEventServiceServer(s.srv, NewEventService(s.cache))
+    shutdown: make(chan struct{}),
+  }
+
+  unaryInterceptors := append([]grpc.UnaryServerInterceptor{tracing.UnaryServerInterceptor, logs.UnaryServerInterceptor, metrics.UnaryServerInterceptor}, plugin.Context.GetUnaryInterceptors()...)
+  streamInterceptors := append([]grpc.StreamServerInterceptor{tracing.StreamServerInterceptor, logs.StreamServerInterceptor, metrics.StreamServerInterceptor}, plugin.Context.GetStreamInterceptors()...)
+  s.srv = grpc.NewServer(
+    grpc.UnaryInterceptor(chainUnaryInterceptors(unaryInterceptors...)),
+    grpc.StreamInterceptor(chainStreamInterceptors(streamInterceptors...)),
+  )
+  rpc.RegisterEventServiceServer(s.srv, NewEventService(s.cache, s.shutdown))
   rpc.RegisterProfileServiceServer(s.srv, NewProfileService(s.cache))
   rpc.RegisterServerServiceServer(s.srv, NewServerService(s.cache))
-  rpc.RegisterSystemServiceServer(s.srv, NewSystemService(s.plugin))
+  rpc.RegisterSystemServiceServer(s.srv, NewSystemService(s.plugin, s.cache, s.reload))
+  healthpb.RegisterHealthServer(s.srv, s.health.GrpcServer())
+  for _, factory := range plugin.Context.GetGrpcServices() {
+    err := factory(s.srv, services)
+    if err != nil {
+      return nil, fmt.Errorf("failed to register plugin service: %s", err)
+    }
+  }
   reflection.Register(s.srv)
-  s.srv.Serve(listener)
+  return s, nil
 }
 
-// Stops listening
-func (s *Server) Stop() {
-  s.srv.Stop()
+// Starts listening on an arbitrary socket
+func (s *Server) Listen(listener net.Listener) error {
+  return s.srv.Serve(listener)
 }
 
-// destroys the server instance permanently
-func (s *Server) Destroy() {
-  if s.srv != nil {
+// Stops accepting new calls, ends all event streams and waits for in-flight calls to complete
+// if the passed context expires before all calls have completed, the remaining calls are aborted
+func (s *Server) Stop(ctx context.Context) error {
+  close(s.shutdown)
+
+  stopped := make(chan struct{})
+  go func() {
+    s.srv.GracefulStop()
+    close(stopped)
+  }()
+
+  select {
+  case <-stopped:
+    return nil
+  case <-ctx.Done():
+    s.logger.Warningf("in-flight calls did not complete in time - aborting remaining calls")
     s.srv.Stop()
-  }
-  if s.cache != nil {
-    s.cache.Close()
+    return ctx.Err()
   }
 }
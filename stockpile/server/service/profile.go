@@ -39,10 +39,15 @@ func NewProfileService(cache *cache.Cache) (*ProfileServiceImpl) {
   }
 }
 
-func (s *ProfileServiceImpl) GetId(_ context.Context, req *rpc.GetIdRequest) (*rpc.ProfileId, error) {
+func (s *ProfileServiceImpl) GetId(ctx context.Context, req *rpc.GetIdRequest) (*rpc.ProfileId, error) {
   at := time.Unix(req.Timestamp, 0)
-
-  profile, err := s.cache.GetProfileId(req.Name, at)
+  var profile *entity.ProfileId
+  var err error
+  if req.Offline {
+    profile, err = s.cache.GetOfflineProfileId(ctx, req.Name, at)
+  } else {
+    profile, err = s.cache.GetProfileId(ctx, req.Name, at)
+  }
   if err != nil {
     return nil, err
   }
@@ -52,12 +57,12 @@ func (s *ProfileServiceImpl) GetId(_ context.Context, req *rpc.GetIdRequest) (*r
   return rpc.ProfileIdToRpc(profile), nil
 }
 
-func (s *ProfileServiceImpl) BulkGetId(_ context.Context, req *rpc.BulkIdRequest) (*rpc.BulkIdResponse, error) {
+func (s *ProfileServiceImpl) BulkGetId(ctx context.Context, req *rpc.BulkIdRequest) (*rpc.BulkIdResponse, error) {
   if len(req.Names) > 100 {
     return nil, errors.New("cannot process more than 100 names at once")
   }
 
-  ids, err := s.cache.BulkGetProfileId(req.Names)
+  ids, err := s.cache.BulkGetProfileId(ctx, req.Names)
   if err != nil {
     return nil, err
   }
@@ -65,13 +70,13 @@ func (s *ProfileServiceImpl) BulkGetId(_ context.Context, req *rpc.BulkIdRequest
   return rpc.BulkIdsToRpc(ids), nil
 }
 
-func (s *ProfileServiceImpl) GetNameHistory(_ context.Context, req *rpc.IdRequest) (*rpc.NameHistory, error) {
+func (s *ProfileServiceImpl) GetNameHistory(ctx context.Context, req *rpc.IdRequest) (*rpc.NameHistory, error) {
   id, err := entity.ParseId(req.Id)
   if err != nil {
     return nil, err
   }
 
-  history, err := s.cache.GetNameHistory(id)
+  history, err := s.cache.GetNameHistory(ctx, id)
   if err != nil {
     return nil, err
   }
@@ -79,13 +84,22 @@ func (s *ProfileServiceImpl) GetNameHistory(_ context.Context, req *rpc.IdReques
   return rpc.NameHistoryToRpc(history), nil
 }
 
-func (s *ProfileServiceImpl) GetProfile(_ context.Context, req *rpc.IdRequest) (*rpc.Profile, error) {
+func (s *ProfileServiceImpl) GetNameTimeline(ctx context.Context, req *rpc.NameRequest) (*rpc.NameTimeline, error) {
+  timeline, err := s.cache.GetNameTimeline(ctx, req.Name)
+  if err != nil {
+    return nil, err
+  }
+
+  return rpc.NameTimelineToRpc(timeline), nil
+}
+
+func (s *ProfileServiceImpl) GetProfile(ctx context.Context, req *rpc.IdRequest) (*rpc.Profile, error) {
   id, err := entity.ParseId(req.Id)
   if err != nil {
     return nil, err
   }
 
-  profile, err := s.cache.GetProfile(id)
+  profile, err := s.cache.GetProfile(ctx, id)
   if err != nil {
     return nil, err
   }
@@ -36,8 +36,8 @@ func NewServerService(cache *cache.Cache) *ServerServiceImpl {
   }
 }
 
-func (s *ServerServiceImpl) GetBlacklist(context.Context, *empty.Empty) (*rpc.Blacklist, error) {
-  blacklist, err := s.cache.GetBlacklist()
+func (s *ServerServiceImpl) GetBlacklist(ctx context.Context, _ *empty.Empty) (*rpc.Blacklist, error) {
+  blacklist, err := s.cache.GetBlacklist(ctx)
   if err != nil {
     return nil, err
   }
@@ -45,8 +45,8 @@ func (s *ServerServiceImpl) GetBlacklist(context.Context, *empty.Empty) (*rpc.Bl
   return rpc.BlacklistToRpc(blacklist), nil
 }
 
-func (s *ServerServiceImpl) CheckBlacklist(_ context.Context, req *rpc.CheckBlacklistRequest) (*rpc.CheckBlacklistResponse, error) {
-  blacklist, err := s.cache.GetBlacklist()
+func (s *ServerServiceImpl) CheckBlacklist(ctx context.Context, req *rpc.CheckBlacklistRequest) (*rpc.CheckBlacklistResponse, error) {
+  blacklist, err := s.cache.GetBlacklist(ctx)
   if err != nil {
     return nil, err
   }
@@ -67,8 +67,8 @@ func (s *ServerServiceImpl) CheckBlacklist(_ context.Context, req *rpc.CheckBlac
   }, nil
 }
 
-func (s *ServerServiceImpl) Login(_ context.Context, req *rpc.LoginRequest) (*rpc.Profile, error) {
-  profile, err := s.cache.Login(req.DisplayName, req.ServerId, req.Ip)
+func (s *ServerServiceImpl) Login(ctx context.Context, req *rpc.LoginRequest) (*rpc.Profile, error) {
+  profile, err := s.cache.Login(ctx, req.DisplayName, req.ServerId, req.Ip)
   if err != nil {
     return nil, err
   }
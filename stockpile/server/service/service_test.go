@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package service_test
+
+import (
+  "io/ioutil"
+  "os"
+  "testing"
+
+  "github.com/dotStart/Stockpile/stockpile/logs"
+  "github.com/dotStart/Stockpile/stockpile/plugin"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/service/servicetest"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+)
+
+// exercises every RPC and event of an in-process server which is backed by a memory backend and
+// a mock upstream
+func TestServer(t *testing.T) {
+  dir, err := ioutil.TempDir("", "stockpile-plugins")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+
+  cfg, err := server.MergeConfigLayers(nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = logs.Setup(cfg.Logging)
+  if err != nil {
+    t.Fatal(err)
+  }
+  backend, err := storage.NewMemoryStorageBackend(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  harness, err := servicetest.NewHarness(cfg, plugin.NewManager(dir), backend)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer harness.Close()
+
+  harness.Test(t)
+}
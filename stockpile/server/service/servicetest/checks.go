@@ -0,0 +1,428 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package servicetest
+
+import (
+  "fmt"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/conformance"
+  "github.com/google/uuid"
+)
+
+// creates the list of conformance checks which exercise every RPC and event type
+func (h *Harness) Checks() []*conformance.Check {
+  return []*conformance.Check{
+    {Name: "get-id", Run: h.checkGetId},
+    {Name: "get-id-unknown", Run: h.checkGetIdUnknown},
+    {Name: "get-id-offline", Run: h.checkGetIdOffline},
+    {Name: "bulk-get-id", Run: h.checkBulkGetId},
+    {Name: "name-history", Run: h.checkNameHistory},
+    {Name: "get-id-historical", Run: h.checkGetIdHistorical},
+    {Name: "get-id-reassigned", Run: h.checkGetIdReassigned},
+    {Name: "name-timeline", Run: h.checkNameTimeline},
+    {Name: "profile", Run: h.checkProfile},
+    {Name: "profile-unknown", Run: h.checkProfileUnknown},
+    {Name: "blacklist", Run: h.checkBlacklist},
+    {Name: "login", Run: h.checkLogin},
+    {Name: "status", Run: h.checkStatus},
+    {Name: "plugins", Run: h.checkPlugins},
+    {Name: "log-levels", Run: h.checkLogLevels},
+    {Name: "reload", Run: h.checkReload},
+  }
+}
+
+// verifies that names are resolved through the upstream once and cached afterwards
+func (h *Harness) checkGetId() error {
+  name := h.fixture.currentName
+  for i, expected := range []int{1, 0} {
+    err := h.expectRequests("profile_id", expected, func() error {
+      profileId, err := h.Client.GetProfileId(name, time.Now())
+      if err != nil {
+        return err
+      }
+      if profileId == nil || profileId.Id != h.fixture.renamedId {
+        return fmt.Errorf("expected \"%s\" to resolve to profile %s but got %v", name, h.fixture.renamedId, profileId)
+      }
+      return nil
+    })
+    if err != nil {
+      return fmt.Errorf("query #%d: %s", i, err)
+    }
+  }
+
+  return h.expectEvent(entity.ProfileIdEvent, func(e *entity.Event) bool {
+    payload, err := e.ProfileIdPayload()
+    return err == nil && payload.Id == h.fixture.renamedId
+  })
+}
+
+// verifies that names are resolved in accordance with the time of the query
+func (h *Harness) checkGetIdHistorical() error {
+  at := h.fixture.changedAt.Add(-time.Hour * 24)
+  profileId, err := h.Client.GetProfileId(h.fixture.initialName, at)
+  if err != nil {
+    return err
+  }
+  if profileId == nil || profileId.Id != h.fixture.renamedId {
+    return fmt.Errorf("expected \"%s\" to resolve to profile %s at %s but got %v", h.fixture.initialName, h.fixture.renamedId, at, profileId)
+  }
+
+  profileId, err = h.Client.GetProfileId(h.fixture.initialName, time.Now())
+  if err != nil {
+    return err
+  }
+  if profileId != nil {
+    return fmt.Errorf("expected \"%s\" to be unassigned but got profile %s", h.fixture.initialName, profileId.Id)
+  }
+  return nil
+}
+
+// verifies that historical lookups of a reassigned name resolve to the respective owner and are
+// answered from the ownership timeline once the name history of the owner has been retrieved
+func (h *Harness) checkGetIdReassigned() error {
+  name := h.fixture.reassignedName
+  before := h.fixture.changedAt.Add(-time.Hour * 24)
+  after := h.fixture.claimedAt.Add(time.Hour * 24)
+
+  expectations := []struct {
+    at       time.Time
+    expected uuid.UUID
+    requests int
+  }{
+    {before, h.fixture.previousId, 1},
+    {time.Now(), h.fixture.claimantId, 1},
+    {after, h.fixture.claimantId, 1},
+    {before, h.fixture.previousId, 0},
+  }
+  for i, e := range expectations {
+    fn := func() error {
+      profileId, err := h.Client.GetProfileId(name, e.at)
+      if err != nil {
+        return err
+      }
+      if profileId == nil || profileId.Id != e.expected {
+        return fmt.Errorf("expected \"%s\" to resolve to profile %s at %s but got %v", name, e.expected, e.at, profileId)
+      }
+      return nil
+    }
+
+    var err error
+    if h.timelines {
+      err = h.expectRequests("profile_id", e.requests, fn)
+    } else {
+      err = fn()
+    }
+    if err != nil {
+      return fmt.Errorf("query #%d: %s", i, err)
+    }
+  }
+  return nil
+}
+
+// verifies that the ownership timeline of a name reflects the name histories of all its owners
+func (h *Harness) checkNameTimeline() error {
+  if !h.timelines {
+    return nil
+  }
+
+  // the timeline is only aware of previous owners once their history has been retrieved
+  _, err := h.Client.GetNameHistory(h.fixture.previousId)
+  if err != nil {
+    return err
+  }
+
+  timeline, err := h.Client.GetNameTimeline(h.fixture.reassignedName)
+  if err != nil {
+    return err
+  }
+  if len(timeline.Periods) != 2 {
+    return fmt.Errorf("expected 2 periods but got %d", len(timeline.Periods))
+  }
+
+  previous, claimant := timeline.Periods[0], timeline.Periods[1]
+  if previous.Id != h.fixture.previousId || previous.Until.Unix() != h.fixture.changedAt.Unix() {
+    return fmt.Errorf("expected profile %s to own the name until %s but got %+v", h.fixture.previousId, h.fixture.changedAt, *previous)
+  }
+  if claimant.Id != h.fixture.claimantId || claimant.From.Unix() != h.fixture.claimedAt.Unix() || !claimant.IsOpen() {
+    return fmt.Errorf("expected profile %s to own the name since %s but got %+v", h.fixture.claimantId, h.fixture.claimedAt, *claimant)
+  }
+  return nil
+}
+
+// verifies that unknown names are reported as such
+func (h *Harness) checkGetIdUnknown() error {
+  name := randomName()
+  profileId, err := h.Client.GetProfileId(name, time.Now())
+  if err != nil {
+    return err
+  }
+  if profileId != nil {
+    return fmt.Errorf("expected \"%s\" to be unassigned but got profile %s", name, profileId.Id)
+  }
+  return nil
+}
+
+// verifies that offline ids are generated locally while their profiles and name histories remain
+// available through the cache
+func (h *Harness) checkGetIdOffline() error {
+  name := randomName()
+  id := entity.OfflineId(name)
+  err := h.expectRequests("profile_id", 0, func() error {
+    profileId, err := h.Client.GetOfflineProfileId(name, time.Now())
+    if err != nil {
+      return err
+    }
+    if profileId == nil || profileId.Id != id {
+      return fmt.Errorf("expected \"%s\" to resolve to offline profile %s but got %v", name, id, profileId)
+    }
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+
+  err = h.expectRequests("profile", 0, func() error {
+    profile, err := h.Client.GetProfile(id)
+    if err != nil {
+      return err
+    }
+    if profile == nil || profile.Name != name {
+      return fmt.Errorf("expected offline profile %s to be known as \"%s\" but got %v", id, name, profile)
+    }
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+
+  return h.expectRequests("name_history", 0, func() error {
+    history, err := h.Client.GetNameHistory(id)
+    if err != nil {
+      return err
+    }
+    if history == nil || len(history.History) != 1 || history.History[0].Name != name {
+      return fmt.Errorf("expected name history of offline profile %s to consist of \"%s\" but got %v", id, name, history)
+    }
+    return nil
+  })
+}
+
+// verifies that multiple names are resolved at once while unknown names are omitted
+func (h *Harness) checkBulkGetId() error {
+  ids, err := h.Client.BulkGetProfileId([]string{h.fixture.currentName, h.fixture.texturedName, randomName()})
+  if err != nil {
+    return err
+  }
+
+  expected := map[uuid.UUID]bool{
+    h.fixture.renamedId:  true,
+    h.fixture.texturedId: true,
+  }
+  if len(ids) != len(expected) {
+    return fmt.Errorf("expected %d profile(s) but got %d", len(expected), len(ids))
+  }
+  for _, id := range ids {
+    if !expected[id.Id] {
+      return fmt.Errorf("unexpected profile %s (\"%s\")", id.Id, id.Name)
+    }
+  }
+  return nil
+}
+
+// verifies that name histories are resolved through the upstream once and cached afterwards
+func (h *Harness) checkNameHistory() error {
+  for i, expected := range []int{1, 0} {
+    err := h.expectRequests("name_history", expected, func() error {
+      history, err := h.Client.GetNameHistory(h.fixture.renamedId)
+      if err != nil {
+        return err
+      }
+      if history == nil || len(history.History) != 2 {
+        return fmt.Errorf("expected name history with 2 entries but got %v", history)
+      }
+      if history.History[0].Name != h.fixture.initialName || history.History[1].Name != h.fixture.currentName {
+        return fmt.Errorf("expected names \"%s\" and \"%s\" but got \"%s\" and \"%s\"", h.fixture.initialName, h.fixture.currentName, history.History[0].Name, history.History[1].Name)
+      }
+      if history.History[1].ChangedToAt.Unix() != h.fixture.changedAt.Unix() {
+        return fmt.Errorf("expected name change at %s but got %s", h.fixture.changedAt, history.History[1].ChangedToAt)
+      }
+      return nil
+    })
+    if err != nil {
+      return fmt.Errorf("query #%d: %s", i, err)
+    }
+  }
+
+  return h.expectEvent(entity.NameHistoryEvent, func(e *entity.Event) bool {
+    key, err := e.IdKey()
+    return err == nil && *key == h.fixture.renamedId
+  })
+}
+
+// verifies that profiles (including their textures) are resolved through the upstream once and
+// cached afterwards
+func (h *Harness) checkProfile() error {
+  for i, expected := range []int{1, 0} {
+    err := h.expectRequests("profile", expected, func() error {
+      profile, err := h.Client.GetProfile(h.fixture.texturedId)
+      if err != nil {
+        return err
+      }
+      if profile == nil || profile.Name != h.fixture.texturedName {
+        return fmt.Errorf("expected profile \"%s\" but got %v", h.fixture.texturedName, profile)
+      }
+      if profile.Textures == nil || profile.Textures.Textures["SKIN"] != h.fixture.skinUrl {
+        return fmt.Errorf("expected skin %s but got %v", h.fixture.skinUrl, profile.Textures)
+      }
+      return nil
+    })
+    if err != nil {
+      return fmt.Errorf("query #%d: %s", i, err)
+    }
+  }
+
+  return h.expectEvent(entity.ProfileEvent, func(e *entity.Event) bool {
+    payload, err := e.ProfilePayload()
+    return err == nil && payload.Id == h.fixture.texturedId
+  })
+}
+
+// verifies that unknown profiles are reported as such
+func (h *Harness) checkProfileUnknown() error {
+  id := uuid.New()
+  profile, err := h.Client.GetProfile(id)
+  if err != nil {
+    return err
+  }
+  if profile != nil {
+    return fmt.Errorf("expected no data for unknown profile %s", id)
+  }
+  return nil
+}
+
+// verifies that the blacklist is retrieved and matched against server addresses
+func (h *Harness) checkBlacklist() error {
+  blacklist, err := h.Client.GetBlacklist()
+  if err != nil {
+    return err
+  }
+  if blacklist == nil || len(blacklist.Hashes) != 1 {
+    return fmt.Errorf("expected blacklist with a single entry but got %v", blacklist)
+  }
+  err = h.expectEvent(entity.BlacklistEvent, func(e *entity.Event) bool {
+    return true
+  })
+  if err != nil {
+    return err
+  }
+
+  return h.expectRequests("blacklist", 0, func() error {
+    matched, err := h.Client.CheckBlacklist([]string{h.fixture.blockedAddress, "mc.example.org"})
+    if err != nil {
+      return err
+    }
+    if len(matched) != 1 || matched[0] != h.fixture.blockedAddress {
+      return fmt.Errorf("expected only \"%s\" to match but got %v", h.fixture.blockedAddress, matched)
+    }
+    return nil
+  })
+}
+
+// verifies that logins are forwarded to the upstream and update the cache
+func (h *Harness) checkLogin() error {
+  err := h.expectRequests("login", 1, func() error {
+    profile, err := h.Client.Login(h.fixture.texturedName, "stockpile", "")
+    if err != nil {
+      return err
+    }
+    if profile == nil || profile.Id != h.fixture.texturedId {
+      return fmt.Errorf("expected login of profile %s but got %v", h.fixture.texturedId, profile)
+    }
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+
+  return h.expectEvent(entity.ProfileEvent, func(e *entity.Event) bool {
+    payload, err := e.ProfilePayload()
+    return err == nil && payload.Id == h.fixture.texturedId
+  })
+}
+
+// verifies that the server reports its status
+func (h *Harness) checkStatus() error {
+  status, err := h.Client.GetStatus()
+  if err != nil {
+    return err
+  }
+  if !status.StorageAvailable {
+    return fmt.Errorf("expected storage to be available but got: %s", status.StorageError)
+  }
+  return nil
+}
+
+// verifies that the server reports its plugins
+func (h *Harness) checkPlugins() error {
+  _, err := h.Client.GetPluginList()
+  return err
+}
+
+// verifies that log levels are reported and updated at runtime
+func (h *Harness) checkLogLevels() error {
+  levels, err := h.Client.GetLogLevels()
+  if err != nil {
+    return err
+  }
+  original, ok := levels["rpc"]
+  if !ok {
+    return fmt.Errorf("expected level of module \"rpc\" to be reported")
+  }
+
+  level := "ERROR"
+  if original == level {
+    level = "WARNING"
+  }
+  levels, err = h.Client.SetLogLevel("rpc", level)
+  if err != nil {
+    return err
+  }
+  if levels["rpc"] != level {
+    return fmt.Errorf("expected level of module \"rpc\" to be %s but got %s", level, levels["rpc"])
+  }
+
+  _, err = h.Client.SetLogLevel("rpc", original)
+  return err
+}
+
+// verifies that configuration reloads are reported to the caller and to event listeners
+func (h *Harness) checkReload() error {
+  changes, err := h.Client.ReloadConfig()
+  if err != nil {
+    return err
+  }
+  if len(changes) != 1 || changes[0].Setting != "harness" {
+    return fmt.Errorf("expected a single change to \"harness\" but got %v", changes)
+  }
+
+  return h.expectEvent(entity.ConfigEvent, func(e *entity.Event) bool {
+    payload, err := e.ConfigPayload()
+    return err == nil && len(payload.Changes) == 1 && payload.Changes[0].Setting == "harness"
+  })
+}
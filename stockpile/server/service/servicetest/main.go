@@ -0,0 +1,377 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package servicetest
+
+import (
+  "context"
+  "crypto/sha1"
+  "encoding/base64"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "net"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/client"
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/conformance"
+  "github.com/dotStart/Stockpile/stockpile/health"
+  "github.com/dotStart/Stockpile/stockpile/mojang"
+  "github.com/dotStart/Stockpile/stockpile/mojang/mock"
+  "github.com/dotStart/Stockpile/stockpile/plugin"
+  "github.com/dotStart/Stockpile/stockpile/ratelimit"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/service"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/google/uuid"
+)
+
+// defines the maximum amount of time to wait for an expected event
+const eventTimeout = 5 * time.Second
+
+// defines the amount of events which are buffered until they are consumed by a check
+const eventBufferSize = 256
+
+// defines the maximum amount of time to wait for in-flight calls when the harness is closed
+const shutdownTimeout = 5 * time.Second
+
+// provides an in-process server which is backed by a mock upstream
+//
+// the harness generates a random set of profiles and a blacklist entry upon construction and
+// may thus be used with storage backends which already contain data
+type Harness struct {
+  Upstream *mock.Server
+  Cache    *cache.Cache
+  Client   *client.Stockpile
+  Address  string
+
+  fixture     *fixture
+  upstreamSrv *httptest.Server
+  rpc         *service.Server
+  events      chan *entity.Event
+  done        chan struct{}
+  timelines   bool
+}
+
+// describes the data which is served by the mock upstream
+type fixture struct {
+  // profile which has changed its name once
+  renamedId   uuid.UUID
+  initialName string
+  currentName string
+  changedAt   time.Time
+
+  // name which has been released by one profile and claimed by another profile afterwards
+  reassignedName string
+  previousId     uuid.UUID
+  claimantId     uuid.UUID
+  claimedAt      time.Time
+
+  // profile which carries textures
+  texturedId   uuid.UUID
+  texturedName string
+  skinUrl      string
+
+  // blacklisted server address
+  blockedAddress string
+}
+
+// creates a new harness which stores its data within a given backend
+// interceptors and services of the passed plugins are registered with the server while the
+// harness takes ownership of the backend and will close it along with the server
+func NewHarness(cfg *server.Config, plugins *plugin.Manager, backend storage.StorageBackend) (*Harness, error) {
+  f := newFixture()
+  mockFixture, err := f.mock()
+  if err != nil {
+    backend.Close()
+    return nil, fmt.Errorf("illegal fixture: %s", err)
+  }
+
+  // the blacklist is shared by all instances and may thus remain from a previous run
+  err = backend.PurgeBlacklist()
+  if err != nil {
+    backend.Close()
+    return nil, fmt.Errorf("failed to purge blacklist: %s", err)
+  }
+
+  h := &Harness{
+    Upstream: mock.New(mockFixture, mock.Options{}),
+    fixture:  f,
+    events:   make(chan *entity.Event, eventBufferSize),
+    done:     make(chan struct{}),
+  }
+  h.upstreamSrv = httptest.NewServer(h.Upstream)
+
+  // timelines are optional thus checks which rely on them are relaxed for backends without support
+  if timelines, ok := backend.(storage.TimelineStorageBackend); ok {
+    _, err = timelines.GetNameTimeline(f.reassignedName)
+    h.timelines = err != storage.ErrTimelineUnsupported
+  }
+
+  api := mojang.New()
+  err = api.SetServers(h.upstreamSrv.URL, h.upstreamSrv.URL)
+  if err != nil {
+    h.upstreamSrv.Close()
+    backend.Close()
+    return nil, err
+  }
+  rateLimit, err := ratelimit.NewLocalStore(cfg)
+  if err != nil {
+    h.upstreamSrv.Close()
+    backend.Close()
+    return nil, fmt.Errorf("failed to initialize rate limit store: %s", err)
+  }
+  h.Cache = cache.New(api, backend, rateLimit)
+
+  services := &plugin.Services{
+    Config: cfg,
+    Cache:  h.Cache,
+  }
+  h.rpc, err = service.NewServer(plugins, services, health.NewChecker(), h.reload)
+  if err != nil {
+    h.Cache.Close()
+    h.upstreamSrv.Close()
+    return nil, fmt.Errorf("failed to initialize grpc server: %s", err)
+  }
+
+  listener, err := net.Listen("tcp", "127.0.0.1:0")
+  if err != nil {
+    h.Cache.Close()
+    h.upstreamSrv.Close()
+    return nil, err
+  }
+  go h.rpc.Listen(listener)
+  h.Address = listener.Addr().String()
+
+  h.Client, err = client.New(h.Address)
+  if err != nil {
+    h.Close()
+    return nil, err
+  }
+  err = h.subscribe()
+  if err != nil {
+    h.Close()
+    return nil, fmt.Errorf("failed to subscribe to events: %s", err)
+  }
+  return h, nil
+}
+
+// generates a random set of profiles
+func newFixture() *fixture {
+  return &fixture{
+    renamedId:   uuid.New(),
+    initialName: randomName(),
+    currentName: randomName(),
+    changedAt:   time.Now().Add(-time.Hour * 24 * 60).Truncate(time.Second),
+
+    reassignedName: randomName(),
+    previousId:     uuid.New(),
+    claimantId:     uuid.New(),
+    claimedAt:      time.Now().Add(-time.Hour * 24 * 10).Truncate(time.Second),
+
+    texturedId:   uuid.New(),
+    texturedName: randomName(),
+    skinUrl:      "http://textures.minecraft.net/texture/" + strings.Replace(uuid.New().String(), "-", "", -1),
+
+    blockedAddress: strings.Replace(uuid.New().String(), "-", "", -1)[:12] + ".example.org",
+  }
+}
+
+// generates a random (but valid) player name
+func randomName() string {
+  return "sp" + strings.Replace(uuid.New().String(), "-", "", -1)[:12]
+}
+
+// converts the fixture into its mock upstream representation
+func (f *fixture) mock() (*mock.Fixture, error) {
+  textures, err := json.Marshal(map[string]interface{}{
+    "timestamp":   time.Now().UnixNano() / int64(time.Millisecond),
+    "profileId":   entity.ToMojangId(f.texturedId),
+    "profileName": f.texturedName,
+    "textures": map[string]interface{}{
+      "SKIN": map[string]string{"url": f.skinUrl},
+    },
+  })
+  if err != nil {
+    return nil, err
+  }
+  hash := sha1.Sum([]byte(f.blockedAddress))
+
+  fixture := &mock.Fixture{
+    Profiles: []*mock.FixtureProfile{
+      {
+        Id: entity.ToMojangId(f.renamedId),
+        Names: []*mock.FixtureName{
+          {Name: f.initialName},
+          {Name: f.currentName, ChangedToAt: f.changedAt.UnixNano() / int64(time.Millisecond)},
+        },
+      },
+      {
+        Id: entity.ToMojangId(f.previousId),
+        Names: []*mock.FixtureName{
+          {Name: f.reassignedName},
+          {Name: randomName(), ChangedToAt: f.changedAt.UnixNano() / int64(time.Millisecond)},
+        },
+      },
+      {
+        Id: entity.ToMojangId(f.claimantId),
+        Names: []*mock.FixtureName{
+          {Name: randomName()},
+          {Name: f.reassignedName, ChangedToAt: f.claimedAt.UnixNano() / int64(time.Millisecond)},
+        },
+      },
+      {
+        Id:    entity.ToMojangId(f.texturedId),
+        Names: []*mock.FixtureName{{Name: f.texturedName}},
+        Properties: []*entity.ProfileProperty{
+          {
+            Name:      "textures",
+            Value:     base64.StdEncoding.EncodeToString(textures),
+            Signature: base64.StdEncoding.EncodeToString([]byte("signature")),
+          },
+        },
+      },
+    },
+    Blacklist: []string{hex.EncodeToString(hash[:])},
+  }
+  return fixture, fixture.Parse()
+}
+
+// reloads the server configuration on behalf of the system service
+// the harness reports a fixed change in order to permit verification of config events
+func (h *Harness) reload() ([]*entity.ConfigChange, error) {
+  changes := []*entity.ConfigChange{
+    {
+      Setting: "harness",
+      Old:     "old",
+      New:     "new",
+    },
+  }
+  h.Cache.PublishConfigChange(changes)
+  return changes, nil
+}
+
+// opens an event stream and waits for the server to begin forwarding events
+func (h *Harness) subscribe() error {
+  stream, err := h.Client.EventChannel(nil)
+  if err != nil {
+    return err
+  }
+  go func() {
+    for {
+      select {
+      case e := <-stream:
+        select {
+        case h.events <- e:
+        default:
+        }
+      case <-h.done:
+        return
+      }
+    }
+  }()
+
+  // the stream is registered with the cache asynchronously - we'll publish marker events until
+  // the first one arrives
+  deadline := time.Now().Add(eventTimeout)
+  for time.Now().Before(deadline) {
+    h.Cache.PublishConfigChange(nil)
+    select {
+    case <-h.events:
+      h.drainEvents()
+      return nil
+    case <-time.After(100 * time.Millisecond):
+    }
+  }
+  return fmt.Errorf("timed out while waiting for event stream")
+}
+
+// discards all events which have been received so far
+func (h *Harness) drainEvents() {
+  for {
+    select {
+    case <-h.events:
+    default:
+      return
+    }
+  }
+}
+
+// waits for an event of a given type which satisfies the passed predicate
+// events which do not match are discarded
+func (h *Harness) expectEvent(eventType entity.EventType, match func(e *entity.Event) bool) error {
+  timeout := time.After(eventTimeout)
+  for {
+    select {
+    case e := <-h.events:
+      if e.Type == eventType && match(e) {
+        return nil
+      }
+    case <-timeout:
+      return fmt.Errorf("timed out while waiting for event of type %d", eventType)
+    }
+  }
+}
+
+// invokes a function and verifies that it submits the expected amount of requests to a given
+// upstream endpoint
+func (h *Harness) expectRequests(endpoint string, expected int, fn func() error) error {
+  before := h.Upstream.RequestCount(endpoint)
+  err := fn()
+  if err != nil {
+    return err
+  }
+
+  actual := h.Upstream.RequestCount(endpoint) - before
+  if actual != expected {
+    return fmt.Errorf("expected %d upstream request(s) to %s but got %d", expected, endpoint, actual)
+  }
+  return nil
+}
+
+// executes all conformance checks against this harness
+func (h *Harness) Run() []*conformance.Result {
+  return conformance.Run(h.Checks())
+}
+
+// executes all conformance checks against this harness as subtests of a go test
+func (h *Harness) Test(t *testing.T) {
+  conformance.Test(t, h.Checks())
+}
+
+// shuts down the server, its cache (including the storage backend) and the mock upstream
+func (h *Harness) Close() error {
+  close(h.done)
+
+  // the server is stopped first in order to end the event stream gracefully
+  ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+  defer cancel()
+  err := h.rpc.Stop(ctx)
+  if h.Client != nil {
+    h.Client.Close()
+  }
+  closeErr := h.Cache.Close()
+  if err == nil {
+    err = closeErr
+  }
+  h.upstreamSrv.Close()
+  return err
+}
@@ -17,6 +17,9 @@
 package service
 
 import (
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/cache"
+  "github.com/dotStart/Stockpile/stockpile/logs"
   "github.com/dotStart/Stockpile/stockpile/metadata"
   "github.com/dotStart/Stockpile/stockpile/plugin"
   "github.com/dotStart/Stockpile/rpc"
@@ -28,29 +31,66 @@ import (
 type SystemServiceImpl struct {
   logger *logging.Logger
   plugin *plugin.Manager
+  cache  *cache.Cache
+  reload ReloadFunc
 }
 
-func NewSystemService(plugin *plugin.Manager) (*SystemServiceImpl) {
+func NewSystemService(plugin *plugin.Manager, cache *cache.Cache, reload ReloadFunc) (*SystemServiceImpl) {
   return &SystemServiceImpl{
     logger: logging.MustGetLogger("system-srv"),
     plugin: plugin,
+    cache:  cache,
+    reload: reload,
   }
 }
 
-func (s *SystemServiceImpl) GetStatus(context.Context, *empty.Empty) (*rpc.Status, error) {
+func (s *SystemServiceImpl) GetStatus(ctx context.Context, _ *empty.Empty) (*rpc.Status, error) {
+  storageErr := ""
+  err := s.cache.PingStorage()
+  if err != nil {
+    logs.ForContext(ctx, s.logger).Warningf("storage backend is unavailable: %s", err)
+    storageErr = err.Error()
+  }
+
   return &rpc.Status{
-    Brand:          metadata.Brand(),
-    Version:        metadata.Version(),
-    VersionFull:    metadata.VersionFull(),
-    CommitHash:     metadata.CommitHash(),
-    BuildTimestamp: metadata.Timestamp().Unix(),
+    Brand:            metadata.Brand(),
+    Version:          metadata.Version(),
+    VersionFull:      metadata.VersionFull(),
+    CommitHash:       metadata.CommitHash(),
+    BuildTimestamp:   metadata.Timestamp().Unix(),
+    Storage:          rpc.StorageStatisticsToRpc(s.cache.GetStorageStatistics()),
+    StorageAvailable: err == nil,
+    StorageError:     storageErr,
   }, nil
 }
 
 func (s *SystemServiceImpl) GetPlugins(context.Context, *empty.Empty) (*rpc.PluginList, error) {
-  list := make([]*plugin.Metadata, len(s.plugin.Plugins))
-  for i, p := range s.plugin.Plugins {
-    list[i] = &p.Metadata
+  return rpc.PluginStatusListToRpc(s.plugin.Status()), nil
+}
+
+func (s *SystemServiceImpl) GetLogLevels(context.Context, *empty.Empty) (*rpc.LogLevelList, error) {
+  return rpc.LogLevelsToRpc(logs.GetLevels()), nil
+}
+
+func (s *SystemServiceImpl) SetLogLevel(ctx context.Context, req *rpc.LogLevel) (*rpc.LogLevelList, error) {
+  err := logs.SetLevel(req.Module, req.Level)
+  if err != nil {
+    return nil, err
+  }
+
+  module := req.Module
+  if module == "" {
+    module = "(default)"
+  }
+  logs.ForContext(ctx, s.logger).Noticef("log level of module %s changed to %s", module, req.Level)
+  return rpc.LogLevelsToRpc(logs.GetLevels()), nil
+}
+
+func (s *SystemServiceImpl) ReloadConfig(ctx context.Context, _ *empty.Empty) (*rpc.ConfigChangeSet, error) {
+  logs.ForContext(ctx, s.logger).Noticef("configuration reload requested")
+  changes, err := s.reload()
+  if err != nil {
+    return nil, err
   }
-  return rpc.PluginMetadataListToRpc(list), nil
+  return rpc.ConfigChangeSetToRpc(&entity.ConfigChangeSet{Changes: changes}), nil
 }
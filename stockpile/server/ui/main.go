@@ -18,6 +18,7 @@ package ui
 
 import (
   "net/http"
+  "sync"
   "time"
 
   "github.com/dotStart/Stockpile/stockpile/cache"
@@ -37,7 +38,9 @@ type Server struct {
   listener *cache.Listener
 
   rateLimitTicker *time.Ticker
+  shutdown        chan struct{}
 
+  corsMutex    sync.RWMutex
   corsOverride string
 }
 
@@ -55,6 +58,7 @@ func NewServer(httpSrv *http.ServeMux, corsOverride string, plugin *plugin.Manag
     listener: cacheImpl.NewListener(),
 
     rateLimitTicker: time.NewTicker(time.Minute),
+    shutdown:        make(chan struct{}),
 
     corsOverride: corsOverride,
   }
@@ -82,17 +86,38 @@ func (s *Server) handleRootRequest(w http.ResponseWriter, req *http.Request) {
 }
 
 func (s *Server) handleSocket(w http.ResponseWriter, req *http.Request) {
-  if s.corsOverride != "" {
-    w.Header().Set("Access-Control-Allow-Origin", s.corsOverride)
+  corsOverride := s.getCorsOverride()
+  if corsOverride != "" {
+    w.Header().Set("Access-Control-Allow-Origin", corsOverride)
     w.Header().Set("Access-Control-Allow-Credentials", "true")
   }
   s.io.ServeHTTP(w, req)
 }
 
+// retrieves the origin from which CORS requests are currently permitted (if any)
+func (s *Server) getCorsOverride() string {
+  s.corsMutex.RLock()
+  defer s.corsMutex.RUnlock()
+  return s.corsOverride
+}
+
+// replaces the origin from which CORS requests are permitted (an empty string disables CORS
+// entirely)
+func (s *Server) SetCorsOverride(corsOverride string) {
+  s.corsMutex.Lock()
+  defer s.corsMutex.Unlock()
+  s.corsOverride = corsOverride
+}
+
 // forwards the current rate limit to connected clients
 func (s *Server) forwardRateLimit() {
-  for range s.rateLimitTicker.C {
-    s.io.BroadcastTo("ui", "rate-limit", s.cache.GetRateLimitAllocation())
+  for {
+    select {
+    case <-s.rateLimitTicker.C:
+      s.io.BroadcastTo("ui", "rate-limit", s.cache.GetRateLimitAllocation())
+    case <-s.shutdown:
+      return
+    }
   }
 }
 
@@ -124,10 +149,12 @@ func (s *Server) onSocketConnect(io socketio.Socket) {
       Version          string             `json:"version"`
       PluginsSupported bool               `json:"pluginsSupported"`
       Plugins          []*plugin.Metadata `json:"plugins"`
+      RateLimit        uint64             `json:"rateLimit"`
     }{
       Version:          metadata.VersionFull(),
       PluginsSupported: plugin.PluginsAvailable,
       Plugins:          pluginList,
+      RateLimit:        s.cache.GetRateLimitCapacity(),
     },
   )
   io.Emit("rate-limit", s.cache.GetRateLimitAllocation())
@@ -136,3 +163,11 @@ func (s *Server) onSocketConnect(io socketio.Socket) {
 func (s *Server) onSocketDisconnect(io socketio.Socket) {
   s.logger.Debugf("client %s (id %s) has disconnected", io.Request().RemoteAddr, io.Id())
 }
+
+// stops forwarding rate limits and cache events to connected clients
+func (s *Server) Close() error {
+  s.rateLimitTicker.Stop()
+  close(s.shutdown)
+  s.listener.Close()
+  return nil
+}
@@ -0,0 +1,425 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package storage
+
+import (
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "os"
+  "time"
+
+  bolt "github.com/coreos/bbolt"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/hashicorp/hcl2/gohcl"
+  "github.com/op/go-logging"
+)
+
+// defines the length of the timestamp header which precedes every value within the database
+const boltHeaderLength = 8
+
+// defines the version of the on-disk format which is written by this release
+// databases which use a different format are refused rather than misinterpreted
+const boltFormatVersion = 1
+
+// identifies the bucket which holds the database metadata (such as its format version) along with
+// the key of the format version within it
+var (
+  boltMetaBucket = []byte("meta")
+  boltVersionKey = []byte("version")
+)
+
+// defines the amount of time to wait for another instance to release the database lock
+const defaultBoltLockTimeout = time.Second * 5
+
+// defines the interval in which expired entries are removed from the database when no interval is
+// configured
+const defaultBoltSweepInterval = time.Minute * 10
+
+const boltFilePerms = 0660 // rw-rw----
+
+// provides a storage backend which stores all cache entries within a single transactional
+// database file (one bucket per category)
+//
+// every value is prefixed with the time at which it has been written (in nanoseconds since UNIX
+// epoch) and expires once the TTL of its category has elapsed since then - thus TTL changes apply
+// to previously written values as well
+type boltStorageBackendInterface struct {
+  logger      *logging.Logger
+  cfg         *server.Config
+  lock        *bolt.DB
+  db          *bolt.DB
+  sweepTicker *time.Ticker
+  done        chan struct{}
+}
+
+type BoltStorageBackendCfg struct {
+  Path           string  `hcl:"path,attr"`
+  LockTimeout    *string `hcl:"lock-timeout,attr"`
+  SweepInterval  *string `hcl:"sweep-interval,attr"`
+  CompactOnStart *bool   `hcl:"compact-on-start,attr"`
+}
+
+// creates a new bolt storage backend
+func NewBoltStorageBackend(cfg *server.Config) (StorageBackend, error) {
+  boltCfg := &BoltStorageBackendCfg{}
+  diag := gohcl.DecodeBody(cfg.Storage.Parameters, server.EvalContext(), boltCfg)
+  if diag.HasErrors() {
+    return nil, fmt.Errorf("illegal backend configuration: %s", diag.Error())
+  }
+  if boltCfg.Path == "" {
+    return nil, fmt.Errorf("illegal backend configuration: path is required")
+  }
+
+  lockTimeout := defaultBoltLockTimeout
+  if boltCfg.LockTimeout != nil {
+    timeout, err := time.ParseDuration(*boltCfg.LockTimeout)
+    if err != nil {
+      return nil, fmt.Errorf("illegal backend configuration: illegal lock timeout: %s", err)
+    }
+    lockTimeout = timeout
+  }
+  sweepInterval := defaultBoltSweepInterval
+  if boltCfg.SweepInterval != nil {
+    interval, err := time.ParseDuration(*boltCfg.SweepInterval)
+    if err != nil {
+      return nil, fmt.Errorf("illegal backend configuration: illegal sweep interval: %s", err)
+    }
+    if interval <= 0 {
+      return nil, fmt.Errorf("illegal backend configuration: sweep interval must be positive")
+    }
+    sweepInterval = interval
+  }
+
+  options := &bolt.Options{
+    Timeout: lockTimeout,
+  }
+
+  lock, err := lockBoltDatabase(boltCfg.Path, options)
+  if err != nil {
+    return nil, err
+  }
+  db, err := openBoltDatabase(boltCfg.Path, options)
+  if err != nil {
+    lock.Close()
+    return nil, err
+  }
+
+  impl := &boltStorageBackendInterface{
+    logger: logging.MustGetLogger("bolt"),
+    cfg:    cfg,
+    lock:   lock,
+    db:     db,
+    done:   make(chan struct{}),
+  }
+  err = impl.checkFormat()
+  if err != nil {
+    db.Close()
+    lock.Close()
+    return nil, err
+  }
+  if boltCfg.CompactOnStart != nil && *boltCfg.CompactOnStart {
+    err = impl.compact(options)
+    if err != nil {
+      lock.Close()
+      return nil, err
+    }
+  }
+
+  impl.sweepTicker = time.NewTicker(sweepInterval)
+  go impl.sweep()
+  return NewEncodedStorageBackend(cfg, impl), nil
+}
+
+// acquires the lock file which accompanies a given database file
+//
+// the database file itself is locked as well but its lock is released briefly while the file is
+// replaced by its compacted version - the lock file is held for the entire lifetime of the backend
+// instead and thus keeps other instances from opening the database in the meantime (bolt is used
+// for the lock file as well in order to rely on its platform specific locking)
+func lockBoltDatabase(path string, options *bolt.Options) (*bolt.DB, error) {
+  lock, err := bolt.Open(path+".lock", boltFilePerms, options)
+  if err == bolt.ErrTimeout {
+    return nil, fmt.Errorf("database \"%s\" is locked by another instance", path)
+  }
+  if err != nil {
+    return nil, fmt.Errorf("failed to lock database \"%s\": %s", path, err)
+  }
+  return lock, nil
+}
+
+// opens (or creates) a database file and acquires its lock
+func openBoltDatabase(path string, options *bolt.Options) (*bolt.DB, error) {
+  db, err := bolt.Open(path, boltFilePerms, options)
+  if err == bolt.ErrTimeout {
+    return nil, fmt.Errorf("database \"%s\" is locked by another instance", path)
+  }
+  if err != nil {
+    return nil, fmt.Errorf("failed to open database \"%s\": %s", path, err)
+  }
+  return db, nil
+}
+
+// verifies that the database uses a known format version and records the format version of newly
+// created databases
+func (b *boltStorageBackendInterface) checkFormat() error {
+  return b.db.Update(func(tx *bolt.Tx) error {
+    meta := tx.Bucket(boltMetaBucket)
+    if meta == nil {
+      var err error
+      meta, err = tx.CreateBucket(boltMetaBucket)
+      if err != nil {
+        return err
+      }
+
+      enc := make([]byte, 8)
+      binary.BigEndian.PutUint64(enc, boltFormatVersion)
+      return meta.Put(boltVersionKey, enc)
+    }
+
+    enc := meta.Get(boltVersionKey)
+    if len(enc) != 8 {
+      return fmt.Errorf("database \"%s\" carries a malformed format version", b.db.Path())
+    }
+    if version := binary.BigEndian.Uint64(enc); version != boltFormatVersion {
+      return fmt.Errorf("database \"%s\" uses format version %d but this release only supports version %d", b.db.Path(), version, boltFormatVersion)
+    }
+    return nil
+  })
+}
+
+// rewrites the database into a new file in order to reclaim the space occupied by previously
+// removed and expired entries (the database is closed when compaction fails)
+//
+// callers are expected to hold the database lock file as the database lock is briefly released
+// while the file is replaced
+func (b *boltStorageBackendInterface) compact(options *bolt.Options) error {
+  db := b.db
+  path := db.Path()
+  tmpPath := path + ".compact"
+
+  // left overs from a previously interrupted compaction are simply discarded as the original
+  // database is only ever replaced once the copy has been completed
+  os.Remove(tmpPath)
+
+  dst, err := bolt.Open(tmpPath, boltFilePerms, options)
+  if err != nil {
+    db.Close()
+    return fmt.Errorf("failed to create compacted database: %s", err)
+  }
+
+  start := time.Now()
+  err = db.View(func(srcTx *bolt.Tx) error {
+    return dst.Update(func(dstTx *bolt.Tx) error {
+      return srcTx.ForEach(func(name []byte, src *bolt.Bucket) error {
+        bucket, err := dstTx.CreateBucket(name)
+        if err != nil {
+          return err
+        }
+        bucket.FillPercent = 1 // values are copied in order and will thus never be split
+
+        // metadata does not carry a header and is thus copied as-is
+        meta := bytes.Equal(name, boltMetaBucket)
+        ttl := b.categoryTtl(string(name))
+        now := time.Now().UnixNano()
+        return src.ForEach(func(key []byte, value []byte) error {
+          if !meta && isBoltValueExpired(value, ttl, now) {
+            return nil
+          }
+          return bucket.Put(key, value)
+        })
+      })
+    })
+  })
+  dst.Close()
+  if err != nil {
+    os.Remove(tmpPath)
+    db.Close()
+    return fmt.Errorf("failed to compact database: %s", err)
+  }
+
+  srcSize := fileSize(path)
+  db.Close()
+  err = os.Rename(tmpPath, path)
+  if err != nil {
+    os.Remove(tmpPath)
+    return fmt.Errorf("failed to replace database with its compacted version: %s", err)
+  }
+  b.logger.Infof("compacted database from %d to %d bytes in %s", srcSize, fileSize(path), time.Since(start))
+
+  b.db, err = openBoltDatabase(path, options)
+  return err
+}
+
+// retrieves the size of a given file (or zero if it cannot be accessed)
+func fileSize(path string) int64 {
+  stat, err := os.Stat(path)
+  if err != nil {
+    return 0
+  }
+  return stat.Size()
+}
+
+// retrieves the TTL which applies to the entries within a given category
+func (b *boltStorageBackendInterface) categoryTtl(category string) time.Duration {
+  return categoryTtl(b.cfg.GetTtl(), category)
+}
+
+// evaluates whether a stored value has expired at a given time (in nanoseconds since UNIX epoch)
+func isBoltValueExpired(value []byte, ttl time.Duration, now int64) bool {
+  if len(value) < boltHeaderLength {
+    return true // malformed
+  }
+
+  writtenAt := int64(binary.BigEndian.Uint64(value))
+  return ttl >= 0 && now-writtenAt > int64(ttl)
+}
+
+func (b *boltStorageBackendInterface) GetCacheEntry(category string, key string, ttl time.Duration) ([]byte, error) {
+  data, _, err := b.GetTimestampedCacheEntry(category, key, ttl)
+  return data, err
+}
+
+func (b *boltStorageBackendInterface) GetTimestampedCacheEntry(category string, key string, ttl time.Duration) ([]byte, time.Time, error) {
+  var data []byte
+  var writtenAt time.Time
+  err := b.db.View(func(tx *bolt.Tx) error {
+    bucket := tx.Bucket([]byte(category))
+    if bucket == nil {
+      return nil
+    }
+
+    value := bucket.Get([]byte(key))
+    if value == nil || isBoltValueExpired(value, ttl, time.Now().UnixNano()) {
+      return nil
+    }
+
+    // values are only valid for the lifetime of their transaction
+    writtenAt = time.Unix(0, int64(binary.BigEndian.Uint64(value)))
+    data = make([]byte, len(value)-boltHeaderLength)
+    copy(data, value[boltHeaderLength:])
+    return nil
+  })
+  return data, writtenAt, err
+}
+
+func (b *boltStorageBackendInterface) PutCacheEntry(category string, key string, data []byte, ttl time.Duration) error {
+  return b.PutTimestampedCacheEntry(category, key, data, time.Now(), ttl)
+}
+
+func (b *boltStorageBackendInterface) PutTimestampedCacheEntry(category string, key string, data []byte, writtenAt time.Time, ttl time.Duration) error {
+  value := make([]byte, boltHeaderLength+len(data))
+  binary.BigEndian.PutUint64(value, uint64(writtenAt.UnixNano()))
+  copy(value[boltHeaderLength:], data)
+
+  return b.db.Update(func(tx *bolt.Tx) error {
+    bucket, err := tx.CreateBucketIfNotExists([]byte(category))
+    if err != nil {
+      return err
+    }
+    return bucket.Put([]byte(key), value)
+  })
+}
+
+func (b *boltStorageBackendInterface) PurgeCacheEntry(category string, key string) error {
+  return b.db.Update(func(tx *bolt.Tx) error {
+    bucket := tx.Bucket([]byte(category))
+    if bucket == nil {
+      return nil
+    }
+    return bucket.Delete([]byte(key))
+  })
+}
+
+func (b *boltStorageBackendInterface) ListCacheEntries(category string) ([]string, error) {
+  keys := make([]string, 0)
+  err := b.db.View(func(tx *bolt.Tx) error {
+    bucket := tx.Bucket([]byte(category))
+    if bucket == nil {
+      return nil
+    }
+
+    ttl := b.categoryTtl(category)
+    now := time.Now().UnixNano()
+    return bucket.ForEach(func(key []byte, value []byte) error {
+      if !isBoltValueExpired(value, ttl, now) {
+        keys = append(keys, string(key))
+      }
+      return nil
+    })
+  })
+  return keys, err
+}
+
+// removes all expired entries from the database and returns the amount of removed entries
+func (b *boltStorageBackendInterface) removeExpiredEntries() (int, error) {
+  removed := 0
+  err := b.db.Update(func(tx *bolt.Tx) error {
+    removed = 0
+    now := time.Now().UnixNano()
+
+    return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+      if bytes.Equal(name, boltMetaBucket) {
+        return nil
+      }
+      ttl := b.categoryTtl(string(name))
+
+      // keys cannot be deleted while iterating using ForEach thus we'll have to rely on a cursor
+      // which is repositioned by Delete
+      cursor := bucket.Cursor()
+      for key, value := cursor.First(); key != nil; {
+        if isBoltValueExpired(value, ttl, now) {
+          err := cursor.Delete()
+          if err != nil {
+            return err
+          }
+          removed++
+          key, value = cursor.Seek(key)
+          continue
+        }
+        key, value = cursor.Next()
+      }
+      return nil
+    })
+  })
+  return removed, err
+}
+
+// periodically removes expired entries until the backend is closed
+func (b *boltStorageBackendInterface) sweep() {
+  for {
+    select {
+    case <-b.done:
+      return
+    case <-b.sweepTicker.C:
+      removed, err := b.removeExpiredEntries()
+      if err != nil {
+        b.logger.Errorf("failed to remove expired entries: %s", err)
+        continue
+      }
+      b.logger.Debugf("removed %d expired entries", removed)
+    }
+  }
+}
+
+func (b *boltStorageBackendInterface) Close() error {
+  b.sweepTicker.Stop()
+  close(b.done)
+  err := b.db.Close()
+  b.lock.Close()
+  return err
+}
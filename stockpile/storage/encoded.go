@@ -44,6 +44,37 @@ type EncodedStorageBackendInterface interface {
   Close() error
 }
 
+// provides an optional extension to encoded storage backend implementations which are capable of
+// listing the keys within a given category
+type EncodedStorageBackendIterator interface {
+  // retrieves the keys of all cache entries within a given category
+  ListCacheEntries(category string) ([]string, error)
+}
+
+// provides an optional extension to encoded storage backend implementations which are shared
+// between multiple instances and are capable of reporting modifications made by other instances
+type EncodedStorageBackendObserver interface {
+  // registers a handler which is notified whenever another instance modifies or purges an entry
+  SubscribeCacheEntryInvalidations(handler InvalidationHandler) error
+}
+
+// provides an optional extension to encoded storage backend implementations which rely on external
+// services
+type EncodedStorageBackendPinger interface {
+  // verifies whether the backend is currently reachable and returns a descriptive error otherwise
+  Ping() error
+}
+
+// provides an optional extension to encoded storage backend implementations which keep track of
+// the time at which their entries have been written
+type EncodedStorageBackendTimestamper interface {
+  // retrieves the data of a previously stored cache entry along with the time at which it has been
+  // written (given that it exists and is still considered valid in accordance with its ttl)
+  GetTimestampedCacheEntry(category string, name string, ttl time.Duration) ([]byte, time.Time, error)
+  // creates or updates a cache entry which has been written at the given time
+  PutTimestampedCacheEntry(category string, name string, encoded []byte, writtenAt time.Time, ttl time.Duration) error
+}
+
 func NewEncodedStorageBackend(cfg *server.Config, impl EncodedStorageBackendInterface) *EncodedStorageBackend {
   return &EncodedStorageBackend{
     cfg:  cfg,
@@ -52,7 +83,7 @@ func NewEncodedStorageBackend(cfg *server.Config, impl EncodedStorageBackendInte
 }
 
 func (f *EncodedStorageBackend) GetProfileId(name string, at time.Time) (*entity.ProfileId, error) {
-  enc, err := f.impl.GetCacheEntry("name", calculateHash(name), f.cfg.Ttl.Name)
+  enc, err := f.impl.GetCacheEntry("name", calculateHash(name), f.cfg.GetTtl().Name)
   if err != nil {
     return nil, err
   }
@@ -76,7 +107,7 @@ func (f *EncodedStorageBackend) GetProfileId(name string, at time.Time) (*entity
 
 func (f *EncodedStorageBackend) PutProfileId(profileId *entity.ProfileId) error {
   key := calculateHash(profileId.Name)
-  enc, err := f.impl.GetCacheEntry("name", key, f.cfg.Ttl.Name)
+  enc, err := f.impl.GetCacheEntry("name", key, f.cfg.GetTtl().Name)
   if err != nil {
     return err
   }
@@ -110,12 +141,12 @@ func (f *EncodedStorageBackend) PutProfileId(profileId *entity.ProfileId) error
   if err != nil {
     return err
   }
-  return f.impl.PutCacheEntry("name", key, enc, f.cfg.Ttl.Name)
+  return f.impl.PutCacheEntry("name", key, enc, f.cfg.GetTtl().Name)
 }
 
 func (f *EncodedStorageBackend) PurgeProfileId(name string, at time.Time) error {
   key := calculateHash(name)
-  enc, err := f.impl.GetCacheEntry("name", key, f.cfg.Ttl.Name)
+  enc, err := f.impl.GetCacheEntry("name", key, f.cfg.GetTtl().Name)
   if err != nil {
     return err
   }
@@ -146,11 +177,11 @@ func (f *EncodedStorageBackend) PurgeProfileId(name string, at time.Time) error
   if err != nil {
     return err
   }
-  return f.impl.PutCacheEntry("name", key, enc, f.cfg.Ttl.Name)
+  return f.impl.PutCacheEntry("name", key, enc, f.cfg.GetTtl().Name)
 }
 
 func (f *EncodedStorageBackend) GetNameHistory(id uuid.UUID) (*entity.NameChangeHistory, error) {
-  enc, err := f.impl.GetCacheEntry("history", id.String(), f.cfg.Ttl.NameHistory)
+  enc, err := f.impl.GetCacheEntry("history", id.String(), f.cfg.GetTtl().NameHistory)
   if err != nil {
     return nil, err
   }
@@ -169,7 +200,7 @@ func (f *EncodedStorageBackend) PutNameHistory(id uuid.UUID, history *entity.Nam
     return err
   }
 
-  return f.impl.PutCacheEntry("history", id.String(), enc, f.cfg.Ttl.NameHistory)
+  return f.impl.PutCacheEntry("history", id.String(), enc, f.cfg.GetTtl().NameHistory)
 }
 
 func (f *EncodedStorageBackend) PurgeNameHistory(id uuid.UUID) error {
@@ -177,7 +208,7 @@ func (f *EncodedStorageBackend) PurgeNameHistory(id uuid.UUID) error {
 }
 
 func (f *EncodedStorageBackend) GetProfile(id uuid.UUID) (*entity.Profile, error) {
-  enc, err := f.impl.GetCacheEntry("profile", id.String(), f.cfg.Ttl.Profile)
+  enc, err := f.impl.GetCacheEntry("profile", id.String(), f.cfg.GetTtl().Profile)
   if err != nil {
     return nil, err
   }
@@ -196,7 +227,7 @@ func (f *EncodedStorageBackend) PutProfile(profile *entity.Profile) error {
     return err
   }
 
-  return f.impl.PutCacheEntry("profile", profile.Id.String(), enc, f.cfg.Ttl.Profile)
+  return f.impl.PutCacheEntry("profile", profile.Id.String(), enc, f.cfg.GetTtl().Profile)
 }
 
 func (f *EncodedStorageBackend) PurgeProfile(id uuid.UUID) error {
@@ -205,7 +236,7 @@ func (f *EncodedStorageBackend) PurgeProfile(id uuid.UUID) error {
 
 // Server Data
 func (f *EncodedStorageBackend) GetBlacklist() (*entity.Blacklist, error) {
-  enc, err := f.impl.GetCacheEntry("misc", "blacklist", f.cfg.Ttl.Blacklist)
+  enc, err := f.impl.GetCacheEntry("misc", "blacklist", f.cfg.GetTtl().Blacklist)
   if err != nil {
     return nil, err
   }
@@ -224,13 +255,219 @@ func (f *EncodedStorageBackend) PutBlacklist(blacklist *entity.Blacklist) error
     return err
   }
 
-  return f.impl.PutCacheEntry("misc", "blacklist", enc, f.cfg.Ttl.Blacklist)
+  return f.impl.PutCacheEntry("misc", "blacklist", enc, f.cfg.GetTtl().Blacklist)
 }
 
 func (f *EncodedStorageBackend) PurgeBlacklist() error {
   return f.impl.PurgeCacheEntry("misc", "blacklist")
 }
 
+func (f *EncodedStorageBackend) GetNameTimeline(name string) (*entity.NameTimeline, error) {
+  enc, err := f.impl.GetCacheEntry("timeline", calculateHash(name), f.cfg.GetTtl().Name)
+  if err != nil {
+    return nil, err
+  }
+  if enc == nil {
+    return nil, nil
+  }
+
+  timeline := &entity.NameTimeline{}
+  err = timeline.Deserialize(enc)
+  return timeline, err
+}
+
+func (f *EncodedStorageBackend) PutNameTimeline(timeline *entity.NameTimeline) error {
+  enc, err := timeline.Serialize()
+  if err != nil {
+    return err
+  }
+
+  return f.impl.PutCacheEntry("timeline", calculateHash(timeline.Name), enc, f.cfg.GetTtl().Name)
+}
+
+func (f *EncodedStorageBackend) PurgeNameTimeline(name string) error {
+  return f.impl.PurgeCacheEntry("timeline", calculateHash(name))
+}
+
+func (f *EncodedStorageBackend) ForEachProfileId(fn func(profileId *entity.ProfileId) error) error {
+  return f.forEachCacheEntry("name", f.cfg.GetTtl().Name, func(_ string, enc []byte) error {
+    ids, err := entity.DeserializeProfileIdArray(enc)
+    if err != nil {
+      return err
+    }
+
+    for _, id := range ids {
+      err = fn(id)
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  })
+}
+
+func (f *EncodedStorageBackend) ForEachNameHistory(fn func(id uuid.UUID, history *entity.NameChangeHistory) error) error {
+  return f.forEachCacheEntry("history", f.cfg.GetTtl().NameHistory, func(key string, enc []byte) error {
+    id, err := uuid.Parse(key)
+    if err != nil {
+      return err
+    }
+
+    history := &entity.NameChangeHistory{}
+    err = history.Deserialize(enc)
+    if err != nil {
+      return err
+    }
+    return fn(id, history)
+  })
+}
+
+func (f *EncodedStorageBackend) ForEachProfile(fn func(profile *entity.Profile) error) error {
+  return f.forEachCacheEntry("profile", f.cfg.GetTtl().Profile, func(_ string, enc []byte) error {
+    profile := &entity.Profile{}
+    err := profile.Deserialize(enc)
+    if err != nil {
+      return err
+    }
+    return fn(profile)
+  })
+}
+
+func (f *EncodedStorageBackend) ForEachNameTimeline(fn func(timeline *entity.NameTimeline) error) error {
+  return f.forEachCacheEntry("timeline", f.cfg.GetTtl().Name, func(_ string, enc []byte) error {
+    timeline := &entity.NameTimeline{}
+    err := timeline.Deserialize(enc)
+    if err != nil {
+      return err
+    }
+    return fn(timeline)
+  })
+}
+
+func (f *EncodedStorageBackend) ForEachCacheEntry(category string, fn func(entry *CacheEntry) error) error {
+  iterator, ok := f.impl.(EncodedStorageBackendIterator)
+  if !ok {
+    return ErrIterationUnsupported
+  }
+  if _, ok := f.impl.(EncodedStorageBackendTimestamper); !ok {
+    return ErrTimestampUnsupported
+  }
+
+  keys, err := iterator.ListCacheEntries(category)
+  if err != nil {
+    return err
+  }
+
+  for _, key := range keys {
+    entry, err := f.GetCacheEntry(category, key)
+    if err != nil {
+      return err
+    }
+    if entry == nil {
+      continue // expired or removed in the meantime
+    }
+
+    err = fn(entry)
+    if err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+func (f *EncodedStorageBackend) GetCacheEntry(category string, key string) (*CacheEntry, error) {
+  timestamper, ok := f.impl.(EncodedStorageBackendTimestamper)
+  if !ok {
+    return nil, ErrTimestampUnsupported
+  }
+
+  enc, writtenAt, err := timestamper.GetTimestampedCacheEntry(category, key, categoryTtl(f.cfg.GetTtl(), category))
+  if err != nil || enc == nil {
+    return nil, err
+  }
+
+  return &CacheEntry{
+    Category:  category,
+    Key:       key,
+    Data:      enc,
+    WrittenAt: writtenAt,
+  }, nil
+}
+
+func (f *EncodedStorageBackend) PutCacheEntry(entry *CacheEntry) error {
+  timestamper, ok := f.impl.(EncodedStorageBackendTimestamper)
+  if !ok {
+    return ErrTimestampUnsupported
+  }
+
+  return timestamper.PutTimestampedCacheEntry(entry.Category, entry.Key, entry.Data, entry.WrittenAt, categoryTtl(f.cfg.GetTtl(), entry.Category))
+}
+
+func (f *EncodedStorageBackend) SubscribeInvalidations(handler InvalidationHandler) error {
+  observer, ok := f.impl.(EncodedStorageBackendObserver)
+  if !ok {
+    return ErrObservationUnsupported
+  }
+
+  return observer.SubscribeCacheEntryInvalidations(handler)
+}
+
+func (f *EncodedStorageBackend) Ping() error {
+  pinger, ok := f.impl.(EncodedStorageBackendPinger)
+  if !ok {
+    return nil // local implementations are always considered available
+  }
+
+  return pinger.Ping()
+}
+
+// invokes the passed function for every valid cache entry within a given category
+func (f *EncodedStorageBackend) forEachCacheEntry(category string, ttl time.Duration, fn func(key string, enc []byte) error) error {
+  iterator, ok := f.impl.(EncodedStorageBackendIterator)
+  if !ok {
+    return ErrIterationUnsupported
+  }
+
+  keys, err := iterator.ListCacheEntries(category)
+  if err != nil {
+    return err
+  }
+
+  for _, key := range keys {
+    enc, err := f.impl.GetCacheEntry(category, key, ttl)
+    if err != nil {
+      return err
+    }
+    if enc == nil {
+      continue // expired or removed in the meantime
+    }
+
+    err = fn(key, enc)
+    if err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+// retrieves the TTL which applies to the entries within a given category (negative TTLs never
+// expire)
+func categoryTtl(ttl *server.TtlConfig, category string) time.Duration {
+  switch category {
+  case "name", "timeline":
+    return ttl.Name
+  case "history":
+    return ttl.NameHistory
+  case "profile":
+    return ttl.Profile
+  case "misc":
+    return ttl.Blacklist
+  }
+  return -1
+}
+
 func (f *EncodedStorageBackend) Close() error {
   return f.impl.Close()
 }
@@ -32,14 +32,19 @@ import (
 const lockExpiration = time.Minute * 5 // keep-alive occurs every minute, expiration after 5 minutes
 const lockKeepalive = time.Minute
 
-const dirPerms = 664  // rw-rw-r--
-const filePerms = 664 // rw-rw-r--
+const dirPerms = 0775  // rwxrwxr-x
+const filePerms = 0664 // rw-rw-r--
+
+// identifies entries which are still being written
+const tempFilePrefix = ".tmp-"
 
 type fileStorageBackendInterface struct {
-  logger     *logging.Logger
-  cfg        *FileStorageBackendCfg
-  lockPath   string
-  lockTicker *time.Ticker
+  logger       *logging.Logger
+  cfg          *FileStorageBackendCfg
+  lockPath     string
+  lockTicker   *time.Ticker
+  lockDone     chan struct{}
+  lockReleased chan struct{}
 }
 
 type FileStorageBackendCfg struct {
@@ -47,9 +52,13 @@ type FileStorageBackendCfg struct {
 }
 
 // creates a new file storage backend
+// Deprecated: the bolt backend provides proper locking and crash safety and should be preferred
 func NewFileStorageBackend(cfg *server.Config) (StorageBackend, error) {
   fileCfg := &FileStorageBackendCfg{}
-  gohcl.DecodeBody(cfg.Storage.Parameters, nil, fileCfg)
+  gohcl.DecodeBody(cfg.Storage.Parameters, server.EvalContext(), fileCfg)
+
+  logger := logging.MustGetLogger("file")
+  logger.Warningf("the file storage backend is deprecated and will be removed in a future release - use the bolt backend instead (existing data may be copied using the migrating backend)")
 
   lockPath := filepath.Join(fileCfg.Path, "storage.lock")
   _, err := os.Stat(fileCfg.Path)
@@ -69,7 +78,7 @@ func NewFileStorageBackend(cfg *server.Config) (StorageBackend, error) {
         return nil, errors.New("file storage directory is locked by another instance - verify whether another instance is running and delete 'storage.lock' if this issue persists")
       }
     }
-    if !os.IsNotExist(err) {
+    if err != nil && !os.IsNotExist(err) {
       return nil, err
     }
   }
@@ -80,10 +89,12 @@ func NewFileStorageBackend(cfg *server.Config) (StorageBackend, error) {
   }
 
   impl := &fileStorageBackendInterface{
-    logger:     logging.MustGetLogger("file"),
-    cfg:        fileCfg,
-    lockPath:   lockPath,
-    lockTicker: time.NewTicker(lockKeepalive),
+    logger:       logger,
+    cfg:          fileCfg,
+    lockPath:     lockPath,
+    lockTicker:   time.NewTicker(lockKeepalive),
+    lockDone:     make(chan struct{}),
+    lockReleased: make(chan struct{}),
   }
   go impl.updateLock()
   return NewEncodedStorageBackend(cfg, impl), nil
@@ -91,30 +102,56 @@ func NewFileStorageBackend(cfg *server.Config) (StorageBackend, error) {
 
 // updates the modification time of the lock file periodically to prevent its automatic expiration
 func (f *fileStorageBackendInterface) updateLock() {
-  for range f.lockTicker.C {
-    f.logger.Debugf("updating database lock")
-    ioutil.WriteFile(f.lockPath, []byte{}, filePerms)
+  defer close(f.lockReleased)
+
+  for {
+    select {
+    case <-f.lockDone:
+      return
+    case <-f.lockTicker.C:
+      f.logger.Debugf("updating database lock")
+      ioutil.WriteFile(f.lockPath, []byte{}, filePerms)
+    }
   }
 }
 
 func (f *fileStorageBackendInterface) GetCacheEntry(category string, key string, ttl time.Duration) ([]byte, error) {
+  data, _, err := f.GetTimestampedCacheEntry(category, key, ttl)
+  return data, err
+}
+
+// the modification time of an entry doubles as the time at which it has been written
+func (f *fileStorageBackendInterface) GetTimestampedCacheEntry(category string, key string, ttl time.Duration) ([]byte, time.Time, error) {
   path := filepath.Join(f.cfg.Path, category, strings.ToLower(key))
 
   stat, err := os.Stat(path)
   if os.IsNotExist(err) {
-    return nil, nil
+    return nil, time.Time{}, nil
   }
   if err != nil {
-    return nil, err
+    return nil, time.Time{}, err
   }
   if ttl != -1 && stat.ModTime().Add(ttl).Before(time.Now()) {
-    return nil, nil
+    return nil, time.Time{}, nil
   }
 
-  return ioutil.ReadFile(path)
+  data, err := ioutil.ReadFile(path)
+  if err != nil {
+    return nil, time.Time{}, err
+  }
+  return data, stat.ModTime(), nil
 }
 
 func (f *fileStorageBackendInterface) PutCacheEntry(category string, key string, data []byte, ttl time.Duration) error {
+  return f.writeCacheEntry(category, key, data, time.Time{})
+}
+
+func (f *fileStorageBackendInterface) PutTimestampedCacheEntry(category string, key string, data []byte, writtenAt time.Time, ttl time.Duration) error {
+  return f.writeCacheEntry(category, key, data, writtenAt)
+}
+
+// writes a cache entry and backdates its modification time to the given time (unless zero)
+func (f *fileStorageBackendInterface) writeCacheEntry(category string, key string, data []byte, writtenAt time.Time) error {
   dir := filepath.Join(f.cfg.Path, category)
   path := filepath.Join(dir, strings.ToLower(key))
 
@@ -127,7 +164,30 @@ func (f *fileStorageBackendInterface) PutCacheEntry(category string, key string,
     os.MkdirAll(dir, dirPerms)
   }
 
-  return ioutil.WriteFile(path, data, filePerms)
+  // entries are written to a temporary file first and moved into place afterwards in order to
+  // prevent concurrent readers from encountering partially written entries
+  tmp, err := ioutil.TempFile(dir, tempFilePrefix)
+  if err != nil {
+    return err
+  }
+  _, err = tmp.Write(data)
+  if err == nil {
+    err = tmp.Chmod(filePerms)
+  }
+  closeErr := tmp.Close()
+  if err == nil {
+    err = closeErr
+  }
+  if err == nil && !writtenAt.IsZero() {
+    err = os.Chtimes(tmp.Name(), writtenAt, writtenAt)
+  }
+  if err == nil {
+    err = os.Rename(tmp.Name(), path)
+  }
+  if err != nil {
+    os.Remove(tmp.Name())
+  }
+  return err
 }
 
 func (f *fileStorageBackendInterface) PurgeCacheEntry(category string, key string) error {
@@ -145,6 +205,30 @@ func (f *fileStorageBackendInterface) PurgeCacheEntry(category string, key strin
   return os.Remove(path)
 }
 
+func (f *fileStorageBackendInterface) ListCacheEntries(category string) ([]string, error) {
+  files, err := ioutil.ReadDir(filepath.Join(f.cfg.Path, category))
+  if err != nil {
+    if os.IsNotExist(err) {
+      return nil, nil
+    }
+
+    return nil, err
+  }
+
+  keys := make([]string, 0, len(files))
+  for _, file := range files {
+    if file.IsDir() || strings.HasPrefix(file.Name(), tempFilePrefix) {
+      continue
+    }
+
+    keys = append(keys, file.Name())
+  }
+  return keys, nil
+}
+
 func (f *fileStorageBackendInterface) Close() error {
-  return nil
+  f.lockTicker.Stop()
+  close(f.lockDone)
+  <-f.lockReleased
+  return os.Remove(f.lockPath)
 }
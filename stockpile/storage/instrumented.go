@@ -0,0 +1,229 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package storage
+
+import (
+  "context"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/metrics"
+  "github.com/dotStart/Stockpile/stockpile/tracing"
+  "github.com/google/uuid"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/trace"
+)
+
+// provides a storage backend which records the latency and errors of all operations performed
+// by another backend and creates a span for each of them
+// optional extensions of the delegate backend remain accessible through this backend
+type InstrumentedStorageBackend struct {
+  ctx      context.Context
+  delegate StorageBackend
+}
+
+// wraps a storage backend in order to record metrics about its operations
+func NewInstrumentedStorageBackend(delegate StorageBackend) *InstrumentedStorageBackend {
+  return &InstrumentedStorageBackend{
+    ctx:      context.Background(),
+    delegate: delegate,
+  }
+}
+
+// creates a copy of this backend which creates its spans within the passed context
+func (i *InstrumentedStorageBackend) WithContext(ctx context.Context) *InstrumentedStorageBackend {
+  return &InstrumentedStorageBackend{
+    ctx:      ctx,
+    delegate: i.delegate,
+  }
+}
+
+// begins observing an operation and returns a function which completes the observation
+func (i *InstrumentedStorageBackend) observe(operation string) func(err error) {
+  start := time.Now()
+  _, span := tracing.Start(i.ctx, "storage."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+    attribute.String("stockpile.storage.operation", operation),
+  ))
+
+  return func(err error) {
+    metrics.ObserveStorageOperation(operation, start, err)
+    tracing.End(span, err)
+  }
+}
+
+func (i *InstrumentedStorageBackend) Close() error {
+  return i.delegate.Close()
+}
+
+func (i *InstrumentedStorageBackend) GetStatistics() *entity.StorageStatistics {
+  provider, ok := i.delegate.(StatisticsProvider)
+  if !ok {
+    return nil
+  }
+  return provider.GetStatistics()
+}
+
+func (i *InstrumentedStorageBackend) Ping() error {
+  done := i.observe("ping")
+  err := Ping(i.delegate)
+  done(err)
+  return err
+}
+
+func (i *InstrumentedStorageBackend) InvalidateProfileId(name string) {
+  if backend, ok := i.delegate.(CachingStorageBackend); ok {
+    backend.InvalidateProfileId(name)
+  }
+}
+
+func (i *InstrumentedStorageBackend) InvalidateNameHistory(id uuid.UUID) {
+  if backend, ok := i.delegate.(CachingStorageBackend); ok {
+    backend.InvalidateNameHistory(id)
+  }
+}
+
+func (i *InstrumentedStorageBackend) InvalidateProfile(id uuid.UUID) {
+  if backend, ok := i.delegate.(CachingStorageBackend); ok {
+    backend.InvalidateProfile(id)
+  }
+}
+
+func (i *InstrumentedStorageBackend) InvalidateBlacklist() {
+  if backend, ok := i.delegate.(CachingStorageBackend); ok {
+    backend.InvalidateBlacklist()
+  }
+}
+
+func (i *InstrumentedStorageBackend) GetProfileId(name string, at time.Time) (*entity.ProfileId, error) {
+  done := i.observe("get_profile_id")
+  profileId, err := i.delegate.GetProfileId(name, at)
+  done(err)
+  return profileId, err
+}
+
+func (i *InstrumentedStorageBackend) PutProfileId(profileId *entity.ProfileId) error {
+  done := i.observe("put_profile_id")
+  err := i.delegate.PutProfileId(profileId)
+  done(err)
+  return err
+}
+
+func (i *InstrumentedStorageBackend) PurgeProfileId(name string, at time.Time) error {
+  done := i.observe("purge_profile_id")
+  err := i.delegate.PurgeProfileId(name, at)
+  done(err)
+  return err
+}
+
+func (i *InstrumentedStorageBackend) GetNameHistory(id uuid.UUID) (*entity.NameChangeHistory, error) {
+  done := i.observe("get_name_history")
+  history, err := i.delegate.GetNameHistory(id)
+  done(err)
+  return history, err
+}
+
+func (i *InstrumentedStorageBackend) PutNameHistory(id uuid.UUID, history *entity.NameChangeHistory) error {
+  done := i.observe("put_name_history")
+  err := i.delegate.PutNameHistory(id, history)
+  done(err)
+  return err
+}
+
+func (i *InstrumentedStorageBackend) PurgeNameHistory(id uuid.UUID) error {
+  done := i.observe("purge_name_history")
+  err := i.delegate.PurgeNameHistory(id)
+  done(err)
+  return err
+}
+
+func (i *InstrumentedStorageBackend) GetProfile(id uuid.UUID) (*entity.Profile, error) {
+  done := i.observe("get_profile")
+  profile, err := i.delegate.GetProfile(id)
+  done(err)
+  return profile, err
+}
+
+func (i *InstrumentedStorageBackend) PutProfile(profile *entity.Profile) error {
+  done := i.observe("put_profile")
+  err := i.delegate.PutProfile(profile)
+  done(err)
+  return err
+}
+
+func (i *InstrumentedStorageBackend) PurgeProfile(id uuid.UUID) error {
+  done := i.observe("purge_profile")
+  err := i.delegate.PurgeProfile(id)
+  done(err)
+  return err
+}
+
+func (i *InstrumentedStorageBackend) GetBlacklist() (*entity.Blacklist, error) {
+  done := i.observe("get_blacklist")
+  blacklist, err := i.delegate.GetBlacklist()
+  done(err)
+  return blacklist, err
+}
+
+func (i *InstrumentedStorageBackend) PutBlacklist(blacklist *entity.Blacklist) error {
+  done := i.observe("put_blacklist")
+  err := i.delegate.PutBlacklist(blacklist)
+  done(err)
+  return err
+}
+
+func (i *InstrumentedStorageBackend) PurgeBlacklist() error {
+  done := i.observe("purge_blacklist")
+  err := i.delegate.PurgeBlacklist()
+  done(err)
+  return err
+}
+
+func (i *InstrumentedStorageBackend) GetNameTimeline(name string) (*entity.NameTimeline, error) {
+  backend, ok := i.delegate.(TimelineStorageBackend)
+  if !ok {
+    return nil, ErrTimelineUnsupported
+  }
+
+  done := i.observe("get_name_timeline")
+  timeline, err := backend.GetNameTimeline(name)
+  done(err)
+  return timeline, err
+}
+
+func (i *InstrumentedStorageBackend) PutNameTimeline(timeline *entity.NameTimeline) error {
+  backend, ok := i.delegate.(TimelineStorageBackend)
+  if !ok {
+    return ErrTimelineUnsupported
+  }
+
+  done := i.observe("put_name_timeline")
+  err := backend.PutNameTimeline(timeline)
+  done(err)
+  return err
+}
+
+func (i *InstrumentedStorageBackend) PurgeNameTimeline(name string) error {
+  backend, ok := i.delegate.(TimelineStorageBackend)
+  if !ok {
+    return ErrTimelineUnsupported
+  }
+
+  done := i.observe("purge_name_timeline")
+  err := backend.PurgeNameTimeline(name)
+  done(err)
+  return err
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package storage
+
+import (
+  "container/list"
+  "sync"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+)
+
+// provides a size bounded least recently used cache which additionally expires its entries after
+// a given duration
+type lruCache struct {
+  mutex      sync.Mutex
+  maxEntries int
+  maxBytes   int64
+  bytes      int64
+  entries    map[string]*list.Element
+  order      *list.List
+
+  hits        uint64
+  misses      uint64
+  evictions   uint64
+  expirations uint64
+}
+
+// represents a single entry within an lru cache
+type lruEntry struct {
+  key       string
+  value     interface{}
+  size      int64
+  expiresAt time.Time
+}
+
+// creates a new lru cache which retains at most the specified amount of entries and bytes
+// when zero or a negative value is passed for either limit, the cache will not be bounded by it
+func newLruCache(maxEntries int, maxBytes int64) *lruCache {
+  return &lruCache{
+    maxEntries: maxEntries,
+    maxBytes:   maxBytes,
+    entries:    make(map[string]*list.Element),
+    order:      list.New(),
+  }
+}
+
+// retrieves a value from the cache and marks it as recently used
+func (c *lruCache) Get(key string) (interface{}, bool) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+
+  elem := c.entries[key]
+  if elem == nil {
+    c.misses++
+    return nil, false
+  }
+
+  entry := elem.Value.(*lruEntry)
+  if !entry.expiresAt.After(time.Now()) {
+    c.removeElement(elem)
+    c.expirations++
+    c.misses++
+    return nil, false
+  }
+
+  c.order.MoveToFront(elem)
+  c.hits++
+  return entry.value, true
+}
+
+// creates or replaces a value of a given (approximate) size within the cache and evicts the least
+// recently used entries if the cache exceeds its capacity
+func (c *lruCache) Put(key string, value interface{}, size int64, ttl time.Duration) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+
+  expiresAt := time.Now().Add(ttl)
+  elem := c.entries[key]
+  if elem != nil {
+    entry := elem.Value.(*lruEntry)
+    c.bytes += size - entry.size
+    entry.value = value
+    entry.size = size
+    entry.expiresAt = expiresAt
+    c.order.MoveToFront(elem)
+  } else {
+    c.entries[key] = c.order.PushFront(&lruEntry{
+      key:       key,
+      value:     value,
+      size:      size,
+      expiresAt: expiresAt,
+    })
+    c.bytes += size
+  }
+
+  // the most recent entry is always retained (even if it exceeds the byte limit on its own)
+  for c.order.Len() > 1 && ((c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes)) {
+    c.removeElement(c.order.Back())
+    c.evictions++
+  }
+}
+
+// removes a value from the cache (if present)
+func (c *lruCache) Remove(key string) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+
+  elem := c.entries[key]
+  if elem != nil {
+    c.removeElement(elem)
+  }
+}
+
+// removes all expired values from the cache and returns the amount of removed values
+func (c *lruCache) RemoveExpired() int {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+
+  now := time.Now()
+  removed := 0
+  for elem := c.order.Back(); elem != nil; {
+    prev := elem.Prev()
+    if !elem.Value.(*lruEntry).expiresAt.After(now) {
+      c.removeElement(elem)
+      removed++
+    }
+    elem = prev
+  }
+
+  c.expirations += uint64(removed)
+  return removed
+}
+
+// removes all values from the cache
+func (c *lruCache) Clear() {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+
+  c.entries = make(map[string]*list.Element)
+  c.order.Init()
+  c.bytes = 0
+}
+
+// retrieves the amount of entries within the cache (including entries which have expired but have
+// yet to be removed)
+func (c *lruCache) Len() int {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+
+  return c.order.Len()
+}
+
+// retrieves a snapshot of the cache utilization and its access statistics
+func (c *lruCache) Statistics() *entity.StorageStatistics {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+
+  return &entity.StorageStatistics{
+    Entries:     int64(c.order.Len()),
+    Bytes:       c.bytes,
+    Hits:        c.hits,
+    Misses:      c.misses,
+    Evictions:   c.evictions,
+    Expirations: c.expirations,
+  }
+}
+
+// removes a list element from the cache
+// this method expects the caller to hold the cache mutex
+func (c *lruCache) removeElement(elem *list.Element) {
+  entry := elem.Value.(*lruEntry)
+  c.order.Remove(elem)
+  delete(c.entries, entry.key)
+  c.bytes -= entry.size
+}
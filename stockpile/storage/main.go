@@ -17,12 +17,33 @@
 package storage
 
 import (
+  "errors"
   "time"
 
   "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
   "github.com/google/uuid"
 )
 
+// indicates that a storage backend is unable to enumerate its contents
+var ErrIterationUnsupported = errors.New("storage backend does not support iteration")
+
+// indicates that a storage backend is unable to report modifications made by other instances
+var ErrObservationUnsupported = errors.New("storage backend does not support observation")
+
+// indicates that a storage backend is unable to retain name timelines
+var ErrTimelineUnsupported = errors.New("storage backend does not support name timelines")
+
+// indicates that a storage backend is unable to report or retain the time at which its entries
+// have been written
+var ErrTimestampUnsupported = errors.New("storage backend does not support entry timestamps")
+
+// provides a factory for storage backend instances
+type Factory = func(*server.Config) (StorageBackend, error)
+
+// provides a lookup function which resolves storage backend factories based on their identifier
+type FactoryLookup = func(id string) Factory
+
 // provides an abstraction layer between the application and a storage backend
 type StorageBackend interface {
   Close() error
@@ -43,3 +64,102 @@ type StorageBackend interface {
   PutBlacklist(blacklist *entity.Blacklist) error
   PurgeBlacklist() error
 }
+
+// provides an optional extension to storage backends which are capable of enumerating their
+// contents (for instance, in order to copy them into another backend)
+type IterableStorageBackend interface {
+  StorageBackend
+
+  // invokes the passed function for every stored name association
+  ForEachProfileId(fn func(profileId *entity.ProfileId) error) error
+  // invokes the passed function for every stored name history
+  ForEachNameHistory(fn func(id uuid.UUID, history *entity.NameChangeHistory) error) error
+  // invokes the passed function for every stored profile
+  ForEachProfile(fn func(profile *entity.Profile) error) error
+}
+
+// provides an optional extension to storage backends which are capable of retaining the ownership
+// timelines of names (as derived from the name histories of their owners)
+type TimelineStorageBackend interface {
+  StorageBackend
+
+  // retrieves the ownership timeline of a given name
+  GetNameTimeline(name string) (*entity.NameTimeline, error)
+  // creates or replaces the ownership timeline of a name
+  PutNameTimeline(timeline *entity.NameTimeline) error
+  // purges the ownership timeline of a given name
+  PurgeNameTimeline(name string) error
+}
+
+// provides an optional extension to timeline storage backends which are capable of enumerating
+// their timelines as well
+type IterableTimelineStorageBackend interface {
+  TimelineStorageBackend
+
+  // invokes the passed function for every stored name timeline
+  ForEachNameTimeline(fn func(timeline *entity.NameTimeline) error) error
+}
+
+// handles the invalidation of a cache entry within a given category (one of "name", "history",
+// "profile", "timeline" or "misc")
+// name associations are identified by the hash of their lower case name while histories and
+// profiles are identified by their profile id
+type InvalidationHandler = func(category string, key string)
+
+// provides an optional extension to storage backends which are shared between multiple instances
+// and are capable of reporting modifications made by other instances
+type ObservableStorageBackend interface {
+  StorageBackend
+
+  // registers a handler which is notified whenever another instance modifies or purges an entry
+  SubscribeInvalidations(handler InvalidationHandler) error
+}
+
+// represents the encoded form of a cache entry along with the time at which it has been written
+type CacheEntry struct {
+  Category  string
+  Key       string
+  Data      []byte
+  WrittenAt time.Time
+}
+
+// provides an optional extension to storage backends which are capable of transferring their
+// entries without resetting the time at which they have been written (for instance, in order to
+// retain the remaining lifetime of entries which are copied into another backend)
+type TimestampedStorageBackend interface {
+  StorageBackend
+
+  // invokes the passed function for every valid cache entry within a given category
+  ForEachCacheEntry(category string, fn func(entry *CacheEntry) error) error
+  // retrieves a cache entry (given that it exists and is still considered valid)
+  GetCacheEntry(category string, key string) (*CacheEntry, error)
+  // creates or replaces a cache entry while retaining the time at which it has been written
+  PutCacheEntry(entry *CacheEntry) error
+}
+
+// provides an optional extension to storage backends which collect statistics about their
+// utilization
+type StatisticsProvider interface {
+  // retrieves a snapshot of the current backend statistics
+  GetStatistics() *entity.StorageStatistics
+}
+
+// provides an optional extension to storage backends which rely on external services and are
+// thus capable of reporting whether they are currently able to serve requests
+type PingableStorageBackend interface {
+  // verifies whether the backend is currently reachable and returns a descriptive error otherwise
+  Ping() error
+}
+
+// provides an optional extension to storage backends which retain local copies of entries and
+// thus need to be notified when other instances modify them
+type CachingStorageBackend interface {
+  // discards all local copies of the associations of a given name
+  InvalidateProfileId(name string)
+  // discards the local copy of the name history of a given profile
+  InvalidateNameHistory(id uuid.UUID)
+  // discards the local copy of a given profile
+  InvalidateProfile(id uuid.UUID)
+  // discards the local copy of the blacklist
+  InvalidateBlacklist()
+}
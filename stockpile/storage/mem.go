@@ -17,58 +17,143 @@
 package storage
 
 import (
+  "fmt"
   "strings"
+  "sync"
   "time"
 
   "github.com/dotStart/Stockpile/entity"
   "github.com/dotStart/Stockpile/stockpile/server"
   "github.com/google/uuid"
+  "github.com/hashicorp/hcl2/gohcl"
   "github.com/op/go-logging"
 )
 
+// defines the interval in which expired entries are removed when no interval is configured
+const defaultJanitorInterval = time.Minute
+
 type MemoryStorageBackend struct {
   cfg    *server.Config
   logger *logging.Logger
 
-  profileId   map[string][]expirationWrapper
-  nameHistory map[uuid.UUID]*expirationWrapper
-  profile     map[uuid.UUID]*expirationWrapper
+  // guards modifications which need to read existing entries before replacing them (such as
+  // name associations)
+  mutex sync.Mutex
+  // entries are copied whenever they are stored or retrieved as callers may modify both the
+  // instances they pass and the instances they receive
+  entries       *lruCache
+  janitorTicker *time.Ticker
+  done          chan struct{}
+  closeOnce     sync.Once
+}
 
-  blacklist *expirationWrapper
+type MemoryStorageBackendCfg struct {
+  MaxEntries      *int    `hcl:"max-entries,attr"`
+  MaxBytes        *int64  `hcl:"max-bytes,attr"`
+  JanitorInterval *string `hcl:"janitor-interval,attr"`
 }
 
 // creates a new memory based storage backend
 func NewMemoryStorageBackend(cfg *server.Config) (StorageBackend, error) {
-  return &MemoryStorageBackend{
+  memCfg := &MemoryStorageBackendCfg{}
+  if cfg.Storage.Parameters != nil {
+    diag := gohcl.DecodeBody(cfg.Storage.Parameters, server.EvalContext(), memCfg)
+    if diag.HasErrors() {
+      return nil, fmt.Errorf("illegal backend configuration: %s", diag.Error())
+    }
+  }
+
+  maxEntries := 0
+  if memCfg.MaxEntries != nil {
+    maxEntries = *memCfg.MaxEntries
+  }
+  var maxBytes int64
+  if memCfg.MaxBytes != nil {
+    maxBytes = *memCfg.MaxBytes
+  }
+  janitorInterval := defaultJanitorInterval
+  if memCfg.JanitorInterval != nil {
+    interval, err := time.ParseDuration(*memCfg.JanitorInterval)
+    if err != nil {
+      return nil, fmt.Errorf("illegal backend configuration: illegal janitor interval: %s", err)
+    }
+    if interval <= 0 {
+      return nil, fmt.Errorf("illegal backend configuration: janitor interval must be positive")
+    }
+    janitorInterval = interval
+  }
+
+  backend := &MemoryStorageBackend{
     cfg:    cfg,
     logger: logging.MustGetLogger("memdb"),
 
-    profileId:   make(map[string][]expirationWrapper),
-    nameHistory: make(map[uuid.UUID]*expirationWrapper),
-    profile:     make(map[uuid.UUID]*expirationWrapper),
-  }, nil
+    entries:       newLruCache(maxEntries, maxBytes),
+    janitorTicker: time.NewTicker(janitorInterval),
+    done:          make(chan struct{}),
+  }
+  go backend.clearExpiredEntries()
+  return backend, nil
 }
 
+// stops the janitor and discards all entries
+// subsequent invocations have no effect as the backend may be closed by both the cache and a
+// wrapping meta backend
 func (m *MemoryStorageBackend) Close() error {
+  m.closeOnce.Do(func() {
+    m.janitorTicker.Stop()
+    close(m.done)
+    m.entries.Clear()
+  })
   return nil
 }
 
-func (m *MemoryStorageBackend) GetProfileId(name string, at time.Time) (*entity.ProfileId, error) {
-  m.clearExpiredEntries()
+// retrieves a snapshot of the current backend utilization and access statistics
+func (m *MemoryStorageBackend) GetStatistics() *entity.StorageStatistics {
+  return m.entries.Statistics()
+}
+
+// estimates the amount of memory occupied by a given entry based on its encoded representation
+func estimateSize(enc []byte, err error) int64 {
+  if err != nil {
+    return 0
+  }
+  return int64(len(enc))
+}
+
+// retrieves a copy of the associations stored for a given name
+func (m *MemoryStorageBackend) getAssociations(name string) []*entity.ProfileId {
+  cached, ok := m.entries.Get(l1Key("name", name))
+  if !ok {
+    return nil
+  }
+  return cached.([]*entity.ProfileId)
+}
+
+// replaces the associations stored for a given name
+func (m *MemoryStorageBackend) putAssociations(name string, associations []*entity.ProfileId) {
+  key := l1Key("name", name)
+  if len(associations) == 0 {
+    m.entries.Remove(key)
+    return
+  }
 
+  m.entries.Put(key, associations, estimateSize(entity.SerializeProfileIdArray(associations)), m.cfg.GetTtl().Name)
+}
+
+func (m *MemoryStorageBackend) GetProfileId(name string, at time.Time) (*entity.ProfileId, error) {
   name = strings.ToLower(name)
   m.logger.Debugf("checking profile associations for \"%s\" at time %s", name, at)
-  mappings := m.profileId[name]
-  if mappings == nil {
+
+  associations := m.getAssociations(name)
+  if associations == nil {
     m.logger.Debugf("no associations for \"%s\"", name)
     return nil, nil
   }
 
-  for _, exp := range mappings {
-    association := exp.content.(*entity.ProfileId)
+  for _, association := range associations {
     if association.IsValid(at) {
       m.logger.Debugf("association to profile %s matches", association.Id)
-      return association, nil
+      return association.Copy(), nil
     } else {
       m.logger.Debugf("association to profile %s is invalid", association.Id)
     }
@@ -78,198 +163,159 @@ func (m *MemoryStorageBackend) GetProfileId(name string, at time.Time) (*entity.
 }
 
 func (m *MemoryStorageBackend) PutProfileId(profileId *entity.ProfileId) error {
-  m.clearExpiredEntries()
+  m.mutex.Lock()
+  defer m.mutex.Unlock()
 
   name := strings.ToLower(profileId.Name)
   m.logger.Debugf("updating association for name \"%s\" to profile %s at time %s (valid until %s)", profileId.Name, profileId.Id, profileId.LastSeenAt, profileId.ValidUntil)
-  mappings := m.profileId[name]
+
+  // associations are copied before they are modified as previously returned instances may still
+  // be in use by other routines
+  existing := m.getAssociations(name)
+  associations := make([]*entity.ProfileId, len(existing), len(existing)+1)
   found := false
-  if mappings != nil {
-    for _, e := range mappings {
-      entry := e.content.(*entity.ProfileId)
-      if entry.IsOverlappingWith(profileId) {
-        entry.UpdateExpiration(profileId.LastSeenAt)
-        found = true
-      }
+  for i, e := range existing {
+    entry := e.Copy()
+    if entry.IsOverlappingWith(profileId) {
+      entry.UpdateExpiration(profileId.LastSeenAt)
+      found = true
     }
-  } else {
-    mappings = make([]expirationWrapper, 0)
+    associations[i] = entry
   }
 
+  // the passed association is copied as well as it remains under the control of the caller
   if !found {
-    mappings = append(mappings, expirationWrapper{
-      content:   profileId,
-      createdAt: time.Now(),
-    })
+    associations = append(associations, profileId.Copy())
   }
 
-  m.profileId[name] = mappings
+  m.putAssociations(name, associations)
   return nil
 }
 
 func (m *MemoryStorageBackend) PurgeProfileId(name string, at time.Time) error {
-  m.clearExpiredEntries()
+  m.mutex.Lock()
+  defer m.mutex.Unlock()
 
+  name = strings.ToLower(name)
   m.logger.Debugf("purging profile associations for \"%s\" at time %s", name, at)
-  mappings := m.profileId[name]
-  if mappings == nil {
+
+  existing := m.getAssociations(name)
+  if existing == nil {
     m.logger.Debugf("No associations for \"%s\"", name)
     return nil
   }
 
   if at.Unix() == -1 {
-    delete(m.profileId, name)
+    m.putAssociations(name, nil)
     return nil
   }
 
-  for i := 0; i < len(mappings); {
-    exp := mappings[i]
-    association := exp.content.(*entity.ProfileId)
-
+  associations := make([]*entity.ProfileId, 0, len(existing))
+  for _, association := range existing {
     if association.IsValid(at) {
       m.logger.Debugf("purging association to profile %s", association.Id)
-      mappings = append(mappings[:i], mappings[:i+1]...)
       continue
     }
 
-    i++
+    associations = append(associations, association)
   }
 
-  m.profileId[name] = mappings
+  m.putAssociations(name, associations)
   return nil
 }
 
 func (m *MemoryStorageBackend) GetNameHistory(id uuid.UUID) (*entity.NameChangeHistory, error) {
-  m.clearExpiredEntries()
-
-  exp := m.nameHistory[id]
-  if exp == nil {
+  cached, ok := m.entries.Get(l1Key("history", id.String()))
+  if !ok {
     return nil, nil
   }
 
-  return exp.content.(*entity.NameChangeHistory), nil
+  return cached.(*entity.NameChangeHistory).Copy(), nil
 }
 
 func (m *MemoryStorageBackend) PutNameHistory(id uuid.UUID, history *entity.NameChangeHistory) error {
-  m.clearExpiredEntries()
-
   m.logger.Debugf("storing history for profile %s (consisting of %d elements)", id, len(history.History))
-  m.nameHistory[id] = &expirationWrapper{
-    content:   history,
-    createdAt: time.Now(),
-  }
-
+  m.entries.Put(l1Key("history", id.String()), history.Copy(), estimateSize(history.Serialize()), m.cfg.GetTtl().NameHistory)
   return nil
 }
 
 func (m *MemoryStorageBackend) PurgeNameHistory(id uuid.UUID) error {
-  m.clearExpiredEntries()
-
   m.logger.Debugf("purging history for profile %s", id)
-  delete(m.nameHistory, id)
+  m.entries.Remove(l1Key("history", id.String()))
   return nil
 }
 
 func (m *MemoryStorageBackend) GetProfile(id uuid.UUID) (*entity.Profile, error) {
-  m.clearExpiredEntries()
-
-  exp := m.profile[id]
-  if exp == nil {
+  cached, ok := m.entries.Get(l1Key("profile", id.String()))
+  if !ok {
     return nil, nil
   }
 
-  return exp.content.(*entity.Profile), nil
+  return cached.(*entity.Profile).Copy(), nil
 }
 
 func (m *MemoryStorageBackend) PutProfile(profile *entity.Profile) error {
-  m.clearExpiredEntries()
-
   m.logger.Debugf("storing profile %s", profile.Id)
-  m.profile[profile.Id] = &expirationWrapper{
-    content:   profile,
-    createdAt: time.Now(),
-  }
-
+  m.entries.Put(l1Key("profile", profile.Id.String()), profile.Copy(), estimateSize(profile.Serialize()), m.cfg.GetTtl().Profile)
   return nil
 }
 
 func (m *MemoryStorageBackend) PurgeProfile(id uuid.UUID) error {
-  m.clearExpiredEntries()
-
   m.logger.Debugf("purging profile %s", id)
-  delete(m.profile, id)
+  m.entries.Remove(l1Key("profile", id.String()))
   return nil
 }
 
 func (m *MemoryStorageBackend) GetBlacklist() (*entity.Blacklist, error) {
-  if m.blacklist == nil {
+  cached, ok := m.entries.Get(l1Key("misc", "blacklist"))
+  if !ok {
     return nil, nil
   }
 
-  return m.blacklist.content.(*entity.Blacklist), nil
+  return cached.(*entity.Blacklist).Copy(), nil
 }
 
 func (m *MemoryStorageBackend) PutBlacklist(blacklist *entity.Blacklist) error {
-  m.blacklist = &expirationWrapper{
-    content:   blacklist,
-    createdAt: time.Now(),
-  }
+  m.entries.Put(l1Key("misc", "blacklist"), blacklist.Copy(), estimateSize(blacklist.Serialize()), m.cfg.GetTtl().Blacklist)
   return nil
 }
 
 func (m *MemoryStorageBackend) PurgeBlacklist() error {
   m.logger.Debugf("purging blacklist")
-  m.blacklist = nil
+  m.entries.Remove(l1Key("misc", "blacklist"))
   return nil
 }
 
-// clears all expired entries from the database
-func (m *MemoryStorageBackend) clearExpiredEntries() { // TODO: run on a timer instead?
-  m.logger.Debug("purging expired data")
-
-  deletedProfileIds := 0
-  deletedNameHistories := 0
-  deletedProfiles := 0
-  deletedBlacklists := 0
-
-  for profileId, mappings := range m.profileId {
-    for i := 0; i < len(mappings); {
-      exp := mappings[i]
-
-      if !exp.isValid(m.cfg.Ttl.Name) {
-        deletedProfileIds++
-
-        if len(mappings) == 1 {
-          delete(m.profileId, profileId)
-          continue
-        }
+func (m *MemoryStorageBackend) GetNameTimeline(name string) (*entity.NameTimeline, error) {
+  cached, ok := m.entries.Get(l1Key("timeline", strings.ToLower(name)))
+  if !ok {
+    return nil, nil
+  }
 
-        mappings = append(mappings[:i], mappings[i+1:]...)
-        continue
-      }
+  return cached.(*entity.NameTimeline).Copy(), nil
+}
 
-      i++
-    }
-  }
+func (m *MemoryStorageBackend) PutNameTimeline(timeline *entity.NameTimeline) error {
+  m.logger.Debugf("storing timeline for name \"%s\" (consisting of %d periods)", timeline.Name, len(timeline.Periods))
+  m.entries.Put(l1Key("timeline", strings.ToLower(timeline.Name)), timeline.Copy(), estimateSize(timeline.Serialize()), m.cfg.GetTtl().Name)
+  return nil
+}
 
-  for key, history := range m.nameHistory {
-    if !history.isValid(m.cfg.Ttl.NameHistory) {
-      deletedNameHistories++
-      delete(m.nameHistory, key)
-    }
-  }
+func (m *MemoryStorageBackend) PurgeNameTimeline(name string) error {
+  m.logger.Debugf("purging timeline for name \"%s\"", name)
+  m.entries.Remove(l1Key("timeline", strings.ToLower(name)))
+  return nil
+}
 
-  for key, profile := range m.profile {
-    if !profile.isValid(m.cfg.Ttl.Profile) {
-      deletedProfiles++
-      delete(m.profile, key)
+// periodically clears all expired entries from the database until the backend is closed
+func (m *MemoryStorageBackend) clearExpiredEntries() {
+  for {
+    select {
+    case <-m.done:
+      return
+    case <-m.janitorTicker.C:
+      removed := m.entries.RemoveExpired()
+      m.logger.Debugf("removed %d expired entries from memory", removed)
     }
   }
-
-  if m.blacklist != nil && m.blacklist.isValid(m.cfg.Ttl.Blacklist) {
-    deletedBlacklists = 1
-    m.blacklist = nil
-  }
-
-  m.logger.Debugf("removed %d profile Ids, %d name histories, %d profiles and %d blacklists from memory", deletedProfileIds, deletedNameHistories, deletedProfiles, deletedBlacklists)
 }
@@ -0,0 +1,589 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package storage
+
+import (
+  "errors"
+  "fmt"
+  "sync"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/google/uuid"
+  "github.com/hashicorp/hcl2/gohcl"
+  "github.com/op/go-logging"
+)
+
+// indicates that a background copy has been interrupted due to the backend shutting down
+var errMigrationAborted = errors.New("migration has been aborted")
+
+// identifies the categories which are copied when both backends retain the time at which their
+// entries have been written
+var migratedCategories = []struct {
+  category string
+  kind     string
+}{
+  {"name", "name associations"},
+  {"history", "name histories"},
+  {"profile", "profiles"},
+  {"misc", "blacklist"},
+  {"timeline", "timelines"},
+}
+
+// provides a meta storage backend which gradually moves all data from a source backend into a
+// target backend
+//
+// reads are served from the target backend first and fall back to the source backend (copying
+// the result into the target backend) while writes and purges are applied to both backends in
+// order to permit switching back to the source backend until the migration has been completed
+type MigratingStorageBackend struct {
+  logger *logging.Logger
+  source StorageBackend
+  target StorageBackend
+
+  done      chan struct{}
+  closeOnce sync.Once
+  wg        sync.WaitGroup
+}
+
+type MigratingStorageBackendCfg struct {
+  Source         *server.StorageConfig `hcl:"source,block"`
+  Target         *server.StorageConfig `hcl:"target,block"`
+  BackgroundCopy *bool                 `hcl:"background-copy,attr"`
+}
+
+// creates a factory for migrating storage backends which resolves its source and target backends
+// using the passed lookup function
+func NewMigratingStorageBackendFactory(lookup FactoryLookup) Factory {
+  return func(cfg *server.Config) (StorageBackend, error) {
+    migratingCfg := &MigratingStorageBackendCfg{}
+    diag := gohcl.DecodeBody(cfg.Storage.Parameters, server.EvalContext(), migratingCfg)
+    if diag.HasErrors() {
+      return nil, fmt.Errorf("illegal backend configuration: %s", diag.Error())
+    }
+
+    source, err := createDelegateBackend(cfg, lookup, "source", migratingCfg.Source)
+    if err != nil {
+      return nil, err
+    }
+    target, err := createDelegateBackend(cfg, lookup, "target", migratingCfg.Target)
+    if err != nil {
+      source.Close()
+      return nil, err
+    }
+
+    backend := &MigratingStorageBackend{
+      logger: logging.MustGetLogger("migration"),
+      source: source,
+      target: target,
+      done:   make(chan struct{}),
+    }
+
+    if migratingCfg.BackgroundCopy == nil || *migratingCfg.BackgroundCopy {
+      backend.wg.Add(1)
+      go backend.copyAll()
+    }
+    return backend, nil
+  }
+}
+
+// constructs a backend which is referenced by a meta backend
+func createDelegateBackend(cfg *server.Config, lookup FactoryLookup, role string, storageCfg *server.StorageConfig) (StorageBackend, error) {
+  if storageCfg == nil {
+    return nil, fmt.Errorf("illegal backend configuration: missing %s backend", role)
+  }
+
+  factory := lookup(storageCfg.Type)
+  if factory == nil {
+    return nil, fmt.Errorf("illegal backend configuration: no such %s backend: %s", role, storageCfg.Type)
+  }
+
+  backend, err := factory(cfg.WithStorage(storageCfg))
+  if err != nil {
+    return nil, fmt.Errorf("failed to initialize %s backend \"%s\": %s", role, storageCfg.Type, err)
+  }
+  return backend, nil
+}
+
+// copies all entries which are present within the source backend to the target backend
+func (m *MigratingStorageBackend) copyAll() {
+  defer m.wg.Done()
+
+  source, ok := m.source.(IterableStorageBackend)
+  if !ok {
+    m.logger.Warningf("source backend does not support iteration - entries will only be migrated when accessed")
+    return
+  }
+
+  m.logger.Infof("beginning background migration")
+  start := time.Now()
+
+  // entries are copied along with the time at which they have been written where both backends
+  // support it as they would otherwise be considered fresh within the target backend
+  timestampedSource, sourceOk := m.source.(TimestampedStorageBackend)
+  timestampedTarget, targetOk := m.target.(TimestampedStorageBackend)
+  if sourceOk && targetOk {
+    err := m.copyCacheEntries(timestampedSource, timestampedTarget, start)
+    if err != ErrTimestampUnsupported {
+      return
+    }
+  }
+  m.logger.Warningf("backends do not retain the time at which entries have been written - migrated entries will not expire before the ttl elapses again")
+
+  profileIds := 0
+  err := source.ForEachProfileId(func(profileId *entity.ProfileId) error {
+    if m.isClosed() {
+      return errMigrationAborted
+    }
+
+    profileIds++
+    return m.target.PutProfileId(profileId)
+  })
+  if !m.checkCopyResult("name associations", err) {
+    return
+  }
+
+  histories := 0
+  err = source.ForEachNameHistory(func(id uuid.UUID, history *entity.NameChangeHistory) error {
+    if m.isClosed() {
+      return errMigrationAborted
+    }
+
+    existing, err := m.target.GetNameHistory(id)
+    if err != nil || existing != nil {
+      return err
+    }
+
+    histories++
+    return m.target.PutNameHistory(id, history)
+  })
+  if !m.checkCopyResult("name histories", err) {
+    return
+  }
+
+  profiles := 0
+  err = source.ForEachProfile(func(profile *entity.Profile) error {
+    if m.isClosed() {
+      return errMigrationAborted
+    }
+
+    existing, err := m.target.GetProfile(profile.Id)
+    if err != nil || existing != nil {
+      return err
+    }
+
+    profiles++
+    return m.target.PutProfile(profile)
+  })
+  if !m.checkCopyResult("profiles", err) {
+    return
+  }
+
+  blacklist, err := m.target.GetBlacklist()
+  if err == nil && blacklist == nil {
+    blacklist, err = m.source.GetBlacklist()
+    if err == nil && blacklist != nil {
+      err = m.target.PutBlacklist(blacklist)
+    }
+  }
+  if !m.checkCopyResult("blacklist", err) {
+    return
+  }
+
+  // timelines are only copied when both backends support them
+  timelines := 0
+  sourceTimelines, sourceOk := m.source.(IterableTimelineStorageBackend)
+  targetTimelines, targetOk := m.target.(TimelineStorageBackend)
+  if sourceOk && targetOk {
+    err = sourceTimelines.ForEachNameTimeline(func(timeline *entity.NameTimeline) error {
+      if m.isClosed() {
+        return errMigrationAborted
+      }
+
+      existing, err := targetTimelines.GetNameTimeline(timeline.Name)
+      if err != nil || existing != nil {
+        return err
+      }
+
+      timelines++
+      return targetTimelines.PutNameTimeline(timeline)
+    })
+    if !m.checkCopyResult("timelines", err) {
+      return
+    }
+  }
+
+  m.logger.Infof("background migration completed in %s: copied %d name associations, %d name histories, %d profiles and %d timelines", time.Since(start), profileIds, histories, profiles, timelines)
+}
+
+// copies all entries along with the time at which they have been written
+// ErrTimestampUnsupported is returned before any entry is copied when either backend is unable to
+// retain these times
+func (m *MigratingStorageBackend) copyCacheEntries(source TimestampedStorageBackend, target TimestampedStorageBackend, start time.Time) error {
+  copied := make(map[string]int)
+  for _, migrated := range migratedCategories {
+    category := migrated.category
+    err := source.ForEachCacheEntry(category, func(entry *CacheEntry) error {
+      if m.isClosed() {
+        return errMigrationAborted
+      }
+
+      existing, err := target.GetCacheEntry(entry.Category, entry.Key)
+      if err != nil || existing != nil {
+        return err
+      }
+
+      copied[category]++
+      return target.PutCacheEntry(entry)
+    })
+    if err == ErrTimestampUnsupported {
+      return err
+    }
+    if !m.checkCopyResult(migrated.kind, err) {
+      return err
+    }
+  }
+
+  m.logger.Infof("background migration completed in %s: copied %d name associations, %d name histories, %d profiles and %d timelines", time.Since(start), copied["name"], copied["history"], copied["profile"], copied["timeline"])
+  return nil
+}
+
+// copies a single entry along with the time at which it has been written where both backends
+// support it and falls back to the passed function (which stores the entry as a fresh entry)
+// otherwise
+func (m *MigratingStorageBackend) migrateEntry(category string, key string, put func() error) error {
+  source, sourceOk := m.source.(TimestampedStorageBackend)
+  target, targetOk := m.target.(TimestampedStorageBackend)
+  if !sourceOk || !targetOk {
+    return put()
+  }
+
+  // entries which are already partially present within the target backend (e.g. associations
+  // which are not valid at the requested time) are merged instead
+  existing, err := target.GetCacheEntry(category, key)
+  if err == ErrTimestampUnsupported || existing != nil {
+    return put()
+  }
+  if err != nil {
+    return err
+  }
+
+  entry, err := source.GetCacheEntry(category, key)
+  if err == ErrTimestampUnsupported {
+    return put()
+  }
+  if err != nil || entry == nil {
+    return err
+  }
+  return target.PutCacheEntry(entry)
+}
+
+// evaluates whether a background copy step has completed successfully and logs its failure
+// otherwise
+func (m *MigratingStorageBackend) checkCopyResult(kind string, err error) bool {
+  switch err {
+  case nil:
+    return true
+  case errMigrationAborted:
+    m.logger.Warningf("background migration has been interrupted while copying %s", kind)
+  case ErrIterationUnsupported:
+    m.logger.Warningf("source backend does not support iteration - entries will only be migrated when accessed")
+  case ErrTimelineUnsupported:
+    m.logger.Warningf("backends do not support timelines - timelines will not be migrated")
+  default:
+    m.logger.Errorf("background migration of %s has failed: %s", kind, err)
+  }
+  return false
+}
+
+// evaluates whether the backend has been closed
+func (m *MigratingStorageBackend) isClosed() bool {
+  select {
+  case <-m.done:
+    return true
+  default:
+    return false
+  }
+}
+
+func (m *MigratingStorageBackend) Ping() error {
+  err := Ping(m.source)
+  if err != nil {
+    return fmt.Errorf("source backend is unavailable: %s", err)
+  }
+
+  err = Ping(m.target)
+  if err != nil {
+    return fmt.Errorf("target backend is unavailable: %s", err)
+  }
+  return nil
+}
+
+// interrupts the background migration and closes the source and target backends
+// subsequent invocations have no effect as the backend may be closed by both the cache and a
+// wrapping meta backend
+func (m *MigratingStorageBackend) Close() (err error) {
+  m.closeOnce.Do(func() {
+    close(m.done)
+    m.wg.Wait()
+
+    sourceErr := m.source.Close()
+    targetErr := m.target.Close()
+    err = targetErr
+    if sourceErr != nil {
+      err = sourceErr
+    }
+  })
+  return err
+}
+
+func (m *MigratingStorageBackend) GetProfileId(name string, at time.Time) (*entity.ProfileId, error) {
+  profileId, targetErr := m.target.GetProfileId(name, at)
+  if targetErr != nil {
+    m.logger.Errorf("target backend responded with error: %s", targetErr)
+  }
+  if profileId != nil {
+    return profileId, nil
+  }
+
+  profileId, err := m.source.GetProfileId(name, at)
+  if err != nil {
+    return nil, err
+  }
+  if profileId == nil {
+    return nil, targetErr
+  }
+
+  m.logger.Debugf("migrating association for name \"%s\" on access", name)
+  err = m.migrateEntry("name", calculateHash(name), func() error {
+    return m.target.PutProfileId(profileId)
+  })
+  if err != nil {
+    m.logger.Errorf("failed to migrate association for name \"%s\": %s", name, err)
+  }
+  return profileId, nil
+}
+
+func (m *MigratingStorageBackend) PutProfileId(profileId *entity.ProfileId) error {
+  err := m.source.PutProfileId(profileId)
+  if err != nil {
+    return err
+  }
+  return m.target.PutProfileId(profileId)
+}
+
+func (m *MigratingStorageBackend) PurgeProfileId(name string, at time.Time) error {
+  err := m.source.PurgeProfileId(name, at)
+  if err != nil {
+    return err
+  }
+  return m.target.PurgeProfileId(name, at)
+}
+
+func (m *MigratingStorageBackend) GetNameHistory(id uuid.UUID) (*entity.NameChangeHistory, error) {
+  history, targetErr := m.target.GetNameHistory(id)
+  if targetErr != nil {
+    m.logger.Errorf("target backend responded with error: %s", targetErr)
+  }
+  if history != nil {
+    return history, nil
+  }
+
+  history, err := m.source.GetNameHistory(id)
+  if err != nil {
+    return nil, err
+  }
+  if history == nil {
+    return nil, targetErr
+  }
+
+  m.logger.Debugf("migrating name history of profile %s on access", id)
+  err = m.migrateEntry("history", id.String(), func() error {
+    return m.target.PutNameHistory(id, history)
+  })
+  if err != nil {
+    m.logger.Errorf("failed to migrate name history of profile %s: %s", id, err)
+  }
+  return history, nil
+}
+
+func (m *MigratingStorageBackend) PutNameHistory(id uuid.UUID, history *entity.NameChangeHistory) error {
+  err := m.source.PutNameHistory(id, history)
+  if err != nil {
+    return err
+  }
+  return m.target.PutNameHistory(id, history)
+}
+
+func (m *MigratingStorageBackend) PurgeNameHistory(id uuid.UUID) error {
+  err := m.source.PurgeNameHistory(id)
+  if err != nil {
+    return err
+  }
+  return m.target.PurgeNameHistory(id)
+}
+
+func (m *MigratingStorageBackend) GetProfile(id uuid.UUID) (*entity.Profile, error) {
+  profile, targetErr := m.target.GetProfile(id)
+  if targetErr != nil {
+    m.logger.Errorf("target backend responded with error: %s", targetErr)
+  }
+  if profile != nil {
+    return profile, nil
+  }
+
+  profile, err := m.source.GetProfile(id)
+  if err != nil {
+    return nil, err
+  }
+  if profile == nil {
+    return nil, targetErr
+  }
+
+  m.logger.Debugf("migrating profile %s on access", id)
+  err = m.migrateEntry("profile", id.String(), func() error {
+    return m.target.PutProfile(profile)
+  })
+  if err != nil {
+    m.logger.Errorf("failed to migrate profile %s: %s", id, err)
+  }
+  return profile, nil
+}
+
+func (m *MigratingStorageBackend) PutProfile(profile *entity.Profile) error {
+  err := m.source.PutProfile(profile)
+  if err != nil {
+    return err
+  }
+  return m.target.PutProfile(profile)
+}
+
+func (m *MigratingStorageBackend) PurgeProfile(id uuid.UUID) error {
+  err := m.source.PurgeProfile(id)
+  if err != nil {
+    return err
+  }
+  return m.target.PurgeProfile(id)
+}
+
+func (m *MigratingStorageBackend) GetBlacklist() (*entity.Blacklist, error) {
+  blacklist, targetErr := m.target.GetBlacklist()
+  if targetErr != nil {
+    m.logger.Errorf("target backend responded with error: %s", targetErr)
+  }
+  if blacklist != nil {
+    return blacklist, nil
+  }
+
+  blacklist, err := m.source.GetBlacklist()
+  if err != nil {
+    return nil, err
+  }
+  if blacklist == nil {
+    return nil, targetErr
+  }
+
+  err = m.migrateEntry("misc", "blacklist", func() error {
+    return m.target.PutBlacklist(blacklist)
+  })
+  if err != nil {
+    m.logger.Errorf("failed to migrate blacklist: %s", err)
+  }
+  return blacklist, nil
+}
+
+func (m *MigratingStorageBackend) PutBlacklist(blacklist *entity.Blacklist) error {
+  err := m.source.PutBlacklist(blacklist)
+  if err != nil {
+    return err
+  }
+  return m.target.PutBlacklist(blacklist)
+}
+
+func (m *MigratingStorageBackend) PurgeBlacklist() error {
+  err := m.source.PurgeBlacklist()
+  if err != nil {
+    return err
+  }
+  return m.target.PurgeBlacklist()
+}
+
+// timelines are only retained when the target backend supports them while the source backend is
+// consulted and updated whenever it supports them as well
+func (m *MigratingStorageBackend) GetNameTimeline(name string) (*entity.NameTimeline, error) {
+  target, ok := m.target.(TimelineStorageBackend)
+  if !ok {
+    return nil, ErrTimelineUnsupported
+  }
+
+  timeline, targetErr := target.GetNameTimeline(name)
+  if targetErr != nil {
+    m.logger.Errorf("target backend responded with error: %s", targetErr)
+  }
+  source, ok := m.source.(TimelineStorageBackend)
+  if timeline != nil || !ok {
+    return timeline, targetErr
+  }
+
+  timeline, err := source.GetNameTimeline(name)
+  if err != nil {
+    return nil, err
+  }
+  if timeline == nil {
+    return nil, targetErr
+  }
+
+  m.logger.Debugf("migrating timeline of name \"%s\" on access", name)
+  err = m.migrateEntry("timeline", calculateHash(name), func() error {
+    return target.PutNameTimeline(timeline)
+  })
+  if err != nil {
+    m.logger.Errorf("failed to migrate timeline of name \"%s\": %s", name, err)
+  }
+  return timeline, nil
+}
+
+func (m *MigratingStorageBackend) PutNameTimeline(timeline *entity.NameTimeline) error {
+  target, ok := m.target.(TimelineStorageBackend)
+  if !ok {
+    return ErrTimelineUnsupported
+  }
+
+  if source, ok := m.source.(TimelineStorageBackend); ok {
+    err := source.PutNameTimeline(timeline)
+    if err != nil {
+      return err
+    }
+  }
+  return target.PutNameTimeline(timeline)
+}
+
+func (m *MigratingStorageBackend) PurgeNameTimeline(name string) error {
+  target, ok := m.target.(TimelineStorageBackend)
+  if !ok {
+    return ErrTimelineUnsupported
+  }
+
+  if source, ok := m.source.(TimelineStorageBackend); ok {
+    err := source.PurgeNameTimeline(name)
+    if err != nil {
+      return err
+    }
+  }
+  return target.PurgeNameTimeline(name)
+}
@@ -0,0 +1,775 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package storage_test
+
+import (
+  "encoding/binary"
+  "fmt"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  bolt "github.com/coreos/bbolt"
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/dotStart/Stockpile/stockpile/storage/storagetest"
+  "github.com/google/uuid"
+)
+
+// resolves the built-in backends which may be referenced by meta backends
+func lookup(id string) storage.Factory {
+  switch id {
+  case "mem":
+    return storage.NewMemoryStorageBackend
+  case "file":
+    return storage.NewFileStorageBackend
+  case "bolt":
+    return storage.NewBoltStorageBackend
+  case "migrating":
+    return storage.NewMigratingStorageBackendFactory(lookup)
+  case "tiered":
+    return storage.NewTieredStorageBackendFactory(lookup)
+  }
+  return nil
+}
+
+// executes the storage conformance suite against a given storage configuration
+// all occurrences of {dir} within the configuration are replaced with a temporary directory which
+// is removed once the test completes
+func testBackend(t *testing.T, src string) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  cfg := f.Load(src)
+  storagetest.Test(t, cfg, lookup(cfg.Storage.Type), storagetest.Options{
+    SkipExpiry: testing.Short(),
+  })
+}
+
+func TestMemoryStorageBackend(t *testing.T) {
+  testBackend(t, "storage \"mem\" {}\n")
+}
+
+func TestMemoryStorageBackendCopy(t *testing.T) {
+  backend, err := storage.NewMemoryStorageBackend(servertest.LoadConfig(t, "storage \"mem\" {}\n"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer backend.Close()
+
+  id := uuid.New()
+  profileId := &entity.ProfileId{
+    Id:          id,
+    Name:        "Notch",
+    FirstSeenAt: time.Unix(0, 0),
+    LastSeenAt:  time.Now(),
+    ValidUntil:  time.Now().Add(time.Hour),
+  }
+  err = backend.PutProfileId(profileId)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // modifications to the passed instance must not be visible to subsequent readers
+  profileId.Id = uuid.New()
+
+  stored, err := backend.GetProfileId("notch", time.Now())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if stored == nil || stored.Id != id {
+    t.Fatal("expected stored association to remain unchanged")
+  }
+
+  // neither are modifications to previously retrieved instances
+  stored.Id = uuid.New()
+  stored, err = backend.GetProfileId("notch", time.Now())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if stored == nil || stored.Id != id {
+    t.Fatal("expected retrieved association to be a copy")
+  }
+
+  history := &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "Notch", ChangedToAt: time.Unix(0, 0)},
+  }}
+  err = backend.PutNameHistory(id, history)
+  if err != nil {
+    t.Fatal(err)
+  }
+  history.History[0].Name = "jeb_"
+  storedHistory, err := backend.GetNameHistory(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if storedHistory == nil || storedHistory.History[0].Name != "Notch" {
+    t.Fatal("expected stored history to remain unchanged")
+  }
+  storedHistory.History[0].Name = "jeb_"
+  storedHistory.History = append(storedHistory.History, &entity.NameChange{Name: "Dinnerbone"})
+  storedHistory, err = backend.GetNameHistory(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(storedHistory.History) != 1 || storedHistory.History[0].Name != "Notch" {
+    t.Fatal("expected retrieved history to be a copy")
+  }
+
+  profile := &entity.Profile{
+    Id:   id,
+    Name: "Notch",
+    Properties: map[string]*entity.ProfileProperty{
+      "textures": {Name: "textures", Value: "original"},
+    },
+    Textures: &entity.ProfileTextures{
+      ProfileId:   id,
+      ProfileName: "Notch",
+      Textures:    map[string]string{"SKIN": "original"},
+    },
+  }
+  err = backend.PutProfile(profile)
+  if err != nil {
+    t.Fatal(err)
+  }
+  profile.Properties["textures"].Value = "modified"
+  profile.Textures.Textures["SKIN"] = "modified"
+  storedProfile, err := backend.GetProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if storedProfile == nil || storedProfile.Properties["textures"].Value != "original" || storedProfile.Textures.Textures["SKIN"] != "original" {
+    t.Fatal("expected stored profile to remain unchanged")
+  }
+  storedProfile.Name = "jeb_"
+  storedProfile.Properties["textures"].Value = "modified"
+  storedProfile.Textures.Textures["SKIN"] = "modified"
+  storedProfile, err = backend.GetProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if storedProfile.Name != "Notch" || storedProfile.Properties["textures"].Value != "original" || storedProfile.Textures.Textures["SKIN"] != "original" {
+    t.Fatal("expected retrieved profile to be a copy")
+  }
+
+  hash := strings.Repeat("a", 40)
+  blacklist := &entity.Blacklist{Hashes: []string{hash}}
+  err = backend.PutBlacklist(blacklist)
+  if err != nil {
+    t.Fatal(err)
+  }
+  blacklist.Hashes[0] = strings.Repeat("b", 40)
+  storedBlacklist, err := backend.GetBlacklist()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if storedBlacklist == nil || storedBlacklist.Hashes[0] != hash {
+    t.Fatal("expected stored blacklist to remain unchanged")
+  }
+  storedBlacklist.Hashes[0] = strings.Repeat("b", 40)
+  storedBlacklist, err = backend.GetBlacklist()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if storedBlacklist.Hashes[0] != hash {
+    t.Fatal("expected retrieved blacklist to be a copy")
+  }
+
+  timelines := backend.(storage.TimelineStorageBackend)
+  timeline := entity.NewNameTimeline("Notch")
+  timeline.Update(id, &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "Notch", ChangedToAt: time.Unix(0, 0)},
+  }}, time.Now())
+  err = timelines.PutNameTimeline(timeline)
+  if err != nil {
+    t.Fatal(err)
+  }
+  timeline.Periods[0].Id = uuid.New()
+  storedTimeline, err := timelines.GetNameTimeline("notch")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if storedTimeline == nil || len(storedTimeline.Periods) != 1 || storedTimeline.Periods[0].Id != id {
+    t.Fatal("expected stored timeline to remain unchanged")
+  }
+
+  // timelines are updated in place during their reconciliation within the cache
+  storedTimeline.Update(uuid.New(), &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "Notch", ChangedToAt: time.Now().Add(-time.Hour)},
+  }}, time.Now())
+  storedTimeline, err = timelines.GetNameTimeline("notch")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(storedTimeline.Periods) != 1 || storedTimeline.Periods[0].Id != id {
+    t.Fatal("expected retrieved timeline to be a copy")
+  }
+}
+
+func TestMemoryStorageBackendClose(t *testing.T) {
+  backend, err := storage.NewMemoryStorageBackend(servertest.LoadConfig(t, "storage \"mem\" {}\n"))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // backends may be closed by the cache as well as by wrapping meta backends
+  err = backend.Close()
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = backend.Close()
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestFileStorageBackend(t *testing.T) {
+  testBackend(t, `storage "file" {
+    path = "{dir}/file"
+  }`)
+}
+
+func TestBoltStorageBackend(t *testing.T) {
+  testBackend(t, `storage "bolt" {
+    path = "{dir}/stockpile.db"
+  }`)
+}
+
+func TestBoltStorageBackendLock(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  cfg := f.Load(`storage "bolt" {
+    path = "{dir}/stockpile.db"
+    lock-timeout = "100ms"
+    compact-on-start = true
+  }`)
+
+  backend, err := storage.NewBoltStorageBackend(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  id := uuid.New()
+  err = backend.PutProfile(&entity.Profile{Id: id, Name: "compact"})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  _, err = storage.NewBoltStorageBackend(cfg)
+  if err == nil || !strings.Contains(err.Error(), "locked by another instance") {
+    t.Fatalf("expected database to be locked but got: %v", err)
+  }
+  backend.Close()
+
+  // compaction is performed while the lock file is held and must retain all valid entries
+  backend, err = storage.NewBoltStorageBackend(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  profile, err := backend.GetProfile(id)
+  backend.Close()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile == nil {
+    t.Fatal("expected profile to survive compaction")
+  }
+
+  // the format version is retained as well
+  db, err := bolt.Open(filepath.Join(f.Dir, "stockpile.db"), 0600, nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer db.Close()
+  db.View(func(tx *bolt.Tx) error {
+    if meta := tx.Bucket([]byte("meta")); meta == nil || meta.Get([]byte("version")) == nil {
+      t.Fatal("expected format version to survive compaction")
+    }
+    return nil
+  })
+}
+
+func TestBoltStorageBackendTtlReload(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  cfg := f.Load(`storage "bolt" {
+    path = "{dir}/stockpile.db"
+  }`)
+
+  backend, err := storage.NewBoltStorageBackend(cfg)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer backend.Close()
+
+  id := uuid.New()
+  err = backend.PutProfile(&entity.Profile{Id: id, Name: "reload"})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // shortened TTLs also apply to values which have been written before the reload
+  cfg.Apply(servertest.LoadConfig(t, `ttl {
+    profile = "1ms"
+  }`))
+  time.Sleep(10 * time.Millisecond)
+
+  profile, err := backend.GetProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile != nil {
+    t.Fatal("expected profile to expire after its TTL has been shortened")
+  }
+}
+
+func TestBoltStorageBackendFormatVersion(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  path := filepath.Join(f.Dir, "stockpile.db")
+  db, err := bolt.Open(path, 0600, nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = db.Update(func(tx *bolt.Tx) error {
+    meta, err := tx.CreateBucket([]byte("meta"))
+    if err != nil {
+      return err
+    }
+
+    enc := make([]byte, 8)
+    binary.BigEndian.PutUint64(enc, 2)
+    return meta.Put([]byte("version"), enc)
+  })
+  db.Close()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // databases written by later releases are rejected rather than misinterpreted
+  cfg := f.Load(`storage "bolt" {
+    path = "{dir}/stockpile.db"
+  }`)
+  backend, err := storage.NewBoltStorageBackend(cfg)
+  if err == nil {
+    backend.Close()
+    t.Fatal("expected database of an unknown format version to be rejected")
+  }
+  if !strings.Contains(err.Error(), "format version 2") {
+    t.Errorf("expected error to report the unknown format version but got: %s", err)
+  }
+
+  // the lock is released when a database is rejected
+  db, err = bolt.Open(path+".lock", 0600, &bolt.Options{Timeout: time.Second})
+  if err != nil {
+    t.Fatalf("expected lock to be released: %s", err)
+  }
+  db.Close()
+}
+
+func TestTieredStorageBackend(t *testing.T) {
+  testBackend(t, `storage "tiered" {
+    l2 "bolt" {
+      path = "{dir}/stockpile.db"
+    }
+  }`)
+}
+
+// delays the retrieval of profiles in order to permit tests to interleave other operations
+type blockingStorageBackend struct {
+  storage.StorageBackend
+  retrieving chan struct{}
+  release    chan struct{}
+}
+
+func (b *blockingStorageBackend) GetProfile(id uuid.UUID) (*entity.Profile, error) {
+  profile, err := b.StorageBackend.GetProfile(id)
+  if b.retrieving != nil {
+    b.retrieving <- struct{}{}
+    <-b.release
+  }
+  return profile, err
+}
+
+// creates a tiered backend which stores its l2 entries within a blocking memory backend
+func newTieredTestBackend(t *testing.T, f *servertest.Fixture) (*storage.TieredStorageBackend, *blockingStorageBackend) {
+  l2 := &blockingStorageBackend{}
+  backend, err := storage.NewTieredStorageBackendFactory(func(id string) storage.Factory {
+    if id == "mem" {
+      return func(cfg *server.Config) (storage.StorageBackend, error) {
+        mem, err := storage.NewMemoryStorageBackend(cfg)
+        l2.StorageBackend = mem
+        return l2, err
+      }
+    }
+    return lookup(id)
+  })(f.Load(`storage "tiered" {
+    l2 "mem" {}
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return backend.(*storage.TieredStorageBackend), l2
+}
+
+func TestTieredStorageBackendCopy(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  backend, _ := newTieredTestBackend(t, f)
+  defer backend.Close()
+
+  id := uuid.New()
+  err := backend.PutProfile(&entity.Profile{Id: id, Name: "Notch"})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // the first retrieval populates l1 while the second one is served from l1
+  for i := 0; i < 2; i++ {
+    profile, err := backend.GetProfile(id)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if profile == nil || profile.Name != "Notch" {
+      t.Fatalf("expected retrieval %d to return an unmodified profile", i)
+    }
+    profile.Name = "jeb_"
+  }
+
+  err = backend.PutProfileId(&entity.ProfileId{
+    Id:          id,
+    Name:        "Notch",
+    FirstSeenAt: time.Unix(0, 0),
+    LastSeenAt:  time.Now(),
+    ValidUntil:  time.Now().Add(time.Hour),
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  for i := 0; i < 2; i++ {
+    profileId, err := backend.GetProfileId("Notch", time.Now())
+    if err != nil {
+      t.Fatal(err)
+    }
+    if profileId == nil || profileId.Id != id {
+      t.Fatalf("expected retrieval %d to return an unmodified association", i)
+    }
+    profileId.Id = uuid.New()
+  }
+}
+
+func TestTieredStorageBackendInvalidationRace(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  backend, l2 := newTieredTestBackend(t, f)
+  defer backend.Close()
+
+  id := uuid.New()
+  err := backend.PutProfile(&entity.Profile{Id: id, Name: "Notch"})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  l2.retrieving = make(chan struct{})
+  l2.release = make(chan struct{})
+  done := make(chan *entity.Profile)
+  go func() {
+    profile, err := backend.GetProfile(id)
+    if err != nil {
+      t.Error(err)
+    }
+    done <- profile
+  }()
+
+  // the profile is replaced (e.g. by another instance) while the stale value is being retrieved
+  <-l2.retrieving
+  err = l2.StorageBackend.PutProfile(&entity.Profile{Id: id, Name: "jeb_"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  backend.InvalidateProfile(id)
+  close(l2.release)
+
+  stale := <-done
+  if stale == nil || stale.Name != "Notch" {
+    t.Fatal("expected concurrent retrieval to return the previous profile")
+  }
+
+  l2.retrieving = nil
+  profile, err := backend.GetProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile == nil || profile.Name != "jeb_" {
+    t.Fatal("expected stale profile to be discarded instead of being cached within l1")
+  }
+}
+
+func TestMigratingStorageBackend(t *testing.T) {
+  testBackend(t, `storage "migrating" {
+    source "file" {
+      path = "{dir}/file"
+    }
+    target "bolt" {
+      path = "{dir}/stockpile.db"
+    }
+  }`)
+}
+
+func TestMigratingStorageBackendClose(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  backend, err := storage.NewMigratingStorageBackendFactory(lookup)(f.Load(`storage "migrating" {
+    source "file" {
+      path = "{dir}/file"
+    }
+    target "mem" {}
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // closing the backend a second time must neither interrupt the (already stopped) migration
+  // again nor close its delegates twice
+  err = backend.Close()
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = backend.Close()
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestMigratingStorageBackendCopy(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  source, err := storage.NewFileStorageBackend(f.Load(`storage "file" {
+    path = "{dir}/source"
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  timeline := entity.NewNameTimeline("Notch")
+  timeline.Update(uuid.New(), &entity.NameChangeHistory{History: []*entity.NameChange{
+    {Name: "Notch", ChangedToAt: time.Unix(0, 0)},
+  }}, time.Now())
+  err = source.(storage.TimelineStorageBackend).PutNameTimeline(timeline)
+  if err != nil {
+    t.Fatal(err)
+  }
+  source.Close()
+
+  // the target is observed directly as accessing the timeline through the migrating backend would
+  // copy it on access
+  target, err := storage.NewMemoryStorageBackend(f.Load("storage \"mem\" {}\n"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  backend, err := storage.NewMigratingStorageBackendFactory(func(id string) storage.Factory {
+    if id == "mem" {
+      return func(*server.Config) (storage.StorageBackend, error) {
+        return target, nil
+      }
+    }
+    return lookup(id)
+  })(f.Load(`storage "migrating" {
+    source "file" {
+      path = "{dir}/source"
+    }
+    target "mem" {}
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer backend.Close()
+
+  deadline := time.Now().Add(5 * time.Second)
+  for {
+    copied, err := target.(storage.TimelineStorageBackend).GetNameTimeline("notch")
+    if err != nil {
+      t.Fatal(err)
+    }
+    if copied != nil {
+      break
+    }
+    if time.Now().After(deadline) {
+      t.Fatal("expected timeline to be migrated into the target backend")
+    }
+    time.Sleep(10 * time.Millisecond)
+  }
+}
+
+// populates a file backend with a profile which has been written at a given time
+func putBackdatedProfile(t *testing.T, backend storage.StorageBackend, writtenAt time.Time) uuid.UUID {
+  id := uuid.New()
+  err := backend.PutProfile(&entity.Profile{Id: id, Name: "backdated"})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  timestamped := backend.(storage.TimestampedStorageBackend)
+  entry, err := timestamped.GetCacheEntry("profile", id.String())
+  if err != nil {
+    t.Fatal(err)
+  }
+  entry.WrittenAt = writtenAt
+  err = timestamped.PutCacheEntry(entry)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return id
+}
+
+// creates a migrating backend from a file source into a bolt target which is exposed directly
+func newTimestampedMigration(t *testing.T, f *servertest.Fixture, backgroundCopy bool) (storage.StorageBackend, storage.TimestampedStorageBackend) {
+  var target storage.StorageBackend
+  backend, err := storage.NewMigratingStorageBackendFactory(func(id string) storage.Factory {
+    if id == "bolt" {
+      return func(cfg *server.Config) (storage.StorageBackend, error) {
+        var err error
+        target, err = storage.NewBoltStorageBackend(cfg)
+        return target, err
+      }
+    }
+    return lookup(id)
+  })(f.Load(`storage "migrating" {
+    source "file" {
+      path = "{dir}/source"
+    }
+    target "bolt" {
+      path = "{dir}/stockpile.db"
+    }
+    background-copy = ` + fmt.Sprint(backgroundCopy) + `
+  }
+  ttl {
+    profile = "1h"
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return backend, target.(storage.TimestampedStorageBackend)
+}
+
+func TestMigratingStorageBackendCopyRetainsWriteTime(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  source, err := storage.NewFileStorageBackend(f.Load(`storage "file" {
+    path = "{dir}/source"
+  }
+  ttl {
+    profile = "1h"
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  writtenAt := time.Now().Add(-30 * time.Minute).Truncate(time.Second)
+  valid := putBackdatedProfile(t, source, writtenAt)
+  expired := putBackdatedProfile(t, source, time.Now().Add(-2*time.Hour))
+  timeline := entity.NewNameTimeline("Notch")
+  err = source.(storage.TimelineStorageBackend).PutNameTimeline(timeline)
+  if err != nil {
+    t.Fatal(err)
+  }
+  source.Close()
+
+  backend, target := newTimestampedMigration(t, f, true)
+  defer backend.Close()
+
+  // timelines are copied last and thus indicate that all profiles have been processed
+  deadline := time.Now().Add(5 * time.Second)
+  for {
+    copied, err := target.(storage.TimelineStorageBackend).GetNameTimeline("notch")
+    if err != nil {
+      t.Fatal(err)
+    }
+    if copied != nil {
+      break
+    }
+    if time.Now().After(deadline) {
+      t.Fatal("expected timeline to be migrated into the target backend")
+    }
+    time.Sleep(10 * time.Millisecond)
+  }
+
+  entry, err := target.GetCacheEntry("profile", valid.String())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if entry == nil {
+    t.Fatal("expected valid profile to be migrated into the target backend")
+  }
+  if !entry.WrittenAt.Equal(writtenAt) {
+    t.Errorf("expected migrated profile to retain its write time of %s but got %s", writtenAt, entry.WrittenAt)
+  }
+
+  entry, err = target.GetCacheEntry("profile", expired.String())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if entry != nil {
+    t.Error("expected expired profile to be skipped")
+  }
+}
+
+func TestMigratingStorageBackendAccessRetainsWriteTime(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  source, err := storage.NewFileStorageBackend(f.Load(`storage "file" {
+    path = "{dir}/source"
+  }`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  writtenAt := time.Now().Add(-30 * time.Minute).Truncate(time.Second)
+  id := putBackdatedProfile(t, source, writtenAt)
+  source.Close()
+
+  backend, target := newTimestampedMigration(t, f, false)
+  defer backend.Close()
+
+  profile, err := backend.GetProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile == nil {
+    t.Fatal("expected profile to be served from the source backend")
+  }
+
+  entry, err := target.GetCacheEntry("profile", id.String())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if entry == nil {
+    t.Fatal("expected profile to be migrated on access")
+  }
+  if !entry.WrittenAt.Equal(writtenAt) {
+    t.Errorf("expected migrated profile to retain its write time of %s but got %s", writtenAt, entry.WrittenAt)
+  }
+}
@@ -0,0 +1,535 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package storagetest
+
+import (
+  "fmt"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/google/uuid"
+)
+
+// verifies that lookups and purges of unknown entries succeed without results
+func (s *suite) checkMissingEntries() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    name := randomName()
+    id := uuid.New()
+
+    err := expectProfileId(backend, name, currentTime(), nil)
+    if err != nil {
+      return err
+    }
+    history, err := backend.GetNameHistory(id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve name history: %s", err)
+    }
+    if history != nil {
+      return fmt.Errorf("expected no name history for unknown profile %s", id)
+    }
+    profile, err := backend.GetProfile(id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve profile: %s", err)
+    }
+    if profile != nil {
+      return fmt.Errorf("expected no data for unknown profile %s", id)
+    }
+
+    err = backend.PurgeProfileId(name, currentTime())
+    if err != nil {
+      return fmt.Errorf("failed to purge unknown association: %s", err)
+    }
+    err = backend.PurgeNameHistory(id)
+    if err != nil {
+      return fmt.Errorf("failed to purge unknown name history: %s", err)
+    }
+    err = backend.PurgeProfile(id)
+    if err != nil {
+      return fmt.Errorf("failed to purge unknown profile: %s", err)
+    }
+    return nil
+  })
+}
+
+// verifies that associations are retained and resolved regardless of the case of their name
+func (s *suite) checkProfileId() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    now := currentTime()
+    profileId := newProfileId(uuid.New(), randomName(), now)
+
+    err := backend.PutProfileId(profileId)
+    if err != nil {
+      return fmt.Errorf("failed to store association: %s", err)
+    }
+
+    err = expectProfileId(backend, profileId.Name, now, profileId)
+    if err != nil {
+      return err
+    }
+    return expectProfileId(backend, strings.ToUpper(profileId.Name), now, profileId)
+  })
+}
+
+// verifies that associations are only resolved within their validity window
+func (s *suite) checkProfileIdValidity() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    seenAt := currentTime().Add(-time.Hour * 24 * 10)
+    profileId := newProfileId(uuid.New(), randomName(), seenAt)
+
+    err := backend.PutProfileId(profileId)
+    if err != nil {
+      return fmt.Errorf("failed to store association: %s", err)
+    }
+
+    expectations := []struct {
+      at       time.Time
+      expected *entity.ProfileId
+    }{
+      {profileId.FirstSeenAt.Add(-time.Second), nil},
+      {profileId.FirstSeenAt, profileId},
+      {currentTime(), profileId},
+      {profileId.ValidUntil.Add(-time.Second), profileId},
+      {profileId.ValidUntil, nil},
+      {profileId.ValidUntil.Add(time.Hour), nil},
+    }
+    for _, e := range expectations {
+      err = expectProfileId(backend, profileId.Name, e.at, e.expected)
+      if err != nil {
+        return err
+      }
+    }
+    return nil
+  })
+}
+
+// verifies that a name which has been associated with different profiles over time resolves to
+// the respective profile within each validity window
+func (s *suite) checkProfileIdReassignment() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    name := randomName()
+    now := currentTime()
+    previous := newProfileId(uuid.New(), name, now.Add(-time.Hour*24*200))
+    current := newProfileId(uuid.New(), name, now)
+
+    err := backend.PutProfileId(previous)
+    if err != nil {
+      return fmt.Errorf("failed to store previous association: %s", err)
+    }
+    err = backend.PutProfileId(current)
+    if err != nil {
+      return fmt.Errorf("failed to store current association: %s", err)
+    }
+
+    err = expectProfileId(backend, name, previous.FirstSeenAt.Add(time.Hour*24), previous)
+    if err != nil {
+      return err
+    }
+    err = expectProfileId(backend, name, previous.ValidUntil.Add(time.Hour*24), nil)
+    if err != nil {
+      return err
+    }
+    return expectProfileId(backend, name, now, current)
+  })
+}
+
+// verifies that overlapping associations with the same profile are merged into a single
+// association which is extended to the most recent encounter
+func (s *suite) checkProfileIdMerge() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    id := uuid.New()
+    name := randomName()
+    now := currentTime()
+    initial := newProfileId(id, name, now.Add(-time.Hour*24*20))
+    encounter := newProfileId(id, name, now.Add(-time.Hour*24*5))
+    if !initial.IsOverlappingWith(encounter) {
+      return fmt.Errorf("expected associations to overlap")
+    }
+
+    err := backend.PutProfileId(initial)
+    if err != nil {
+      return fmt.Errorf("failed to store initial association: %s", err)
+    }
+    err = backend.PutProfileId(encounter)
+    if err != nil {
+      return fmt.Errorf("failed to store subsequent association: %s", err)
+    }
+
+    merged := *initial
+    merged.UpdateExpiration(encounter.LastSeenAt)
+    err = expectProfileId(backend, name, initial.FirstSeenAt, &merged)
+    if err != nil {
+      return err
+    }
+    err = expectProfileId(backend, name, initial.ValidUntil.Add(time.Hour*24), &merged)
+    if err != nil {
+      return err
+    }
+    return expectProfileId(backend, name, merged.ValidUntil, nil)
+  })
+}
+
+// verifies that purges only remove the association which is valid at the given time
+func (s *suite) checkProfileIdPurge() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    name := randomName()
+    now := currentTime()
+    previous := newProfileId(uuid.New(), name, now.Add(-time.Hour*24*200))
+    current := newProfileId(uuid.New(), name, now)
+
+    err := backend.PutProfileId(previous)
+    if err != nil {
+      return fmt.Errorf("failed to store previous association: %s", err)
+    }
+    err = backend.PutProfileId(current)
+    if err != nil {
+      return fmt.Errorf("failed to store current association: %s", err)
+    }
+
+    err = backend.PurgeProfileId(name, now)
+    if err != nil {
+      return fmt.Errorf("failed to purge current association: %s", err)
+    }
+    err = expectProfileId(backend, name, now, nil)
+    if err != nil {
+      return err
+    }
+    at := previous.FirstSeenAt.Add(time.Hour * 24)
+    err = expectProfileId(backend, name, at, previous)
+    if err != nil {
+      return err
+    }
+
+    err = backend.PurgeProfileId(name, at)
+    if err != nil {
+      return fmt.Errorf("failed to purge previous association: %s", err)
+    }
+    return expectProfileId(backend, name, at, nil)
+  })
+}
+
+// verifies that name histories are retained and purged
+func (s *suite) checkNameHistory() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    id := uuid.New()
+    history := newNameHistory(randomName(), randomName(), currentTime().Add(-time.Hour*24*45))
+
+    err := backend.PutNameHistory(id, history)
+    if err != nil {
+      return fmt.Errorf("failed to store name history: %s", err)
+    }
+    actual, err := backend.GetNameHistory(id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve name history: %s", err)
+    }
+    err = compareNameHistory(history, actual)
+    if err != nil {
+      return err
+    }
+
+    err = backend.PurgeNameHistory(id)
+    if err != nil {
+      return fmt.Errorf("failed to purge name history: %s", err)
+    }
+    actual, err = backend.GetNameHistory(id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve name history: %s", err)
+    }
+    if actual != nil {
+      return fmt.Errorf("expected name history to be purged")
+    }
+    return nil
+  })
+}
+
+// verifies that name timelines are retained and purged regardless of the case of their name
+// backends which do not support timelines pass this check without further verification
+func (s *suite) checkNameTimeline() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    timelines, ok := backend.(storage.TimelineStorageBackend)
+    if !ok {
+      return nil
+    }
+
+    now := currentTime()
+    changedAt := now.Add(-time.Hour * 24 * 45)
+    timeline := entity.NewNameTimeline(randomName())
+    timeline.Update(uuid.New(), newNameHistory(timeline.Name, randomName(), changedAt), now)
+    timeline.Update(uuid.New(), newNameHistory(randomName(), timeline.Name, changedAt.Add(time.Hour*24*40)), now)
+
+    err := timelines.PutNameTimeline(timeline)
+    if err == storage.ErrTimelineUnsupported {
+      return nil
+    }
+    if err != nil {
+      return fmt.Errorf("failed to store timeline: %s", err)
+    }
+    actual, err := timelines.GetNameTimeline(strings.ToUpper(timeline.Name))
+    if err != nil {
+      return fmt.Errorf("failed to retrieve timeline: %s", err)
+    }
+    err = compareNameTimeline(timeline, actual)
+    if err != nil {
+      return err
+    }
+
+    err = timelines.PurgeNameTimeline(timeline.Name)
+    if err != nil {
+      return fmt.Errorf("failed to purge timeline: %s", err)
+    }
+    actual, err = timelines.GetNameTimeline(timeline.Name)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve timeline: %s", err)
+    }
+    if actual != nil {
+      return fmt.Errorf("expected timeline to be purged")
+    }
+    return nil
+  })
+}
+
+// verifies that profiles (including their properties and textures) are retained and purged
+func (s *suite) checkProfile() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    profile := newProfile(uuid.New(), randomName(), currentTime())
+
+    err := backend.PutProfile(profile)
+    if err != nil {
+      return fmt.Errorf("failed to store profile: %s", err)
+    }
+    actual, err := backend.GetProfile(profile.Id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve profile: %s", err)
+    }
+    err = compareProfile(profile, actual)
+    if err != nil {
+      return err
+    }
+
+    err = backend.PurgeProfile(profile.Id)
+    if err != nil {
+      return fmt.Errorf("failed to purge profile: %s", err)
+    }
+    actual, err = backend.GetProfile(profile.Id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve profile: %s", err)
+    }
+    if actual != nil {
+      return fmt.Errorf("expected profile to be purged")
+    }
+    return nil
+  })
+}
+
+// verifies that the blacklist is retained and purged
+func (s *suite) checkBlacklist() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    hashes := []string{randomHash(), randomHash()}
+    blacklist, err := entity.NewBlacklist(append([]string{}, hashes...))
+    if err != nil {
+      return err
+    }
+
+    err = backend.PutBlacklist(blacklist)
+    if err != nil {
+      return fmt.Errorf("failed to store blacklist: %s", err)
+    }
+    actual, err := backend.GetBlacklist()
+    if err != nil {
+      return fmt.Errorf("failed to retrieve blacklist: %s", err)
+    }
+    if actual == nil {
+      return fmt.Errorf("expected blacklist but got none")
+    }
+    if len(actual.Hashes) != len(hashes) {
+      return fmt.Errorf("expected %d hash(es) but got %d", len(hashes), len(actual.Hashes))
+    }
+    for _, hash := range hashes {
+      if !actual.Contains(hash) {
+        return fmt.Errorf("expected blacklist to contain hash %s", hash)
+      }
+    }
+
+    err = backend.PurgeBlacklist()
+    if err != nil {
+      return fmt.Errorf("failed to purge blacklist: %s", err)
+    }
+    actual, err = backend.GetBlacklist()
+    if err != nil {
+      return fmt.Errorf("failed to retrieve blacklist: %s", err)
+    }
+    if actual != nil {
+      return fmt.Errorf("expected blacklist to be purged")
+    }
+    return nil
+  })
+}
+
+// verifies that entries are no longer returned once their TTL has passed
+func (s *suite) checkExpiry() error {
+  return s.with(expiryTtl, func(backend storage.StorageBackend) error {
+    now := currentTime()
+    profileId := newProfileId(uuid.New(), randomName(), now)
+    history := newNameHistory(randomName(), profileId.Name, now.Add(-time.Hour*24*45))
+    profile := newProfile(profileId.Id, profileId.Name, now)
+    blacklist, err := entity.NewBlacklist([]string{randomHash()})
+    if err != nil {
+      return err
+    }
+
+    err = backend.PutProfileId(profileId)
+    if err != nil {
+      return fmt.Errorf("failed to store association: %s", err)
+    }
+    err = backend.PutNameHistory(profileId.Id, history)
+    if err != nil {
+      return fmt.Errorf("failed to store name history: %s", err)
+    }
+    err = backend.PutProfile(profile)
+    if err != nil {
+      return fmt.Errorf("failed to store profile: %s", err)
+    }
+    err = backend.PutBlacklist(blacklist)
+    if err != nil {
+      return fmt.Errorf("failed to store blacklist: %s", err)
+    }
+
+    err = expectProfileId(backend, profileId.Name, now, profileId)
+    if err != nil {
+      return fmt.Errorf("before expiration: %s", err)
+    }
+
+    time.Sleep(expiryTtl + 1500*time.Millisecond)
+
+    err = expectProfileId(backend, profileId.Name, now, nil)
+    if err != nil {
+      return fmt.Errorf("after expiration: %s", err)
+    }
+    actualHistory, err := backend.GetNameHistory(profileId.Id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve name history: %s", err)
+    }
+    if actualHistory != nil {
+      return fmt.Errorf("expected name history to expire")
+    }
+    actualProfile, err := backend.GetProfile(profileId.Id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve profile: %s", err)
+    }
+    if actualProfile != nil {
+      return fmt.Errorf("expected profile to expire")
+    }
+    actualBlacklist, err := backend.GetBlacklist()
+    if err != nil {
+      return fmt.Errorf("failed to retrieve blacklist: %s", err)
+    }
+    if actualBlacklist != nil {
+      return fmt.Errorf("expected blacklist to expire")
+    }
+    return nil
+  })
+}
+
+// verifies that the backend permits simultaneous access from multiple routines
+// every routine maintains its own set of entries while also updating a set of shared entries
+// which must remain readable (e.g. must never be observed in a partially written state)
+func (s *suite) checkConcurrency() error {
+  return s.with(defaultTtl, func(backend storage.StorageBackend) error {
+    now := currentTime()
+    shared := newProfileId(uuid.New(), randomName(), now)
+    sharedHistory := newNameHistory(randomName(), shared.Name, now.Add(-time.Hour*24*45))
+
+    errs := make(chan error, concurrentWorkers)
+    var wg sync.WaitGroup
+    for i := 0; i < concurrentWorkers; i++ {
+      wg.Add(1)
+      go func() {
+        defer wg.Done()
+        errs <- s.runWorker(backend, shared, sharedHistory)
+      }()
+    }
+    wg.Wait()
+    close(errs)
+
+    for err := range errs {
+      if err != nil {
+        return err
+      }
+    }
+
+    err := expectProfileId(backend, shared.Name, now, shared)
+    if err != nil {
+      return err
+    }
+    history, err := backend.GetNameHistory(shared.Id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve shared name history: %s", err)
+    }
+    return compareNameHistory(sharedHistory, history)
+  })
+}
+
+// performs a series of modifications and lookups on behalf of a concurrency check
+func (s *suite) runWorker(backend storage.StorageBackend, shared *entity.ProfileId, sharedHistory *entity.NameChangeHistory) error {
+  for i := 0; i < concurrentIterations; i++ {
+    now := currentTime()
+    profileId := newProfileId(uuid.New(), randomName(), now)
+    profile := newProfile(profileId.Id, profileId.Name, now)
+
+    err := backend.PutProfileId(profileId)
+    if err != nil {
+      return fmt.Errorf("failed to store association: %s", err)
+    }
+    err = backend.PutProfile(profile)
+    if err != nil {
+      return fmt.Errorf("failed to store profile: %s", err)
+    }
+    sharedId := newProfileId(shared.Id, shared.Name, shared.FirstSeenAt)
+    err = backend.PutProfileId(sharedId)
+    if err != nil {
+      return fmt.Errorf("failed to store shared association: %s", err)
+    }
+    err = backend.PutNameHistory(shared.Id, sharedHistory)
+    if err != nil {
+      return fmt.Errorf("failed to store shared name history: %s", err)
+    }
+
+    err = expectProfileId(backend, profileId.Name, now, profileId)
+    if err != nil {
+      return err
+    }
+    actual, err := backend.GetProfile(profile.Id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve profile: %s", err)
+    }
+    err = compareProfile(profile, actual)
+    if err != nil {
+      return err
+    }
+    history, err := backend.GetNameHistory(shared.Id)
+    if err != nil {
+      return fmt.Errorf("failed to retrieve shared name history: %s", err)
+    }
+    err = compareNameHistory(sharedHistory, history)
+    if err != nil {
+      return fmt.Errorf("shared name history: %s", err)
+    }
+  }
+  return nil
+}
@@ -0,0 +1,315 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package storagetest
+
+import (
+  "fmt"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/conformance"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/google/uuid"
+)
+
+// defines the TTL which is applied to all entries while checks are executed (unless they verify
+// expiration)
+const defaultTtl = time.Hour
+
+// defines the TTL which is applied to all entries while expiration is verified
+// backends which store modification times with second granularity (such as the file backend)
+// require the TTL to span at least a full second
+const expiryTtl = 2 * time.Second
+
+// defines the amount of routines which access a backend simultaneously during concurrency checks
+const concurrentWorkers = 8
+
+// defines the amount of operations each routine performs during concurrency checks
+const concurrentIterations = 25
+
+// configures the storage conformance checks
+type Options struct {
+  // skips checks which wait for entries to expire (these take several seconds to complete)
+  SkipExpiry bool
+}
+
+// provides a set of conformance checks for storage backends
+//
+// every check constructs a fresh backend instance using the passed factory and configuration
+// (with the TTLs replaced) and closes it once completed. Checks rely on randomly generated names
+// and profile ids and may thus be executed against backends which contain data but will replace
+// any cached blacklist
+type suite struct {
+  cfg     *server.Config
+  factory storage.Factory
+}
+
+// creates the list of conformance checks for the backend constructed by a given factory
+func Checks(cfg *server.Config, factory storage.Factory, opts Options) []*conformance.Check {
+  s := &suite{
+    cfg:     cfg,
+    factory: factory,
+  }
+
+  checks := []*conformance.Check{
+    {Name: "missing-entries", Run: s.checkMissingEntries},
+    {Name: "profile-id", Run: s.checkProfileId},
+    {Name: "profile-id-validity", Run: s.checkProfileIdValidity},
+    {Name: "profile-id-reassignment", Run: s.checkProfileIdReassignment},
+    {Name: "profile-id-merge", Run: s.checkProfileIdMerge},
+    {Name: "profile-id-purge", Run: s.checkProfileIdPurge},
+    {Name: "name-history", Run: s.checkNameHistory},
+    {Name: "name-timeline", Run: s.checkNameTimeline},
+    {Name: "profile", Run: s.checkProfile},
+    {Name: "blacklist", Run: s.checkBlacklist},
+  }
+  if !opts.SkipExpiry {
+    checks = append(checks, &conformance.Check{Name: "expiry", Run: s.checkExpiry})
+  }
+  return append(checks, &conformance.Check{Name: "concurrency", Run: s.checkConcurrency})
+}
+
+// executes all conformance checks against the backend constructed by a given factory
+func Run(cfg *server.Config, factory storage.Factory, opts Options) []*conformance.Result {
+  return conformance.Run(Checks(cfg, factory, opts))
+}
+
+// executes all conformance checks against the backend constructed by a given factory as subtests
+// of a go test
+func Test(t *testing.T, cfg *server.Config, factory storage.Factory, opts Options) {
+  conformance.Test(t, Checks(cfg, factory, opts))
+}
+
+// constructs a new backend instance which applies a given TTL to all entries
+func (s *suite) open(ttl time.Duration) (storage.StorageBackend, error) {
+  cfg := *s.cfg
+  cfg.Ttl = &server.TtlConfig{
+    Name:        ttl,
+    NameHistory: ttl,
+    Profile:     ttl,
+    Blacklist:   ttl,
+  }
+
+  backend, err := s.factory(&cfg)
+  if err != nil {
+    return nil, fmt.Errorf("failed to initialize backend: %s", err)
+  }
+  return backend, nil
+}
+
+// executes a function against a fresh backend instance
+func (s *suite) with(ttl time.Duration, fn func(backend storage.StorageBackend) error) error {
+  backend, err := s.open(ttl)
+  if err != nil {
+    return err
+  }
+
+  err = fn(backend)
+  closeErr := backend.Close()
+  if err == nil && closeErr != nil {
+    err = fmt.Errorf("failed to close backend: %s", closeErr)
+  }
+  return err
+}
+
+// generates a random (but valid) player name
+func randomName() string {
+  return "sp" + strings.Replace(uuid.New().String(), "-", "", -1)[:12]
+}
+
+// generates a random SHA-1 hash
+func randomHash() string {
+  return strings.Replace(uuid.New().String()+uuid.New().String(), "-", "", -1)[:40]
+}
+
+// retrieves the current time with second precision (as retained by most backends)
+func currentTime() time.Time {
+  return time.Now().Truncate(time.Second)
+}
+
+// creates an association which has been discovered at a given time
+func newProfileId(id uuid.UUID, name string, at time.Time) *entity.ProfileId {
+  profileId := &entity.ProfileId{
+    Id:   id,
+    Name: name,
+  }
+  profileId.UpdateDiscovery(at)
+  return profileId
+}
+
+// creates a name history which consists of an initial name and a single name change
+func newNameHistory(initial string, changed string, changedAt time.Time) *entity.NameChangeHistory {
+  return &entity.NameChangeHistory{
+    History: []*entity.NameChange{
+      {
+        Name:       initial,
+        ValidUntil: entity.CalculateNameGracePeriodEnd(changedAt),
+      },
+      {
+        Name:        changed,
+        ChangedToAt: changedAt,
+        ValidUntil:  entity.CalculateNameGracePeriodEnd(changedAt),
+      },
+    },
+  }
+}
+
+// creates a profile with a textures property
+func newProfile(id uuid.UUID, name string, at time.Time) *entity.Profile {
+  return &entity.Profile{
+    Id:   id,
+    Name: name,
+    Properties: map[string]*entity.ProfileProperty{
+      "textures": {
+        Name:      "textures",
+        Value:     randomHash(),
+        Signature: randomHash(),
+      },
+    },
+    Textures: &entity.ProfileTextures{
+      Timestamp:   at,
+      ProfileId:   id,
+      ProfileName: name,
+      Textures: map[string]string{
+        "SKIN": "http://textures.minecraft.net/texture/" + randomHash(),
+      },
+    },
+  }
+}
+
+// verifies that the backend resolves a name to the expected association at a given time
+// timestamps are compared with second precision as most backends do not retain fractions
+func expectProfileId(backend storage.StorageBackend, name string, at time.Time, expected *entity.ProfileId) error {
+  actual, err := backend.GetProfileId(name, at)
+  if err != nil {
+    return fmt.Errorf("failed to retrieve association of \"%s\" at %s: %s", name, at, err)
+  }
+
+  if expected == nil {
+    if actual != nil {
+      return fmt.Errorf("expected no association of \"%s\" at %s but got profile %s", name, at, actual.Id)
+    }
+    return nil
+  }
+
+  if actual == nil {
+    return fmt.Errorf("expected association of \"%s\" with profile %s at %s but got none", name, expected.Id, at)
+  }
+  if actual.Id != expected.Id {
+    return fmt.Errorf("expected association of \"%s\" with profile %s at %s but got profile %s", name, expected.Id, at, actual.Id)
+  }
+  if !strings.EqualFold(actual.Name, expected.Name) {
+    return fmt.Errorf("expected association to be named \"%s\" but got \"%s\"", expected.Name, actual.Name)
+  }
+  if actual.FirstSeenAt.Unix() != expected.FirstSeenAt.Unix() {
+    return fmt.Errorf("expected association of \"%s\" to be first seen at %s but got %s", name, expected.FirstSeenAt, actual.FirstSeenAt)
+  }
+  if actual.ValidUntil.Unix() != expected.ValidUntil.Unix() {
+    return fmt.Errorf("expected association of \"%s\" to be valid until %s but got %s", name, expected.ValidUntil, actual.ValidUntil)
+  }
+  return nil
+}
+
+// verifies that a retrieved name history matches the expected history
+func compareNameHistory(expected *entity.NameChangeHistory, actual *entity.NameChangeHistory) error {
+  if actual == nil {
+    return fmt.Errorf("expected name history but got none")
+  }
+  if len(actual.History) != len(expected.History) {
+    return fmt.Errorf("expected %d name change(s) but got %d", len(expected.History), len(actual.History))
+  }
+
+  for i, change := range expected.History {
+    other := actual.History[i]
+    if other.Name != change.Name {
+      return fmt.Errorf("expected name change #%d to \"%s\" but got \"%s\"", i, change.Name, other.Name)
+    }
+    if other.ChangedToAt.Unix() != change.ChangedToAt.Unix() {
+      return fmt.Errorf("expected name change #%d at %s but got %s", i, change.ChangedToAt, other.ChangedToAt)
+    }
+    if other.ValidUntil.Unix() != change.ValidUntil.Unix() {
+      return fmt.Errorf("expected name change #%d to be valid until %s but got %s", i, change.ValidUntil, other.ValidUntil)
+    }
+  }
+  return nil
+}
+
+// verifies that a retrieved profile matches the expected profile
+func compareProfile(expected *entity.Profile, actual *entity.Profile) error {
+  if actual == nil {
+    return fmt.Errorf("expected profile %s but got none", expected.Id)
+  }
+  if actual.Id != expected.Id || actual.Name != expected.Name {
+    return fmt.Errorf("expected profile %s (\"%s\") but got %s (\"%s\")", expected.Id, expected.Name, actual.Id, actual.Name)
+  }
+
+  if len(actual.Properties) != len(expected.Properties) {
+    return fmt.Errorf("expected %d propert(ies) but got %d", len(expected.Properties), len(actual.Properties))
+  }
+  for name, property := range expected.Properties {
+    other := actual.Properties[name]
+    if other == nil || *other != *property {
+      return fmt.Errorf("property \"%s\" does not match", name)
+    }
+  }
+
+  if expected.Textures == nil {
+    if actual.Textures != nil {
+      return fmt.Errorf("expected no textures")
+    }
+    return nil
+  }
+  if actual.Textures == nil {
+    return fmt.Errorf("expected textures but got none")
+  }
+  if actual.Textures.Timestamp.Unix() != expected.Textures.Timestamp.Unix() || actual.Textures.ProfileId != expected.Textures.ProfileId || actual.Textures.ProfileName != expected.Textures.ProfileName {
+    return fmt.Errorf("texture metadata does not match")
+  }
+  if len(actual.Textures.Textures) != len(expected.Textures.Textures) {
+    return fmt.Errorf("expected %d texture(s) but got %d", len(expected.Textures.Textures), len(actual.Textures.Textures))
+  }
+  for kind, url := range expected.Textures.Textures {
+    if actual.Textures.Textures[kind] != url {
+      return fmt.Errorf("texture \"%s\" does not match", kind)
+    }
+  }
+  return nil
+}
+
+// verifies whether two timelines contain the same periods
+func compareNameTimeline(expected *entity.NameTimeline, actual *entity.NameTimeline) error {
+  if actual == nil {
+    return fmt.Errorf("expected timeline of name \"%s\" but got nothing", expected.Name)
+  }
+  if actual.Name != expected.Name {
+    return fmt.Errorf("expected timeline of name \"%s\" but got \"%s\"", expected.Name, actual.Name)
+  }
+  if len(actual.Periods) != len(expected.Periods) {
+    return fmt.Errorf("expected %d period(s) but got %d", len(expected.Periods), len(actual.Periods))
+  }
+
+  for i, e := range expected.Periods {
+    a := actual.Periods[i]
+    if a.Id != e.Id || a.Name != e.Name || !a.From.Equal(e.From) || !a.Until.Equal(e.Until) || !a.UpdatedAt.Equal(e.UpdatedAt) {
+      return fmt.Errorf("period #%d differs: expected %+v but got %+v", i, *e, *a)
+    }
+  }
+  return nil
+}
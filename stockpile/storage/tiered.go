@@ -0,0 +1,362 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package storage
+
+import (
+  "fmt"
+  "sync"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/google/uuid"
+  "github.com/hashicorp/hcl2/gohcl"
+  "github.com/op/go-logging"
+)
+
+// defines the amount of entries retained within the l1 cache when no limit is configured
+const defaultL1MaxEntries = 10000
+
+// defines the maximum amount of time an entry is retained within the l1 cache when no limit is
+// configured
+const defaultL1Ttl = time.Minute * 5
+
+// provides a composite storage backend which keeps recently used entries within a size bounded
+// in-process cache (l1) in front of an arbitrary (typically remote) storage backend (l2)
+//
+// the l1 cache is invalidated whenever an entry is modified through this instance and, if the l2
+// backend supports it, whenever an entry is modified by another instance
+//
+// entries are copied whenever they are stored in or retrieved from the l1 cache as callers may
+// modify the instances they receive
+type TieredStorageBackend struct {
+  cfg    *server.Config
+  logger *logging.Logger
+
+  l1    *lruCache
+  l1Ttl time.Duration
+  l2    StorageBackend
+
+  // guards the population of the l1 cache against concurrent invalidations
+  // the generation is incremented with every invalidation and values which have been retrieved
+  // from l2 are discarded when an invalidation has occurred while they were being retrieved
+  mutex      sync.Mutex
+  generation uint64
+}
+
+type TieredStorageBackendCfg struct {
+  L1 *TieredL1Cfg          `hcl:"l1,block"`
+  L2 *server.StorageConfig `hcl:"l2,block"`
+}
+
+type TieredL1Cfg struct {
+  MaxEntries *int    `hcl:"max-entries,attr"`
+  Ttl        *string `hcl:"ttl,attr"`
+}
+
+// creates a factory for tiered storage backends which resolves its l2 backend using the passed
+// lookup function
+func NewTieredStorageBackendFactory(lookup FactoryLookup) Factory {
+  return func(cfg *server.Config) (StorageBackend, error) {
+    tieredCfg := &TieredStorageBackendCfg{}
+    diag := gohcl.DecodeBody(cfg.Storage.Parameters, server.EvalContext(), tieredCfg)
+    if diag.HasErrors() {
+      return nil, fmt.Errorf("illegal backend configuration: %s", diag.Error())
+    }
+
+    maxEntries := defaultL1MaxEntries
+    l1Ttl := defaultL1Ttl
+    if tieredCfg.L1 != nil {
+      if tieredCfg.L1.MaxEntries != nil {
+        maxEntries = *tieredCfg.L1.MaxEntries
+      }
+      if tieredCfg.L1.Ttl != nil {
+        ttl, err := time.ParseDuration(*tieredCfg.L1.Ttl)
+        if err != nil {
+          return nil, fmt.Errorf("illegal backend configuration: illegal l1 ttl: %s", err)
+        }
+        l1Ttl = ttl
+      }
+    }
+
+    l2, err := createDelegateBackend(cfg, lookup, "l2", tieredCfg.L2)
+    if err != nil {
+      return nil, err
+    }
+
+    backend := &TieredStorageBackend{
+      cfg:    cfg,
+      logger: logging.MustGetLogger("tiered"),
+      l1:     newLruCache(maxEntries, 0),
+      l1Ttl:  l1Ttl,
+      l2:     l2,
+    }
+
+    if observable, ok := l2.(ObservableStorageBackend); ok {
+      err = observable.SubscribeInvalidations(backend.Invalidate)
+    } else {
+      err = ErrObservationUnsupported
+    }
+    if err == ErrObservationUnsupported {
+      backend.logger.Warningf("l2 backend \"%s\" does not report remote modifications - l1 entries may be stale for up to %s when multiple instances share the backend", tieredCfg.L2.Type, l1Ttl)
+    } else if err != nil {
+      l2.Close()
+      return nil, fmt.Errorf("failed to subscribe to l2 invalidations: %s", err)
+    }
+
+    return backend, nil
+  }
+}
+
+// calculates the key of an l1 entry
+func l1Key(category string, key string) string {
+  return category + "/" + key
+}
+
+// calculates the amount of time an entry of a given type may be retained within the l1 cache
+func (t *TieredStorageBackend) ttl(typeTtl time.Duration) time.Duration {
+  if typeTtl < t.l1Ttl {
+    return typeTtl
+  }
+  return t.l1Ttl
+}
+
+// removes an entry from the l1 cache
+func (t *TieredStorageBackend) Invalidate(category string, key string) {
+  t.logger.Debugf("invalidating l1 entry %s/%s", category, key)
+
+  t.mutex.Lock()
+  defer t.mutex.Unlock()
+  t.generation++
+  t.l1.Remove(l1Key(category, key))
+}
+
+// retrieves the current l1 generation
+// this value is to be passed to populate once the respective value has been retrieved from l2
+func (t *TieredStorageBackend) currentGeneration() uint64 {
+  t.mutex.Lock()
+  defer t.mutex.Unlock()
+  return t.generation
+}
+
+// stores a value which has been retrieved from l2 within the l1 cache unless an invalidation has
+// occurred since the passed generation has been retrieved
+func (t *TieredStorageBackend) populate(generation uint64, key string, value interface{}, ttl time.Duration) {
+  t.mutex.Lock()
+  defer t.mutex.Unlock()
+  if t.generation != generation {
+    t.logger.Debugf("discarding l1 entry %s as it has been invalidated while it was retrieved", key)
+    return
+  }
+  t.l1.Put(key, value, 0, ttl)
+}
+
+func (t *TieredStorageBackend) InvalidateProfileId(name string) {
+  t.Invalidate("name", calculateHash(name))
+}
+
+func (t *TieredStorageBackend) InvalidateNameHistory(id uuid.UUID) {
+  t.Invalidate("history", id.String())
+}
+
+func (t *TieredStorageBackend) InvalidateProfile(id uuid.UUID) {
+  t.Invalidate("profile", id.String())
+}
+
+func (t *TieredStorageBackend) InvalidateBlacklist() {
+  t.Invalidate("misc", "blacklist")
+}
+
+// retrieves a snapshot of the l1 cache utilization and access statistics
+func (t *TieredStorageBackend) GetStatistics() *entity.StorageStatistics {
+  return t.l1.Statistics()
+}
+
+func (t *TieredStorageBackend) Ping() error {
+  return Ping(t.l2)
+}
+
+func (t *TieredStorageBackend) Close() error {
+  t.l1.Clear()
+  return t.l2.Close()
+}
+
+func (t *TieredStorageBackend) GetProfileId(name string, at time.Time) (*entity.ProfileId, error) {
+  key := l1Key("name", calculateHash(name))
+  if cached, ok := t.l1.Get(key); ok {
+    for _, profileId := range cached.([]*entity.ProfileId) {
+      if profileId.IsValid(at) {
+        return profileId.Copy(), nil
+      }
+    }
+  }
+
+  generation := t.currentGeneration()
+  profileId, err := t.l2.GetProfileId(name, at)
+  if err != nil || profileId == nil {
+    return profileId, err
+  }
+
+  // associations are only ever added to the list of known associations of a name as any
+  // modification within l2 results in the invalidation of the entire entry
+  // the cached list is never modified in place as it may be in use by concurrent readers
+  var associations []*entity.ProfileId
+  if cached, ok := t.l1.Get(key); ok {
+    associations = append(associations, cached.([]*entity.ProfileId)...)
+  }
+  t.populate(generation, key, append(associations, profileId.Copy()), t.ttl(t.cfg.GetTtl().Name))
+  return profileId, nil
+}
+
+func (t *TieredStorageBackend) PutProfileId(profileId *entity.ProfileId) error {
+  err := t.l2.PutProfileId(profileId)
+  t.Invalidate("name", calculateHash(profileId.Name))
+  return err
+}
+
+func (t *TieredStorageBackend) PurgeProfileId(name string, at time.Time) error {
+  err := t.l2.PurgeProfileId(name, at)
+  t.Invalidate("name", calculateHash(name))
+  return err
+}
+
+func (t *TieredStorageBackend) GetNameHistory(id uuid.UUID) (*entity.NameChangeHistory, error) {
+  key := l1Key("history", id.String())
+  if cached, ok := t.l1.Get(key); ok {
+    return cached.(*entity.NameChangeHistory).Copy(), nil
+  }
+
+  generation := t.currentGeneration()
+  history, err := t.l2.GetNameHistory(id)
+  if err != nil || history == nil {
+    return history, err
+  }
+
+  t.populate(generation, key, history.Copy(), t.ttl(t.cfg.GetTtl().NameHistory))
+  return history, nil
+}
+
+func (t *TieredStorageBackend) PutNameHistory(id uuid.UUID, history *entity.NameChangeHistory) error {
+  err := t.l2.PutNameHistory(id, history)
+  t.Invalidate("history", id.String())
+  return err
+}
+
+func (t *TieredStorageBackend) PurgeNameHistory(id uuid.UUID) error {
+  err := t.l2.PurgeNameHistory(id)
+  t.Invalidate("history", id.String())
+  return err
+}
+
+func (t *TieredStorageBackend) GetProfile(id uuid.UUID) (*entity.Profile, error) {
+  key := l1Key("profile", id.String())
+  if cached, ok := t.l1.Get(key); ok {
+    return cached.(*entity.Profile).Copy(), nil
+  }
+
+  generation := t.currentGeneration()
+  profile, err := t.l2.GetProfile(id)
+  if err != nil || profile == nil {
+    return profile, err
+  }
+
+  t.populate(generation, key, profile.Copy(), t.ttl(t.cfg.GetTtl().Profile))
+  return profile, nil
+}
+
+func (t *TieredStorageBackend) PutProfile(profile *entity.Profile) error {
+  err := t.l2.PutProfile(profile)
+  t.Invalidate("profile", profile.Id.String())
+  return err
+}
+
+func (t *TieredStorageBackend) PurgeProfile(id uuid.UUID) error {
+  err := t.l2.PurgeProfile(id)
+  t.Invalidate("profile", id.String())
+  return err
+}
+
+func (t *TieredStorageBackend) GetBlacklist() (*entity.Blacklist, error) {
+  key := l1Key("misc", "blacklist")
+  if cached, ok := t.l1.Get(key); ok {
+    return cached.(*entity.Blacklist).Copy(), nil
+  }
+
+  generation := t.currentGeneration()
+  blacklist, err := t.l2.GetBlacklist()
+  if err != nil || blacklist == nil {
+    return blacklist, err
+  }
+
+  t.populate(generation, key, blacklist.Copy(), t.ttl(t.cfg.GetTtl().Blacklist))
+  return blacklist, nil
+}
+
+func (t *TieredStorageBackend) PutBlacklist(blacklist *entity.Blacklist) error {
+  err := t.l2.PutBlacklist(blacklist)
+  t.Invalidate("misc", "blacklist")
+  return err
+}
+
+func (t *TieredStorageBackend) PurgeBlacklist() error {
+  err := t.l2.PurgeBlacklist()
+  t.Invalidate("misc", "blacklist")
+  return err
+}
+
+func (t *TieredStorageBackend) GetNameTimeline(name string) (*entity.NameTimeline, error) {
+  l2, ok := t.l2.(TimelineStorageBackend)
+  if !ok {
+    return nil, ErrTimelineUnsupported
+  }
+
+  key := l1Key("timeline", calculateHash(name))
+  if cached, ok := t.l1.Get(key); ok {
+    return cached.(*entity.NameTimeline).Copy(), nil
+  }
+
+  generation := t.currentGeneration()
+  timeline, err := l2.GetNameTimeline(name)
+  if err != nil || timeline == nil {
+    return timeline, err
+  }
+
+  t.populate(generation, key, timeline.Copy(), t.ttl(t.cfg.GetTtl().Name))
+  return timeline, nil
+}
+
+func (t *TieredStorageBackend) PutNameTimeline(timeline *entity.NameTimeline) error {
+  l2, ok := t.l2.(TimelineStorageBackend)
+  if !ok {
+    return ErrTimelineUnsupported
+  }
+
+  err := l2.PutNameTimeline(timeline)
+  t.Invalidate("timeline", calculateHash(timeline.Name))
+  return err
+}
+
+func (t *TieredStorageBackend) PurgeNameTimeline(name string) error {
+  l2, ok := t.l2.(TimelineStorageBackend)
+  if !ok {
+    return ErrTimelineUnsupported
+  }
+
+  err := l2.PurgeNameTimeline(name)
+  t.Invalidate("timeline", calculateHash(name))
+  return err
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package storage_test
+
+import (
+  "errors"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/dotStart/Stockpile/stockpile/storage"
+  "github.com/google/uuid"
+)
+
+// provides an l2 backend which reports remote modifications through a previously registered
+// handler
+type observableStorageBackend struct {
+  storage.StorageBackend
+  handler storage.InvalidationHandler
+  err     error
+}
+
+func (b *observableStorageBackend) SubscribeInvalidations(handler storage.InvalidationHandler) error {
+  b.handler = handler
+  return b.err
+}
+
+// creates a tiered backend from a given configuration which stores its l2 entries within an
+// observable memory backend
+func newObservableTieredBackend(t *testing.T, f *servertest.Fixture, src string, subscribeErr error) (storage.StorageBackend, *observableStorageBackend, error) {
+  l2 := &observableStorageBackend{err: subscribeErr}
+  backend, err := storage.NewTieredStorageBackendFactory(func(id string) storage.Factory {
+    if id == "mem" {
+      return func(cfg *server.Config) (storage.StorageBackend, error) {
+        mem, err := storage.NewMemoryStorageBackend(cfg)
+        l2.StorageBackend = mem
+        return l2, err
+      }
+    }
+    return lookup(id)
+  })(f.Load(src))
+  return backend, l2, err
+}
+
+// retrieves a profile and fails the test unless it carries the expected name
+func expectProfileName(t *testing.T, backend storage.StorageBackend, id uuid.UUID, expected string, msg string) {
+  profile, err := backend.GetProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile == nil || profile.Name != expected {
+    t.Fatalf("%s: expected profile \"%s\" but got %v", msg, expected, profile)
+  }
+}
+
+func TestTieredStorageBackendInvalidation(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  backend, l2, err := newObservableTieredBackend(t, f, `storage "tiered" {
+    l2 "mem" {}
+  }`, nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer backend.Close()
+  if l2.handler == nil {
+    t.Fatal("expected tiered backend to subscribe to l2 invalidations")
+  }
+
+  id := uuid.New()
+  err = backend.PutProfile(&entity.Profile{Id: id, Name: "Notch"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  expectProfileName(t, backend, id, "Notch", "initial retrieval")
+
+  // modifications which bypass the tiered backend remain invisible until they are reported
+  err = l2.StorageBackend.PutProfile(&entity.Profile{Id: id, Name: "jeb_"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  expectProfileName(t, backend, id, "Notch", "unreported modification")
+
+  l2.handler("profile", id.String())
+  expectProfileName(t, backend, id, "jeb_", "remote invalidation")
+
+  // modifications through the tiered backend invalidate l1 immediately
+  err = backend.PutProfile(&entity.Profile{Id: id, Name: "Dinnerbone"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  expectProfileName(t, backend, id, "Dinnerbone", "local modification")
+
+  err = backend.PurgeProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  profile, err := backend.GetProfile(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if profile != nil {
+    t.Fatalf("expected purged profile to be evicted from l1 but got %v", profile)
+  }
+
+  // histories and blacklists are invalidated using their respective categories
+  history := &entity.NameChangeHistory{History: []*entity.NameChange{{Name: "Notch"}}}
+  err = backend.PutNameHistory(id, history)
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = backend.GetNameHistory(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = l2.StorageBackend.PutNameHistory(id, &entity.NameChangeHistory{History: []*entity.NameChange{{Name: "jeb_"}}})
+  if err != nil {
+    t.Fatal(err)
+  }
+  l2.handler("history", id.String())
+  history, err = backend.GetNameHistory(id)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if history == nil || len(history.History) != 1 || history.History[0].Name != "jeb_" {
+    t.Fatalf("expected invalidated history to be retrieved from l2 but got %v", history)
+  }
+
+  err = backend.PutBlacklist(&entity.Blacklist{Hashes: []string{strings.Repeat("a", 40)}})
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = backend.GetBlacklist()
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = l2.StorageBackend.PutBlacklist(&entity.Blacklist{Hashes: []string{strings.Repeat("a", 40), strings.Repeat("b", 40)}})
+  if err != nil {
+    t.Fatal(err)
+  }
+  l2.handler("misc", "blacklist")
+  blacklist, err := backend.GetBlacklist()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if blacklist == nil || len(blacklist.Hashes) != 2 {
+    t.Fatalf("expected invalidated blacklist to be retrieved from l2 but got %v", blacklist)
+  }
+}
+
+func TestTieredStorageBackendSubscriptionFailure(t *testing.T) {
+  f := servertest.NewFixture(t)
+  defer f.Remove()
+
+  _, _, err := newObservableTieredBackend(t, f, `storage "tiered" {
+    l2 "mem" {}
+  }`, errors.New("connection refused"))
+  if err == nil || !strings.Contains(err.Error(), "connection refused") {
+    t.Fatalf("expected subscription failure to be reported but got: %v", err)
+  }
+
+  // backends which cannot report remote modifications are accepted (with a warning)
+  backend, l2 := newTieredTestBackend(t, f)
+  defer backend.Close()
+  if l2.StorageBackend == nil {
+    t.Fatal("expected l2 backend to be created")
+  }
+}
+
+func TestTieredStorageBackendTtl(t *testing.T) {
+  tests := []struct {
+    name string
+    src  string
+  }{
+    // l1 entries never outlive the TTL of their type
+    {"type", `storage "tiered" {
+      l1 {
+        ttl = "1h"
+      }
+      l2 "mem" {}
+    }
+
+    ttl {
+      profile = "100ms"
+    }`},
+    // l1 entries expire after the l1 TTL even if their type permits longer retention
+    {"l1", `storage "tiered" {
+      l1 {
+        ttl = "100ms"
+      }
+      l2 "mem" {}
+    }`},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      f := servertest.NewFixture(t)
+      defer f.Remove()
+
+      backend, l2, err := newObservableTieredBackend(t, f, test.src, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      defer backend.Close()
+
+      id := uuid.New()
+      err = backend.PutProfile(&entity.Profile{Id: id, Name: "Notch"})
+      if err != nil {
+        t.Fatal(err)
+      }
+      expectProfileName(t, backend, id, "Notch", "initial retrieval")
+
+      err = l2.StorageBackend.PutProfile(&entity.Profile{Id: id, Name: "jeb_"})
+      if err != nil {
+        t.Fatal(err)
+      }
+      expectProfileName(t, backend, id, "Notch", "retrieval from l1")
+
+      // the modification is re-applied as it may have expired within l2 as well
+      time.Sleep(150 * time.Millisecond)
+      err = l2.StorageBackend.PutProfile(&entity.Profile{Id: id, Name: "jeb_"})
+      if err != nil {
+        t.Fatal(err)
+      }
+      expectProfileName(t, backend, id, "jeb_", "retrieval after l1 expiry")
+    })
+  }
+}
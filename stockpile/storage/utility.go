@@ -20,22 +20,20 @@ import (
   "crypto/sha1"
   "encoding/hex"
   "strings"
-  "time"
 )
 
-// provides a primitive wrapper object which handles expiration in the memory storage backend
-type expirationWrapper struct {
-  content   interface{}
-  createdAt time.Time
-}
-
-// evaluates whether a particular entry is still considered valid
-func (w *expirationWrapper) isValid(ttl time.Duration) bool {
-  return time.Since(w.createdAt) <= ttl
-}
-
 // calculates a unified cache for a given input value (typically for primitive built-in cache types)
 func calculateHash(input string) string {
   enc := sha1.Sum([]byte(strings.ToLower(input)))
   return hex.EncodeToString(enc[:])
 }
+
+// verifies whether a given backend is reachable (backends which do not rely on external services
+// are always considered reachable)
+func Ping(backend StorageBackend) error {
+  pingable, ok := backend.(PingableStorageBackend)
+  if !ok {
+    return nil
+  }
+  return pingable.Ping()
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package tracing
+
+import (
+  "context"
+
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+  sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// creates an exporter which submits spans to an OTLP collector via HTTP
+func newExporter(cfg *server.TracingConfig) (sdktrace.SpanExporter, error) {
+  opts := []otlptracehttp.Option{
+    otlptracehttp.WithEndpoint(cfg.Endpoint),
+  }
+  if cfg.Insecure != nil && *cfg.Insecure {
+    opts = append(opts, otlptracehttp.WithInsecure())
+  }
+  if cfg.Path != nil {
+    opts = append(opts, otlptracehttp.WithURLPath(*cfg.Path))
+  }
+  if cfg.Headers != nil {
+    opts = append(opts, otlptracehttp.WithHeaders(*cfg.Headers))
+  }
+
+  return otlptracehttp.New(context.Background(), opts...)
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package tracing
+
+import (
+  "context"
+
+  "go.opentelemetry.io/otel"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/trace"
+  "google.golang.org/grpc"
+  "google.golang.org/grpc/metadata"
+  "google.golang.org/grpc/status"
+)
+
+// provides a propagation carrier which operates on incoming gRPC metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+  values := metadata.MD(c).Get(key)
+  if len(values) == 0 {
+    return ""
+  }
+  return values[0]
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+  metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+  keys := make([]string, 0, len(c))
+  for key := range c {
+    keys = append(keys, key)
+  }
+  return keys
+}
+
+// starts a server span for a gRPC call which continues the trace of the caller (if any)
+func startServerSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+  if md, ok := metadata.FromIncomingContext(ctx); ok {
+    ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+  }
+
+  return Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+    attribute.String("rpc.system", "grpc"),
+    attribute.String("rpc.method", method),
+  ))
+}
+
+// ends a server span and records the resulting status code
+func endServerSpan(span trace.Span, err error) {
+  code := status.Code(err)
+  span.SetAttributes(attribute.String("rpc.grpc.status_code", code.String()))
+  if err != nil {
+    span.RecordError(err)
+    span.SetStatus(codes.Error, err.Error())
+  }
+  span.End()
+}
+
+// creates a span for every unary gRPC call
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+  ctx, span := startServerSpan(ctx, info.FullMethod)
+  res, err := handler(ctx, req)
+  endServerSpan(span, err)
+  return res, err
+}
+
+// replaces the context of a server stream with a context which carries the call span
+type tracedServerStream struct {
+  grpc.ServerStream
+  ctx context.Context
+}
+
+func (s *tracedServerStream) Context() context.Context {
+  return s.ctx
+}
+
+// creates a span for every streaming gRPC call
+func StreamServerInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+  ctx, span := startServerSpan(stream.Context(), info.FullMethod)
+  err := handler(srv, &tracedServerStream{
+    ServerStream: stream,
+    ctx:          ctx,
+  })
+  endServerSpan(span, err)
+  return err
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package tracing
+
+import (
+  "context"
+  "errors"
+  "sort"
+  "testing"
+
+  "go.opentelemetry.io/otel"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/propagation"
+  sdktrace "go.opentelemetry.io/otel/sdk/trace"
+  "go.opentelemetry.io/otel/sdk/trace/tracetest"
+  "go.opentelemetry.io/otel/trace"
+  "google.golang.org/grpc"
+  "google.golang.org/grpc/metadata"
+)
+
+// installs a tracer provider which records all spans along with the trace context propagator
+func setupRecorder() *tracetest.SpanRecorder {
+  recorder := tracetest.NewSpanRecorder()
+  provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+  otel.SetTracerProvider(provider)
+  otel.SetTextMapPropagator(propagation.TraceContext{})
+  return recorder
+}
+
+func TestMetadataCarrier(t *testing.T) {
+  md := metadata.MD{}
+  carrier := metadataCarrier(md)
+
+  carrier.Set("Traceparent", "value")
+  carrier.Set("tracestate", "state")
+  if value := carrier.Get("traceparent"); value != "value" {
+    t.Errorf("expected keys to be case insensitive but got \"%s\"", value)
+  }
+  if value := carrier.Get("missing"); value != "" {
+    t.Errorf("expected missing key to be empty but got \"%s\"", value)
+  }
+  if values := md.Get("tracestate"); len(values) != 1 || values[0] != "state" {
+    t.Errorf("expected values to be written to the underlying metadata but got %v", values)
+  }
+
+  keys := carrier.Keys()
+  sort.Strings(keys)
+  if len(keys) != 2 || keys[0] != "traceparent" || keys[1] != "tracestate" {
+    t.Errorf("expected keys traceparent and tracestate but got %v", keys)
+  }
+}
+
+// the trace of a caller is continued by the spans of the calls it issues
+func TestUnaryServerInterceptorPropagation(t *testing.T) {
+  recorder := setupRecorder()
+
+  parentCtx, parent := otel.Tracer("test").Start(context.Background(), "client")
+  md := metadata.MD{}
+  otel.GetTextMapPropagator().Inject(parentCtx, metadataCarrier(md))
+  parent.End()
+  if len(md.Get("traceparent")) == 0 {
+    t.Fatal("expected trace context to be injected into metadata")
+  }
+
+  var handlerSpan trace.SpanContext
+  failure := errors.New("failure")
+  _, err := UnaryServerInterceptor(metadata.NewIncomingContext(context.Background(), md), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Method"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+    handlerSpan = trace.SpanContextFromContext(ctx)
+    return nil, failure
+  })
+  if err != failure {
+    t.Fatalf("expected handler error to be passed on but got %v", err)
+  }
+
+  spans := recorder.Ended()
+  if len(spans) != 2 {
+    t.Fatalf("expected 2 spans but got %d", len(spans))
+  }
+  server := spans[1]
+  if server.Name() != "/test/Method" || server.SpanKind() != trace.SpanKindServer {
+    t.Errorf("expected server span for /test/Method but got %s span %s", server.SpanKind(), server.Name())
+  }
+  if server.Parent().SpanID() != parent.SpanContext().SpanID() || server.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+    t.Errorf("expected server span to continue the trace of the caller")
+  }
+  if handlerSpan.SpanID() != server.SpanContext().SpanID() {
+    t.Errorf("expected handler to receive the server span")
+  }
+  if server.Status().Code != codes.Error {
+    t.Errorf("expected failed call to be recorded as error but got %s", server.Status().Code)
+  }
+}
+
+// calls without trace context begin a new trace
+func TestUnaryServerInterceptorWithoutMetadata(t *testing.T) {
+  recorder := setupRecorder()
+
+  _, err := UnaryServerInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Method"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+    return nil, nil
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  spans := recorder.Ended()
+  if len(spans) != 1 {
+    t.Fatalf("expected 1 span but got %d", len(spans))
+  }
+  if spans[0].Parent().IsValid() {
+    t.Errorf("expected a new trace to be started")
+  }
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package tracing
+
+import (
+  "context"
+
+  "github.com/dotStart/Stockpile/stockpile/metadata"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "go.opentelemetry.io/otel"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/propagation"
+  "go.opentelemetry.io/otel/sdk/resource"
+  sdktrace "go.opentelemetry.io/otel/sdk/trace"
+  "go.opentelemetry.io/otel/trace"
+)
+
+// identifies the spans created by stockpile
+const instrumentationName = "github.com/dotStart/Stockpile"
+
+// retrieves the tracer which is used to create all spans within the application
+// when tracing has not been configured, all spans will be discarded
+func Tracer() trace.Tracer {
+  return otel.Tracer(instrumentationName)
+}
+
+// starts a new span as a child of the span within the passed context (if any)
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+  return Tracer().Start(ctx, name, opts...)
+}
+
+// ends a span and marks it as failed when an error is passed
+func End(span trace.Span, err error) {
+  if err != nil {
+    span.RecordError(err)
+    span.SetStatus(codes.Error, err.Error())
+  }
+  span.End()
+}
+
+// configures the global tracer provider based on the passed configuration and returns a function
+// which flushes all pending spans on shutdown
+// when no tracing configuration is present, spans are discarded and a no-op function is returned
+func Setup(cfg *server.Config) (func(ctx context.Context) error, error) {
+  otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+  if cfg.Tracing == nil {
+    return func(ctx context.Context) error { return nil }, nil
+  }
+
+  exporter, err := newExporter(cfg.Tracing)
+  if err != nil {
+    return nil, err
+  }
+
+  serviceName := server.DefaultTracingServiceName
+  if cfg.Tracing.ServiceName != nil {
+    serviceName = *cfg.Tracing.ServiceName
+  }
+  sampleRatio := 1.0
+  if cfg.Tracing.SampleRatio != nil {
+    sampleRatio = *cfg.Tracing.SampleRatio
+  }
+
+  provider := sdktrace.NewTracerProvider(
+    sdktrace.WithBatcher(exporter),
+    sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+    sdktrace.WithResource(resource.NewSchemaless(
+      attribute.String("service.name", serviceName),
+      attribute.String("service.version", metadata.VersionFull()),
+    )),
+  )
+  otel.SetTracerProvider(provider)
+  return provider.Shutdown, nil
+}
@@ -75,6 +75,7 @@ const app = new Vue({
     connected: false,
 
     rateLimitAllocation: 0,
+    rateLimit: 600,
     version: '',
     plugins: [],
     pluginsUnavailable: false,
@@ -96,10 +97,10 @@ const app = new Vue({
       return addr + ':80'
     },
     rateLimitLabel: function () {
-      return `Rate Limit: ${this.rateLimitAllocation} / 600`
+      return `Rate Limit: ${this.rateLimitAllocation} / ${this.rateLimit}`
     },
     rateLimitPercent: function () {
-      return this.rateLimitAllocation / 600 * 100
+      return this.rateLimitAllocation / this.rateLimit * 100
     }
   }
 });
@@ -126,6 +127,7 @@ socket.on('system', (sys) => {
   }
 
   app.version = sys.version;
+  app.rateLimit = sys.rateLimit;
 });
 
 socket.on('rate-limit', (allocation) => {
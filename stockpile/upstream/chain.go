@@ -0,0 +1,228 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package upstream
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "strings"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/google/uuid"
+  "github.com/hashicorp/hcl2/gohcl"
+)
+
+// provides a composite upstream provider which consults an ordered list of providers
+//
+// requests are passed on to the next provider when a provider does not know the requested resource
+// or does not support the request at all while errors are reported to the caller immediately (in
+// order to prevent outages of a provider from being masked by the providers which follow it)
+type ChainedUpstream struct {
+  links []*chainLink
+}
+
+type chainLink struct {
+  id       string
+  provider Upstream
+}
+
+type ChainedUpstreamCfg struct {
+  Providers []*server.UpstreamConfig `hcl:"provider,block"`
+}
+
+// creates a factory for chained upstream providers which resolves its providers using the passed
+// lookup function
+func NewChainedUpstreamFactory(lookup FactoryLookup) Factory {
+  return func(cfg *server.Config) (Upstream, error) {
+    chainCfg := &ChainedUpstreamCfg{}
+    if cfg.Upstream.Parameters != nil {
+      diag := gohcl.DecodeBody(cfg.Upstream.Parameters, server.EvalContext(), chainCfg)
+      if diag.HasErrors() {
+        return nil, fmt.Errorf("illegal provider configuration: %s", diag.Error())
+      }
+    }
+    if len(chainCfg.Providers) == 0 {
+      return nil, errors.New("illegal provider configuration: at least one provider is required")
+    }
+
+    chain := &ChainedUpstream{
+      links: make([]*chainLink, 0, len(chainCfg.Providers)),
+    }
+    for _, providerCfg := range chainCfg.Providers {
+      factory := lookup(providerCfg.Type)
+      if factory == nil {
+        chain.Close()
+        return nil, fmt.Errorf("illegal provider configuration: no such provider: %s", providerCfg.Type)
+      }
+
+      provider, err := factory(cfg.WithUpstream(providerCfg))
+      if err != nil {
+        chain.Close()
+        return nil, fmt.Errorf("failed to initialize provider \"%s\": %s", providerCfg.Type, err)
+      }
+      chain.links = append(chain.links, &chainLink{
+        id:       providerCfg.Type,
+        provider: provider,
+      })
+    }
+    return chain, nil
+  }
+}
+
+// invokes the passed function for every provider (in order) until one of them reports a result
+func (c *ChainedUpstream) each(fn func(provider Upstream) (bool, error)) error {
+  for _, link := range c.links {
+    found, err := fn(link.provider)
+    if err == ErrUnsupported {
+      continue
+    }
+    if err != nil {
+      return fmt.Errorf("%s: %s", link.id, err)
+    }
+    if found {
+      return nil
+    }
+  }
+  return nil
+}
+
+func (c *ChainedUpstream) GetId(ctx context.Context, name string, at time.Time) (*entity.ProfileId, error) {
+  var id *entity.ProfileId
+  err := c.each(func(provider Upstream) (bool, error) {
+    var err error
+    id, err = provider.GetId(ctx, name, at)
+    return id != nil, err
+  })
+  if err != nil {
+    return nil, err
+  }
+  return id, nil
+}
+
+// names which are unknown to a provider are passed on to the next provider in a single request
+func (c *ChainedUpstream) BulkGetId(ctx context.Context, names []string) ([]*entity.ProfileId, error) {
+  ids := make([]*entity.ProfileId, 0, len(names))
+  err := c.each(func(provider Upstream) (bool, error) {
+    resolved, err := provider.BulkGetId(ctx, names)
+    if err != nil {
+      return false, err
+    }
+
+    ids = append(ids, resolved...)
+    names = unresolvedNames(names, resolved)
+    return len(names) == 0, nil
+  })
+  if err != nil {
+    return nil, err
+  }
+  return ids, nil
+}
+
+func (c *ChainedUpstream) GetHistory(ctx context.Context, id uuid.UUID) (*entity.NameChangeHistory, error) {
+  var history *entity.NameChangeHistory
+  err := c.each(func(provider Upstream) (bool, error) {
+    var err error
+    history, err = provider.GetHistory(ctx, id)
+    return history != nil, err
+  })
+  if err != nil {
+    return nil, err
+  }
+  return history, nil
+}
+
+func (c *ChainedUpstream) GetProfile(ctx context.Context, id uuid.UUID) (*entity.Profile, error) {
+  var profile *entity.Profile
+  err := c.each(func(provider Upstream) (bool, error) {
+    var err error
+    profile, err = provider.GetProfile(ctx, id)
+    return profile != nil, err
+  })
+  if err != nil {
+    return nil, err
+  }
+  return profile, nil
+}
+
+func (c *ChainedUpstream) GetBlacklist(ctx context.Context) (*entity.Blacklist, error) {
+  var blacklist *entity.Blacklist
+  err := c.each(func(provider Upstream) (bool, error) {
+    var err error
+    blacklist, err = provider.GetBlacklist(ctx)
+    return blacklist != nil, err
+  })
+  if err != nil {
+    return nil, err
+  }
+  return blacklist, nil
+}
+
+func (c *ChainedUpstream) Login(ctx context.Context, displayName string, serverId string, ip string) (*entity.Profile, error) {
+  var profile *entity.Profile
+  err := c.each(func(provider Upstream) (bool, error) {
+    var err error
+    profile, err = provider.Login(ctx, displayName, serverId, ip)
+    return profile != nil, err
+  })
+  if err != nil {
+    return nil, err
+  }
+  return profile, nil
+}
+
+// reports the first provider which is currently unable to serve requests
+func (c *ChainedUpstream) Ping() error {
+  for _, link := range c.links {
+    if pingable, ok := link.provider.(PingableUpstream); ok {
+      if err := pingable.Ping(); err != nil {
+        return fmt.Errorf("%s: %s", link.id, err)
+      }
+    }
+  }
+  return nil
+}
+
+// closes all providers within the chain
+// the first error is reported once all providers have been closed
+func (c *ChainedUpstream) Close() error {
+  var err error
+  for _, link := range c.links {
+    if closeErr := Close(link.provider); closeErr != nil && err == nil {
+      err = fmt.Errorf("%s: %s", link.id, closeErr)
+    }
+  }
+  return err
+}
+
+// removes all names which have been resolved by a provider from a list of names
+func unresolvedNames(names []string, resolved []*entity.ProfileId) []string {
+  known := make(map[string]bool)
+  for _, id := range resolved {
+    known[strings.ToLower(id.Name)] = true
+  }
+
+  remaining := make([]string, 0, len(names))
+  for _, name := range names {
+    if !known[strings.ToLower(name)] {
+      remaining = append(remaining, name)
+    }
+  }
+  return remaining
+}
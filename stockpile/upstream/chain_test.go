@@ -0,0 +1,206 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package upstream
+
+import (
+  "context"
+  "errors"
+  "testing"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/dotStart/Stockpile/stockpile/server/servertest"
+  "github.com/google/uuid"
+)
+
+// provides a provider which resolves a fixed set of names and keeps track of the names it has been
+// asked for
+type staticUpstream struct {
+  names     map[string]uuid.UUID
+  err       error
+  requested []string
+}
+
+func (u *staticUpstream) GetId(ctx context.Context, name string, at time.Time) (*entity.ProfileId, error) {
+  ids, err := u.BulkGetId(ctx, []string{name})
+  if err != nil || len(ids) == 0 {
+    return nil, err
+  }
+  return ids[0], nil
+}
+
+func (u *staticUpstream) BulkGetId(ctx context.Context, names []string) ([]*entity.ProfileId, error) {
+  u.requested = append(u.requested, names...)
+  if u.err != nil {
+    return nil, u.err
+  }
+
+  ids := make([]*entity.ProfileId, 0, len(names))
+  for _, name := range names {
+    if id, ok := u.names[name]; ok {
+      ids = append(ids, &entity.ProfileId{Id: id, Name: name})
+    }
+  }
+  return ids, nil
+}
+
+func (u *staticUpstream) GetHistory(ctx context.Context, id uuid.UUID) (*entity.NameChangeHistory, error) {
+  return nil, ErrUnsupported
+}
+
+func (u *staticUpstream) GetProfile(ctx context.Context, id uuid.UUID) (*entity.Profile, error) {
+  return nil, ErrUnsupported
+}
+
+func (u *staticUpstream) GetBlacklist(ctx context.Context) (*entity.Blacklist, error) {
+  return nil, ErrUnsupported
+}
+
+func (u *staticUpstream) Login(ctx context.Context, displayName string, serverId string, ip string) (*entity.Profile, error) {
+  return nil, ErrUnsupported
+}
+
+// provides a provider which keeps track of whether it has been closed
+type closableUpstream struct {
+  staticUpstream
+  closed   int
+  closeErr error
+}
+
+func (u *closableUpstream) Close() error {
+  u.closed++
+  return u.closeErr
+}
+
+// loads a configuration which selects a chain of providers
+func loadChainConfig(t *testing.T, providers string) *server.Config {
+  return servertest.LoadConfig(t, "upstream \"chain\" {\n"+providers+"\n}\n")
+}
+
+// creates a chain which consists of a given set of providers (in order)
+func newChain(t *testing.T, providers ...Upstream) Upstream {
+  ids := []string{"first", "second", "third"}[:len(providers)]
+  factory := NewChainedUpstreamFactory(func(id string) Factory {
+    for i, provider := range providers {
+      if ids[i] == id {
+        provider := provider
+        return func(*server.Config) (Upstream, error) { return provider, nil }
+      }
+    }
+    return nil
+  })
+
+  src := ""
+  for _, id := range ids {
+    src += "provider \"" + id + "\" {}\n"
+  }
+  chain, err := factory(loadChainConfig(t, src))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return chain
+}
+
+func TestChainFallThrough(t *testing.T) {
+  first := &staticUpstream{names: map[string]uuid.UUID{"a": uuid.New()}}
+  second := &staticUpstream{names: map[string]uuid.UUID{"a": uuid.New(), "b": uuid.New()}}
+  chain := newChain(t, first, second)
+
+  id, err := chain.GetId(context.Background(), "a", time.Now())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if id == nil || id.Id != first.names["a"] {
+    t.Errorf("expected first provider to answer but got %v", id)
+  }
+  id, err = chain.GetId(context.Background(), "b", time.Now())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if id == nil || id.Id != second.names["b"] {
+    t.Errorf("expected unknown names to be passed on but got %v", id)
+  }
+
+  // only names which remain unresolved are passed on
+  second.requested = nil
+  ids, err := chain.BulkGetId(context.Background(), []string{"a", "b", "c"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(ids) != 2 {
+    t.Errorf("expected 2 names to be resolved but got %d", len(ids))
+  }
+  if len(second.requested) != 2 || second.requested[0] != "b" || second.requested[1] != "c" {
+    t.Errorf("expected unresolved names to be passed on but got %v", second.requested)
+  }
+
+  // unsupported requests are passed on as well
+  profile, err := chain.GetProfile(context.Background(), uuid.New())
+  if err != nil || profile != nil {
+    t.Errorf("expected unsupported request to be ignored but got %v and %v", profile, err)
+  }
+}
+
+func TestChainError(t *testing.T) {
+  first := &staticUpstream{err: errors.New("failure")}
+  second := &staticUpstream{names: map[string]uuid.UUID{"a": uuid.New()}}
+  chain := newChain(t, first, second)
+
+  // errors are reported immediately as outages must not be masked by later providers
+  _, err := chain.GetId(context.Background(), "a", time.Now())
+  if err == nil || err.Error() != "first: failure" {
+    t.Errorf("expected error of first provider to be reported but got: %v", err)
+  }
+  if len(second.requested) != 0 {
+    t.Errorf("expected second provider not to be consulted but got %v", second.requested)
+  }
+}
+
+func TestChainClose(t *testing.T) {
+  first := &closableUpstream{closeErr: errors.New("failure")}
+  second := &closableUpstream{}
+  chain := newChain(t, first, &staticUpstream{}, second)
+
+  // providers are closed even when a preceding provider fails to close
+  err := Close(chain)
+  if err == nil || err.Error() != "first: failure" {
+    t.Errorf("expected error of first provider to be reported but got: %v", err)
+  }
+  if first.closed != 1 || second.closed != 1 {
+    t.Errorf("expected all providers to be closed once but got %d and %d", first.closed, second.closed)
+  }
+}
+
+func TestChainCloseOnFailure(t *testing.T) {
+  first := &closableUpstream{}
+  factory := NewChainedUpstreamFactory(func(id string) Factory {
+    if id != "first" {
+      return func(*server.Config) (Upstream, error) { return nil, errors.New("failure") }
+    }
+    return func(*server.Config) (Upstream, error) { return first, nil }
+  })
+
+  _, err := factory(loadChainConfig(t, `provider "first" {}
+  provider "second" {}`))
+  if err == nil {
+    t.Fatal("expected chain to fail")
+  }
+  if first.closed != 1 {
+    t.Error("expected previously initialized providers to be closed")
+  }
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package upstream
+
+import (
+  "context"
+  "errors"
+  "io"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/google/uuid"
+)
+
+// indicates that a provider is unable to serve a given kind of request (chained providers pass
+// such requests on to the next provider)
+var ErrUnsupported = errors.New("upstream provider does not support this request")
+
+// provides a factory for upstream provider instances
+type Factory = func(*server.Config) (Upstream, error)
+
+// provides a lookup function which resolves upstream provider factories based on their identifier
+type FactoryLookup = func(id string) Factory
+
+// provides an abstraction layer between the cache and the source of its data (typically the
+// Mojang API)
+//
+// providers indicate that a resource does not exist by returning nil without an error
+type Upstream interface {
+  // retrieves the profile id associated with a given name at the specified time
+  GetId(ctx context.Context, name string, at time.Time) (*entity.ProfileId, error)
+  // resolves a list of multiple names at the current time (names which are not associated with
+  // any profile are omitted from the result)
+  BulkGetId(ctx context.Context, names []string) ([]*entity.ProfileId, error)
+  // retrieves the complete name change history for a given profile
+  GetHistory(ctx context.Context, id uuid.UUID) (*entity.NameChangeHistory, error)
+  // retrieves the profile with a given id
+  GetProfile(ctx context.Context, id uuid.UUID) (*entity.Profile, error)
+  // retrieves the server blacklist
+  GetBlacklist(ctx context.Context) (*entity.Blacklist, error)
+  // performs the server-side phase of the online handshake
+  Login(ctx context.Context, displayName string, serverId string, ip string) (*entity.Profile, error)
+}
+
+// provides an optional extension to providers which rely on external services and are thus
+// capable of reporting whether they are currently able to serve requests
+type PingableUpstream interface {
+  // verifies whether the provider is currently able to serve requests and returns a descriptive
+  // error otherwise
+  Ping() error
+}
+
+// releases the resources held by a provider (e.g. connections or plugin processes)
+// providers may implement io.Closer when they need to be notified of their shutdown
+func Close(provider Upstream) error {
+  if closer, ok := provider.(io.Closer); ok {
+    return closer.Close()
+  }
+  return nil
+}
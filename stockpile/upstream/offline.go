@@ -0,0 +1,194 @@
+/*
+ * Copyright 2018 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package upstream
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "path"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/dotStart/Stockpile/entity"
+  "github.com/dotStart/Stockpile/stockpile/server"
+  "github.com/google/uuid"
+  "github.com/hashicorp/hcl2/gohcl"
+)
+
+// defines the amount of names which are retained in order to resolve offline identifiers when no
+// limit is configured
+const defaultOfflineMaxNames = 10000
+
+// provides an upstream provider which answers requests for offline mode (e.g. non-authenticated)
+// servers using the identifiers such servers derive from player names
+//
+// names are answered when they match one of the configured patterns or, when the provider serves
+// an entire offline realm, regardless of their value - all other names are reported as unknown
+// (and are thus passed on to the next provider when chained)
+//
+// as offline identifiers cannot be reversed, histories and profiles are only available for names
+// which have been resolved by this instance (up to the configured limit)
+type OfflineUpstream struct {
+  realm    bool
+  patterns []string
+
+  namesMutex sync.RWMutex
+  names      map[uuid.UUID]string
+  order      []uuid.UUID
+  next       int
+}
+
+type OfflineUpstreamCfg struct {
+  Realm    *bool     `hcl:"realm,attr"`
+  Patterns *[]string `hcl:"patterns,attr"`
+  MaxNames *int      `hcl:"max-names,attr"`
+}
+
+func NewOfflineUpstream(cfg *server.Config) (Upstream, error) {
+  offlineCfg := &OfflineUpstreamCfg{}
+  if cfg.Upstream.Parameters != nil {
+    diag := gohcl.DecodeBody(cfg.Upstream.Parameters, server.EvalContext(), offlineCfg)
+    if diag.HasErrors() {
+      return nil, fmt.Errorf("illegal provider configuration: %s", diag.Error())
+    }
+  }
+
+  provider := &OfflineUpstream{
+    realm:    offlineCfg.Realm != nil && *offlineCfg.Realm,
+    patterns: make([]string, 0),
+  }
+  if offlineCfg.Patterns != nil {
+    for _, pattern := range *offlineCfg.Patterns {
+      pattern = strings.ToLower(pattern)
+      if _, err := path.Match(pattern, ""); err != nil {
+        return nil, fmt.Errorf("illegal provider configuration: illegal pattern \"%s\": %s", pattern, err)
+      }
+      provider.patterns = append(provider.patterns, pattern)
+    }
+  }
+  if !provider.realm && len(provider.patterns) == 0 {
+    return nil, errors.New("illegal provider configuration: either realm or patterns is required")
+  }
+
+  maxNames := defaultOfflineMaxNames
+  if offlineCfg.MaxNames != nil {
+    maxNames = *offlineCfg.MaxNames
+  }
+  if maxNames <= 0 {
+    return nil, errors.New("illegal provider configuration: max-names must be positive")
+  }
+  provider.names = make(map[uuid.UUID]string)
+  provider.order = make([]uuid.UUID, maxNames)
+  return provider, nil
+}
+
+// evaluates whether a given name is answered by this provider
+func (o *OfflineUpstream) matches(name string) bool {
+  if o.realm {
+    return true
+  }
+
+  name = strings.ToLower(name)
+  for _, pattern := range o.patterns {
+    if ok, _ := path.Match(pattern, name); ok {
+      return true
+    }
+  }
+  return false
+}
+
+// records the name of a resolved identifier (the oldest name is discarded when the limit is
+// exceeded)
+func (o *OfflineUpstream) remember(id uuid.UUID, name string) {
+  o.namesMutex.Lock()
+  defer o.namesMutex.Unlock()
+
+  if _, ok := o.names[id]; ok {
+    return
+  }
+  if evicted := o.order[o.next]; evicted != uuid.Nil {
+    delete(o.names, evicted)
+  }
+  o.order[o.next] = id
+  o.names[id] = name
+  o.next = (o.next + 1) % len(o.order)
+}
+
+// retrieves the name of a previously resolved identifier
+func (o *OfflineUpstream) lookup(id uuid.UUID) (string, bool) {
+  if !entity.IsOfflineId(id) {
+    return "", false
+  }
+
+  o.namesMutex.RLock()
+  defer o.namesMutex.RUnlock()
+  name, ok := o.names[id]
+  return name, ok
+}
+
+func (o *OfflineUpstream) GetId(ctx context.Context, name string, at time.Time) (*entity.ProfileId, error) {
+  if !o.matches(name) {
+    return nil, nil
+  }
+
+  profileId := entity.NewOfflineProfileId(name, at)
+  o.remember(profileId.Id, name)
+  return profileId, nil
+}
+
+func (o *OfflineUpstream) BulkGetId(ctx context.Context, names []string) ([]*entity.ProfileId, error) {
+  at := time.Now()
+  ids := make([]*entity.ProfileId, 0)
+  for _, name := range names {
+    if !o.matches(name) {
+      continue
+    }
+
+    profileId := entity.NewOfflineProfileId(name, at)
+    o.remember(profileId.Id, name)
+    ids = append(ids, profileId)
+  }
+  return ids, nil
+}
+
+func (o *OfflineUpstream) GetHistory(ctx context.Context, id uuid.UUID) (*entity.NameChangeHistory, error) {
+  name, ok := o.lookup(id)
+  if !ok {
+    return nil, nil
+  }
+  return entity.NewOfflineNameHistory(name), nil
+}
+
+func (o *OfflineUpstream) GetProfile(ctx context.Context, id uuid.UUID) (*entity.Profile, error) {
+  name, ok := o.lookup(id)
+  if !ok {
+    return nil, nil
+  }
+  return entity.NewOfflineProfile(name), nil
+}
+
+// offline mode servers do not consult the blacklist
+func (o *OfflineUpstream) GetBlacklist(ctx context.Context) (*entity.Blacklist, error) {
+  return nil, ErrUnsupported
+}
+
+// offline mode servers do not perform the online handshake
+func (o *OfflineUpstream) Login(ctx context.Context, displayName string, serverId string, ip string) (*entity.Profile, error) {
+  return nil, ErrUnsupported
+}